@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// acmeChallengePrefix is where ACME CAs fetch HTTP-01 challenge responses.
+// It is always at the root of the domain, whatever base_path is.
+const acmeChallengePrefix = "/.well-known/acme-challenge/"
+
+// acmeTokenPattern matches ACME tokens, which are base64url without padding
+var acmeTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// withACMEChallenge answers HTTP-01 challenges for acme_challenge_domains,
+// either by forwarding them to the application or from acme_challenge_dir,
+// so the application or a certbot next to binaryDeploy can obtain
+// certificates while binaryDeploy holds port 80. Everything else goes to
+// next.
+func withACMEChallenge(next http.Handler) http.Handler {
+	var challenges http.Handler
+	switch {
+	case appConfig().ACMEChallengeApp:
+		challenges = acmeAppProxy()
+	case appConfig().ACMEChallengeDir != "":
+		challenges = http.HandlerFunc(serveACMEChallengeFile)
+	default:
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, acmeChallengePrefix) || !acmeChallengeDomain(r.Host) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !acmeTokenPattern.MatchString(strings.TrimPrefix(r.URL.Path, acmeChallengePrefix)) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.Info("Answering ACME challenge", "host", r.Host, "path", r.URL.Path, "client", requestSource(r))
+		challenges.ServeHTTP(w, r)
+	})
+}
+
+// acmeChallengeDomain reports whether challenges for host are answered
+func acmeChallengeDomain(host string) bool {
+	if len(appConfig().ACMEChallengeDomains) == 0 {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, domain := range appConfig().ACMEChallengeDomains {
+		if strings.EqualFold(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// acmeAppProxy forwards challenges to the application, keeping the Host
+// header so it can tell which of its domains is being validated
+func acmeAppProxy() http.Handler {
+	target := &url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", strconv.Itoa(appConfig().ApplicationPort))}
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Error("Failed to forward ACME challenge to the application", "path", r.URL.Path, "error", err)
+			http.Error(w, "Application unavailable", http.StatusBadGateway)
+		},
+	}
+}
+
+// serveACMEChallengeFile serves a challenge from acme_challenge_dir, laid out
+// as certbot --webroot writes it
+func serveACMEChallengeFile(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, acmeChallengePrefix)
+	w.Header().Set("Content-Type", "text/plain")
+	http.ServeFile(w, r, filepath.Join(appConfig().ACMEChallengeDir, ".well-known", "acme-challenge", token))
+}
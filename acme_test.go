@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func acmeRequest(handler http.Handler, method, host, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Host = host
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWithACMEChallenge_ServesWebroot(t *testing.T) {
+	webroot := t.TempDir()
+	challenges := filepath.Join(webroot, ".well-known", "acme-challenge")
+	os.MkdirAll(challenges, 0755)
+	os.WriteFile(filepath.Join(challenges, "tok3n_-A"), []byte("tok3n_-A.key-authorization"), 0644)
+	os.WriteFile(filepath.Join(webroot, "secret.txt"), []byte("not a challenge"), 0644)
+
+	currentConfig.Store(&config.DeployConfig{ACMEChallengeDir: webroot, ACMEChallengeDomains: []string{"deploy.example.com"}})
+	handler := withACMEChallenge(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("next"))
+	}))
+
+	tests := []struct {
+		name, method, host, path string
+		status                   int
+		body                     string
+	}{
+		{"challenge", http.MethodGet, "deploy.example.com", "/.well-known/acme-challenge/tok3n_-A", http.StatusOK, "tok3n_-A.key-authorization"},
+		{"challenge with port", http.MethodGet, "DEPLOY.example.com:80", "/.well-known/acme-challenge/tok3n_-A", http.StatusOK, "tok3n_-A.key-authorization"},
+		{"unknown token", http.MethodGet, "deploy.example.com", "/.well-known/acme-challenge/missing", http.StatusNotFound, ""},
+		{"path traversal", http.MethodGet, "deploy.example.com", "/.well-known/acme-challenge/..%2f..%2fsecret.txt", http.StatusNotFound, ""},
+		{"wrong method", http.MethodPost, "deploy.example.com", "/.well-known/acme-challenge/tok3n_-A", http.StatusMethodNotAllowed, ""},
+		{"other domain", http.MethodGet, "other.example.com", "/.well-known/acme-challenge/tok3n_-A", http.StatusOK, "next"},
+		{"other path", http.MethodGet, "deploy.example.com", "/webhook", http.StatusOK, "next"},
+	}
+	for _, tt := range tests {
+		rec := acmeRequest(handler, tt.method, tt.host, tt.path)
+		if rec.Code != tt.status || (tt.body != "" && rec.Body.String() != tt.body) {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.name, tt.status, tt.body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestWithACMEChallenge_ForwardsToApp(t *testing.T) {
+	var host string
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Write([]byte("from the app " + r.URL.Path))
+	}))
+	defer app.Close()
+
+	currentConfig.Store(&config.DeployConfig{ACMEChallengeApp: true, ApplicationPort: testAppPort(t, app)})
+	handler := withACMEChallenge(http.NotFoundHandler())
+
+	rec := acmeRequest(handler, http.MethodGet, "shop.example.com", "/.well-known/acme-challenge/abc123")
+	if rec.Code != http.StatusOK || rec.Body.String() != "from the app /.well-known/acme-challenge/abc123" {
+		t.Errorf("Expected the challenge to be answered by the application, got %d %q", rec.Code, rec.Body)
+	}
+	if host != "shop.example.com" {
+		t.Errorf("Expected the application to see the challenged host, got %q", host)
+	}
+
+	app.Close()
+	if rec := acmeRequest(handler, http.MethodGet, "shop.example.com", "/.well-known/acme-challenge/abc123"); rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 while the application is down, got %d", rec.Code)
+	}
+}
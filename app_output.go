@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"binaryDeploy/logbuffer"
+	"binaryDeploy/logrotate"
+)
+
+const (
+	defaultTailLines = 100
+	maxLineLength    = 16 * 1024
+)
+
+// AppOutput keeps the recent stdout/stderr lines of the managed application,
+// separate from binaryDeploy's own logs, and fans new lines out to followers.
+// Lines are also appended to app_log_file and published on the /logs stream
+// when configured.
+type AppOutput struct {
+	app       string
+	buffer    *logbuffer.Buffer // JSON-encoded AppLines
+	prefix    bool              // Tag lines with their instance
+	file      io.Writer         // Optional app_log_file
+	streamer  *LogStreamer      // Optional /logs stream
+	clients   map[chan AppLine]bool
+	clientsMu sync.RWMutex
+}
+
+// AppLine is one line of application output
+type AppLine struct {
+	Time     time.Time `json:"time"`
+	App      string    `json:"app"`
+	Instance int       `json:"instance"`
+	Stream   string    `json:"stream"` // stdout or stderr
+	Line     string    `json:"line"`
+}
+
+// text is the line as shown by /apps/{name}/logs/tail, starting with its
+// instance when prefix is set
+func (l AppLine) text(prefix bool) []byte {
+	if prefix {
+		return []byte(fmt.Sprintf("[%d] %s", l.Instance, l.Line))
+	}
+	return []byte(l.Line)
+}
+
+// appOutput holds the captured output of the managed application
+var appOutput *AppOutput
+
+// NewAppOutput returns an AppOutput for app holding at most maxLines lines
+func NewAppOutput(app string, maxLines int, prefix bool) *AppOutput {
+	return &AppOutput{
+		app:     app,
+		buffer:  logbuffer.New(maxLines, 0),
+		prefix:  prefix,
+		clients: make(map[chan AppLine]bool),
+	}
+}
+
+// setupAppOutput starts capturing the output of every instance
+func setupAppOutput() {
+	appOutput = NewAppOutput(appConfig().AppName, appConfig().AppLogLines, appConfig().Instances > 1)
+	if appConfig().AppLogFile != "" {
+		file, err := logrotate.Open(appConfig().AppLogFile, int64(appConfig().LogMaxSizeMB)*1024*1024)
+		if err != nil {
+			slog.Error("Failed to open app_log_file, application output is only kept in memory", "path", appConfig().AppLogFile, "error", err)
+		} else {
+			appOutput.file = file
+		}
+	}
+	if appConfig().AppLogStream {
+		appOutput.streamer = globalLogStreamer
+	}
+	processManager.SetOutputSink(appOutput.Writer)
+}
+
+// Writer returns a writer that splits one instance's stream into lines
+func (o *AppOutput) Writer(instance int, stream string) io.Writer {
+	return &lineWriter{output: o, instance: instance, stream: stream}
+}
+
+// add stores a complete line and sends it to followers
+func (o *AppOutput) add(line AppLine) {
+	line.Line = secretRedactor.Redact(line.Line)
+	if data, err := json.Marshal(line); err == nil {
+		o.buffer.Add(data)
+	}
+	if o.file != nil {
+		instance := ""
+		if o.prefix {
+			instance = fmt.Sprintf("[%d] ", line.Instance)
+		}
+		fmt.Fprintf(o.file, "%s %s %s%s\n", line.Time.UTC().Format(time.RFC3339Nano), line.Stream, instance, line.Line)
+	}
+	if o.streamer != nil {
+		o.streamer.PublishAppLine(line)
+	}
+
+	o.clientsMu.RLock()
+	defer o.clientsMu.RUnlock()
+	for client := range o.clients {
+		select {
+		case client <- line:
+		default:
+			// Slow follower, drop the line rather than block the app
+		}
+	}
+}
+
+// Lines returns up to n of the most recent lines, oldest first
+func (o *AppOutput) Lines(n int) []AppLine {
+	data := o.buffer.Snapshot()
+	if n < len(data) {
+		data = data[len(data)-n:]
+	}
+	lines := make([]AppLine, 0, len(data))
+	for _, d := range data {
+		var line AppLine
+		if json.Unmarshal(d, &line) == nil {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Tail returns up to n of the most recent lines as text, oldest first
+func (o *AppOutput) Tail(n int) [][]byte {
+	lines := o.Lines(n)
+	text := make([][]byte, len(lines))
+	for i, line := range lines {
+		text[i] = line.text(o.prefix)
+	}
+	return text
+}
+
+// Follow registers a channel that receives every new line
+func (o *AppOutput) Follow() chan AppLine {
+	client := make(chan AppLine, 100)
+	o.clientsMu.Lock()
+	defer o.clientsMu.Unlock()
+	o.clients[client] = true
+	return client
+}
+
+// Unfollow removes a channel returned by Follow
+func (o *AppOutput) Unfollow(client chan AppLine) {
+	o.clientsMu.Lock()
+	defer o.clientsMu.Unlock()
+	delete(o.clients, client)
+}
+
+// lineWriter buffers a partial line until its newline arrives
+type lineWriter struct {
+	output   *AppOutput
+	instance int
+	stream   string
+	partial  []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lw.partial = append(lw.partial, data...)
+			if len(lw.partial) >= maxLineLength {
+				lw.flush()
+			}
+			break
+		}
+		lw.partial = append(lw.partial, data[:i]...)
+		lw.flush()
+		data = data[i+1:]
+	}
+	return len(p), nil
+}
+
+func (lw *lineWriter) flush() {
+	lw.output.add(AppLine{
+		Time:     time.Now(),
+		App:      lw.output.app,
+		Instance: lw.instance,
+		Stream:   lw.stream,
+		Line:     string(bytes.TrimRight(lw.partial, "\r")),
+	})
+	lw.partial = lw.partial[:0]
+}
+
+// appLogsTailHandler returns the last lines of the application's output as
+// plain text and, with follow=true, keeps streaming new lines
+func appLogsTailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	lines := defaultTailLines
+	if v := query.Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid lines", http.StatusBadRequest)
+			return
+		}
+		lines = n
+	}
+	follow := query.Get("follow") == "true"
+
+	flusher, ok := w.(http.Flusher)
+	if follow && !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before taking the snapshot so no line falls in between
+	var client chan AppLine
+	if follow {
+		client = appOutput.Follow()
+		defer appOutput.Unfollow(client)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	for _, line := range appOutput.Tail(lines) {
+		w.Write(line)
+		w.Write([]byte("\n"))
+	}
+	if !follow {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case line := <-client:
+			w.Write(line.text(appOutput.prefix))
+			w.Write([]byte("\n"))
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-draining:
+			return
+		}
+	}
+}
+
+// appLogsStreamHandler handles GET /app-logs, streaming the application's
+// output as server-sent events, one JSON AppLine per event. It starts with
+// the last lines (default 100) and can be limited to one stream or instance.
+func appLogsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	lines := defaultTailLines
+	if v := query.Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid lines", http.StatusBadRequest)
+			return
+		}
+		lines = n
+	}
+	stream := query.Get("stream")
+	if stream != "" && stream != "stdout" && stream != "stderr" {
+		http.Error(w, "stream must be stdout or stderr", http.StatusBadRequest)
+		return
+	}
+	instance := -1
+	if v := query.Get("instance"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid instance", http.StatusBadRequest)
+			return
+		}
+		instance = n
+	}
+	matches := func(line AppLine) bool {
+		return (stream == "" || line.Stream == stream) && (instance < 0 || line.Instance == instance)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before taking the snapshot so no line falls in between
+	client := appOutput.Follow()
+	defer appOutput.Unfollow(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	send := func(line AppLine) {
+		if data, err := json.Marshal(line); err == nil {
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+	}
+	backlog := appOutput.Lines(appConfig().AppLogLines)
+	var recent []AppLine
+	for _, line := range backlog {
+		if matches(line) {
+			recent = append(recent, line)
+		}
+	}
+	for _, line := range recent[max(0, len(recent)-lines):] {
+		send(line)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case line := <-client:
+			if matches(line) {
+				send(line)
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		case <-draining:
+			return
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/processmanager"
+	"binaryDeploy/secrets"
+)
+
+// setupAppOutputTest captures output of myapp into a fresh appOutput
+func setupAppOutputTest(t *testing.T, cfg *config.DeployConfig, prefix bool) {
+	t.Helper()
+	cfg.AppName = "myapp"
+	if cfg.AppLogLines == 0 {
+		cfg.AppLogLines = 5
+	}
+	currentConfig.Store(cfg)
+	secretRedactor = secrets.NewRedactor(nil)
+	appOutput = NewAppOutput(cfg.AppName, cfg.AppLogLines, prefix)
+}
+
+func getAppLogsTail(query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	appLogsTailHandler(rec, httptest.NewRequest(http.MethodGet, "/apps/myapp/logs/tail"+query, nil))
+	return rec
+}
+
+func TestAppLogsTail_ReturnsLastLines(t *testing.T) {
+	setupAppOutputTest(t, &config.DeployConfig{}, false)
+
+	stdout := appOutput.Writer(0, "stdout")
+	fmt.Fprint(stdout, "line 1\nline 2\r\nline")
+	fmt.Fprint(stdout, " 3\n")
+	for i := 4; i <= 7; i++ {
+		fmt.Fprintf(stdout, "line %d\n", i)
+	}
+	fmt.Fprint(stdout, "partial")
+
+	tests := []struct {
+		query string
+		body  string
+	}{
+		{"?lines=2", "line 6\nline 7\n"},
+		{"", "line 3\nline 4\nline 5\nline 6\nline 7\n"}, // Only app_log_lines are kept
+		{"?lines=0", ""},
+	}
+	for _, tt := range tests {
+		rec := getAppLogsTail(tt.query)
+		if rec.Code != http.StatusOK || rec.Body.String() != tt.body {
+			t.Errorf("%q: expected %q, got %d %q", tt.query, tt.body, rec.Code, rec.Body)
+		}
+	}
+	if rec := getAppLogsTail("?lines=-1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected negative lines to be refused, got %d", rec.Code)
+	}
+}
+
+func TestAppLogsTail_PrefixesInstances(t *testing.T) {
+	setupAppOutputTest(t, &config.DeployConfig{}, true)
+
+	fmt.Fprintln(appOutput.Writer(0, "stdout"), "from the first")
+	fmt.Fprintln(appOutput.Writer(1, "stderr"), "from the second")
+	if body := getAppLogsTail("").Body.String(); body != "[0] from the first\n[1] from the second\n" {
+		t.Errorf("Expected lines tagged with their instance, got %q", body)
+	}
+}
+
+func TestAppLogsTail_FollowsNewLines(t *testing.T) {
+	setupAppOutputTest(t, &config.DeployConfig{}, false)
+	fmt.Fprintln(appOutput.Writer(0, "stdout"), "before")
+
+	server := httptest.NewServer(http.HandlerFunc(appLogsTailHandler))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/apps/myapp/logs/tail?follow=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != "before\n" {
+		t.Fatalf("Expected the recent lines first, got %q", line)
+	}
+	fmt.Fprintln(appOutput.Writer(0, "stderr"), "after")
+	if line, _ := reader.ReadString('\n'); line != "after\n" {
+		t.Errorf("Expected the new line to be streamed, got %q", line)
+	}
+}
+
+func TestSetupAppOutput_WritesAppLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp", AppLogLines: 5, Instances: 2, AppLogFile: path, LogMaxSizeMB: 1})
+	secretRedactor = secrets.NewRedactor(nil)
+	processManager = processmanager.NewGroup(2)
+	setupAppOutput()
+
+	fmt.Fprintln(appOutput.Writer(0, "stdout"), "listening on :3000")
+	fmt.Fprintln(appOutput.Writer(1, "stderr"), "connection refused")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " stdout [0] listening on :3000") || !strings.HasSuffix(lines[1], " stderr [1] connection refused") {
+		t.Errorf("Expected timestamped lines tagged with stream and instance, got %q", data)
+	}
+}
+
+func TestAppLogsStream_FiltersByStreamAndInstance(t *testing.T) {
+	setupAppOutputTest(t, &config.DeployConfig{}, true)
+	fmt.Fprintln(appOutput.Writer(0, "stderr"), "old error on 0")
+	fmt.Fprintln(appOutput.Writer(1, "stdout"), "old output on 1")
+	fmt.Fprintln(appOutput.Writer(1, "stderr"), "old error on 1")
+
+	server := httptest.NewServer(http.HandlerFunc(appLogsStreamHandler))
+	defer server.Close()
+	for _, query := range []string{"?stream=stdin", "?instance=first", "?lines=-1"} {
+		resp, err := http.Get(server.URL + "/app-logs" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/app-logs?stream=stderr&instance=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected server-sent events, got %q", contentType)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	next := func() AppLine {
+		t.Helper()
+		for {
+			text, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Reading the stream: %v", err)
+			}
+			if data, ok := strings.CutPrefix(text, "data: "); ok {
+				var line AppLine
+				if err := json.Unmarshal([]byte(data), &line); err != nil {
+					t.Fatal(err)
+				}
+				return line
+			}
+		}
+	}
+
+	if line := next(); line.Line != "old error on 1" || line.App != "myapp" || line.Instance != 1 || line.Stream != "stderr" {
+		t.Errorf("Expected only the matching recent line, got %+v", line)
+	}
+	fmt.Fprintln(appOutput.Writer(0, "stderr"), "new error on 0")
+	fmt.Fprintln(appOutput.Writer(1, "stdout"), "new output on 1")
+	fmt.Fprintln(appOutput.Writer(1, "stderr"), "new error on 1")
+	if line := next(); line.Line != "new error on 1" {
+		t.Errorf("Expected only matching new lines to be streamed, got %+v", line)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultExecTimeout = 5 * time.Minute
+	maxExecTimeout     = 30 * time.Minute
+)
+
+// ExecRequest is the body accepted by POST /apps/{name}/exec
+type ExecRequest struct {
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+	Instance       int    `json:"instance,omitempty"` // Instance whose PORT and INSTANCE_ID the command gets
+}
+
+// appsHandler dispatches /apps/{name}/{action} requests
+func appsHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.Trim(strings.TrimPrefix(r.URL.Path, "/apps/"), "/"), "/", 3)
+	if len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	name, action := parts[0], parts[1]
+	if name != appConfig().AppName {
+		http.Error(w, fmt.Sprintf("Unknown application %q", name), http.StatusNotFound)
+		return
+	}
+
+	switch action {
+	case "exec":
+		unlessDisabled("exec", appExecHandler)(w, r)
+	case "events":
+		appEventsHandler(w, r)
+	case "dependencies":
+		appDependenciesHandler(w, r)
+	case "gc":
+		appGCHandler(w, r)
+	case "logs":
+		if len(parts) != 3 || parts[2] != "tail" {
+			http.NotFound(w, r)
+			return
+		}
+		appLogsTailHandler(w, r)
+	case "files":
+		relPath := ""
+		if len(parts) == 3 {
+			relPath = parts[2]
+		}
+		appFilesHandler(w, r, relPath)
+	case "process":
+		if len(parts) != 3 {
+			http.NotFound(w, r)
+			return
+		}
+		unlessDisabled("process", func(w http.ResponseWriter, r *http.Request) {
+			processControlHandler(w, r, parts[2])
+		})(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// appEventsHandler lists the state transitions of the managed process,
+// newest first
+func appEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"app":    appConfig().AppName,
+		"events": processManager.Events().Recent(limit),
+	})
+}
+
+// appExecHandler runs a one-off command in the application's working directory
+// and streams its combined stdout/stderr back to the caller
+func appExecHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Command) == "" {
+		http.Error(w, "Invalid payload - missing command", http.StatusBadRequest)
+		return
+	}
+
+	timeout := defaultExecTimeout
+	if req.TimeoutSeconds > 0 {
+		timeout = time.Duration(req.TimeoutSeconds) * time.Second
+	}
+	if timeout > maxExecTimeout {
+		timeout = maxExecTimeout
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	workingDir := targetWorkingDir()
+	cmd, err := processManager.ExecCommand(ctx, req.Instance, workingDir, req.Command)
+	if err != nil {
+		http.Error(w, "Invalid payload - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Executing command in application directory",
+		"app", appConfig().AppName,
+		"instance", req.Instance,
+		"command", req.Command,
+		"working_dir", workingDir,
+		"remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Trailer", "X-Exit-Code")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Stdout and Stderr share one writer so exec serializes writes for us
+	output := &flushWriter{w: w, flusher: flusher}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	start := time.Now()
+	err = cmd.Run()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	default:
+		exitCode = -1
+		fmt.Fprintf(w, "exec failed: %v\n", err)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		fmt.Fprintf(w, "exec timed out after %s\n", timeout)
+	}
+
+	fmt.Fprintf(w, "\n[exit code %d]\n", exitCode)
+	w.Header().Set("X-Exit-Code", fmt.Sprintf("%d", exitCode))
+
+	slog.Info("Exec command finished",
+		"app", appConfig().AppName,
+		"command", req.Command,
+		"exit_code", exitCode,
+		"duration", time.Since(start).String())
+}
+
+// flushWriter flushes after every write so output reaches the client immediately
+type flushWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	fw.flusher.Flush()
+	return n, err
+}
+
+// FileEntry describes a file or directory inside an application's deploy directory
+type FileEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// appFilesHandler lists directories and downloads files from the deploy directory
+func appFilesHandler(w http.ResponseWriter, r *http.Request, relPath string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	root, fullPath, err := resolveDeployPath(appConfig().DeployDir, relPath)
+	if err != nil {
+		slog.Warn("Rejected file browser path", "path", relPath, "error", err, "remote_addr", r.RemoteAddr)
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to stat path", http.StatusInternalServerError)
+		return
+	}
+
+	if info.IsDir() {
+		entries, err := listDeployDir(root, fullPath)
+		if err != nil {
+			slog.Error("Failed to list deploy directory", "path", fullPath, "error", err)
+			http.Error(w, "Failed to list directory", http.StatusInternalServerError)
+			return
+		}
+		dirPath, _ := filepath.Rel(root, fullPath)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"path":    filepath.ToSlash(dirPath),
+			"entries": entries,
+		})
+		return
+	}
+
+	if !info.Mode().IsRegular() {
+		http.Error(w, "Not a regular file", http.StatusBadRequest)
+		return
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		http.Error(w, "Failed to open file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	slog.Info("Serving file from deploy directory", "path", fullPath, "size", info.Size(), "remote_addr", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", info.Name()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// resolveDeployPath maps a request path onto the deploy directory, resolving
+// symlinks and rejecting anything that ends up outside of it. It returns the
+// resolved root along with the resolved target path.
+func resolveDeployPath(deployDir, relPath string) (string, string, error) {
+	root, err := filepath.Abs(deployDir)
+	if err != nil {
+		return "", "", err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", "", err
+	}
+
+	fullPath := filepath.Join(root, filepath.FromSlash("/"+relPath))
+	if resolved, err := filepath.EvalSymlinks(fullPath); err == nil {
+		fullPath = resolved
+	} else if !os.IsNotExist(err) {
+		return "", "", err
+	}
+
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("path escapes deploy directory")
+	}
+	for _, path := range []string{filepath.Clean(filepath.FromSlash(relPath)), rel} {
+		if hiddenDeployPath(path) {
+			return "", "", fmt.Errorf("%s is not served by the file browser", path)
+		}
+	}
+
+	return root, fullPath, nil
+}
+
+// secretFilePatterns match files the file browser doesn't serve because
+// they usually hold credentials
+var secretFilePatterns = []string{"*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore", "id_rsa*", "id_ecdsa*", "id_ed25519*", "*credentials*", "*.env"}
+
+// hiddenDeployPath reports whether the file browser hides path, relative to
+// the deploy directory: anything in or under a hidden file, and config files
+// rendered from config_templates, which hold secrets
+func hiddenDeployPath(path string) bool {
+	for _, name := range strings.Split(path, string(filepath.Separator)) {
+		if hiddenDeployFile(name) {
+			return true
+		}
+	}
+	return renderedConfigFile(path)
+}
+
+// hiddenDeployFile reports whether the file browser hides name: dotfiles,
+// such as .git, .env and binaryDeploy's own scratch files, and secret files
+func hiddenDeployFile(name string) bool {
+	if strings.HasPrefix(name, ".") && name != "." {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, pattern := range secretFilePatterns {
+		if ok, _ := filepath.Match(pattern, lower); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// listDeployDir returns the entries of dir with paths relative to root
+func listDeployDir(root, dir string) ([]FileEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]FileEntry, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		rel, _ := filepath.Rel(root, filepath.Join(dir, entry.Name()))
+		if hiddenDeployPath(rel) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Entry removed while listing
+		}
+		entries = append(entries, FileEntry{
+			Name:    entry.Name(),
+			Path:    filepath.ToSlash(rel),
+			IsDir:   entry.IsDir(),
+			Size:    info.Size(),
+			Mode:    info.Mode().String(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	// Directories first, then alphabetical
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+
+	return entries, nil
+}
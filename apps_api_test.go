@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/processmanager"
+)
+
+func TestResolveDeployPath(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{ConfigTemplates: []string{"config.tmpl:config/app.yaml"}})
+	base := t.TempDir()
+	deployDir := filepath.Join(base, "deployments")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{filepath.Join(deployDir, "repo", "static"), filepath.Join(deployDir, "repo", "config"), filepath.Join(deployDir, "repo", ".git"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(deployDir, "repo", "static", "index.html"), filepath.Join(deployDir, "repo", ".git", "config"), filepath.Join(outside, "passwd")} {
+		if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(deployDir, "escape"):          outside,
+		filepath.Join(deployDir, "passwd"):          filepath.Join(outside, "passwd"),
+		filepath.Join(deployDir, "assets"):          filepath.Join(deployDir, "repo", "static"),
+		filepath.Join(deployDir, "repo", "exposed"): filepath.Join(deployDir, "repo", ".git"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string // Relative to the deploy directory; empty if refused
+	}{
+		{"", "."},
+		{"repo/static/index.html", "repo/static/index.html"},
+		{"missing.txt", "missing.txt"},
+		{"/etc/passwd", "etc/passwd"},
+		{"repo/../repo/static/index.html", "repo/static/index.html"},
+		{"../outside/passwd", ""},
+		{"repo/../../outside/passwd", ""},
+		{"assets/index.html", "repo/static/index.html"},
+		{"escape", ""},
+		{"escape/passwd", ""},
+		{"passwd", ""},
+		{"repo/.git/config", ""},
+		{"repo/exposed/config", ""},
+		{".env", ""},
+		{"repo/static/.htpasswd", ""},
+		{"repo/server.key", ""},
+		{"repo/config/app.yaml", ""},
+	}
+	root, _ := filepath.EvalSymlinks(deployDir)
+	for _, tt := range tests {
+		_, fullPath, err := resolveDeployPath(deployDir, tt.path)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q: expected to be refused, resolved to %s", tt.path, fullPath)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.path, err)
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); fullPath != want {
+			t.Errorf("%q: expected %s, got %s", tt.path, want, fullPath)
+		}
+	}
+}
+
+func TestHiddenDeployPath(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{ConfigTemplates: []string{"config.tmpl:config/app.yaml", "settings.ini.tmpl"}})
+
+	tests := []struct {
+		path   string
+		hidden bool
+	}{
+		{".", false},
+		{"repo/main.go", false},
+		{"repo/static/env.js", false},
+		{"repo/config/app.yaml.example", false},
+		{".git", true},
+		{"repo/.git/HEAD", true},
+		{"repo/.env", true},
+		{"repo/production.env", true},
+		{"repo/certs/server.pem", true},
+		{"repo/certs/TLS.KEY", true},
+		{"repo/.ssh/authorized_keys", true},
+		{"repo/id_ed25519.pub", true},
+		{"repo/aws-credentials.json", true},
+		{"repo/config/app.yaml", true},
+		{"config/app.yaml", true},
+		{"repo/settings.ini", true},
+	}
+	for _, tt := range tests {
+		if got := hiddenDeployPath(filepath.FromSlash(tt.path)); got != tt.hidden {
+			t.Errorf("hiddenDeployPath(%q) = %v, want %v", tt.path, got, tt.hidden)
+		}
+	}
+}
+
+// setupExecTest serves the routes for two running instances of myapp
+func setupExecTest(t *testing.T, disabled ...string) http.Handler {
+	t.Helper()
+	cfg := &config.DeployConfig{
+		AppName:           "myapp",
+		TargetRepoURL:     "https://git.example.com/team/app.git",
+		APIToken:          "exec-test-token",
+		DeployDir:         t.TempDir(),
+		RunCommand:        "sleep 5",
+		ApplicationPort:   9100,
+		DisabledEndpoints: disabled,
+	}
+	currentConfig.Store(cfg)
+	if err := os.MkdirAll(targetWorkingDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	group := processmanager.NewGroup(2)
+	if err := group.StartProcess(cfg, targetWorkingDir()); err != nil {
+		t.Fatalf("StartProcess: %v", err)
+	}
+	t.Cleanup(func() { group.Shutdown() })
+	processManager = group
+	return setupRoutes()
+}
+
+func execRequest(routes http.Handler, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAppExec_RequiresAPIToken(t *testing.T) {
+	routes := setupExecTest(t)
+	dir := t.TempDir()
+	command := fmt.Sprintf(`{"command": "touch %s/ran"}`, dir)
+
+	for _, token := range []string{"", "wrong-token"} {
+		if rec := execRequest(routes, "/apps/myapp/exec", token, command); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected 401, got %d", token, rec.Code)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ran")); err == nil {
+		t.Error("Expected the command not to run without the api_token")
+	}
+}
+
+func TestAppExec_RejectsInvalidCommands(t *testing.T) {
+	routes := setupExecTest(t)
+
+	tests := []struct {
+		name, path, body string
+		status           int
+	}{
+		{"unknown app", "/apps/other/exec", `{"command": "true"}`, http.StatusNotFound},
+		{"missing command", "/apps/myapp/exec", `{"command": "  "}`, http.StatusBadRequest},
+		{"malformed body", "/apps/myapp/exec", `command=true`, http.StatusBadRequest},
+		{"instance out of range", "/apps/myapp/exec", `{"command": "true", "instance": 2}`, http.StatusBadRequest},
+		{"negative instance", "/apps/myapp/exec", `{"command": "true", "instance": -1}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := execRequest(routes, tt.path, "exec-test-token", tt.body); rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d %s", tt.name, tt.status, rec.Code, rec.Body)
+		}
+	}
+
+	routes = setupExecTest(t, "exec")
+	if rec := execRequest(routes, "/apps/myapp/exec", "exec-test-token", `{"command": "true"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected exec listed in disabled_endpoints to answer 404, got %d", rec.Code)
+	}
+}
+
+func TestAppExec_RunsInInstanceEnvironment(t *testing.T) {
+	routes := setupExecTest(t)
+
+	for instance, want := range []string{"9100 0", "9101 1"} {
+		body := fmt.Sprintf(`{"command": "echo \"$PORT $INSTANCE_ID $(pwd)\"", "instance": %d}`, instance)
+		rec := execRequest(routes, "/apps/myapp/exec", "exec-test-token", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("instance %d: expected 200, got %d %s", instance, rec.Code, rec.Body)
+		}
+		dir, _ := filepath.EvalSymlinks(targetWorkingDir())
+		if want := want + " " + dir + "\n"; !strings.HasPrefix(rec.Body.String(), want) {
+			t.Errorf("instance %d: expected output %q, got %q", instance, want, rec.Body)
+		}
+		if exit := rec.Result().Trailer.Get("X-Exit-Code"); exit != "0" {
+			t.Errorf("instance %d: expected exit code 0, got %q", instance, exit)
+		}
+	}
+
+	rec := execRequest(routes, "/apps/myapp/exec", "exec-test-token", `{"command": "echo failing >&2; exit 3"}`)
+	if !strings.Contains(rec.Body.String(), "failing") || !strings.Contains(rec.Body.String(), "[exit code 3]") {
+		t.Errorf("Expected stderr and the exit code in the output, got %q", rec.Body)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"binaryDeploy/artifacts"
+	"binaryDeploy/s3"
+)
+
+// artifactStore holds built artifacts by commit; nil when artifact_paths is
+// not configured
+var artifactStore *artifacts.Store
+
+// setupArtifactStore opens the configured artifact store. Deployments build
+// as usual if it can't be opened.
+func setupArtifactStore() {
+	if len(appConfig().ArtifactPaths) == 0 {
+		return
+	}
+
+	location := appConfig().ArtifactStore
+	if location == "" {
+		location = filepath.Join(appConfig().DeployDir, "artifacts")
+	}
+	backend, err := newObjectBackend(location)
+	if err != nil {
+		slog.Error("Failed to open artifact store, artifacts will not be kept", "error", err)
+		return
+	}
+	artifactStore = artifacts.NewStore(backend)
+	slog.Info("Artifact store enabled", "store", backend, "paths", appConfig().ArtifactPaths)
+}
+
+// newObjectBackend returns the object storage at location: an
+// s3://bucket/prefix URL or a local directory
+func newObjectBackend(location string) (artifacts.Backend, error) {
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		client, err := s3.New(s3.Config{
+			Endpoint:  appConfig().S3Endpoint,
+			Region:    appConfig().S3Region,
+			Bucket:    bucket,
+			Prefix:    prefix,
+			AccessKey: appConfig().S3AccessKey,
+			SecretKey: appConfig().S3SecretKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", location, err)
+		}
+		return client, nil
+	}
+	return artifacts.NewDirBackend(location), nil
+}
+
+// restoreArtifacts puts the stored build of commit into the working
+// directory, reporting whether the build can be skipped. Any problem falls
+// back to building.
+func restoreArtifacts(commit string) bool {
+	if artifactStore == nil || commit == "" {
+		return false
+	}
+
+	restored, err := artifactStore.Restore(commit, targetWorkingDir())
+	if err != nil {
+		slog.Warn("Failed to restore stored artifacts, rebuilding", "commit", commit, "error", err)
+		return false
+	}
+	return restored
+}
+
+// saveArtifacts stores the freshly built artifacts of commit. Failing to
+// store them doesn't fail the deployment.
+func saveArtifacts(commit string) {
+	if artifactStore == nil || commit == "" {
+		return
+	}
+
+	manifest, err := artifactStore.Save(commit, targetWorkingDir(), appConfig().ArtifactPaths)
+	if err != nil {
+		slog.Error("Failed to store build artifacts", "commit", commit, "error", err)
+		return
+	}
+	slog.Info("Stored build artifacts", "commit", commit, "files", len(manifest.Files))
+}
@@ -0,0 +1,80 @@
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Digest hashes the regular files at paths (files or directories, relative
+// to root) together with their relative paths and permissions, so two
+// builds have the same digest exactly when they would save the same
+// artifacts. Missing paths count as empty; a build without any file is an
+// error.
+func Digest(root string, paths []string) (string, error) {
+	var lines []string
+	for _, path := range paths {
+		err := filepath.WalkDir(filepath.Join(root, path), func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, file)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			sum, err := fileHash(file)
+			if err != nil {
+				return fmt.Errorf("hashing %s: %w", rel, err)
+			}
+			lines = append(lines, fmt.Sprintf("%s %o %s\n", sum, info.Mode().Perm(), filepath.ToSlash(rel)))
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(lines) == 0 {
+		return "", fmt.Errorf("no artifact files found")
+	}
+
+	// Overlapping paths list a file twice; sorting keeps the digest
+	// independent of the order paths are given in
+	sort.Strings(lines)
+	digest := sha256.New()
+	for i, line := range lines {
+		if i > 0 && line == lines[i-1] {
+			continue
+		}
+		io.WriteString(digest, line)
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+func fileHash(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,41 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	build := t.TempDir()
+	os.MkdirAll(filepath.Join(build, "bin"), 0755)
+	os.WriteFile(filepath.Join(build, "bin", "app"), []byte("binary v1"), 0755)
+	os.WriteFile(filepath.Join(build, "config.yml"), []byte("port: 80"), 0644)
+
+	first, err := Digest(build, []string{"bin", "config.yml"})
+	if err != nil {
+		t.Fatalf("Digest failed: %v", err)
+	}
+	if again, _ := Digest(build, []string{"config.yml", "bin", "bin/app"}); again != first {
+		t.Error("expected digest to ignore path order and overlap")
+	}
+	if withMissing, _ := Digest(build, []string{"bin", "config.yml", "docs"}); withMissing != first {
+		t.Error("expected missing paths to be ignored")
+	}
+
+	os.Chmod(filepath.Join(build, "config.yml"), 0600)
+	modeChanged, _ := Digest(build, []string{"bin", "config.yml"})
+	if modeChanged == first {
+		t.Error("expected a permission change to change the digest")
+	}
+
+	os.WriteFile(filepath.Join(build, "bin", "app"), []byte("binary v2"), 0755)
+	contentChanged, _ := Digest(build, []string{"bin", "config.yml"})
+	if contentChanged == first || contentChanged == modeChanged {
+		t.Error("expected a content change to change the digest")
+	}
+
+	if _, err := Digest(build, []string{"docs"}); err == nil {
+		t.Error("expected an error when no file matches")
+	}
+}
@@ -0,0 +1,62 @@
+package artifacts
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DirBackend stores objects as files under a local directory
+type DirBackend struct {
+	dir string
+}
+
+// NewDirBackend returns a backend rooted at dir
+func NewDirBackend(dir string) *DirBackend {
+	return &DirBackend{dir: dir}
+}
+
+// String returns the backing directory, for logs
+func (b *DirBackend) String() string {
+	return b.dir
+}
+
+// Put writes body to key, atomically replacing any existing object
+func (b *DirBackend) Put(key string, body io.Reader, size int64) error {
+	path := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get opens key for reading
+func (b *DirBackend) Get(key string) (io.ReadCloser, error) {
+	return os.Open(b.path(key))
+}
+
+// Exists reports whether key is present
+func (b *DirBackend) Exists(key string) (bool, error) {
+	_, err := os.Stat(b.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *DirBackend) path(key string) string {
+	return filepath.Join(b.dir, filepath.FromSlash(key))
+}
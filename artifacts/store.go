@@ -0,0 +1,200 @@
+// Package artifacts keeps built files in a content-addressed store keyed by
+// commit, so a previously built revision can be redeployed without
+// rebuilding it
+package artifacts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backend stores opaque objects by key. Get must return an error wrapping
+// fs.ErrNotExist for missing keys.
+type Backend interface {
+	Put(key string, body io.Reader, size int64) error
+	Get(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+}
+
+// Entry is one stored file
+type Entry struct {
+	Path string      `json:"path"` // Slash-separated, relative to the release root
+	Hash string      `json:"sha256"`
+	Size int64       `json:"size"`
+	Mode fs.FileMode `json:"mode"`
+}
+
+// Manifest lists the files built from one commit
+type Manifest struct {
+	Commit    string    `json:"commit"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []Entry   `json:"files"`
+}
+
+// Store saves and restores build artifacts. File contents live under
+// objects/<sha256> so unchanged files are stored once across commits;
+// commits/<sha>.json lists which objects make up each build.
+type Store struct {
+	backend Backend
+}
+
+// NewStore returns a store on top of backend
+func NewStore(backend Backend) *Store {
+	return &Store{backend: backend}
+}
+
+// Has reports whether artifacts were saved for commit
+func (s *Store) Has(commit string) (bool, error) {
+	return s.backend.Exists(manifestKey(commit))
+}
+
+// Save stores the files at paths (files or directories, relative to root)
+// as the artifacts of commit
+func (s *Store) Save(commit, root string, paths []string) (*Manifest, error) {
+	manifest := &Manifest{Commit: commit, CreatedAt: time.Now().UTC()}
+
+	for _, path := range paths {
+		err := filepath.WalkDir(filepath.Join(root, path), func(file string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil // Symlinks and special files aren't build output
+			}
+			rel, err := filepath.Rel(root, file)
+			if err != nil {
+				return err
+			}
+			entry, err := s.saveFile(file, filepath.ToSlash(rel))
+			if err != nil {
+				return fmt.Errorf("storing %s: %w", rel, err)
+			}
+			manifest.Files = append(manifest.Files, entry)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("no artifact files found under %s", strings.Join(paths, ", "))
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.backend.Put(manifestKey(commit), strings.NewReader(string(data)), int64(len(data))); err != nil {
+		return nil, fmt.Errorf("storing manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+// saveFile uploads file under its content hash unless it's already stored
+func (s *Store) saveFile(file, rel string) (Entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Path: rel, Hash: hex.EncodeToString(hash.Sum(nil)), Size: info.Size(), Mode: info.Mode().Perm()}
+
+	exists, err := s.backend.Exists(objectKey(entry.Hash))
+	if err != nil || exists {
+		return entry, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Entry{}, err
+	}
+	return entry, s.backend.Put(objectKey(entry.Hash), f, entry.Size)
+}
+
+// Restore writes the artifacts of commit into root. It returns false without
+// touching root when nothing was saved for commit.
+func (s *Store) Restore(commit, root string) (bool, error) {
+	body, err := s.backend.Get(manifestKey(commit))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var manifest Manifest
+	err = json.NewDecoder(body).Decode(&manifest)
+	body.Close()
+	if err != nil {
+		return false, fmt.Errorf("reading manifest for %s: %w", commit, err)
+	}
+
+	for _, entry := range manifest.Files {
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+			return false, fmt.Errorf("manifest for %s has unsafe path %q", commit, entry.Path)
+		}
+		if err := s.restoreFile(entry, filepath.Join(root, filepath.FromSlash(entry.Path))); err != nil {
+			return false, fmt.Errorf("restoring %s: %w", entry.Path, err)
+		}
+	}
+	return true, nil
+}
+
+// restoreFile downloads one object to dest, verifying its hash before the
+// file is moved into place
+func (s *Store) restoreFile(entry Entry, dest string) error {
+	body, err := s.backend.Get(objectKey(entry.Hash))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".artifact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != entry.Hash {
+		return fmt.Errorf("checksum mismatch: got %s, want %s", got, entry.Hash)
+	}
+	if err := os.Chmod(tmp.Name(), entry.Mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+func manifestKey(commit string) string {
+	return "commits/" + commit + ".json"
+}
+
+func objectKey(hash string) string {
+	return "objects/" + hash[:2] + "/" + hash
+}
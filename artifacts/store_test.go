@@ -0,0 +1,85 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore_SaveAndRestore(t *testing.T) {
+	store := NewStore(NewDirBackend(t.TempDir()))
+	build := t.TempDir()
+	os.MkdirAll(filepath.Join(build, "bin"), 0755)
+	os.WriteFile(filepath.Join(build, "bin", "app"), []byte("binary v1"), 0755)
+	os.WriteFile(filepath.Join(build, "static.css"), []byte("body{}"), 0644)
+
+	manifest, err := store.Save("aaa111", build, []string{"bin", "static.css"})
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if len(manifest.Files) != 2 {
+		t.Fatalf("expected 2 files in manifest, got %+v", manifest.Files)
+	}
+	if ok, _ := store.Has("aaa111"); !ok {
+		t.Error("expected commit to be stored")
+	}
+
+	release := t.TempDir()
+	restored, err := store.Restore("aaa111", release)
+	if err != nil || !restored {
+		t.Fatalf("Restore failed: %v, %v", restored, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(release, "bin", "app"))
+	if err != nil || string(data) != "binary v1" {
+		t.Errorf("restored binary = %q, %v", data, err)
+	}
+	if info, _ := os.Stat(filepath.Join(release, "bin", "app")); info.Mode().Perm() != 0755 {
+		t.Errorf("expected executable mode to be restored, got %v", info.Mode().Perm())
+	}
+}
+
+func TestStore_RestoreUnknownCommit(t *testing.T) {
+	store := NewStore(NewDirBackend(t.TempDir()))
+
+	restored, err := store.Restore("missing", t.TempDir())
+	if err != nil || restored {
+		t.Errorf("expected nothing restored without error, got %v, %v", restored, err)
+	}
+}
+
+func TestStore_DeduplicatesObjects(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(NewDirBackend(dir))
+	build := t.TempDir()
+	os.WriteFile(filepath.Join(build, "app"), []byte("same"), 0755)
+
+	store.Save("aaa", build, []string{"app"})
+	store.Save("bbb", build, []string{"app"})
+
+	objects, _ := filepath.Glob(filepath.Join(dir, "objects", "*", "*"))
+	if len(objects) != 1 {
+		t.Errorf("expected one stored object for identical builds, got %d", len(objects))
+	}
+}
+
+func TestStore_RejectsCorruptObject(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(NewDirBackend(dir))
+	build := t.TempDir()
+	os.WriteFile(filepath.Join(build, "app"), []byte("good"), 0755)
+
+	manifest, err := store.Save("aaa", build, []string{"app"})
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, "objects", manifest.Files[0].Hash[:2], manifest.Files[0].Hash), []byte("evil"), 0644)
+
+	release := t.TempDir()
+	if _, err := store.Restore("aaa", release); err == nil {
+		t.Fatal("expected a checksum error")
+	}
+	if _, err := os.Stat(filepath.Join(release, "app")); !os.IsNotExist(err) {
+		t.Error("expected the corrupt file not to be written")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// requireAPIToken wraps a handler so it only runs for requests carrying the
+// configured api_token as a bearer token, or logged in as one of auth_users
+// with basic auth or a login page session. When neither is configured the
+// endpoint is disabled entirely rather than left open.
+func requireAPIToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if appConfig().APIToken == "" && len(appConfig().AuthUsers) == 0 {
+			http.Error(w, "Endpoint disabled: api_token is not configured", http.StatusForbidden)
+			return
+		}
+
+		user, err := authenticate(r)
+		if errors.Is(err, errCrossOrigin) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if user == "" {
+			slog.Warn("Rejected unauthenticated management request",
+				"path", r.URL.Path,
+				"remote_addr", r.RemoteAddr)
+			recordAuthFailure(r, "invalid api_token")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// requestSource identifies the client that made r, for audit trails
+func requestSource(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// hasValidAPIToken reports whether the request carries the configured api_token
+func hasValidAPIToken(r *http.Request) bool {
+	if appConfig().APIToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(appConfig().APIToken)) == 1
+}
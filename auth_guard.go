@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"binaryDeploy/authguard"
+	"binaryDeploy/notify"
+)
+
+// authGuard bans clients that keep failing authentication; until
+// setupAuthGuard runs it only counts failures
+var authGuard = authguard.New(authguard.Config{})
+
+// setupAuthGuard configures banning from the auth_* settings
+func setupAuthGuard() {
+	exempt, err := authguard.ParseNetworks(appConfig().AuthBanExempt)
+	if err != nil {
+		slog.Error("Invalid auth_ban_exempt, no clients are exempt from bans", "error", err)
+	}
+	authGuard = authguard.New(authguard.Config{
+		Threshold: appConfig().AuthFailureThreshold,
+		Window:    time.Duration(appConfig().AuthFailureWindow) * time.Second,
+		Ban:       time.Duration(appConfig().AuthBanDuration) * time.Second,
+		Exempt:    exempt,
+	})
+}
+
+// withAuthBans rejects every request from a banned client before it reaches
+// a handler
+func withAuthBans(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if until, banned := authGuard.Banned(requestSource(r)); banned {
+			retryAfter := int(time.Until(until).Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recordAuthFailure counts a failed signature, token or API token check by
+// r's client, banning it and alerting operators once it fails too often
+func recordAuthFailure(r *http.Request, reason string) {
+	client := requestSource(r)
+	failures, until, banned := authGuard.Fail(client)
+	if !banned {
+		return
+	}
+
+	slog.ErrorContext(r.Context(), "Banned client after repeated authentication failures", "client", client,
+		"failures", failures, "until", until, "last_reason", reason)
+	notifier().Notify(notify.Event{
+		Type:    notify.EventClientBanned,
+		App:     appConfig().AppName,
+		Message: fmt.Sprintf("Banned %s until %s after %d failed authentication attempts", client, until.Format(time.RFC3339), failures),
+		Fields: map[string]interface{}{
+			"client":      client,
+			"failures":    failures,
+			"window":      (time.Duration(appConfig().AuthFailureWindow) * time.Second).String(),
+			"until":       until,
+			"last_reason": reason,
+			"last_path":   r.URL.Path,
+			"user_agent":  r.Header.Get("User-Agent"),
+		},
+		CorrelationID: requestCorrelationID(r),
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/notify"
+)
+
+// recordingNotifier keeps the events sent to it
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []notify.Event
+}
+
+func (n *recordingNotifier) Send(ctx context.Context, event notify.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	return nil
+}
+
+func (n *recordingNotifier) Events() []notify.Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]notify.Event(nil), n.events...)
+}
+
+// useRecordingNotifier sends notifications to a recordingNotifier for the
+// rest of the test
+func useRecordingNotifier(t *testing.T) (*recordingNotifier, *notify.Dispatcher) {
+	t.Helper()
+	recorder := &recordingNotifier{}
+	dispatcher := notify.NewDispatcher(notify.Route{Name: "test", Notifier: recorder})
+	currentNotifier.Store(dispatcher)
+	t.Cleanup(func() { currentNotifier.Store(nil) })
+	return recorder, dispatcher
+}
+
+func TestAuthBans_BanClientsAfterRepeatedFailures(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		AppName:              "myapp",
+		APIToken:             "guard-test-token",
+		AuthFailureThreshold: 3,
+		AuthFailureWindow:    300,
+		AuthBanDuration:      900,
+		AuthBanExempt:        []string{"10.0.0.0/8"},
+	})
+	setupAuthGuard()
+	recorder, dispatcher := useRecordingNotifier(t)
+	handler := withAuthBans(requireAPIToken(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(addr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := request("203.0.113.7:5000", "wrong"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected 401, got %d", i+1, rec.Code)
+		}
+		request("10.1.2.3:5000", "wrong")
+	}
+
+	rec := request("203.0.113.7:5001", "guard-test-token")
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected the banned client to get 403 with Retry-After even with the token, got %d", rec.Code)
+	}
+	if rec := request("198.51.100.9:5000", "guard-test-token"); rec.Code != http.StatusOK {
+		t.Errorf("Expected other clients to be unaffected, got %d", rec.Code)
+	}
+	if rec := request("10.1.2.3:5000", "guard-test-token"); rec.Code != http.StatusOK {
+		t.Errorf("Expected a client in auth_ban_exempt not to be banned, got %d", rec.Code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dispatcher.Wait(ctx)
+	events := recorder.Events()
+	if len(events) != 1 || events[0].Type != notify.EventClientBanned || events[0].Fields["client"] != "203.0.113.7" {
+		t.Errorf("Expected one client_banned event for 203.0.113.7, got %+v", events)
+	}
+}
@@ -0,0 +1,164 @@
+// Package authguard counts failed authentication attempts per client address
+// and temporarily bans clients that fail too often, so a shared secret can't
+// be brute-forced endlessly
+package authguard
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config sets when clients are banned
+type Config struct {
+	Threshold int           // Failures within Window that trigger a ban; 0 disables banning
+	Window    time.Duration // How long a failure counts towards the threshold
+	Ban       time.Duration // How long a ban lasts
+	Exempt    []*net.IPNet  // Clients never banned, e.g. a reverse proxy
+}
+
+// Ban is a client that is currently banned
+type Ban struct {
+	Client   string    `json:"client"`
+	Until    time.Time `json:"until"`
+	Failures int       `json:"failures"`
+}
+
+type client struct {
+	failures    []time.Time // Within the window, oldest first
+	bannedUntil time.Time
+}
+
+// Guard tracks failures and bans; it is safe for concurrent use
+type Guard struct {
+	cfg       Config
+	mu        sync.Mutex
+	clients   map[string]*client
+	total     int64
+	bans      int64
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates a Guard
+func New(cfg Config) *Guard {
+	return &Guard{cfg: cfg, clients: make(map[string]*client), now: time.Now}
+}
+
+// Banned reports whether addr is banned and until when
+func (g *Guard) Banned(addr string) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, ok := g.clients[addr]
+	if !ok || !g.now().Before(c.bannedUntil) {
+		return time.Time{}, false
+	}
+	return c.bannedUntil, true
+}
+
+// Fail records a failed attempt by addr. It returns the number of failures
+// within the window and, when this failure triggered a ban, its end.
+func (g *Guard) Fail(addr string) (failures int, bannedUntil time.Time, banned bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	g.total++
+	g.sweep(now)
+
+	c, ok := g.clients[addr]
+	if !ok {
+		c = &client{}
+		g.clients[addr] = c
+	}
+	c.failures = append(recent(c.failures, now.Add(-g.cfg.Window)), now)
+	failures = len(c.failures)
+
+	if g.cfg.Threshold <= 0 || failures < g.cfg.Threshold || now.Before(c.bannedUntil) || g.exempt(addr) {
+		return failures, time.Time{}, false
+	}
+	c.bannedUntil = now.Add(g.cfg.Ban)
+	g.bans++
+	return failures, c.bannedUntil, true
+}
+
+// Bans lists the clients banned now, soonest to expire first
+func (g *Guard) Bans() []Ban {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	bans := []Ban{}
+	for addr, c := range g.clients {
+		if now.Before(c.bannedUntil) {
+			bans = append(bans, Ban{Client: addr, Until: c.bannedUntil, Failures: len(c.failures)})
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+	return bans
+}
+
+// Stats returns the failures and bans recorded since the Guard was created
+func (g *Guard) Stats() (failures, bans int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.total, g.bans
+}
+
+// exempt reports whether addr is in an exempt network
+func (g *Guard) exempt(addr string) bool {
+	ip := net.ParseIP(addr)
+	for _, network := range g.cfg.Exempt {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// sweep forgets clients with no recent failures and no ban, at most once per
+// window, so scanning from many addresses doesn't grow memory without bound
+func (g *Guard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < g.cfg.Window {
+		return
+	}
+	g.lastSweep = now
+	for addr, c := range g.clients {
+		c.failures = recent(c.failures, now.Add(-g.cfg.Window))
+		if len(c.failures) == 0 && !now.Before(c.bannedUntil) {
+			delete(g.clients, addr)
+		}
+	}
+}
+
+// recent drops the failures before cutoff
+func recent(failures []time.Time, cutoff time.Time) []time.Time {
+	i := sort.Search(len(failures), func(i int) bool { return failures[i].After(cutoff) })
+	return failures[i:]
+}
+
+// ParseNetworks parses IP addresses and CIDR ranges
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", value)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,107 @@
+package authguard
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTestGuard(cfg Config) (*Guard, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := New(cfg)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestGuard_BansAfterThreshold(t *testing.T) {
+	g, now := newTestGuard(Config{Threshold: 3, Window: time.Minute, Ban: 10 * time.Minute})
+
+	for i := 1; i <= 2; i++ {
+		if n, _, banned := g.Fail("203.0.113.7"); banned || n != i {
+			t.Fatalf("failure %d: expected no ban, got banned=%v failures=%d", i, banned, n)
+		}
+	}
+	if _, banned := g.Banned("203.0.113.7"); banned {
+		t.Fatal("expected no ban below the threshold")
+	}
+
+	n, until, banned := g.Fail("203.0.113.7")
+	if !banned || n != 3 || !until.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("expected a 10 minute ban on the third failure, got banned=%v failures=%d until=%s", banned, n, until)
+	}
+	if _, banned := g.Banned("203.0.113.7"); !banned {
+		t.Error("expected the client to be banned")
+	}
+	if _, banned := g.Banned("198.51.100.1"); banned {
+		t.Error("expected other clients to be unaffected")
+	}
+
+	// Failing while banned doesn't report a new ban
+	if _, _, banned := g.Fail("203.0.113.7"); banned {
+		t.Error("expected no second ban while banned")
+	}
+
+	*now = now.Add(11 * time.Minute)
+	if _, banned := g.Banned("203.0.113.7"); banned {
+		t.Error("expected the ban to expire")
+	}
+	if failures, bans := g.Stats(); failures != 4 || bans != 1 {
+		t.Errorf("expected 4 failures and 1 ban, got %d and %d", failures, bans)
+	}
+}
+
+func TestGuard_FailuresExpire(t *testing.T) {
+	g, now := newTestGuard(Config{Threshold: 3, Window: time.Minute, Ban: time.Minute})
+	g.Fail("203.0.113.7")
+	g.Fail("203.0.113.7")
+	*now = now.Add(2 * time.Minute)
+	if n, _, banned := g.Fail("203.0.113.7"); banned || n != 1 {
+		t.Errorf("expected old failures to expire, got banned=%v failures=%d", banned, n)
+	}
+}
+
+func TestGuard_ExemptAndDisabled(t *testing.T) {
+	exempt, err := ParseNetworks([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, _ := newTestGuard(Config{Threshold: 1, Window: time.Minute, Ban: time.Minute, Exempt: exempt})
+	for _, addr := range []string{"10.1.2.3", "192.0.2.1"} {
+		if _, _, banned := g.Fail(addr); banned {
+			t.Errorf("expected %s to be exempt", addr)
+		}
+	}
+	if _, _, banned := g.Fail("192.0.2.2"); !banned {
+		t.Error("expected a client outside the exempt networks to be banned")
+	}
+
+	g, _ = newTestGuard(Config{Window: time.Minute, Ban: time.Minute})
+	for i := 0; i < 100; i++ {
+		if _, _, banned := g.Fail("203.0.113.7"); banned {
+			t.Fatal("expected no bans with a zero threshold")
+		}
+	}
+}
+
+func TestGuard_ForgetsIdleClients(t *testing.T) {
+	g, now := newTestGuard(Config{Threshold: 5, Window: time.Minute, Ban: time.Minute})
+	for i := 0; i < 1000; i++ {
+		g.Fail(fmt.Sprintf("203.0.113.%d", i%250))
+	}
+	*now = now.Add(2 * time.Minute)
+	g.Fail("198.51.100.1")
+	if len(g.clients) != 1 {
+		t.Errorf("expected idle clients to be forgotten, %d remain", len(g.clients))
+	}
+	if len(g.Bans()) != 0 {
+		t.Errorf("expected no bans, got %v", g.Bans())
+	}
+}
+
+func TestParseNetworks_Invalid(t *testing.T) {
+	for _, value := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0"} {
+		if _, err := ParseNetworks([]string{value}); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
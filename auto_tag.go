@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"os/exec"
+	"strings"
+
+	"binaryDeploy/history"
+	"binaryDeploy/releasenotes"
+	"binaryDeploy/semver"
+)
+
+// autoTagRelease tags the commit of a successful deployment with the next
+// semantic version, worked out from the conventional commits since the latest
+// version tag, and pushes the tag. The version is stored on rec.
+func autoTagRelease(rec *history.Record) {
+	if !appConfig().AutoTag || rec.Commit == "" || !autoTagEnvironment() {
+		return
+	}
+	repoDir := targetRepoDir()
+	prefix := appConfig().TagPrefix
+
+	// A redeploy or rollback of a released commit keeps its version
+	if existing, _, ok := semver.Latest(prefix, gitLines(repoDir, "tag", "--points-at", rec.Commit)); ok {
+		rec.Version = prefix + existing.String()
+		return
+	}
+
+	latest, latestTag, found := semver.Latest(prefix, gitLines(repoDir, "tag", "--list", "--merged", rec.Commit))
+	revs := []string{"-n", fmt.Sprint(maxReleaseNoteCommits), rec.Commit}
+	if found {
+		revs = []string{"-n", fmt.Sprint(maxReleaseNoteCommits), latestTag + ".." + rec.Commit}
+	}
+	commits, err := gitCommits(repoDir, revs...)
+	if err != nil {
+		slog.Warn("Failed to read commits for version tag", "since", latestTag, "error", err)
+		return
+	}
+	notes := releasenotes.Build(latestTag, rec.Commit, commits)
+	level := notes.BumpLevel()
+	if level == semver.None {
+		return
+	}
+
+	tag := prefix + latest.Bump(level).String()
+	tagger, _ := mail.ParseAddress(appConfig().TagTagger)
+	message := fmt.Sprintf("Release %s\n\n%s", tag, notes.Markdown())
+	if err := runCommandInDir(repoDir, "git", "-c", "user.name="+tagger.Name, "-c", "user.email="+tagger.Address,
+		"tag", "-a", tag, "-m", message, rec.Commit); err != nil {
+		slog.Error("Failed to create version tag", "tag", tag, "error", err)
+		return
+	}
+
+	// Push to the repository URL rather than origin, which may be a shared
+	// mirror
+	if appConfig().TagPush {
+		if err := runCommandInDir(repoDir, "git", gitCommand(rec.Repo, "push", rec.Repo, "refs/tags/"+tag)...); err != nil {
+			// Drop the local tag so the next deployment doesn't build on a
+			// version the remote never saw
+			slog.Error("Failed to push version tag", "tag", tag, "error", err)
+			runCommandInDir(repoDir, "git", "tag", "-d", tag)
+			return
+		}
+	}
+
+	rec.Version = tag
+	slog.Info("Tagged release", "tag", tag, "commit", rec.Commit, "bump", semver.LevelName(level), "previous", latestTag)
+}
+
+// autoTagEnvironment reports whether deployments to the configured
+// environment are tagged
+func autoTagEnvironment() bool {
+	for _, env := range appConfig().AutoTagEnvironments {
+		if strings.EqualFold(env, appConfig().Environment) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitLines runs a git command in repoDir and returns its non-empty output
+// lines, or nothing if it fails
+func gitLines(repoDir string, args ...string) []string {
+	out, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).Output()
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
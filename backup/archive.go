@@ -0,0 +1,112 @@
+// Package backup packs files and directories into gzipped tar archives
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive writes a .tar.gz of paths to w. Directories are included
+// recursively; entries are named by their path with any leading "/" removed.
+// Symlinks are stored as links, other special files are skipped.
+func Archive(w io.Writer, paths []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return addEntry(tw, path, d)
+		})
+		if err != nil {
+			return fmt.Errorf("archiving %s: %w", root, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// File is an in-memory file for ArchiveFiles
+type File struct {
+	Name string
+	Data []byte
+}
+
+// ArchiveFiles writes a .tar.gz of generated files to w, each stored with mode
+// 0600 and modification time modTime
+func ArchiveFiles(w io.Writer, files []File, modTime time.Time) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, f := range files {
+		header := &tar.Header{
+			Name:    f.Name,
+			Mode:    0600,
+			Size:    int64(len(f.Data)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("archiving %s: %w", f.Name, err)
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return fmt.Errorf("archiving %s: %w", f.Name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addEntry(tw *tar.Writer, path string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() && !info.IsDir() && info.Mode()&fs.ModeSymlink == 0 {
+		return nil
+	}
+
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = strings.TrimLeft(filepath.ToSlash(path), "/")
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(tw, f, header.Size)
+	return err
+}
@@ -0,0 +1,90 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "data", "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "data", "db.sqlite"), []byte("rows"), 0644)
+	os.WriteFile(filepath.Join(dir, "data", "sub", "uploads.txt"), []byte("files"), 0644)
+
+	var buf bytes.Buffer
+	if err := Archive(&buf, []string{filepath.Join(dir, "data")}); err != nil {
+		t.Fatalf("Archive failed: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("not a gzip stream: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	contents := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading archive: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		contents[header.Name] = string(data)
+	}
+
+	prefix := strings.TrimLeft(filepath.ToSlash(dir), "/")
+	if contents[prefix+"/data/db.sqlite"] != "rows" || contents[prefix+"/data/sub/uploads.txt"] != "files" {
+		t.Errorf("unexpected archive contents %v", contents)
+	}
+	if _, ok := contents[prefix+"/data/sub/"]; !ok {
+		t.Error("expected directory entries in the archive")
+	}
+}
+
+func TestArchive_MissingPath(t *testing.T) {
+	if err := Archive(io.Discard, []string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+}
+
+func TestArchiveFiles(t *testing.T) {
+	var buf bytes.Buffer
+	files := []File{{Name: "build.log", Data: []byte("error: missing module")}, {Name: "git.txt", Data: []byte("HEAD abc")}}
+	if err := ArchiveFiles(&buf, files, time.Now()); err != nil {
+		t.Fatalf("ArchiveFiles failed: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("not a gzip stream: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading archive: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		if header.Name == "build.log" && string(data) != "error: missing module" {
+			t.Errorf("unexpected build.log contents %q", data)
+		}
+		names = append(names, header.Name)
+	}
+	if strings.Join(names, ",") != "build.log,git.txt" {
+		t.Errorf("unexpected entries %v", names)
+	}
+}
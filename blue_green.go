@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/processmanager"
+)
+
+// releaseColors name the two releases of the blue-green strategy; each runs
+// as the process group instance of the same index
+var releaseColors = [2]string{"blue", "green"}
+
+const (
+	releasesDirectory    = "releases"
+	currentReleaseLink   = "current"
+	switchCommandTimeout = 2 * time.Minute
+)
+
+// liveRelease is the index of the release serving traffic, or -1 before the
+// first blue-green deployment
+var liveRelease atomic.Int32
+
+// releaseInfo describes the build in a release directory
+type releaseInfo struct {
+	Color    string    `json:"color"`
+	Commit   string    `json:"commit,omitempty"`
+	Branch   string    `json:"branch,omitempty"`
+	StagedAt time.Time `json:"staged_at"`
+}
+
+// releaseStatus is the /releases view of a release
+type releaseStatus struct {
+	releaseInfo
+	Live    bool   `json:"live"`
+	Running bool   `json:"running"`
+	Port    int    `json:"port"`
+	Dir     string `json:"dir"`
+}
+
+func blueGreenEnabled() bool {
+	return appConfig().DeploymentStrategy == config.StrategyBlueGreen
+}
+
+// setupBlueGreen picks up which release was live before binaryDeploy
+// restarted, so the next deployment keeps it as the previous release
+func setupBlueGreen() {
+	liveRelease.Store(-1)
+	if !blueGreenEnabled() {
+		return
+	}
+
+	target, err := os.Readlink(filepath.Join(releasesDir(), currentReleaseLink))
+	if err == nil {
+		for i, color := range releaseColors {
+			if target == color {
+				liveRelease.Store(int32(i))
+			}
+		}
+	}
+	slog.Info("Blue-green deployments enabled", "ports", appConfig().BlueGreenPorts, "proxy", appConfig().BlueGreenProxy, "previous_live", liveColor())
+}
+
+// liveInstance is the process group instance serving traffic
+func liveInstance() int {
+	if i := liveRelease.Load(); blueGreenEnabled() && i >= 0 {
+		return int(i)
+	}
+	return 0
+}
+
+func liveColor() string {
+	if i := liveRelease.Load(); i >= 0 {
+		return releaseColors[i]
+	}
+	return ""
+}
+
+// liveReleaseCommit is the commit the live release was built from
+func liveReleaseCommit() string {
+	i := liveRelease.Load()
+	if i < 0 {
+		return ""
+	}
+	info, _ := readReleaseInfo(int(i))
+	return info.Commit
+}
+
+func releasesDir() string {
+	return filepath.Join(appConfig().DeployDir, releasesDirectory)
+}
+
+func releaseDir(i int) string {
+	return filepath.Join(releasesDir(), releaseColors[i])
+}
+
+// releaseWorkingDir is where the application of release i runs
+func releaseWorkingDir(i int) string {
+	if appConfig().WorkingDir != "" {
+		return filepath.Join(releaseDir(i), appConfig().WorkingDir)
+	}
+	return releaseDir(i)
+}
+
+// deployBlueGreen copies the build in the checkout to the release that isn't
+// live, starts it beside the live one and switches traffic to it once it is
+// healthy. The previous release stays on disk for rollback.
+func deployBlueGreen(profile *deployProfile, req DeployRequest, commit string) error {
+	live := int(liveRelease.Load())
+
+	// A restart reruns the live release as it is: the checkout may hold a
+	// build that was rolled back
+	if req.IsRestart() && live >= 0 {
+		slog.Info("Restarting live release", "color", releaseColors[live])
+		if err := profile.step("start", func() error {
+			return startRelease(live)
+		}); err != nil {
+			return fmt.Errorf("failed to start application process: %w", err)
+		}
+		if err := profile.step("health", func() error { return waitForInstanceReady(live) }); err != nil {
+			return fmt.Errorf("application did not become healthy: %w", err)
+		}
+		return nil
+	}
+
+	next := 0
+	if live == 0 {
+		next = 1
+	}
+	err := profile.step("stage", func() error {
+		return stageRelease(next, releaseInfo{Commit: commit, Branch: req.Branch})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to stage %s release: %w", releaseColors[next], err)
+	}
+	if err := promoteRelease(profile, next); err != nil {
+		// A release that never went live is nothing to roll back to
+		os.Remove(releaseInfoPath(next))
+		return err
+	}
+	return nil
+}
+
+// startRelease (re)starts the application of release i, resolving the
+// placeholders of run_command for the commit it holds
+func startRelease(i int) error {
+	info, _ := readReleaseInfo(i)
+	dir := releaseWorkingDir(i)
+	cfg, err := instanceConfig(appConfig(), i, templateData(info.Branch, info.Commit, dir))
+	if err != nil {
+		return err
+	}
+	return processManager.StartInstance(i, cfg, dir)
+}
+
+// rollbackBlueGreen switches traffic back to the release that was live
+// before the current one, returning the commit it runs
+func rollbackBlueGreen(profile *deployProfile) (string, error) {
+	if !blueGreenEnabled() {
+		return "", fmt.Errorf("previous_release requires deployment_strategy=%s", config.StrategyBlueGreen)
+	}
+	live := int(liveRelease.Load())
+	if live < 0 {
+		return "", fmt.Errorf("no blue-green release is live yet")
+	}
+	previous := 1 - live
+	info, err := readReleaseInfo(previous)
+	if err != nil {
+		return "", fmt.Errorf("no previous release to roll back to: %w", err)
+	}
+
+	slog.Info("Rolling back to previous release", "color", releaseColors[previous], "commit", info.Commit)
+	return info.Commit, promoteRelease(profile, previous)
+}
+
+// promoteRelease starts release next, waits for it to become healthy, warms
+// it up, switches traffic to it and stops the release it replaces. If
+// anything fails before the switch the live release keeps serving.
+func promoteRelease(profile *deployProfile, next int) error {
+	color := releaseColors[next]
+	live := int(liveRelease.Load())
+
+	slog.Info("Starting release", "color", color, "port", processmanager.InstancePort(appConfig(), next), "working_dir", releaseWorkingDir(next))
+	err := profile.step("start", func() error {
+		return startRelease(next)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start %s release: %w", color, err)
+	}
+
+	// Don't leave a broken release running beside the live one
+	abandon := func(err error) error {
+		if stopErr := processManager.StopInstance(next); stopErr != nil {
+			slog.Error("Failed to stop abandoned release", "color", color, "error", stopErr)
+		}
+		if live >= 0 {
+			return fmt.Errorf("%w; the %s release keeps serving", err, releaseColors[live])
+		}
+		return err
+	}
+
+	if err := profile.step("health", func() error { return waitForInstanceReady(next) }); err != nil {
+		return abandon(fmt.Errorf("%s release did not become healthy: %w", color, err))
+	}
+	profile.step("warmup", func() error {
+		warmUpURLs(func(u string) string { return instanceURL(u, next) })
+		return nil
+	})
+	if err := profile.step("switch", func() error { return switchTraffic(next) }); err != nil {
+		return abandon(fmt.Errorf("failed to switch traffic to %s release: %w", color, err))
+	}
+
+	if live >= 0 && live != next {
+		err := profile.step("stop", func() error { return processManager.StopInstance(live) })
+		if err != nil {
+			slog.Error("Failed to stop previous release", "color", releaseColors[live], "error", err)
+		}
+	}
+	return nil
+}
+
+// switchTraffic runs blue_green_switch_command for release next, then makes
+// it the live release of the built-in proxy and the current symlink
+func switchTraffic(next int) error {
+	if command := appConfig().BlueGreenSwitchCommand; command != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), switchCommandTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = releaseWorkingDir(next)
+		cmd.Env = append(os.Environ(),
+			"BLUE_GREEN_COLOR="+releaseColors[next],
+			"BLUE_GREEN_PORT="+strconv.Itoa(processmanager.InstancePort(appConfig(), next)),
+			"BLUE_GREEN_DIR="+releaseDir(next),
+		)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("blue_green_switch_command: %w", err)
+		}
+	}
+
+	// Swap the symlink atomically so it never dangles
+	link := filepath.Join(releasesDir(), currentReleaseLink)
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(releaseColors[next], tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	liveRelease.Store(int32(next))
+	slog.Info("Switched traffic to release", "color", releaseColors[next], "port", processmanager.InstancePort(appConfig(), next))
+	return nil
+}
+
+// stageRelease replaces release i with a copy of the checkout, leaving out
+// its git metadata
+func stageRelease(i int, info releaseInfo) error {
+	dir := releaseDir(i)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := copyTree(targetRepoDir(), dir); err != nil {
+		return err
+	}
+
+	info.Color = releaseColors[i]
+	info.StagedAt = time.Now().UTC()
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	slog.Info("Staged release", "color", info.Color, "commit", info.Commit, "dir", dir)
+	return os.WriteFile(releaseInfoPath(i), data, 0644)
+}
+
+func releaseInfoPath(i int) string {
+	return filepath.Join(releasesDir(), releaseColors[i]+".json")
+}
+
+func readReleaseInfo(i int) (releaseInfo, error) {
+	var info releaseInfo
+	data, err := os.ReadFile(releaseInfoPath(i))
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("%s: %w", releaseInfoPath(i), err)
+	}
+	if _, err := os.Stat(releaseDir(i)); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// copyTree copies the files, directories and symlinks under src to dst,
+// skipping src/.git
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == ".git" {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil // Sockets, pipes and devices aren't part of a build
+	})
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// startBlueGreenProxy serves the application port, forwarding every request
+// to the live release. It returns nil when the proxy is disabled.
+func startBlueGreenProxy() *http.Server {
+	if !blueGreenEnabled() || !appConfig().BlueGreenProxy {
+		return nil
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			port := processmanager.InstancePort(appConfig(), liveInstance())
+			r.SetURL(&url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", strconv.Itoa(port))})
+			r.Out.Host = r.In.Host
+			r.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Warn("Live release unavailable", "color", liveColor(), "path", r.URL.Path, "error", err)
+			http.Error(w, "Application unavailable", http.StatusBadGateway)
+		},
+	}
+	server := &http.Server{
+		Addr: ":" + strconv.Itoa(appConfig().ApplicationPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if liveRelease.Load() < 0 {
+				http.Error(w, "Application is starting", http.StatusServiceUnavailable)
+				return
+			}
+			proxy.ServeHTTP(w, r)
+		}),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ConnState:         trackConnection,
+	}
+
+	// Listen up front, so the socket is handed over on a self-update restart
+	ln, err := listen(server.Addr)
+	if err != nil {
+		slog.Error("Blue-green proxy failed", "addr", server.Addr, "error", err)
+		return server
+	}
+
+	go func() {
+		slog.Info("Starting blue-green proxy", "addr", server.Addr)
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Blue-green proxy failed", "addr", server.Addr, "error", err)
+		}
+	}()
+	return server
+}
+
+// releasesHandler reports the blue and green releases
+func releasesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !blueGreenEnabled() {
+		http.Error(w, "deployment_strategy is not blue-green", http.StatusNotFound)
+		return
+	}
+
+	releases := make([]releaseStatus, 0, len(releaseColors))
+	for i := range releaseColors {
+		info, err := readReleaseInfo(i)
+		if err != nil {
+			continue
+		}
+		releases = append(releases, releaseStatus{
+			releaseInfo: info,
+			Live:        liveRelease.Load() == int32(i),
+			Running:     processManager.InstanceRunning(i),
+			Port:        processmanager.InstancePort(appConfig(), i),
+			Dir:         releaseDir(i),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"live":     liveColor(),
+		"releases": releases,
+	})
+}
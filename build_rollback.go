@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/health"
+	"binaryDeploy/processmanager"
+)
+
+const (
+	buildsDirectory = "builds"
+
+	// Consecutive failed health checks that fail the rollback window
+	rollbackHealthFailures = 3
+	rollbackHealthInterval = 5 * time.Second
+)
+
+// errRolledBack marks a deployment whose new build failed and was replaced by
+// the previous one
+var errRolledBack = errors.New("rolled back")
+
+// deployedBuild describes a build in <deploy_dir>/builds that went live.
+// Builds that were staged but never passed their rollback window have no
+// record and are removed.
+type deployedBuild struct {
+	ID         string    `json:"id"`
+	Commit     string    `json:"commit,omitempty"`
+	Branch     string    `json:"branch,omitempty"`
+	DeployedAt time.Time `json:"deployed_at"`
+}
+
+func buildsDir() string {
+	return filepath.Join(appConfig().DeployDir, buildsDirectory)
+}
+
+// buildWorkingDir is where the application of build id runs
+func buildWorkingDir(id string) string {
+	if appConfig().WorkingDir != "" {
+		return filepath.Join(buildsDir(), id, appConfig().WorkingDir)
+	}
+	return filepath.Join(buildsDir(), id)
+}
+
+// stageBuild copies the build in the checkout, without its git metadata, to
+// a new versioned directory and returns its ID
+func stageBuild(commit string) (string, error) {
+	id := time.Now().UTC().Format("20060102-150405")
+	if commit != "" {
+		id += "-" + shortSHA(commit)
+	}
+	dir := filepath.Join(buildsDir(), id)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := copyTree(targetRepoDir(), dir); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	slog.Info("Staged build", "build", id, "dir", dir)
+	return id, nil
+}
+
+// deployedBuilds lists the builds that went live, newest first
+func deployedBuilds() []deployedBuild {
+	files, _ := filepath.Glob(filepath.Join(buildsDir(), "*.json"))
+	var builds []deployedBuild
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var build deployedBuild
+		if json.Unmarshal(data, &build) != nil || build.ID == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(buildsDir(), build.ID)); err != nil {
+			continue
+		}
+		builds = append(builds, build)
+	}
+	sort.Slice(builds, func(i, j int) bool { return builds[i].DeployedAt.After(builds[j].DeployedAt) })
+	return builds
+}
+
+// currentBuild is the build that went live last, if any
+func currentBuild() (deployedBuild, bool) {
+	builds := deployedBuilds()
+	if len(builds) == 0 {
+		return deployedBuild{}, false
+	}
+	return builds[0], true
+}
+
+// markBuildDeployed records build as live and removes builds beyond the
+// keep_builds most recent ones
+func markBuildDeployed(build deployedBuild) {
+	build.DeployedAt = time.Now().UTC()
+	data, err := json.Marshal(build)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(buildsDir(), build.ID+".json"), data, 0644)
+	}
+	if err != nil {
+		slog.Error("Failed to record deployed build, it won't be rolled back to", "build", build.ID, "error", err)
+	}
+	pruneBuilds()
+}
+
+// pruneBuilds keeps the keep_builds most recently deployed builds and
+// removes everything else, including builds that never went live
+func pruneBuilds() {
+	keep := map[string]bool{}
+	for i, build := range deployedBuilds() {
+		if i < appConfig().KeepBuilds {
+			keep[build.ID] = true
+		}
+	}
+
+	entries, err := os.ReadDir(buildsDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		if keep[id] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(buildsDir(), entry.Name())); err != nil {
+			slog.Warn("Failed to remove old build", "build", id, "error", err)
+		} else if entry.IsDir() {
+			slog.Info("Removed old build", "build", id)
+		}
+	}
+}
+
+// watchNewBuild fails if the application dies or keeps failing its health
+// check within rollback_window of since, when it was started
+func watchNewBuild(since time.Time) error {
+	window := time.Duration(appConfig().RollbackWindow) * time.Second
+	if window <= 0 {
+		return nil
+	}
+	slog.Info("Watching new build", "window", window.String())
+
+	deadline := since.Add(window)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastHealthCheck := time.Now()
+	healthFailures := 0
+	for now := time.Now(); now.Before(deadline); now = <-ticker.C {
+		for _, e := range processManager.Events().Recent(0) {
+			if e.Time.After(since) && (e.Type == processmanager.EventCrashed || e.Type == processmanager.EventExited) {
+				return fmt.Errorf("application instance %d died %s after starting: %s",
+					e.Instance, e.Time.Sub(since).Round(time.Second), crashSummary(e))
+			}
+		}
+
+		if appConfig().HealthCheckURL == "" || now.Sub(lastHealthCheck) < rollbackHealthInterval {
+			continue
+		}
+		lastHealthCheck = now
+		if err := checkInstancesHealthy(); err != nil {
+			if healthFailures++; healthFailures >= rollbackHealthFailures {
+				return fmt.Errorf("application became unhealthy after starting: %w", err)
+			}
+			slog.Warn("New build failed a health check", "failures", healthFailures, "error", err)
+		} else {
+			healthFailures = 0
+		}
+	}
+	return nil
+}
+
+// checkInstancesHealthy checks health_check_url on every instance
+func checkInstancesHealthy() error {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackHealthInterval)
+	defer cancel()
+
+	for i := 0; i < processManager.Size(); i++ {
+		if err := health.Check(ctx, instanceURL(appConfig().HealthCheckURL, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackBuild restarts the build that was live before the failed one,
+// returning an errRolledBack describing cause. Without a previous build cause
+// is returned as it is.
+func rollbackBuild(profile *deployProfile, deployConfig *config.DeployConfig, cause error) error {
+	previous, ok := currentBuild()
+	if !ok {
+		slog.Warn("No previous build to roll back to", "error", cause)
+		return cause
+	}
+
+	slog.Warn("New build failed, rolling back to the previous build", "build", previous.ID, "commit", previous.Commit, "error", cause)
+	err := profile.step("rollback", func() error {
+		dir := buildWorkingDir(previous.ID)
+		return startInstances(nil, deployConfig, dir, templateData(previous.Branch, previous.Commit, dir))
+	})
+	if err != nil {
+		return fmt.Errorf("%w; rolling back to build %s also failed: %v", cause, previous.ID, err)
+	}
+	slog.Info("Rolled back to the previous build", "build", previous.ID, "commit", previous.Commit)
+	return fmt.Errorf("%w to build %s (commit %s): %w", errRolledBack, previous.ID, shortSHA(previous.Commit), cause)
+}
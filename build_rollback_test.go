@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/processmanager"
+)
+
+func TestStageBuild_KeepsOnlyDeployedBuilds(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{DeployDir: t.TempDir(), KeepBuilds: 2})
+	for path, content := range map[string]string{"app": "binary", ".git/HEAD": "ref: refs/heads/main\n"} {
+		path = filepath.Join(targetRepoDir(), path)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	for _, commit := range []string{"1111111aaaa", "2222222bbbb", "3333333cccc"} {
+		id, err := stageBuild(commit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(id, "-"+shortSHA(commit)) {
+			t.Errorf("Expected the build ID to name its commit, got %s", id)
+		}
+		if _, err := os.Stat(filepath.Join(buildsDir(), id, ".git")); !os.IsNotExist(err) {
+			t.Errorf("Expected build %s without git metadata", id)
+		}
+		markBuildDeployed(deployedBuild{ID: id, Commit: commit, Branch: "main"})
+		ids = append(ids, id)
+	}
+	unreleased, err := stageBuild("4444444dddd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruneBuilds()
+
+	builds := deployedBuilds()
+	if len(builds) != 2 || builds[0].ID != ids[2] || builds[1].ID != ids[1] {
+		t.Fatalf("Expected the two most recent builds newest first, got %+v", builds)
+	}
+	for _, id := range []string{ids[0], unreleased} {
+		if _, err := os.Stat(filepath.Join(buildsDir(), id)); !os.IsNotExist(err) {
+			t.Errorf("Expected build %s to be removed", id)
+		}
+	}
+	if current, ok := currentBuild(); !ok || current.Commit != "3333333cccc" {
+		t.Errorf("Expected the last deployed build to be current, got %+v", current)
+	}
+}
+
+func TestWatchNewBuild_FailsWhenTheApplicationDies(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{RollbackWindow: 2})
+	processManager = processmanager.NewGroup(1)
+
+	since := time.Now()
+	if err := watchNewBuild(since); err != nil {
+		t.Fatalf("Expected a build that stays up to pass, got %v", err)
+	}
+
+	since = time.Now()
+	processManager.Events().Add(processmanager.Event{Time: since.Add(time.Millisecond), Type: processmanager.EventCrashed})
+	if err := watchNewBuild(since); err == nil || !strings.Contains(err.Error(), "died") {
+		t.Errorf("Expected a crash within rollback_window to fail the build, got %v", err)
+	}
+}
+
+func TestRollbackBuild_RestartsThePreviousBuild(t *testing.T) {
+	cfg, _ := setupRolloutTest(t, 1)
+	cfg.DeployDir = t.TempDir()
+	cfg.KeepBuilds = 3
+	cause := errors.New("application instance 0 died")
+
+	if err := rollbackBuild(nil, cfg, cause); err != cause {
+		t.Fatalf("Expected the cause as it is without a previous build, got %v", err)
+	}
+
+	previous := deployedBuild{ID: "20260301-120000-1111111", Commit: "1111111aaaa", Branch: "main"}
+	if err := os.MkdirAll(filepath.Join(buildsDir(), previous.ID), 0755); err != nil {
+		t.Fatal(err)
+	}
+	markBuildDeployed(previous)
+
+	err := rollbackBuild(nil, cfg, cause)
+	if !errors.Is(err, errRolledBack) || !errors.Is(err, cause) || !strings.Contains(err.Error(), previous.ID) {
+		t.Fatalf("Expected a rollback to %s wrapping the cause, got %v", previous.ID, err)
+	}
+	if !processManager.InstanceRunning(0) {
+		t.Error("Expected the previous build to be running")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"binaryDeploy/history"
+)
+
+// deployChainHeader carries the upstream apps of a chained deployment so
+// downstream instances can record the chain and detect cycles
+const deployChainHeader = "X-Deploy-Chain"
+
+var chainClient = &http.Client{Timeout: 30 * time.Minute}
+
+// parseDeployChain returns the upstream apps listed in the request's chain
+// header
+func parseDeployChain(r *http.Request) []string {
+	var chain []string
+	for _, app := range strings.Split(r.Header.Get(deployChainHeader), ",") {
+		if app = strings.TrimSpace(app); app != "" {
+			chain = append(chain, app)
+		}
+	}
+	return chain
+}
+
+// runDeployChain deploys the downstream instances in chain_deploy_urls, in
+// order, after this app's deployment finished. With chain_on=success nothing
+// is deployed after a failure, upstream or downstream; with chain_on=always
+// every downstream is redeployed regardless of the outcome. Downstream
+// deployments share correlationID.
+func runDeployChain(upstream []string, correlationID string, succeeded bool) []history.DownstreamResult {
+	if len(appConfig().ChainDeployURLs) == 0 {
+		return nil
+	}
+
+	chain := append(append([]string{}, upstream...), appConfig().AppName)
+	onlyOnSuccess := appConfig().ChainOn != "always"
+	skip := onlyOnSuccess && !succeeded
+
+	results := make([]history.DownstreamResult, 0, len(appConfig().ChainDeployURLs))
+	for _, url := range appConfig().ChainDeployURLs {
+		if skip {
+			results = append(results, history.DownstreamResult{URL: url, Result: history.ResultSkipped})
+			continue
+		}
+
+		slog.Info("Triggering chained deployment", "url", url, "chain", strings.Join(chain, ","))
+		if err := deployDownstream(url, chain, correlationID); err != nil {
+			slog.Error("Chained deployment failed", "url", url, "error", err)
+			results = append(results, history.DownstreamResult{URL: url, Result: history.ResultFailure, Error: err.Error()})
+			skip = onlyOnSuccess
+			continue
+		}
+
+		slog.Info("Chained deployment completed", "url", url)
+		results = append(results, history.DownstreamResult{URL: url, Result: history.ResultSuccess})
+	}
+
+	return results
+}
+
+// deployDownstream calls /deploy on the binaryDeploy instance at baseURL and
+// waits for its deployment (and any further chain) to finish
+func deployDownstream(baseURL string, chain []string, correlationID string) error {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(baseURL, "/")+"/deploy", nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set(deployChainHeader, strings.Join(chain, ","))
+	if correlationID != "" {
+		req.Header.Set(correlationHeader, correlationID)
+	}
+	if appConfig().ChainToken != "" {
+		req.Header.Set("Authorization", "Bearer "+appConfig().ChainToken)
+	}
+
+	resp, err := chainClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling downstream instance: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("downstream returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
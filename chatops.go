@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"binaryDeploy/chatops"
+	"binaryDeploy/history"
+)
+
+// maxChatOpsPayloadSize bounds the form body accepted by /chatops
+const maxChatOpsPayloadSize = 64 * 1024
+
+// chatopsHandler serves Slack and Mattermost slash commands:
+//
+//	/deploy <app> [branch]
+//	/status <app>
+//	/rollback <app>
+//
+// The reply is sent straight away; the outcome of a deployment or rollback
+// is posted to the command's response_url when it finishes.
+func chatopsHandler(w http.ResponseWriter, r *http.Request) {
+	verifier := chatops.Verifier{SigningSecret: appConfig().ChatOpsSigningSecret, Token: appConfig().ChatOpsToken}
+	if !verifier.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := readWebhookBody(w, r, maxChatOpsPayloadSize, nil)
+	if err != nil {
+		writeWebhookBodyError(w, err)
+		return
+	}
+	if err := verifier.Verify(r.Header, body, time.Now()); err != nil {
+		slog.WarnContext(r.Context(), "Rejected chat-ops request", "error", err, "remote_addr", r.RemoteAddr)
+		recordAuthFailure(r, "invalid chat-ops signature")
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Invalid form body", http.StatusBadRequest)
+		return
+	}
+	cmd, err := chatops.ParseCommand(form)
+	if err != nil {
+		chatops.Reply(w, chatops.Ephemeral("%v. Usage: %s", err, chatOpsUsage()))
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Chat-ops command received", "command", cmd.Name, "args", strings.Join(cmd.Args, " "), "user", cmd.User, "channel", cmd.Channel)
+
+	if len(cmd.Args) == 0 {
+		chatops.Reply(w, chatops.Ephemeral("Missing application name. Usage: %s", chatOpsUsage()))
+		return
+	}
+	if cmd.Args[0] != appConfig().AppName {
+		chatops.Reply(w, chatops.Ephemeral("Unknown application %q; this server deploys %s", cmd.Args[0], appConfig().AppName))
+		return
+	}
+
+	switch cmd.Name {
+	case "deploy":
+		chatops.Reply(w, chatOpsDeploy(r, cmd))
+	case "status":
+		chatops.Reply(w, chatops.Ephemeral("%s", chatOpsStatus()))
+	case "rollback":
+		chatops.Reply(w, chatOpsRollback(r, cmd))
+	default:
+		chatops.Reply(w, chatops.Ephemeral("Unknown command %q. Usage: %s", cmd.Name, chatOpsUsage()))
+	}
+}
+
+func chatOpsUsage() string {
+	return "`/deploy <app> [branch]`, `/status <app>`, `/rollback <app>`"
+}
+
+// chatOpsDeploy starts a deployment of the app's default or given branch
+func chatOpsDeploy(r *http.Request, cmd chatops.Command) chatops.Response {
+	req := DeployRequest{
+		RepoURL:       appConfig().TargetRepoURL,
+		Trigger:       "Chat-ops deployment",
+		TriggeredBy:   cmd.User,
+		Tags:          []string{history.TagChatOps},
+		CorrelationID: requestCorrelationID(r),
+	}
+	if len(cmd.Args) > 1 {
+		req.Branch = cmd.Args[1]
+	}
+	if err := validateDeployRequest(&req); err != nil {
+		return chatops.Ephemeral("Cannot deploy: %v", err)
+	}
+	if req.Branch != "" && !isAllowedBranch(req.Branch) {
+		return chatops.Ephemeral("Branch %s is not in allowed_branches", req.Branch)
+	}
+	if blocked, _, message := freezeGate(r, req, false); blocked {
+		return chatops.Ephemeral("%s", message)
+	}
+
+	target := appConfig().AppName
+	if req.Branch != "" {
+		target += "@" + req.Branch
+	}
+	startChatOpsDeployment(req, cmd, target)
+	return chatops.InChannel("%s is deploying %s", cmd.User, target)
+}
+
+// chatOpsRollback redeploys the last successfully deployed commit other
+// than the current one
+func chatOpsRollback(r *http.Request, cmd chatops.Command) chatops.Response {
+	if deployHistory == nil {
+		return chatops.Ephemeral("Rollback needs deployment history, which is unavailable")
+	}
+
+	current := gitHeadCommit(targetRepoDir())
+	previous, err := deployHistory.Query(1, func(rec history.Record) bool {
+		return rec.App == appConfig().AppName && rec.Kind == history.KindDeploy && rec.Result == history.ResultSuccess &&
+			rec.Commit != "" && rec.Commit != current
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to read deployment history for rollback", "error", err)
+		return chatops.Ephemeral("Failed to read deployment history: %v", err)
+	}
+	if len(previous) == 0 {
+		return chatops.Ephemeral("No earlier successful deployment of %s to roll back to", appConfig().AppName)
+	}
+
+	req := DeployRequest{
+		RepoURL:       appConfig().TargetRepoURL,
+		Branch:        previous[0].Branch,
+		Commit:        previous[0].Commit,
+		Trigger:       "Chat-ops rollback",
+		TriggeredBy:   cmd.User,
+		Tags:          []string{history.TagChatOps, "rollback"},
+		CorrelationID: requestCorrelationID(r),
+	}
+	if blocked, _, message := freezeGate(r, req, false); blocked {
+		return chatops.Ephemeral("%s", message)
+	}
+
+	target := fmt.Sprintf("%s to %s", appConfig().AppName, shortSHA(req.Commit))
+	startChatOpsDeployment(req, cmd, target)
+	return chatops.InChannel("%s is rolling back %s (deployed %s)", cmd.User, target, previous[0].FinishedAt.Format(time.RFC3339))
+}
+
+// startChatOpsDeployment runs req in the background and posts the outcome
+// to the command's response_url
+func startChatOpsDeployment(req DeployRequest, cmd chatops.Command, target string) {
+	go func() {
+		var resp chatops.Response
+		if err := runDeployment(req); err != nil {
+			slog.Error(req.Trigger+" failed", "error", err)
+			resp = chatops.InChannel("Deploying %s failed: %s", target, secretRedactor.Redact(err.Error()))
+		} else {
+			resp = chatops.InChannel("Deployed %s (%s)", target, shortSHA(gitHeadCommit(targetRepoDir())))
+		}
+
+		if cmd.ResponseURL == "" {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := chatops.PostResponse(ctx, cmd.ResponseURL, resp); err != nil {
+			slog.Warn("Failed to post chat-ops response", "error", err)
+		}
+	}()
+}
+
+// chatOpsStatus summarizes the app's process, last deployment and
+// dependencies
+func chatOpsStatus() string {
+	status := processManager.GetWebStatus()
+	var b strings.Builder
+
+	if status["running"] == true {
+		fmt.Fprintf(&b, "*%s* is running (pid %v, up %v, %v restarts)", appConfig().AppName, status["pid"], status["uptime"], status["restart_count"])
+	} else {
+		fmt.Fprintf(&b, "*%s* is not running", appConfig().AppName)
+	}
+	if commit := gitHeadCommit(targetRepoDir()); commit != "" {
+		fmt.Fprintf(&b, "\nCommit: %s", shortSHA(commit))
+	}
+
+	if deployHistory != nil {
+		recent, err := deployHistory.Query(1, func(rec history.Record) bool {
+			return rec.App == appConfig().AppName && rec.Kind == history.KindDeploy
+		})
+		if err == nil && len(recent) > 0 {
+			last := recent[0]
+			fmt.Fprintf(&b, "\nLast deployment: %s %s by %s at %s", last.Result, shortSHA(last.Commit), last.TriggeredBy, last.FinishedAt.Format(time.RFC3339))
+		}
+	}
+
+	if dependencyMonitor != nil {
+		for _, dep := range dependencyMonitor.Statuses() {
+			state := "up"
+			if !dep.Healthy {
+				state = "DOWN: " + dep.Error
+			}
+			fmt.Fprintf(&b, "\nDependency %s: %s", dep.Name, state)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,186 @@
+// Package chatops implements the request side of Slack and Mattermost slash
+// commands: verifying that a request really came from the chat server,
+// parsing the command and replying.
+package chatops
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxClockSkew is how old a signed Slack request may be before it is treated
+// as a replay
+const MaxClockSkew = 5 * time.Minute
+
+// ErrUnauthorized is returned when a request is neither correctly signed nor
+// carries the expected token
+var ErrUnauthorized = errors.New("request is not signed by the chat server")
+
+// Verifier authenticates slash command requests. Slack requests are signed
+// with the app's signing secret; Mattermost sends the command's token.
+type Verifier struct {
+	SigningSecret string // Slack signing secret
+	Token         string // Mattermost (or legacy Slack) verification token
+}
+
+// Enabled reports whether any credential is configured
+func (v Verifier) Enabled() bool {
+	return v.SigningSecret != "" || v.Token != ""
+}
+
+// Verify checks the request headers and raw form body. A request passes if it
+// carries a valid Slack signature or the configured token.
+func (v Verifier) Verify(header http.Header, body []byte, now time.Time) error {
+	if v.SigningSecret != "" && header.Get("X-Slack-Signature") != "" {
+		return VerifySlackSignature(v.SigningSecret, header, body, now)
+	}
+	if v.Token != "" {
+		token := strings.TrimPrefix(header.Get("Authorization"), "Token ")
+		if token == "" {
+			if form, err := url.ParseQuery(string(body)); err == nil {
+				token = form.Get("token")
+			}
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(v.Token)) == 1 {
+			return nil
+		}
+	}
+	return ErrUnauthorized
+}
+
+// VerifySlackSignature checks the v0 request signature Slack sends in
+// X-Slack-Signature, rejecting requests older than MaxClockSkew
+func VerifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrUnauthorized
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > MaxClockSkew || age < -MaxClockSkew {
+		return fmt.Errorf("%w: timestamp is %s off", ErrUnauthorized, age.Round(time.Second))
+	}
+
+	expected := "v0=" + Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(header.Get("X-Slack-Signature")), []byte(expected)) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// Sign computes the hex HMAC of a Slack request, without the "v0=" prefix
+func Sign(secret, timestamp string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "v0:%s:", timestamp)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Command is a parsed slash command
+type Command struct {
+	Name        string   // deploy, status, rollback, ...
+	Args        []string // Remaining words, e.g. app and branch
+	User        string
+	Channel     string
+	ResponseURL string // Where delayed replies are posted
+}
+
+// ParseCommand reads a slash command from its form fields. Both dedicated
+// commands ("/deploy myapp main") and a single umbrella command whose first
+// word is the action ("/binarydeploy deploy myapp main") are accepted.
+func ParseCommand(form url.Values) (Command, error) {
+	words := strings.Fields(form.Get("text"))
+	name := strings.TrimPrefix(strings.TrimSpace(form.Get("command")), "/")
+	if name == "" {
+		return Command{}, fmt.Errorf("missing command")
+	}
+
+	cmd := Command{
+		Name:        strings.ToLower(name),
+		Args:        words,
+		User:        form.Get("user_name"),
+		Channel:     form.Get("channel_name"),
+		ResponseURL: form.Get("response_url"),
+	}
+	if !isAction(cmd.Name) {
+		if len(words) == 0 {
+			return cmd, fmt.Errorf("missing action")
+		}
+		cmd.Name = strings.ToLower(words[0])
+		cmd.Args = words[1:]
+	}
+	return cmd, nil
+}
+
+// Actions lists the supported slash command actions
+var Actions = []string{"deploy", "status", "rollback"}
+
+func isAction(name string) bool {
+	for _, action := range Actions {
+		if name == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Response is a slash command reply. Ephemeral replies are only shown to the
+// user who ran the command; in-channel replies are visible to everyone.
+type Response struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// Ephemeral builds a reply only the caller sees
+func Ephemeral(format string, args ...interface{}) Response {
+	return Response{ResponseType: "ephemeral", Text: fmt.Sprintf(format, args...)}
+}
+
+// InChannel builds a reply the whole channel sees
+func InChannel(format string, args ...interface{}) Response {
+	return Response{ResponseType: "in_channel", Text: fmt.Sprintf(format, args...)}
+}
+
+// Reply writes resp as the immediate answer to a slash command
+func Reply(w http.ResponseWriter, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// client posts delayed replies
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// PostResponse sends a delayed reply to a command's response_url, e.g. once
+// a deployment started by the command has finished
+func PostResponse(ctx context.Context, responseURL string, resp Response) error {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
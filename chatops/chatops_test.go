@@ -0,0 +1,98 @@
+package chatops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedHeader(secret string, body []byte, at time.Time) http.Header {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	header := http.Header{}
+	header.Set("X-Slack-Request-Timestamp", timestamp)
+	header.Set("X-Slack-Signature", "v0="+Sign(secret, timestamp, body))
+	return header
+}
+
+func TestVerify_SlackSignature(t *testing.T) {
+	v := Verifier{SigningSecret: "8f742231b10e8888abcd99yyyzzz85a5"}
+	body := []byte("command=%2Fdeploy&text=myapp+main")
+	now := time.Now()
+
+	if err := v.Verify(signedHeader(v.SigningSecret, body, now), body, now); err != nil {
+		t.Errorf("expected a valid signature to pass, got %v", err)
+	}
+	if err := v.Verify(signedHeader("wrong", body, now), body, now); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected a bad signature to fail, got %v", err)
+	}
+	if err := v.Verify(signedHeader(v.SigningSecret, []byte("command=%2Frollback"), now), body, now); err == nil {
+		t.Error("expected a tampered body to fail")
+	}
+	old := now.Add(-10 * time.Minute)
+	if err := v.Verify(signedHeader(v.SigningSecret, body, old), body, now); err == nil {
+		t.Error("expected a replayed request to fail")
+	}
+	if err := v.Verify(http.Header{}, body, now); err == nil {
+		t.Error("expected an unsigned request to fail")
+	}
+}
+
+func TestVerify_Token(t *testing.T) {
+	v := Verifier{Token: "mm-token"}
+	now := time.Now()
+
+	if err := v.Verify(http.Header{}, []byte("token=mm-token&command=%2Fstatus"), now); err != nil {
+		t.Errorf("expected the form token to pass, got %v", err)
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Token mm-token")
+	if err := v.Verify(header, []byte("command=%2Fstatus"), now); err != nil {
+		t.Errorf("expected the Authorization token to pass, got %v", err)
+	}
+	if err := v.Verify(http.Header{}, []byte("token=other"), now); err == nil {
+		t.Error("expected a wrong token to fail")
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	cmd, err := ParseCommand(url.Values{"command": {"/deploy"}, "text": {"myapp  feature/x"}, "user_name": {"alice"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "deploy" || len(cmd.Args) != 2 || cmd.Args[1] != "feature/x" || cmd.User != "alice" {
+		t.Errorf("unexpected command %+v", cmd)
+	}
+
+	cmd, err = ParseCommand(url.Values{"command": {"/binarydeploy"}, "text": {"Rollback myapp"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Name != "rollback" || len(cmd.Args) != 1 || cmd.Args[0] != "myapp" {
+		t.Errorf("unexpected umbrella command %+v", cmd)
+	}
+
+	if _, err := ParseCommand(url.Values{"command": {"/binarydeploy"}}); err == nil {
+		t.Error("expected an umbrella command without an action to fail")
+	}
+}
+
+func TestPostResponse(t *testing.T) {
+	var got Response
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if err := PostResponse(context.Background(), srv.URL, InChannel("Deployed %s", "myapp")); err != nil {
+		t.Fatal(err)
+	}
+	if got.ResponseType != "in_channel" || got.Text != "Deployed myapp" {
+		t.Errorf("unexpected response %+v", got)
+	}
+}
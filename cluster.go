@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"binaryDeploy/monitor"
+)
+
+// peerStatusTimeout bounds the status request to each peer, so one
+// unreachable server doesn't hold up the cluster view
+const peerStatusTimeout = 5 * time.Second
+
+var peerClient = &http.Client{Timeout: peerStatusTimeout}
+
+// clusterMember is one server in /cluster/status
+type clusterMember struct {
+	URL          string                 `json:"url,omitempty"` // Empty for the server answering
+	InstanceName string                 `json:"instance_name,omitempty"`
+	Self         bool                   `json:"self,omitempty"`
+	Reachable    bool                   `json:"reachable"`
+	Error        string                 `json:"error,omitempty"`
+	LatencyMS    int64                  `json:"latency_ms"`
+	Status       map[string]interface{} `json:"status,omitempty"` // The member's /status
+}
+
+// clusterStatusHandler serves GET /cluster/status: the status of this
+// server and of every peer in peer_urls, fetched in parallel
+func clusterStatusHandler(local *monitor.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		peers := appConfig().PeerURLs
+		if len(peers) == 0 {
+			http.Error(w, "Cluster peers are not configured", http.StatusNotFound)
+			return
+		}
+
+		members := make([]clusterMember, len(peers)+1)
+		members[0] = clusterMember{InstanceName: appConfig().InstanceName, Self: true, Reachable: true, Status: local.Status()}
+		var wg sync.WaitGroup
+		for i, peer := range peers {
+			wg.Add(1)
+			go func(member *clusterMember, peer string) {
+				defer wg.Done()
+				*member = fetchPeerStatus(r.Context(), peer)
+			}(&members[i+1], peer)
+		}
+		wg.Wait()
+
+		summary := map[string]int{"members": len(members), "reachable": 0, "unreachable": 0, "running": 0, "updating": 0}
+		for _, m := range members {
+			if !m.Reachable {
+				summary["unreachable"]++
+				continue
+			}
+			summary["reachable"]++
+			if process, ok := m.Status["process"].(map[string]interface{}); ok && process["running"] == true {
+				summary["running"]++
+			}
+			if readOnly, ok := m.Status["read_only"].(map[string]interface{}); ok && readOnly["active"] == true {
+				summary["updating"]++
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"members":   members,
+			"summary":   summary,
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+// fetchPeerStatus reads /status of the binaryDeploy server at baseURL
+func fetchPeerStatus(ctx context.Context, baseURL string) clusterMember {
+	member := clusterMember{URL: baseURL}
+	start := time.Now()
+	status, err := getPeerStatus(ctx, baseURL)
+	member.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		member.Error = err.Error()
+		return member
+	}
+	member.Reachable = true
+	member.Status = status
+	if server, ok := status["server"].(map[string]interface{}); ok {
+		member.InstanceName, _ = server["instance_name"].(string)
+	}
+	return member
+}
+
+func getPeerStatus(ctx context.Context, baseURL string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	if appConfig().PeerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+appConfig().PeerToken)
+	}
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("peer returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var status map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&status); err != nil {
+		return nil, fmt.Errorf("reading peer status: %w", err)
+	}
+	return status, nil
+}
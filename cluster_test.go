@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/monitor"
+	"binaryDeploy/processmanager"
+)
+
+func TestClusterStatusHandler_AggregatesPeers(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" || r.Header.Get("Authorization") != "Bearer peer-test-token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"server": {"instance_name": "eu-west"}, "process": {"running": true}, "read_only": {"active": true}}`)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database is locked", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	currentConfig.Store(&config.DeployConfig{
+		InstanceName: "us-east",
+		PeerURLs:     []string{healthy.URL + "/", failing.URL, gone.URL},
+		PeerToken:    "peer-test-token",
+	})
+	local := monitor.NewHandler(processmanager.NewGroup(1), &monitor.ServerConfig{InstanceName: "us-east"})
+
+	rec := httptest.NewRecorder()
+	clusterStatusHandler(local)(rec, httptest.NewRequest(http.MethodGet, "/cluster/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Members []clusterMember `json:"members"`
+		Summary map[string]int  `json:"summary"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Members) != 4 {
+		t.Fatalf("Expected this server and three peers, got %+v", body.Members)
+	}
+	self, eu, broken, unreachable := body.Members[0], body.Members[1], body.Members[2], body.Members[3]
+	if !self.Self || self.InstanceName != "us-east" || !self.Reachable {
+		t.Errorf("Unexpected local member %+v", self)
+	}
+	if !eu.Reachable || eu.InstanceName != "eu-west" || eu.URL != healthy.URL+"/" {
+		t.Errorf("Expected the healthy peer with its instance name, got %+v", eu)
+	}
+	if broken.Reachable || broken.Error == "" {
+		t.Errorf("Expected a peer answering 500 to be unreachable with its error, got %+v", broken)
+	}
+	if unreachable.Reachable {
+		t.Errorf("Expected a stopped peer to be unreachable, got %+v", unreachable)
+	}
+	want := map[string]int{"members": 4, "reachable": 2, "unreachable": 2, "running": 1, "updating": 1}
+	for key, n := range want {
+		if body.Summary[key] != n {
+			t.Errorf("summary.%s: expected %d, got %d", key, n, body.Summary[key])
+		}
+	}
+}
+
+func TestClusterStatusHandler_WithoutPeers(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{})
+	local := monitor.NewHandler(processmanager.NewGroup(1), &monitor.ServerConfig{})
+	rec := httptest.NewRecorder()
+	clusterStatusHandler(local)(rec, httptest.NewRequest(http.MethodGet, "/cluster/status", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without peer_urls, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"binaryDeploy/portalloc"
+)
+
+// AppsDir holds one <name>.config file per app in multi-app mode, next to
+// deploy.config
+const AppsDir = "deploy.d"
+
+// frontKeys are the deploy.config settings of the multi-app front server
+// itself rather than of the apps behind it
+var frontKeys = append([]string{"binary_port", "listen", "admin_listen", "app_port_base", "port_range"}, tlsKeys...)
+
+// AppsConfig is multi-app mode: several target applications, each deployed
+// by its own binaryDeploy instance behind one front server that routes
+// webhook pushes to them
+type AppsConfig struct {
+	Listen      []string // Public addresses of the front server
+	AdminListen []string // Addresses serving everything but the webhook, if set
+	APIToken    string   // Guards the front server's /apps status endpoint
+	PortBase    int      // First loopback port handed to app instances
+	TLS         TLS      // HTTPS for the front server; the apps behind it serve plain HTTP
+	Apps        []App
+
+	// Front carries the shared webhook_body_timeout, trusted_proxies and
+	// auth_* settings, which the front server applies as well as each app
+	Front *DeployConfig
+
+	// Application ports are allocated to the apps from PortRange when it is
+	// set, instead of each app setting its own port
+	PortRange portalloc.Range
+}
+
+// App is one application of multi-app mode
+type App struct {
+	Name string
+	Port int // Loopback port the app instance listens on
+
+	// The app's deploy.config: every shared deploy.config setting, replaced
+	// by the app's own where it sets them
+	Settings map[string]string
+}
+
+// LoadAppsConfig reads the apps declared in the deploy.config at path as
+// apps.<name>.<key> entries and in dir as <name>.config files. The other
+// deploy.config settings are shared by every app. Apps get consecutive
+// ports from app_port_base in name order. It returns nil if no apps are
+// declared, i.e. binaryDeploy manages a single app.
+func LoadAppsConfig(path, dir string) (*AppsConfig, error) {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := map[string]string{}
+	apps := map[string]map[string]string{}
+	for key, value := range values {
+		rest, ok := strings.CutPrefix(key, "apps.")
+		if !ok {
+			shared[key] = value
+			continue
+		}
+		name, setting, ok := strings.Cut(rest, ".")
+		if !ok || name == "" || setting == "" {
+			return nil, fmt.Errorf("invalid key %q: expected apps.<name>.<setting>", key)
+		}
+		if apps[name] == nil {
+			apps[name] = map[string]string{}
+		}
+		apps[name][setting] = value
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.config"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".config")
+		if apps[name] != nil {
+			return nil, fmt.Errorf("app %s is declared both in %s and as apps.%s entries", name, file, name)
+		}
+		settings, err := readConfigFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		apps[name] = settings
+	}
+	if len(apps) == 0 {
+		return nil, nil
+	}
+
+	config := &AppsConfig{
+		Listen:   []string{":8080"},
+		PortBase: 19200,
+		APIToken: shared["api_token"],
+	}
+	if port, ok := shared["binary_port"]; ok {
+		config.Listen = []string{":" + port}
+	}
+	if listen, ok := shared["listen"]; ok {
+		config.Listen = splitList(listen)
+	}
+	if adminListen, ok := shared["admin_listen"]; ok {
+		config.AdminListen = splitList(adminListen)
+	}
+	if base, ok := shared["app_port_base"]; ok {
+		p, err := strconv.Atoi(base)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("invalid app_port_base %q", base)
+		}
+		config.PortBase = p
+	}
+	if portRange, ok := shared["port_range"]; ok && portRange != "" {
+		if config.PortRange, err = portalloc.ParseRange(portRange); err != nil {
+			return nil, fmt.Errorf("invalid port_range: %w", err)
+		}
+	}
+	config.TLS = parseTLS(shared)
+	config.Front = DefaultDeployConfig()
+	config.Front.parseClientLimits(shared)
+	for _, key := range frontKeys {
+		delete(shared, key)
+	}
+
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		settings := maps.Clone(shared)
+		maps.Copy(settings, apps[name])
+		if settings["app_name"] == "" {
+			settings["app_name"] = name
+		}
+		config.Apps = append(config.Apps, App{Name: name, Port: config.PortBase + i, Settings: settings})
+	}
+	return config, nil
+}
+
+// ValidateAppsConfig checks the front server settings and app names. Each
+// app's own settings are validated as the deploy.config they become.
+func ValidateAppsConfig(config *AppsConfig) error {
+	addrs := append(append([]string{}, config.Listen...), config.AdminListen...)
+	for _, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid listen address %q: expected host:port, e.g. :8080", addr)
+		}
+	}
+	if err := config.TLS.Validate(); err != nil {
+		return err
+	}
+	last := config.PortBase + len(config.Apps) - 1
+	if last > 65535 {
+		return fmt.Errorf("app_port_base %d leaves no room for %d apps", config.PortBase, len(config.Apps))
+	}
+	if config.PortRange.Overlaps(portalloc.Range{First: config.PortBase, Last: last}) {
+		return fmt.Errorf("port_range %s overlaps the app instance ports %d-%d from app_port_base", config.PortRange, config.PortBase, last)
+	}
+	for _, app := range config.Apps {
+		if !tenantNamePattern.MatchString(app.Name) {
+			return fmt.Errorf("invalid app name %q: use lowercase letters, digits and dashes", app.Name)
+		}
+	}
+	return nil
+}
+
+// WriteConfigFile writes the app's settings as the deploy.config of its
+// instance
+func (a App) WriteConfigFile(path string) error {
+	keys := make([]string, 0, len(a.Settings))
+	for key := range a.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by binaryDeploy for app %s from deploy.config and %s; edit those instead\n", a.Name, AppsDir)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", key, a.Settings[key])
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
@@ -3,25 +3,221 @@ package config
 import (
 	"bufio"
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
+
+	"binaryDeploy/authguard"
+	"binaryDeploy/health"
+	"binaryDeploy/kv"
+	"binaryDeploy/notify"
+	"binaryDeploy/portalloc"
+	"binaryDeploy/remote"
+	"binaryDeploy/schedule"
+	"binaryDeploy/secrets"
+	"binaryDeploy/templating"
+)
+
+// Deployment strategies: restart the application in place, or start the new
+// version beside the old one and switch traffic once it is healthy
+const (
+	StrategyRestart   = "restart"
+	StrategyBlueGreen = "blue-green"
 )
 
+// DisableableEndpoints are the endpoints disabled_endpoints can turn off
+var DisableableEndpoints = []string{"deploy", "update-target", "update-self", "exec", "process"}
+
+// resourceNamePattern keeps shared_resources names usable as lock file names
+var resourceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
 // DeployConfig represents the parsed deploy.config file
 type DeployConfig struct {
 	// BinaryDeploy Configuration (optional - all have defaults)
 	Port              string
 	LogFile           string
 	LogBufferSize     int
+	LogBufferMaxAge   int    // Seconds; 0 keeps entries until the size limit evicts them
+	LogMaxSizeMB      int    // Rotate log_file past this size; 0 never rotates
+	LogOutput         string // file, stdout or both
+	LogFormat         string // json or console, for stdout; log_file is always JSON
 	DeployDir         string
 	SelfUpdateDir     string
 	SelfUpdateRepoURL string
+	APIToken          string   // Bearer token for management endpoints
+	WebhookTimeout    int      // Seconds a webhook body may take to arrive
+	NotifyURLs        []string // Outbound notification webhooks receiving every event
+	NotifySecret      string   // Signs notify_urls and pause_webhook_url requests
+
+	// Branch a self_update_repo_url push must be on to trigger a self-update,
+	// empty for the repository's default branch, and tag patterns ("v*")
+	// whose pushes trigger one as well
+	SelfUpdateBranch string
+	SelfUpdateTags   []string
+
+	// Self-update builds kept for pinned rollbacks, the installed one
+	// included
+	SelfUpdateKeep int
+
+	// Re-execute the new binary once a self-update or rollback installs it,
+	// handing over the listening sockets, after draining in-flight requests
+	// and deployments for at most SelfUpdateDrainTimeout seconds
+	SelfUpdateRestart      bool
+	SelfUpdateDrainTimeout int
+
+	// Name telling this server apart from others in /status, logs,
+	// /debug/vars and notifications; the hostname by default
+	InstanceName string
+
+	// Git repository this deploy.config is synced from; empty disables
+	// syncing. ConfigRepoFile is the file within it, ConfigRepoPoll the
+	// seconds between fetches, 0 for webhooks only.
+	ConfigRepoURL    string
+	ConfigRepoBranch string
+	ConfigRepoFile   string
+	ConfigRepoPoll   int
+
+	// Listen addresses; Listen defaults to :<port>. When AdminListen is set,
+	// Listen only serves the webhook endpoints and everything else moves to
+	// AdminListen.
+	Listen      []string
+	AdminListen []string
+
+	// Reverse proxy support
+	BasePath       string   // Path prefix every route is served under, e.g. /binarydeploy
+	TrustedProxies []string // Addresses and CIDR ranges whose X-Forwarded-* headers are believed
+
+	// Deploy when the HEAD of a local (file://) target repository changes
+	WatchLocalRepo bool
+
+	// Deploy builds dropped into a directory, e.g. by scp from CI
+	IncomingDir             string
+	IncomingRequireChecksum bool // Reject artifacts without a .sha256 sidecar
+
+	// Send last month's uptime report to the notifiers on the 1st
+	UptimeReportNotify bool
+
+	// Don't restart the application when artifact_paths hash the same as
+	// the build it runs
+	SkipUnchangedBuilds bool
+
+	// Scratch space for simulations, backups and uploads; defaults to
+	// <deploy_dir>/.tmp
+	TempDir string
+
+	// Credentials binaryDeploy writes itself, such as the git credential
+	// store and the session key; defaults to <deploy_dir>-state
+	StateDir string
+
+	// Exit at startup when a diagnostics check fails instead of logging it
+	DiagnosticsStrict bool
+
+	// Endpoints turned off for locked-down installs, see DisableableEndpoints
+	DisabledEndpoints []string
+
+	// ACME HTTP-01 challenges answered at /.well-known/acme-challenge/
+	ACMEChallengeApp     bool     // Forward challenges to the application on ApplicationPort
+	ACMEChallengeDir     string   // Serve challenges from this webroot instead, e.g. for certbot --webroot
+	ACMEChallengeDomains []string // Hosts challenges are answered for; empty answers any
+
+	// Temporary bans of clients that repeatedly fail authentication
+	AuthFailureThreshold int      // Failures within AuthFailureWindow that trigger a ban; 0 disables
+	AuthFailureWindow    int      // Seconds
+	AuthBanDuration      int      // Seconds
+	AuthBanExempt        []string // Addresses and CIDR ranges never banned
+
+	// Authentication of every endpoint but the webhooks and the / liveness
+	// page, with api_token, basic auth or a login page session
+	RequireAuth    bool
+	AuthUsers      map[string]string // Passwords by user name, from auth_users.<name>
+	SessionTimeout int               // Seconds a login page session lasts
+
+	// Notification providers by name, from notifiers.<name>.<option>; see
+	// notify.NewRoute for the options every provider takes
+	Notifiers map[string]map[string]string
+
+	// Key/value store for history, the deployment queue and other state
+	StorageDriver string // file or sqlite
+	StoragePath   string // Directory (file) or database (sqlite); defaults inside deploy_dir
+
+	// Generic webhook trigger (/webhook/generic)
+	GenericWebhookToken  string // Shared token; the endpoint is disabled when empty
+	GenericWebhookHeader string // Header carrying the token
+	GenericRepoExpr      string // JSONPath/template for the repo URL (defaults to target_repo_url)
+	GenericBranchExpr    string // JSONPath/template for the branch or ref
+	GenericCommitExpr    string // JSONPath/template for the commit SHA
+
+	// Incident escalation to an on-call service
+	IncidentProvider         string // "pagerduty" or "opsgenie"; empty disables
+	PagerDutyRoutingKey      string // Events API v2 integration key
+	OpsgenieAPIKey           string // API integration key
+	OpsgenieAPIURL           string // https://api.eu.opsgenie.com for EU accounts
+	IncidentFailureThreshold int    // Consecutive failed deployments that open an incident
+	IncidentCrashThreshold   int    // Crashes within IncidentCrashWindow that count as a crash loop
+	IncidentCrashWindow      int    // Seconds
+
+	// Issue tracker keys (ABC-123) found in deployed commit messages
+	IssueTrackerURL          string   // Jira base URL issue links point to
+	IssueProjects            []string // Project keys to look for; empty matches any key
+	JiraUser                 string   // Account email for a Jira Cloud API token; empty for a personal access token
+	JiraToken                string   // Enables comments on deployed issues
+	IssueCommentEnvironments []string // Environments whose deployments are commented on
+
+	ReleaseNotes bool // Record and announce the commits each deployment contains
+
+	// Semantic version tags created from conventional commits
+	AutoTag             bool     // Tag successful deployments with the next version
+	AutoTagEnvironments []string // Environments whose deployments are tagged
+	TagPrefix           string   // Prepended to the version, e.g. v1.2.3
+	TagPush             bool     // Push new tags to origin
+	TagTagger           string   // "Name <email>" recorded on the annotated tag
+
+	// Slack/Mattermost slash commands (/chatops); disabled when both are empty
+	ChatOpsSigningSecret string // Slack app signing secret
+	ChatOpsToken         string // Mattermost slash command token
+
+	// TLS trust for git and outbound HTTPS
+	CACerts                 []string // Extra PEM CA certificates
+	InsecureSkipVerifyRepos []string // Repo URLs cloned without TLS verification
+
+	// Credentials for private repositories: an SSH key for SSH URLs, a
+	// token for HTTPS ones on GitTokenHosts (the target repository's host
+	// when empty), or a git credential helper
+	GitSSHKeyFile        string
+	GitSSHKnownHostsFile string
+	GitToken             string
+	GitTokenUser         string
+	GitTokenHosts        []string
+	GitCredentialHelper  string
+
+	// GitHub integration (github.com or GitHub Enterprise Server)
+	GitHubAPIURL   string
+	GitHubToken    string
+	GitHubCABundle string
+	GitHubChecks   bool // Report check runs with build output instead of commit statuses
+
+	// Reporting repeated deployment failures of a branch back on GitHub
+	GitHubFailureThreshold int    // Consecutive failures that are reported; 0 disables
+	GitHubFailureReport    string // "issue" or "comment"
+	GitHubFailureLabels    []string
 
 	// Application Configuration (required)
 	TargetRepoURL   string
 	AllowedBranches string // Comma-separated list
 	Secret          string
+	AppName         string // Defaults to the target repository name
+
+	// Tag patterns (v*) whose pushes and published releases deploy the
+	// tagged commit; tags aren't deployed when empty
+	AllowedTags []string
 
 	// Application Deployment Settings
 	BuildCommand    string
@@ -29,10 +225,161 @@ type DeployConfig struct {
 	WorkingDir      string
 	Environment     string
 	ApplicationPort int // Application port, separate from binary port
+	Instances       int // Copies of run_command to supervise, on consecutive ports
 	RestartDelay    int
 	MaxRestarts     int
 	BackupBinary    string
 	RestartCommand  string
+	RestartSchedule string // Cron expression for periodic graceful restarts
+
+	// How a new version replaces the running one
+	DeploymentStrategy     string // StrategyRestart or StrategyBlueGreen
+	BlueGreenPorts         []int  // Ports of the blue and green releases
+	BlueGreenProxy         bool   // Serve ApplicationPort and proxy it to the live release
+	BlueGreenSwitchCommand string // Points external traffic at a newly started release
+
+	// Ports a multi-app front server or multi-tenant supervisor allocated to
+	// the application from its port_range, replacing port and
+	// blue_green_ports. Not read from deploy.config.
+	AllocatedPorts portalloc.Range
+
+	// CPU and IO scheduling priority of the application
+	Nice    int    // -20 (most favourable) to 19; 0 leaves it unchanged
+	IOClass string // "realtime", "best-effort" or "idle"; empty leaves it unchanged
+	IOLevel int    // 0 (highest) to 7 within the realtime and best-effort classes
+
+	// CPUs the application may run on, e.g. to keep it off cores reserved for
+	// binaryDeploy and system services; empty allows all
+	CPUAffinity []int
+
+	// Resource limits of the application, a number or "unlimited"; empty
+	// keeps the limits binaryDeploy itself runs with
+	ULimitNofile string // Open files
+	ULimitNproc  string // Processes of the app's user
+	ULimitCore   string // Core dump size in MB
+
+	// Automatic rollback to the previous build
+	KeepBuilds     int // Deployed builds kept under <deploy_dir>/builds; 0 disables
+	RollbackWindow int // Seconds a new build must stay up and healthy after starting
+
+	// Maintenance of the target repository checkout
+	GitGCSchedule   string // Cron expression for git gc; empty disables
+	GitGCPrune      string // Loose objects older than this are pruned, e.g. 2.weeks.ago
+	GitGCAggressive bool   // Repack from scratch; slower but smaller
+	GitMirrorDir    string // Bare mirrors shared by instances deploying the same repo; empty disables
+
+	CrashOutputLines int  // Lines of output kept to explain a crash
+	AppLogLines      int  // Lines of application output kept for /apps/{name}/logs/tail
+	CoreDumps        bool // Let the app dump core and keep dumps under <deploy_dir>/cores
+	CoreDumpsKeep    int  // Number of most recent core dumps to retain
+
+	// Application output beyond the in-memory tail
+	AppLogFile   string // Appended to and rotated like log_file
+	AppLogStream bool   // Also publish it on the /logs stream
+
+	// Readiness and warm-up
+	HealthCheckURL     string   // Absolute URL or path on the application port
+	HealthCheckTimeout int      // Seconds to wait for the health check to pass after start
+	WarmupURLs         []string // Requested in order once the application is healthy
+
+	// Wait for the application to send READY=1 to $NOTIFY_SOCKET, as with
+	// systemd's sd_notify, before health checks
+	ReadinessNotify bool
+
+	// Liveness probing of the running application with health_check_url
+	HealthCheckInterval int // Seconds between checks; 0 disables probing
+	HealthCheckFailures int // Consecutive failed checks that restart the application
+
+	// Pause between stopping the old version and starting the new one
+	PauseBeforeStart int    // Fixed delay in seconds
+	PauseWebhookURL  string // Verification webhook that must confirm with 2xx
+	PauseTimeout     int    // Seconds to wait for the verification webhook
+
+	// Start the new version when the verification webhook doesn't confirm
+	// within pause_timeout, instead of failing the deployment
+	PauseStartOnTimeout bool
+
+	// Deployment freeze windows, keyed by environment ("" applies to all)
+	FreezeWindows map[string]string
+	FreezeMode    string // "reject" or "queue"
+
+	// Chained deployments of downstream binaryDeploy instances
+	ChainDeployURLs []string // Base URLs deployed in order after this app
+	ChainOn         string   // "success" or "always"
+	ChainToken      string   // Bearer token sent to downstream instances
+
+	// Other binaryDeploy servers whose status /cluster/status aggregates
+	PeerURLs  []string // Base URLs
+	PeerToken string   // Bearer token sent to peers
+
+	ProfileDeployments bool // Time each pipeline step and record it in history
+
+	PostmortemKeep int // Post-mortem bundles of failed deployments to keep; 0 disables
+
+	// Redaction of logs and captured application output, in addition to
+	// the secrets in this file
+	RedactKeywords     []string          // Values assigned to these names, e.g. password=...
+	RedactRules        map[string]string // redact_rules.<name>=<regexp>
+	RedactBuiltinRules bool              // AWS keys, GitHub tokens, private keys, bearer tokens
+
+	// Warn when a deployment is much slower than recent ones
+	DurationAlertFactor float64 // Multiple of the median duration; 0 disables
+	DurationAlertWindow int     // Successful deployments the median is taken over
+
+	// External services the application depends on, shown next to its status
+	DependencyChecks        map[string]string // dependency_checks.<name>=<url>
+	DependencyCheckInterval int               // Seconds between dependency checks
+	CriticalDependencies    []string          // Dependencies that must be healthy to deploy
+	DependencyGateMode      string            // "reject" or "wait" while a critical dependency is down
+	DependencyGateTimeout   int               // Seconds to wait in "wait" mode before giving up
+
+	// Named resources the application shares with other apps, e.g. a
+	// database; only one deployment touching a resource runs at a time
+	// across every binaryDeploy instance using the same resource_lock_dir
+	SharedResources     []string
+	ResourceLockDir     string
+	ResourceLockTimeout int // Seconds a deployment waits for a resource; 0 waits indefinitely
+
+	// Configuration files rendered into the release on every deployment
+	ConfigTemplates  []string          // SOURCE:DEST pairs relative to working_dir
+	TemplateVars     map[string]string // template_vars.<name> plus template_vars_file
+	TemplateVarsFile string            // key=value file kept outside the repo for secrets
+
+	// Built artifacts stored by commit for rollbacks without rebuilding
+	ArtifactPaths []string // Build outputs relative to working_dir
+	ArtifactStore string   // Local directory or s3://bucket/prefix
+
+	// S3-compatible object storage
+	S3Endpoint  string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+
+	// Off-host copies of history, data backups and rotated logs
+	OffsiteStore    string   // s3://bucket/prefix or a directory (e.g. a network mount)
+	OffsiteInterval int      // Seconds between uploads
+	BackupPaths     []string // Files and directories archived on every upload
+
+	// Deployment driver: "local" runs the app here, "ssh" pushes it to
+	// remote hosts, "sftp" uploads publish_dir to shared hosting
+	DeployDriver      string
+	SSHHosts          []string // [user@]host[:port], deployed in order
+	SSHKeyFile        string
+	SSHKnownHostsFile string
+	SSHRemoteDir      string
+	SSHSyncPaths      []string // Relative to working_dir; everything but .git when empty
+	SSHRestartCommand string   // Run in ssh_remote_dir after each sync
+	PublishDir        string   // Build output uploaded by the sftp driver, relative to working_dir
+
+	// Resource limits; replaced by the supervisor's in multi-tenant mode
+	Limits Limits
+
+	// HTTPS for the listeners; off in multi-tenant instances, which sit
+	// behind the supervisor
+	TLS TLS
+
+	// decrypted holds every value that was stored encrypted
+	decrypted []string
 }
 
 // DefaultDeployConfig returns a config with sensible defaults
@@ -42,9 +389,36 @@ func DefaultDeployConfig() *DeployConfig {
 		Port:              "8080",
 		LogFile:           "./binaryDeploy.log",
 		LogBufferSize:     1000,
+		LogOutput:         "file",
+		LogFormat:         "json",
 		DeployDir:         "./deployments",
+		StorageDriver:     "file",
 		SelfUpdateDir:     "./self-update",
 		SelfUpdateRepoURL: "https://github.com/ahauter/binaryDeploy-updater.git",
+		ConfigRepoBranch:  "main",
+		ConfigRepoFile:    "deploy.config",
+		ConfigRepoPoll:    60,
+		GitHubAPIURL:      "https://api.github.com",
+		WebhookTimeout:    10,
+
+		AuthFailureThreshold: 10,
+		AuthFailureWindow:    300,
+		AuthBanDuration:      900,
+
+		AuthUsers:      map[string]string{},
+		SessionTimeout: 43200,
+
+		SelfUpdateKeep: 3,
+
+		SelfUpdateRestart:      true,
+		SelfUpdateDrainTimeout: 30,
+
+		InstanceName: defaultInstanceName(),
+
+		GitTokenUser: "x-access-token",
+
+		GenericWebhookHeader: "X-Webhook-Token",
+		GenericBranchExpr:    "$.ref",
 
 		// Application Configuration defaults
 		AllowedBranches: "main",
@@ -52,8 +426,71 @@ func DefaultDeployConfig() *DeployConfig {
 		// Application Deployment Settings defaults
 		WorkingDir:      "./",
 		ApplicationPort: 8080,
+		Instances:       1,
 		RestartDelay:    5,
 		MaxRestarts:     3,
+
+		DeploymentStrategy: StrategyRestart,
+		BlueGreenProxy:     true,
+		RollbackWindow:     60,
+		IOLevel:            4,
+
+		CrashOutputLines: 20,
+		AppLogLines:      5000,
+		CoreDumpsKeep:    3,
+
+		GitGCSchedule: "0 4 * * 0",
+		GitGCPrune:    "2.weeks.ago",
+
+		HealthCheckTimeout: 30,
+		PauseTimeout:       300,
+
+		HealthCheckFailures: 3,
+
+		Notifiers:     map[string]map[string]string{},
+		FreezeWindows: map[string]string{},
+		FreezeMode:    "reject",
+
+		ChainOn: "success",
+
+		PostmortemKeep: 10,
+
+		RedactRules:        map[string]string{},
+		RedactBuiltinRules: true,
+
+		DurationAlertFactor: 2,
+		DurationAlertWindow: 20,
+
+		DependencyChecks:        map[string]string{},
+		DependencyCheckInterval: 30,
+		DependencyGateMode:      "reject",
+		DependencyGateTimeout:   300,
+
+		ResourceLockDir:     filepath.Join(os.TempDir(), "binaryDeploy-resources"),
+		ResourceLockTimeout: 3600,
+
+		IncidentFailureThreshold: 3,
+		GitHubFailureReport:      "issue",
+		IncidentCrashThreshold:   5,
+		IncidentCrashWindow:      600,
+
+		IssueCommentEnvironments: []string{"production"},
+
+		ReleaseNotes: true,
+
+		AutoTagEnvironments: []string{"production"},
+		TagPrefix:           "v",
+		TagPush:             true,
+		TagTagger:           "binaryDeploy <binarydeploy@localhost>",
+
+		TemplateVars: map[string]string{},
+
+		OffsiteInterval: 3600,
+
+		DeployDriver: "local",
+		PublishDir:   ".",
+
+		TLS: defaultTLS(),
 	}
 }
 
@@ -66,6 +503,15 @@ func LoadDeployConfig(path string) (*DeployConfig, error) {
 
 	config := DefaultDeployConfig()
 
+	// Values stored as enc:v1:... are decrypted with the key from the environment
+	key, err := secrets.LoadKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.decryptValues(values, key); err != nil {
+		return nil, err
+	}
+
 	// Parse required fields
 	if buildCmd, ok := values["build_command"]; ok {
 		config.BuildCommand = buildCmd
@@ -73,9 +519,10 @@ func LoadDeployConfig(path string) (*DeployConfig, error) {
 		return nil, fmt.Errorf("missing required field: build_command")
 	}
 
+	// Remote hosts start the app with ssh_restart_command instead
 	if runCmd, ok := values["run_command"]; ok {
 		config.RunCommand = runCmd
-	} else {
+	} else if driver := values["deploy_driver"]; driver != "ssh" && driver != "sftp" {
 		return nil, fmt.Errorf("missing required field: run_command")
 	}
 
@@ -94,11 +541,103 @@ func LoadDeployConfig(path string) (*DeployConfig, error) {
 		}
 	}
 
+	if instances, ok := values["instances"]; ok {
+		if n, err := strconv.Atoi(instances); err == nil && n > 0 {
+			config.Instances = n
+		}
+	}
+
+	if strategy, ok := values["deployment_strategy"]; ok {
+		config.DeploymentStrategy = strategy
+	}
+
+	if ports, ok := values["blue_green_ports"]; ok {
+		config.BlueGreenPorts = nil
+		for _, port := range splitList(ports) {
+			p, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fmt.Errorf("invalid blue_green_ports %q: expected two port numbers", ports)
+			}
+			config.BlueGreenPorts = append(config.BlueGreenPorts, p)
+		}
+	} else {
+		config.BlueGreenPorts = []int{config.ApplicationPort + 1, config.ApplicationPort + 2}
+	}
+
+	if proxy, ok := values["blue_green_proxy"]; ok {
+		config.BlueGreenProxy = proxy != "false"
+	}
+
+	if command, ok := values["blue_green_switch_command"]; ok {
+		config.BlueGreenSwitchCommand = command
+	}
+
+	if nice, ok := values["nice"]; ok {
+		n, err := strconv.Atoi(nice)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nice %q: expected a number from -20 to 19", nice)
+		}
+		config.Nice = n
+	}
+
+	if class, ok := values["ionice_class"]; ok {
+		config.IOClass = class
+	}
+
+	if level, ok := values["ionice_level"]; ok {
+		n, err := strconv.Atoi(level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ionice_level %q: expected a number from 0 to 7", level)
+		}
+		config.IOLevel = n
+	}
+
+	if cpus, ok := values["cpu_affinity"]; ok {
+		if config.CPUAffinity, err = parseCPUList(cpus); err != nil {
+			return nil, fmt.Errorf("invalid cpu_affinity %q: %w", cpus, err)
+		}
+	}
+
+	for key, dest := range map[string]*string{
+		"ulimit_nofile": &config.ULimitNofile,
+		"ulimit_nproc":  &config.ULimitNproc,
+		"ulimit_core":   &config.ULimitCore,
+	} {
+		if limit, ok := values[key]; ok {
+			if _, err := strconv.ParseUint(limit, 10, 64); err != nil && limit != "unlimited" {
+				return nil, fmt.Errorf("invalid %s %q: expected a number or unlimited", key, limit)
+			}
+			*dest = limit
+		}
+	}
+
+	if keep, ok := values["keep_builds"]; ok {
+		if n, err := strconv.Atoi(keep); err == nil && n >= 0 {
+			config.KeepBuilds = n
+		}
+	}
+
+	if window, ok := values["rollback_window"]; ok {
+		if n, err := strconv.Atoi(window); err == nil && n >= 0 {
+			config.RollbackWindow = n
+		}
+	}
+
+	if config.Limits, err = parseLimits(values); err != nil {
+		return nil, err
+	}
+
+	config.TLS = parseTLS(values)
+
 	// Handle binary port separately if specified
 	if binaryPort, ok := values["binary_port"]; ok {
 		config.Port = binaryPort
 	}
 
+	if instanceName, ok := values["instance_name"]; ok && instanceName != "" {
+		config.InstanceName = instanceName
+	}
+
 	if restartDelay, ok := values["restart_delay"]; ok {
 		if r, err := strconv.Atoi(restartDelay); err == nil {
 			config.RestartDelay = r
@@ -111,6 +650,36 @@ func LoadDeployConfig(path string) (*DeployConfig, error) {
 		}
 	}
 
+	if lines, ok := values["crash_output_lines"]; ok {
+		if n, err := strconv.Atoi(lines); err == nil && n >= 0 {
+			config.CrashOutputLines = n
+		}
+	}
+
+	if lines, ok := values["app_log_lines"]; ok {
+		if n, err := strconv.Atoi(lines); err == nil && n > 0 {
+			config.AppLogLines = n
+		}
+	}
+
+	if appLogFile, ok := values["app_log_file"]; ok {
+		config.AppLogFile = appLogFile
+	}
+
+	if appLogStream, ok := values["app_log_stream"]; ok {
+		config.AppLogStream = appLogStream == "true"
+	}
+
+	if coreDumps, ok := values["core_dumps"]; ok {
+		config.CoreDumps = coreDumps == "true"
+	}
+
+	if keep, ok := values["core_dumps_keep"]; ok {
+		if n, err := strconv.Atoi(keep); err == nil && n > 0 {
+			config.CoreDumpsKeep = n
+		}
+	}
+
 	// Self-update specific fields
 	if backupBinary, ok := values["backup_binary"]; ok {
 		config.BackupBinary = backupBinary
@@ -120,99 +689,1159 @@ func LoadDeployConfig(path string) (*DeployConfig, error) {
 		config.RestartCommand = restartCmd
 	}
 
-	// Parse binary configuration fields
-	if logFile, ok := values["log_file"]; ok {
-		config.LogFile = logFile
+	if restartSchedule, ok := values["restart_schedule"]; ok {
+		config.RestartSchedule = restartSchedule
 	}
 
-	if logBufferSize, ok := values["log_buffer_size"]; ok {
-		if size, err := strconv.Atoi(logBufferSize); err == nil && size > 0 {
-			config.LogBufferSize = size
+	if gcSchedule, ok := values["git_gc_schedule"]; ok {
+		config.GitGCSchedule = gcSchedule
+	}
+
+	if gcPrune, ok := values["git_gc_prune"]; ok && gcPrune != "" {
+		config.GitGCPrune = gcPrune
+	}
+
+	if gcAggressive, ok := values["git_gc_aggressive"]; ok {
+		config.GitGCAggressive = gcAggressive == "true"
+	}
+
+	if mirrorDir, ok := values["git_mirror_dir"]; ok {
+		config.GitMirrorDir = mirrorDir
+	}
+
+	if healthCheckURL, ok := values["health_check_url"]; ok {
+		config.HealthCheckURL = healthCheckURL
+	}
+
+	if healthCheckTimeout, ok := values["health_check_timeout"]; ok {
+		if t, err := strconv.Atoi(healthCheckTimeout); err == nil && t > 0 {
+			config.HealthCheckTimeout = t
 		}
 	}
 
-	if deployDir, ok := values["deploy_dir"]; ok {
-		config.DeployDir = deployDir
+	if notify, ok := values["readiness_notify"]; ok {
+		config.ReadinessNotify = notify == "true"
 	}
 
-	if selfUpdateDir, ok := values["self_update_dir"]; ok {
-		config.SelfUpdateDir = selfUpdateDir
+	if interval, ok := values["health_check_interval"]; ok {
+		if n, err := strconv.Atoi(interval); err == nil && n >= 0 {
+			config.HealthCheckInterval = n
+		}
 	}
 
-	if selfUpdateRepoURL, ok := values["self_update_repo_url"]; ok {
-		config.SelfUpdateRepoURL = selfUpdateRepoURL
+	if failures, ok := values["health_check_failures"]; ok {
+		if n, err := strconv.Atoi(failures); err == nil && n > 0 {
+			config.HealthCheckFailures = n
+		}
 	}
 
-	// Parse application configuration fields (required)
-	if targetRepoURL, ok := values["target_repo_url"]; ok {
-		config.TargetRepoURL = targetRepoURL
-	} else {
-		return nil, fmt.Errorf("missing required field: target_repo_url")
+	if warmupURLs, ok := values["warmup_urls"]; ok {
+		config.WarmupURLs = splitList(warmupURLs)
 	}
 
-	if allowedBranches, ok := values["allowed_branches"]; ok {
-		config.AllowedBranches = allowedBranches
-	} else {
-		return nil, fmt.Errorf("missing required field: allowed_branches")
+	if pause, ok := values["pause_before_start"]; ok {
+		if p, err := strconv.Atoi(pause); err == nil && p >= 0 {
+			config.PauseBeforeStart = p
+		}
 	}
 
-	if secret, ok := values["secret"]; ok {
-		config.Secret = secret
-	} else {
-		return nil, fmt.Errorf("missing required field: secret")
+	if pauseWebhookURL, ok := values["pause_webhook_url"]; ok {
+		config.PauseWebhookURL = pauseWebhookURL
 	}
 
-	return config, nil
-}
+	if pauseTimeout, ok := values["pause_timeout"]; ok {
+		if t, err := strconv.Atoi(pauseTimeout); err == nil && t > 0 {
+			config.PauseTimeout = t
+		}
+	}
 
-// ValidateConfig validates the configuration and returns warnings for used defaults
-func ValidateConfig(config *DeployConfig) error {
-	// Check all required fields
-	if config.TargetRepoURL == "" {
-		return fmt.Errorf("missing required field: target_repo_url")
+	if startOnTimeout, ok := values["pause_start_on_timeout"]; ok {
+		config.PauseStartOnTimeout = startOnTimeout == "true"
 	}
-	if config.AllowedBranches == "" {
-		return fmt.Errorf("missing required field: allowed_branches")
+
+	// freeze_windows applies to every environment, freeze_windows.<env> to one
+	for key, value := range values {
+		if key == "freeze_windows" {
+			config.FreezeWindows[""] = value
+		} else if env, ok := strings.CutPrefix(key, "freeze_windows."); ok && env != "" {
+			config.FreezeWindows[env] = value
+		}
 	}
-	if config.Secret == "" {
-		return fmt.Errorf("missing required field: secret")
+
+	if freezeMode, ok := values["freeze_mode"]; ok {
+		config.FreezeMode = freezeMode
 	}
-	if config.BuildCommand == "" {
-		return fmt.Errorf("missing required field: build_command")
+
+	if chainURLs, ok := values["chain_deploy_urls"]; ok {
+		config.ChainDeployURLs = splitList(chainURLs)
 	}
-	if config.RunCommand == "" {
-		return fmt.Errorf("missing required field: run_command")
+
+	if chainOn, ok := values["chain_on"]; ok && chainOn != "" {
+		config.ChainOn = chainOn
 	}
 
-	return nil
-}
+	if chainToken, ok := values["chain_token"]; ok {
+		config.ChainToken = chainToken
+	}
 
-// GetDefaultWarnings returns warnings for any default values being used
-func GetDefaultWarnings(config *DeployConfig) []string {
-	var warnings []string
+	if peerURLs, ok := values["peer_urls"]; ok {
+		config.PeerURLs = splitList(peerURLs)
+	}
 
-	defaults := DefaultDeployConfig()
+	if peerToken, ok := values["peer_token"]; ok {
+		config.PeerToken = peerToken
+	}
 
-	if config.Port == defaults.Port {
-		warnings = append(warnings, "Using default binary port 8080 (add 'port=8080' to deploy.config to customize)")
+	if profile, ok := values["profile_deployments"]; ok {
+		config.ProfileDeployments = profile == "true"
 	}
-	if config.LogFile == defaults.LogFile {
-		warnings = append(warnings, "Using default log file ./binaryDeploy.log (add 'log_file=...' to deploy.config to customize)")
+
+	if keep, ok := values["postmortem_keep"]; ok {
+		if n, err := strconv.Atoi(keep); err == nil && n >= 0 {
+			config.PostmortemKeep = n
+		}
 	}
-	if config.DeployDir == defaults.DeployDir {
-		warnings = append(warnings, "Using default deploy directory ./deployments (add 'deploy_dir=...' to deploy.config to customize)")
+
+	if factor, ok := values["duration_alert_factor"]; ok {
+		if f, err := strconv.ParseFloat(factor, 64); err == nil && (f == 0 || f > 1) {
+			config.DurationAlertFactor = f
+		}
 	}
-	if config.SelfUpdateDir == defaults.SelfUpdateDir {
-		warnings = append(warnings, "Using default self-update directory ./self-update (add 'self_update_dir=...' to deploy.config to customize)")
+
+	if window, ok := values["duration_alert_window"]; ok {
+		if n, err := strconv.Atoi(window); err == nil && n > 0 {
+			config.DurationAlertWindow = n
+		}
 	}
-	if config.SelfUpdateRepoURL == defaults.SelfUpdateRepoURL {
-		warnings = append(warnings, "Using default self-update repository (add 'self_update_repo_url=...' to deploy.config to customize)")
+
+	for key, value := range values {
+		if name, ok := strings.CutPrefix(key, "dependency_checks."); ok && name != "" {
+			config.DependencyChecks[name] = value
+		}
 	}
-	if config.AllowedBranches == defaults.AllowedBranches {
-		warnings = append(warnings, "Using default allowed branches 'main' (add 'allowed_branches=...' to deploy.config to customize)")
+
+	if interval, ok := values["dependency_check_interval"]; ok {
+		if n, err := strconv.Atoi(interval); err == nil && n > 0 {
+			config.DependencyCheckInterval = n
+		}
 	}
 
-	return warnings
+	if critical, ok := values["critical_dependencies"]; ok {
+		config.CriticalDependencies = splitList(critical)
+	}
+
+	if mode, ok := values["dependency_gate_mode"]; ok {
+		config.DependencyGateMode = mode
+	}
+
+	if timeout, ok := values["dependency_gate_timeout"]; ok {
+		if n, err := strconv.Atoi(timeout); err == nil && n > 0 {
+			config.DependencyGateTimeout = n
+		}
+	}
+
+	if resources, ok := values["shared_resources"]; ok {
+		config.SharedResources = splitList(resources)
+	}
+
+	if lockDir, ok := values["resource_lock_dir"]; ok {
+		config.ResourceLockDir = lockDir
+	}
+
+	if timeout, ok := values["resource_lock_timeout"]; ok {
+		if n, err := strconv.Atoi(timeout); err == nil && n >= 0 {
+			config.ResourceLockTimeout = n
+		}
+	}
+
+	if keywords, ok := values["redact_keywords"]; ok {
+		config.RedactKeywords = splitList(keywords)
+	}
+
+	if builtin, ok := values["redact_builtin_rules"]; ok {
+		config.RedactBuiltinRules = builtin != "false"
+	}
+
+	for key, value := range values {
+		if name, ok := strings.CutPrefix(key, "redact_rules."); ok && name != "" {
+			config.RedactRules[name] = value
+		}
+	}
+
+	if templates, ok := values["config_templates"]; ok {
+		config.ConfigTemplates = splitList(templates)
+	}
+
+	// Variables from template_vars_file are overridden by template_vars.<name>
+	if varsFile, ok := values["template_vars_file"]; ok && varsFile != "" {
+		config.TemplateVarsFile = varsFile
+		fileVars, err := readConfigFile(varsFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading template_vars_file: %w", err)
+		}
+		if err := config.decryptValues(fileVars, key); err != nil {
+			return nil, fmt.Errorf("template_vars_file: %w", err)
+		}
+		for name, value := range fileVars {
+			config.TemplateVars[name] = value
+		}
+	}
+	for key, value := range values {
+		if name, ok := strings.CutPrefix(key, "template_vars."); ok && name != "" {
+			config.TemplateVars[name] = value
+		}
+	}
+
+	if artifactPaths, ok := values["artifact_paths"]; ok {
+		config.ArtifactPaths = splitList(artifactPaths)
+	}
+
+	if artifactStore, ok := values["artifact_store"]; ok {
+		config.ArtifactStore = artifactStore
+	}
+
+	if endpoint, ok := values["s3_endpoint"]; ok {
+		config.S3Endpoint = endpoint
+	}
+
+	if region, ok := values["s3_region"]; ok {
+		config.S3Region = region
+	}
+
+	if accessKey, ok := values["s3_access_key"]; ok {
+		config.S3AccessKey = accessKey
+	}
+
+	if secretKey, ok := values["s3_secret_key"]; ok {
+		config.S3SecretKey = secretKey
+	}
+
+	if offsiteStore, ok := values["offsite_store"]; ok {
+		config.OffsiteStore = offsiteStore
+	}
+
+	if interval, ok := values["offsite_interval"]; ok {
+		if i, err := strconv.Atoi(interval); err == nil && i > 0 {
+			config.OffsiteInterval = i
+		}
+	}
+
+	if backupPaths, ok := values["backup_paths"]; ok {
+		config.BackupPaths = splitList(backupPaths)
+	}
+
+	if driver, ok := values["deploy_driver"]; ok && driver != "" {
+		config.DeployDriver = driver
+	}
+
+	if hosts, ok := values["ssh_hosts"]; ok {
+		config.SSHHosts = splitList(hosts)
+	}
+
+	if keyFile, ok := values["ssh_key_file"]; ok {
+		config.SSHKeyFile = keyFile
+	}
+
+	if knownHosts, ok := values["ssh_known_hosts_file"]; ok {
+		config.SSHKnownHostsFile = knownHosts
+	}
+
+	if remoteDir, ok := values["ssh_remote_dir"]; ok {
+		config.SSHRemoteDir = remoteDir
+	}
+
+	if syncPaths, ok := values["ssh_sync_paths"]; ok {
+		config.SSHSyncPaths = splitList(syncPaths)
+	}
+
+	if restartCmd, ok := values["ssh_restart_command"]; ok {
+		config.SSHRestartCommand = restartCmd
+	}
+
+	if publishDir, ok := values["publish_dir"]; ok && publishDir != "" {
+		config.PublishDir = publishDir
+	}
+
+	// Parse binary configuration fields
+	if logFile, ok := values["log_file"]; ok {
+		config.LogFile = logFile
+	}
+
+	if logBufferSize, ok := values["log_buffer_size"]; ok {
+		if size, err := strconv.Atoi(logBufferSize); err == nil && size > 0 {
+			config.LogBufferSize = size
+		}
+	}
+
+	if maxAge, ok := values["log_buffer_max_age"]; ok {
+		if age, err := strconv.Atoi(maxAge); err == nil && age >= 0 {
+			config.LogBufferMaxAge = age
+		}
+	}
+
+	if maxSize, ok := values["log_max_size_mb"]; ok {
+		if size, err := strconv.Atoi(maxSize); err == nil && size >= 0 {
+			config.LogMaxSizeMB = size
+		}
+	}
+
+	if output, ok := values["log_output"]; ok {
+		config.LogOutput = output
+	}
+
+	if format, ok := values["log_format"]; ok {
+		config.LogFormat = format
+	}
+
+	if deployDir, ok := values["deploy_dir"]; ok {
+		config.DeployDir = deployDir
+	}
+
+	if driver, ok := values["storage_driver"]; ok {
+		config.StorageDriver = driver
+	}
+
+	if storagePath, ok := values["storage_path"]; ok {
+		config.StoragePath = storagePath
+	}
+
+	if selfUpdateDir, ok := values["self_update_dir"]; ok {
+		config.SelfUpdateDir = selfUpdateDir
+	}
+
+	if selfUpdateRepoURL, ok := values["self_update_repo_url"]; ok {
+		config.SelfUpdateRepoURL = selfUpdateRepoURL
+	}
+
+	if branch, ok := values["self_update_branch"]; ok {
+		config.SelfUpdateBranch = branch
+	}
+
+	if tags, ok := values["self_update_tags"]; ok {
+		config.SelfUpdateTags = splitList(tags)
+	}
+
+	if keep, ok := values["self_update_keep"]; ok {
+		if n, err := strconv.Atoi(keep); err == nil && n > 0 {
+			config.SelfUpdateKeep = n
+		}
+	}
+
+	if restart, ok := values["self_update_restart"]; ok {
+		config.SelfUpdateRestart = restart != "false"
+	}
+
+	if timeout, ok := values["self_update_drain_timeout"]; ok {
+		if n, err := strconv.Atoi(timeout); err == nil && n > 0 {
+			config.SelfUpdateDrainTimeout = n
+		}
+	}
+
+	if repoURL, ok := values["config_repo_url"]; ok {
+		config.ConfigRepoURL = repoURL
+	}
+
+	if branch, ok := values["config_repo_branch"]; ok {
+		config.ConfigRepoBranch = branch
+	}
+
+	if file, ok := values["config_repo_file"]; ok {
+		config.ConfigRepoFile = file
+	}
+
+	if poll, ok := values["config_repo_poll"]; ok {
+		if p, err := strconv.Atoi(poll); err == nil && p >= 0 {
+			config.ConfigRepoPoll = p
+		}
+	}
+
+	if apiToken, ok := values["api_token"]; ok {
+		config.APIToken = apiToken
+	}
+
+	if notifyURLs, ok := values["notify_urls"]; ok {
+		config.NotifyURLs = splitList(notifyURLs)
+	}
+	if notifySecret, ok := values["notify_secret"]; ok {
+		config.NotifySecret = notifySecret
+	}
+
+	for key, value := range values {
+		if rest, ok := strings.CutPrefix(key, "notifiers."); ok {
+			if name, option, ok := strings.Cut(rest, "."); ok && name != "" && option != "" {
+				if config.Notifiers[name] == nil {
+					config.Notifiers[name] = map[string]string{}
+				}
+				config.Notifiers[name][option] = value
+			}
+		}
+	}
+
+	config.parseClientLimits(values)
+
+	if listen, ok := values["listen"]; ok {
+		config.Listen = splitList(listen)
+	}
+
+	if adminListen, ok := values["admin_listen"]; ok {
+		config.AdminListen = splitList(adminListen)
+	}
+
+	if basePath, ok := values["base_path"]; ok {
+		config.BasePath = strings.TrimRight(basePath, "/")
+		if config.BasePath != "" && !strings.HasPrefix(config.BasePath, "/") {
+			config.BasePath = "/" + config.BasePath
+		}
+	}
+
+	if watch, ok := values["watch_local_repo"]; ok {
+		config.WatchLocalRepo = watch == "true"
+	}
+
+	if dir, ok := values["incoming_dir"]; ok {
+		config.IncomingDir = dir
+	}
+
+	if required, ok := values["incoming_require_checksum"]; ok {
+		config.IncomingRequireChecksum = required == "true"
+	}
+
+	if notifyReport, ok := values["uptime_report_notify"]; ok {
+		config.UptimeReportNotify = notifyReport == "true"
+	}
+
+	if skip, ok := values["skip_unchanged_builds"]; ok {
+		config.SkipUnchangedBuilds = skip == "true"
+	}
+
+	if tempDir, ok := values["temp_dir"]; ok {
+		config.TempDir = tempDir
+	}
+
+	if stateDir, ok := values["state_dir"]; ok {
+		config.StateDir = stateDir
+	}
+
+	if strict, ok := values["diagnostics_strict"]; ok {
+		config.DiagnosticsStrict = strict == "true"
+	}
+
+	if disabled, ok := values["disabled_endpoints"]; ok {
+		config.DisabledEndpoints = splitList(disabled)
+	}
+
+	if app, ok := values["acme_challenge_app"]; ok {
+		config.ACMEChallengeApp = app == "true"
+	}
+
+	if dir, ok := values["acme_challenge_dir"]; ok {
+		config.ACMEChallengeDir = dir
+	}
+
+	if domains, ok := values["acme_challenge_domains"]; ok {
+		config.ACMEChallengeDomains = splitList(domains)
+	}
+
+	if require, ok := values["require_auth"]; ok {
+		config.RequireAuth = require == "true"
+	}
+
+	for key, value := range values {
+		if name, ok := strings.CutPrefix(key, "auth_users."); ok && name != "" {
+			config.AuthUsers[name] = value
+		}
+	}
+
+	if timeout, ok := values["session_timeout"]; ok {
+		if n, err := strconv.Atoi(timeout); err == nil && n > 0 {
+			config.SessionTimeout = n
+		}
+	}
+
+	if token, ok := values["generic_webhook_token"]; ok {
+		config.GenericWebhookToken = token
+	}
+
+	if provider, ok := values["incident_provider"]; ok {
+		config.IncidentProvider = provider
+	}
+
+	if key, ok := values["pagerduty_routing_key"]; ok {
+		config.PagerDutyRoutingKey = key
+	}
+
+	if key, ok := values["opsgenie_api_key"]; ok {
+		config.OpsgenieAPIKey = key
+	}
+
+	if apiURL, ok := values["opsgenie_api_url"]; ok {
+		config.OpsgenieAPIURL = strings.TrimRight(apiURL, "/")
+	}
+
+	if threshold, ok := values["incident_failure_threshold"]; ok {
+		if n, err := strconv.Atoi(threshold); err == nil && n > 0 {
+			config.IncidentFailureThreshold = n
+		}
+	}
+
+	if threshold, ok := values["incident_crash_threshold"]; ok {
+		if n, err := strconv.Atoi(threshold); err == nil && n > 0 {
+			config.IncidentCrashThreshold = n
+		}
+	}
+
+	if window, ok := values["incident_crash_window"]; ok {
+		if n, err := strconv.Atoi(window); err == nil && n > 0 {
+			config.IncidentCrashWindow = n
+		}
+	}
+
+	if trackerURL, ok := values["issue_tracker_url"]; ok {
+		config.IssueTrackerURL = strings.TrimRight(trackerURL, "/")
+	}
+
+	if projects, ok := values["issue_projects"]; ok {
+		config.IssueProjects = splitList(projects)
+	}
+
+	if user, ok := values["jira_user"]; ok {
+		config.JiraUser = user
+	}
+
+	if token, ok := values["jira_token"]; ok {
+		config.JiraToken = token
+	}
+
+	if envs, ok := values["issue_comment_environments"]; ok {
+		config.IssueCommentEnvironments = splitList(envs)
+	}
+
+	if notes, ok := values["release_notes"]; ok {
+		config.ReleaseNotes = notes != "false"
+	}
+
+	if autoTag, ok := values["auto_tag"]; ok {
+		config.AutoTag = autoTag == "true"
+	}
+
+	if envs, ok := values["auto_tag_environments"]; ok {
+		config.AutoTagEnvironments = splitList(envs)
+	}
+
+	if prefix, ok := values["tag_prefix"]; ok {
+		config.TagPrefix = prefix
+	}
+
+	if push, ok := values["tag_push"]; ok {
+		config.TagPush = push != "false"
+	}
+
+	if tagger, ok := values["tag_tagger"]; ok && tagger != "" {
+		config.TagTagger = tagger
+	}
+
+	if secret, ok := values["chatops_signing_secret"]; ok {
+		config.ChatOpsSigningSecret = secret
+	}
+
+	if token, ok := values["chatops_token"]; ok {
+		config.ChatOpsToken = token
+	}
+
+	if header, ok := values["generic_webhook_header"]; ok && header != "" {
+		config.GenericWebhookHeader = header
+	}
+
+	if expr, ok := values["generic_repo_expr"]; ok {
+		config.GenericRepoExpr = expr
+	}
+
+	if expr, ok := values["generic_branch_expr"]; ok && expr != "" {
+		config.GenericBranchExpr = expr
+	}
+
+	if expr, ok := values["generic_commit_expr"]; ok {
+		config.GenericCommitExpr = expr
+	}
+
+	if caCerts, ok := values["ca_certs"]; ok {
+		config.CACerts = splitList(caCerts)
+	}
+
+	if insecureRepos, ok := values["insecure_skip_verify_repos"]; ok {
+		config.InsecureSkipVerifyRepos = splitList(insecureRepos)
+	}
+
+	if keyFile, ok := values["git_ssh_key_file"]; ok {
+		config.GitSSHKeyFile = keyFile
+	}
+
+	if knownHosts, ok := values["git_ssh_known_hosts_file"]; ok {
+		config.GitSSHKnownHostsFile = knownHosts
+	}
+
+	if token, ok := values["git_token"]; ok {
+		config.GitToken = token
+	}
+
+	if user, ok := values["git_token_user"]; ok && user != "" {
+		config.GitTokenUser = user
+	}
+
+	if hosts, ok := values["git_token_hosts"]; ok {
+		config.GitTokenHosts = splitList(hosts)
+	}
+
+	if helper, ok := values["git_credential_helper"]; ok {
+		config.GitCredentialHelper = helper
+	}
+
+	if githubAPIURL, ok := values["github_api_url"]; ok {
+		config.GitHubAPIURL = githubAPIURL
+	}
+
+	if githubToken, ok := values["github_token"]; ok {
+		config.GitHubToken = githubToken
+	}
+
+	if githubCABundle, ok := values["github_ca_bundle"]; ok {
+		config.GitHubCABundle = githubCABundle
+	}
+
+	if githubChecks, ok := values["github_checks"]; ok {
+		config.GitHubChecks = githubChecks == "true"
+	}
+
+	if threshold, ok := values["github_failure_threshold"]; ok {
+		if n, err := strconv.Atoi(threshold); err == nil && n >= 0 {
+			config.GitHubFailureThreshold = n
+		}
+	}
+
+	if report, ok := values["github_failure_report"]; ok {
+		config.GitHubFailureReport = report
+	}
+
+	if labels, ok := values["github_failure_labels"]; ok {
+		config.GitHubFailureLabels = splitList(labels)
+	}
+
+	// Parse application configuration fields (required)
+	if targetRepoURL, ok := values["target_repo_url"]; ok {
+		config.TargetRepoURL = targetRepoURL
+	} else {
+		return nil, fmt.Errorf("missing required field: target_repo_url")
+	}
+
+	if appName, ok := values["app_name"]; ok && appName != "" {
+		config.AppName = appName
+	} else {
+		config.AppName = RepoNameFromURL(config.TargetRepoURL)
+	}
+
+	if allowedBranches, ok := values["allowed_branches"]; ok {
+		config.AllowedBranches = allowedBranches
+	} else {
+		return nil, fmt.Errorf("missing required field: allowed_branches")
+	}
+
+	if tags, ok := values["allowed_tags"]; ok {
+		config.AllowedTags = splitList(tags)
+	}
+
+	if secret, ok := values["secret"]; ok {
+		config.Secret = secret
+	} else {
+		return nil, fmt.Errorf("missing required field: secret")
+	}
+
+	return config, nil
+}
+
+// parseClientLimits parses the settings guarding the server against its
+// clients, which the multi-app front server shares with the apps
+func (c *DeployConfig) parseClientLimits(values map[string]string) {
+	if timeout, ok := values["webhook_body_timeout"]; ok {
+		if t, err := strconv.Atoi(timeout); err == nil && t > 0 {
+			c.WebhookTimeout = t
+		}
+	}
+
+	if proxies, ok := values["trusted_proxies"]; ok {
+		c.TrustedProxies = splitList(proxies)
+	}
+
+	if threshold, ok := values["auth_failure_threshold"]; ok {
+		if n, err := strconv.Atoi(threshold); err == nil && n >= 0 {
+			c.AuthFailureThreshold = n
+		}
+	}
+
+	if window, ok := values["auth_failure_window"]; ok {
+		if n, err := strconv.Atoi(window); err == nil && n > 0 {
+			c.AuthFailureWindow = n
+		}
+	}
+
+	if ban, ok := values["auth_ban_duration"]; ok {
+		if n, err := strconv.Atoi(ban); err == nil && n > 0 {
+			c.AuthBanDuration = n
+		}
+	}
+
+	if exempt, ok := values["auth_ban_exempt"]; ok {
+		c.AuthBanExempt = splitList(exempt)
+	}
+}
+
+// decryptValues replaces encrypted values in place, remembering the
+// plaintexts so they can be redacted from output
+func (c *DeployConfig) decryptValues(values map[string]string, key []byte) error {
+	for name, value := range values {
+		if !secrets.IsEncrypted(value) {
+			continue
+		}
+		plaintext, err := secrets.Decrypt(key, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		values[name] = plaintext
+		c.decrypted = append(c.decrypted, plaintext)
+	}
+	return nil
+}
+
+// ApplicationPorts is the number of consecutive ports from ApplicationPort
+// the application uses: one per instance, or the proxy port and the blue
+// and green release ports
+func (c *DeployConfig) ApplicationPorts() int {
+	if c.DeploymentStrategy == StrategyBlueGreen {
+		return 3
+	}
+	return max(c.Instances, 1)
+}
+
+// EndpointDisabled reports whether disabled_endpoints turns off endpoint
+func (c *DeployConfig) EndpointDisabled(endpoint string) bool {
+	return slices.Contains(c.DisabledEndpoints, endpoint)
+}
+
+// SecretValues returns the credentials in the config and every value that was
+// stored encrypted, for redacting them from logs and API responses
+func (c *DeployConfig) SecretValues() []string {
+	values := []string{c.Secret, c.APIToken, c.GenericWebhookToken, c.GitHubToken, c.GitToken, c.ChainToken, c.PeerToken, c.S3SecretKey,
+		c.ChatOpsSigningSecret, c.ChatOpsToken, c.PagerDutyRoutingKey, c.OpsgenieAPIKey, c.JiraToken, c.NotifySecret}
+	for _, password := range c.AuthUsers {
+		values = append(values, password)
+	}
+	for _, options := range c.Notifiers {
+		for _, option := range notify.SecretOptions {
+			values = append(values, options[option])
+		}
+	}
+	// Template variables are how secrets get into rendered config files
+	for _, value := range c.TemplateVars {
+		values = append(values, value)
+	}
+	return append(values, c.decrypted...)
+}
+
+// RedactionRules compiles the configured redaction rules
+func (c *DeployConfig) RedactionRules() ([]secrets.Rule, error) {
+	var rules []secrets.Rule
+	if c.RedactBuiltinRules {
+		rules = append(rules, secrets.BuiltinRules()...)
+	}
+	for _, keyword := range c.RedactKeywords {
+		rules = append(rules, secrets.KeywordRule(keyword))
+	}
+
+	names := make([]string, 0, len(c.RedactRules))
+	for name := range c.RedactRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rule, err := secrets.NewRule(name, c.RedactRules[name])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ValidateConfig validates the configuration and returns warnings for used defaults
+func ValidateConfig(config *DeployConfig) error {
+	// Check all required fields
+	if config.TargetRepoURL == "" {
+		return fmt.Errorf("missing required field: target_repo_url")
+	}
+	// The name ends up in email subjects and chat messages
+	if len(config.InstanceName) > 64 || strings.ContainsFunc(config.InstanceName, unicode.IsControl) {
+		return fmt.Errorf("invalid instance_name %q: expected at most 64 characters without control characters", config.InstanceName)
+	}
+	if config.ConfigRepoURL != "" {
+		if config.ConfigRepoBranch == "" {
+			return fmt.Errorf("config_repo_branch must not be empty")
+		}
+		if !filepath.IsLocal(config.ConfigRepoFile) {
+			return fmt.Errorf("invalid config_repo_file %q: expected a path inside the config repository", config.ConfigRepoFile)
+		}
+	}
+	if config.AllowedBranches == "" {
+		return fmt.Errorf("missing required field: allowed_branches")
+	}
+	if config.Secret == "" {
+		return fmt.Errorf("missing required field: secret")
+	}
+	if config.BuildCommand == "" {
+		return fmt.Errorf("missing required field: build_command")
+	}
+	if config.RunCommand == "" && config.DeployDriver == "local" {
+		return fmt.Errorf("missing required field: run_command")
+	}
+	for env, spec := range config.FreezeWindows {
+		if _, err := schedule.ParseWindows(spec); err != nil {
+			if env == "" {
+				return fmt.Errorf("invalid freeze_windows: %w", err)
+			}
+			return fmt.Errorf("invalid freeze_windows.%s: %w", env, err)
+		}
+	}
+	if config.FreezeMode != "reject" && config.FreezeMode != "queue" {
+		return fmt.Errorf("invalid freeze_mode %q: expected reject or queue", config.FreezeMode)
+	}
+	if config.ChainOn != "success" && config.ChainOn != "always" {
+		return fmt.Errorf("invalid chain_on %q: expected success or always", config.ChainOn)
+	}
+	for _, peer := range config.PeerURLs {
+		if u, err := url.Parse(peer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid peer_urls entry %q: expected an http(s) base URL", peer)
+		}
+	}
+	if config.RestartSchedule != "" {
+		if _, err := schedule.Parse(config.RestartSchedule); err != nil {
+			return fmt.Errorf("invalid restart_schedule: %w", err)
+		}
+	}
+	if config.GitGCSchedule != "" {
+		if _, err := schedule.Parse(config.GitGCSchedule); err != nil {
+			return fmt.Errorf("invalid git_gc_schedule: %w", err)
+		}
+	}
+	if strings.HasPrefix(config.GitGCPrune, "-") || strings.ContainsAny(config.GitGCPrune, " \t") {
+		return fmt.Errorf("invalid git_gc_prune %q: expected a date such as 2.weeks.ago, now or never", config.GitGCPrune)
+	}
+	if _, err := config.RedactionRules(); err != nil {
+		return err
+	}
+	for name, target := range config.DependencyChecks {
+		if err := health.ValidateTarget(target); err != nil {
+			return fmt.Errorf("dependency_checks.%s: %w", name, err)
+		}
+	}
+	for _, name := range config.CriticalDependencies {
+		if _, ok := config.DependencyChecks[name]; !ok {
+			return fmt.Errorf("critical_dependencies: %q has no dependency_checks.%s entry", name, name)
+		}
+	}
+	for _, resource := range config.SharedResources {
+		if !resourceNamePattern.MatchString(resource) {
+			return fmt.Errorf("invalid shared_resources entry %q: use letters, digits, dots, dashes and underscores", resource)
+		}
+	}
+	if len(config.SharedResources) > 0 && config.ResourceLockDir == "" {
+		return fmt.Errorf("shared_resources requires resource_lock_dir")
+	}
+	if _, err := mail.ParseAddress(config.TagTagger); err != nil {
+		return fmt.Errorf("invalid tag_tagger %q: expected \"Name <email>\"", config.TagTagger)
+	}
+	if config.JiraToken != "" && config.IssueTrackerURL == "" {
+		return fmt.Errorf("jira_token requires issue_tracker_url")
+	}
+	if config.GitHubFailureReport != "issue" && config.GitHubFailureReport != "comment" {
+		return fmt.Errorf("invalid github_failure_report %q: expected issue or comment", config.GitHubFailureReport)
+	}
+	if config.GitHubFailureThreshold > 0 && config.GitHubToken == "" {
+		return fmt.Errorf("github_failure_threshold requires github_token")
+	}
+	if len(config.GitTokenHosts) > 0 && config.GitToken == "" {
+		return fmt.Errorf("git_token_hosts requires git_token")
+	}
+	for _, host := range config.GitTokenHosts {
+		if strings.ContainsAny(host, "/@ ") {
+			return fmt.Errorf("invalid git_token_hosts entry %q: expected a host name such as github.com", host)
+		}
+	}
+	switch config.IncidentProvider {
+	case "":
+	case "pagerduty":
+		if config.PagerDutyRoutingKey == "" {
+			return fmt.Errorf("incident_provider=pagerduty requires pagerduty_routing_key")
+		}
+	case "opsgenie":
+		if config.OpsgenieAPIKey == "" {
+			return fmt.Errorf("incident_provider=opsgenie requires opsgenie_api_key")
+		}
+	default:
+		return fmt.Errorf("invalid incident_provider %q: expected pagerduty or opsgenie", config.IncidentProvider)
+	}
+	for _, addr := range append(slices.Clone(config.Listen), config.AdminListen...) {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid listen address %q: expected host:port, e.g. 127.0.0.1:9090 or [::]:8080", addr)
+		}
+	}
+	if strings.ContainsAny(config.BasePath, "?#% \t") {
+		return fmt.Errorf("invalid base_path %q: expected a plain path such as /binarydeploy", config.BasePath)
+	}
+	if _, err := authguard.ParseNetworks(config.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted_proxies: %w", err)
+	}
+	for _, endpoint := range config.DisabledEndpoints {
+		if !slices.Contains(DisableableEndpoints, endpoint) {
+			return fmt.Errorf("invalid disabled_endpoints entry %q: expected one of %s", endpoint, strings.Join(DisableableEndpoints, ", "))
+		}
+	}
+	if config.Limits.MaxApps > 0 && config.Instances > config.Limits.MaxApps {
+		return fmt.Errorf("instances=%d exceeds the limit of %d apps", config.Instances, config.Limits.MaxApps)
+	}
+	if config.SkipUnchangedBuilds && len(config.ArtifactPaths) == 0 {
+		return fmt.Errorf("skip_unchanged_builds requires artifact_paths to know which files make up the build")
+	}
+	if _, local := LocalRepoPath(config.TargetRepoURL); config.WatchLocalRepo && !local {
+		return fmt.Errorf("watch_local_repo requires a local target_repo_url, e.g. file:///srv/git/myapp.git")
+	}
+	if config.ACMEChallengeApp && config.ACMEChallengeDir != "" {
+		return fmt.Errorf("acme_challenge_app and acme_challenge_dir are mutually exclusive")
+	}
+	if err := config.TLS.Validate(); err != nil {
+		return err
+	}
+	if config.RequireAuth && config.APIToken == "" && len(config.AuthUsers) == 0 {
+		return fmt.Errorf("require_auth needs api_token or auth_users.<name> to log in with")
+	}
+	for name, password := range config.AuthUsers {
+		if strings.ContainsAny(name, ": ") || password == "" {
+			return fmt.Errorf("invalid auth_users.%s: user names can't contain ':' or spaces and need a password", name)
+		}
+	}
+	if _, err := authguard.ParseNetworks(config.AuthBanExempt); err != nil {
+		return fmt.Errorf("invalid auth_ban_exempt: %w", err)
+	}
+	for name, options := range config.Notifiers {
+		if _, err := notify.NewRoute(name, options); err != nil {
+			return err
+		}
+	}
+	if config.LogOutput != "file" && config.LogOutput != "stdout" && config.LogOutput != "both" {
+		return fmt.Errorf("invalid log_output %q: expected file, stdout or both", config.LogOutput)
+	}
+	if config.LogFormat != "json" && config.LogFormat != "console" {
+		return fmt.Errorf("invalid log_format %q: expected json or console", config.LogFormat)
+	}
+	if !slices.Contains(kv.Drivers, config.StorageDriver) {
+		return fmt.Errorf("invalid storage_driver %q: expected one of %s", config.StorageDriver, strings.Join(kv.Drivers, ", "))
+	}
+	if config.DependencyGateMode != "reject" && config.DependencyGateMode != "wait" {
+		return fmt.Errorf("invalid dependency_gate_mode %q: expected reject or wait", config.DependencyGateMode)
+	}
+	if _, err := templating.ParseFiles(config.ConfigTemplates); err != nil {
+		return fmt.Errorf("invalid config_templates: %w", err)
+	}
+	for key, command := range map[string]string{"build_command": config.BuildCommand, "run_command": config.RunCommand} {
+		if err := templating.ParseCommand(command); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	if strings.HasPrefix(config.ArtifactStore, "s3://") && config.S3Endpoint == "" {
+		return fmt.Errorf("artifact_store %q requires s3_endpoint", config.ArtifactStore)
+	}
+	switch config.DeployDriver {
+	case "local":
+	case "ssh", "sftp":
+		if len(config.SSHHosts) == 0 {
+			return fmt.Errorf("deploy_driver=%s requires ssh_hosts", config.DeployDriver)
+		}
+		if config.SSHRemoteDir == "" {
+			return fmt.Errorf("deploy_driver=%s requires ssh_remote_dir", config.DeployDriver)
+		}
+		for _, host := range config.SSHHosts {
+			if _, err := remote.ParseHost(host); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("invalid deploy_driver %q: expected local, ssh or sftp", config.DeployDriver)
+	}
+	if strings.HasPrefix(config.OffsiteStore, "s3://") && config.S3Endpoint == "" {
+		return fmt.Errorf("offsite_store %q requires s3_endpoint", config.OffsiteStore)
+	}
+	if config.Nice < -20 || config.Nice > 19 {
+		return fmt.Errorf("invalid nice %d: expected -20 to 19", config.Nice)
+	}
+	switch config.IOClass {
+	case "", "realtime", "best-effort", "idle":
+	default:
+		return fmt.Errorf("invalid ionice_class %q: expected realtime, best-effort or idle", config.IOClass)
+	}
+	if config.IOLevel < 0 || config.IOLevel > 7 {
+		return fmt.Errorf("invalid ionice_level %d: expected 0 to 7", config.IOLevel)
+	}
+	if config.ReadinessNotify && config.DeployDriver != "local" {
+		return fmt.Errorf("readiness_notify requires deploy_driver=local")
+	}
+	if config.HealthCheckInterval > 0 && config.HealthCheckURL == "" {
+		return fmt.Errorf("health_check_interval requires health_check_url")
+	}
+	if config.KeepBuilds > 0 {
+		if config.KeepBuilds < 2 {
+			return fmt.Errorf("keep_builds=%d leaves no previous build to roll back to, use at least 2", config.KeepBuilds)
+		}
+		if config.DeployDriver != "local" {
+			return fmt.Errorf("keep_builds requires deploy_driver=local")
+		}
+		if config.DeploymentStrategy == StrategyBlueGreen {
+			return fmt.Errorf("keep_builds can't be combined with deployment_strategy=%s, which keeps the previous release itself", StrategyBlueGreen)
+		}
+	}
+	switch config.DeploymentStrategy {
+	case StrategyRestart:
+	case StrategyBlueGreen:
+		if err := validateBlueGreen(config); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid deployment_strategy %q: expected %s or %s", config.DeploymentStrategy, StrategyRestart, StrategyBlueGreen)
+	}
+
+	return nil
+}
+
+// validateBlueGreen checks that the blue-green strategy can run two releases
+// side by side
+func validateBlueGreen(config *DeployConfig) error {
+	if config.DeployDriver != "local" {
+		return fmt.Errorf("deployment_strategy=%s requires deploy_driver=local", StrategyBlueGreen)
+	}
+	if config.Instances > 1 {
+		return fmt.Errorf("deployment_strategy=%s runs a single instance, remove instances=%d", StrategyBlueGreen, config.Instances)
+	}
+	if config.PauseBeforeStart > 0 || config.PauseWebhookURL != "" {
+		return fmt.Errorf("deployment_strategy=%s never stops the old version before starting the new one, remove pause_before_start and pause_webhook_url", StrategyBlueGreen)
+	}
+	if len(config.BlueGreenPorts) != 2 {
+		return fmt.Errorf("blue_green_ports needs exactly two ports, got %d", len(config.BlueGreenPorts))
+	}
+	for _, port := range config.BlueGreenPorts {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid blue_green_ports entry %d", port)
+		}
+		if config.BlueGreenProxy && port == config.ApplicationPort {
+			return fmt.Errorf("blue_green_ports must differ from port %d, which the blue-green proxy serves", config.ApplicationPort)
+		}
+	}
+	if config.BlueGreenPorts[0] == config.BlueGreenPorts[1] {
+		return fmt.Errorf("blue_green_ports must be two different ports")
+	}
+	if !config.BlueGreenProxy && config.BlueGreenSwitchCommand == "" {
+		return fmt.Errorf("blue_green_proxy=false requires blue_green_switch_command to switch traffic")
+	}
+	return nil
+}
+
+// GetDefaultWarnings returns warnings for any default values being used
+func GetDefaultWarnings(config *DeployConfig) []string {
+	var warnings []string
+
+	defaults := DefaultDeployConfig()
+
+	if config.Port == defaults.Port {
+		warnings = append(warnings, "Using default binary port 8080 (add 'port=8080' to deploy.config to customize)")
+	}
+	if config.LogFile == defaults.LogFile && config.LogOutput != "stdout" {
+		warnings = append(warnings, "Using default log file ./binaryDeploy.log (add 'log_file=...' to deploy.config to customize)")
+	}
+	if config.DeployDir == defaults.DeployDir {
+		warnings = append(warnings, "Using default deploy directory ./deployments (add 'deploy_dir=...' to deploy.config to customize)")
+	}
+	if config.SelfUpdateDir == defaults.SelfUpdateDir {
+		warnings = append(warnings, "Using default self-update directory ./self-update (add 'self_update_dir=...' to deploy.config to customize)")
+	}
+	if config.SelfUpdateRepoURL == defaults.SelfUpdateRepoURL {
+		warnings = append(warnings, "Using default self-update repository (add 'self_update_repo_url=...' to deploy.config to customize)")
+	}
+	if config.AllowedBranches == defaults.AllowedBranches {
+		warnings = append(warnings, "Using default allowed branches 'main' (add 'allowed_branches=...' to deploy.config to customize)")
+	}
+
+	return warnings
+}
+
+// defaultInstanceName is the hostname, or empty when it can't be read
+func defaultInstanceName() string {
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// maxCPUs is the number of CPUs an affinity mask can address
+const maxCPUs = 1024
+
+// parseCPUList parses a Linux cpuset list such as "2-5,8" into CPU numbers
+func parseCPUList(value string) ([]int, error) {
+	var cpus []int
+	seen := map[int]bool{}
+	for _, item := range splitList(value) {
+		first, last, isRange := strings.Cut(item, "-")
+		from, err := strconv.Atoi(strings.TrimSpace(first))
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(strings.TrimSpace(last))
+		}
+		if err != nil || from < 0 || to < from || to >= maxCPUs {
+			return nil, fmt.Errorf("expected CPU numbers or ranges below %d, e.g. 2-5,8", maxCPUs)
+		}
+		for cpu := from; cpu <= to; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	return cpus, nil
+}
+
+// RepoNameFromURL derives a repository name from a clone URL,
+// e.g. https://github.com/user/myapp.git -> myapp
+func RepoNameFromURL(repoURL string) string {
+	name := strings.TrimRight(repoURL, "/")
+	if idx := strings.LastIndexAny(name, "/:"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return strings.TrimSuffix(name, ".git")
+}
+
+// LocalRepoPath returns the filesystem path of a file:// or path repository
+// URL, and false for remote ones
+func LocalRepoPath(repoURL string) (string, bool) {
+	if path, ok := strings.CutPrefix(repoURL, "file://"); ok {
+		return path, true
+	}
+	if filepath.IsAbs(repoURL) || strings.HasPrefix(repoURL, "./") || strings.HasPrefix(repoURL, "../") {
+		return repoURL, true
+	}
+	return "", false
 }
 
 // readConfigFile reads and parses a key=value config file
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limits cap what one binaryDeploy instance, and in multi-tenant mode one
+// tenant, may use. Zero means unlimited.
+type Limits struct {
+	MaxConcurrentDeploys int // Deployments queued or running at once
+	DeploysPerHour       int // Deployments started in any rolling hour
+	DiskQuotaMB          int // Size of the directory binaryDeploy runs from
+	MaxApps              int // Copies of the application, i.e. instances
+}
+
+// limitKeys are the config keys of the Limits fields
+var limitKeys = []string{"max_concurrent_deploys", "deploys_per_hour", "disk_quota_mb", "max_apps"}
+
+// field returns the Limits field behind key
+func (l *Limits) field(key string) *int {
+	switch key {
+	case "max_concurrent_deploys":
+		return &l.MaxConcurrentDeploys
+	case "deploys_per_hour":
+		return &l.DeploysPerHour
+	case "disk_quota_mb":
+		return &l.DiskQuotaMB
+	case "max_apps":
+		return &l.MaxApps
+	}
+	return nil
+}
+
+// set parses value as the limit named by key
+func (l *Limits) set(key, value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid %s %q: expected a number, 0 for unlimited", key, value)
+	}
+	*l.field(key) = n
+	return nil
+}
+
+// parseLimits reads the limit keys present in values
+func parseLimits(values map[string]string) (Limits, error) {
+	var limits Limits
+	for _, key := range limitKeys {
+		if value, ok := values[key]; ok {
+			if err := limits.set(key, value); err != nil {
+				return Limits{}, err
+			}
+		}
+	}
+	return limits, nil
+}
+
+// Override returns l with every limit set in other replacing its own
+func (l Limits) Override(other Limits) Limits {
+	for _, key := range limitKeys {
+		if n := *other.field(key); n > 0 {
+			*l.field(key) = n
+		}
+	}
+	return l
+}
+
+// IsZero reports whether no limit is set
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// String formats the limits that are set as key=value pairs separated by
+// commas, the format ParseLimits reads
+func (l Limits) String() string {
+	var pairs []string
+	for _, key := range limitKeys {
+		if n := *l.field(key); n > 0 {
+			pairs = append(pairs, key+"="+strconv.Itoa(n))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseLimits reads limits formatted by Limits.String
+func ParseLimits(s string) (Limits, error) {
+	values := map[string]string{}
+	for _, pair := range splitList(s) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || (&Limits{}).field(key) == nil {
+			return Limits{}, fmt.Errorf("invalid limit %q", pair)
+		}
+		values[key] = value
+	}
+	return parseLimits(values)
+}
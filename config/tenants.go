@@ -0,0 +1,174 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"binaryDeploy/portalloc"
+)
+
+// tenantNamePattern restricts tenant names to something safe in URL paths
+// and log prefixes
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// TenantsConfig represents the parsed tenants.config file that runs
+// binaryDeploy in multi-tenant mode
+type TenantsConfig struct {
+	Listen   string // Address the shared front server listens on
+	APIToken string // Guards the supervisor's /tenants status endpoint
+	PortBase int    // First loopback port handed to tenant instances
+	Tenants  []Tenant
+
+	// When PortRange is set, each tenant's application gets TenantPorts
+	// ports allocated from it instead of setting its own port
+	PortRange   portalloc.Range
+	TenantPorts int
+}
+
+// Tenant is one isolated namespace: its own directory holding a
+// deploy.config, and with it its own apps, tokens, secrets, deploy
+// directories and logs
+type Tenant struct {
+	Name   string
+	Dir    string
+	User   string // Optional system user the tenant instance runs as
+	Port   int    // Loopback port the tenant instance listens on
+	Limits Limits // Replace the limits in the tenant's own deploy.config
+}
+
+// LoadTenantsConfig reads a tenants.config file. Tenants are declared as
+// tenants.<name>.dir and tenants.<name>.user and get consecutive ports from
+// tenant_port_base in name order. Limits set at the top level apply to every
+// tenant unless tenants.<name>.<limit> overrides them.
+func LoadTenantsConfig(path string) (*TenantsConfig, error) {
+	values, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &TenantsConfig{
+		Listen:      ":8080",
+		PortBase:    19100,
+		TenantPorts: 10,
+	}
+
+	if port, ok := values["port"]; ok {
+		config.Listen = ":" + port
+	}
+	if listen, ok := values["listen"]; ok {
+		config.Listen = listen
+	}
+	if apiToken, ok := values["api_token"]; ok {
+		config.APIToken = apiToken
+	}
+	if base, ok := values["tenant_port_base"]; ok {
+		p, err := strconv.Atoi(base)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("invalid tenant_port_base %q", base)
+		}
+		config.PortBase = p
+	}
+
+	if portRange, ok := values["port_range"]; ok && portRange != "" {
+		if config.PortRange, err = portalloc.ParseRange(portRange); err != nil {
+			return nil, fmt.Errorf("invalid port_range: %w", err)
+		}
+	}
+	if ports, ok := values["tenant_ports"]; ok {
+		n, err := strconv.Atoi(ports)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid tenant_ports %q", ports)
+		}
+		config.TenantPorts = n
+	}
+
+	defaults, err := parseLimits(values)
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := map[string]*Tenant{}
+	for key, value := range values {
+		rest, ok := strings.CutPrefix(key, "tenants.")
+		if !ok {
+			continue
+		}
+		name, option, ok := strings.Cut(rest, ".")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid key %q: expected tenants.<name>.dir or tenants.<name>.user", key)
+		}
+		if tenants[name] == nil {
+			tenants[name] = &Tenant{Name: name, Limits: defaults}
+		}
+		switch {
+		case option == "dir":
+			tenants[name].Dir = value
+		case option == "user":
+			tenants[name].User = value
+		case tenants[name].Limits.field(option) != nil:
+			if err := tenants[name].Limits.set(option, value); err != nil {
+				return nil, fmt.Errorf("tenant %s: %w", name, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown tenant option %q for tenant %s", option, name)
+		}
+	}
+
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		tenant := tenants[name]
+		tenant.Port = config.PortBase + i
+		config.Tenants = append(config.Tenants, *tenant)
+	}
+
+	return config, nil
+}
+
+// ValidateTenantsConfig checks that every tenant is well formed and that no
+// two tenants share a directory, which would share their state
+func ValidateTenantsConfig(config *TenantsConfig) error {
+	if _, _, err := net.SplitHostPort(config.Listen); err != nil {
+		return fmt.Errorf("invalid listen address %q: expected host:port, e.g. :8080", config.Listen)
+	}
+	if len(config.Tenants) == 0 {
+		return fmt.Errorf("no tenants configured: add tenants.<name>.dir entries")
+	}
+	last := config.PortBase + len(config.Tenants) - 1
+	if last > 65535 {
+		return fmt.Errorf("tenant_port_base %d leaves no room for %d tenants", config.PortBase, len(config.Tenants))
+	}
+	if config.PortRange.Overlaps(portalloc.Range{First: config.PortBase, Last: last}) {
+		return fmt.Errorf("port_range %s overlaps the tenant instance ports %d-%d from tenant_port_base", config.PortRange, config.PortBase, last)
+	}
+	if config.PortRange.Size() > 0 && config.PortRange.Size() < config.TenantPorts*len(config.Tenants) {
+		return fmt.Errorf("port_range %s is too small for %d ports for each of %d tenants", config.PortRange, config.TenantPorts, len(config.Tenants))
+	}
+
+	dirs := map[string]string{}
+	for _, tenant := range config.Tenants {
+		if !tenantNamePattern.MatchString(tenant.Name) {
+			return fmt.Errorf("invalid tenant name %q: use lowercase letters, digits and dashes", tenant.Name)
+		}
+		if tenant.Dir == "" {
+			return fmt.Errorf("tenant %s has no dir", tenant.Name)
+		}
+		dir, err := filepath.Abs(tenant.Dir)
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		if other, ok := dirs[dir]; ok {
+			return fmt.Errorf("tenants %s and %s share the directory %s", other, tenant.Name, dir)
+		}
+		dirs[dir] = tenant.Name
+	}
+	return nil
+}
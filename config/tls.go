@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// LetsEncryptDirectory is the ACME directory certificates are requested from
+// unless acme_directory_url is set
+const LetsEncryptDirectory = "https://acme-v02.api.letsencrypt.org/directory"
+
+// TLS serves the listeners over HTTPS, either with a certificate from files
+// or with one obtained automatically from an ACME CA such as Let's Encrypt
+type TLS struct {
+	CertFile string // PEM certificate chain, reloaded when it changes
+	KeyFile  string // PEM private key of CertFile
+
+	ACMEHostnames    []string // Names the automatic certificate covers; empty disables ACME
+	ACMEEmail        string   // Contact the CA sends expiry notices to
+	ACMEDirectoryURL string
+	ACMECacheDir     string // Holds the ACME account key and the certificate
+}
+
+// tlsKeys are the config keys of the TLS fields
+var tlsKeys = []string{"tls_cert_file", "tls_key_file", "acme_hostnames", "acme_email", "acme_directory_url", "acme_cache_dir"}
+
+// defaultTLS has the ACME settings used when acme_hostnames is set
+func defaultTLS() TLS {
+	return TLS{ACMEDirectoryURL: LetsEncryptDirectory, ACMECacheDir: "./acme"}
+}
+
+// parseTLS reads the TLS keys present in values
+func parseTLS(values map[string]string) TLS {
+	t := defaultTLS()
+	if certFile, ok := values["tls_cert_file"]; ok {
+		t.CertFile = certFile
+	}
+	if keyFile, ok := values["tls_key_file"]; ok {
+		t.KeyFile = keyFile
+	}
+	if hostnames, ok := values["acme_hostnames"]; ok {
+		t.ACMEHostnames = splitList(strings.ToLower(hostnames))
+	}
+	if email, ok := values["acme_email"]; ok {
+		t.ACMEEmail = email
+	}
+	if directory, ok := values["acme_directory_url"]; ok && directory != "" {
+		t.ACMEDirectoryURL = directory
+	}
+	if cacheDir, ok := values["acme_cache_dir"]; ok && cacheDir != "" {
+		t.ACMECacheDir = cacheDir
+	}
+	return t
+}
+
+// Enabled reports whether the listeners serve HTTPS
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" || len(t.ACMEHostnames) > 0
+}
+
+// ACME reports whether the certificate is obtained from an ACME CA
+func (t TLS) ACME() bool {
+	return len(t.ACMEHostnames) > 0
+}
+
+// Validate checks that exactly one certificate source is configured
+// completely
+func (t TLS) Validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	if t.CertFile != "" && t.ACME() {
+		return fmt.Errorf("tls_cert_file and acme_hostnames are mutually exclusive")
+	}
+	if !t.ACME() {
+		return nil
+	}
+	for _, host := range t.ACMEHostnames {
+		if strings.Contains(host, "*") || strings.ContainsAny(host, ":/ ") || !strings.Contains(host, ".") {
+			return fmt.Errorf("invalid acme_hostnames entry %q: expected a fully qualified hostname without wildcards", host)
+		}
+	}
+	if t.ACMEEmail != "" {
+		if _, err := mail.ParseAddress(t.ACMEEmail); err != nil {
+			return fmt.Errorf("invalid acme_email %q", t.ACMEEmail)
+		}
+	}
+	if u, err := url.Parse(t.ACMEDirectoryURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid acme_directory_url %q: expected an https URL", t.ACMEDirectoryURL)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"binaryDeploy/config"
+)
+
+// deployConfigFile is the configuration binaryDeploy runs with, relative to
+// its working directory
+const deployConfigFile = "deploy.config"
+
+// reloadMu serializes configuration reloads
+var reloadMu sync.Mutex
+
+// startupOnlySettings are read once at startup, so changing them only takes
+// effect after binaryDeploy restarts
+var startupOnlySettings = []struct {
+	key   string
+	value func(*config.DeployConfig) any
+}{
+	{"binary_port", func(c *config.DeployConfig) any { return c.Port }},
+	{"listen", func(c *config.DeployConfig) any { return c.Listen }},
+	{"admin_listen", func(c *config.DeployConfig) any { return c.AdminListen }},
+	{"tls_cert_file", func(c *config.DeployConfig) any { return c.TLS.CertFile }},
+	{"tls_key_file", func(c *config.DeployConfig) any { return c.TLS.KeyFile }},
+	{"acme_hostnames", func(c *config.DeployConfig) any { return c.TLS.ACMEHostnames }},
+	{"base_path", func(c *config.DeployConfig) any { return c.BasePath }},
+	{"log_file", func(c *config.DeployConfig) any { return c.LogFile }},
+	{"instance_name", func(c *config.DeployConfig) any { return c.InstanceName }},
+	{"log_output", func(c *config.DeployConfig) any { return c.LogOutput }},
+	{"log_format", func(c *config.DeployConfig) any { return c.LogFormat }},
+	{"deploy_dir", func(c *config.DeployConfig) any { return c.DeployDir }},
+	{"temp_dir", func(c *config.DeployConfig) any { return c.TempDir }},
+	{"state_dir", func(c *config.DeployConfig) any { return c.StateDir }},
+	{"storage_driver", func(c *config.DeployConfig) any { return c.StorageDriver }},
+	{"storage_path", func(c *config.DeployConfig) any { return c.StoragePath }},
+	{"instances", func(c *config.DeployConfig) any { return c.Instances }},
+	{"disabled_endpoints", func(c *config.DeployConfig) any { return c.DisabledEndpoints }},
+	{"watch_local_repo", func(c *config.DeployConfig) any { return c.WatchLocalRepo }},
+	{"incoming_dir", func(c *config.DeployConfig) any { return c.IncomingDir }},
+	{"ca_certs", func(c *config.DeployConfig) any { return c.CACerts }},
+	{"restart_schedule", func(c *config.DeployConfig) any { return c.RestartSchedule }},
+	{"git_gc_schedule", func(c *config.DeployConfig) any { return c.GitGCSchedule }},
+	{"auth_failure_threshold", func(c *config.DeployConfig) any { return c.AuthFailureThreshold }},
+	{"auth_ban_exempt", func(c *config.DeployConfig) any { return c.AuthBanExempt }},
+	{"deployment_strategy", func(c *config.DeployConfig) any { return c.DeploymentStrategy }},
+	{"blue_green_ports", func(c *config.DeployConfig) any { return c.BlueGreenPorts }},
+	{"blue_green_proxy", func(c *config.DeployConfig) any { return c.BlueGreenProxy }},
+}
+
+// reloadConfig re-reads deploy.config and applies it without a restart.
+// Settings read per request or per deployment take effect immediately, and
+// notifiers, trusted proxies, incident escalation, the GitHub integration
+// and secret redaction are set up again. Changed settings that only take
+// effect after a restart are returned. An invalid file leaves the running
+// configuration untouched.
+func reloadConfig() (restartRequired []string, err error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next, err := loadDeployConfigFile(deployConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	previous := appConfig()
+	if next.LogFile == "" {
+		next.LogFile = previous.LogFile
+	}
+
+	for _, setting := range startupOnlySettings {
+		if !reflect.DeepEqual(setting.value(previous), setting.value(next)) {
+			restartRequired = append(restartRequired, setting.key)
+		}
+	}
+
+	// The process group and blue-green proxy were set up for the strategy
+	// binaryDeploy started with
+	next.DeploymentStrategy = previous.DeploymentStrategy
+	next.BlueGreenPorts = previous.BlueGreenPorts
+	next.BlueGreenProxy = previous.BlueGreenProxy
+
+	currentConfig.Store(next)
+	setupNotifications()
+	setupReverseProxy()
+	setupEscalation()
+	currentGitHubClient.Store(nil)
+	setupGitHub()
+	if err := setupGitCredentials(); err != nil {
+		slog.Error("Failed to set up git credentials", "error", err)
+	}
+	rules, _ := appConfig().RedactionRules()
+	secretRedactor.Update(appConfig().SecretValues(), rules...)
+
+	slog.Info("Configuration reloaded", "file", deployConfigFile)
+	logEffectiveConfig()
+	if len(restartRequired) > 0 {
+		slog.Warn("Some changed settings take effect after a restart", "settings", restartRequired)
+	}
+	return restartRequired, nil
+}
+
+// startReloadSignalHandler reloads deploy.config on SIGHUP
+func startReloadSignalHandler() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("Received SIGHUP, reloading configuration")
+			if _, err := reloadConfig(); err != nil {
+				slog.Error("Configuration reload failed, keeping the running configuration", "error", err)
+			}
+		}
+	}()
+}
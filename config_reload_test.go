@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"binaryDeploy/secrets"
+)
+
+// writeReloadTestConfig writes the deploy.config reloadConfig reads, with
+// webhook secret secret
+func writeReloadTestConfig(t *testing.T, dir, secret string) {
+	t.Helper()
+	content := fmt.Sprintf(`target_repo_url=https://git.example.com/team/app.git
+allowed_branches=main
+build_command=go build -o app .
+run_command=./app
+deploy_dir=%s
+secret=%s
+api_token=reload-test-token
+trusted_proxies=10.0.0.0/8
+`, filepath.Join(dir, "deployments"), secret)
+	if err := os.WriteFile(filepath.Join(dir, deployConfigFile), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chdirForTest runs the rest of the test in dir, where reloadConfig looks
+// for deploy.config
+func chdirForTest(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestReloadConfig_WhileRequestsAreInFlight(t *testing.T) {
+	dir := t.TempDir()
+	chdirForTest(t, dir)
+	writeReloadTestConfig(t, dir, "secret-0")
+
+	initial, err := loadDeployConfigFile(deployConfigFile)
+	if err != nil {
+		t.Fatalf("loading deploy.config: %v", err)
+	}
+	currentConfig.Store(initial)
+	secretRedactor = secrets.NewRedactor(nil)
+
+	handler := withForwardedHeaders(requireAPIToken(effectiveConfigHandler))
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				req := httptest.NewRequest(http.MethodGet, "/config", nil)
+				req.RemoteAddr = "10.1.2.3:5000"
+				req.Header.Set("Authorization", "Bearer reload-test-token")
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Errorf("GET /config during reload: %d %s", rec.Code, rec.Body)
+					return
+				}
+				gitCredentialArgs()
+			}
+		}()
+	}
+
+	for i := 1; i <= 20; i++ {
+		writeReloadTestConfig(t, dir, fmt.Sprintf("secret-%d", i))
+		if _, err := reloadConfig(); err != nil {
+			t.Errorf("reload %d: %v", i, err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if appConfig().Secret != "secret-20" {
+		t.Errorf("Expected the last reloaded secret, got %q", appConfig().Secret)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"binaryDeploy/notify"
+)
+
+// configSyncStatus is the state of config repository syncing, served by
+// /config/sync
+type configSyncStatus struct {
+	Repo            string     `json:"repo"`
+	Branch          string     `json:"branch"`
+	File            string     `json:"file"`
+	Commit          string     `json:"commit,omitempty"` // Last commit applied
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+	RestartRequired []string   `json:"restart_required,omitempty"`
+	Rejected        string     `json:"rejected_commit,omitempty"` // Not retried until the branch moves on
+	LastError       string     `json:"last_error,omitempty"`
+	LastSync        time.Time  `json:"last_sync,omitempty"`
+}
+
+var (
+	configSync struct {
+		sync.Mutex
+		status configSyncStatus
+	}
+
+	// configSyncRequests wakes the sync loop, e.g. for a webhook push
+	configSyncRequests = make(chan struct{}, 1)
+)
+
+// configRepoName is the config repository checkout in the private state
+// directory. The checkout holds the full deploy.config with its secrets, so
+// it must stay out of deploy_dir, which the file browser serves.
+const configRepoName = "config-repo"
+
+// configRepoDir is where the config repository is checked out
+func configRepoDir() string {
+	return filepath.Join(privateStateDir(), configRepoName)
+}
+
+// startConfigRepoSync keeps deploy.config in sync with config_repo_url,
+// fetching every config_repo_poll seconds and whenever a webhook reports a
+// push to it. It returns immediately when no config repository is set.
+func startConfigRepoSync() {
+	if appConfig().ConfigRepoURL == "" {
+		return
+	}
+
+	go func() {
+		for {
+			if appConfig().ConfigRepoURL == "" {
+				slog.Warn("config_repo_url was removed from the configuration, config repository syncing stopped")
+				return
+			}
+			// Rejections were already logged and notified
+			if err := syncConfigRepo(); err != nil && !errors.Is(err, errConfigRejected) {
+				slog.Error("Config repository sync failed", "repo_url", appConfig().ConfigRepoURL, "error", err)
+			}
+
+			var poll <-chan time.Time
+			if appConfig().ConfigRepoPoll > 0 {
+				poll = time.After(time.Duration(appConfig().ConfigRepoPoll) * time.Second)
+			}
+			select {
+			case <-poll:
+			case <-configSyncRequests:
+			}
+		}
+	}()
+	slog.Info("Syncing configuration from config repository", "repo_url", appConfig().ConfigRepoURL,
+		"branch", appConfig().ConfigRepoBranch, "file", appConfig().ConfigRepoFile, "poll_seconds", appConfig().ConfigRepoPoll)
+}
+
+// requestConfigSync asks the sync loop to fetch the config repository now
+func requestConfigSync() {
+	select {
+	case configSyncRequests <- struct{}{}:
+	default:
+	}
+}
+
+// errConfigRejected is returned when the config repository holds a
+// configuration that fails validation
+var errConfigRejected = errors.New("configuration rejected")
+
+// syncConfigRepo fetches the config repository and applies its
+// configuration if the branch moved to a commit not seen before
+func syncConfigRepo() error {
+	configSync.Lock()
+	defer configSync.Unlock()
+
+	repoURL, branch, file := appConfig().ConfigRepoURL, appConfig().ConfigRepoBranch, appConfig().ConfigRepoFile
+	status := &configSync.status
+	status.Repo, status.Branch, status.File = repoURL, branch, file
+	status.LastSync = time.Now()
+
+	if err := fetchConfigRepo(repoURL, branch); err != nil {
+		status.LastError = err.Error()
+		return err
+	}
+	commit := gitHeadCommit(configRepoDir())
+	if commit == "" {
+		status.LastError = "config repository has no HEAD commit"
+		return errors.New(status.LastError)
+	}
+	if commit == status.Commit || commit == status.Rejected {
+		return nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(configRepoDir(), file))
+	if err != nil {
+		return rejectConfig(commit, fmt.Errorf("reading %s: %w", file, err))
+	}
+	current, err := os.ReadFile(deployConfigFile)
+	if err != nil {
+		status.LastError = err.Error()
+		return err
+	}
+	if bytes.Equal(content, current) {
+		// Already running this configuration, e.g. after a restart
+		status.Commit, status.Rejected, status.LastError = commit, "", ""
+		return nil
+	}
+
+	restartRequired, err := applyConfig(content, current)
+	if err != nil {
+		return rejectConfig(commit, err)
+	}
+
+	status.Commit, status.Rejected, status.LastError = commit, "", ""
+	appliedAt := time.Now()
+	status.AppliedAt = &appliedAt
+	status.RestartRequired = restartRequired
+	slog.Info("Applied configuration from config repository", "commit", shortSHA(commit), "restart_required", restartRequired)
+	if appConfig().ConfigRepoURL == "" {
+		slog.Warn("Applied configuration has no config_repo_url, later changes to the config repository are ignored")
+	}
+
+	message := fmt.Sprintf("Applied configuration %s from %s", shortSHA(commit), repoURL)
+	if len(restartRequired) > 0 {
+		message += fmt.Sprintf("; restart binaryDeploy for %v to take effect", restartRequired)
+	}
+	notifier().Notify(notify.Event{
+		Type:    notify.EventConfigApplied,
+		App:     appConfig().AppName,
+		Message: message,
+		Fields: map[string]interface{}{
+			"repo":             repoURL,
+			"commit":           commit,
+			"restart_required": restartRequired,
+		},
+	})
+	return nil
+}
+
+// fetchConfigRepo clones the config repository or updates the checkout to
+// the tip of branch
+func fetchConfigRepo(repoURL, branch string) error {
+	dir, err := privateStatePath(configRepoName)
+	if err != nil {
+		return fmt.Errorf("config repository: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := runCommandInDir("", "git", gitCommand(repoURL, "clone", "--branch", branch, repoURL, dir)...); err != nil {
+			return fmt.Errorf("cloning config repository: %w", err)
+		}
+		return nil
+	}
+
+	if err := runCommandInDir(dir, "git", "remote", "set-url", "origin", repoURL); err != nil {
+		return fmt.Errorf("setting config repository remote: %w", err)
+	}
+	if err := runCommandInDir(dir, "git", gitCommand(repoURL, "fetch", "origin", branch)...); err != nil {
+		return fmt.Errorf("fetching config repository: %w", err)
+	}
+	if err := runCommandInDir(dir, "git", "reset", "--hard", "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("updating config repository checkout: %w", err)
+	}
+	return nil
+}
+
+// applyConfig validates content and makes it the running deploy.config. The
+// previous file is kept as deploy.config.previous, and put back if the new
+// one can't be loaded.
+func applyConfig(content, previous []byte) (restartRequired []string, err error) {
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(deployConfigFile); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	incoming := deployConfigFile + ".incoming"
+	if err := os.WriteFile(incoming, content, mode); err != nil {
+		return nil, fmt.Errorf("writing %s: %w", incoming, err)
+	}
+	if _, err := loadDeployConfigFile(incoming); err != nil {
+		os.Remove(incoming)
+		return nil, err
+	}
+
+	if err := os.WriteFile(deployConfigFile+".previous", previous, mode); err != nil {
+		os.Remove(incoming)
+		return nil, fmt.Errorf("keeping the previous configuration: %w", err)
+	}
+	if err := os.Rename(incoming, deployConfigFile); err != nil {
+		os.Remove(incoming)
+		return nil, fmt.Errorf("replacing %s: %w", deployConfigFile, err)
+	}
+
+	restartRequired, err = reloadConfig()
+	if err != nil {
+		slog.Error("Reloading the new configuration failed, rolling back", "error", err)
+		if restoreErr := os.WriteFile(deployConfigFile, previous, mode); restoreErr != nil {
+			slog.Error("Failed to restore the previous configuration", "error", restoreErr)
+		}
+		return nil, err
+	}
+	return restartRequired, nil
+}
+
+// rejectConfig records that commit holds a configuration that can't be
+// applied and tells operators. The running configuration stays in place.
+// Called with configSync held.
+func rejectConfig(commit string, err error) error {
+	status := &configSync.status
+	status.Rejected = commit
+	status.LastError = err.Error()
+
+	slog.Error("Rejected configuration from config repository, keeping the running one", "commit", shortSHA(commit), "error", err)
+	notifier().Notify(notify.Event{
+		Type:    notify.EventConfigRejected,
+		App:     appConfig().AppName,
+		Message: fmt.Sprintf("Rejected configuration %s from %s: %v", shortSHA(commit), status.Repo, err),
+		Fields: map[string]interface{}{
+			"repo":   status.Repo,
+			"commit": commit,
+			"error":  err.Error(),
+		},
+	})
+	return fmt.Errorf("%w: %v", errConfigRejected, err)
+}
+
+// configRepoPushHandled handles a webhook push to the config repository,
+// returning false for pushes to any other repository
+func configRepoPushHandled(w http.ResponseWriter, r *http.Request, repoURL, ref string) bool {
+	if appConfig().ConfigRepoURL == "" || repoURL != appConfig().ConfigRepoURL {
+		return false
+	}
+	if branch := extractBranchFromRef(ref); branch != appConfig().ConfigRepoBranch {
+		slog.InfoContext(r.Context(), "Push is not to the config branch", "ref", ref, "config_repo_branch", appConfig().ConfigRepoBranch)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Ref %s is not the config branch", ref)
+		return true
+	}
+	slog.InfoContext(r.Context(), "Config repository push, syncing configuration", "ref", ref)
+	requestConfigSync()
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Config sync triggered for %s", repoURL)
+	return true
+}
+
+// configSyncHandler serves /config/sync: GET reports the sync state, POST
+// syncs now and reports the result
+func configSyncHandler(w http.ResponseWriter, r *http.Request) {
+	if appConfig().ConfigRepoURL == "" {
+		http.Error(w, "Config repository syncing is not configured", http.StatusNotFound)
+		return
+	}
+
+	code := http.StatusOK
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		slog.InfoContext(r.Context(), "Config sync requested", "remote_addr", requestSource(r))
+		if err := syncConfigRepo(); errors.Is(err, errConfigRejected) {
+			code = http.StatusUnprocessableEntity
+		} else if err != nil {
+			code = http.StatusBadGateway
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configSync.Lock()
+	status := configSync.status
+	configSync.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
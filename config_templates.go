@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"binaryDeploy/config"
+	"binaryDeploy/processmanager"
+	"binaryDeploy/templating"
+)
+
+// templateData is what config_templates and the placeholders of
+// build_command and run_command refer to, for a release of commit running in
+// releaseDir
+func templateData(branch, commit, releaseDir string) templating.Data {
+	return templating.Data{
+		App:         appConfig().AppName,
+		Environment: appConfig().Environment,
+		Branch:      branch,
+		Commit:      commit,
+		Port:        appConfig().ApplicationPort,
+		ReleaseDir:  releaseDir,
+		Vars:        appConfig().TemplateVars,
+	}
+}
+
+// renderConfigTemplates renders the configured config_templates into dir,
+// the working directory of the release being deployed
+func renderConfigTemplates(req DeployRequest, commit, dir string) error {
+	files, err := templating.ParseFiles(appConfig().ConfigTemplates)
+	if err != nil {
+		return fmt.Errorf("invalid config_templates: %w", err)
+	}
+
+	slog.Info("Rendering configuration templates", "count", len(files), "dir", dir)
+	if err := templating.Render(dir, files, templateData(req.Branch, commit, dir)); err != nil {
+		return fmt.Errorf("config templating failed: %w", err)
+	}
+	return nil
+}
+
+// renderedConfigFile reports whether path, relative to the deploy directory,
+// is a config file rendered from config_templates in one of the releases
+func renderedConfigFile(path string) bool {
+	files, _ := templating.ParseFiles(appConfig().ConfigTemplates)
+	for _, file := range files {
+		dest := filepath.Clean(file.Dest)
+		if path == dest || strings.HasSuffix(path, string(filepath.Separator)+dest) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildCommand is build_command with its placeholders resolved
+func buildCommand(data templating.Data) (string, error) {
+	command, err := templating.Command(appConfig().BuildCommand, data)
+	if err != nil {
+		return "", fmt.Errorf("invalid build_command: %w", err)
+	}
+	return command, nil
+}
+
+// instanceConfig returns deployConfig with the placeholders of run_command
+// resolved for instance i, whose {{ .Port }} is the instance's own port
+func instanceConfig(deployConfig *config.DeployConfig, i int, data templating.Data) (*config.DeployConfig, error) {
+	data.Port = processmanager.InstancePort(deployConfig, i)
+	command, err := templating.Command(deployConfig.RunCommand, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid run_command: %w", err)
+	}
+	if command == deployConfig.RunCommand {
+		return deployConfig, nil
+	}
+	cfg := *deployConfig
+	cfg.RunCommand = command
+	return &cfg, nil
+}
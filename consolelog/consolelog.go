@@ -0,0 +1,183 @@
+// Package consolelog is a slog handler writing one human-readable line per
+// record, for watching binaryDeploy in a terminal during interactive or
+// development runs
+package consolelog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ANSI colors per level
+const (
+	colorReset = "\033[0m"
+	colorDim   = "\033[2m"
+	colorDebug = "\033[90m"
+	colorInfo  = "\033[32m"
+	colorWarn  = "\033[33m"
+	colorError = "\033[31m"
+)
+
+// Options configures a Handler
+type Options struct {
+	// Level is the minimum level written; defaults to slog.LevelInfo
+	Level slog.Leveler
+	// Color highlights levels and dims attribute keys with ANSI escapes
+	Color bool
+}
+
+// Handler formats records as
+//
+//	15:04:05.000 INFO  Deployment finished commit=1a2b3c4d duration=2.4s
+type Handler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	opts   Options
+	attrs  []byte // Preformatted attributes from WithAttrs
+	prefix string // Group prefix for attribute keys, "" or ending in "."
+}
+
+// NewHandler returns a handler writing to w
+func NewHandler(w io.Writer, opts *Options) *Handler {
+	h := &Handler{mu: &sync.Mutex{}, w: w}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Enabled reports whether records at level are written
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle writes r as one line
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	buf := make([]byte, 0, 256)
+	if !r.Time.IsZero() {
+		buf = r.Time.AppendFormat(buf, "15:04:05.000")
+		buf = append(buf, ' ')
+	}
+	buf = h.appendLevel(buf, r.Level)
+	buf = append(buf, ' ')
+	buf = append(buf, r.Message...)
+	buf = append(buf, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		buf = h.appendAttr(buf, h.prefix, a)
+		return true
+	})
+	buf = append(buf, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+// WithAttrs returns a handler that adds attrs to every record
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]byte(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = h.appendAttr(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+// WithGroup returns a handler that qualifies later attribute keys with name
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+func (h *Handler) appendLevel(buf []byte, level slog.Level) []byte {
+	label := level.String()
+	color := colorInfo
+	switch {
+	case level >= slog.LevelError:
+		color = colorError
+	case level >= slog.LevelWarn:
+		color = colorWarn
+	case level < slog.LevelInfo:
+		color = colorDebug
+	}
+	if h.opts.Color {
+		buf = append(buf, color...)
+	}
+	buf = append(buf, label...)
+	if h.opts.Color {
+		buf = append(buf, colorReset...)
+	}
+	// Pad so messages line up whatever the level
+	for i := len(label); i < 5; i++ {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+func (h *Handler) appendAttr(buf []byte, prefix string, a slog.Attr) []byte {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return buf
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			buf = h.appendAttr(buf, prefix, ga)
+		}
+		return buf
+	}
+
+	buf = append(buf, ' ')
+	if h.opts.Color {
+		buf = append(buf, colorDim...)
+	}
+	buf = append(buf, prefix...)
+	buf = append(buf, a.Key...)
+	buf = append(buf, '=')
+	if h.opts.Color {
+		buf = append(buf, colorReset...)
+	}
+	return appendValue(buf, a.Value)
+}
+
+func appendValue(buf []byte, v slog.Value) []byte {
+	var s string
+	switch v.Kind() {
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339)
+	case slog.KindDuration:
+		s = v.Duration().String()
+	default:
+		s = v.String()
+	}
+	if needsQuoting(s) {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) >= 0
+}
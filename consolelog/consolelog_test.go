@@ -0,0 +1,55 @@
+package consolelog
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_Format(t *testing.T) {
+	var out strings.Builder
+	logger := slog.New(NewHandler(&out, nil))
+
+	logger.Info("Deployment finished", "commit", "1a2b3c4d", "duration", 2400*time.Millisecond, "error", "exit status 1")
+	line := out.String()
+
+	if !strings.HasSuffix(line, "INFO  Deployment finished commit=1a2b3c4d duration=2.4s error=\"exit status 1\"\n") {
+		t.Errorf("unexpected line %q", line)
+	}
+	if _, err := time.Parse("15:04:05.000", line[:12]); err != nil {
+		t.Errorf("expected a leading timestamp, got %q", line)
+	}
+}
+
+func TestHandler_GroupsAndAttrs(t *testing.T) {
+	var out strings.Builder
+	logger := slog.New(NewHandler(&out, nil)).With("app", "api").WithGroup("req")
+
+	logger.Warn("Slow", "path", "/deploy", slog.Group("client", "ip", "10.0.0.1"))
+	if !strings.HasSuffix(out.String(), "WARN  Slow app=api req.path=/deploy req.client.ip=10.0.0.1\n") {
+		t.Errorf("unexpected line %q", out.String())
+	}
+}
+
+func TestHandler_Level(t *testing.T) {
+	var out strings.Builder
+	logger := slog.New(NewHandler(&out, &Options{Level: slog.LevelWarn}))
+
+	logger.Info("hidden")
+	logger.Error("shown", "empty", "")
+	if strings.Contains(out.String(), "hidden") {
+		t.Errorf("expected info records to be dropped, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "ERROR shown empty=\"\"") {
+		t.Errorf("expected the error record, got %q", out.String())
+	}
+}
+
+func TestHandler_Color(t *testing.T) {
+	var out strings.Builder
+	slog.New(NewHandler(&out, &Options{Color: true})).Error("failed")
+	if !strings.Contains(out.String(), colorError+"ERROR"+colorReset) {
+		t.Errorf("expected a colored level, got %q", out.String())
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+
+	"binaryDeploy/history"
+)
+
+// correlationHeader carries the correlation ID of a flow: returned on
+// responses, accepted from callers that already have one (along with
+// X-Request-ID) and passed to downstream instances of a deployment chain
+const correlationHeader = "X-Correlation-ID"
+
+var correlationIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+type correlationKey struct{}
+
+// activeCorrelationID is the correlation ID of the deployment the queue
+// worker is running. Deployments run one at a time, so everything logged
+// meanwhile is attributed to it unless the context says otherwise.
+var activeCorrelationID atomic.Value
+
+// withCorrelationID assigns the request a correlation ID, taken from the
+// caller or generated, and returns it in the response headers. Log with
+// slog.*Context(r.Context(), ...) to tag entries with it.
+func withCorrelationID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(correlationHeader)
+		if !correlationIDPattern.MatchString(id) {
+			id = r.Header.Get("X-Request-ID")
+		}
+		if !correlationIDPattern.MatchString(id) {
+			id = history.NewID()
+		}
+		w.Header().Set(correlationHeader, id)
+		next(w, r.WithContext(context.WithValue(r.Context(), correlationKey{}, id)))
+	}
+}
+
+// requestCorrelationID returns the correlation ID withCorrelationID gave r
+func requestCorrelationID(r *http.Request) string {
+	id, _ := r.Context().Value(correlationKey{}).(string)
+	return id
+}
+
+// logCorrelationID returns the correlation ID a log entry belongs to: the
+// request's, else the running deployment's
+func logCorrelationID(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(correlationKey{}).(string); ok {
+			return id
+		}
+	}
+	id, _ := activeCorrelationID.Load().(string)
+	return id
+}
+
+// runWithCorrelationID runs a deployment with its correlation ID active,
+// generating one for triggers that don't come with a request
+func runWithCorrelationID(req *DeployRequest, run func() error) error {
+	if req.CorrelationID == "" {
+		req.CorrelationID = history.NewID()
+	}
+	activeCorrelationID.Store(req.CorrelationID)
+	defer activeCorrelationID.Store("")
+	return run()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithCorrelationID(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string // Empty for a generated ID
+	}{
+		{"caller's correlation ID", map[string]string{correlationHeader: "deploy-42", "X-Request-ID": "req-1"}, "deploy-42"},
+		{"request ID", map[string]string{"X-Request-ID": "req-1"}, "req-1"},
+		{"none", nil, ""},
+		{"invalid", map[string]string{correlationHeader: "a b\r\nInjected: yes"}, ""},
+		{"too long", map[string]string{correlationHeader: strings.Repeat("a", 65)}, ""},
+	}
+	for _, tt := range tests {
+		var seen string
+		handler := withCorrelationID(func(w http.ResponseWriter, r *http.Request) {
+			seen = requestCorrelationID(r)
+		})
+		req := httptest.NewRequest(http.MethodPost, "/deploy", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		returned := rec.Header().Get(correlationHeader)
+		if returned == "" || returned != seen {
+			t.Errorf("%s: expected the handler's ID %q in the response, got %q", tt.name, seen, returned)
+		}
+		if tt.want != "" && seen != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, seen)
+		}
+		if tt.want == "" && !correlationIDPattern.MatchString(seen) {
+			t.Errorf("%s: expected a generated ID, got %q", tt.name, seen)
+		}
+	}
+}
+
+func TestLogCorrelationID_TagsLogEntries(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(NewLogStreamer(slog.NewJSONHandler(&out, nil), 10, 0, nil))
+
+	ctx := context.WithValue(context.Background(), correlationKey{}, "webhook-7")
+	logger.InfoContext(ctx, "Webhook received")
+
+	req := DeployRequest{}
+	runWithCorrelationID(&req, func() error {
+		logger.Info("Building")
+		return nil
+	})
+	logger.Info("Idle")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 log lines, got %q", out.String())
+	}
+	if !strings.Contains(lines[0], `"correlation_id":"webhook-7"`) {
+		t.Errorf("Expected the request's correlation ID, got %s", lines[0])
+	}
+	if req.CorrelationID == "" || !strings.Contains(lines[1], `"correlation_id":"`+req.CorrelationID+`"`) {
+		t.Errorf("Expected the deployment's correlation ID %q, got %s", req.CorrelationID, lines[1])
+	}
+	if strings.Contains(lines[2], "correlation_id") {
+		t.Errorf("Expected no correlation ID once the deployment finished, got %s", lines[2])
+	}
+}
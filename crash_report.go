@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"binaryDeploy/notify"
+	"binaryDeploy/processmanager"
+)
+
+// setupCrashReporting redacts secrets from captured application output and
+// notifies operators whenever the application crashes
+func setupCrashReporting() {
+	processManager.SetOutputFilter(secretRedactor.Redact)
+	processManager.SetExitHandler(reportProcessExit)
+
+	if appConfig().CoreDumps {
+		checkCorePattern()
+	}
+}
+
+// checkCorePattern warns when the kernel sends core dumps somewhere other
+// than the crashing process's working directory, where they are collected
+func checkCorePattern() {
+	data, err := os.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		slog.Warn("Cannot read core_pattern, core dumps may not be collected", "error", err)
+		return
+	}
+	pattern := strings.TrimSpace(string(data))
+	switch {
+	case strings.HasPrefix(pattern, "|"):
+		slog.Warn("Core dumps are piped to another handler and won't be collected; set kernel.core_pattern=core to keep them",
+			"core_pattern", pattern)
+	case strings.Contains(pattern, "/"):
+		slog.Warn("Core dumps are written outside the app's working directory and won't be collected; set kernel.core_pattern=core to keep them",
+			"core_pattern", pattern)
+	default:
+		slog.Info("Core dumps enabled", "dir", processmanager.CoreDumpDir(appConfig()), "keep", appConfig().CoreDumpsKeep)
+	}
+}
+
+// reportProcessExit logs and notifies about a process that exited on its own
+// and escalates crash loops
+func reportProcessExit(event processmanager.Event) {
+	if event.Type != processmanager.EventCrashed {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"instance": event.Instance,
+		"pid":      event.PID,
+		"uptime":   event.Uptime,
+	}
+	if event.ExitCode != nil {
+		fields["exit_code"] = *event.ExitCode
+	}
+	if event.Signal != "" {
+		fields["signal"] = event.Signal
+	}
+	if event.OOMKilled {
+		fields["oom_killed"] = true
+	}
+	if event.Reason != "" {
+		fields["reason"] = event.Reason
+	}
+	if len(event.Output) > 0 {
+		fields["output"] = event.Output
+	}
+	if event.CoreDump != "" {
+		fields["core_dump"] = event.CoreDump
+	}
+
+	slog.Error("Application crashed", "app", appConfig().AppName, "instance", event.Instance, "pid", event.PID,
+		"exit_code", event.ExitCode, "signal", event.Signal, "oom_killed", event.OOMKilled, "reason", event.Reason)
+	notifier().Notify(notify.Event{
+		Type:    notify.EventProcessCrashed,
+		App:     appConfig().AppName,
+		Message: fmt.Sprintf("%s crashed: %s", appConfig().AppName, crashSummary(event)),
+		Fields:  fields,
+	})
+	// Don't hold up the automatic restart while the on-call service answers
+	go escalateCrash(event)
+}
+
+// crashSummary describes how a process ended in a few words
+func crashSummary(event processmanager.Event) string {
+	switch {
+	case event.OOMKilled:
+		return "killed by the OOM killer"
+	case event.Signal != "":
+		return "terminated by signal " + event.Signal
+	case event.ExitCode != nil:
+		return fmt.Sprintf("exit code %d", *event.ExitCode)
+	default:
+		return event.Reason
+	}
+}
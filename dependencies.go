@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"binaryDeploy/health"
+	"binaryDeploy/history"
+	"binaryDeploy/notify"
+)
+
+// dependencyCheckTimeout bounds a single dependency check
+const dependencyCheckTimeout = 5 * time.Second
+
+// errDependenciesUnhealthy is returned when the dependency gate stops a
+// deployment
+var errDependenciesUnhealthy = errors.New("critical dependencies are unhealthy")
+
+// dependencyMonitor polls the app's external dependencies; nil when none are
+// configured
+var dependencyMonitor *health.DependencyMonitor
+
+// setupDependencyChecks starts polling the services listed under
+// dependency_checks.<name>
+func setupDependencyChecks() {
+	if len(appConfig().DependencyChecks) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(appConfig().DependencyChecks))
+	for name := range appConfig().DependencyChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	deps := make([]health.Dependency, len(names))
+	for i, name := range names {
+		deps[i] = health.Dependency{Name: name, Target: appConfig().DependencyChecks[name]}
+	}
+
+	interval := time.Duration(appConfig().DependencyCheckInterval) * time.Second
+	dependencyMonitor = health.NewDependencyMonitor(deps, interval, dependencyCheckTimeout, reportDependencyChange)
+	go dependencyMonitor.Run(context.Background())
+
+	slog.Info("Dependency checks enabled", "app", appConfig().AppName, "dependencies", names, "interval", interval.String())
+}
+
+// reportDependencyChange logs and notifies when a dependency goes down or
+// recovers
+func reportDependencyChange(s health.DependencyStatus) {
+	eventType := notify.EventDependencyUp
+	message := fmt.Sprintf("Dependency %s of %s is reachable again", s.Name, appConfig().AppName)
+	if s.Healthy {
+		slog.Info("Dependency recovered", "app", appConfig().AppName, "dependency", s.Name, "target", s.Target)
+	} else {
+		eventType = notify.EventDependencyDown
+		message = fmt.Sprintf("Dependency %s of %s is down: %s", s.Name, appConfig().AppName, s.Error)
+		slog.Warn("Dependency is down", "app", appConfig().AppName, "dependency", s.Name, "target", s.Target, "error", s.Error)
+	}
+
+	notifier().Notify(notify.Event{
+		Type:    eventType,
+		App:     appConfig().AppName,
+		Message: message,
+		Fields: map[string]interface{}{
+			"dependency": s.Name,
+			"target":     s.Target,
+			"error":      s.Error,
+			"since":      s.Since,
+		},
+	})
+}
+
+// appDependenciesHandler returns the latest status of every dependency
+func appDependenciesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := []health.DependencyStatus{}
+	if dependencyMonitor != nil {
+		statuses = dependencyMonitor.Statuses()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"app":          appConfig().AppName,
+		"dependencies": statuses,
+	})
+}
+
+// dependencyGateEnabled reports whether deployments wait for or are rejected
+// by unhealthy critical dependencies
+func dependencyGateEnabled() bool {
+	return dependencyMonitor != nil && len(appConfig().CriticalDependencies) > 0
+}
+
+// dependencyOverride reports whether the caller asked to deploy despite
+// unhealthy critical dependencies and is allowed to
+func dependencyOverride(r *http.Request) bool {
+	if r.URL.Query().Get("override_dependencies") != "true" {
+		return false
+	}
+	if !hasValidAPIToken(r) {
+		slog.Warn("Dependency override requested without a valid api_token", "remote_addr", r.RemoteAddr)
+		recordAuthFailure(r, "invalid api_token for dependency override")
+		return false
+	}
+	return true
+}
+
+// dependencyGate re-checks the critical dependencies before req deploys. If
+// any is down the deployment is rejected, or with dependency_gate_mode=wait,
+// held until they recover or dependency_gate_timeout passes. Overridden
+// deployments go ahead and are tagged in history.
+func dependencyGate(req *DeployRequest) error {
+	dependencyMonitor.CheckAll(context.Background())
+	down := dependencyMonitor.Unhealthy(appConfig().CriticalDependencies)
+	if len(down) == 0 {
+		return nil
+	}
+
+	if req.OverrideDependencies {
+		slog.Warn("Deploying despite unhealthy critical dependencies", "dependencies", describeDependencies(down))
+		req.Tags = append(req.Tags, history.TagDependencyOverride)
+		return nil
+	}
+
+	if appConfig().DependencyGateMode == "wait" {
+		timeout := time.Duration(appConfig().DependencyGateTimeout) * time.Second
+		deadline := time.Now().Add(timeout)
+		poll := time.Duration(appConfig().DependencyCheckInterval) * time.Second
+		if poll > 5*time.Second {
+			poll = 5 * time.Second
+		}
+
+		slog.Warn("Waiting for critical dependencies before deploying",
+			"dependencies", describeDependencies(down), "timeout", timeout.String())
+		for len(down) > 0 && time.Now().Before(deadline) {
+			time.Sleep(poll)
+			dependencyMonitor.CheckAll(context.Background())
+			down = dependencyMonitor.Unhealthy(appConfig().CriticalDependencies)
+		}
+		if len(down) == 0 {
+			slog.Info("Critical dependencies recovered, continuing deployment")
+			return nil
+		}
+		return fmt.Errorf("%w after waiting %s: %s; pass override_dependencies=true with the api_token to deploy anyway",
+			errDependenciesUnhealthy, timeout, describeDependencies(down))
+	}
+
+	slog.Warn("Deployment rejected, critical dependencies are unhealthy", "dependencies", describeDependencies(down))
+	return fmt.Errorf("%w: %s; pass override_dependencies=true with the api_token to deploy anyway",
+		errDependenciesUnhealthy, describeDependencies(down))
+}
+
+// describeDependencies lists unhealthy dependencies with their errors
+func describeDependencies(statuses []health.DependencyStatus) string {
+	parts := make([]string, len(statuses))
+	for i, s := range statuses {
+		parts[i] = fmt.Sprintf("%s (%s)", s.Name, s.Error)
+	}
+	return strings.Join(parts, ", ")
+}
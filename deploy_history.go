@@ -0,0 +1,115 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"binaryDeploy/history"
+)
+
+var deployHistory *history.Store
+
+// setupHistory opens the deployment history kept in the data store
+func setupHistory() {
+	if dataStore == nil {
+		return
+	}
+	store, err := history.NewStore(dataStore)
+	if err != nil {
+		slog.Error("Failed to open deployment history, history disabled", "error", err)
+		return
+	}
+	deployHistory = store
+}
+
+// finishDeployment runs any chained deployments and records the outcome of a
+// target deployment that started at startedAt. Failures get a post-mortem
+// bundle including buildOutput.
+func finishDeployment(req DeployRequest, commit string, startedAt time.Time, profile *deployProfile, buildOutput []byte, err error) {
+	rec := history.Record{
+		App:         appConfig().AppName,
+		Kind:        history.KindDeploy,
+		Trigger:     req.Trigger,
+		TriggeredBy: req.TriggeredBy,
+		Repo:        req.RepoURL,
+		Branch:      req.Branch,
+		Commit:      commit,
+		Version:     req.GitTag,
+		Result:      history.ResultSuccess,
+		StartedAt:   startedAt,
+		FinishedAt:  time.Now(),
+		Chain:       req.Chain,
+		Steps:       profile.Steps(),
+		Tags:        uniqueTags(req.Tags),
+		Issues:      req.Issues,
+
+		CorrelationID: req.CorrelationID,
+	}
+	if req.Artifact != "" {
+		rec.Artifact = filepath.Base(req.Artifact)
+	}
+	profile.logSummary(rec.FinishedAt.Sub(startedAt))
+	if !req.IsRestart() && appConfig().ReleaseNotes {
+		rec.ReleaseNotes = buildReleaseNotes(targetRepoDir(), commit)
+	}
+
+	if err != nil {
+		rec.Result = history.ResultFailure
+		rec.Error = err.Error()
+		rec.ID = history.NewID()
+		if appConfig().PostmortemKeep > 0 {
+			bundle, bundleErr := writePostmortemBundle(rec, buildOutput)
+			if bundleErr != nil {
+				slog.Error("Failed to write post-mortem bundle", "error", bundleErr)
+			} else {
+				rec.Bundle = bundle
+				slog.Info("Wrote post-mortem bundle", "path", filepath.Join(appConfig().DeployDir, bundle))
+			}
+		}
+	}
+
+	// A restart doesn't change what downstream apps depend on
+	if req.IsRestart() {
+		rec.Kind = history.KindRestart
+	} else {
+		rec.Downstream = runDeployChain(req.Chain, req.CorrelationID, err == nil)
+		if err == nil {
+			checkDurationRegression(rec)
+			autoTagRelease(&rec)
+		}
+	}
+	recordDeployment(rec)
+
+	if err != nil {
+		notifyDeploymentFailed(rec)
+	} else if rec.Kind == history.KindDeploy {
+		notifyDeploymentSucceeded(rec)
+		commentOnIssues(rec)
+	}
+	escalateDeployment(rec)
+	reportRepeatedFailures(rec, buildOutput)
+}
+
+// recordDeployment appends rec to the deployment history
+func recordDeployment(rec history.Record) {
+	if deployHistory == nil {
+		return
+	}
+	if err := deployHistory.Append(rec); err != nil {
+		slog.Error("Failed to record deployment history", "error", err)
+	}
+}
+
+// uniqueTags drops repeated tags, keeping the first occurrence of each
+func uniqueTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	return out
+}
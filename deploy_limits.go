@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/notify"
+)
+
+// diskQuotaInterval is how often the janitor measures disk usage against
+// disk_quota_mb
+const diskQuotaInterval = 5 * time.Minute
+
+// Limit names, as reported in limit_exceeded events and /status
+const (
+	limitConcurrentDeploys = "max_concurrent_deploys"
+	limitDeploysPerHour    = "deploys_per_hour"
+	limitDiskQuota         = "disk_quota_mb"
+)
+
+// errLimitExceeded is wrapped by the errors of deployments rejected by a limit
+var errLimitExceeded = errors.New("limit exceeded")
+
+// deployLimits tracks usage against appConfig().Limits
+var deployLimits = struct {
+	sync.Mutex
+	inFlight    int         // Deployments queued or running
+	started     []time.Time // Deployments admitted within the last hour
+	diskUsageMB int
+	overQuota   bool
+	exceeded    map[string]int // Rejections per limit since startup
+}{exceeded: make(map[string]int)}
+
+// setupDeployLimits seeds the hourly deployment count from history, so a
+// restart doesn't reset deploys_per_hour
+func setupDeployLimits() {
+	if appConfig().Limits.DeploysPerHour == 0 || deployHistory == nil {
+		return
+	}
+	since := time.Now().Add(-time.Hour)
+	recent, err := deployHistory.Query(0, func(rec history.Record) bool {
+		return rec.Kind == history.KindDeploy && rec.StartedAt.After(since) && !slices.Contains(rec.Tags, history.TagAutoStart)
+	})
+	if err != nil {
+		slog.Error("Failed to read recent deployments, deploys_per_hour starts from zero", "error", err)
+		return
+	}
+	// Oldest first, like admitDeployment appends them
+	for i := len(recent) - 1; i >= 0; i-- {
+		deployLimits.started = append(deployLimits.started, recent[i].StartedAt)
+	}
+}
+
+// admitDeployment checks req against the deployment limits and counts it
+// as in flight until release is called. Auto-start deployments are never
+// rejected so the application comes back after a restart.
+func admitDeployment(req DeployRequest) (release func(), err error) {
+	limits := appConfig().Limits
+	now := time.Now()
+
+	deployLimits.Lock()
+	cutoff := now.Add(-time.Hour)
+	deployLimits.started = slices.DeleteFunc(deployLimits.started, func(t time.Time) bool { return t.Before(cutoff) })
+
+	if !slices.Contains(req.Tags, history.TagAutoStart) {
+		var limit, message string
+		switch {
+		case limits.MaxConcurrentDeploys > 0 && deployLimits.inFlight >= limits.MaxConcurrentDeploys:
+			limit = limitConcurrentDeploys
+			message = fmt.Sprintf("%d deployments are already queued or running (max_concurrent_deploys=%d)", deployLimits.inFlight, limits.MaxConcurrentDeploys)
+		case limits.DeploysPerHour > 0 && len(deployLimits.started) >= limits.DeploysPerHour:
+			limit = limitDeploysPerHour
+			message = fmt.Sprintf("%d deployments were started in the last hour (deploys_per_hour=%d); next allowed at %s",
+				len(deployLimits.started), limits.DeploysPerHour, deployLimits.started[0].Add(time.Hour).Format(time.RFC3339))
+		case limits.DiskQuotaMB > 0 && deployLimits.overQuota:
+			limit = limitDiskQuota
+			message = fmt.Sprintf("disk usage of %d MB exceeds disk_quota_mb=%d", deployLimits.diskUsageMB, limits.DiskQuotaMB)
+		}
+		if limit != "" {
+			deployLimits.exceeded[limit]++
+			deployLimits.Unlock()
+			notifyLimitExceeded(req, limit, message)
+			return nil, fmt.Errorf("%w: %s", errLimitExceeded, message)
+		}
+	}
+
+	deployLimits.inFlight++
+	deployLimits.started = append(deployLimits.started, now)
+	deployLimits.Unlock()
+	return func() {
+		deployLimits.Lock()
+		deployLimits.inFlight--
+		deployLimits.Unlock()
+	}, nil
+}
+
+func notifyLimitExceeded(req DeployRequest, limit, message string) {
+	slog.Warn("Deployment rejected by limit", "limit", limit, "trigger", req.Trigger, "reason", message, "correlation_id", req.CorrelationID)
+	notifier().Notify(notify.Event{
+		Type:    notify.EventLimitExceeded,
+		App:     appConfig().AppName,
+		Message: fmt.Sprintf("%s deployment of %s rejected: %s", req.Trigger, appConfig().AppName, message),
+		Fields: map[string]interface{}{
+			"limit":        limit,
+			"trigger":      req.Trigger,
+			"triggered_by": req.TriggeredBy,
+			"tenant":       tenantName,
+		},
+		CorrelationID: req.CorrelationID,
+	})
+}
+
+// startDiskQuotaJanitor measures the directory binaryDeploy runs from
+// against disk_quota_mb every diskQuotaInterval. When it's over quota the
+// janitor cleans up what it safely can, and deployments are rejected until
+// usage is back under the quota.
+func startDiskQuotaJanitor() {
+	if appConfig().Limits.DiskQuotaMB == 0 {
+		return
+	}
+	go func() {
+		for {
+			checkDiskQuota()
+			time.Sleep(diskQuotaInterval)
+		}
+	}()
+}
+
+// checkDiskQuota runs one janitor pass
+func checkDiskQuota() {
+	quota := appConfig().Limits.DiskQuotaMB
+	usage := int(dirSize(".") >> 20)
+	if usage > quota {
+		slog.Info("Disk usage over quota, cleaning up", "usage_mb", usage, "quota_mb", quota)
+		cleanTempRoot(tempRoot())
+		if _, err := runGitGC(); err != nil && !errors.Is(err, errNoCheckout) {
+			slog.Error("Git gc during quota cleanup failed", "error", err)
+		}
+		usage = int(dirSize(".") >> 20)
+	}
+
+	deployLimits.Lock()
+	wasOver := deployLimits.overQuota
+	deployLimits.diskUsageMB = usage
+	deployLimits.overQuota = usage > quota
+	deployLimits.Unlock()
+
+	switch {
+	case usage > quota && !wasOver:
+		message := fmt.Sprintf("Disk usage of %s is %d MB, over its quota of %d MB; deployments are rejected until space is freed", appConfig().AppName, usage, quota)
+		slog.Error("Disk quota exceeded", "usage_mb", usage, "quota_mb", quota)
+		notifier().Notify(notify.Event{
+			Type:    notify.EventLimitExceeded,
+			App:     appConfig().AppName,
+			Message: message,
+			Fields: map[string]interface{}{
+				"limit":    limitDiskQuota,
+				"usage_mb": usage,
+				"quota_mb": quota,
+				"tenant":   tenantName,
+			},
+		})
+	case usage <= quota && wasOver:
+		slog.Info("Disk usage back under quota, deployments allowed again", "usage_mb", usage, "quota_mb", quota)
+	}
+}
+
+// limitsStatus reports the limits and their usage for /status
+type limitsStatus struct{}
+
+func (limitsStatus) LimitStatus() map[string]interface{} {
+	deployLimits.Lock()
+	defer deployLimits.Unlock()
+
+	cutoff := time.Now().Add(-time.Hour)
+	lastHour := 0
+	for _, t := range deployLimits.started {
+		if t.After(cutoff) {
+			lastHour++
+		}
+	}
+	exceeded := make(map[string]int, len(deployLimits.exceeded))
+	for limit, n := range deployLimits.exceeded {
+		exceeded[limit] = n
+	}
+	return map[string]interface{}{
+		"max_concurrent_deploys": appConfig().Limits.MaxConcurrentDeploys,
+		"deploys_per_hour":       appConfig().Limits.DeploysPerHour,
+		"disk_quota_mb":          appConfig().Limits.DiskQuotaMB,
+		"max_apps":               appConfig().Limits.MaxApps,
+		"deploys_in_flight":      deployLimits.inFlight,
+		"deploys_last_hour":      lastHour,
+		"disk_usage_mb":          deployLimits.diskUsageMB,
+		"exceeded":               exceeded,
+	}
+}
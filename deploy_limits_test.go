@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/history"
+	"binaryDeploy/kv"
+	"binaryDeploy/notify"
+)
+
+// resetDeployLimits clears the usage counted by earlier tests
+func resetDeployLimits(t *testing.T) {
+	t.Helper()
+	deployLimits.Lock()
+	deployLimits.inFlight, deployLimits.started = 0, nil
+	deployLimits.diskUsageMB, deployLimits.overQuota = 0, false
+	deployLimits.exceeded = make(map[string]int)
+	deployLimits.Unlock()
+}
+
+func TestAdmitDeployment_MaxConcurrentDeploys(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp", Limits: config.Limits{MaxConcurrentDeploys: 2}})
+	resetDeployLimits(t)
+	recorder, dispatcher := useRecordingNotifier(t)
+
+	first, err := admitDeployment(DeployRequest{Trigger: "Manual deployment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admitDeployment(DeployRequest{Trigger: "Manual deployment"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admitDeployment(DeployRequest{Trigger: "Webhook deployment"}); !errors.Is(err, errLimitExceeded) {
+		t.Fatalf("Expected a third deployment to be rejected, got %v", err)
+	}
+	if release, err := admitDeployment(DeployRequest{Trigger: "Auto-start", Tags: []string{history.TagAutoStart}}); err != nil {
+		t.Errorf("Expected auto-start deployments never to be rejected, got %v", err)
+	} else {
+		release()
+	}
+
+	first()
+	if _, err := admitDeployment(DeployRequest{Trigger: "Webhook deployment"}); err != nil {
+		t.Errorf("Expected a slot to free up when a deployment finishes, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dispatcher.Wait(ctx)
+	events := recorder.Events()
+	if len(events) != 1 || events[0].Type != notify.EventLimitExceeded || events[0].Fields["limit"] != limitConcurrentDeploys {
+		t.Errorf("Expected one limit_exceeded event for max_concurrent_deploys, got %+v", events)
+	}
+	status := limitsStatus{}.LimitStatus()
+	if exceeded := status["exceeded"].(map[string]int); exceeded[limitConcurrentDeploys] != 1 {
+		t.Errorf("Expected the rejection to be counted in /status, got %v", exceeded)
+	}
+}
+
+func TestAdmitDeployment_DeploysPerHourSurvivesRestarts(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp", Limits: config.Limits{DeploysPerHour: 2}})
+	resetDeployLimits(t)
+	useRecordingNotifier(t)
+	store, err := kv.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployHistory, err = history.NewStore(store); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { deployHistory = nil })
+
+	now := time.Now()
+	for _, rec := range []history.Record{
+		{ID: history.NewID(), Kind: history.KindDeploy, StartedAt: now.Add(-2 * time.Hour)},
+		{ID: history.NewID(), Kind: history.KindDeploy, StartedAt: now.Add(-10 * time.Minute), Tags: []string{history.TagAutoStart}},
+		{ID: history.NewID(), Kind: history.KindDeploy, StartedAt: now.Add(-5 * time.Minute)},
+	} {
+		deployHistory.Append(rec)
+	}
+	setupDeployLimits()
+
+	release, err := admitDeployment(DeployRequest{Trigger: "Manual deployment"})
+	if err != nil {
+		t.Fatalf("Expected one more deployment this hour, got %v", err)
+	}
+	release()
+	if _, err := admitDeployment(DeployRequest{Trigger: "Manual deployment"}); !errors.Is(err, errLimitExceeded) {
+		t.Errorf("Expected deploys_per_hour to count deployments from before the restart, got %v", err)
+	}
+}
+
+func TestAdmitDeployment_DiskQuota(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp", Limits: config.Limits{DiskQuotaMB: 100}})
+	resetDeployLimits(t)
+	useRecordingNotifier(t)
+
+	deployLimits.Lock()
+	deployLimits.diskUsageMB, deployLimits.overQuota = 150, true
+	deployLimits.Unlock()
+	if _, err := admitDeployment(DeployRequest{Trigger: "Manual deployment"}); !errors.Is(err, errLimitExceeded) {
+		t.Errorf("Expected deployments to be rejected over the disk quota, got %v", err)
+	}
+}
+
+func TestTenantLimits_OverrideTheTenantsOwnConfig(t *testing.T) {
+	cfg, err := config.LoadTenantsConfig(writeTenantsConfig(t, `deploys_per_hour=10
+max_apps=2
+tenants.acme.dir=/srv/acme
+tenants.acme.deploys_per_hour=30
+tenants.globex.dir=/srv/globex
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	acme, globex := cfg.Tenants[0], cfg.Tenants[1]
+	if acme.Limits != (config.Limits{DeploysPerHour: 30, MaxApps: 2}) || globex.Limits != (config.Limits{DeploysPerHour: 10, MaxApps: 2}) {
+		t.Fatalf("Expected shared limits with per-tenant overrides, got %+v and %+v", acme.Limits, globex.Limits)
+	}
+
+	previous := tenantName
+	tenantName = "acme"
+	t.Cleanup(func() { tenantName = previous })
+	t.Setenv(tenantListenEnv, "127.0.0.1:20000")
+	t.Setenv(tenantLimitsEnv, acme.Limits.String())
+
+	own := &config.DeployConfig{Limits: config.Limits{DeploysPerHour: 1000, MaxConcurrentDeploys: 3}}
+	if err := applyTenantOverrides(own); err != nil {
+		t.Fatal(err)
+	}
+	if own.Limits != (config.Limits{DeploysPerHour: 30, MaxApps: 2, MaxConcurrentDeploys: 3}) {
+		t.Errorf("Expected the supervisor's limits to replace the tenant's own, got %+v", own.Limits)
+	}
+
+	t.Setenv(tenantLimitsEnv, "deploys_per_hour=lots")
+	if err := applyTenantOverrides(&config.DeployConfig{}); err == nil {
+		t.Error("Expected malformed limits from the supervisor to be refused")
+	}
+}
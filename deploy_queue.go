@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/jobs"
+)
+
+// deployJob is the payload of a queued target deployment
+type deployJob struct {
+	Request              DeployRequest `json:"request"`
+	Trigger              string        `json:"trigger,omitempty"`
+	TriggeredBy          string        `json:"triggered_by,omitempty"`
+	Chain                []string      `json:"chain,omitempty"`
+	OverrideDependencies bool          `json:"override_dependencies,omitempty"`
+	CorrelationID        string        `json:"correlation_id,omitempty"`
+	Artifact             string        `json:"artifact,omitempty"`
+}
+
+var (
+	deployQueue *jobs.Store
+
+	// deployWaiters delivers results to callers blocked in runDeployment
+	deployWaiters = struct {
+		sync.Mutex
+		m map[string]chan error
+	}{m: make(map[string]chan error)}
+
+	errDeploymentDiscarded = errors.New("queued deployment was discarded")
+)
+
+// setupDeployQueue opens the persistent deployment queue and starts the
+// worker that runs deployments one at a time. Deployments run directly if the
+// queue can't be opened.
+func setupDeployQueue() {
+	if dataStore == nil {
+		return
+	}
+	store, err := jobs.Open(dataStore)
+	if err != nil {
+		slog.Error("Failed to open deployment queue, deployments will not survive restarts", "error", err)
+		return
+	}
+	deployQueue = store
+
+	for _, job := range store.List() {
+		switch job.State {
+		case jobs.StateInterrupted:
+			slog.Warn("Deployment was interrupted by a restart, resume or discard it via /jobs",
+				"job_id", job.ID, "started_at", job.StartedAt)
+		case jobs.StatePending:
+			slog.Info("Recovered queued deployment", "job_id", job.ID, "queued_at", job.CreatedAt)
+		}
+	}
+
+	go deployWorker()
+}
+
+// runDeployment queues req and waits for it to finish
+func runDeployment(req DeployRequest) error {
+	if req.CorrelationID == "" {
+		req.CorrelationID = history.NewID()
+	}
+	release, err := admitDeployment(req)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if deployQueue == nil {
+		return runWithCorrelationID(&req, func() error { return deployTarget(req) })
+	}
+
+	// Hold the waiter lock across Add so the worker can't report the result
+	// before the waiter is registered
+	done := make(chan error, 1)
+	deployWaiters.Lock()
+	job, err := deployQueue.Add(deployJob{Request: req, Trigger: req.Trigger, TriggeredBy: req.TriggeredBy, Chain: req.Chain, OverrideDependencies: req.OverrideDependencies, CorrelationID: req.CorrelationID, Artifact: req.Artifact})
+	if err != nil {
+		deployWaiters.Unlock()
+		slog.Error("Failed to queue deployment, running it directly", "error", err)
+		return runWithCorrelationID(&req, func() error { return deployTarget(req) })
+	}
+	deployWaiters.m[job.ID] = done
+	deployWaiters.Unlock()
+
+	if depth := deployQueue.Depth(); depth > 1 {
+		slog.Info("Deployment queued behind others", "job_id", job.ID, "pending", depth-1)
+	}
+
+	return <-done
+}
+
+// deployWorker runs queued deployments in order
+func deployWorker() {
+	for {
+		job, ok, err := deployQueue.Next()
+		if err != nil {
+			slog.Error("Failed to claim queued deployment", "error", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if !ok {
+			<-deployQueue.Ready()
+			continue
+		}
+
+		var payload deployJob
+		if err = json.Unmarshal(job.Payload, &payload); err != nil {
+			slog.Error("Dropping unreadable queued deployment", "job_id", job.ID, "error", err)
+		} else {
+			req := payload.Request
+			req.Trigger = payload.Trigger
+			req.TriggeredBy = payload.TriggeredBy
+			req.Chain = payload.Chain
+			req.OverrideDependencies = payload.OverrideDependencies
+			req.CorrelationID = payload.CorrelationID
+			req.Artifact = payload.Artifact
+			err = runWithCorrelationID(&req, func() error {
+				slog.Info("Running queued deployment", "job_id", job.ID, "trigger", req.Trigger)
+				return deployTarget(req)
+			})
+		}
+
+		if finishErr := deployQueue.Finish(job.ID); finishErr != nil {
+			slog.Error("Failed to remove finished deployment from queue", "job_id", job.ID, "error", finishErr)
+		}
+		completeDeploymentJob(job.ID, err)
+	}
+}
+
+// completeDeploymentJob hands the result of a job to its waiter, if any
+func completeDeploymentJob(id string, err error) {
+	deployWaiters.Lock()
+	done, ok := deployWaiters.m[id]
+	delete(deployWaiters.m, id)
+	deployWaiters.Unlock()
+
+	if ok {
+		done <- err
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/issues"
+	"binaryDeploy/releasenotes"
+)
+
+const (
+	defaultRecentDeployments = 10
+	maxRecentDeployments     = 100
+)
+
+// DeploymentSummary is one row of the dashboard's deployment history panel
+type DeploymentSummary struct {
+	ID            string              `json:"id"`
+	Kind          string              `json:"kind"`
+	Commit        string              `json:"commit"`
+	ShortCommit   string              `json:"short_commit"`
+	Branch        string              `json:"branch"`
+	Version       string              `json:"version,omitempty"`
+	Artifact      string              `json:"artifact,omitempty"`
+	Result        string              `json:"result"`
+	Error         string              `json:"error,omitempty"`
+	Duration      string              `json:"duration"`
+	DurationMS    int64               `json:"duration_ms"`
+	Trigger       string              `json:"trigger"`
+	TriggeredBy   string              `json:"triggered_by"`
+	CorrelationID string              `json:"correlation_id,omitempty"`
+	Tags          []string            `json:"tags,omitempty"`
+	Issues        []issues.Link       `json:"issues,omitempty"`
+	ReleaseNotes  *releasenotes.Notes `json:"release_notes,omitempty"`
+	Bundle        string              `json:"bundle,omitempty"`
+	StartedAt     time.Time           `json:"started_at"`
+	FinishedAt    time.Time           `json:"finished_at"`
+}
+
+// summarizeDeployment flattens a history record for display
+func summarizeDeployment(rec history.Record) DeploymentSummary {
+	return DeploymentSummary{
+		ID:            rec.ID,
+		Kind:          rec.Kind,
+		Commit:        rec.Commit,
+		ShortCommit:   rec.Commit[:min(8, len(rec.Commit))],
+		Branch:        rec.Branch,
+		Version:       rec.Version,
+		Artifact:      rec.Artifact,
+		Result:        rec.Result,
+		Error:         rec.Error,
+		Duration:      (time.Duration(rec.DurationMS) * time.Millisecond).String(),
+		DurationMS:    rec.DurationMS,
+		Trigger:       rec.Trigger,
+		TriggeredBy:   rec.TriggeredBy,
+		CorrelationID: rec.CorrelationID,
+		Tags:          rec.Tags,
+		Issues:        issueLinks(rec.Issues),
+		ReleaseNotes:  rec.ReleaseNotes,
+		Bundle:        rec.Bundle,
+		StartedAt:     rec.StartedAt,
+		FinishedAt:    rec.FinishedAt,
+	}
+}
+
+// recentDeploymentsHandler handles GET /deployments/recent?limit=N and
+// GET /deployments?limit=N&offset=N with optional branch, result, kind,
+// commit, since, until and tag filters. Repeated tag parameters must all
+// match. The response carries an ETag so pollers get a cheap 304 while
+// nothing changed.
+func recentDeploymentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultRecentDeployments
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxRecentDeployments)
+	}
+	offset := 0
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	var since, until time.Time
+	for name, dest := range map[string]*time.Time{"since": &since, "until": &until} {
+		if v := query.Get(name); v != "" {
+			t, err := parseExportTime(v, name == "until")
+			if err != nil {
+				http.Error(w, name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dest = t
+		}
+	}
+	branch, result, kind, commit := query.Get("branch"), query.Get("result"), query.Get("kind"), query.Get("commit")
+	tags := query["tag"]
+
+	var match func(history.Record) bool
+	if branch != "" || result != "" || kind != "" || commit != "" || !since.IsZero() || !until.IsZero() || len(tags) > 0 {
+		match = func(rec history.Record) bool {
+			if (branch != "" && rec.Branch != branch) || (result != "" && rec.Result != result) || (kind != "" && rec.Kind != kind) {
+				return false
+			}
+			if commit != "" && !strings.HasPrefix(rec.Commit, commit) {
+				return false
+			}
+			if (!since.IsZero() && rec.StartedAt.Before(since)) || (!until.IsZero() && rec.StartedAt.After(until)) {
+				return false
+			}
+			for _, tag := range tags {
+				if !rec.HasTag(tag) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	records := []history.Record{}
+	if deployHistory != nil {
+		var err error
+		if records, err = deployHistory.Query(0, match); err != nil {
+			http.Error(w, "Failed to read deployment history", http.StatusInternalServerError)
+			return
+		}
+	}
+	total := len(records)
+	records = records[min(offset, total):min(offset+limit, total)]
+
+	summaries := make([]DeploymentSummary, len(records))
+	for i, rec := range records {
+		summaries[i] = summarizeDeployment(rec)
+	}
+
+	response := map[string]interface{}{
+		"deployments": summaries,
+		"count":       len(summaries),
+		"total":       total,
+		"offset":      offset,
+	}
+	if offset+len(summaries) < total {
+		response["next_offset"] = offset + len(summaries)
+	}
+
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(response)
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body.Bytes())
+}
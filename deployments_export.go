@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"binaryDeploy/history"
+)
+
+// exportColumns are the columns of a deployment history export, in order
+var exportColumns = []string{
+	"id", "app", "kind", "started_at", "finished_at", "duration_ms", "result",
+	"repo", "branch", "commit", "version", "artifact", "trigger", "triggered_by",
+	"tags", "issues", "correlation_id", "error",
+}
+
+// exportRow is one deployment in an export, flattened for spreadsheets and
+// change-management tools
+type exportRow struct {
+	ID            string    `json:"id"`
+	App           string    `json:"app"`
+	Kind          string    `json:"kind"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	DurationMS    int64     `json:"duration_ms"`
+	Result        string    `json:"result"`
+	Repo          string    `json:"repo"`
+	Branch        string    `json:"branch"`
+	Commit        string    `json:"commit"`
+	Version       string    `json:"version"`
+	Artifact      string    `json:"artifact"`
+	Trigger       string    `json:"trigger"`
+	TriggeredBy   string    `json:"triggered_by"`
+	Tags          []string  `json:"tags"`
+	Issues        []string  `json:"issues"`
+	CorrelationID string    `json:"correlation_id"`
+	Error         string    `json:"error"`
+}
+
+func newExportRow(rec history.Record) exportRow {
+	row := exportRow{
+		ID:            rec.ID,
+		App:           rec.App,
+		Kind:          rec.Kind,
+		StartedAt:     rec.StartedAt,
+		FinishedAt:    rec.FinishedAt,
+		DurationMS:    rec.DurationMS,
+		Result:        rec.Result,
+		Repo:          rec.Repo,
+		Branch:        rec.Branch,
+		Commit:        rec.Commit,
+		Version:       rec.Version,
+		Artifact:      rec.Artifact,
+		Trigger:       rec.Trigger,
+		TriggeredBy:   rec.TriggeredBy,
+		Tags:          rec.Tags,
+		Issues:        rec.Issues,
+		CorrelationID: rec.CorrelationID,
+		Error:         rec.Error,
+	}
+	if row.Tags == nil {
+		row.Tags = []string{}
+	}
+	if row.Issues == nil {
+		row.Issues = []string{}
+	}
+	return row
+}
+
+// csvRecord returns the row's cells in exportColumns order
+func (row exportRow) csvRecord() []string {
+	cells := []string{
+		row.ID, row.App, row.Kind,
+		row.StartedAt.UTC().Format(time.RFC3339), row.FinishedAt.UTC().Format(time.RFC3339),
+		strconv.FormatInt(row.DurationMS, 10), row.Result,
+		row.Repo, row.Branch, row.Commit, row.Version, row.Artifact, row.Trigger, row.TriggeredBy,
+		strings.Join(row.Tags, ";"), strings.Join(row.Issues, ";"), row.CorrelationID, row.Error,
+	}
+	for i, cell := range cells {
+		cells[i] = csvSafe(cell)
+	}
+	return cells
+}
+
+// csvSafe keeps spreadsheets from evaluating a cell, e.g. an error message
+// or branch name starting with "=", as a formula
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// parseExportTime parses an RFC 3339 time or a date. A date as the end of
+// the range includes the whole day.
+func parseExportTime(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected an RFC 3339 time or a YYYY-MM-DD date, got %q", value)
+	}
+	if end {
+		return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return day, nil
+}
+
+// deploymentsExportHandler handles GET
+// /deployments/export?format=csv|json&since=T&until=T&tag=T, reporting the
+// deployments started in the range oldest first for change-management
+// reporting. since and until are RFC 3339 times or dates; both are
+// optional.
+func deploymentsExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	var since, until time.Time
+	for name, dest := range map[string]*time.Time{"since": &since, "until": &until} {
+		if v := query.Get(name); v != "" {
+			t, err := parseExportTime(v, name == "until")
+			if err != nil {
+				http.Error(w, name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*dest = t
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		http.Error(w, "until must not be before since", http.StatusBadRequest)
+		return
+	}
+	tags := query["tag"]
+
+	records := []history.Record{}
+	if deployHistory != nil {
+		var err error
+		records, err = deployHistory.Query(0, func(rec history.Record) bool {
+			if (!since.IsZero() && rec.StartedAt.Before(since)) || (!until.IsZero() && rec.StartedAt.After(until)) {
+				return false
+			}
+			for _, tag := range tags {
+				if !rec.HasTag(tag) {
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil {
+			http.Error(w, "Failed to read deployment history", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Reports read oldest first
+	rows := make([]exportRow, len(records))
+	for i, rec := range records {
+		rows[len(records)-1-i] = newExportRow(rec)
+	}
+
+	filename := "deployments-" + appConfig().AppName
+	if !since.IsZero() {
+		filename += "-from-" + since.UTC().Format(time.DateOnly)
+	}
+	if !until.IsZero() {
+		filename += "-to-" + until.UTC().Format(time.DateOnly)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+
+	if format == "json" {
+		report := map[string]interface{}{
+			"app":         appConfig().AppName,
+			"generated":   time.Now().UTC().Format(time.RFC3339),
+			"count":       len(rows),
+			"deployments": rows,
+		}
+		if !since.IsZero() {
+			report["since"] = since.Format(time.RFC3339)
+		}
+		if !until.IsZero() {
+			report["until"] = until.Format(time.RFC3339)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write(exportColumns)
+	for _, row := range rows {
+		cw.Write(row.csvRecord())
+	}
+	cw.Flush()
+}
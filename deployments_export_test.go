@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/history"
+	"binaryDeploy/kv"
+)
+
+func setupExportTest(t *testing.T) {
+	t.Helper()
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp"})
+	store, err := kv.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployHistory, err = history.NewStore(store); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { deployHistory = nil })
+
+	for _, rec := range []history.Record{
+		{ID: "june", Commit: "1111111", StartedAt: time.Date(2026, 6, 30, 23, 0, 0, 0, time.UTC)},
+		{ID: "july", Commit: "2222222", Branch: "=HYPERLINK(\"http://evil\")", Tags: []string{"hotfix", "manual"},
+			StartedAt: time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)},
+		{ID: "september", Commit: "3333333", Tags: []string{"manual"}, Error: "-build failed",
+			StartedAt: time.Date(2026, 9, 30, 22, 0, 0, 0, time.UTC)},
+		{ID: "october", Commit: "4444444", StartedAt: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		rec.Kind, rec.Result = history.KindDeploy, history.ResultSuccess
+		if err := deployHistory.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getExport(t *testing.T, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	deploymentsExportHandler(rec, httptest.NewRequest(http.MethodGet, "/deployments/export"+query, nil))
+	return rec
+}
+
+func TestDeploymentsExport_CSV(t *testing.T) {
+	setupExportTest(t)
+
+	rec := getExport(t, "?since=2026-07-01&until=2026-09-30")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	if disposition := rec.Header().Get("Content-Disposition"); disposition != `attachment; filename="deployments-myapp-from-2026-07-01-to-2026-09-30.csv"` {
+		t.Errorf("Unexpected Content-Disposition %q", disposition)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || len(rows[0]) != len(exportColumns) || rows[0][0] != "id" {
+		t.Fatalf("Expected a header and the July and September deployments, got %v", rows)
+	}
+	column := func(row []string, name string) string {
+		for i, c := range exportColumns {
+			if c == name {
+				return row[i]
+			}
+		}
+		t.Fatalf("No column %s", name)
+		return ""
+	}
+	if column(rows[1], "id") != "july" || column(rows[2], "id") != "september" {
+		t.Errorf("Expected the deployments oldest first, got %s, %s", column(rows[1], "id"), column(rows[2], "id"))
+	}
+	if branch := column(rows[1], "branch"); branch != `'=HYPERLINK("http://evil")` {
+		t.Errorf("Expected a formula-like branch to be escaped, got %q", branch)
+	}
+	if errorCell := column(rows[2], "error"); errorCell != "'-build failed" {
+		t.Errorf("Expected an error starting with - to be escaped, got %q", errorCell)
+	}
+	if tags := column(rows[1], "tags"); tags != "hotfix;manual" {
+		t.Errorf("Expected tags joined with ;, got %q", tags)
+	}
+}
+
+func TestDeploymentsExport_JSONWithTags(t *testing.T) {
+	setupExportTest(t)
+
+	rec := getExport(t, "?format=json&tag=manual")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	var report struct {
+		App         string      `json:"app"`
+		Count       int         `json:"count"`
+		Deployments []exportRow `json:"deployments"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.App != "myapp" || report.Count != 2 || len(report.Deployments) != 2 {
+		t.Fatalf("Expected the two manual deployments, got %+v", report)
+	}
+	if report.Deployments[0].ID != "july" || report.Deployments[1].ID != "september" {
+		t.Errorf("Expected the deployments oldest first, got %s, %s", report.Deployments[0].ID, report.Deployments[1].ID)
+	}
+	if report.Deployments[1].Error != "-build failed" {
+		t.Errorf("Expected JSON values unescaped, got %q", report.Deployments[1].Error)
+	}
+}
+
+func TestDeploymentsExport_RejectsBadParameters(t *testing.T) {
+	setupExportTest(t)
+
+	for _, query := range []string{"?format=xml", "?since=yesterday", "?since=2026-09-01&until=2026-08-01"} {
+		if rec := getExport(t, query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+	rec := httptest.NewRecorder()
+	deploymentsExportHandler(rec, httptest.NewRequest(http.MethodPost, "/deployments/export", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestParseExportTime_DateAsEndIncludesTheDay(t *testing.T) {
+	end, err := parseExportTime("2026-09-30", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !end.After(time.Date(2026, 9, 30, 23, 59, 59, 0, time.UTC)) || !end.Before(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the end of 2026-09-30, got %s", end)
+	}
+	start, err := parseExportTime("2026-09-30T12:00:00+02:00", false)
+	if err != nil || !start.Equal(time.Date(2026, 9, 30, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected an RFC 3339 time to be parsed, got %s, %v", start, err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"expvar"
+	"runtime"
+	"time"
+)
+
+var serverStartTime = time.Now()
+
+// publishRuntimeVars registers the values served at /debug/vars alongside
+// expvar's built-in cmdline and memstats
+func publishRuntimeVars() {
+	expvar.Publish("goroutines", expvar.Func(func() any {
+		return runtime.NumGoroutine()
+	}))
+
+	expvar.Publish("gc", expvar.Func(func() any {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		stats := map[string]any{
+			"num_gc":         m.NumGC,
+			"pause_total_ms": float64(m.PauseTotalNs) / float64(time.Millisecond),
+			"heap_alloc":     m.HeapAlloc,
+			"heap_objects":   m.HeapObjects,
+			"next_gc":        m.NextGC,
+		}
+		if m.LastGC != 0 {
+			stats["last_gc"] = time.Unix(0, int64(m.LastGC))
+		}
+		return stats
+	}))
+
+	expvar.Publish("sse_clients", expvar.Func(func() any {
+		if globalLogStreamer == nil {
+			return 0
+		}
+		globalLogStreamer.clientsMux.RLock()
+		defer globalLogStreamer.clientsMux.RUnlock()
+		return len(globalLogStreamer.clients)
+	}))
+
+	expvar.Publish("queue_depth", expvar.Func(func() any {
+		if deployQueue == nil {
+			return 0
+		}
+		return deployQueue.Depth()
+	}))
+
+	expvar.Publish("auth", expvar.Func(func() any {
+		failures, bans := authGuard.Stats()
+		return map[string]any{"failures": failures, "bans": bans, "banned": authGuard.Bans()}
+	}))
+
+	expvar.Publish("instance_name", expvar.Func(func() any {
+		return appConfig().InstanceName
+	}))
+
+	expvar.Publish("uptime_seconds", expvar.Func(func() any {
+		return int64(time.Since(serverStartTime).Seconds())
+	}))
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// unlessDisabled serves next unless disabled_endpoints turns endpoint off,
+// in which case it answers 404 as if the endpoint didn't exist, so a
+// locked-down install doesn't rely on authentication alone
+func unlessDisabled(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	if !appConfig().EndpointDisabled(endpoint) {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.Warn("Request to disabled endpoint", "endpoint", endpoint, "path", r.URL.Path, "client", requestSource(r))
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/notify"
+)
+
+// minDurationSamples is how many earlier deployments a baseline needs before
+// it is trusted
+const minDurationSamples = 5
+
+// checkDurationRegression warns when the successful deployment rec took
+// duration_alert_factor times longer than the median of the previous
+// duration_alert_window successful deployments. rec must not be in the
+// history yet.
+func checkDurationRegression(rec history.Record) {
+	if deployHistory == nil || appConfig().DurationAlertFactor <= 0 {
+		return
+	}
+
+	previous, err := deployHistory.Query(appConfig().DurationAlertWindow, func(r history.Record) bool {
+		return r.App == rec.App && r.Kind == history.KindDeploy && r.Result == history.ResultSuccess
+	})
+	if err != nil {
+		slog.Warn("Failed to read deployment history for duration baseline", "error", err)
+		return
+	}
+	baseline := history.DurationBaseline(previous)
+	if baseline.Samples < minDurationSamples || baseline.Median <= 0 {
+		return
+	}
+
+	duration := rec.FinishedAt.Sub(rec.StartedAt)
+	threshold := time.Duration(float64(baseline.Median) * appConfig().DurationAlertFactor)
+	if duration <= threshold {
+		return
+	}
+
+	ratio := float64(duration) / float64(baseline.Median)
+	slog.Warn("Deployment took much longer than usual",
+		"app", rec.App,
+		"commit", rec.Commit,
+		"duration", duration.Round(time.Millisecond).String(),
+		"median", baseline.Median.String(),
+		"ratio", fmt.Sprintf("%.1f", ratio),
+		"samples", baseline.Samples)
+
+	fields := map[string]interface{}{
+		"commit":      rec.Commit,
+		"branch":      rec.Branch,
+		"duration_ms": duration.Milliseconds(),
+		"median_ms":   baseline.Median.Milliseconds(),
+		"ratio":       ratio,
+		"samples":     baseline.Samples,
+	}
+	if len(rec.Steps) > 0 {
+		fields["steps"] = rec.Steps
+	}
+	if len(rec.Issues) > 0 {
+		fields["issues"] = issueLinks(rec.Issues)
+	}
+	notifier().Notify(notify.Event{
+		Type: notify.EventDeploymentSlow,
+		App:  rec.App,
+		Message: fmt.Sprintf("Deployment of %s took %s, %.1fx the median of %s over the last %d deployments",
+			rec.App, duration.Round(time.Second), ratio, baseline.Median.Round(time.Second), baseline.Samples),
+		Fields:        fields,
+		CorrelationID: rec.CorrelationID,
+	})
+}
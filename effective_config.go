@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"binaryDeploy/config"
+	"binaryDeploy/secrets"
+)
+
+// effectiveConfig is the configuration an instance runs with: defaults,
+// deploy.config values and the settings its supervisor passes through the
+// environment, with credentials masked
+type effectiveConfig struct {
+	File         string                 `json:"file"`
+	InstanceName string                 `json:"instance_name,omitempty"`
+	Overrides    []string               `json:"overrides,omitempty"` // Environment settings applied over the file
+	Settings     map[string]interface{} `json:"settings"`
+}
+
+// resolveEffectiveConfig describes cfg as loaded from deploy.config
+func resolveEffectiveConfig(cfg *config.DeployConfig) (effectiveConfig, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return effectiveConfig{}, err
+	}
+	var settings map[string]interface{}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return effectiveConfig{}, err
+	}
+
+	secretValues := make(map[string]bool)
+	for _, v := range cfg.SecretValues() {
+		if v != "" {
+			secretValues[v] = true
+		}
+	}
+	for key, value := range settings {
+		settings[key] = maskSettingValue(key, value, secretValues)
+	}
+	// Template variables are how secrets get into rendered config files, so
+	// none of them are shown, whatever their names
+	if vars, ok := settings["TemplateVars"].(map[string]interface{}); ok {
+		for name := range vars {
+			vars[name] = secrets.Redacted
+		}
+	}
+
+	return effectiveConfig{
+		File:         deployConfigFile,
+		InstanceName: cfg.InstanceName,
+		Overrides:    configOverrides(),
+		Settings:     settings,
+	}, nil
+}
+
+// maskSettingValue masks credentials anywhere in a setting, including maps
+// such as auth_users and notifier options
+func maskSettingValue(key string, value interface{}, secretValues map[string]bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		if secretValues[v] {
+			return secrets.Redacted
+		}
+		return secretRedactor.RedactField(key, v)
+	case []interface{}:
+		for i := range v {
+			v[i] = maskSettingValue(key, v[i], secretValues)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = maskSettingValue(k, v[k], secretValues)
+		}
+	}
+	return value
+}
+
+// configOverrides lists the settings a supervisor passed through the
+// environment, which replace what deploy.config says
+func configOverrides() []string {
+	var overrides []string
+	if tenantName != "" {
+		overrides = append(overrides, "tenant "+tenantName+" (listen, base_path, limits, TLS)")
+	}
+	if managedAppName != "" {
+		overrides = append(overrides, "app "+managedAppName+" (listen, base_path)")
+	}
+	if ports := os.Getenv(allocatedPortsEnv); ports != "" {
+		overrides = append(overrides, "allocated ports "+ports)
+	}
+	return overrides
+}
+
+// logEffectiveConfig logs the configuration binaryDeploy is running with, so
+// operators can tell from the log which settings an instance used
+func logEffectiveConfig() {
+	effective, err := resolveEffectiveConfig(appConfig())
+	if err != nil {
+		slog.Warn("Failed to resolve the effective configuration", "error", err)
+		return
+	}
+	settings, _ := json.Marshal(effective.Settings)
+	slog.Info("Effective configuration", "file", effective.File, "overrides", effective.Overrides, "settings", string(settings))
+}
+
+// effectiveConfigHandler serves GET /config: the resolved configuration,
+// which follows reloads of deploy.config
+func effectiveConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	effective, err := resolveEffectiveConfig(appConfig())
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(effective)
+}
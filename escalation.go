@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/incident"
+	"binaryDeploy/processmanager"
+)
+
+// incidents holds the provider that opens and resolves incidents, which
+// reloadConfig replaces
+var incidents struct {
+	sync.RWMutex
+	provider incident.Provider
+}
+
+// incidentProvider returns the incident provider; nil when escalation is off
+func incidentProvider() incident.Provider {
+	incidents.RLock()
+	defer incidents.RUnlock()
+	return incidents.provider
+}
+
+// openIncidents tracks incident keys binaryDeploy has triggered (true) or
+// resolved (false). Keys it hasn't touched since starting are absent, so the
+// first success after a restart still resolves incidents opened before it.
+var openIncidents = struct {
+	sync.Mutex
+	keys map[string]bool
+}{keys: make(map[string]bool)}
+
+// setupEscalation configures the on-call service incidents are opened in
+func setupEscalation() {
+	var provider incident.Provider
+	switch appConfig().IncidentProvider {
+	case "pagerduty":
+		provider = incident.NewPagerDuty(appConfig().PagerDutyRoutingKey)
+	case "opsgenie":
+		provider = incident.NewOpsgenie(appConfig().OpsgenieAPIURL, appConfig().OpsgenieAPIKey)
+	}
+	incidents.Lock()
+	incidents.provider = provider
+	incidents.Unlock()
+	if provider == nil {
+		return
+	}
+	slog.Info("Incident escalation enabled", "provider", appConfig().IncidentProvider,
+		"failure_threshold", appConfig().IncidentFailureThreshold, "crash_threshold", appConfig().IncidentCrashThreshold)
+}
+
+// deploymentIncidentKey and crashLoopIncidentKey identify the two incidents
+// binaryDeploy opens per app
+func deploymentIncidentKey() string {
+	return "binaryDeploy:" + appConfig().AppName + ":deployment-failures"
+}
+
+func crashLoopIncidentKey() string {
+	return "binaryDeploy:" + appConfig().AppName + ":crash-loop"
+}
+
+// escalateDeployment opens an incident once incident_failure_threshold
+// deployments in a row have failed, and resolves open incidents when one
+// succeeds. rec must already be in the history.
+func escalateDeployment(rec history.Record) {
+	if incidentProvider() == nil || rec.Kind != history.KindDeploy {
+		return
+	}
+
+	if rec.Result == history.ResultSuccess {
+		resolveIncident(deploymentIncidentKey())
+		resolveIncident(crashLoopIncidentKey())
+		return
+	}
+
+	if deployHistory == nil {
+		return
+	}
+	recent, err := deployHistory.Query(appConfig().IncidentFailureThreshold, func(r history.Record) bool {
+		return r.App == rec.App && r.Kind == history.KindDeploy
+	})
+	if err != nil {
+		slog.Warn("Failed to read deployment history for escalation", "error", err)
+		return
+	}
+	if len(recent) < appConfig().IncidentFailureThreshold {
+		return
+	}
+	for _, r := range recent {
+		if r.Result != history.ResultFailure {
+			return
+		}
+	}
+
+	details := map[string]interface{}{
+		"failures": len(recent),
+		"commit":   rec.Commit,
+		"branch":   rec.Branch,
+		"error":    rec.Error,
+		"trigger":  rec.Trigger,
+	}
+	if rec.Bundle != "" {
+		details["bundle"] = rec.Bundle
+	}
+	if rec.CorrelationID != "" {
+		details["correlation_id"] = rec.CorrelationID
+	}
+	triggerIncident(incident.Incident{
+		Key:      deploymentIncidentKey(),
+		Summary:  fmt.Sprintf("%s: %d deployments in a row failed, last error: %s", appConfig().AppName, len(recent), rec.Error),
+		Source:   appConfig().AppName,
+		Severity: "error",
+		Details:  details,
+	})
+}
+
+// escalateCrash opens an incident when the app has crashed
+// incident_crash_threshold times within incident_crash_window
+func escalateCrash(event processmanager.Event) {
+	if incidentProvider() == nil || event.Type != processmanager.EventCrashed {
+		return
+	}
+
+	window := time.Duration(appConfig().IncidentCrashWindow) * time.Second
+	crashes := 0
+	for _, e := range processManager.Events().Recent(0) {
+		if e.Type == processmanager.EventCrashed && time.Since(e.Time) <= window {
+			crashes++
+		}
+	}
+	if crashes < appConfig().IncidentCrashThreshold {
+		return
+	}
+
+	triggerIncident(incident.Incident{
+		Key:      crashLoopIncidentKey(),
+		Summary:  fmt.Sprintf("%s is crash-looping: %d crashes in %s, last %s", appConfig().AppName, crashes, window, crashSummary(event)),
+		Source:   appConfig().AppName,
+		Severity: "critical",
+		Details: map[string]interface{}{
+			"crashes":  crashes,
+			"window":   window.String(),
+			"instance": event.Instance,
+			"reason":   event.Reason,
+		},
+	})
+}
+
+// triggerIncident opens inc unless binaryDeploy already has it open
+func triggerIncident(inc incident.Incident) {
+	provider := incidentProvider()
+	if provider == nil {
+		return
+	}
+
+	openIncidents.Lock()
+	if openIncidents.keys[inc.Key] {
+		openIncidents.Unlock()
+		return
+	}
+	openIncidents.keys[inc.Key] = true
+	openIncidents.Unlock()
+
+	inc.Summary = secretRedactor.Redact(inc.Summary)
+	if msg, ok := inc.Details["error"].(string); ok {
+		inc.Details["error"] = secretRedactor.Redact(msg)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := provider.Trigger(ctx, inc); err != nil {
+		slog.Error("Failed to open incident", "provider", appConfig().IncidentProvider, "key", inc.Key, "error", err)
+		openIncidents.Lock()
+		delete(openIncidents.keys, inc.Key)
+		openIncidents.Unlock()
+		return
+	}
+	slog.Warn("Opened incident", "provider", appConfig().IncidentProvider, "key", inc.Key, "summary", inc.Summary)
+}
+
+// resolveIncident resolves the incident with key unless binaryDeploy already
+// knows it is closed
+func resolveIncident(key string) {
+	provider := incidentProvider()
+	if provider == nil {
+		return
+	}
+
+	openIncidents.Lock()
+	if open, known := openIncidents.keys[key]; known && !open {
+		openIncidents.Unlock()
+		return
+	}
+	openIncidents.keys[key] = false
+	openIncidents.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := provider.Resolve(ctx, key); err != nil {
+		slog.Error("Failed to resolve incident", "provider", appConfig().IncidentProvider, "key", key, "error", err)
+		openIncidents.Lock()
+		delete(openIncidents.keys, key)
+		openIncidents.Unlock()
+		return
+	}
+	slog.Info("Resolved incident", "provider", appConfig().IncidentProvider, "key", key)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// webhookForge describes how a git forge signs and labels its webhooks
+type webhookForge struct {
+	Name            string
+	SignatureHeader string
+	SignaturePrefix string // Precedes the hex HMAC-SHA256 of the body
+	EventHeader     string
+}
+
+var (
+	forgeGitHub = webhookForge{Name: "github", SignatureHeader: "X-Hub-Signature-256", SignaturePrefix: "sha256=", EventHeader: "X-GitHub-Event"}
+	forgeGitea  = webhookForge{Name: "gitea", SignatureHeader: "X-Gitea-Signature", EventHeader: "X-Gitea-Event"}
+	forgeGogs   = webhookForge{Name: "gogs", SignatureHeader: "X-Gogs-Signature", EventHeader: "X-Gogs-Event"}
+)
+
+// detectWebhookForge tells which forge sent r. Gitea also sends the Gogs
+// and GitHub headers, so it is checked first; anything unrecognised is
+// treated as GitHub.
+func detectWebhookForge(r *http.Request) webhookForge {
+	for _, forge := range []webhookForge{forgeGitea, forgeGogs} {
+		if r.Header.Get(forge.SignatureHeader) != "" || r.Header.Get(forge.EventHeader) != "" {
+			return forge
+		}
+	}
+	return forgeGitHub
+}
+
+// normalizePushPayload fills in the fields GitHub sends but Gitea and Gogs
+// name differently or leave out: Gogs has no head_commit, and neither sets
+// pusher.name
+func normalizePushPayload(payload *GitHubPushPayload) {
+	if payload.HeadCommit.ID == "" && !isZeroCommit(payload.After) {
+		payload.HeadCommit.ID = payload.After
+		for _, c := range payload.Commits {
+			if c.ID == payload.After {
+				payload.HeadCommit.Message = c.Message
+			}
+		}
+	}
+	if payload.Pusher.Name == "" {
+		payload.Pusher.Name = payload.Pusher.Username
+	}
+	if payload.Pusher.Name == "" {
+		payload.Pusher.Name = payload.Pusher.Login
+	}
+}
+
+// isZeroCommit reports whether sha is the all-zero ID forges send as the
+// new commit of a deleted branch
+func isZeroCommit(sha string) bool {
+	return sha != "" && strings.Trim(sha, "0") == ""
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/schedule"
+)
+
+// frozenDeployment is an automatic deployment held back until a freeze
+// window ends. Only the most recent one is kept.
+var frozenDeployment struct {
+	sync.Mutex
+	req   DeployRequest
+	timer *time.Timer
+}
+
+// activeFreeze returns the freeze window currently blocking deployments to
+// the configured environment, and when it lifts
+func activeFreeze(now time.Time) (*schedule.Window, time.Time, bool) {
+	var windows []schedule.Window
+	for _, env := range []string{"", appConfig().Environment} {
+		spec, ok := appConfig().FreezeWindows[env]
+		if !ok {
+			continue
+		}
+		parsed, err := schedule.ParseWindows(spec)
+		if err != nil {
+			slog.Error("Invalid freeze window", "environment", env, "error", err)
+			continue
+		}
+		windows = append(windows, parsed...)
+	}
+	return schedule.ActiveWindow(windows, now)
+}
+
+// freezeOverride reports whether the caller asked to bypass freeze windows
+// and is allowed to
+func freezeOverride(r *http.Request) bool {
+	if r.URL.Query().Get("override_freeze") != "true" {
+		return false
+	}
+	if !hasValidAPIToken(r) {
+		slog.WarnContext(r.Context(), "Freeze override requested without a valid api_token", "remote_addr", r.RemoteAddr)
+		recordAuthFailure(r, "invalid api_token for freeze override")
+		return false
+	}
+	slog.WarnContext(r.Context(), "Deployment freeze overridden", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+	return true
+}
+
+// freezeGate checks freeze windows before deploying req. When a
+// window is active, automatic deployments are queued or rejected according to
+// freeze_mode while manual ones (automatic=false) are always rejected. The
+// HTTP status and message for the caller are returned.
+func freezeGate(r *http.Request, req DeployRequest, automatic bool) (blocked bool, status int, message string) {
+	window, until, frozen := activeFreeze(time.Now())
+	if !frozen || freezeOverride(r) {
+		return false, 0, ""
+	}
+
+	environment := appConfig().Environment
+	if environment == "" {
+		environment = appConfig().AppName
+	}
+
+	if automatic && appConfig().FreezeMode == "queue" {
+		queueFrozenDeployment(req, until)
+		message = fmt.Sprintf("Deployments to %s are frozen (%s); deployment queued until %s",
+			environment, window.Spec, until.Format(time.RFC3339))
+		slog.InfoContext(r.Context(), "Deployment queued by freeze window", "environment", environment, "window", window.Spec, "until", until)
+		return true, http.StatusAccepted, message
+	}
+
+	message = fmt.Sprintf("Deployments to %s are frozen (%s) until %s; retry later or pass override_freeze=true with the api_token",
+		environment, window.Spec, until.Format(time.RFC3339))
+	slog.WarnContext(r.Context(), "Deployment rejected by freeze window", "environment", environment, "window", window.Spec, "until", until)
+	return true, http.StatusLocked, message
+}
+
+// queueFrozenDeployment schedules req to deploy once the freeze lifts,
+// replacing any deployment queued earlier
+func queueFrozenDeployment(req DeployRequest, until time.Time) {
+	frozenDeployment.Lock()
+	defer frozenDeployment.Unlock()
+
+	if frozenDeployment.timer != nil {
+		frozenDeployment.timer.Stop()
+		slog.Info("Replacing previously queued deployment", "repo_url", frozenDeployment.req.RepoURL)
+	}
+
+	frozenDeployment.req = req
+	frozenDeployment.timer = time.AfterFunc(time.Until(until), runFrozenDeployment)
+}
+
+// runFrozenDeployment starts the queued deployment, re-queueing it if another
+// window has started in the meantime
+func runFrozenDeployment() {
+	frozenDeployment.Lock()
+	req := frozenDeployment.req
+	frozenDeployment.timer = nil
+	frozenDeployment.Unlock()
+
+	if _, until, frozen := activeFreeze(time.Now()); frozen {
+		queueFrozenDeployment(req, until)
+		return
+	}
+
+	slog.Info("Freeze window ended, starting queued deployment", "repo_url", req.RepoURL)
+	req.Trigger += " (queued during freeze)"
+	req.Tags = append(req.Tags, history.TagFrozen)
+	triggerTargetDeployment(req, "Queued deployment started after freeze window")
+}
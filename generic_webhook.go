@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"binaryDeploy/history"
+	"binaryDeploy/jsonpath"
+)
+
+// maxGenericPayloadSize bounds the body accepted by /webhook/generic
+const maxGenericPayloadSize = 1 << 20
+
+// genericWebhookHandler accepts arbitrary JSON payloads from CI systems and
+// extracts repo, branch and commit using the configured JSONPath expressions
+func genericWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if appConfig().GenericWebhookToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Incoming generic webhook request",
+		"method", r.Method,
+		"remote_addr", r.RemoteAddr,
+		"user_agent", r.Header.Get("User-Agent"))
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(appConfig().GenericWebhookHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(appConfig().GenericWebhookToken)) != 1 {
+		slog.WarnContext(r.Context(), "Invalid generic webhook token", "header", appConfig().GenericWebhookHeader, "remote_addr", r.RemoteAddr)
+		recordAuthFailure(r, "invalid generic webhook token")
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := readWebhookBody(w, r, maxGenericPayloadSize, nil)
+	if err != nil {
+		writeWebhookBodyError(w, err)
+		return
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	req, ref, err := genericDeployRequest(doc)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Rejected generic payload", "error", err)
+		http.Error(w, "Invalid payload - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	repoURL, branch := req.RepoURL, extractBranchFromRef(ref)
+
+	slog.InfoContext(r.Context(), "Generic payload parsed successfully",
+		"repo_url", repoURL,
+		"ref", ref,
+		"commit_id", req.Commit[:min(8, len(req.Commit))])
+
+	if repoURL == appConfig().SelfUpdateRepoURL {
+		selfRef, ok := selfUpdateRef(ref)
+		if !ok {
+			slog.InfoContext(r.Context(), "Ref does not trigger self-updates", "ref", ref, "self_update_branch", appConfig().SelfUpdateBranch)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "Ref %s is not configured for self-updates", ref)
+			return
+		}
+		triggerSelfUpdate("Generic webhook self-update", fmt.Sprintf("Generic webhook self-update triggered for %s", selfRef), selfRef)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Self-update deployment triggered for %s", repoURL)
+		return
+	}
+
+	if configRepoPushHandled(w, r, repoURL, ref) {
+		return
+	}
+
+	if req.GitTag != "" && !isAllowedTag(req.GitTag) {
+		slog.InfoContext(r.Context(), "Tag not in allowed tags", "tag", req.GitTag)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Tag %s is not configured for auto-deployment", req.GitTag)
+		return
+	}
+	if req.GitTag == "" && !isAllowedBranch(req.Branch) {
+		slog.InfoContext(r.Context(), "Branch not in allowed branches", "branch", req.Branch)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Branch %s is not configured for auto-deployment", req.Branch)
+		return
+	}
+
+	req.Trigger = "Generic webhook deployment"
+	req.TriggeredBy = requestSource(r)
+	req.Tags = []string{history.TagWebhook}
+	req.CorrelationID = requestCorrelationID(r)
+	req.OverrideDependencies = dependencyOverride(r)
+	if blocked, status, message := freezeGate(r, req, true); blocked {
+		w.WriteHeader(status)
+		fmt.Fprint(w, message)
+		return
+	}
+
+	if req.GitTag != "" && !claimTagDeploy(req.GitTag) {
+		slog.InfoContext(r.Context(), "Tag was just deployed", "tag", req.GitTag)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Tag %s was already deployed", req.GitTag)
+		return
+	}
+
+	triggerTargetDeployment(req, fmt.Sprintf("Generic webhook deployment triggered for %s (%s)", repoURL, branch))
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Deployment triggered for %s", repoURL)
+}
+
+// genericDeployRequest extracts the repository, ref and commit of a generic
+// webhook payload with the configured JSONPath expressions. The request
+// deploys the extracted commit, or the branch or tag the ref names; ref is
+// returned as extracted for self-update and config repository pushes.
+func genericDeployRequest(doc interface{}) (req DeployRequest, ref string, err error) {
+	req.RepoURL = appConfig().TargetRepoURL
+	if appConfig().GenericRepoExpr != "" {
+		if req.RepoURL, err = jsonpath.Evaluate(appConfig().GenericRepoExpr, doc); err != nil {
+			return req, "", fmt.Errorf("missing repository: %w", err)
+		}
+	}
+
+	if ref, err = jsonpath.Evaluate(appConfig().GenericBranchExpr, doc); err != nil {
+		return req, "", fmt.Errorf("missing branch: %w", err)
+	}
+	if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+		req.GitTag = tag
+	} else {
+		req.Branch = extractBranchFromRef(ref)
+	}
+	name := req.Branch + req.GitTag
+	if !branchNamePattern.MatchString(name) || strings.HasPrefix(name, "-") || strings.Contains(name, "..") {
+		return req, "", fmt.Errorf("invalid ref %q", ref)
+	}
+
+	if appConfig().GenericCommitExpr != "" {
+		if req.Commit, err = jsonpath.Evaluate(appConfig().GenericCommitExpr, doc); err != nil {
+			return req, "", fmt.Errorf("missing commit: %w", err)
+		}
+		if !commitPattern.MatchString(req.Commit) {
+			return req, "", fmt.Errorf("invalid commit %q: expected a 7-40 character hex SHA", req.Commit)
+		}
+		// A tag deploys the commit it points to now
+		if req.GitTag != "" {
+			req.Commit = ""
+		}
+	}
+	return req, ref, nil
+}
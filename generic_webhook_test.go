@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func genericTestPayload(t *testing.T, payload string) interface{} {
+	t.Helper()
+	var doc interface{}
+	if err := json.Unmarshal([]byte(payload), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestGenericDeployRequest_DeploysExtractedCommit(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL:     "https://git.example.com/team/app.git",
+		GenericBranchExpr: "$.build.branch",
+		GenericCommitExpr: "$.build.sha",
+	})
+
+	doc := genericTestPayload(t, `{"build": {"branch": "refs/heads/release/2.1", "sha": "3f9c2a7e1b"}}`)
+	req, ref, err := genericDeployRequest(doc)
+	if err != nil {
+		t.Fatalf("genericDeployRequest: %v", err)
+	}
+	if req.RepoURL != appConfig().TargetRepoURL || req.Branch != "release/2.1" || req.Commit != "3f9c2a7e1b" || req.GitTag != "" {
+		t.Errorf("Expected release/2.1 at 3f9c2a7e1b of the target repo, got %+v", req)
+	}
+	if ref != "refs/heads/release/2.1" {
+		t.Errorf("Expected the extracted ref, got %q", ref)
+	}
+
+	doc = genericTestPayload(t, `{"build": {"branch": "refs/tags/v2.1.0", "sha": "3f9c2a7e1b"}}`)
+	if req, _, err = genericDeployRequest(doc); err != nil {
+		t.Fatalf("genericDeployRequest: %v", err)
+	}
+	if req.GitTag != "v2.1.0" || req.Branch != "" || req.Commit != "" {
+		t.Errorf("Expected the tag v2.1.0, got %+v", req)
+	}
+}
+
+func TestGenericDeployRequest_RejectsMalformedRefs(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL:     "https://git.example.com/team/app.git",
+		GenericBranchExpr: "$.branch",
+		GenericCommitExpr: "$.sha",
+	})
+
+	payloads := []string{
+		`{"branch": "main", "sha": "HEAD~1"}`,
+		`{"branch": "main", "sha": "abc"}`,
+		`{"branch": "--upload-pack=evil", "sha": "3f9c2a7e1b"}`,
+		`{"branch": "main/../../x", "sha": "3f9c2a7e1b"}`,
+		`{"sha": "3f9c2a7e1b"}`,
+	}
+	for _, payload := range payloads {
+		if req, _, err := genericDeployRequest(genericTestPayload(t, payload)); err == nil {
+			t.Errorf("Expected %s to be rejected, got %+v", payload, req)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// gitCredentialsName is the git credential store in the private state
+// directory
+const gitCredentialsName = "git-credentials"
+
+// gitCredentials holds the path of the git credential store holding
+// git_token, which reloadConfig replaces. The token lives in a 0600 file
+// rather than on git's command line, where other users could read it.
+var gitCredentials struct {
+	sync.RWMutex
+	file string
+}
+
+// gitCredentialsFile returns the git credential store, or empty when no
+// token is configured
+func gitCredentialsFile() string {
+	gitCredentials.RLock()
+	defer gitCredentials.RUnlock()
+	return gitCredentials.file
+}
+
+func setGitCredentialsFile(path string) {
+	gitCredentials.Lock()
+	gitCredentials.file = path
+	gitCredentials.Unlock()
+}
+
+// setupGitCredentials checks the SSH key and writes the credential store
+// for git_token, removing a stale one when the token was dropped
+func setupGitCredentials() error {
+	for _, file := range []string{appConfig().GitSSHKeyFile, appConfig().GitSSHKnownHostsFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("git SSH credentials: %w", err)
+		}
+	}
+
+	if appConfig().GitToken == "" {
+		setGitCredentialsFile("")
+		path := filepath.Join(privateStateDir(), gitCredentialsName)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed to remove git credentials", "path", path, "error", err)
+		}
+		return nil
+	}
+
+	hosts := gitTokenHosts()
+	if len(hosts) == 0 {
+		return fmt.Errorf("git_token: target_repo_url %q is not an HTTPS URL, list the hosts to use the token for in git_token_hosts", appConfig().TargetRepoURL)
+	}
+	var store strings.Builder
+	for _, host := range hosts {
+		u := url.URL{Scheme: "https", User: url.UserPassword(appConfig().GitTokenUser, appConfig().GitToken), Host: host}
+		store.WriteString(u.String() + "\n")
+	}
+	path, err := privateStatePath(gitCredentialsName)
+	if err != nil {
+		return fmt.Errorf("git credentials: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(store.String()), 0600); err != nil {
+		return fmt.Errorf("writing git credentials: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+	setGitCredentialsFile(path)
+
+	slog.Info("Git token configured", "hosts", hosts, "user", appConfig().GitTokenUser)
+	return nil
+}
+
+// gitTokenHosts are the hosts git_token is sent to: git_token_hosts, or the
+// host of an HTTPS target_repo_url
+func gitTokenHosts() []string {
+	if len(appConfig().GitTokenHosts) > 0 {
+		return appConfig().GitTokenHosts
+	}
+	u, err := url.Parse(appConfig().TargetRepoURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil
+	}
+	return []string{u.Host}
+}
+
+// gitCredentialArgs returns the "-c key=value" options that hand git the
+// configured SSH key, token and credential helper
+func gitCredentialArgs() []string {
+	var args []string
+	if appConfig().GitSSHKeyFile != "" || appConfig().GitSSHKnownHostsFile != "" {
+		// BatchMode fails instead of prompting for a passphrase or an
+		// unknown host key
+		command := "ssh -o BatchMode=yes"
+		if appConfig().GitSSHKeyFile != "" {
+			command += " -i " + shellQuote(appConfig().GitSSHKeyFile) + " -o IdentitiesOnly=yes"
+		}
+		if appConfig().GitSSHKnownHostsFile != "" {
+			command += " -o UserKnownHostsFile=" + shellQuote(appConfig().GitSSHKnownHostsFile) + " -o StrictHostKeyChecking=yes"
+		}
+		args = append(args, "-c", "core.sshCommand="+command)
+	}
+
+	credentialsFile := gitCredentialsFile()
+	if credentialsFile != "" || appConfig().GitCredentialHelper != "" {
+		// An empty helper drops those of the user's git config, so git
+		// doesn't fall back to e.g. a desktop keychain
+		args = append(args, "-c", "credential.helper=")
+		if credentialsFile != "" {
+			args = append(args, "-c", "credential.helper=store --file="+shellQuote(credentialsFile))
+		}
+		if appConfig().GitCredentialHelper != "" {
+			args = append(args, "-c", "credential.helper="+appConfig().GitCredentialHelper)
+		}
+	}
+	return args
+}
+
+// shellQuote quotes s for the shell git runs ssh and credential helpers with
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"binaryDeploy/config"
+)
+
+// mirrorPath returns the bare mirror of repoURL inside git_mirror_dir. The
+// name is derived from the URL so every binaryDeploy instance on the host
+// deploying the same repository finds the same mirror.
+func mirrorPath(repoURL string) string {
+	sum := sha256.Sum256([]byte(repoURL))
+	name := config.RepoNameFromURL(repoURL) + "-" + hex.EncodeToString(sum[:6]) + ".git"
+	return filepath.Join(appConfig().GitMirrorDir, name)
+}
+
+// updateMirror creates or fetches the shared mirror of repoURL and returns
+// its path. Instances sharing the mirror take turns through a lock file, so
+// each fetch from the remote serves every environment deployed after it.
+func updateMirror(repoURL string) (string, error) {
+	mirror := mirrorPath(repoURL)
+	if err := os.MkdirAll(appConfig().GitMirrorDir, 0755); err != nil {
+		return "", err
+	}
+	unlock, err := lockFile(mirror + ".lock")
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(mirror); os.IsNotExist(err) {
+		slog.Info("Creating shared mirror", "repo_url", repoURL, "path", mirror)
+		if err := runCommandInDir("", "git", gitCommand(repoURL, "clone", "--bare", repoURL, mirror)...); err != nil {
+			os.RemoveAll(mirror)
+			return "", fmt.Errorf("failed to clone mirror: %w", err)
+		}
+		// Checkouts borrow objects from the mirror, so objects must never
+		// be pruned from under them. Only branches and tags are mirrored,
+		// not e.g. GitHub's refs/pull/*.
+		for _, setting := range [][]string{
+			{"remote.origin.fetch", "+refs/heads/*:refs/heads/*"},
+			{"gc.pruneExpire", "never"},
+		} {
+			if err := runCommandInDir(mirror, "git", append([]string{"config"}, setting...)...); err != nil {
+				os.RemoveAll(mirror)
+				return "", fmt.Errorf("failed to configure mirror: %w", err)
+			}
+		}
+		return mirror, nil
+	}
+
+	slog.Info("Updating shared mirror", "path", mirror)
+	if err := runCommandInDir(mirror, "git", gitCommand(repoURL, "fetch", "--prune", "--tags", "origin")...); err != nil {
+		return "", fmt.Errorf("failed to fetch mirror: %w", err)
+	}
+	return mirror, nil
+}
+
+// useMirror points an existing checkout at mirror: fetches come from the
+// mirror and objects already in it are borrowed rather than copied. This
+// also converts checkouts cloned before git_mirror_dir was set.
+func useMirror(repoDir, mirror string) error {
+	if out, err := exec.Command("git", "-C", repoDir, "remote", "get-url", "origin").Output(); err == nil &&
+		strings.TrimSpace(string(out)) == mirror {
+		return nil
+	}
+
+	slog.Info("Switching checkout to shared mirror", "path", repoDir, "mirror", mirror)
+	alternates := filepath.Join(repoDir, ".git", "objects", "info", "alternates")
+	existing, _ := os.ReadFile(alternates)
+	objects := filepath.Join(mirror, "objects")
+	if !strings.Contains(string(existing), objects+"\n") {
+		f, err := os.OpenFile(alternates, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(objects + "\n")
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return runCommandInDir(repoDir, "git", "remote", "set-url", "origin", mirror)
+}
+
+// leaveMirror detaches a checkout made with git_mirror_dir once it is unset:
+// borrowed objects are copied in and origin points back at repoURL
+func leaveMirror(repoDir, repoURL string) error {
+	alternates := filepath.Join(repoDir, ".git", "objects", "info", "alternates")
+	if _, err := os.Stat(alternates); err != nil {
+		return nil
+	}
+
+	slog.Info("Detaching checkout from shared mirror", "path", repoDir)
+	if err := runCommandInDir(repoDir, "git", "repack", "-a", "-d"); err != nil {
+		return err
+	}
+	if err := os.Remove(alternates); err != nil {
+		return err
+	}
+	return runCommandInDir(repoDir, "git", "remote", "set-url", "origin", repoURL)
+}
+
+// lockFile takes an exclusive lock on path, waiting for other processes
+// holding it, and returns the function releasing it
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// tryLockFile takes an exclusive lock on path if no other process holds it,
+// returning a nil release function if one does
+func tryLockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
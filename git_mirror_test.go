@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/testutil"
+)
+
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCheckoutTargetRevision_SharesMirror(t *testing.T) {
+	repo := testutil.NewRepo(t, map[string]string{"main.go": "package main\n"})
+	mirrors := t.TempDir()
+	cfg := &config.DeployConfig{TargetRepoURL: repo.URL, AllowedBranches: "main", GitMirrorDir: mirrors}
+	currentConfig.Store(cfg)
+	req := DeployRequest{RepoURL: repo.URL, Branch: "main"}
+
+	staging, production := filepath.Join(t.TempDir(), "repo"), filepath.Join(t.TempDir(), "repo")
+	if err := checkoutTargetRevision(req, staging); err != nil {
+		t.Fatalf("staging checkout: %v", err)
+	}
+	mirror := mirrorPath(repo.URL)
+	if filepath.Dir(mirror) != mirrors || gitOutput(t, mirror, "rev-parse", "--is-bare-repository") != "true" {
+		t.Fatalf("Expected a bare mirror in git_mirror_dir, got %s", mirror)
+	}
+
+	// The second environment picks up a commit the mirror fetched for it
+	latest := repo.Commit("Release", map[string]string{"VERSION": "2\n"})
+	if err := checkoutTargetRevision(req, production); err != nil {
+		t.Fatalf("production checkout: %v", err)
+	}
+	for name, dir := range map[string]string{"staging": staging, "production": production} {
+		if url := gitOutput(t, dir, "remote", "get-url", "origin"); url != mirror {
+			t.Errorf("%s: expected origin to be the mirror, got %s", name, url)
+		}
+		alternates, _ := os.ReadFile(filepath.Join(dir, ".git", "objects", "info", "alternates"))
+		if !strings.Contains(string(alternates), filepath.Join(mirror, "objects")) {
+			t.Errorf("%s: expected objects borrowed from the mirror, got %q", name, alternates)
+		}
+	}
+	if head := gitHeadCommit(production); head != latest {
+		t.Errorf("Expected production at %s, got %s", latest, head)
+	}
+
+	// Unsetting git_mirror_dir detaches the checkout again
+	cfg.GitMirrorDir = ""
+	if err := checkoutTargetRevision(req, staging); err != nil {
+		t.Fatalf("staging checkout without the mirror: %v", err)
+	}
+	if url := gitOutput(t, staging, "remote", "get-url", "origin"); url != repo.URL {
+		t.Errorf("Expected origin back on the repository, got %s", url)
+	}
+	if _, err := os.Stat(filepath.Join(staging, ".git", "objects", "info", "alternates")); err == nil {
+		t.Error("Expected the checkout to stop borrowing objects")
+	}
+	os.RemoveAll(mirror)
+	if out, err := exec.Command("git", "-C", staging, "fsck", "--connectivity-only").CombinedOutput(); err != nil {
+		t.Errorf("Expected the detached checkout to be complete without the mirror: %v: %s", err, out)
+	}
+	if head := gitHeadCommit(staging); head != latest {
+		t.Errorf("Expected staging at %s, got %s", latest, head)
+	}
+}
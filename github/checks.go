@@ -0,0 +1,79 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Check run statuses and conclusions accepted by the GitHub API
+const (
+	CheckInProgress = "in_progress"
+	CheckCompleted  = "completed"
+
+	ConclusionSuccess = "success"
+	ConclusionFailure = "failure"
+)
+
+// MaxCheckOutputText is the most GitHub accepts in a check run's output text
+// and summary
+const MaxCheckOutputText = 65535
+
+// CheckRun is a check run as sent to the GitHub API. Check runs can only be
+// created with a GitHub App installation token.
+type CheckRun struct {
+	Name        string       `json:"name,omitempty"`
+	HeadSHA     string       `json:"head_sha,omitempty"`
+	Status      string       `json:"status,omitempty"`
+	Conclusion  string       `json:"conclusion,omitempty"`
+	DetailsURL  string       `json:"details_url,omitempty"`
+	StartedAt   *time.Time   `json:"started_at,omitempty"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+	Output      *CheckOutput `json:"output,omitempty"`
+}
+
+// CheckOutput is the text shown on a check run's page
+type CheckOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text,omitempty"`
+}
+
+// CreateCheckRun creates a check run and returns its ID
+func (c *Client) CreateCheckRun(ctx context.Context, owner, repo string, run CheckRun) (int64, error) {
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	path := fmt.Sprintf("/repos/%s/%s/check-runs", owner, repo)
+	if err := c.do(ctx, "POST", path, run, &created); err != nil {
+		return 0, err
+	}
+	return created.ID, nil
+}
+
+// UpdateCheckRun changes the fields set in run on check run id
+func (c *Client) UpdateCheckRun(ctx context.Context, owner, repo string, id int64, run CheckRun) error {
+	path := fmt.Sprintf("/repos/%s/%s/check-runs/%d", owner, repo, id)
+	return c.do(ctx, "PATCH", path, run, nil)
+}
+
+// TailOutput fits the end of output, where build errors usually are, into
+// max bytes, noting how much was cut from the start
+func TailOutput(output string, max int) string {
+	if len(output) <= max {
+		return output
+	}
+	const note = "... %d bytes truncated ...\n"
+	keep := max - len(fmt.Sprintf(note, len(output)))
+	cut := len(output) - keep
+	for cut < len(output) && !utf8.RuneStart(output[cut]) {
+		cut++
+	}
+	if i := strings.IndexByte(output[cut:], '\n'); i >= 0 && i < 200 {
+		// Start on a whole line
+		cut += i + 1
+	}
+	return fmt.Sprintf(note, cut) + output[cut:]
+}
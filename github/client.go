@@ -0,0 +1,154 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the API base URL for github.com
+const DefaultAPIURL = "https://api.github.com"
+
+// Client is a minimal GitHub REST API client that works against both
+// github.com and GitHub Enterprise Server
+type Client struct {
+	BaseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient creates a client for the given API base URL. A bare GitHub
+// Enterprise Server host (https://ghe.example.com) is expanded to its REST
+// endpoint (https://ghe.example.com/api/v3). caBundle is an optional PEM file
+// trusted in addition to the system roots.
+func NewClient(baseURL, token, caBundle string) (*Client, error) {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	baseURL, err := normalizeBaseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caBundle != "" {
+		var roots *x509.CertPool
+		if transport.TLSClientConfig != nil {
+			roots = transport.TLSClientConfig.RootCAs
+		}
+		pool, err := loadCABundle(caBundle, roots)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &Client{
+		BaseURL: baseURL,
+		token:   token,
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: transport,
+		},
+	}, nil
+}
+
+// normalizeBaseURL validates the base URL and appends /api/v3 for GHES hosts
+func normalizeBaseURL(baseURL string) (string, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid GitHub API URL %q", baseURL)
+	}
+	if u.Path == "" && u.Host != "api.github.com" {
+		u.Path = "/api/v3"
+	}
+	return u.String(), nil
+}
+
+// loadCABundle returns base (or the system roots when base is nil) plus the
+// certificates in caFile
+func loadCABundle(caFile string, base *x509.CertPool) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA bundle: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if base != nil {
+		pool = base.Clone()
+	} else if pool, err = x509.SystemCertPool(); err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+	}
+	return pool, nil
+}
+
+// do sends a JSON request to the API and decodes a JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GitHub API %s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// ParseRepo extracts owner and repository name from a clone URL in https,
+// ssh:// or scp-like (git@host:owner/repo.git) form
+func ParseRepo(repoURL string) (owner, repo string, ok bool) {
+	path := repoURL
+	if u, err := url.Parse(repoURL); err == nil && u.Host != "" {
+		path = u.Path
+	} else if idx := strings.Index(repoURL, ":"); idx >= 0 && strings.Contains(repoURL[:idx], "@") {
+		path = repoURL[idx+1:]
+	} else {
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(path, ".git"), "/"), "/")
+	if len(parts) < 2 {
+		return "", "", false
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], true
+}
@@ -0,0 +1,206 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBaseURL(t *testing.T) {
+	tests := map[string]string{
+		"https://api.github.com":              "https://api.github.com",
+		"https://api.github.com/":             "https://api.github.com",
+		"https://ghe.example.com":             "https://ghe.example.com/api/v3",
+		"https://ghe.example.com/api/v3/":     "https://ghe.example.com/api/v3",
+		"https://ghe.example.com/custom/path": "https://ghe.example.com/custom/path",
+	}
+
+	for in, expected := range tests {
+		got, err := normalizeBaseURL(in)
+		if err != nil {
+			t.Fatalf("normalizeBaseURL(%q) returned error: %v", in, err)
+		}
+		if got != expected {
+			t.Errorf("normalizeBaseURL(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+
+	if _, err := normalizeBaseURL("not a url"); err == nil {
+		t.Error("Expected error for invalid URL")
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	tests := []struct {
+		url   string
+		owner string
+		repo  string
+		ok    bool
+	}{
+		{"https://github.com/user/myapp.git", "user", "myapp", true},
+		{"https://ghe.example.com/org/service", "org", "service", true},
+		{"git@github.com:user/myapp.git", "user", "myapp", true},
+		{"ssh://git@ghe.example.com/org/service.git", "org", "service", true},
+		{"/srv/git/myapp", "", "", false},
+		{"https://github.com/user", "", "", false},
+	}
+
+	for _, tt := range tests {
+		owner, repo, ok := ParseRepo(tt.url)
+		if ok != tt.ok || owner != tt.owner || repo != tt.repo {
+			t.Errorf("ParseRepo(%q) = (%q, %q, %v), expected (%q, %q, %v)",
+				tt.url, owner, repo, ok, tt.owner, tt.repo, tt.ok)
+		}
+	}
+}
+
+func TestCreateStatus(t *testing.T) {
+	var received Status
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/org/service/statuses/abc123" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Missing authorization header")
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL+"/api/v3", "token", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	err = client.CreateStatus(context.Background(), "org", "service", "abc123", Status{
+		State:   StateSuccess,
+		Context: "binaryDeploy/service",
+	})
+	if err != nil {
+		t.Fatalf("CreateStatus failed: %v", err)
+	}
+	if received.State != StateSuccess || received.Context != "binaryDeploy/service" {
+		t.Errorf("Unexpected status payload: %+v", received)
+	}
+}
+
+func TestCheckRuns(t *testing.T) {
+	var requests []string
+	var updated CheckRun
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": 42}`))
+		case http.MethodPatch:
+			json.NewDecoder(r.Body).Decode(&updated)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL+"/api/v3", "token", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	id, err := client.CreateCheckRun(context.Background(), "org", "service", CheckRun{Name: "binaryDeploy/service", HeadSHA: "abc123", Status: CheckInProgress})
+	if err != nil || id != 42 {
+		t.Fatalf("CreateCheckRun = %d, %v; expected 42", id, err)
+	}
+	err = client.UpdateCheckRun(context.Background(), "org", "service", id, CheckRun{
+		Status:     CheckCompleted,
+		Conclusion: ConclusionFailure,
+		Output:     &CheckOutput{Title: "Deployment failed", Summary: "exit status 1", Text: "build log"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateCheckRun failed: %v", err)
+	}
+
+	expected := []string{"POST /api/v3/repos/org/service/check-runs", "PATCH /api/v3/repos/org/service/check-runs/42"}
+	if strings.Join(requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+	if updated.Conclusion != ConclusionFailure || updated.Output == nil || updated.Output.Text != "build log" {
+		t.Errorf("Unexpected update %+v", updated)
+	}
+}
+
+func TestTailOutput(t *testing.T) {
+	if got := TailOutput("short", 100); got != "short" {
+		t.Errorf("Expected short output unchanged, got %q", got)
+	}
+
+	output := strings.Repeat("compiling package\n", 100) + "error: undefined: foo\n"
+	got := TailOutput(output, 200)
+	if len(got) > 200 {
+		t.Errorf("Expected at most 200 bytes, got %d", len(got))
+	}
+	if !strings.HasPrefix(got, "... ") || !strings.HasSuffix(got, "error: undefined: foo\n") {
+		t.Errorf("Expected the tail with a truncation note, got %q", got)
+	}
+	if !strings.Contains(got, "truncated ...\ncompiling package\n") {
+		t.Errorf("Expected the kept output to start on a whole line, got %q", got)
+	}
+}
+
+func TestIssues(t *testing.T) {
+	var requests []string
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/v3")
+		requests = append(requests, r.Method+" "+path)
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		switch {
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`[{"number": 7}, {"number": 3}]`))
+		case path == "/repos/org/service/issues":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number": 12}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "token", "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	ctx := context.Background()
+
+	number, err := client.CreateIssue(ctx, "org", "service", Issue{Title: "Deployments failing", Body: "log"})
+	if err != nil || number != 12 {
+		t.Fatalf("CreateIssue = %d, %v; expected 12", number, err)
+	}
+	if err := client.CreateIssueComment(ctx, "org", "service", 12, "fixed"); err != nil {
+		t.Fatalf("CreateIssueComment failed: %v", err)
+	}
+	if err := client.CloseIssue(ctx, "org", "service", 12); err != nil {
+		t.Fatalf("CloseIssue failed: %v", err)
+	}
+	if err := client.CreateCommitComment(ctx, "org", "service", "abc123", "broken"); err != nil {
+		t.Fatalf("CreateCommitComment failed: %v", err)
+	}
+	pulls, err := client.CommitPullRequests(ctx, "org", "service", "abc123")
+	if err != nil || len(pulls) != 2 || pulls[0] != 7 {
+		t.Fatalf("CommitPullRequests = %v, %v; expected [7 3]", pulls, err)
+	}
+
+	expected := []string{
+		"POST /repos/org/service/issues",
+		"POST /repos/org/service/issues/12/comments",
+		"PATCH /repos/org/service/issues/12",
+		"POST /repos/org/service/commits/abc123/comments",
+		"GET /repos/org/service/commits/abc123/pulls",
+	}
+	if strings.Join(requests, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+	if bodies[1]["body"] != "fixed" || bodies[2]["state"] != "closed" {
+		t.Errorf("Unexpected request bodies %v", bodies)
+	}
+}
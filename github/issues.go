@@ -0,0 +1,60 @@
+package github
+
+import (
+	"context"
+	"fmt"
+)
+
+// Issue is a GitHub issue as sent to the API
+type Issue struct {
+	Title  string   `json:"title"`
+	Body   string   `json:"body,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// CreateIssue opens an issue and returns its number
+func (c *Client) CreateIssue(ctx context.Context, owner, repo string, issue Issue) (int, error) {
+	var created struct {
+		Number int `json:"number"`
+	}
+	path := fmt.Sprintf("/repos/%s/%s/issues", owner, repo)
+	if err := c.do(ctx, "POST", path, issue, &created); err != nil {
+		return 0, err
+	}
+	return created.Number, nil
+}
+
+// CloseIssue closes issue number, which may also be a pull request
+func (c *Client) CloseIssue(ctx context.Context, owner, repo string, number int) error {
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d", owner, repo, number)
+	return c.do(ctx, "PATCH", path, map[string]string{"state": "closed"}, nil)
+}
+
+// CreateIssueComment comments on issue or pull request number
+func (c *Client) CreateIssueComment(ctx context.Context, owner, repo string, number int, body string) error {
+	path := fmt.Sprintf("/repos/%s/%s/issues/%d/comments", owner, repo, number)
+	return c.do(ctx, "POST", path, map[string]string{"body": body}, nil)
+}
+
+// CreateCommitComment comments on commit sha
+func (c *Client) CreateCommitComment(ctx context.Context, owner, repo, sha, body string) error {
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s/comments", owner, repo, sha)
+	return c.do(ctx, "POST", path, map[string]string{"body": body}, nil)
+}
+
+// CommitPullRequests returns the numbers of the pull requests that contain
+// commit sha, open ones first as GitHub lists them
+func (c *Client) CommitPullRequests(ctx context.Context, owner, repo, sha string) ([]int, error) {
+	var pulls []struct {
+		Number int `json:"number"`
+	}
+	path := fmt.Sprintf("/repos/%s/%s/commits/%s/pulls", owner, repo, sha)
+	if err := c.do(ctx, "GET", path, nil, &pulls); err != nil {
+		return nil, err
+	}
+	numbers := make([]int, len(pulls))
+	for i, pull := range pulls {
+		numbers[i] = pull.Number
+	}
+	return numbers, nil
+}
@@ -0,0 +1,32 @@
+package github
+
+import (
+	"context"
+	"fmt"
+)
+
+// Commit status states accepted by the GitHub API
+const (
+	StatePending = "pending"
+	StateSuccess = "success"
+	StateFailure = "failure"
+	StateError   = "error"
+)
+
+// Status is a commit status as sent to the GitHub API
+type Status struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context,omitempty"`
+}
+
+// CreateStatus sets a commit status on sha
+func (c *Client) CreateStatus(ctx context.Context, owner, repo, sha string, status Status) error {
+	// GitHub rejects descriptions longer than 140 characters
+	if len(status.Description) > 140 {
+		status.Description = status.Description[:137] + "..."
+	}
+	path := fmt.Sprintf("/repos/%s/%s/statuses/%s", owner, repo, sha)
+	return c.do(ctx, "POST", path, status, nil)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"binaryDeploy/github"
+)
+
+// checkStreamInterval is how often the build output of a running deployment
+// is pushed to its check run
+const checkStreamInterval = 10 * time.Second
+
+// deploymentCheck is the GitHub check run of one deployment, showing its
+// build output as it runs
+type deploymentCheck struct {
+	client      *github.Client
+	owner, repo string
+	id          int64
+	output      *tailBuffer
+	stop        chan struct{}
+	done        chan struct{}
+}
+
+// startDeploymentCheck creates an in-progress check run on sha and starts
+// streaming output to it. It returns nil when check runs are disabled or
+// can't be created, in which case the caller falls back to commit statuses.
+func startDeploymentCheck(repoURL, sha string, output *tailBuffer) *deploymentCheck {
+	client := githubClient()
+	if client == nil || !appConfig().GitHubChecks || sha == "" {
+		return nil
+	}
+
+	owner, repo, ok := github.ParseRepo(repoURL)
+	if !ok {
+		slog.Debug("Skipping check run, repository URL not recognized", "repo_url", repoURL)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	id, err := client.CreateCheckRun(ctx, owner, repo, github.CheckRun{
+		Name:      "binaryDeploy/" + appConfig().AppName,
+		HeadSHA:   sha,
+		Status:    github.CheckInProgress,
+		StartedAt: &now,
+		Output:    &github.CheckOutput{Title: "Deployment in progress", Summary: "Deploying " + shortSHA(sha)},
+	})
+	if err != nil {
+		slog.Warn("Failed to create check run, reporting a commit status instead", "repo", owner+"/"+repo, "sha", sha, "error", err)
+		return nil
+	}
+
+	check := &deploymentCheck{
+		client: client,
+		owner:  owner,
+		repo:   repo,
+		id:     id,
+		output: output,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go check.stream()
+	return check
+}
+
+// stream pushes the build output to the check run whenever it has grown,
+// until finish is called
+func (c *deploymentCheck) stream() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(checkStreamInterval)
+	defer ticker.Stop()
+
+	var sent string
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		text := c.text()
+		if text == sent {
+			continue
+		}
+		c.update(github.CheckRun{
+			Output: &github.CheckOutput{Title: "Deployment in progress", Summary: "Building", Text: text},
+		})
+		sent = text
+	}
+}
+
+// finish completes the check run with the deployment's outcome and the full
+// kept build output
+func (c *deploymentCheck) finish(err error) {
+	close(c.stop)
+	<-c.done
+
+	now := time.Now()
+	run := github.CheckRun{
+		Status:      github.CheckCompleted,
+		Conclusion:  github.ConclusionSuccess,
+		CompletedAt: &now,
+		Output:      &github.CheckOutput{Title: "Deployed successfully", Summary: "Deployed successfully", Text: c.text()},
+	}
+	if err != nil {
+		run.Conclusion = github.ConclusionFailure
+		run.Output.Title = "Deployment failed"
+		run.Output.Summary = github.TailOutput(secretRedactor.Redact(err.Error()), github.MaxCheckOutputText)
+	}
+	c.update(run)
+}
+
+func (c *deploymentCheck) update(run github.CheckRun) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := c.client.UpdateCheckRun(ctx, c.owner, c.repo, c.id, run); err != nil {
+		slog.Warn("Failed to update check run", "repo", c.owner+"/"+c.repo, "check_run", c.id, "error", err)
+	}
+}
+
+// text is the redacted build output so far as a code block, keeping its end
+// if it is longer than GitHub allows
+func (c *deploymentCheck) text() string {
+	output := secretRedactor.Redact(string(c.output.Bytes()))
+	if output == "" {
+		return ""
+	}
+	const fence = "````\n"
+	return fence + github.TailOutput(output, github.MaxCheckOutputText-2*len(fence)-1) + "\n" + fence
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"binaryDeploy/github"
+	"binaryDeploy/history"
+)
+
+// failureExcerptBytes is how much of the end of the error and of the build
+// output a failure report quotes, well within GitHub's limit on comments
+const failureExcerptBytes = 16 << 10
+
+// failureIssues holds the issue opened for each failing branch, so the
+// deployment that fixes the branch can close it. It is lost on restart, which
+// leaves the issue for developers to close.
+var failureIssues = struct {
+	sync.Mutex
+	numbers map[string]int
+}{numbers: make(map[string]int)}
+
+// reportRepeatedFailures tells developers on GitHub when deployments of a
+// branch have failed github_failure_threshold times in a row, by opening an
+// issue or commenting on the pull request or commit that broke the branch.
+// It reports once per run of failures; rec must already be in the history.
+func reportRepeatedFailures(rec history.Record, buildOutput []byte) {
+	client := githubClient()
+	if client == nil || appConfig().GitHubFailureThreshold == 0 || rec.Kind != history.KindDeploy {
+		return
+	}
+
+	repoURL := rec.Repo
+	if repoURL == "" {
+		repoURL = appConfig().TargetRepoURL
+	}
+	owner, repo, ok := github.ParseRepo(repoURL)
+	if !ok {
+		slog.Debug("Skipping failure report, repository URL not recognized", "repo_url", repoURL)
+		return
+	}
+
+	if rec.Result == history.ResultSuccess {
+		closeFailureIssue(client, owner, repo, rec)
+		return
+	}
+
+	if deployHistory == nil {
+		return
+	}
+	threshold := appConfig().GitHubFailureThreshold
+	recent, err := deployHistory.Query(threshold+1, func(r history.Record) bool {
+		return r.App == rec.App && r.Kind == history.KindDeploy && r.Branch == rec.Branch
+	})
+	if err != nil {
+		slog.Warn("Failed to read deployment history for failure report", "error", err)
+		return
+	}
+	failures := 0
+	for _, r := range recent {
+		if r.Result != history.ResultFailure {
+			break
+		}
+		failures++
+	}
+	if failures != threshold {
+		return
+	}
+
+	// The oldest failure of the run is the commit that broke the branch
+	breaking := recent[threshold-1].Commit
+	if breaking == "" {
+		breaking = rec.Commit
+	}
+	body := failureReportBody(rec, threshold, breaking, buildOutput)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if appConfig().GitHubFailureReport == "comment" {
+		commentOnBreakingChange(ctx, client, owner, repo, breaking, body)
+		return
+	}
+
+	number, err := client.CreateIssue(ctx, owner, repo, github.Issue{
+		Title:  fmt.Sprintf("Deployments of %s to %s are failing", branchLabel(rec.Branch), appConfig().AppName),
+		Body:   body,
+		Labels: appConfig().GitHubFailureLabels,
+	})
+	if err != nil {
+		slog.Error("Failed to open issue for failing deployments", "repo", owner+"/"+repo, "error", err)
+		return
+	}
+	failureIssues.Lock()
+	failureIssues.numbers[rec.Branch] = number
+	failureIssues.Unlock()
+	slog.Warn("Opened issue for failing deployments", "repo", owner+"/"+repo, "issue", number, "branch", rec.Branch, "failures", failures)
+}
+
+// commentOnBreakingChange comments on the pull request that merged the
+// breaking commit, or on the commit itself if it wasn't merged by one
+func commentOnBreakingChange(ctx context.Context, client *github.Client, owner, repo, sha, body string) {
+	if sha == "" {
+		slog.Warn("Skipping failure report, the failing commit is unknown")
+		return
+	}
+
+	pulls, err := client.CommitPullRequests(ctx, owner, repo, sha)
+	if err != nil {
+		slog.Warn("Failed to find the pull request of the failing commit", "sha", sha, "error", err)
+	}
+	if len(pulls) > 0 {
+		err = client.CreateIssueComment(ctx, owner, repo, pulls[0], body)
+	} else {
+		err = client.CreateCommitComment(ctx, owner, repo, sha, body)
+	}
+	if err != nil {
+		slog.Error("Failed to comment on the failing commit", "repo", owner+"/"+repo, "sha", sha, "error", err)
+		return
+	}
+	slog.Warn("Commented on the failing commit", "repo", owner+"/"+repo, "sha", sha, "pull_requests", pulls)
+}
+
+// closeFailureIssue closes the issue opened for rec's branch now that it
+// deploys again
+func closeFailureIssue(client *github.Client, owner, repo string, rec history.Record) {
+	failureIssues.Lock()
+	number, ok := failureIssues.numbers[rec.Branch]
+	delete(failureIssues.numbers, rec.Branch)
+	failureIssues.Unlock()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	body := fmt.Sprintf("%s deployed %s successfully in commit %s.", appConfig().AppName, branchLabel(rec.Branch), shortSHA(rec.Commit))
+	if err := client.CreateIssueComment(ctx, owner, repo, number, body); err != nil {
+		slog.Warn("Failed to comment on failing deployments issue", "issue", number, "error", err)
+	}
+	if err := client.CloseIssue(ctx, owner, repo, number); err != nil {
+		slog.Error("Failed to close failing deployments issue", "issue", number, "error", err)
+		return
+	}
+	slog.Info("Closed failing deployments issue", "repo", owner+"/"+repo, "issue", number, "branch", rec.Branch)
+}
+
+// failureReportBody describes the run of failures in Markdown with the last
+// error and the end of the build output
+func failureReportBody(rec history.Record, failures int, breaking string, buildOutput []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The last %d deployments of %s to **%s** failed.\n\n", failures, branchLabel(rec.Branch), appConfig().AppName)
+	if breaking != "" {
+		fmt.Fprintf(&b, "- First failing commit: %s\n", breaking)
+	}
+	if rec.Commit != "" && rec.Commit != breaking {
+		fmt.Fprintf(&b, "- Latest failing commit: %s\n", rec.Commit)
+	}
+	if rec.Bundle != "" {
+		fmt.Fprintf(&b, "- Post-mortem bundle on the server: `%s`\n", rec.Bundle)
+	}
+
+	fmt.Fprintf(&b, "\nLast error:\n\n````\n%s\n````\n", github.TailOutput(secretRedactor.Redact(rec.Error), failureExcerptBytes))
+	if output := secretRedactor.Redact(string(buildOutput)); output != "" {
+		fmt.Fprintf(&b, "\nEnd of the build output:\n\n````\n%s\n````\n", github.TailOutput(output, failureExcerptBytes))
+	}
+	return b.String()
+}
+
+// branchLabel formats branch for a report, as branches are empty for e.g.
+// manual deployments of the current checkout
+func branchLabel(branch string) string {
+	if branch == "" {
+		return "the current checkout"
+	}
+	return "`" + branch + "`"
+}
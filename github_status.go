@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"binaryDeploy/github"
+)
+
+// setupGitHub creates the GitHub API client when a token is configured
+func setupGitHub() {
+	if appConfig().GitHubToken == "" {
+		return
+	}
+
+	client, err := github.NewClient(appConfig().GitHubAPIURL, appConfig().GitHubToken, appConfig().GitHubCABundle)
+	if err != nil {
+		slog.Error("Failed to set up GitHub integration, commit statuses disabled", "error", err)
+		return
+	}
+	currentGitHubClient.Store(client)
+	slog.Info("GitHub integration enabled", "api_url", client.BaseURL)
+}
+
+// reportCommitStatus publishes the deployment state of sha as a GitHub commit
+// status. It is a no-op when the integration is disabled or the repository
+// URL isn't a GitHub-style URL.
+func reportCommitStatus(repoURL, sha, state, description string) {
+	client := githubClient()
+	if client == nil || sha == "" {
+		return
+	}
+
+	owner, repo, ok := github.ParseRepo(repoURL)
+	if !ok {
+		slog.Debug("Skipping commit status, repository URL not recognized", "repo_url", repoURL)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	err := client.CreateStatus(ctx, owner, repo, sha, github.Status{
+		State:       state,
+		Description: description,
+		Context:     "binaryDeploy/" + appConfig().AppName,
+	})
+	if err != nil {
+		slog.Warn("Failed to report commit status", "repo", owner+"/"+repo, "sha", sha, "state", state, "error", err)
+	}
+}
+
+// gitCommitSubject returns the subject line of commit in repoDir, or "" if
+// it can't be read
+func gitCommitSubject(repoDir, commit string) string {
+	if commit == "" {
+		return ""
+	}
+	out, err := exec.Command("git", "-C", repoDir, "log", "-1", "--format=%s", commit, "--").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// gitHeadCommit returns the commit currently checked out in repoDir
+func gitHeadCommit(repoDir string) string {
+	out, err := exec.Command("git", "-C", repoDir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
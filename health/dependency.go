@@ -0,0 +1,231 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPorts are used for dependency URLs that don't name a port
+var defaultPorts = map[string]string{
+	"tcp":        "",
+	"redis":      "6379",
+	"rediss":     "6380",
+	"postgres":   "5432",
+	"postgresql": "5432",
+	"mysql":      "3306",
+	"mongodb":    "27017",
+	"amqp":       "5672",
+	"memcached":  "11211",
+}
+
+// Dependency is an external service the application needs
+type Dependency struct {
+	Name   string
+	Target string // http(s)://..., tcp://host:port, redis://host, postgres://host, ...
+}
+
+// ValidateTarget checks that target is a URL CheckDependency understands
+func ValidateTarget(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid dependency %q: expected a URL with a host", DisplayTarget(target))
+	}
+	if u.Scheme == "http" || u.Scheme == "https" {
+		return nil
+	}
+	port, known := defaultPorts[u.Scheme]
+	if !known {
+		return fmt.Errorf("invalid dependency %q: unsupported scheme %q", u.Redacted(), u.Scheme)
+	}
+	if port == "" && u.Port() == "" {
+		return fmt.Errorf("invalid dependency %q: a port is required", u.Redacted())
+	}
+	return nil
+}
+
+// DisplayTarget returns target with any password masked
+func DisplayTarget(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	return u.Redacted()
+}
+
+// CheckDependency reports whether the service at target is reachable. HTTP
+// URLs must return 2xx, Redis must answer PING, anything else must accept a
+// TCP connection.
+func CheckDependency(ctx context.Context, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "http" || u.Scheme == "https" {
+		return Check(ctx, target)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultPorts[u.Scheme])
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if u.Scheme == "redis" {
+		return redisPing(ctx, conn)
+	}
+	return nil
+}
+
+// redisPing sends PING and accepts any reply; an authentication error still
+// means the server is up
+func redisPing(ctx context.Context, conn net.Conn) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("no reply to PING: %w", err)
+	}
+	if !strings.HasPrefix(reply, "+") && !strings.HasPrefix(reply, "-") {
+		return fmt.Errorf("unexpected reply to PING: %q", strings.TrimSpace(reply))
+	}
+	return nil
+}
+
+// DependencyStatus is the latest result of checking one dependency
+type DependencyStatus struct {
+	Name      string    `json:"name"`
+	Target    string    `json:"target"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Since     time.Time `json:"since"` // When Healthy last changed
+}
+
+// DependencyMonitor polls dependencies and keeps their latest status
+type DependencyMonitor struct {
+	deps     []Dependency
+	interval time.Duration
+	timeout  time.Duration
+	onChange func(DependencyStatus)
+
+	mu       sync.RWMutex
+	statuses map[string]DependencyStatus
+}
+
+// NewDependencyMonitor checks deps every interval, giving each check up to
+// timeout. onChange, if set, is called when a dependency goes down or comes
+// back up.
+func NewDependencyMonitor(deps []Dependency, interval, timeout time.Duration, onChange func(DependencyStatus)) *DependencyMonitor {
+	return &DependencyMonitor{
+		deps:     deps,
+		interval: interval,
+		timeout:  timeout,
+		onChange: onChange,
+		statuses: make(map[string]DependencyStatus),
+	}
+}
+
+// Run checks all dependencies immediately and then every interval until ctx
+// is done
+func (m *DependencyMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.CheckAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckAll checks every dependency concurrently and records the results
+func (m *DependencyMonitor) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, dep := range m.deps {
+		wg.Add(1)
+		go func(dep Dependency) {
+			defer wg.Done()
+			m.check(ctx, dep)
+		}(dep)
+	}
+	wg.Wait()
+}
+
+func (m *DependencyMonitor) check(ctx context.Context, dep Dependency) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := CheckDependency(ctx, dep.Target)
+	status := DependencyStatus{
+		Name:      dep.Name,
+		Target:    DisplayTarget(dep.Target),
+		Healthy:   err == nil,
+		LatencyMS: time.Since(start).Milliseconds(),
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	m.mu.Lock()
+	prev, seen := m.statuses[dep.Name]
+	status.Since = status.CheckedAt
+	if seen && prev.Healthy == status.Healthy {
+		status.Since = prev.Since
+	}
+	m.statuses[dep.Name] = status
+	m.mu.Unlock()
+
+	// The first failing check is a change too; the first passing one isn't
+	if m.onChange != nil && ((seen && prev.Healthy != status.Healthy) || (!seen && !status.Healthy)) {
+		m.onChange(status)
+	}
+}
+
+// Statuses returns the latest status of every checked dependency by name
+func (m *DependencyMonitor) Statuses() []DependencyStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]DependencyStatus, 0, len(m.statuses))
+	for _, s := range m.statuses {
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Unhealthy returns the statuses of the named dependencies that failed their
+// last check. Dependencies that haven't been checked yet are not included.
+func (m *DependencyMonitor) Unhealthy(names []string) []DependencyStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var unhealthy []DependencyStatus
+	for _, name := range names {
+		if s, ok := m.statuses[name]; ok && !s.Healthy {
+			unhealthy = append(unhealthy, s)
+		}
+	}
+	return unhealthy
+}
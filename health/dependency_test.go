@@ -0,0 +1,115 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckDependency_HTTP(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	if err := CheckDependency(context.Background(), ok.URL); err != nil {
+		t.Errorf("expected a healthy dependency, got %v", err)
+	}
+	if err := CheckDependency(context.Background(), failing.URL); err == nil {
+		t.Error("expected a 503 to be unhealthy")
+	}
+}
+
+func TestCheckDependency_TCPAndRedis(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if line, _ := bufio.NewReader(conn).ReadString('\n'); line == "PING\r\n" {
+					conn.Write([]byte("-NOAUTH Authentication required.\r\n"))
+				}
+			}()
+		}
+	}()
+	addr := l.Addr().String()
+
+	if err := CheckDependency(context.Background(), "tcp://"+addr); err != nil {
+		t.Errorf("expected the TCP check to pass, got %v", err)
+	}
+	if err := CheckDependency(context.Background(), "redis://"+addr); err != nil {
+		t.Errorf("expected a Redis asking for auth to count as up, got %v", err)
+	}
+
+	l.Close()
+	if err := CheckDependency(context.Background(), "postgres://"+addr); err == nil {
+		t.Error("expected a closed port to be unhealthy")
+	}
+}
+
+func TestValidateTarget(t *testing.T) {
+	for _, target := range []string{"https://api.example.com/health", "redis://cache", "postgres://db.internal", "tcp://10.0.0.5:9000"} {
+		if err := ValidateTarget(target); err != nil {
+			t.Errorf("ValidateTarget(%q) = %v", target, err)
+		}
+	}
+	for _, target := range []string{"db.internal:5432", "tcp://host", "ftp://files.example.com"} {
+		if err := ValidateTarget(target); err == nil {
+			t.Errorf("expected %q to be rejected", target)
+		}
+	}
+	if got := DisplayTarget("postgres://app:hunter2@db:5432/app"); got != "postgres://app:xxxxx@db:5432/app" {
+		t.Errorf("DisplayTarget = %q", got)
+	}
+}
+
+func TestDependencyMonitor_ReportsChanges(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var changes []DependencyStatus
+	m := NewDependencyMonitor([]Dependency{{Name: "api", Target: srv.URL}}, time.Minute, time.Second, func(s DependencyStatus) {
+		changes = append(changes, s)
+	})
+
+	m.CheckAll(context.Background())
+	m.CheckAll(context.Background())
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes while healthy, got %+v", changes)
+	}
+
+	healthy.Store(false)
+	m.CheckAll(context.Background())
+	if len(changes) != 1 || changes[0].Healthy {
+		t.Fatalf("expected one down change, got %+v", changes)
+	}
+
+	statuses := m.Statuses()
+	if len(statuses) != 1 || statuses[0].Healthy || statuses[0].Error == "" {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+	if unhealthy := m.Unhealthy([]string{"api", "unknown"}); len(unhealthy) != 1 || unhealthy[0].Name != "api" {
+		t.Errorf("expected only api to be unhealthy, got %+v", unhealthy)
+	}
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// client is shared by health checks and warm-up requests
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Check performs a single GET against url and reports whether it returned 2xx
+func Check(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unhealthy status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// WaitForHealthy polls url until it returns 2xx or ctx expires
+func WaitForHealthy(ctx context.Context, url string, interval time.Duration) error {
+	var lastErr error
+	for {
+		if lastErr = Check(ctx, url); lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("health check %s did not pass: %w", url, lastErr)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WarmupResult records the outcome of a single warm-up request
+type WarmupResult struct {
+	URL        string        `json:"url"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// Warmup requests each URL in order so the application can prime caches before
+// it takes traffic. Failures are reported but don't stop the remaining requests.
+func Warmup(ctx context.Context, urls []string) []WarmupResult {
+	results := make([]WarmupResult, 0, len(urls))
+	for _, url := range urls {
+		result := WarmupResult{URL: url}
+		start := time.Now()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err == nil {
+			var resp *http.Response
+			if resp, err = client.Do(req); err == nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				result.StatusCode = resp.StatusCode
+			}
+		}
+		result.Duration = time.Since(start)
+
+		if err != nil {
+			result.Error = err.Error()
+			slog.Warn("Warm-up request failed", "url", url, "error", err)
+		} else {
+			slog.Info("Warm-up request completed", "url", url, "status", result.StatusCode, "duration", result.Duration.String())
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,33 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Baseline summarizes how long recent comparable deployments took
+type Baseline struct {
+	Median  time.Duration
+	Samples int
+}
+
+// DurationBaseline returns the median duration of records. Callers pass the
+// successful deployments to compare against, e.g. from Query.
+func DurationBaseline(records []Record) Baseline {
+	if len(records) == 0 {
+		return Baseline{}
+	}
+
+	durations := make([]int64, len(records))
+	for i, rec := range records {
+		durations[i] = rec.DurationMS
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	mid := len(durations) / 2
+	median := durations[mid]
+	if len(durations)%2 == 0 {
+		median = (durations[mid-1] + durations[mid]) / 2
+	}
+	return Baseline{Median: time.Duration(median) * time.Millisecond, Samples: len(records)}
+}
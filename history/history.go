@@ -0,0 +1,234 @@
+package history
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"binaryDeploy/kv"
+	"binaryDeploy/releasenotes"
+)
+
+// Record kinds
+const (
+	KindDeploy     = "deploy"
+	KindRestart    = "restart"
+	KindSelfUpdate = "self-update" // binaryDeploy updating itself
+)
+
+// Tags assigned automatically from what triggered a deployment. Operators
+// may add their own (hotfix, rollback, ...) on manual deployments.
+const (
+	TagWebhook   = "webhook"
+	TagManual    = "manual"
+	TagChained   = "chained"
+	TagScheduled = "scheduled"
+	TagAutoStart = "auto-start"
+	TagFrozen    = "queued-during-freeze"
+
+	TagDependencyOverride = "dependency-override"
+	TagChatOps            = "chatops"
+	TagLocalWatch         = "local-watch"
+	TagArtifactDrop       = "artifact-drop"
+	TagUnchangedBuild     = "unchanged-build" // Restart skipped, the build matched the running one
+	TagRolledBack         = "rolled-back"     // The new build failed and the previous one was restarted
+)
+
+// Deployment results
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultSkipped = "skipped"
+)
+
+// Record describes one finished deployment or restart
+type Record struct {
+	ID          string    `json:"id"`
+	App         string    `json:"app"`
+	Kind        string    `json:"kind"`
+	Trigger     string    `json:"trigger,omitempty"`
+	TriggeredBy string    `json:"triggered_by,omitempty"`
+	Repo        string    `json:"repo,omitempty"`
+	Branch      string    `json:"branch,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+	Result      string    `json:"result"`
+	Error       string    `json:"error,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	DurationMS  int64     `json:"duration_ms"`
+
+	// CorrelationID tags the log entries and notifications of the deployment
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	// Chain lists the upstream apps whose deployments led to this one,
+	// outermost first
+	Chain      []string           `json:"chain,omitempty"`
+	Downstream []DownstreamResult `json:"downstream,omitempty"`
+
+	// Steps is the per-step breakdown recorded when profiling is enabled
+	Steps []StepTiming `json:"steps,omitempty"`
+
+	// Tags label the deployment for later analysis (hotfix, rollback, ...)
+	Tags []string `json:"tags,omitempty"`
+
+	// Issues are the issue tracker keys mentioned by the deployed commits
+	Issues []string `json:"issues,omitempty"`
+
+	// ReleaseNotes lists the commits deployed since the previous deployment
+	ReleaseNotes *releasenotes.Notes `json:"release_notes,omitempty"`
+
+	// Version is the semantic version tag of the deployed commit
+	Version string `json:"version,omitempty"`
+
+	// Artifact is the file name of a build dropped into incoming_dir, for
+	// deployments of one instead of a commit
+	Artifact string `json:"artifact,omitempty"`
+
+	// Bundle is the post-mortem archive of a failed deployment, relative to
+	// the deploy directory
+	Bundle string `json:"bundle,omitempty"`
+}
+
+// HasTag reports whether the record carries tag
+func (r Record) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// StepTiming is how long one pipeline step (fetch, build, start...) took
+type StepTiming struct {
+	Name           string `json:"name"`
+	DurationMS     int64  `json:"duration_ms"`
+	HeapDeltaBytes int64  `json:"heap_delta_bytes"`
+	Failed         bool   `json:"failed,omitempty"`
+}
+
+// DownstreamResult is the outcome of a chained deployment triggered after
+// this one
+type DownstreamResult struct {
+	URL    string `json:"url"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bucket holds one record per deployment, keyed by when it was appended
+const bucket = "deployments"
+
+// Store keeps records in a key/value store, in the order they were appended
+type Store struct {
+	mu   sync.Mutex
+	db   kv.Store
+	last int64
+}
+
+// NewStore returns a store backed by db
+func NewStore(db kv.Store) (*Store, error) {
+	entries, err := db.List(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	s := &Store{db: db}
+	if len(entries) > 0 {
+		s.last, _ = strconv.ParseInt(entries[len(entries)-1].Key, 10, 64)
+	}
+	return s, nil
+}
+
+// NewID returns a random identifier for a record
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// Append stores rec after every earlier record, filling in the ID and
+// duration when unset
+func (s *Store) Append(rec Record) error {
+	if rec.ID == "" {
+		rec.ID = NewID()
+	}
+	if rec.DurationMS == 0 && !rec.FinishedAt.IsZero() {
+		rec.DurationMS = rec.FinishedAt.Sub(rec.StartedAt).Milliseconds()
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding history record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Keys are fixed-width nanosecond timestamps so key order is append
+	// order, even when the clock steps back
+	seq := max(time.Now().UnixNano(), s.last+1)
+	if err := s.db.Put(bucket, fmt.Sprintf("%019d", seq), data); err != nil {
+		return fmt.Errorf("writing history record: %w", err)
+	}
+	s.last = seq
+	return nil
+}
+
+// Export returns a consistent copy of the history, one JSON record per line,
+// for shipping off the host
+func (s *Store) Export() ([]byte, error) {
+	entries, err := s.db.List(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.Write(entry.Value)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// Recent returns up to n records, newest first. n <= 0 returns all records.
+func (s *Store) Recent(n int) ([]Record, error) {
+	return s.Query(n, nil)
+}
+
+// Query returns up to n records matching match, newest first. A nil match
+// accepts every record; n <= 0 returns all matches.
+func (s *Store) Query(n int, match func(Record) bool) ([]Record, error) {
+	entries, err := s.db.List(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+
+	var records []Record
+	for _, entry := range entries {
+		var rec Record
+		if err := json.Unmarshal(entry.Value, &rec); err != nil {
+			continue // Skip records written by an incompatible version
+		}
+		if match != nil && !match(rec) {
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	// Newest first
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if n > 0 && len(records) > n {
+		records = records[:n]
+	}
+	if records == nil {
+		records = []Record{}
+	}
+	return records, nil
+}
@@ -0,0 +1,154 @@
+package history
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/kv"
+)
+
+func TestStore_AppendAndRecent(t *testing.T) {
+	store := newTestStore(t)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, commit := range []string{"aaa", "bbb", "ccc"} {
+		err := store.Append(Record{
+			App:        "myapp",
+			Kind:       KindDeploy,
+			Commit:     commit,
+			Result:     ResultSuccess,
+			StartedAt:  start.Add(time.Duration(i) * time.Minute),
+			FinishedAt: start.Add(time.Duration(i)*time.Minute + 1500*time.Millisecond),
+		})
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	records, err := store.Recent(2)
+	if err != nil {
+		t.Fatalf("Recent failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].Commit != "ccc" || records[1].Commit != "bbb" {
+		t.Errorf("expected newest first, got %s, %s", records[0].Commit, records[1].Commit)
+	}
+	if records[0].ID == "" {
+		t.Error("expected ID to be assigned")
+	}
+	if records[0].DurationMS != 1500 {
+		t.Errorf("expected duration 1500ms, got %d", records[0].DurationMS)
+	}
+
+	all, err := store.Recent(0)
+	if err != nil {
+		t.Fatalf("Recent failed: %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("expected 3 records, got %d", len(all))
+	}
+}
+
+func TestStore_RecentEmpty(t *testing.T) {
+	store := newTestStore(t)
+
+	records, err := store.Recent(10)
+	if err != nil {
+		t.Fatalf("Recent failed: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("expected no records, got %d", len(records))
+	}
+}
+
+func TestStore_Reopen(t *testing.T) {
+	db, err := kv.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, _ := NewStore(db)
+	store.Append(Record{Commit: "aaa"})
+	store.Append(Record{Commit: "bbb"})
+
+	store, err = NewStore(db)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	store.Append(Record{Commit: "ccc"})
+
+	records, _ := store.Recent(0)
+	if len(records) != 3 || records[0].Commit != "ccc" {
+		t.Errorf("expected appends after reopening to sort last, got %+v", records)
+	}
+}
+
+func TestStore_QueryByTag(t *testing.T) {
+	store := newTestStore(t)
+
+	store.Append(Record{Commit: "aaa", Tags: []string{"webhook"}})
+	store.Append(Record{Commit: "bbb", Tags: []string{"manual", "hotfix"}})
+	store.Append(Record{Commit: "ccc", Tags: []string{"webhook", "hotfix"}})
+
+	records, err := store.Query(0, func(r Record) bool { return r.HasTag("hotfix") })
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Commit != "ccc" || records[1].Commit != "bbb" {
+		t.Errorf("expected hotfix deployments newest first, got %+v", records)
+	}
+
+	records, _ = store.Query(1, func(r Record) bool { return r.HasTag("webhook") })
+	if len(records) != 1 || records[0].Commit != "ccc" {
+		t.Errorf("expected the newest webhook deployment only, got %+v", records)
+	}
+}
+
+func TestStore_Export(t *testing.T) {
+	store := newTestStore(t)
+
+	if data, err := store.Export(); err != nil || len(data) != 0 {
+		t.Fatalf("expected an empty export before any records, got %q, %v", data, err)
+	}
+
+	store.Append(Record{Commit: "aaa"})
+	store.Append(Record{Commit: "bbb"})
+
+	data, err := store.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("expected 2 exported lines, got %d", lines)
+	}
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := kv.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	store, err := NewStore(db)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	return store
+}
+
+func TestDurationBaseline(t *testing.T) {
+	records := []Record{{DurationMS: 40}, {DurationMS: 10}, {DurationMS: 30}, {DurationMS: 1000}}
+
+	b := DurationBaseline(records)
+	if b.Median != 35*time.Millisecond || b.Samples != 4 {
+		t.Errorf("expected median 35ms over 4 samples, got %+v", b)
+	}
+	if b := DurationBaseline(records[:3]); b.Median != 30*time.Millisecond {
+		t.Errorf("expected median 30ms, got %v", b.Median)
+	}
+	if b := DurationBaseline(nil); b.Samples != 0 || b.Median != 0 {
+		t.Errorf("expected an empty baseline, got %+v", b)
+	}
+}
@@ -0,0 +1,188 @@
+// Package incident opens and resolves incidents in PagerDuty or Opsgenie
+package incident
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Incident describes something that needs a human. Key identifies it so a
+// later Resolve (or a repeated Trigger) refers to the same incident.
+type Incident struct {
+	Key      string
+	Summary  string
+	Source   string // Host or application the incident is about
+	Severity string // critical, error, warning or info
+	Details  map[string]interface{}
+}
+
+// Provider opens and resolves incidents in an on-call service
+type Provider interface {
+	Trigger(ctx context.Context, inc Incident) error
+	Resolve(ctx context.Context, key string) error
+}
+
+// client is shared by the providers
+var client = &http.Client{Timeout: 15 * time.Second}
+
+// DefaultPagerDutyURL is the PagerDuty Events API v2 endpoint
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty sends events to a PagerDuty service integration
+type PagerDuty struct {
+	URL        string
+	RoutingKey string
+}
+
+// NewPagerDuty creates a provider for the integration with routingKey
+func NewPagerDuty(routingKey string) *PagerDuty {
+	return &PagerDuty{URL: DefaultPagerDutyURL, RoutingKey: routingKey}
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+// Trigger opens an incident, or adds to the open one with the same key
+func (p *PagerDuty) Trigger(ctx context.Context, inc Incident) error {
+	severity := inc.Severity
+	if severity == "" {
+		severity = "error"
+	}
+	return post(ctx, p.URL, nil, pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    inc.Key,
+		Payload: &pagerDutyPayload{
+			Summary:       truncate(inc.Summary, 1024),
+			Source:        inc.Source,
+			Severity:      severity,
+			CustomDetails: inc.Details,
+		},
+	})
+}
+
+// Resolve resolves the incident with key
+func (p *PagerDuty) Resolve(ctx context.Context, key string) error {
+	return post(ctx, p.URL, nil, pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    key,
+	})
+}
+
+// DefaultOpsgenieURL is the Opsgenie API for accounts in the US region; EU
+// accounts use https://api.eu.opsgenie.com
+const DefaultOpsgenieURL = "https://api.opsgenie.com"
+
+// Opsgenie creates alerts through the Opsgenie Alert API
+type Opsgenie struct {
+	URL    string
+	APIKey string
+}
+
+// NewOpsgenie creates a provider for the API at baseURL (DefaultOpsgenieURL
+// when empty) with an API integration key
+func NewOpsgenie(baseURL, apiKey string) *Opsgenie {
+	if baseURL == "" {
+		baseURL = DefaultOpsgenieURL
+	}
+	return &Opsgenie{URL: baseURL, APIKey: apiKey}
+}
+
+// opsgeniePriorities maps severities to alert priorities
+var opsgeniePriorities = map[string]string{
+	"critical": "P1",
+	"error":    "P2",
+	"warning":  "P3",
+	"info":     "P5",
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	Priority    string            `json:"priority,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// Trigger creates an alert; Opsgenie deduplicates open alerts by alias
+func (o *Opsgenie) Trigger(ctx context.Context, inc Incident) error {
+	details := make(map[string]string, len(inc.Details))
+	for k, v := range inc.Details {
+		details[k] = fmt.Sprint(v)
+	}
+	priority, ok := opsgeniePriorities[inc.Severity]
+	if !ok {
+		priority = "P2"
+	}
+	return post(ctx, o.URL+"/v2/alerts", o.headers(), opsgenieAlert{
+		Message:     truncate(inc.Summary, 130),
+		Alias:       inc.Key,
+		Description: inc.Summary,
+		Source:      inc.Source,
+		Priority:    priority,
+		Details:     details,
+	})
+}
+
+// Resolve closes the alert with alias key
+func (o *Opsgenie) Resolve(ctx context.Context, key string) error {
+	endpoint := o.URL + "/v2/alerts/" + url.PathEscape(key) + "/close?identifierType=alias"
+	return post(ctx, endpoint, o.headers(), map[string]string{"source": "binaryDeploy"})
+}
+
+func (o *Opsgenie) headers() http.Header {
+	return http.Header{"Authorization": {"GenieKey " + o.APIKey}}
+}
+
+// post sends body as JSON and expects a 2xx response
+func post(ctx context.Context, endpoint string, header http.Header, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
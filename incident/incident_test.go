@@ -0,0 +1,96 @@
+package incident
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type captured struct {
+	path  string
+	auth  string
+	query string
+	body  map[string]interface{}
+}
+
+func captureServer(t *testing.T, got *[]captured) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := captured{path: r.URL.Path, auth: r.Header.Get("Authorization"), query: r.URL.RawQuery}
+		json.NewDecoder(r.Body).Decode(&c.body)
+		*got = append(*got, c)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPagerDuty_TriggerAndResolve(t *testing.T) {
+	var got []captured
+	srv := captureServer(t, &got)
+	pd := &PagerDuty{URL: srv.URL, RoutingKey: "R0UT1NG"}
+
+	inc := Incident{Key: "myapp/deployments", Summary: "myapp failed 3 deployments in a row", Source: "myapp",
+		Details: map[string]interface{}{"failures": 3}}
+	if err := pd.Trigger(context.Background(), inc); err != nil {
+		t.Fatal(err)
+	}
+	if err := pd.Resolve(context.Background(), inc.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(got))
+	}
+	trigger := got[0].body
+	if trigger["event_action"] != "trigger" || trigger["dedup_key"] != "myapp/deployments" || trigger["routing_key"] != "R0UT1NG" {
+		t.Errorf("unexpected trigger %v", trigger)
+	}
+	payload := trigger["payload"].(map[string]interface{})
+	if payload["severity"] != "error" || payload["source"] != "myapp" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+	if got[1].body["event_action"] != "resolve" || got[1].body["payload"] != nil {
+		t.Errorf("unexpected resolve %v", got[1].body)
+	}
+}
+
+func TestOpsgenie_TriggerAndResolve(t *testing.T) {
+	var got []captured
+	srv := captureServer(t, &got)
+	og := NewOpsgenie(srv.URL, "key123")
+
+	inc := Incident{Key: "myapp/crash-loop", Summary: "myapp is crash-looping", Severity: "critical",
+		Details: map[string]interface{}{"crashes": 5}}
+	if err := og.Trigger(context.Background(), inc); err != nil {
+		t.Fatal(err)
+	}
+	if err := og.Resolve(context.Background(), inc.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	if got[0].path != "/v2/alerts" || got[0].auth != "GenieKey key123" {
+		t.Errorf("unexpected create request %+v", got[0])
+	}
+	if got[0].body["alias"] != "myapp/crash-loop" || got[0].body["priority"] != "P1" {
+		t.Errorf("unexpected alert %v", got[0].body)
+	}
+	if details := got[0].body["details"].(map[string]interface{}); details["crashes"] != "5" {
+		t.Errorf("expected details as strings, got %v", details)
+	}
+	if got[1].path != "/v2/alerts/myapp/crash-loop/close" || got[1].query != "identifierType=alias" {
+		t.Errorf("unexpected close request %+v", got[1])
+	}
+}
+
+func TestPost_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"invalid routing key"}`, http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	if err := (&PagerDuty{URL: srv.URL}).Resolve(context.Background(), "k"); err == nil {
+		t.Error("expected an error for a 400 response")
+	}
+}
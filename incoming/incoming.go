@@ -0,0 +1,281 @@
+// Package incoming picks up build artifacts dropped into a directory, e.g.
+// by scp from CI, and validates and unpacks them for deployment
+package incoming
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ChecksumSuffix names the sidecar holding an artifact's SHA-256, in
+// sha256sum's format or as the bare hex digest
+const ChecksumSuffix = ".sha256"
+
+// ErrNoChecksum is returned by VerifyChecksum for an artifact without a
+// sidecar when one is required
+var ErrNoChecksum = errors.New("no " + ChecksumSuffix + " file")
+
+// Watcher finds files dropped into Dir that have finished arriving
+type Watcher struct {
+	Dir string
+	// Settle is how long a file's size and modification time must stay the
+	// same before it is reported
+	Settle time.Duration
+
+	seen map[string]fileState
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+// Poll returns the names of the files in Dir that haven't changed for
+// Settle. Hidden files, subdirectories, checksum sidecars and names ending
+// in .tmp or .part (uploads in progress) are skipped. Callers move reported
+// files out of Dir; ones left behind are reported again.
+func (w *Watcher) Poll() ([]string, error) {
+	entries, err := os.ReadDir(w.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if w.seen == nil {
+		w.seen = map[string]fileState{}
+	}
+
+	now := time.Now()
+	present := map[string]bool{}
+	var ready []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || skipName(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Moved away meanwhile
+		}
+		present[name] = true
+
+		prev, ok := w.seen[name]
+		if !ok || prev.size != info.Size() || !prev.modTime.Equal(info.ModTime()) {
+			w.seen[name] = fileState{size: info.Size(), modTime: info.ModTime(), since: now}
+			continue
+		}
+		if now.Sub(prev.since) >= w.Settle {
+			ready = append(ready, name)
+		}
+	}
+	for name := range w.seen {
+		if !present[name] {
+			delete(w.seen, name)
+		}
+	}
+	return ready, nil
+}
+
+func skipName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".tmp") ||
+		strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ChecksumSuffix)
+}
+
+// VerifyChecksum checks file against its sidecar, file + ChecksumSuffix.
+// Without a sidecar it returns ErrNoChecksum if required, else nil.
+func VerifyChecksum(file string, required bool) error {
+	sidecar, err := os.ReadFile(file + ChecksumSuffix)
+	if os.IsNotExist(err) {
+		if required {
+			return ErrNoChecksum
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty %s file", ChecksumSuffix)
+	}
+	want := strings.ToLower(fields[0])
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch: file has %s, %s says %s", got, ChecksumSuffix, want)
+	}
+	return nil
+}
+
+// Unpack puts the artifact file into dest, returning the number of files
+// written. Tarballs, gzipped or not, are extracted; entries escaping dest,
+// device files and links pointing outside dest are rejected before
+// anything is written. Any other file is taken to be a binary and
+// installed executable under its own name.
+func Unpack(file, dest string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	stream, isArchive, err := archiveStream(f)
+	if err != nil {
+		return 0, err
+	}
+	if !isArchive {
+		return 1, installBinary(stream, filepath.Join(dest, filepath.Base(file)))
+	}
+
+	// Validate the whole archive before touching dest
+	if err := validateTar(tar.NewReader(stream)); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if stream, _, err = archiveStream(f); err != nil {
+		return 0, err
+	}
+	return extractTar(tar.NewReader(stream), dest)
+}
+
+// archiveStream returns the contents of r, decompressed if gzipped, and
+// whether they are a tar archive
+func archiveStream(r io.Reader) (io.Reader, bool, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		return gz, true, err
+	}
+	return br, isTar(br), nil
+}
+
+// isTar reports whether r starts with a tar header
+func isTar(r *bufio.Reader) bool {
+	header, err := r.Peek(262)
+	return err == nil && bytes.Equal(header[257:262], []byte("ustar"))
+}
+
+func validateTar(tr *tar.Reader) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		name, err := entryPath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeDir:
+		case tar.TypeSymlink:
+			target := hdr.Linkname
+			if !path.IsAbs(target) {
+				target = path.Join(path.Dir(name), target)
+			}
+			if path.IsAbs(target) || target == ".." || strings.HasPrefix(target, "../") {
+				return fmt.Errorf("archive entry %q links outside the artifact: %s", hdr.Name, hdr.Linkname)
+			}
+		default:
+			return fmt.Errorf("archive entry %q has unsupported type %q", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
+
+// entryPath cleans an archive entry name, rejecting ones escaping the root
+func entryPath(name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("archive entry %q escapes the artifact", name)
+	}
+	return clean, nil
+}
+
+func extractTar(tr *tar.Reader, dest string) (int, error) {
+	files := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, fmt.Errorf("reading archive: %w", err)
+		}
+		name, _ := entryPath(hdr.Name)
+		target := filepath.Join(dest, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return files, err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return files, err
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return files, err
+			}
+			files++
+		case tar.TypeReg:
+			if err := writeFile(tr, target, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return files, err
+			}
+			files++
+		}
+	}
+}
+
+// installBinary writes r to dest as an executable
+func installBinary(r io.Reader, dest string) error {
+	return writeFile(r, dest, 0755)
+}
+
+// writeFile atomically replaces dest with r's contents, so a running
+// binary being replaced keeps working
+func writeFile(r io.Reader, dest string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".unpack-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
@@ -0,0 +1,164 @@
+package incoming
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+type entry struct {
+	name, body, link string
+	typ              byte
+}
+
+func writeTarball(t *testing.T, path string, entries []entry) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Mode: 0755, Size: int64(len(e.body)), Linkname: e.link}
+		if e.typ != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.body))
+	}
+	tw.Close()
+	gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnpack_Tarball(t *testing.T) {
+	dir := t.TempDir()
+	artifact := filepath.Join(dir, "app-1.2.0.tar.gz")
+	writeTarball(t, artifact, []entry{
+		{name: "./bin/", typ: tar.TypeDir},
+		{name: "./bin/app", body: "binary", typ: tar.TypeReg},
+		{name: "static/index.html", body: "<html>", typ: tar.TypeReg},
+		{name: "current", link: "bin/app", typ: tar.TypeSymlink},
+	})
+
+	dest := filepath.Join(dir, "out")
+	files, err := Unpack(artifact, dest)
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if files != 3 {
+		t.Errorf("expected 3 files, got %d", files)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "current")); string(data) != "binary" {
+		t.Errorf("expected the symlink to resolve to the binary, got %q", data)
+	}
+	if info, err := os.Stat(filepath.Join(dest, "bin", "app")); err != nil || info.Mode().Perm()&0100 == 0 {
+		t.Errorf("expected an executable binary, got %v, %v", info, err)
+	}
+}
+
+func TestUnpack_RejectsEscapes(t *testing.T) {
+	for name, e := range map[string]entry{
+		"traversal": {name: "../../etc/cron.d/x", body: "x", typ: tar.TypeReg},
+		"absolute":  {name: "/etc/passwd", body: "x", typ: tar.TypeReg},
+		"symlink":   {name: "escape", link: "../../outside", typ: tar.TypeSymlink},
+		"device":    {name: "null", typ: tar.TypeChar},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			artifact := filepath.Join(dir, "bad.tar.gz")
+			// The good entry comes first: nothing may be written at all
+			writeTarball(t, artifact, []entry{{name: "good", body: "ok", typ: tar.TypeReg}, e})
+
+			dest := filepath.Join(dir, "out")
+			if _, err := Unpack(artifact, dest); err == nil {
+				t.Fatal("expected the archive to be rejected")
+			}
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Error("expected nothing to be written for a rejected archive")
+			}
+		})
+	}
+}
+
+func TestUnpack_Binary(t *testing.T) {
+	dir := t.TempDir()
+	artifact := filepath.Join(dir, "myapp")
+	os.WriteFile(artifact, []byte("\x7fELF..."), 0644)
+
+	dest := filepath.Join(dir, "out")
+	if files, err := Unpack(artifact, dest); err != nil || files != 1 {
+		t.Fatalf("expected one file, got %d, %v", files, err)
+	}
+	info, err := os.Stat(filepath.Join(dest, "myapp"))
+	if err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected an executable myapp, got %v, %v", info, err)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dir := t.TempDir()
+	artifact := filepath.Join(dir, "app.tar.gz")
+	os.WriteFile(artifact, []byte("contents"), 0644)
+	sum := sha256.Sum256([]byte("contents"))
+
+	if err := VerifyChecksum(artifact, false); err != nil {
+		t.Errorf("expected no sidecar to pass when not required, got %v", err)
+	}
+	if err := VerifyChecksum(artifact, true); !errors.Is(err, ErrNoChecksum) {
+		t.Errorf("expected ErrNoChecksum, got %v", err)
+	}
+
+	os.WriteFile(artifact+ChecksumSuffix, []byte(hex.EncodeToString(sum[:])+"  app.tar.gz\n"), 0644)
+	if err := VerifyChecksum(artifact, true); err != nil {
+		t.Errorf("expected a matching checksum to pass, got %v", err)
+	}
+
+	os.WriteFile(artifact+ChecksumSuffix, []byte(hex.EncodeToString(make([]byte, 32))), 0644)
+	if err := VerifyChecksum(artifact, false); err == nil {
+		t.Error("expected a mismatching checksum to fail")
+	}
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	dir := t.TempDir()
+	w := &Watcher{Dir: dir, Settle: 50 * time.Millisecond}
+
+	os.WriteFile(filepath.Join(dir, "app.tar.gz"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "app.tar.gz.sha256"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(dir, "upload.part"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("a"), 0644)
+	os.Mkdir(filepath.Join(dir, "deployed"), 0755)
+
+	if ready, _ := w.Poll(); len(ready) != 0 {
+		t.Errorf("expected nothing on first sight, got %v", ready)
+	}
+	time.Sleep(60 * time.Millisecond)
+	os.WriteFile(filepath.Join(dir, "growing"), []byte("a"), 0644)
+	ready, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ready) != 1 || ready[0] != "app.tar.gz" {
+		t.Errorf("expected only the settled artifact, got %v", ready)
+	}
+
+	// A file still being written starts settling over
+	time.Sleep(60 * time.Millisecond)
+	os.WriteFile(filepath.Join(dir, "growing"), []byte("ab"), 0644)
+	ready, _ = w.Poll()
+	sort.Strings(ready)
+	if len(ready) != 1 || ready[0] != "app.tar.gz" {
+		t.Errorf("expected the growing file to wait, got %v", ready)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/incoming"
+)
+
+// Subdirectories of incoming_dir artifacts move through
+const (
+	incomingDeploying = ".deploying"
+	incomingDeployed  = "deployed"
+	incomingFailed    = "failed"
+)
+
+const (
+	// incomingPollInterval is how often incoming_dir is checked
+	incomingPollInterval = time.Second
+	// incomingSettle is how long a dropped file must stay unchanged before
+	// it is deployed, in case the uploader writes it in place
+	incomingSettle = 2 * time.Second
+	// incomingKeep is how many deployed artifacts are kept for reference
+	incomingKeep = 5
+)
+
+// startIncomingWatcher deploys artifacts dropped into incoming_dir, for
+// CI pipelines that push builds with scp instead of calling binaryDeploy.
+// It returns immediately when incoming_dir isn't set.
+func startIncomingWatcher() {
+	if appConfig().IncomingDir == "" {
+		return
+	}
+	for _, sub := range []string{incomingDeploying, incomingDeployed, incomingFailed} {
+		if err := os.MkdirAll(filepath.Join(appConfig().IncomingDir, sub), 0755); err != nil {
+			slog.Error("Failed to create incoming directory, dropped artifacts won't deploy", "path", appConfig().IncomingDir, "error", err)
+			return
+		}
+	}
+
+	watcher := &incoming.Watcher{Dir: appConfig().IncomingDir, Settle: incomingSettle}
+	go func() {
+		frozen := map[string]bool{}
+		ticker := time.NewTicker(incomingPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			names, err := watcher.Poll()
+			if err != nil {
+				slog.Error("Failed to list incoming directory", "path", appConfig().IncomingDir, "error", err)
+				continue
+			}
+			// Deployments run one at a time anyway; one artifact at a time
+			// keeps their order and outcome obvious
+			for _, name := range names {
+				deployDroppedArtifact(name, frozen)
+			}
+		}
+	}()
+	slog.Info("Watching for dropped artifacts", "path", appConfig().IncomingDir, "require_checksum", appConfig().IncomingRequireChecksum)
+}
+
+// deployDroppedArtifact validates and deploys incoming_dir/name, then moves
+// it to deployed/ or failed/. frozen remembers artifacts already reported
+// as held by a freeze window.
+func deployDroppedArtifact(name string, frozen map[string]bool) {
+	dropped := filepath.Join(appConfig().IncomingDir, name)
+
+	if window, until, ok := activeFreeze(time.Now()); ok {
+		if appConfig().FreezeMode != "queue" {
+			failDroppedArtifact(dropped, fmt.Errorf("deployments are frozen (%s) until %s", window.Spec, until.Format(time.RFC3339)))
+			return
+		}
+		// Left in place, it deploys once the freeze lifts
+		if !frozen[name] {
+			frozen[name] = true
+			slog.Info("Dropped artifact held by freeze window", "artifact", name, "window", window.Spec, "until", until)
+		}
+		return
+	}
+	delete(frozen, name)
+
+	triggeredBy := fileOwner(dropped)
+	path := filepath.Join(appConfig().IncomingDir, incomingDeploying, name)
+	if err := moveArtifact(dropped, path); err != nil {
+		slog.Error("Failed to claim dropped artifact", "artifact", name, "error", err)
+		return
+	}
+
+	if err := incoming.VerifyChecksum(path, appConfig().IncomingRequireChecksum); err != nil {
+		failDroppedArtifact(path, fmt.Errorf("invalid checksum: %w", err))
+		return
+	}
+
+	slog.Info("Deploying dropped artifact", "artifact", name, "triggered_by", triggeredBy)
+	req := DeployRequest{
+		RepoURL:     appConfig().TargetRepoURL,
+		Artifact:    path,
+		Trigger:     "Artifact drop",
+		TriggeredBy: triggeredBy,
+		Tags:        []string{history.TagArtifactDrop},
+	}
+	if err := runDeployment(req); err != nil {
+		failDroppedArtifact(path, err)
+		return
+	}
+
+	if err := moveArtifact(path, filepath.Join(appConfig().IncomingDir, incomingDeployed, name)); err != nil {
+		slog.Error("Failed to move deployed artifact", "artifact", name, "error", err)
+	}
+	pruneDeployedArtifacts(filepath.Join(appConfig().IncomingDir, incomingDeployed), incomingKeep)
+}
+
+// unpackDroppedArtifact puts a dropped artifact into the working directory
+func unpackDroppedArtifact(path string) error {
+	workingDir := targetWorkingDir()
+	files, err := incoming.Unpack(path, workingDir)
+	if err != nil {
+		return fmt.Errorf("invalid artifact %s: %w", filepath.Base(path), err)
+	}
+	slog.Info("Unpacked dropped artifact", "artifact", filepath.Base(path), "files", files, "path", workingDir)
+	return nil
+}
+
+// failDroppedArtifact moves path to failed/ with the reason next to it
+func failDroppedArtifact(path string, reason error) {
+	name := filepath.Base(path)
+	slog.Error("Dropped artifact failed", "artifact", name, "error", reason)
+
+	failed := filepath.Join(appConfig().IncomingDir, incomingFailed, name)
+	if err := moveArtifact(path, failed); err != nil {
+		slog.Error("Failed to move failed artifact", "artifact", name, "error", err)
+		return
+	}
+	if err := os.WriteFile(failed+".error", []byte(reason.Error()+"\n"), 0644); err != nil {
+		slog.Warn("Failed to record why artifact failed", "artifact", name, "error", err)
+	}
+}
+
+// moveArtifact renames src and its checksum sidecar, if any, to dest
+func moveArtifact(src, dest string) error {
+	if err := os.Rename(src, dest); err != nil {
+		return err
+	}
+	err := os.Rename(src+incoming.ChecksumSuffix, dest+incoming.ChecksumSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// pruneDeployedArtifacts deletes all but the newest keep artifacts in dir
+func pruneDeployedArtifacts(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type artifact struct {
+		name    string
+		modTime time.Time
+	}
+	var artifacts []artifact
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && entry.Type().IsRegular() &&
+			filepath.Ext(entry.Name()) != incoming.ChecksumSuffix {
+			artifacts = append(artifacts, artifact{entry.Name(), info.ModTime()})
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].modTime.After(artifacts[j].modTime) })
+	for i := keep; i < len(artifacts); i++ {
+		path := filepath.Join(dir, artifacts[i].name)
+		os.Remove(path)
+		os.Remove(path + incoming.ChecksumSuffix)
+	}
+}
+
+// fileOwner names the user owning path, usually the account CI uploads with
+func fileOwner(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "incoming_dir"
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "incoming_dir"
+	}
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+	return "uid " + uid
+}
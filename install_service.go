@@ -0,0 +1,228 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"binaryDeploy/secrets"
+)
+
+// serviceOptions describe the systemd unit install-service writes
+type serviceOptions struct {
+	Name       string
+	User       bool   // A user unit rather than a system one
+	RunAs      string // User= of a system unit; empty runs as root
+	Dir        string // WorkingDirectory, where deploy.config is
+	Executable string
+	Tenants    string // tenants.config to run instead of deploy.config
+	KeyFile    string // BINARYDEPLOY_SECRET_KEY_FILE passed on to the service
+}
+
+// runInstallServiceCommand implements install-service: it writes a systemd
+// unit running binaryDeploy from the current directory, enables lingering
+// for user units so they keep running after logout, and enables and starts
+// the service
+func runInstallServiceCommand(args []string) int {
+	flags := flag.NewFlagSet("install-service", flag.ContinueOnError)
+	userUnit := flags.Bool("user", false, "install a systemd user unit instead of a system unit")
+	name := flags.String("name", "binarydeploy", "name of the service")
+	dir := flags.String("dir", ".", "directory with deploy.config the service runs in")
+	runAs := flags.String("run-as", os.Getenv("SUDO_USER"), "user a system unit runs as; empty runs it as root")
+	tenants := flags.String("tenants", "", "run the tenants in this tenants.config instead of deploy.config")
+	printOnly := flags.Bool("print", false, "print the unit instead of installing it")
+	noStart := flags.Bool("no-start", false, "enable the service without starting it")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := serviceOptions{Name: *name, User: *userUnit, Tenants: *tenants}
+	if !opts.User {
+		opts.RunAs = *runAs
+	}
+	var err error
+	if opts.Dir, err = filepath.Abs(*dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if opts.Executable, err = os.Executable(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating the binaryDeploy executable: %v\n", err)
+		return 1
+	}
+	if opts.Executable, err = filepath.EvalSymlinks(opts.Executable); err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating the binaryDeploy executable: %v\n", err)
+		return 1
+	}
+
+	configFile := filepath.Join(opts.Dir, deployConfigFile)
+	if opts.Tenants != "" {
+		configFile = filepath.Join(opts.Dir, opts.Tenants)
+	}
+	if _, err := os.Stat(configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// The key itself is never written into the unit, which is world-readable
+	if keyFile := os.Getenv(secrets.KeyFileEnv); keyFile != "" {
+		opts.KeyFile, _ = filepath.Abs(keyFile)
+	} else if os.Getenv(secrets.KeyEnv) != "" {
+		fmt.Fprintf(os.Stderr, "Warning: %s is not copied into the unit; put the key in a file and set %s instead\n", secrets.KeyEnv, secrets.KeyFileEnv)
+	}
+
+	unit := serviceUnit(opts)
+	if *printOnly {
+		fmt.Print(unit)
+		return 0
+	}
+	if !opts.User && os.Geteuid() != 0 {
+		fmt.Fprintln(os.Stderr, "Error: installing a system unit needs root; run with sudo or pass -user")
+		return 1
+	}
+
+	path, err := serviceUnitPath(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+		return 1
+	}
+	fmt.Printf("Wrote %s\n", path)
+
+	if opts.User {
+		if err := enableLingering(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v; the service stops when you log out\n", err)
+		}
+	}
+
+	systemctl := func(args ...string) error {
+		if opts.User {
+			args = append([]string{"--user"}, args...)
+		}
+		cmd := exec.Command("systemctl", args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("systemctl %s: %w", strings.Join(args, " "), err)
+		}
+		return nil
+	}
+	enable := []string{"enable", opts.Name}
+	if !*noStart {
+		enable = []string{"enable", "--now", opts.Name}
+	}
+	for _, args := range [][]string{{"daemon-reload"}, enable} {
+		if err := systemctl(args...); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	journal := "journalctl -u " + opts.Name + " -f"
+	if opts.User {
+		journal = "journalctl --user -u " + opts.Name + " -f"
+	}
+	fmt.Printf("Installed %s; follow its output with: %s\n", opts.Name, journal)
+	return 0
+}
+
+// serviceUnit renders the systemd unit for opts. Restart=always brings
+// binaryDeploy back after crashes and picks up a self-updated binary when it
+// is restarted; KillMode=mixed lets it stop the application gracefully
+// before systemd kills what is left.
+func serviceUnit(opts serviceOptions) string {
+	execStart := systemdQuote(opts.Executable)
+	if opts.Tenants != "" {
+		execStart += " tenants " + systemdQuote(opts.Tenants)
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=binaryDeploy webhook deployment server (%s)\n", systemdEscape(opts.Dir))
+	b.WriteString("Wants=network-online.target\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("StartLimitIntervalSec=0\n")
+	b.WriteString("\n[Service]\n")
+	b.WriteString("Type=exec\n")
+	if opts.RunAs != "" {
+		fmt.Fprintf(&b, "User=%s\n", opts.RunAs)
+	}
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdEscape(opts.Dir))
+	fmt.Fprintf(&b, "ExecStart=%s\n", execStart)
+	if opts.Tenants == "" {
+		// The tenant supervisor doesn't reload, SIGHUP would stop it
+		b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	}
+	if opts.KeyFile != "" {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(secrets.KeyFileEnv+"="+opts.KeyFile))
+	}
+	b.WriteString("Restart=always\n")
+	b.WriteString("RestartSec=5\n")
+	b.WriteString("KillMode=mixed\n")
+	b.WriteString("TimeoutStopSec=60\n")
+	b.WriteString("\n[Install]\n")
+	if opts.User {
+		b.WriteString("WantedBy=default.target\n")
+	} else {
+		b.WriteString("WantedBy=multi-user.target\n")
+	}
+	return b.String()
+}
+
+// serviceUnitPath is where systemd looks for the unit
+func serviceUnitPath(opts serviceOptions) (string, error) {
+	if !opts.User {
+		return filepath.Join("/etc/systemd/system", opts.Name+".service"), nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "systemd", "user", opts.Name+".service"), nil
+}
+
+// enableLingering lets the current user's services run without a login
+// session, so a user unit starts at boot and survives logging out
+func enableLingering() error {
+	current, err := user.Current()
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command("loginctl", "show-user", current.Username, "--property=Linger").Output()
+	if err == nil && strings.TrimSpace(string(out)) == "Linger=yes" {
+		return nil
+	}
+	if out, err := exec.Command("loginctl", "enable-linger", current.Username).CombinedOutput(); err != nil {
+		return fmt.Errorf("enabling lingering for %s: %v: %s", current.Username, err, strings.TrimSpace(string(out)))
+	}
+	fmt.Printf("Enabled lingering for %s\n", current.Username)
+	return nil
+}
+
+// systemdEscape escapes the % specifier character in a unit setting
+func systemdEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// systemdQuote quotes s as one word of a unit's command line or
+// Environment= setting
+func systemdQuote(s string) string {
+	s = systemdEscape(s)
+	if !strings.ContainsAny(s, " \t\"'\\$") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "$", "$$")
+	return `"` + s + `"`
+}
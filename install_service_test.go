@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"binaryDeploy/secrets"
+)
+
+func TestServiceUnit_SystemUnit(t *testing.T) {
+	unit := serviceUnit(serviceOptions{
+		Name:       "binarydeploy",
+		RunAs:      "deploy",
+		Dir:        "/srv/deploy 100%",
+		Executable: "/usr/local/bin/binary deploy",
+		KeyFile:    "/etc/binarydeploy/key",
+	})
+	for _, line := range []string{
+		"User=deploy\n",
+		"WorkingDirectory=/srv/deploy 100%%\n",
+		`ExecStart="/usr/local/bin/binary deploy"` + "\n",
+		"ExecReload=/bin/kill -HUP $MAINPID\n",
+		"Environment=" + secrets.KeyFileEnv + "=/etc/binarydeploy/key\n",
+		"Restart=always\n",
+		"KillMode=mixed\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, line) {
+			t.Errorf("Expected the unit to contain %q:\n%s", line, unit)
+		}
+	}
+}
+
+func TestServiceUnit_UserUnitRunningTenants(t *testing.T) {
+	unit := serviceUnit(serviceOptions{
+		Name:       "tenants",
+		User:       true,
+		Dir:        "/home/alex/deploy",
+		Executable: "/home/alex/bin/binaryDeploy",
+		Tenants:    "tenants.config",
+	})
+	if !strings.Contains(unit, "ExecStart=/home/alex/bin/binaryDeploy tenants tenants.config\n") {
+		t.Errorf("Expected the unit to run the tenant supervisor:\n%s", unit)
+	}
+	if strings.Contains(unit, "ExecReload=") || strings.Contains(unit, "User=") {
+		t.Errorf("Expected no reload or User= for a user unit running tenants:\n%s", unit)
+	}
+	if !strings.Contains(unit, "WantedBy=default.target\n") {
+		t.Errorf("Expected a user unit to be wanted by default.target:\n%s", unit)
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := map[string]string{
+		"/usr/bin/binaryDeploy": "/usr/bin/binaryDeploy",
+		"/opt/my app/bin":       `"/opt/my app/bin"`,
+		`KEY=a"b$c\d`:           `"KEY=a\"b$$c\\d"`,
+		"50%":                   "50%%",
+	}
+	for in, want := range tests {
+		if got := systemdQuote(in); got != want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestServiceUnitPath(t *testing.T) {
+	if path, err := serviceUnitPath(serviceOptions{Name: "binarydeploy"}); err != nil || path != "/etc/systemd/system/binarydeploy.service" {
+		t.Errorf("Unexpected system unit path %q, %v", path, err)
+	}
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	if path, err := serviceUnitPath(serviceOptions{Name: "binarydeploy", User: true}); err != nil || path != filepath.Join(config, "systemd/user/binarydeploy.service") {
+		t.Errorf("Unexpected user unit path %q, %v", path, err)
+	}
+}
+
+func TestRunInstallServiceCommand_Print(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(secrets.KeyFileEnv, "")
+	if code := runInstallServiceCommand([]string{"-print", "-dir", dir}); code != 1 {
+		t.Errorf("Expected a directory without deploy.config to be refused, got %d", code)
+	}
+	if err := os.WriteFile(filepath.Join(dir, deployConfigFile), []byte("target_repo_url=x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = out
+	code := runInstallServiceCommand([]string{"-print", "-dir", dir, "-run-as", "deploy"})
+	os.Stdout = stdout
+	out.Close()
+
+	unit, _ := os.ReadFile(out.Name())
+	if code != 0 || !strings.Contains(string(unit), "WorkingDirectory="+dir+"\n") || !strings.Contains(string(unit), "User=deploy\n") {
+		t.Errorf("Expected the unit to be printed, got %d %q", code, unit)
+	}
+}
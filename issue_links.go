@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/issues"
+)
+
+// maxIssueScanCommits bounds how many deployed commits are searched for
+// issue keys
+const maxIssueScanCommits = 200
+
+// pushIssueKeys returns the issue keys mentioned by the commits in a push
+func pushIssueKeys(payload GitHubPushPayload) []string {
+	messages := []string{payload.HeadCommit.Message}
+	for _, c := range payload.Commits {
+		messages = append(messages, c.Message)
+	}
+	return issues.ExtractKeys(appConfig().IssueProjects, messages...)
+}
+
+// deployedIssueKeys returns the issue keys mentioned by the commits deployed
+// since the last successful deployment, or by commit alone if there was none
+func deployedIssueKeys(repoDir, commit string) []string {
+	if commit == "" {
+		return nil
+	}
+
+	revs := []string{"-n", "1", commit}
+	if previous := lastDeployedCommit(); previous != "" && previous != commit {
+		revs = []string{"-n", fmt.Sprint(maxIssueScanCommits), previous + ".." + commit}
+	}
+	out, err := exec.Command("git", append([]string{"-C", repoDir, "log", "--format=%B"}, revs...)...).Output()
+	if err != nil {
+		slog.Warn("Failed to read commit messages for issue keys", "error", err)
+		return nil
+	}
+	return issues.ExtractKeys(appConfig().IssueProjects, string(out))
+}
+
+// lastDeployedCommit returns the commit of the last successful deployment
+func lastDeployedCommit() string {
+	if deployHistory == nil {
+		return ""
+	}
+	recent, err := deployHistory.Query(1, func(r history.Record) bool {
+		return r.App == appConfig().AppName && r.Kind == history.KindDeploy && r.Result == history.ResultSuccess && r.Commit != ""
+	})
+	if err != nil || len(recent) == 0 {
+		return ""
+	}
+	return recent[0].Commit
+}
+
+// issueLinks links issue keys to the tracker, for notifications and the
+// dashboard
+func issueLinks(keys []string) []issues.Link {
+	if len(keys) == 0 {
+		return nil
+	}
+	return issues.Links(appConfig().IssueTrackerURL, keys)
+}
+
+// commentOnIssues tells every issue mentioned by a successful deployment that
+// it has reached one of issue_comment_environments
+func commentOnIssues(rec history.Record) {
+	if appConfig().JiraToken == "" || len(rec.Issues) == 0 || !issueCommentEnvironment() {
+		return
+	}
+
+	environment := appConfig().Environment
+	body := fmt.Sprintf("Deployed to %s (%s) in commit %s on %s.",
+		environment, appConfig().AppName, shortSHA(rec.Commit), rec.FinishedAt.UTC().Format(time.RFC1123))
+	if rec.TriggeredBy != "" {
+		body += " Triggered by " + rec.TriggeredBy + "."
+	}
+
+	jira := issues.NewJira(appConfig().IssueTrackerURL, appConfig().JiraUser, appConfig().JiraToken)
+	go func() {
+		for _, key := range rec.Issues {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			err := jira.Comment(ctx, key, body)
+			cancel()
+			if err != nil {
+				slog.Warn("Failed to comment on issue", "issue", key, "error", err)
+				continue
+			}
+			slog.Info("Commented on deployed issue", "issue", key, "environment", environment)
+		}
+	}()
+}
+
+// issueCommentEnvironment reports whether deployments to the configured
+// environment are commented on
+func issueCommentEnvironment() bool {
+	for _, env := range appConfig().IssueCommentEnvironments {
+		if strings.EqualFold(env, appConfig().Environment) {
+			return true
+		}
+	}
+	return false
+}
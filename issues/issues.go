@@ -0,0 +1,111 @@
+// Package issues finds issue tracker keys such as ABC-123 in commit messages
+// and comments on the issues in Jira
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// keyPattern matches Jira-style keys: an uppercase project key, a dash and
+// an issue number
+var keyPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9_]{1,9}-[1-9][0-9]{0,6}\b`)
+
+// ExtractKeys returns the issue keys mentioned in texts, in order of first
+// appearance and without duplicates. When projects is non-empty only keys of
+// those projects are returned, which avoids false positives like UTF-8.
+func ExtractKeys(projects []string, texts ...string) []string {
+	allowed := make(map[string]bool, len(projects))
+	for _, p := range projects {
+		allowed[strings.ToUpper(p)] = true
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, key := range keyPattern.FindAllString(text, -1) {
+			project := key[:strings.LastIndex(key, "-")]
+			if seen[key] || (len(allowed) > 0 && !allowed[project]) {
+				continue
+			}
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Link is an issue key with its URL in the tracker
+type Link struct {
+	Key string `json:"key"`
+	URL string `json:"url,omitempty"`
+}
+
+// Links builds links to keys in the Jira instance at baseURL. URLs are left
+// empty when baseURL is.
+func Links(baseURL string, keys []string) []Link {
+	links := make([]Link, len(keys))
+	for i, key := range keys {
+		links[i] = Link{Key: key}
+		if baseURL != "" {
+			links[i].URL = strings.TrimRight(baseURL, "/") + "/browse/" + url.PathEscape(key)
+		}
+	}
+	return links
+}
+
+// Jira posts comments through the Jira REST API
+type Jira struct {
+	BaseURL string
+	User    string // Account email for Jira Cloud API tokens; empty for a personal access token
+	Token   string
+	client  *http.Client
+}
+
+// NewJira creates a client for the Jira instance at baseURL
+func NewJira(baseURL, user, token string) *Jira {
+	return &Jira{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		User:    user,
+		Token:   token,
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Comment adds a comment to the issue with key
+func (j *Jira) Comment(ctx context.Context, key, body string) error {
+	data, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return err
+	}
+	endpoint := j.BaseURL + "/rest/api/2/issue/" + url.PathEscape(key) + "/comment"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if j.User != "" {
+		req.SetBasicAuth(j.User, j.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+j.Token)
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("commenting on %s: unexpected status %d: %s", key, resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
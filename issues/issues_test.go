@@ -0,0 +1,61 @@
+package issues
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestExtractKeys(t *testing.T) {
+	messages := []string{
+		"ABC-123: fix login redirect",
+		"Merge pull request #42 (OPS-7, ABC-123)\n\nAlso touches abc-9 and UTF-8 handling",
+		"WEB-0 is not a key, WEB-15 is",
+	}
+
+	got := ExtractKeys(nil, messages...)
+	want := []string{"ABC-123", "OPS-7", "UTF-8", "WEB-15"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractKeys = %v, want %v", got, want)
+	}
+
+	got = ExtractKeys([]string{"abc", "OPS"}, messages...)
+	want = []string{"ABC-123", "OPS-7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractKeys with projects = %v, want %v", got, want)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	links := Links("https://example.atlassian.net/", []string{"ABC-1"})
+	if links[0].URL != "https://example.atlassian.net/browse/ABC-1" {
+		t.Errorf("unexpected link %+v", links[0])
+	}
+	if links := Links("", []string{"ABC-1"}); links[0].URL != "" {
+		t.Errorf("expected no URL without a tracker, got %+v", links[0])
+	}
+}
+
+func TestJiraComment(t *testing.T) {
+	var path, user, pass, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, pass, _ = r.BasicAuth()
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		body = payload["body"]
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	jira := NewJira(srv.URL, "bot@example.com", "tok")
+	if err := jira.Comment(context.Background(), "ABC-123", "Deployed to production"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/rest/api/2/issue/ABC-123/comment" || user != "bot@example.com" || pass != "tok" || body != "Deployed to production" {
+		t.Errorf("unexpected request: path=%s user=%s body=%q", path, user, body)
+	}
+}
@@ -0,0 +1,284 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"binaryDeploy/kv"
+)
+
+// Job states
+const (
+	StatePending     = "pending"
+	StateRunning     = "running"
+	StateInterrupted = "interrupted"
+)
+
+// ErrNotFound is returned for unknown job IDs
+var ErrNotFound = errors.New("job not found")
+
+// Job is a queued unit of work. Payload is opaque to the store.
+type Job struct {
+	ID        string          `json:"id"`
+	State     string          `json:"state"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	StartedAt *time.Time      `json:"started_at,omitempty"`
+}
+
+// Buckets of the key/value store holding the queue
+const (
+	jobsBucket = "jobs"
+	metaBucket = "jobs-meta"
+)
+
+// Store is a FIFO job queue persisted to a key/value store, one entry per
+// job keyed by its zero-padded ID so key order is queue order. Finished jobs
+// are removed.
+type Store struct {
+	mu     sync.Mutex
+	db     kv.Store
+	jobs   []Job
+	nextID int64
+	ready  chan struct{}
+}
+
+// Open loads the queue from db. Jobs that were running when the previous
+// process stopped are marked interrupted and left for an operator to resume
+// or discard; pending jobs are kept and run as normal.
+func Open(db kv.Store) (*Store, error) {
+	s := &Store{db: db, nextID: 1, ready: make(chan struct{}, 1)}
+
+	if data, err := db.Get(metaBucket, "next_id"); err == nil {
+		s.nextID, _ = strconv.ParseInt(string(data), 10, 64)
+	} else if err != kv.ErrNotFound {
+		return nil, fmt.Errorf("reading job store: %w", err)
+	}
+
+	entries, err := db.List(jobsBucket)
+	if err != nil {
+		return nil, fmt.Errorf("reading job store: %w", err)
+	}
+	for _, entry := range entries {
+		var job Job
+		if err := json.Unmarshal(entry.Value, &job); err != nil {
+			return nil, fmt.Errorf("parsing job %s: %w", entry.Key, err)
+		}
+		s.jobs = append(s.jobs, job)
+	}
+
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	s.signal()
+	return s, nil
+}
+
+// recover marks running jobs interrupted and moves nextID past every known
+// job; callers hold s.mu or have exclusive access
+func (s *Store) recover() error {
+	for i := range s.jobs {
+		if id, err := strconv.ParseInt(s.jobs[i].ID, 10, 64); err == nil && id >= s.nextID {
+			s.nextID = id + 1
+		}
+		if s.jobs[i].State == StateRunning {
+			s.jobs[i].State = StateInterrupted
+			if err := s.put(s.jobs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Add appends a pending job carrying payload
+func (s *Store) Add(payload any) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("encoding job payload: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := Job{
+		ID:        fmt.Sprintf("%d", s.nextID),
+		State:     StatePending,
+		Payload:   data,
+		CreatedAt: time.Now(),
+	}
+	s.nextID++
+	if err := s.saveNextID(); err != nil {
+		s.nextID--
+		return Job{}, err
+	}
+	if err := s.put(job); err != nil {
+		return Job{}, err
+	}
+	s.jobs = append(s.jobs, job)
+
+	s.signal()
+	return job, nil
+}
+
+// Next claims the oldest pending job, marking it running. It returns false
+// when nothing is pending.
+func (s *Store) Next() (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.jobs {
+		if s.jobs[i].State != StatePending {
+			continue
+		}
+		now := time.Now()
+		s.jobs[i].State = StateRunning
+		s.jobs[i].StartedAt = &now
+		if err := s.put(s.jobs[i]); err != nil {
+			s.jobs[i].State = StatePending
+			s.jobs[i].StartedAt = nil
+			return Job{}, false, err
+		}
+		return s.jobs[i], true, nil
+	}
+	return Job{}, false, nil
+}
+
+// Ready returns a channel that receives after jobs become pending
+func (s *Store) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Finish removes a job once it has run, whatever the outcome
+func (s *Store) Finish(id string) error {
+	return s.remove(id, StateRunning)
+}
+
+// Discard removes a pending or interrupted job without running it
+func (s *Store) Discard(id string) error {
+	return s.remove(id, StatePending, StateInterrupted)
+}
+
+// Resume moves an interrupted job back into the queue
+func (s *Store) Resume(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if s.jobs[i].State != StateInterrupted {
+		return fmt.Errorf("job %s is %s, only interrupted jobs can be resumed", id, s.jobs[i].State)
+	}
+
+	s.jobs[i].State = StatePending
+	s.jobs[i].StartedAt = nil
+	if err := s.put(s.jobs[i]); err != nil {
+		s.jobs[i].State = StateInterrupted
+		return err
+	}
+
+	s.signal()
+	return nil
+}
+
+// List returns a snapshot of all jobs in queue order
+func (s *Store) List() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]Job, len(s.jobs))
+	copy(jobs, s.jobs)
+	return jobs
+}
+
+// Depth returns the number of pending jobs
+func (s *Store) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	depth := 0
+	for _, job := range s.jobs {
+		if job.State == StatePending {
+			depth++
+		}
+	}
+	return depth
+}
+
+func (s *Store) remove(id string, states ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	allowed := false
+	for _, state := range states {
+		if s.jobs[i].State == state {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("job %s is %s", id, s.jobs[i].State)
+	}
+
+	if err := s.db.Delete(jobsBucket, jobKey(id)); err != nil {
+		return fmt.Errorf("removing job: %w", err)
+	}
+	s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+	return nil
+}
+
+func (s *Store) index(id string) int {
+	for i, job := range s.jobs {
+		if job.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// signal wakes a waiting worker without blocking
+func (s *Store) signal() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// put writes job to the store; callers hold s.mu
+func (s *Store) put(job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("encoding job: %w", err)
+	}
+	if err := s.db.Put(jobsBucket, jobKey(job.ID), data); err != nil {
+		return fmt.Errorf("writing job store: %w", err)
+	}
+	return nil
+}
+
+// saveNextID persists the next job ID so IDs aren't reused once the queue
+// drains; callers hold s.mu
+func (s *Store) saveNextID() error {
+	if err := s.db.Put(metaBucket, "next_id", []byte(strconv.FormatInt(s.nextID, 10))); err != nil {
+		return fmt.Errorf("writing job store: %w", err)
+	}
+	return nil
+}
+
+// jobKey pads numeric IDs so keys sort in queue order
+func jobKey(id string) string {
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return fmt.Sprintf("%019d", n)
+	}
+	return id
+}
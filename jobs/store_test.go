@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"testing"
+
+	"binaryDeploy/kv"
+)
+
+func openDB(t *testing.T) kv.Store {
+	t.Helper()
+	db, err := kv.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	return db
+}
+
+func TestStore_FIFO(t *testing.T) {
+	s, err := Open(openDB(t))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	first, _ := s.Add(map[string]string{"commit": "aaa"})
+	second, _ := s.Add(map[string]string{"commit": "bbb"})
+	if s.Depth() != 2 {
+		t.Fatalf("expected depth 2, got %d", s.Depth())
+	}
+
+	job, ok, err := s.Next()
+	if err != nil || !ok {
+		t.Fatalf("Next failed: ok=%v err=%v", ok, err)
+	}
+	if job.ID != first.ID || job.State != StateRunning {
+		t.Errorf("expected first job running, got %+v", job)
+	}
+	if err := s.Finish(job.ID); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	job, ok, _ = s.Next()
+	if !ok || job.ID != second.ID {
+		t.Errorf("expected second job, got %+v", job)
+	}
+	s.Finish(job.ID)
+
+	if _, ok, _ := s.Next(); ok {
+		t.Error("expected empty queue")
+	}
+}
+
+func TestStore_RecoversAfterCrash(t *testing.T) {
+	db := openDB(t)
+	s, err := Open(db)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	running, _ := s.Add("running")
+	pending, _ := s.Add("pending")
+	if _, _, err := s.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+
+	// Simulate a restart without finishing the running job
+	s, err = Open(db)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+
+	jobs := s.List()
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	if jobs[0].ID != running.ID || jobs[0].State != StateInterrupted {
+		t.Errorf("expected running job to be interrupted, got %+v", jobs[0])
+	}
+	if jobs[1].ID != pending.ID || jobs[1].State != StatePending {
+		t.Errorf("expected pending job to be kept, got %+v", jobs[1])
+	}
+
+	// New IDs continue after the recovered ones
+	next, _ := s.Add("new")
+	if next.ID == running.ID || next.ID == pending.ID {
+		t.Errorf("expected a fresh ID, got %s", next.ID)
+	}
+}
+
+func TestStore_ResumeAndDiscard(t *testing.T) {
+	db := openDB(t)
+	s, _ := Open(db)
+	a, _ := s.Add("a")
+	b, _ := s.Add("b")
+	s.Next()
+	s.Next()
+
+	s, _ = Open(db)
+
+	if err := s.Resume(a.ID); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if err := s.Resume(a.ID); err == nil {
+		t.Error("expected resuming a pending job to fail")
+	}
+	if err := s.Discard(b.ID); err != nil {
+		t.Fatalf("Discard failed: %v", err)
+	}
+	if err := s.Discard(b.ID); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	job, ok, _ := s.Next()
+	if !ok || job.ID != a.ID {
+		t.Errorf("expected resumed job to run, got %+v", job)
+	}
+}
+
+func TestStore_IDsNotReusedAfterDraining(t *testing.T) {
+	db := openDB(t)
+	s, _ := Open(db)
+	first, _ := s.Add("a")
+	s.Next()
+	s.Finish(first.ID)
+
+	s, _ = Open(db)
+	next, _ := s.Add("b")
+	if next.ID == first.ID {
+		t.Errorf("expected a fresh ID after the queue drained, got %s again", next.ID)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"binaryDeploy/jobs"
+)
+
+// jobsHandler serves the deployment queue:
+//
+//	GET  /jobs                list queued, running and interrupted jobs
+//	POST /jobs/{id}/resume    re-queue an interrupted job
+//	POST /jobs/{id}/discard   drop a pending or interrupted job
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if deployQueue == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "deployment queue is not available"})
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/"), "/")
+	if len(parts) == 1 && parts[0] == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jobs": deployQueue.List()})
+		return
+	}
+
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, action := parts[0], parts[1]
+	var err error
+	switch action {
+	case "resume":
+		err = deployQueue.Resume(id)
+	case "discard":
+		if err = deployQueue.Discard(id); err == nil {
+			completeDeploymentJob(id, errDeploymentDiscarded)
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if errors.Is(err, jobs.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	slog.Info("Queued deployment updated by operator", "job_id", id, "action", action, "remote_addr", r.RemoteAddr)
+	json.NewEncoder(w).Encode(map[string]string{"status": action + "d", "id": id})
+}
@@ -0,0 +1,163 @@
+// Package jsonpath implements the small subset of JSONPath needed to pull
+// scalar fields out of webhook payloads: $.a.b, $.a[0].b, $['a-b'].c
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is one segment of a parsed path: either an object key or an array index
+type step struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// Path is a compiled JSONPath expression
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// Compile parses a JSONPath expression
+func Compile(expr string) (*Path, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpath %q: must start with $", expr)
+	}
+
+	var steps []step
+	rest := expr[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("jsonpath %q: empty key", expr)
+			}
+			steps = append(steps, step{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: unterminated [", expr)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, step{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			idx, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: invalid index %q", expr, inner)
+			}
+			steps = append(steps, step{index: idx, isIdx: true})
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", expr, rest[0])
+		}
+	}
+
+	return &Path{expr: expr, steps: steps}, nil
+}
+
+// Lookup walks the decoded JSON document and returns the value at the path
+func (p *Path) Lookup(doc interface{}) (interface{}, bool) {
+	current := doc
+	for _, s := range p.steps {
+		if s.isIdx {
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			idx := s.index
+			if idx < 0 {
+				idx += len(arr) // Negative indexes count from the end
+			}
+			if idx < 0 || idx >= len(arr) {
+				return nil, false
+			}
+			current = arr[idx]
+			continue
+		}
+
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[s.key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// LookupString returns the value at the path formatted as a string. Only
+// scalar values (strings, numbers, booleans) are accepted.
+func (p *Path) LookupString(doc interface{}) (string, bool) {
+	value, ok := p.Lookup(doc)
+	if !ok {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// String returns the original expression
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Evaluate resolves expr against doc. expr is either a plain JSONPath
+// ("$.ref") or a template embedding paths in {{ }} placeholders
+// ("https://git.example.com/{{$.project.path}}.git").
+func Evaluate(expr string, doc interface{}) (string, error) {
+	if !strings.Contains(expr, "{{") {
+		p, err := Compile(expr)
+		if err != nil {
+			return "", err
+		}
+		value, ok := p.LookupString(doc)
+		if !ok {
+			return "", fmt.Errorf("no value at %s", expr)
+		}
+		return value, nil
+	}
+
+	var out strings.Builder
+	rest := expr
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			out.WriteString(rest)
+			return out.String(), nil
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			return "", fmt.Errorf("template %q: unterminated {{", expr)
+		}
+		out.WriteString(rest[:start])
+
+		value, err := Evaluate(strings.TrimSpace(rest[start+2:start+end]), doc)
+		if err != nil {
+			return "", fmt.Errorf("template %q: %w", expr, err)
+		}
+		out.WriteString(value)
+		rest = rest[start+end+2:]
+	}
+}
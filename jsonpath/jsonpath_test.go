@@ -0,0 +1,87 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const payload = `{
+	"repository": {"clone_url": "https://git.example.com/team/app.git", "full-name": "team/app"},
+	"ref": "refs/heads/main",
+	"commits": [{"id": "abc"}, {"id": "def"}],
+	"build": {"number": 42, "ok": true}
+}`
+
+func TestLookupString(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(payload), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"$.repository.clone_url":    "https://git.example.com/team/app.git",
+		"$.repository['full-name']": "team/app",
+		"$.ref":                     "refs/heads/main",
+		"$.commits[0].id":           "abc",
+		"$.commits[-1].id":          "def",
+		"$.build.number":            "42",
+		"$.build.ok":                "true",
+	}
+
+	for expr, expected := range tests {
+		p, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", expr, err)
+		}
+		got, ok := p.LookupString(doc)
+		if !ok || got != expected {
+			t.Errorf("%s = %q (found %v), expected %q", expr, got, ok, expected)
+		}
+	}
+
+	missing := []string{"$.nope", "$.commits[5].id", "$.repository", "$.ref.x"}
+	for _, expr := range missing {
+		p, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("Compile(%q) failed: %v", expr, err)
+		}
+		if got, ok := p.LookupString(doc); ok {
+			t.Errorf("%s: expected no value, got %q", expr, got)
+		}
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "repository.url", "$.", "$..a", "$[0", "$[x]", "$a"} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("Expected error compiling %q", expr)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(payload), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"$.ref": "refs/heads/main",
+		"https://git.example.com/{{ $.repository['full-name'] }}.git": "https://git.example.com/team/app.git",
+		"build-{{$.build.number}}-{{$.commits[0].id}}":                "build-42-abc",
+		"no placeholders but not a path {{":                           "",
+	}
+
+	for expr, expected := range tests {
+		got, err := Evaluate(expr, doc)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("Evaluate(%q): expected error, got %q", expr, got)
+			}
+			continue
+		}
+		if err != nil || got != expected {
+			t.Errorf("Evaluate(%q) = %q, %v; expected %q", expr, got, err, expected)
+		}
+	}
+}
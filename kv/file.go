@@ -0,0 +1,254 @@
+package kv
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// compactAfter is how many overwritten or deleted entries a bucket log may
+// collect before it is rewritten, on top of one per live key
+const compactAfter = 1000
+
+// FileStore keeps each bucket as an append-only JSON-lines log of puts and
+// deletes in a directory, replayed into memory when the bucket is first
+// used. Every write is synced before it returns, a torn last line left by a
+// crash is skipped on replay, and logs are compacted once they are mostly
+// superseded entries.
+type FileStore struct {
+	mu      sync.Mutex
+	dir     string
+	buckets map[string]*fileBucket
+}
+
+type fileBucket struct {
+	path    string
+	file    *os.File
+	values  map[string][]byte
+	garbage int
+}
+
+type logEntry struct {
+	Key     string `json:"key"`
+	Value   []byte `json:"value,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// OpenFile opens the file store in dir, creating the directory if needed
+func OpenFile(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating storage directory: %w", err)
+	}
+	return &FileStore{dir: dir, buckets: make(map[string]*fileBucket)}, nil
+}
+
+// Get returns the value stored under key
+func (s *FileStore) Get(bucket, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := b.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// Put stores value under key
+func (s *FileStore) Put(bucket, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	if err := b.write(logEntry{Key: key, Value: value}); err != nil {
+		return err
+	}
+	if _, ok := b.values[key]; ok {
+		b.garbage++
+	}
+	b.values[key] = append([]byte(nil), value...)
+	return b.maybeCompact()
+}
+
+// Delete removes key
+func (s *FileStore) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	if _, ok := b.values[key]; !ok {
+		return nil
+	}
+	if err := b.write(logEntry{Key: key, Deleted: true}); err != nil {
+		return err
+	}
+	delete(b.values, key)
+	b.garbage += 2 // The delete and the value it removes
+	return b.maybeCompact()
+}
+
+// List returns every entry of bucket in key order
+func (s *FileStore) List(bucket string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := s.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return b.entries(), nil
+}
+
+// Close closes the bucket logs
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for name, b := range s.buckets {
+		if err := b.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.buckets, name)
+	}
+	return firstErr
+}
+
+// bucket returns the named bucket, replaying its log on first use; callers
+// hold s.mu
+func (s *FileStore) bucket(name string) (*fileBucket, error) {
+	if b, ok := s.buckets[name]; ok {
+		return b, nil
+	}
+	if err := checkBucket(name); err != nil {
+		return nil, err
+	}
+
+	b := &fileBucket{path: filepath.Join(s.dir, name+".jsonl"), values: make(map[string][]byte)}
+	if err := b.replay(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(b.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening bucket %s: %w", name, err)
+	}
+	b.file = f
+	if err := b.maybeCompact(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.buckets[name] = b
+	return b, nil
+}
+
+func (b *fileBucket) replay() error {
+	f, err := os.Open(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening bucket log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			b.garbage++
+			continue // Skip torn or corrupt lines
+		}
+		if _, ok := b.values[entry.Key]; ok {
+			b.garbage++
+		}
+		if entry.Deleted {
+			delete(b.values, entry.Key)
+			b.garbage++
+		} else {
+			b.values[entry.Key] = entry.Value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading bucket log: %w", err)
+	}
+	return nil
+}
+
+func (b *fileBucket) write(entry logEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding entry: %w", err)
+	}
+	if _, err := b.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing bucket log: %w", err)
+	}
+	if err := b.file.Sync(); err != nil {
+		return fmt.Errorf("syncing bucket log: %w", err)
+	}
+	return nil
+}
+
+func (b *fileBucket) entries() []Entry {
+	entries := make([]Entry, 0, len(b.values))
+	for key, value := range b.values {
+		entries = append(entries, Entry{Key: key, Value: append([]byte(nil), value...)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	return entries
+}
+
+// maybeCompact rewrites the log with only the live entries once superseded
+// entries outnumber them, writing to a temporary file that is renamed into
+// place so a crash leaves either the old or the new log behind
+func (b *fileBucket) maybeCompact() error {
+	if b.garbage < compactAfter+len(b.values) {
+		return nil
+	}
+
+	tmp := b.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("compacting bucket log: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range b.entries() {
+		data, err := json.Marshal(logEntry{Key: entry.Key, Value: entry.Value})
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("encoding entry: %w", err)
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("compacting bucket log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("syncing bucket log: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		f.Close()
+		return fmt.Errorf("replacing bucket log: %w", err)
+	}
+
+	b.file.Close()
+	b.file = f
+	b.garbage = 0
+	return nil
+}
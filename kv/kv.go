@@ -0,0 +1,64 @@
+// Package kv is the embedded key/value store behind binaryDeploy's persistent
+// state (deployment history, the deployment queue, ...), so features share
+// one storage backend instead of each inventing its own file format
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Storage drivers
+const (
+	DriverFile   = "file"
+	DriverSQLite = "sqlite"
+)
+
+// Drivers lists the supported storage drivers
+var Drivers = []string{DriverFile, DriverSQLite}
+
+// ErrNotFound is returned by Get for keys that don't exist
+var ErrNotFound = errors.New("key not found")
+
+// Store holds values in named buckets. Implementations are safe for
+// concurrent use.
+type Store interface {
+	// Get returns the value stored under key, or ErrNotFound
+	Get(bucket, key string) ([]byte, error)
+	// Put stores value under key, replacing any previous value
+	Put(bucket, key string, value []byte) error
+	// Delete removes key; deleting a missing key is not an error
+	Delete(bucket, key string) error
+	// List returns every entry of bucket in ascending key order
+	List(bucket string) ([]Entry, error)
+	Close() error
+}
+
+// Entry is one key/value pair of a bucket
+type Entry struct {
+	Key   string
+	Value []byte
+}
+
+var bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Open opens the store of the given driver at path: a directory for the
+// file driver, a database file for sqlite
+func Open(driver, path string) (Store, error) {
+	switch driver {
+	case DriverFile:
+		return OpenFile(path)
+	case DriverSQLite:
+		return OpenSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
+func checkBucket(bucket string) error {
+	if !bucketName.MatchString(bucket) {
+		return fmt.Errorf("invalid bucket name %q", bucket)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package kv
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func openStores(t *testing.T) map[string]Store {
+	stores := map[string]Store{}
+	file, err := OpenFile(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	stores[DriverFile] = file
+
+	if _, err := exec.LookPath("sqlite3"); err == nil {
+		db, err := OpenSQLite(filepath.Join(t.TempDir(), "data.db"))
+		if err != nil {
+			t.Fatalf("OpenSQLite failed: %v", err)
+		}
+		stores[DriverSQLite] = db
+	}
+	return stores
+}
+
+func TestStore_GetPutDelete(t *testing.T) {
+	for driver, s := range openStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			if _, err := s.Get("jobs", "1"); err != ErrNotFound {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+
+			if err := s.Put("jobs", "1", []byte(`{"state":"pending"}`)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if err := s.Put("jobs", "1", []byte(`{"state":"running"}`)); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			got, err := s.Get("jobs", "1")
+			if err != nil || string(got) != `{"state":"running"}` {
+				t.Errorf("expected the latest value, got %q, %v", got, err)
+			}
+
+			// Buckets don't share keys
+			if _, err := s.Get("other", "1"); err != ErrNotFound {
+				t.Errorf("expected ErrNotFound in another bucket, got %v", err)
+			}
+
+			if err := s.Delete("jobs", "1"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := s.Delete("jobs", "1"); err != nil {
+				t.Errorf("expected deleting a missing key to succeed, got %v", err)
+			}
+			if _, err := s.Get("jobs", "1"); err != ErrNotFound {
+				t.Errorf("expected ErrNotFound after delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStore_ListInKeyOrder(t *testing.T) {
+	for driver, s := range openStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			for _, key := range []string{"b", "it's", "a", "c"} {
+				if err := s.Put("history", key, []byte("value "+key+"\n\x00")); err != nil {
+					t.Fatalf("Put failed: %v", err)
+				}
+			}
+
+			entries, err := s.List("history")
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			var keys []string
+			for _, e := range entries {
+				keys = append(keys, e.Key)
+				if string(e.Value) != "value "+e.Key+"\n\x00" {
+					t.Errorf("unexpected value for %s: %q", e.Key, e.Value)
+				}
+			}
+			if fmt.Sprint(keys) != "[a b c it's]" {
+				t.Errorf("expected keys in order, got %v", keys)
+			}
+
+			if entries, err := s.List("empty"); err != nil || len(entries) != 0 {
+				t.Errorf("expected an empty bucket, got %v, %v", entries, err)
+			}
+		})
+	}
+}
+
+func TestStore_RejectsInvalidBucket(t *testing.T) {
+	for driver, s := range openStores(t) {
+		t.Run(driver, func(t *testing.T) {
+			if err := s.Put("../escape", "k", nil); err == nil {
+				t.Error("expected an invalid bucket name to be rejected")
+			}
+		})
+	}
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := OpenFile(dir)
+	s.Put("jobs", "1", []byte("one"))
+	s.Put("jobs", "2", []byte("two"))
+	s.Delete("jobs", "1")
+	s.Close()
+
+	// A crash mid-write leaves a torn last line behind
+	f, _ := os.OpenFile(filepath.Join(dir, "jobs.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"key":"3","val`)
+	f.Close()
+
+	s, err := OpenFile(dir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	entries, err := s.List("jobs")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "2" || string(entries[0].Value) != "two" {
+		t.Errorf("expected only key 2 to survive, got %+v", entries)
+	}
+	if err := s.Put("jobs", "4", []byte("four")); err != nil {
+		t.Fatalf("Put after reopen failed: %v", err)
+	}
+}
+
+func TestFileStore_Compacts(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := OpenFile(dir)
+	for i := 0; i < 3*compactAfter; i++ {
+		if err := s.Put("queue", "job", []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	s.Put("queue", "other", []byte("kept"))
+
+	info, err := os.Stat(filepath.Join(dir, "queue.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 100*1024 {
+		t.Errorf("expected the log to be compacted, it is %d bytes", info.Size())
+	}
+
+	s.Close()
+	s, _ = OpenFile(dir)
+	got, err := s.Get("queue", "job")
+	if err != nil || string(got) != fmt.Sprint(3*compactAfter-1) {
+		t.Errorf("expected the last value after compaction, got %q, %v", got, err)
+	}
+	if got, _ := s.Get("queue", "other"); string(got) != "kept" {
+		t.Errorf("expected other keys to survive compaction, got %q", got)
+	}
+}
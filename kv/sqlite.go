@@ -0,0 +1,136 @@
+package kv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SQLiteStore keeps every bucket in one table of a SQLite database, driven
+// through the system sqlite3 binary so the module needs no cgo or third-party
+// driver. Keys and values cross the command line hex-encoded.
+type SQLiteStore struct {
+	mu     sync.Mutex
+	binary string
+	path   string
+}
+
+// OpenSQLite opens or creates the database at path
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	binary, err := exec.LookPath("sqlite3")
+	if err != nil {
+		return nil, fmt.Errorf("sqlite storage needs the sqlite3 binary: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating storage directory: %w", err)
+	}
+
+	s := &SQLiteStore{binary: binary, path: path}
+	if _, err := s.exec(`PRAGMA journal_mode=WAL;
+CREATE TABLE IF NOT EXISTS kv (
+	bucket TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value BLOB NOT NULL,
+	PRIMARY KEY (bucket, key)
+);`); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the value stored under key
+func (s *SQLiteStore) Get(bucket, key string) ([]byte, error) {
+	if err := checkBucket(bucket); err != nil {
+		return nil, err
+	}
+	out, err := s.exec(fmt.Sprintf("SELECT 'v' || hex(value) FROM kv WHERE bucket = %s AND key = %s;",
+		quote(bucket), quote(key)))
+	if err != nil {
+		return nil, err
+	}
+	line := strings.TrimSpace(string(out))
+	if line == "" {
+		return nil, ErrNotFound
+	}
+	return hex.DecodeString(strings.TrimPrefix(line, "v"))
+}
+
+// Put stores value under key
+func (s *SQLiteStore) Put(bucket, key string, value []byte) error {
+	if err := checkBucket(bucket); err != nil {
+		return err
+	}
+	_, err := s.exec(fmt.Sprintf("INSERT OR REPLACE INTO kv (bucket, key, value) VALUES (%s, %s, X'%s');",
+		quote(bucket), quote(key), hex.EncodeToString(value)))
+	return err
+}
+
+// Delete removes key
+func (s *SQLiteStore) Delete(bucket, key string) error {
+	if err := checkBucket(bucket); err != nil {
+		return err
+	}
+	_, err := s.exec(fmt.Sprintf("DELETE FROM kv WHERE bucket = %s AND key = %s;", quote(bucket), quote(key)))
+	return err
+}
+
+// List returns every entry of bucket in key order
+func (s *SQLiteStore) List(bucket string) ([]Entry, error) {
+	if err := checkBucket(bucket); err != nil {
+		return nil, err
+	}
+	out, err := s.exec(fmt.Sprintf("SELECT hex(key) || ' ' || hex(value) FROM kv WHERE bucket = %s ORDER BY key;",
+		quote(bucket)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		hexKey, hexValue, _ := strings.Cut(line, " ")
+		key, err := hex.DecodeString(hexKey)
+		if err != nil {
+			return nil, fmt.Errorf("decoding key: %w", err)
+		}
+		value, err := hex.DecodeString(hexValue)
+		if err != nil {
+			return nil, fmt.Errorf("decoding value: %w", err)
+		}
+		entries = append(entries, Entry{Key: string(key), Value: value})
+	}
+	return entries, nil
+}
+
+// Close is a no-op; every statement runs in its own sqlite3 process
+func (s *SQLiteStore) Close() error {
+	return nil
+}
+
+// exec runs sql against the database and returns what it printed
+func (s *SQLiteStore) exec(sql string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cmd := exec.Command(s.binary, "-batch", "-bail", "-cmd", ".timeout 5000", s.path)
+	cmd.Stdin = strings.NewReader(sql)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("sqlite3: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// quote renders s as a SQL string literal
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
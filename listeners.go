@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+)
+
+// publicPaths are the endpoints served on the public listen addresses when
+// admin_listen moves everything else to a private address
+var publicPaths = []string{"/webhook", "/webhook/generic", "/chatops"}
+
+// publicRoutes serves only the webhook endpoints of routes, plus the
+// "server is running" root page load balancers probe
+func publicRoutes(routes http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	for _, path := range publicPaths {
+		mux.Handle(path, routes)
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		routes.ServeHTTP(w, r)
+	})
+	return mux
+}
+
+// listenAddresses returns the public addresses, defaulting to every
+// interface on port
+func listenAddresses() []string {
+	if len(appConfig().Listen) > 0 {
+		return appConfig().Listen
+	}
+	return []string{":" + appConfig().Port}
+}
+
+// startServers serves routes on the configured addresses, splitting off the
+// webhook endpoints when admin_listen is set. A listener that fails to start
+// stops binaryDeploy.
+func startServers(routes http.Handler) []*http.Server {
+	public, surface := routes, "all"
+	if len(appConfig().AdminListen) > 0 {
+		public, surface = publicRoutes(routes), "webhooks"
+	}
+
+	var servers []*http.Server
+	for _, addr := range listenAddresses() {
+		servers = append(servers, startServer(addr, surface, public))
+	}
+	for _, addr := range appConfig().AdminListen {
+		servers = append(servers, startServer(addr, "admin", routes))
+	}
+	return servers
+}
+
+func startServer(addr, surface string, handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           withForwardedHeaders(withACMEChallenge(withAuthBans(withBasePath(withDashboardAuth(handler))))),
+		ReadHeaderTimeout: readHeaderTimeout,
+		TLSConfig:         serverTLS,
+		ConnState:         trackConnection,
+	}
+
+	ln, err := listen(addr)
+	if err != nil {
+		slog.Error("Server failed", "addr", addr, "error", err)
+		fmt.Fprintf(os.Stderr, "Error listening on %s: %v\n", addr, err)
+		os.Exit(1)
+	}
+
+	go func() {
+		slog.Info("Starting webhook server", "addr", addr, "serves", surface, "tls", server.TLSConfig != nil)
+		if err := serve(server, ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server failed", "addr", addr, "error", err)
+			fmt.Fprintf(os.Stderr, "Error listening on %s: %v\n", addr, err)
+			os.Exit(1)
+		}
+	}()
+	return server
+}
+
+// serve is listenAndServe on a socket that is already listening
+func serve(server *http.Server, ln net.Listener) error {
+	if server.TLSConfig != nil {
+		return server.ServeTLS(ln, "", "")
+	}
+	return server.Serve(ln)
+}
+
+// listenAndServe serves HTTPS when server has a TLS configuration, plain
+// HTTP otherwise
+func listenAndServe(server *http.Server) error {
+	if server.TLSConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// shutdownServers gracefully stops every server
+func shutdownServers(ctx context.Context, servers []*http.Server) {
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Server forced to shutdown", "addr", server.Addr, "error", err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/testutil"
+)
+
+func TestStartServers_SplitsWebhooksFromAdmin(t *testing.T) {
+	public1 := "127.0.0.1:" + strconv.Itoa(testutil.FreePort(t))
+	public2 := "127.0.0.1:" + strconv.Itoa(testutil.FreePort(t))
+	admin := "127.0.0.1:" + strconv.Itoa(testutil.FreePort(t))
+	currentConfig.Store(&config.DeployConfig{Listen: []string{public1, public2}, AdminListen: []string{admin}})
+	setupAuthGuard()
+
+	routes := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "served "+r.URL.Path)
+	})
+	servers := startServers(routes)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownServers(ctx, servers)
+	})
+	if len(servers) != 3 {
+		t.Fatalf("Expected a server per address, got %d", len(servers))
+	}
+
+	get := func(addr, path string) (int, string) {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatalf("GET %s%s: %v", addr, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	for _, addr := range []string{public1, public2} {
+		for _, path := range []string{"/", "/webhook", "/webhook/generic", "/chatops"} {
+			if status, body := get(addr, path); status != http.StatusOK || body != "served "+path {
+				t.Errorf("%s%s: expected the public endpoint to be served, got %d %q", addr, path, status, body)
+			}
+		}
+		for _, path := range []string{"/deploy", "/monitor", "/update-self"} {
+			if status, _ := get(addr, path); status != http.StatusNotFound {
+				t.Errorf("%s%s: expected 404 on the public address, got %d", addr, path, status)
+			}
+		}
+	}
+	for _, path := range []string{"/webhook", "/deploy", "/monitor"} {
+		if status, body := get(admin, path); status != http.StatusOK || body != "served "+path {
+			t.Errorf("admin %s: expected everything to be served, got %d %q", path, status, body)
+		}
+	}
+}
+
+func TestListenAddresses_DefaultsToPort(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{Port: "8080"})
+	if addrs := listenAddresses(); len(addrs) != 1 || addrs[0] != ":8080" {
+		t.Errorf("Expected every interface on port, got %v", addrs)
+	}
+}
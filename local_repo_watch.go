@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/history"
+	"binaryDeploy/repowatch"
+)
+
+// startLocalRepoWatcher deploys whenever the HEAD of a local target
+// repository moves to a new commit, for air-gapped setups where nothing
+// sends webhooks. It returns immediately unless watch_local_repo is set.
+func startLocalRepoWatcher() {
+	if !appConfig().WatchLocalRepo {
+		return
+	}
+	path, _ := config.LocalRepoPath(appConfig().TargetRepoURL)
+
+	out, err := exec.Command("git", "-C", path, "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		slog.Error("Failed to find local repository, not watching it", "path", path, "error", err)
+		return
+	}
+	gitDir := strings.TrimSpace(string(out))
+
+	// Auto-start deploys the current commit
+	last, _, err := localRepoHead(path)
+	if err != nil {
+		slog.Error("Failed to read local repository HEAD, not watching it", "path", path, "error", err)
+		return
+	}
+
+	err = repowatch.Watch(context.Background(), gitDir, func() {
+		commit, branch, err := localRepoHead(path)
+		if err != nil {
+			slog.Warn("Failed to read local repository HEAD", "path", path, "error", err)
+			return
+		}
+		if commit == last {
+			return
+		}
+		last = commit
+		deployLocalRepoHead(path, commit, branch)
+	})
+	if err != nil {
+		slog.Error("Failed to watch local repository", "path", gitDir, "error", err)
+		return
+	}
+	slog.Info("Watching local repository for new commits", "path", gitDir, "head", shortSHA(last))
+}
+
+// localRepoHead returns the commit HEAD points at and its branch, "" when
+// detached
+func localRepoHead(path string) (commit, branch string, err error) {
+	out, err := exec.Command("git", "-C", path, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", "", err
+	}
+	commit = strings.TrimSpace(string(out))
+	// symbolic-ref -q fails quietly on a detached HEAD
+	if out, err := exec.Command("git", "-C", path, "symbolic-ref", "-q", "--short", "HEAD").Output(); err == nil {
+		branch = strings.TrimSpace(string(out))
+	}
+	return commit, branch, nil
+}
+
+// deployLocalRepoHead deploys commit after HEAD of the local repository
+// moved to it, subject to allowed_branches and freeze windows
+func deployLocalRepoHead(path, commit, branch string) {
+	if branch == "" {
+		slog.Warn("Local repository HEAD is detached, not deploying", "path", path, "commit", shortSHA(commit))
+		return
+	}
+	if !isAllowedBranch(branch) {
+		slog.Info("Branch not in allowed branches", "branch", branch, "commit", shortSHA(commit))
+		return
+	}
+
+	author := "local repository"
+	if out, err := exec.Command("git", "-C", path, "log", "-1", "--format=%an", commit).Output(); err == nil && len(out) > 1 {
+		author = strings.TrimSpace(string(out))
+	}
+	req := DeployRequest{
+		RepoURL:     appConfig().TargetRepoURL,
+		Branch:      branch,
+		Commit:      commit,
+		Trigger:     "Local repository change",
+		TriggeredBy: author,
+		Tags:        []string{history.TagLocalWatch},
+	}
+
+	if window, until, frozen := activeFreeze(time.Now()); frozen {
+		if appConfig().FreezeMode == "queue" {
+			slog.Info("Deployment queued by freeze window", "window", window.Spec, "until", until, "commit", shortSHA(commit))
+			queueFrozenDeployment(req, until)
+		} else {
+			slog.Warn("Deployment rejected by freeze window", "window", window.Spec, "until", until, "commit", shortSHA(commit))
+		}
+		return
+	}
+
+	slog.Info("Local repository HEAD changed, deploying", "branch", branch, "commit", shortSHA(commit), "author", author)
+	triggerTargetDeployment(req, fmt.Sprintf("Local repository change deployment triggered for %s (%s)", shortSHA(commit), branch))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"binaryDeploy/loghistory"
+	"binaryDeploy/logrotate"
+)
+
+// Page sizes of /logs/history
+const (
+	defaultLogHistoryLimit = 100
+	maxLogHistoryLimit     = 1000
+)
+
+// logHistoryFiles returns the log file and its rotated files, oldest first
+func logHistoryFiles() ([]string, error) {
+	rotated, err := logrotate.Rotated(appConfig().LogFile)
+	if err != nil {
+		return nil, err
+	}
+	return append(rotated, appConfig().LogFile), nil
+}
+
+// logHistoryHandler handles GET /logs/history, paging through the log file
+// and its rotated files beyond what the live stream buffers. Entries come
+// oldest first in the shape /logs streams them:
+//
+//	?before=<id>&limit=N  the N entries before id; without before, the latest
+//	?after=<id>&limit=N   the N entries after id
+//	?around=<id>&limit=N  id and the entries around it, for permalinks
+//
+// correlation_id narrows the entries to one webhook or deployment.
+func logHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if appConfig().LogOutput == "stdout" {
+		http.Error(w, "Log history needs log_output=file or both", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultLogHistoryLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxLogHistoryLimit)
+	}
+
+	cursors := 0
+	for _, name := range []string{"before", "after", "around"} {
+		if v := query.Get(name); v != "" {
+			cursors++
+			if !loghistory.ValidID(v) {
+				http.Error(w, name+" must be a log entry ID", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if cursors > 1 {
+		http.Error(w, "Use only one of before, after and around", http.StatusBadRequest)
+		return
+	}
+
+	var match func([]byte) bool
+	if id := query.Get("correlation_id"); id != "" {
+		match = func(line []byte) bool {
+			if !bytes.Contains(line, []byte(id)) {
+				return false
+			}
+			var v struct {
+				CorrelationID string `json:"correlation_id"`
+			}
+			return json.Unmarshal(line, &v) == nil && v.CorrelationID == id
+		}
+	}
+
+	files, err := logHistoryFiles()
+	if err != nil {
+		http.Error(w, "Failed to list log files", http.StatusInternalServerError)
+		return
+	}
+
+	var entries []loghistory.Entry
+	var older, newer bool
+	switch {
+	case query.Get("after") != "":
+		entries, newer, err = loghistory.After(files, query.Get("after"), limit, match)
+		older = true
+	case query.Get("around") != "":
+		entries, older, newer, err = loghistory.Around(files, query.Get("around"), limit, match)
+	default:
+		entries, older, err = loghistory.Before(files, query.Get("before"), limit, match)
+		newer = query.Get("before") != ""
+	}
+	if err != nil {
+		slog.Error("Failed to read log history", "error", err)
+		http.Error(w, "Failed to read log history", http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]StreamingLogEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, streamingEntry(e))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries":   out,
+		"has_older": older,
+		"has_newer": newer,
+	})
+}
+
+// streamingEntry converts a line of the log file into the form the live
+// stream sends
+func streamingEntry(e loghistory.Entry) StreamingLogEntry {
+	var fields map[string]interface{}
+	json.Unmarshal(e.Line, &fields)
+
+	entry := StreamingLogEntry{ID: e.ID, Timestamp: e.Time, Fields: map[string]interface{}{}}
+	var level slog.Level
+	if s, ok := fields["level"].(string); ok && level.UnmarshalText([]byte(s)) == nil {
+		entry.Level = level.String()
+	}
+	entry.Message, _ = fields["msg"].(string)
+	entry.Color = levelColor(level)
+	for key, value := range fields {
+		if key != "time" && key != "level" && key != "msg" {
+			entry.Fields[key] = value
+		}
+	}
+	return entry
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	"binaryDeploy/consolelog"
+)
+
+// stdoutLogHandler writes logs to stdout in log_format, coloring console
+// output only when stdout is a terminal
+func stdoutLogHandler() slog.Handler {
+	if appConfig().LogFormat == "console" {
+		info, err := os.Stdout.Stat()
+		color := err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == ""
+		return consolelog.NewHandler(os.Stdout, &consolelog.Options{Color: color})
+	}
+	return slog.NewJSONHandler(os.Stdout, nil)
+}
+
+// teeLogHandler sends every record to each of handlers
+func teeLogHandler(handlers []slog.Handler) slog.Handler {
+	if len(handlers) == 1 {
+		return handlers[0]
+	}
+	return teeHandler(handlers)
+}
+
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
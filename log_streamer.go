@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
 	"log/slog"
+
+	"binaryDeploy/logbuffer"
+	"binaryDeploy/loghistory"
+	"binaryDeploy/secrets"
 )
 
 // LogStreamer handles real-time log streaming with circular buffer
@@ -15,14 +20,16 @@ type LogStreamer struct {
 	logChan    chan []byte
 	clients    map[chan []byte]bool
 	clientsMux sync.RWMutex
-	buffer     [][]byte
-	bufferMux  sync.RWMutex
+	buffer     *logbuffer.Buffer
 	maxBuffer  int
+	maxAge     time.Duration
 	startTime  time.Time
+	redactor   *secrets.Redactor
 }
 
 // StreamingLogEntry represents a formatted log entry for frontend
 type StreamingLogEntry struct {
+	ID        string                 `json:"id"` // Links to the entry, see /logs/history
 	Timestamp time.Time              `json:"timestamp"`
 	Level     string                 `json:"level"`
 	Message   string                 `json:"message"`
@@ -33,8 +40,10 @@ type StreamingLogEntry struct {
 // Global log streamer instance
 var globalLogStreamer *LogStreamer
 
-// NewLogStreamer creates a new log streaming handler
-func NewLogStreamer(baseHandler slog.Handler, maxBuffer int) *LogStreamer {
+// NewLogStreamer creates a new log streaming handler keeping at most
+// maxBuffer entries, none older than maxAge (zero keeps them indefinitely).
+// Secret values known to redactor never reach the log file or the stream.
+func NewLogStreamer(baseHandler slog.Handler, maxBuffer int, maxAge time.Duration, redactor *secrets.Redactor) *LogStreamer {
 	if maxBuffer <= 0 {
 		maxBuffer = 1000 // default
 	}
@@ -43,9 +52,11 @@ func NewLogStreamer(baseHandler slog.Handler, maxBuffer int) *LogStreamer {
 		handler:   baseHandler,
 		logChan:   make(chan []byte, 1000), // buffered channel
 		clients:   make(map[chan []byte]bool),
-		buffer:    make([][]byte, 0, maxBuffer),
+		buffer:    logbuffer.New(maxBuffer, maxAge),
 		maxBuffer: maxBuffer,
+		maxAge:    maxAge,
 		startTime: time.Now(),
+		redactor:  redactor,
 	}
 
 	// Start log distribution goroutine
@@ -56,16 +67,22 @@ func NewLogStreamer(baseHandler slog.Handler, maxBuffer int) *LogStreamer {
 
 // Handle implements slog.Handler interface
 func (ls *LogStreamer) Handle(ctx context.Context, r slog.Record) error {
+	r = ls.redactRecord(r)
+	if id := logCorrelationID(ctx); id != "" {
+		r.AddAttrs(slog.String("correlation_id", id))
+	}
+
 	// First, write to the original handler (file)
 	err := ls.handler.Handle(ctx, r)
 
 	// Create streaming log entry
 	entry := StreamingLogEntry{
+		ID:        loghistory.ID(r.Time, r.Message),
 		Timestamp: r.Time,
 		Level:     r.Level.String(),
 		Message:   r.Message,
 		Fields:    make(map[string]interface{}),
-		Color:     ls.getLevelColor(r.Level),
+		Color:     levelColor(r.Level),
 	}
 
 	// Extract attributes
@@ -87,6 +104,70 @@ func (ls *LogStreamer) Handle(ctx context.Context, r slog.Record) error {
 	return err
 }
 
+// PublishAppLine streams a line of application output to /logs clients,
+// tagged with the app, instance and stream. It isn't written to log_file.
+func (ls *LogStreamer) PublishAppLine(line AppLine) {
+	entry := StreamingLogEntry{
+		ID:        loghistory.ID(line.Time, line.Line),
+		Timestamp: line.Time,
+		Level:     slog.LevelInfo.String(),
+		Message:   line.Line,
+		Fields: map[string]interface{}{
+			"app":      line.App,
+			"instance": line.Instance,
+			"stream":   line.Stream,
+		},
+		Color: "#6b7280", // gray, set apart from binaryDeploy's own entries
+	}
+	if data, err := json.Marshal(entry); err == nil {
+		select {
+		case ls.logChan <- data:
+		default:
+			// Channel full, skip this line to avoid blocking the app
+		}
+	}
+}
+
+// redactRecord returns r with secret values replaced in its message and
+// attributes
+func (ls *LogStreamer) redactRecord(r slog.Record) slog.Record {
+	if ls.redactor == nil {
+		return r
+	}
+	out := slog.NewRecord(r.Time, r.Level, ls.redactor.Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(ls.redactAttr(a))
+		return true
+	})
+	return out
+}
+
+// redactAttr replaces secret values in a, formatting non-string values such as
+// errors as strings when they contain one
+func (ls *LogStreamer) redactAttr(a slog.Attr) slog.Attr {
+	if ls.redactor == nil {
+		return a
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, ls.redactor.RedactField(a.Key, v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = ls.redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		text := fmt.Sprint(v.Any())
+		if redacted := ls.redactor.RedactField(a.Key, text); redacted != text {
+			return slog.String(a.Key, redacted)
+		}
+	}
+	return a
+}
+
 // Enabled implements slog.Handler interface
 func (ls *LogStreamer) Enabled(ctx context.Context, level slog.Level) bool {
 	return ls.handler.Enabled(ctx, level)
@@ -94,14 +175,19 @@ func (ls *LogStreamer) Enabled(ctx context.Context, level slog.Level) bool {
 
 // WithAttrs implements slog.Handler interface
 func (ls *LogStreamer) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = ls.redactAttr(a)
+	}
 	return &LogStreamer{
-		handler:   ls.handler.WithAttrs(attrs),
+		handler:   ls.handler.WithAttrs(redacted),
 		logChan:   ls.logChan,
 		clients:   ls.clients,
 		buffer:    ls.buffer,
-		bufferMux: ls.bufferMux,
 		maxBuffer: ls.maxBuffer,
+		maxAge:    ls.maxAge,
 		startTime: ls.startTime,
+		redactor:  ls.redactor,
 	}
 }
 
@@ -112,10 +198,24 @@ func (ls *LogStreamer) WithGroup(name string) slog.Handler {
 		logChan:   ls.logChan,
 		clients:   ls.clients,
 		buffer:    ls.buffer,
-		bufferMux: ls.bufferMux,
 		maxBuffer: ls.maxBuffer,
+		maxAge:    ls.maxAge,
 		startTime: ls.startTime,
+		redactor:  ls.redactor,
+	}
+}
+
+// logEntryMatches reports whether a streamed entry belongs to the flow with
+// correlationID; an empty correlationID matches every entry
+func logEntryMatches(data []byte, correlationID string) bool {
+	if correlationID == "" {
+		return true
+	}
+	var entry StreamingLogEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return false
 	}
+	return entry.Fields["correlation_id"] == correlationID
 }
 
 // AddClient adds a new SSE client
@@ -135,26 +235,20 @@ func (ls *LogStreamer) RemoveClient(clientChan chan []byte) {
 
 // GetBufferedLogs returns the current buffer contents
 func (ls *LogStreamer) GetBufferedLogs() [][]byte {
-	ls.bufferMux.RLock()
-	defer ls.bufferMux.RUnlock()
-
-	// Return a copy to avoid race conditions
-	result := make([][]byte, len(ls.buffer))
-	copy(result, ls.buffer)
-	return result
+	return ls.buffer.Snapshot()
 }
 
 // GetStats returns streaming statistics
 func (ls *LogStreamer) GetStats() map[string]interface{} {
-	ls.bufferMux.RLock()
 	ls.clientsMux.RLock()
-	defer ls.bufferMux.RUnlock()
 	defer ls.clientsMux.RUnlock()
 
 	return map[string]interface{}{
 		"clients_count":   len(ls.clients),
-		"buffer_size":     len(ls.buffer),
+		"buffer_size":     ls.buffer.Len(),
+		"buffer_bytes":    ls.buffer.Bytes(),
 		"max_buffer":      ls.maxBuffer,
+		"max_age":         ls.maxAge.String(),
 		"uptime":          time.Since(ls.startTime).String(),
 		"total_log_count": ls.getTotalLogCount(),
 	}
@@ -164,7 +258,7 @@ func (ls *LogStreamer) GetStats() map[string]interface{} {
 func (ls *LogStreamer) distributeLogs() {
 	for logData := range ls.logChan {
 		// Add to circular buffer
-		ls.addToBuffer(logData)
+		ls.buffer.Add(logData)
 
 		// Send to all clients
 		ls.clientsMux.RLock()
@@ -180,22 +274,8 @@ func (ls *LogStreamer) distributeLogs() {
 	}
 }
 
-// addToBuffer adds log entry to circular buffer
-func (ls *LogStreamer) addToBuffer(logData []byte) {
-	ls.bufferMux.Lock()
-	defer ls.bufferMux.Unlock()
-
-	ls.buffer = append(ls.buffer, logData)
-
-	// Maintain circular buffer size
-	if len(ls.buffer) > ls.maxBuffer {
-		// Remove oldest entry (slide window)
-		ls.buffer = ls.buffer[1:]
-	}
-}
-
-// getLevelColor returns CSS color class for log level
-func (ls *LogStreamer) getLevelColor(level slog.Level) string {
+// levelColor returns the CSS color of a log level
+func levelColor(level slog.Level) string {
 	switch level {
 	case slog.LevelError:
 		return "#ef4444" // red
@@ -0,0 +1,149 @@
+package logbuffer
+
+import (
+	"sync"
+	"time"
+)
+
+// chunkSize is the size of the blocks log lines are packed into. Large
+// blocks mean a few big allocations instead of one per line, and the entry
+// index holds no pointers, so the garbage collector has little to scan.
+const chunkSize = 64 * 1024
+
+type entry struct {
+	at    int64 // Unix nanoseconds
+	chunk int   // Absolute chunk number
+	off   int
+	n     int
+}
+
+// Buffer keeps the most recent log lines, bounded by count and by age
+type Buffer struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxAge     time.Duration
+
+	chunks     [][]byte // chunks[0] is chunk number firstChunk
+	firstChunk int
+	entries    []entry
+	head       int // Index of the oldest live entry in entries
+
+	now func() time.Time
+}
+
+// New returns a buffer holding at most maxEntries lines, none older than
+// maxAge. A zero maxAge disables age-based retention.
+func New(maxEntries int, maxAge time.Duration) *Buffer {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &Buffer{maxEntries: maxEntries, maxAge: maxAge, now: time.Now}
+}
+
+// Add appends a copy of data to the buffer
+func (b *Buffer) Add(data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+
+	last := len(b.chunks) - 1
+	if last < 0 || cap(b.chunks[last])-len(b.chunks[last]) < len(data) {
+		size := chunkSize
+		if len(data) > size {
+			size = len(data)
+		}
+		b.chunks = append(b.chunks, make([]byte, 0, size))
+		last++
+	}
+
+	off := len(b.chunks[last])
+	b.chunks[last] = append(b.chunks[last], data...)
+	b.entries = append(b.entries, entry{
+		at:    now.UnixNano(),
+		chunk: b.firstChunk + last,
+		off:   off,
+		n:     len(data),
+	})
+
+	b.evict(now)
+}
+
+// Snapshot returns the live lines, oldest first. The returned slices share
+// the buffer's memory and must not be modified.
+func (b *Buffer) Snapshot() [][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evict(b.now())
+
+	lines := make([][]byte, 0, len(b.entries)-b.head)
+	for _, e := range b.entries[b.head:] {
+		chunk := b.chunks[e.chunk-b.firstChunk]
+		lines = append(lines, chunk[e.off:e.off+e.n:e.off+e.n])
+	}
+	return lines
+}
+
+// Len returns the number of live lines
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.evict(b.now())
+	return len(b.entries) - b.head
+}
+
+// Bytes returns the memory held by line data
+func (b *Buffer) Bytes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total := 0
+	for _, chunk := range b.chunks {
+		total += cap(chunk)
+	}
+	return total
+}
+
+// evict drops lines over the count or age limit, then releases chunks no
+// live line points into; callers hold b.mu
+func (b *Buffer) evict(now time.Time) {
+	cutoff := int64(0)
+	if b.maxAge > 0 {
+		cutoff = now.Add(-b.maxAge).UnixNano()
+	}
+
+	for b.head < len(b.entries) {
+		e := b.entries[b.head]
+		if len(b.entries)-b.head <= b.maxEntries && e.at >= cutoff {
+			break
+		}
+		b.head++
+	}
+
+	if b.head == len(b.entries) {
+		// Everything expired; start over rather than keep empty chunks
+		b.firstChunk += len(b.chunks)
+		b.chunks = nil
+		b.entries = b.entries[:0]
+		b.head = 0
+		return
+	}
+
+	// Release chunks before the oldest live line
+	if drop := b.entries[b.head].chunk - b.firstChunk; drop > 0 {
+		for i := 0; i < drop; i++ {
+			b.chunks[i] = nil
+		}
+		b.chunks = b.chunks[drop:]
+		b.firstChunk += drop
+	}
+
+	// Compact the index once most of it is dead
+	if b.head > len(b.entries)/2 {
+		n := copy(b.entries, b.entries[b.head:])
+		b.entries = b.entries[:n]
+		b.head = 0
+	}
+}
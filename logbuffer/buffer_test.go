@@ -0,0 +1,102 @@
+package logbuffer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuffer_MaxEntries(t *testing.T) {
+	b := New(3, 0)
+	for i := 0; i < 10; i++ {
+		b.Add([]byte(fmt.Sprintf("line %d", i)))
+	}
+
+	lines := b.Snapshot()
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	for i, want := range []string{"line 7", "line 8", "line 9"} {
+		if string(lines[i]) != want {
+			t.Errorf("line %d: expected %q, got %q", i, want, lines[i])
+		}
+	}
+}
+
+func TestBuffer_MaxAge(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := New(100, time.Hour)
+	b.now = func() time.Time { return now }
+
+	b.Add([]byte("old"))
+	now = now.Add(30 * time.Minute)
+	b.Add([]byte("recent"))
+
+	now = now.Add(45 * time.Minute)
+	lines := b.Snapshot()
+	if len(lines) != 1 || string(lines[0]) != "recent" {
+		t.Fatalf("expected only the recent line, got %q", lines)
+	}
+
+	now = now.Add(time.Hour)
+	if b.Len() != 0 {
+		t.Errorf("expected all lines to expire, got %d", b.Len())
+	}
+	if b.Bytes() != 0 {
+		t.Errorf("expected chunks to be released, got %d bytes", b.Bytes())
+	}
+
+	b.Add([]byte("after idle"))
+	if lines := b.Snapshot(); len(lines) != 1 || string(lines[0]) != "after idle" {
+		t.Errorf("expected buffer to accept lines after expiring, got %q", lines)
+	}
+}
+
+func TestBuffer_ReleasesChunks(t *testing.T) {
+	b := New(10, 0)
+	line := []byte(strings.Repeat("x", 1024))
+
+	for i := 0; i < 1000; i++ {
+		b.Add(line)
+	}
+
+	if b.Len() != 10 {
+		t.Fatalf("expected 10 lines, got %d", b.Len())
+	}
+	// 10 lines of 1KiB fit in at most two chunks
+	if b.Bytes() > 2*chunkSize {
+		t.Errorf("expected old chunks to be released, holding %d bytes", b.Bytes())
+	}
+	for _, l := range b.Snapshot() {
+		if len(l) != len(line) {
+			t.Fatalf("corrupted line of length %d", len(l))
+		}
+	}
+}
+
+func TestBuffer_LargeLine(t *testing.T) {
+	b := New(5, 0)
+	big := []byte(strings.Repeat("y", chunkSize*2))
+	b.Add([]byte("small"))
+	b.Add(big)
+	b.Add([]byte("after"))
+
+	lines := b.Snapshot()
+	if len(lines) != 3 || len(lines[1]) != len(big) || string(lines[2]) != "after" {
+		t.Errorf("unexpected snapshot: %d lines", len(lines))
+	}
+}
+
+func TestBuffer_SnapshotIsStable(t *testing.T) {
+	b := New(2, 0)
+	b.Add([]byte("first"))
+	snapshot := b.Snapshot()
+
+	b.Add([]byte("second"))
+	b.Add([]byte("third"))
+
+	if string(snapshot[0]) != "first" {
+		t.Errorf("snapshot changed to %q", snapshot[0])
+	}
+}
@@ -0,0 +1,208 @@
+// Package loghistory pages through the JSON-lines log binaryDeploy writes,
+// including its rotated files, so a viewer can scroll back past the
+// in-memory buffer. Entries are addressed by IDs derived from their time
+// and message, which stay valid when the log rotates.
+package loghistory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLineSize bounds a single log line
+const maxLineSize = 1024 * 1024
+
+// Entry is one line of the log
+type Entry struct {
+	ID   string
+	Time time.Time
+	Line []byte // The JSON object as written
+}
+
+// ID returns the identifier of the entry logged at t with message msg:
+// the Unix time in milliseconds and a hash of the message, e.g.
+// 1760607193456-9f1c03aa
+func ID(t time.Time, msg string) string {
+	h := fnv.New32a()
+	h.Write([]byte(msg))
+	return fmt.Sprintf("%d-%08x", t.UnixMilli(), h.Sum32())
+}
+
+// ValidID reports whether id has the form ID returns
+func ValidID(id string) bool {
+	_, err := idMillis(id)
+	return err == nil
+}
+
+func idMillis(id string) (int64, error) {
+	ms, hash, ok := strings.Cut(id, "-")
+	if !ok || len(hash) != 8 {
+		return 0, fmt.Errorf("invalid log entry ID %q", id)
+	}
+	if _, err := strconv.ParseUint(hash, 16, 32); err != nil {
+		return 0, fmt.Errorf("invalid log entry ID %q", id)
+	}
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log entry ID %q", id)
+	}
+	return n, nil
+}
+
+// cursor locates a position in the log by entry ID. An entry that is no
+// longer in the log, e.g. because its file was deleted, is located by its
+// time instead.
+type cursor struct {
+	id     string
+	millis int64
+}
+
+func parseCursor(id string) (cursor, error) {
+	if id == "" {
+		return cursor{millis: math.MaxInt64}, nil
+	}
+	ms, err := idMillis(id)
+	return cursor{id: id, millis: ms}, err
+}
+
+// Before returns up to limit entries logged before the entry with ID
+// before, oldest first. An empty before pages back from the end of the
+// log. files are the log files oldest first; entries that match rejects
+// are skipped. more reports whether older entries remain.
+func Before(files []string, before string, limit int, match func([]byte) bool) (entries []Entry, more bool, err error) {
+	c, err := parseCursor(before)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Newest file first, keeping the last limit+1 entries of each before
+	// the cursor
+	for i := len(files) - 1; i >= 0 && len(entries) <= limit; i-- {
+		var ring []Entry
+		err := scan(files[i], func(e Entry) bool {
+			if e.ID == c.id || e.Time.UnixMilli() > c.millis {
+				return false
+			}
+			if match == nil || match(e.Line) {
+				ring = append(ring, e)
+				if len(ring) > limit+1 {
+					ring = ring[1:]
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		entries = append(ring, entries...)
+	}
+
+	if len(entries) > limit {
+		return entries[len(entries)-limit:], true, nil
+	}
+	return entries, false, nil
+}
+
+// After returns up to limit entries logged after the entry with ID after,
+// oldest first. An empty after starts at the beginning of the log. more
+// reports whether newer entries remain.
+func After(files []string, after string, limit int, match func([]byte) bool) ([]Entry, bool, error) {
+	return forward(files, after, limit, match, false)
+}
+
+// Around returns the entry with ID id together with up to limit entries
+// around it, oldest first, for links to a specific moment. The entry is
+// included even when match rejects it.
+func Around(files []string, id string, limit int, match func([]byte) bool) (entries []Entry, olderMore, newerMore bool, err error) {
+	if id == "" {
+		return nil, false, false, fmt.Errorf("invalid log entry ID %q", id)
+	}
+	older, olderMore, err := Before(files, id, limit/2, match)
+	if err != nil {
+		return nil, false, false, err
+	}
+	newer, newerMore, err := forward(files, id, limit-len(older), match, true)
+	if err != nil {
+		return nil, false, false, err
+	}
+	return append(older, newer...), olderMore, newerMore, nil
+}
+
+// forward collects entries after the cursor at from, including the entry
+// at the cursor itself when inclusive is set
+func forward(files []string, from string, limit int, match func([]byte) bool, inclusive bool) (entries []Entry, more bool, err error) {
+	c, err := parseCursor(from)
+	if err != nil {
+		return nil, false, err
+	}
+	passed := from == ""
+
+	for _, file := range files {
+		err := scan(file, func(e Entry) bool {
+			if !passed {
+				switch {
+				case e.ID == c.id:
+					passed = true
+					if !inclusive {
+						return true
+					}
+					entries = append(entries, e)
+					return len(entries) <= limit
+				case e.Time.UnixMilli() > c.millis:
+					passed = true
+				default:
+					return true
+				}
+			}
+			if match == nil || match(e.Line) {
+				entries = append(entries, e)
+			}
+			return len(entries) <= limit
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		if len(entries) > limit {
+			return entries[:limit], true, nil
+		}
+	}
+	return entries, false, nil
+}
+
+// scan calls fn with each entry of file in order until fn returns false. A
+// missing file has no entries; lines that aren't log entries are skipped.
+func scan(file string, fn func(Entry) bool) error {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var head struct {
+			Time time.Time `json:"time"`
+			Msg  string    `json:"msg"`
+		}
+		line := scanner.Bytes()
+		if err := json.Unmarshal(line, &head); err != nil || head.Time.IsZero() {
+			continue
+		}
+		entry := Entry{ID: ID(head.Time, head.Msg), Time: head.Time, Line: append([]byte(nil), line...)}
+		if !fn(entry) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,146 @@
+package loghistory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+// writeLog writes entries msg-first..msg-(first+n-1), one second apart
+func writeLog(t *testing.T, path string, first, n int) {
+	t.Helper()
+	var b strings.Builder
+	for i := first; i < first+n; i++ {
+		line, _ := json.Marshal(map[string]interface{}{
+			"time":  start.Add(time.Duration(i) * time.Second),
+			"level": "INFO",
+			"msg":   fmt.Sprintf("msg-%d", i),
+			"n":     i,
+		})
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rotatedLog returns a log of 10 entries whose first 6 were rotated
+func rotatedLog(t *testing.T) []string {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "app.log.20261016-090005"), filepath.Join(dir, "app.log")}
+	writeLog(t, files[0], 0, 6)
+	os.WriteFile(files[0], append(mustRead(t, files[0]), []byte("not json\n")...), 0644)
+	writeLog(t, files[1], 6, 4)
+	return files
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func idOf(i int) string {
+	return ID(start.Add(time.Duration(i)*time.Second), fmt.Sprintf("msg-%d", i))
+}
+
+func numbers(entries []Entry) string {
+	var parts []string
+	for _, e := range entries {
+		var v struct{ N int }
+		json.Unmarshal(e.Line, &v)
+		parts = append(parts, fmt.Sprint(v.N))
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestBefore(t *testing.T) {
+	files := rotatedLog(t)
+
+	entries, more, err := Before(files, "", 3, nil)
+	if err != nil {
+		t.Fatalf("Before failed: %v", err)
+	}
+	if got := numbers(entries); got != "7,8,9" || !more {
+		t.Errorf("latest page = %s (more %v), want 7,8,9 (more true)", got, more)
+	}
+	if entries[0].ID != idOf(7) {
+		t.Errorf("entry ID = %s, want %s", entries[0].ID, idOf(7))
+	}
+
+	// Across the rotation
+	entries, more, _ = Before(files, entries[0].ID, 3, nil)
+	if got := numbers(entries); got != "4,5,6" || !more {
+		t.Errorf("second page = %s (more %v), want 4,5,6 (more true)", got, more)
+	}
+
+	entries, more, _ = Before(files, idOf(2), 5, nil)
+	if got := numbers(entries); got != "0,1" || more {
+		t.Errorf("last page = %s (more %v), want 0,1 (more false)", got, more)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	files := rotatedLog(t)
+
+	entries, more, err := After(files, idOf(4), 3, nil)
+	if err != nil {
+		t.Fatalf("After failed: %v", err)
+	}
+	if got := numbers(entries); got != "5,6,7" || !more {
+		t.Errorf("page = %s (more %v), want 5,6,7 (more true)", got, more)
+	}
+
+	entries, more, _ = After(files, idOf(7), 5, nil)
+	if got := numbers(entries); got != "8,9" || more {
+		t.Errorf("page = %s (more %v), want 8,9 (more false)", got, more)
+	}
+}
+
+func TestAround(t *testing.T) {
+	files := rotatedLog(t)
+	even := func(line []byte) bool {
+		var v struct{ N int }
+		json.Unmarshal(line, &v)
+		return v.N%2 == 0
+	}
+
+	entries, olderMore, newerMore, err := Around(files, idOf(5), 5, even)
+	if err != nil {
+		t.Fatalf("Around failed: %v", err)
+	}
+	if got := numbers(entries); got != "2,4,5,6,8" || !olderMore || newerMore {
+		t.Errorf("around = %s (older %v, newer %v), want 2,4,5,6,8 (older true, newer false)", got, olderMore, newerMore)
+	}
+}
+
+func TestCursorNoLongerInLog(t *testing.T) {
+	files := rotatedLog(t)
+
+	// An entry from a deleted file is located by its time
+	gone := ID(start.Add(5500*time.Millisecond), "deleted")
+	entries, _, err := Before(files, gone, 2, nil)
+	if err != nil {
+		t.Fatalf("Before failed: %v", err)
+	}
+	if got := numbers(entries); got != "4,5" {
+		t.Errorf("before = %s, want 4,5", got)
+	}
+	entries, _, _ = After(files, gone, 2, nil)
+	if got := numbers(entries); got != "6,7" {
+		t.Errorf("after = %s, want 6,7", got)
+	}
+
+	if _, _, err := Before(files, "yesterday", 2, nil); err == nil {
+		t.Error("expected an error for a malformed ID")
+	}
+}
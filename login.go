@@ -0,0 +1,342 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sessionCookie  = "binarydeploy_session"
+	sessionKeyFile = "session_key"
+
+	// apiTokenUser is who logged in with api_token rather than auth_users
+	apiTokenUser = "api_token"
+)
+
+// sessionKey signs login page sessions. It is kept in the private state
+// directory so sessions outlive restarts and self-updates.
+var sessionKey []byte
+
+// authPublicPaths stay reachable without logging in when require_auth is
+// set: the webhooks check their own signatures or tokens, and / is probed
+// by load balancers
+var authPublicPaths = []string{"/", "/webhook", "/webhook/generic", "/chatops", "/login", "/logout"}
+
+// errCrossOrigin rejects state-changing requests a session cookie was sent
+// with from another site
+var errCrossOrigin = errors.New("cross-origin request")
+
+// setupSessions loads or creates the key login page sessions are signed
+// with
+func setupSessions() {
+	path, err := privateStatePath(sessionKeyFile)
+	if err == nil {
+		key, readErr := os.ReadFile(path)
+		if readErr == nil && len(key) == 32 {
+			sessionKey = key
+			return
+		}
+	}
+
+	key := make([]byte, 32)
+	rand.Read(key)
+	sessionKey = key
+	if err == nil {
+		err = os.WriteFile(path, key, 0600)
+	}
+	if err != nil {
+		slog.Warn("Failed to save the session key, logins won't survive a restart", "error", err)
+	}
+}
+
+// withDashboardAuth requires every request but those to authPublicPaths to
+// be authenticated when require_auth is set. Browsers asking for a page are
+// sent to the login page; API clients get 401.
+func withDashboardAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !appConfig().RequireAuth || isAuthPublicPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := authenticate(r)
+		switch {
+		case errors.Is(err, errCrossOrigin):
+			slog.Warn("Rejected cross-origin request", "path", r.URL.Path, "origin", r.Header.Get("Origin"), "client", requestSource(r))
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		case err != nil:
+			slog.Warn("Rejected unauthenticated request", "path", r.URL.Path, "client", requestSource(r), "error", err)
+			recordAuthFailure(r, err.Error())
+			unauthorized(w, r)
+		case user == "":
+			unauthorized(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+func isAuthPublicPath(path string) bool {
+	for _, public := range authPublicPaths {
+		if path == public {
+			return true
+		}
+	}
+	return false
+}
+
+// unauthorized sends a browser to the login page and tells API clients how
+// to authenticate
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, appConfig().BasePath+"/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+		return
+	}
+	if len(appConfig().AuthUsers) > 0 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="binaryDeploy", charset="UTF-8"`)
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// authenticate returns who made r: the api_token bearer, an auth_users
+// basic auth user or the user of a session cookie. It returns "" without
+// an error when r carries no credentials, and an error when they are wrong.
+func authenticate(r *http.Request) (string, error) {
+	if hasValidAPIToken(r) {
+		return apiTokenUser, nil
+	}
+	if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return "", errors.New("invalid api_token")
+	}
+	if name, password, ok := r.BasicAuth(); ok {
+		if !validUserPassword(name, password) {
+			return "", errors.New("invalid password for " + name)
+		}
+		return name, nil
+	}
+
+	for _, cookie := range r.Cookies() {
+		if cookie.Name != sessionCookie {
+			continue
+		}
+		user, ok := verifySession(cookie.Value)
+		if !ok {
+			continue
+		}
+		if !sameOrigin(r) {
+			return "", errCrossOrigin
+		}
+		return user, nil
+	}
+	return "", nil
+}
+
+// validUserPassword checks a login against auth_users
+func validUserPassword(name, password string) bool {
+	expected, ok := appConfig().AuthUsers[name]
+	if !ok {
+		// Compare anyway so unknown users take as long as wrong passwords
+		expected = "\x00"
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1 && ok
+}
+
+// sameOrigin reports whether a state-changing request came from the
+// dashboard itself. Browsers send Origin with cross-site POSTs, so a
+// mismatch means another site is riding on the session cookie.
+func sameOrigin(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return r.Header.Get("Sec-Fetch-Site") != "cross-site"
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// sessionSecret is what a session of user is bound to besides the key, so
+// changing the password or api_token logs its sessions out
+func sessionSecret(user string) (string, bool) {
+	if user == apiTokenUser {
+		return appConfig().APIToken, appConfig().APIToken != ""
+	}
+	password, ok := appConfig().AuthUsers[user]
+	return password, ok
+}
+
+func sessionMAC(user, expires, secret string) []byte {
+	mac := hmac.New(sha256.New, sessionKey)
+	mac.Write([]byte(user + "\n" + expires + "\n" + secret))
+	return mac.Sum(nil)
+}
+
+// newSession returns a cookie value logging user in for session_timeout
+func newSession(user string) (string, time.Time) {
+	secret, _ := sessionSecret(user)
+	expires := time.Now().Add(time.Duration(appConfig().SessionTimeout) * time.Second)
+	unix := strconv.FormatInt(expires.Unix(), 10)
+	value := base64.RawURLEncoding.EncodeToString([]byte(user)) + "." + unix + "." +
+		base64.RawURLEncoding.EncodeToString(sessionMAC(user, unix, secret))
+	return value, expires
+}
+
+// verifySession returns the user of a session cookie value that is
+// unexpired and correctly signed
+func verifySession(value string) (string, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	name, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", false
+	}
+	user := string(name)
+	secret, ok := sessionSecret(user)
+	if !ok || !hmac.Equal(signature, sessionMAC(user, parts[1], secret)) {
+		return "", false
+	}
+	return user, true
+}
+
+// sessionCookiePath scopes the cookie to this instance, so tenants and apps
+// behind one front server don't overwrite each other's sessions
+func sessionCookiePath() string {
+	return appConfig().BasePath + "/"
+}
+
+// loginHandler serves the login page and starts a session for a valid
+// auth_users login or api_token
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	next := loginRedirectPath(r.FormValue("next"))
+
+	switch r.Method {
+	case http.MethodGet:
+		renderLogin(w, next, "", http.StatusOK)
+	case http.MethodPost:
+		name, password := r.PostFormValue("username"), r.PostFormValue("password")
+		user := ""
+		switch {
+		case name == "" && appConfig().APIToken != "" &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(appConfig().APIToken)) == 1:
+			user = apiTokenUser
+		case name != "" && validUserPassword(name, password):
+			user = name
+		}
+		if user == "" {
+			slog.Warn("Failed login", "user", name, "client", requestSource(r))
+			recordAuthFailure(r, "invalid login")
+			renderLogin(w, next, "Invalid user name or password", http.StatusUnauthorized)
+			return
+		}
+
+		value, expires := newSession(user)
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookie,
+			Value:    value,
+			Path:     sessionCookiePath(),
+			Expires:  expires,
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.URL.Scheme == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+		slog.Info("User logged in", "user", user, "client", requestSource(r))
+		http.Redirect(w, r, appConfig().BasePath+next, http.StatusSeeOther)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// loginRedirectPath returns next when it is a path on this server, and the
+// dashboard otherwise. Browsers read a backslash as a slash, so /\evil.com
+// would leave the site like //evil.com does.
+func loginRedirectPath(next string) string {
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.Contains(next, `\`) ||
+		!strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		return "/monitor"
+	}
+	return next
+}
+
+// logoutHandler ends the session and returns to the login page
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     sessionCookiePath(),
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, appConfig().BasePath+"/login", http.StatusSeeOther)
+}
+
+func renderLogin(w http.ResponseWriter, next, message string, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	loginTemplate.Execute(w, map[string]any{
+		"Next":     next,
+		"Message":  message,
+		"Users":    len(appConfig().AuthUsers) > 0,
+		"APIToken": appConfig().APIToken != "",
+	})
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Binary Deploy - Log in</title>
+    <style>
+        body { background: #0d1117; color: #f0f6fc; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', sans-serif;
+               display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+        form { background: #161b22; border: 1px solid #30363d; border-radius: 8px; padding: 32px; width: 320px; }
+        h1 { font-size: 20px; margin: 0 0 24px; }
+        label { display: block; font-size: 13px; color: #8b949e; margin-bottom: 6px; }
+        input { width: 100%; box-sizing: border-box; padding: 8px 10px; margin-bottom: 16px; background: #0d1117;
+                border: 1px solid #30363d; border-radius: 6px; color: #f0f6fc; font-size: 14px; }
+        button { width: 100%; padding: 10px; background: #238636; border: none; border-radius: 6px; color: #fff;
+                 font-size: 14px; font-weight: 600; cursor: pointer; }
+        .message { color: #f85149; font-size: 13px; margin-bottom: 16px; }
+        .hint { color: #8b949e; font-size: 12px; margin-top: 16px; }
+    </style>
+</head>
+<body>
+    <form method="post" action="login">
+        <h1>🚀 Binary Deploy</h1>
+        {{if .Message}}<div class="message">{{.Message}}</div>{{end}}
+        <input type="hidden" name="next" value="{{.Next}}">
+        {{if .Users}}<label for="username">User name</label>
+        <input id="username" name="username" autocomplete="username" autofocus>{{end}}
+        <label for="password">{{if .Users}}Password{{else}}API token{{end}}</label>
+        <input id="password" name="password" type="password" autocomplete="current-password" {{if not .Users}}autofocus{{end}}>
+        <button type="submit">Log in</button>
+        {{if and .Users .APIToken}}<div class="hint">Leave the user name empty to log in with the API token.</div>{{end}}
+    </form>
+</body>
+</html>
+`))
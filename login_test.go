@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func setupLoginTest(t *testing.T) {
+	t.Helper()
+	currentConfig.Store(&config.DeployConfig{
+		APIToken:       "login-test-token",
+		AuthUsers:      map[string]string{"alice": "correct horse"},
+		SessionTimeout: 3600,
+	})
+	sessionKey = []byte("0123456789abcdef0123456789abcdef")
+}
+
+func postLogin(next, username, password string) *httptest.ResponseRecorder {
+	form := url.Values{"next": {next}, "username": {username}, "password": {password}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	loginHandler(rec, req)
+	return rec
+}
+
+func TestLoginHandler_RedirectsOnlyWithinTheServer(t *testing.T) {
+	setupLoginTest(t)
+
+	tests := []struct {
+		next     string
+		location string
+	}{
+		{"/apps/web/logs?lines=50", "/apps/web/logs?lines=50"},
+		{"/deployments", "/deployments"},
+		{"", "/monitor"},
+		{"//evil.com", "/monitor"},
+		{`/\evil.com`, "/monitor"},
+		{`/\/evil.com`, "/monitor"},
+		{"https://evil.com/", "/monitor"},
+		{"javascript:alert(1)", "/monitor"},
+		{"/\t/evil.com", "/monitor"},
+		{"monitor", "/monitor"},
+	}
+	for _, tt := range tests {
+		rec := postLogin(tt.next, "alice", "correct horse")
+		if rec.Code != http.StatusSeeOther {
+			t.Errorf("next=%q: expected 303, got %d", tt.next, rec.Code)
+			continue
+		}
+		if location := rec.Header().Get("Location"); location != tt.location {
+			t.Errorf("next=%q: expected redirect to %q, got %q", tt.next, tt.location, location)
+		}
+	}
+}
+
+func TestLoginHandler_SetsSessionCookie(t *testing.T) {
+	setupLoginTest(t)
+
+	if rec := postLogin("/monitor", "alice", "wrong"); rec.Code != http.StatusUnauthorized || len(rec.Result().Cookies()) != 0 {
+		t.Fatalf("Expected a wrong password to be refused without a session, got %d %v", rec.Code, rec.Result().Cookies())
+	}
+
+	for _, login := range []struct{ username, password, user string }{
+		{"alice", "correct horse", "alice"},
+		{"", "login-test-token", apiTokenUser},
+	} {
+		rec := postLogin("/monitor", login.username, login.password)
+		cookies := rec.Result().Cookies()
+		if rec.Code != http.StatusSeeOther || len(cookies) != 1 || cookies[0].Name != sessionCookie {
+			t.Fatalf("Expected a session cookie for %q, got %d %v", login.user, rec.Code, cookies)
+		}
+		if !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode {
+			t.Errorf("Expected an HttpOnly, SameSite=Lax cookie, got %+v", cookies[0])
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/monitor", nil)
+		req.AddCookie(cookies[0])
+		if user, err := authenticate(req); err != nil || user != login.user {
+			t.Errorf("Expected the session to authenticate %q, got %q, %v", login.user, user, err)
+		}
+	}
+}
+
+func TestVerifySession(t *testing.T) {
+	setupLoginTest(t)
+
+	value, _ := newSession("alice")
+	if user, ok := verifySession(value); !ok || user != "alice" {
+		t.Fatalf("Expected a fresh session to verify, got %q, %v", user, ok)
+	}
+
+	parts := strings.Split(value, ".")
+	forged := parts[0] + "." + "9999999999" + "." + parts[2]
+	if _, ok := verifySession(forged); ok {
+		t.Error("Expected a session with a changed expiry to be rejected")
+	}
+	if _, ok := verifySession(parts[0] + ".1." + parts[2]); ok {
+		t.Error("Expected an expired session to be rejected")
+	}
+
+	currentConfig.Store(&config.DeployConfig{AuthUsers: map[string]string{"alice": "new password"}, SessionTimeout: 3600})
+	if _, ok := verifySession(value); ok {
+		t.Error("Expected changing the password to end existing sessions")
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{"GET from anywhere", http.MethodGet, map[string]string{"Origin": "https://evil.example"}, true},
+		{"POST from the dashboard", http.MethodPost, map[string]string{"Origin": "http://deploy.example.com"}, true},
+		{"POST from another site", http.MethodPost, map[string]string{"Origin": "https://evil.example"}, false},
+		{"POST marked cross-site", http.MethodPost, map[string]string{"Sec-Fetch-Site": "cross-site"}, false},
+		{"POST without browser headers", http.MethodPost, nil, true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://deploy.example.com/deploy", nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		if got := sameOrigin(req); got != tt.want {
+			t.Errorf("%s: sameOrigin = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthenticate_RejectsCrossOriginSession(t *testing.T) {
+	setupLoginTest(t)
+	value, _ := newSession("alice")
+
+	req := httptest.NewRequest(http.MethodPost, "http://deploy.example.com/deploy", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: value})
+	if _, err := authenticate(req); err != errCrossOrigin {
+		t.Errorf("Expected errCrossOrigin, got %v", err)
+	}
+}
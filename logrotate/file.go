@@ -0,0 +1,166 @@
+// Package logrotate provides a log file that is renamed aside once it grows
+// past a size limit, so rotated files can be shipped elsewhere and removed
+package logrotate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// timestampFormat is appended to rotated file names; it sorts chronologically
+const timestampFormat = "20060102T150405.000Z"
+
+// File is an append-only log file that rotates itself when it exceeds
+// maxBytes. A maxBytes of 0 never rotates.
+type File struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	file     *os.File
+	size     int64
+
+	// fallback receives writes while the file can't be opened or written
+	fallback io.Writer
+	err      error
+}
+
+// Open opens or creates the log file at path
+func Open(path string, maxBytes int64) (*File, error) {
+	f := &File{path: path, maxBytes: maxBytes}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenWithFallback opens the log file at path like Open, but keeps logging to
+// fallback instead of failing when the file can't be opened or written, e.g.
+// on a read-only filesystem or a full disk. The returned File is always
+// usable; the error says why it started degraded. Call Reopen to try the file
+// again.
+func OpenWithFallback(path string, maxBytes int64, fallback io.Writer) (*File, error) {
+	f := &File{path: path, maxBytes: maxBytes, fallback: fallback}
+	if err := f.open(); err != nil {
+		f.err = err
+		return f, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past its limit.
+// A failed rotation keeps writing to the current file rather than losing logs.
+// With a fallback, p goes there instead while the file is unavailable.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return f.fallback.Write(p)
+	}
+
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		f.rotate()
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err != nil && f.fallback != nil {
+		// Repeat the whole entry rather than splitting it across outputs
+		f.err = err
+		return f.fallback.Write(p)
+	}
+	if err == nil {
+		f.err = nil
+	}
+	return n, err
+}
+
+// Reopen tries to open the file again if it couldn't be opened before. It is
+// a no-op while the file is open; a file that fails writes keeps being
+// retried by every Write.
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file != nil {
+		return nil
+	}
+	if err := f.open(); err != nil {
+		f.err = err
+		return err
+	}
+	f.err = nil
+	return nil
+}
+
+// Err returns why the file is being bypassed for the fallback, or nil while
+// logs reach it
+func (f *File) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// rotate renames the current file aside and starts a new one
+func (f *File) rotate() {
+	rotated := f.path + "." + time.Now().UTC().Format(timestampFormat)
+	if err := os.Rename(f.path, rotated); err != nil {
+		fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		return
+	}
+
+	old := f.file
+	if err := f.open(); err != nil {
+		// Keep writing to the renamed file until the next attempt
+		fmt.Fprintf(os.Stderr, "reopening log file failed: %v\n", err)
+		return
+	}
+	old.Close()
+}
+
+// Close closes the current file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
+// Rotated returns the rotated files of the log at path, oldest first
+func Rotated(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	var rotated []string
+	for _, match := range matches {
+		suffix := match[len(path)+1:]
+		if _, err := time.Parse(timestampFormat, suffix); err == nil {
+			rotated = append(rotated, match)
+		}
+	}
+	sort.Strings(rotated)
+	return rotated, nil
+}
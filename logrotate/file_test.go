@@ -0,0 +1,99 @@
+package logrotate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFile_RotatesPastLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := Open(path, 10)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	f.Write([]byte("12345678\n"))
+	f.Write([]byte("abcdefgh\n"))
+
+	rotated, err := Rotated(path)
+	if err != nil || len(rotated) != 1 {
+		t.Fatalf("expected one rotated file, got %v, %v", rotated, err)
+	}
+	if data, _ := os.ReadFile(rotated[0]); string(data) != "12345678\n" {
+		t.Errorf("rotated file holds %q", data)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "abcdefgh\n" {
+		t.Errorf("current file holds %q", data)
+	}
+}
+
+func TestFile_NoLimitNeverRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 100; i++ {
+		f.Write([]byte(strings.Repeat("x", 100)))
+	}
+
+	if rotated, _ := Rotated(path); len(rotated) != 0 {
+		t.Errorf("expected no rotation, got %v", rotated)
+	}
+}
+
+func TestRotated_IgnoresOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	os.WriteFile(path+".backup", nil, 0644)
+	os.WriteFile(path+".20261016T040000.000Z", nil, 0644)
+
+	rotated, _ := Rotated(path)
+	if len(rotated) != 1 || !strings.HasSuffix(rotated[0], ".20261016T040000.000Z") {
+		t.Errorf("unexpected rotated files %v", rotated)
+	}
+}
+
+func TestFile_FallsBackWhenUnavailable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "app.log")
+	var fallback strings.Builder
+
+	f, err := OpenWithFallback(path, 0, &fallback)
+	if err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+	defer f.Close()
+
+	f.Write([]byte("first\n"))
+	if fallback.String() != "first\n" {
+		t.Errorf("expected the write to reach the fallback, got %q", fallback.String())
+	}
+	if f.Err() == nil {
+		t.Error("expected Err to report the degraded state")
+	}
+
+	if err := f.Reopen(); err == nil {
+		t.Fatal("expected Reopen to fail while the directory is missing")
+	}
+	os.MkdirAll(dir, 0755)
+	if err := f.Reopen(); err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if f.Err() != nil {
+		t.Errorf("expected Err to clear after reopening, got %v", f.Err())
+	}
+
+	f.Write([]byte("second\n"))
+	if data, _ := os.ReadFile(path); string(data) != "second\n" {
+		t.Errorf("expected the file to receive writes after reopening, got %q", data)
+	}
+	if fallback.String() != "first\n" {
+		t.Errorf("expected nothing more on the fallback, got %q", fallback.String())
+	}
+}
@@ -6,22 +6,32 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"hash"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"binaryDeploy/config"
+	"binaryDeploy/github"
+	"binaryDeploy/history"
+	"binaryDeploy/logrotate"
 	"binaryDeploy/monitor"
+	"binaryDeploy/notify"
 	"binaryDeploy/processmanager"
+	"binaryDeploy/secrets"
 	"binaryDeploy/updater"
 )
 
@@ -35,6 +45,7 @@ func min(a, b int) int {
 
 type GitHubPushPayload struct {
 	Ref        string `json:"ref"`
+	After      string `json:"after"` // New head commit; Gogs sends no head_commit
 	Repository struct {
 		Name string `json:"name"`
 		URL  string `json:"clone_url"`
@@ -43,6 +54,24 @@ type GitHubPushPayload struct {
 		ID      string `json:"id"`
 		Message string `json:"message"`
 	} `json:"head_commit"`
+	Commits []struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+	} `json:"commits"`
+	Pusher struct {
+		Name     string `json:"name"`
+		Username string `json:"username"` // Gitea and Gogs
+		Login    string `json:"login"`
+	} `json:"pusher"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+
+	// Release events carry the release instead of a ref and commits
+	Action  string `json:"action"`
+	Release struct {
+		TagName string `json:"tag_name"`
+	} `json:"release"`
 }
 
 type UpdateStatus struct {
@@ -50,12 +79,21 @@ type UpdateStatus struct {
 	StartTime   time.Time `json:"start_time"`
 	Message     string    `json:"message"`
 	Error       string    `json:"error,omitempty"`
+	RolledBack  bool      `json:"rolled_back,omitempty"` // The previous build was restarted after the new one failed
 	CompletedAt time.Time `json:"completed_at,omitempty"`
 }
 
 var (
-	appConfig      *config.DeployConfig
-	processManager *processmanager.ProcessManager
+	// reloadConfig replaces the configuration and what's built from it while
+	// handlers and deployments read them, so they're read through appConfig,
+	// notifier and githubClient
+	currentConfig       atomic.Pointer[config.DeployConfig]
+	currentNotifier     atomic.Pointer[notify.Dispatcher]
+	currentGitHubClient atomic.Pointer[github.Client]
+
+	processManager *processmanager.Group
+	secretRedactor *secrets.Redactor
+	appLogFile     *logrotate.File
 	updateStatus   = struct {
 		sync.RWMutex
 		target UpdateStatus `json:"target"`
@@ -66,6 +104,22 @@ var (
 	}
 )
 
+// appConfig returns the running configuration
+func appConfig() *config.DeployConfig {
+	return currentConfig.Load()
+}
+
+// notifier returns the dispatcher for the running configuration's notifiers
+func notifier() *notify.Dispatcher {
+	return currentNotifier.Load()
+}
+
+// githubClient returns the GitHub API client, or nil when the integration is
+// off
+func githubClient() *github.Client {
+	return currentGitHubClient.Load()
+}
+
 func main() {
 	// Handle command line flags
 	if len(os.Args) > 1 {
@@ -76,48 +130,131 @@ func main() {
 		case "--help":
 			fmt.Println("BinaryDeploy - Self-Updating Git Webhook Server")
 			fmt.Println("Usage:")
-			fmt.Println("  binaryDeploy              - Start webhook server")
-			fmt.Println("  binaryDeploy --version    - Show version information")
-			fmt.Println("  binaryDeploy --help       - Show this help message")
+			fmt.Println("  binaryDeploy                     - Start webhook server")
+			fmt.Println("  binaryDeploy --version           - Show version information")
+			fmt.Println("  binaryDeploy --help              - Show this help message")
+			fmt.Println("  binaryDeploy --simulate          - Build and start the target app in a scratch directory, exit 0 if it would deploy")
+			fmt.Println("  binaryDeploy generate-secret-key - Print a new key for encrypted config values")
+			fmt.Println("  binaryDeploy encrypt-secret      - Encrypt a value read from stdin")
+			fmt.Println("  binaryDeploy tenants [file]      - Run one isolated instance per tenant in tenants.config")
+			fmt.Println("  binaryDeploy install-service     - Install and start a systemd service running binaryDeploy from this directory")
 			return
+		case "--simulate":
+			os.Exit(runSimulateCommand(os.Args[2:]))
+		case "tenants":
+			os.Exit(runTenantsCommand(os.Args[2:]))
+		case "install-service":
+			os.Exit(runInstallServiceCommand(os.Args[2:]))
+		case "generate-secret-key", "encrypt-secret":
+			if err := runSecretsCommand(os.Args[1], os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// Apps declared in deploy.config or deploy.d/ each get an instance of
+	// their own behind a front server
+	if managedAppName == "" {
+		apps, err := multiAppConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading deploy.config: %v\n", err)
+			os.Exit(1)
+		}
+		if apps != nil {
+			os.Exit(runMultiApp(apps))
 		}
 	}
 
 	loadConfig()
 	setupLogger()
+	logEffectiveConfig()
+	adoptInheritedListeners()
 
-	// Initialize process manager
-	processManager = processmanager.NewProcessManager()
+	if err := setupTrustedCAs(); err != nil {
+		slog.Error("Failed to load custom CA certificates", "error", err)
+		fmt.Fprintf(os.Stderr, "Error loading ca_certs: %v\n", err)
+		os.Exit(1)
+	}
 
-	server := &http.Server{
-		Addr:    ":" + appConfig.Port,
-		Handler: setupRoutes(),
+	if err := setupGitCredentials(); err != nil {
+		slog.Error("Failed to set up git credentials", "error", err)
+		fmt.Fprintf(os.Stderr, "Error setting up git credentials: %v\n", err)
+		os.Exit(1)
 	}
 
-	go func() {
-		slog.Info("Starting webhook server", "port", appConfig.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("Server failed", "error", err)
-			os.Exit(1)
-		}
-	}()
+	setupTempDirs()
+	cleanSelfUpdateLeftovers()
+
+	// Initialize process manager, with an instance per blue-green release
+	instances := appConfig().Instances
+	if blueGreenEnabled() {
+		instances = len(releaseColors)
+	}
+	processManager = processmanager.NewGroup(instances)
+	setupBlueGreen()
+	setupNotifications()
+	setupAuthGuard()
+	setupReverseProxy()
+	setupEscalation()
+	setupCrashReporting()
+	setupAppOutput()
+	setupGitHub()
+	setupStorage()
+	setupHistory()
+	setupUptimeTracking()
+	setupDeployLimits()
+	setupArtifactStore()
+	setupDeployQueue()
+	setupDependencyChecks()
+	publishRuntimeVars()
+	runStartupDiagnostics()
+
+	setupTLS()
+	setupSessions()
+	servers := startServers(setupRoutes())
+	startCertificateRenewal()
+	if proxy := startBlueGreenProxy(); proxy != nil {
+		servers = append(servers, proxy)
+	}
+	closeUnusedListeners()
 
 	// Auto-start target app after server initialization
 	go func() {
 		// Give server a moment to start up
 		time.Sleep(3 * time.Second)
 
-		slog.Info("Auto-starting target application", "repo", appConfig.TargetRepoURL)
-		if err := deployTargetRepo(appConfig.TargetRepoURL); err != nil {
+		slog.Info("Auto-starting target application", "repo", appConfig().TargetRepoURL)
+		if err := runDeployment(DeployRequest{RepoURL: appConfig().TargetRepoURL, Trigger: "Auto-start", TriggeredBy: "binaryDeploy", Tags: []string{history.TagAutoStart}}); err != nil {
 			slog.Error("Auto-start deployment failed", "error", err)
 		} else {
 			slog.Info("Target application auto-started successfully")
 		}
 	}()
 
+	startRestartScheduler()
+	startGitGCScheduler()
+	startLocalRepoWatcher()
+	startIncomingWatcher()
+	startOffsiteBackups()
+	startUptimeTracker()
+	startDiskQuotaJanitor()
+	startConfigRepoSync()
+	startReloadSignalHandler()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	for running := true; running; {
+		select {
+		case <-quit:
+			running = false
+		case reason := <-selfRestart:
+			if err := restartSelf(servers, reason); err != nil {
+				selfRestartFailed(err)
+			}
+		}
+	}
 
 	slog.Info("Shutting down server...")
 
@@ -129,35 +266,76 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		slog.Error("Server forced to shutdown", "error", err)
-	}
+	shutdownServers(ctx, servers)
+	removeTempDirs()
 
 	slog.Info("Server exited")
 }
 
+// logReopenInterval is how often a log file that can't be written is retried
+const logReopenInterval = 30 * time.Second
+
 func setupLogger() {
-	if appConfig.LogFile == "" {
-		appConfig.LogFile = "./binaryDeploy.log"
+	var handlers []slog.Handler
+	var logErr error
+	if appConfig().LogOutput != "stdout" {
+		// A log file that can't be opened mustn't take the server down; log
+		// to stderr until it can, unless stdout gets the logs anyway
+		var fallback io.Writer = os.Stderr
+		if appConfig().LogOutput == "both" {
+			fallback = io.Discard
+		}
+		appLogFile, logErr = logrotate.OpenWithFallback(appConfig().LogFile, int64(appConfig().LogMaxSizeMB)*1024*1024, fallback)
+		handlers = append(handlers, slog.NewJSONHandler(appLogFile, nil))
 	}
-
-	logFile, err := os.OpenFile(appConfig.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		panic(err)
+	if appConfig().LogOutput != "file" {
+		handlers = append(handlers, stdoutLogHandler())
 	}
-
-	// Create base JSON handler for file logging
-	baseHandler := slog.NewJSONHandler(logFile, nil)
+	baseHandler := teeLogHandler(handlers)
 
 	// Wrap with streaming handler for real-time logs
-	globalLogStreamer = NewLogStreamer(baseHandler, appConfig.LogBufferSize)
+	// Rules were checked when the config was validated
+	rules, _ := appConfig().RedactionRules()
+	secretRedactor = secrets.NewRedactor(appConfig().SecretValues(), rules...)
+	globalLogStreamer = NewLogStreamer(baseHandler, appConfig().LogBufferSize, time.Duration(appConfig().LogBufferMaxAge)*time.Second, secretRedactor)
 
 	logger := slog.New(globalLogStreamer)
+	if appConfig().InstanceName != "" {
+		logger = logger.With("instance_name", appConfig().InstanceName)
+	}
 	slog.SetDefault(logger)
+
+	if logErr != nil {
+		slog.Warn("Failed to open log file, retrying until it can be opened",
+			"path", appConfig().LogFile, "error", logErr)
+	}
+	if appLogFile != nil {
+		go reopenLogFile()
+	}
+}
+
+// reopenLogFile retries a log file that couldn't be opened and reports when
+// logs reach it again
+func reopenLogFile() {
+	ticker := time.NewTicker(logReopenInterval)
+	defer ticker.Stop()
+
+	degraded := appLogFile.Err() != nil
+	for range ticker.C {
+		appLogFile.Reopen()
+		err := appLogFile.Err()
+		switch {
+		case err == nil && degraded:
+			slog.Info("Log file is writable again", "path", appConfig().LogFile)
+		case err != nil && !degraded:
+			slog.Warn("Log file is not writable, retrying", "path", appConfig().LogFile, "error", err)
+		}
+		degraded = err != nil
+	}
 }
 
 func loadConfig() {
-	configFile := "deploy.config"
+	configFile := deployConfigFile
 	if _, err := os.Stat(configFile); os.IsNotExist(err) {
 		fmt.Fprintf(os.Stderr, "Error: deploy.config file not found\n")
 		fmt.Fprintf(os.Stderr, "Please create a deploy.config file with your application and binary configuration.\n")
@@ -176,26 +354,48 @@ func loadConfig() {
 		os.Exit(1)
 	}
 
-	// Load configuration using the config package
-	deployConfig, err := config.LoadDeployConfig(configFile)
+	deployConfig, err := loadDeployConfigFile(configFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading deploy.config: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Validate required fields
-	if err := config.ValidateConfig(deployConfig); err != nil {
-		fmt.Fprintf(os.Stderr, "Configuration validation failed: %v\n", err)
-		os.Exit(1)
-	}
-
 	// Show warnings for any default values being used
 	warnings := config.GetDefaultWarnings(deployConfig)
 	for _, warning := range warnings {
 		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
 	}
 
-	appConfig = deployConfig
+	if deployConfig.LogFile == "" {
+		deployConfig.LogFile = "./binaryDeploy.log"
+	}
+	currentConfig.Store(deployConfig)
+}
+
+// loadDeployConfigFile reads and validates a deploy.config, applying the
+// supervisor's settings when running as a tenant
+func loadDeployConfigFile(path string) (*config.DeployConfig, error) {
+	deployConfig, err := config.LoadDeployConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyTenantOverrides(deployConfig); err != nil {
+		return nil, fmt.Errorf("applying tenant settings: %w", err)
+	}
+	if managedAppName != "" {
+		if err := applyAppOverrides(deployConfig, managedAppName, os.Getenv(appListenEnv)); err != nil {
+			return nil, fmt.Errorf("applying app settings: %w", err)
+		}
+	}
+	if ports := os.Getenv(allocatedPortsEnv); ports != "" {
+		if err := applyAllocatedPorts(deployConfig, ports); err != nil {
+			return nil, fmt.Errorf("%s: %w", allocatedPortsEnv, err)
+		}
+	}
+	if err := config.ValidateConfig(deployConfig); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+	return deployConfig, nil
 }
 
 func logsOnlyHandler(w http.ResponseWriter, r *http.Request) {
@@ -501,7 +701,7 @@ func logsOnlyHandler(w http.ResponseWriter, r *http.Request) {
                 <span>🗑️</span>
                 <span>Clear</span>
             </button>
-            <a href="/monitor" class="btn" target="_blank">
+            <a href="monitor" class="btn" target="_blank">
                 <span>🔙</span>
                 <span>Dashboard</span>
             </a>
@@ -529,7 +729,8 @@ func logsOnlyHandler(w http.ResponseWriter, r *http.Request) {
             statusElement.textContent = '🟡 Connecting...';
             statusElement.className = 'log-status connecting';
 
-            eventSource = new EventSource('/logs');
+            // Pass ?correlation_id=... through to follow a single flow
+            eventSource = new EventSource('logs' + window.location.search);
             
             eventSource.onopen = function() {
                 statusElement.textContent = '🟢 Connected';
@@ -640,86 +841,122 @@ func setupRoutes() http.Handler {
 	mux := http.NewServeMux()
 
 	// Convert comma-separated branches to array for monitor
-	allowedBranches := strings.Split(appConfig.AllowedBranches, ",")
+	allowedBranches := strings.Split(appConfig().AllowedBranches, ",")
 	for i, branch := range allowedBranches {
 		allowedBranches[i] = strings.TrimSpace(branch)
 	}
 
 	// Create monitor handler with server config
 	serverConfig := &monitor.ServerConfig{
-		Port:              appConfig.Port,
-		Secret:            appConfig.Secret,
-		TargetRepoURL:     appConfig.TargetRepoURL,
-		SelfUpdateRepoURL: appConfig.SelfUpdateRepoURL,
-		DeployDir:         appConfig.DeployDir,
-		SelfUpdateDir:     appConfig.SelfUpdateDir,
+		Port:              appConfig().Port,
+		Secret:            appConfig().Secret,
+		TargetRepoURL:     appConfig().TargetRepoURL,
+		SelfUpdateRepoURL: appConfig().SelfUpdateRepoURL,
+		DeployDir:         appConfig().DeployDir,
+		SelfUpdateDir:     appConfig().SelfUpdateDir,
 		AllowedBranches:   allowedBranches,
-		LogFile:           appConfig.LogFile,
+		LogFile:           appConfig().LogFile,
+		DisabledEndpoints: appConfig().DisabledEndpoints,
+		Tenant:            tenantName,
+		InstanceName:      appConfig().InstanceName,
+		Login:             appConfig().RequireAuth,
 	}
 
 	monitorHandler := monitor.NewHandler(processManager, serverConfig)
+	if dependencyMonitor != nil {
+		monitorHandler.SetDependencies(dependencyMonitor)
+	}
+	if appLogFile != nil {
+		monitorHandler.SetLogFile(appLogFile)
+	}
+	if !appConfig().Limits.IsZero() {
+		monitorHandler.SetLimits(limitsStatus{})
+	}
+	monitorHandler.SetReadOnly(readOnlyStatus{})
 	monitorHandler.RegisterRoutes(mux)
 
-	mux.HandleFunc("/webhook", webhookHandler)
+	// Status of this server and its peer_urls, for one view of the fleet
+	mux.HandleFunc("/cluster/status", clusterStatusHandler(monitorHandler))
 
-	// Manual deployment endpoint for testing
-	mux.HandleFunc("/deploy", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
-			w.Header().Set("Content-Type", "application/json")
-			if err := deployTargetRepo(appConfig.TargetRepoURL); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-			} else {
-				w.WriteHeader(http.StatusOK)
-				json.NewEncoder(w).Encode(map[string]string{"status": "deployment started"})
-			}
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
+	mux.HandleFunc("/login", loginHandler)
+	mux.HandleFunc("/logout", logoutHandler)
+
+	mux.HandleFunc("/webhook", withCorrelationID(webhookHandler))
+	mux.HandleFunc("/webhook/generic", withCorrelationID(genericWebhookHandler))
+	mux.HandleFunc("/chatops", withCorrelationID(chatopsHandler))
+
+	// Per-application management endpoints
+	mux.HandleFunc("/apps/", requireAPIToken(readOnlyDuringSelfUpdate(appsHandler)))
+
+	// Stop, start or restart the target app without redeploying it
+	mux.HandleFunc("/api/process/", unlessDisabled("process", withCorrelationID(requireAPIToken(readOnlyDuringSelfUpdate(processAPIHandler)))))
+
+	// Manual deployment endpoint, optionally for a specific branch or commit
+	mux.HandleFunc("/deploy", unlessDisabled("deploy", withCorrelationID(requireAPIToken(readOnlyDuringSelfUpdate(deployHandler)))))
+
+	// Go runtime profiling (CPU, heap, goroutines) for diagnosing slow
+	// deployments and memory growth
+	mux.HandleFunc("/debug/pprof/", requireAPIToken(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", requireAPIToken(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", requireAPIToken(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", requireAPIToken(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", requireAPIToken(pprof.Trace))
+
+	// Runtime counters: goroutines, GC, SSE clients, queue depth
+	mux.HandleFunc("/debug/vars", requireAPIToken(expvar.Handler().ServeHTTP))
+
+	// Blue-green releases
+	mux.HandleFunc("/releases", requireAPIToken(releasesHandler))
+
+	// Startup diagnostics report
+	mux.HandleFunc("/diagnostics", requireAPIToken(diagnosticsHandler))
+
+	// Deployment history for the dashboard, optionally filtered by tag
+	mux.HandleFunc("/deployments", recentDeploymentsHandler)
+	mux.HandleFunc("/deployments/recent", recentDeploymentsHandler)
+
+	// Deployment history over a date range as CSV or JSON, for compliance
+	// and change-management reporting
+	mux.HandleFunc("/deployments/export", requireAPIToken(deploymentsExportHandler))
+
+	// Monthly availability and deployment report
+	mux.HandleFunc("/reports/uptime", requireAPIToken(uptimeReportHandler))
+	mux.HandleFunc("/config/sync", requireAPIToken(readOnlyDuringSelfUpdate(configSyncHandler)))
+
+	// The resolved configuration, credentials masked
+	mux.HandleFunc("/config", requireAPIToken(effectiveConfigHandler))
+
+	// Deployments, self-updates, process events and errors in one feed
+	mux.HandleFunc("/timeline", requireAPIToken(timelineHandler))
+
+	// Deployment queue inspection and recovery of interrupted jobs
+	mux.HandleFunc("/jobs", requireAPIToken(readOnlyDuringSelfUpdate(jobsHandler)))
+	mux.HandleFunc("/jobs/", requireAPIToken(readOnlyDuringSelfUpdate(jobsHandler)))
 
 	// Force update target app endpoint
-	mux.HandleFunc("/update-target", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/update-target", unlessDisabled("update-target", withCorrelationID(requireAPIToken(readOnlyDuringSelfUpdate(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			// Mark update as starting
-			updateStatus.Lock()
-			updateStatus.target = UpdateStatus{
-				IsRunning: true,
-				StartTime: time.Now(),
-				Message:   "Target app update started",
+			req := DeployRequest{RepoURL: appConfig().TargetRepoURL, Trigger: "Target app update", TriggeredBy: requestSource(r), Tags: []string{history.TagManual}}
+			req.CorrelationID = requestCorrelationID(r)
+			req.OverrideDependencies = dependencyOverride(r)
+			if blocked, status, message := freezeGate(r, req, false); blocked {
+				http.Error(w, message, status)
+				return
 			}
-			updateStatus.Unlock()
+
+			triggerTargetDeployment(req, "Target app update started")
 
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
 			json.NewEncoder(w).Encode(map[string]string{
-				"status":    "Target app update started",
-				"timestamp": time.Now().Format(time.RFC3339),
+				"status":         "Target app update started",
+				"correlation_id": req.CorrelationID,
+				"timestamp":      time.Now().Format(time.RFC3339),
 			})
-
-			// Run deployment asynchronously
-			go func() {
-				if err := deployTargetRepo(appConfig.TargetRepoURL); err != nil {
-					slog.Error("Manual target app update failed", "error", err)
-					updateStatus.Lock()
-					updateStatus.target.IsRunning = false
-					updateStatus.target.Error = err.Error()
-					updateStatus.target.Message = "Target app update failed"
-					updateStatus.target.CompletedAt = time.Now()
-					updateStatus.Unlock()
-				} else {
-					slog.Info("Manual target app update completed successfully")
-					updateStatus.Lock()
-					updateStatus.target.IsRunning = false
-					updateStatus.target.Message = "Target app update completed successfully"
-					updateStatus.target.CompletedAt = time.Now()
-					updateStatus.Unlock()
-				}
-			}()
 		} else {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
-	})
+	})))))
 
 	// Update status endpoint
 	mux.HandleFunc("/update-status", func(w http.ResponseWriter, r *http.Request) {
@@ -738,16 +975,16 @@ func setupRoutes() http.Handler {
 	})
 
 	// Force update self endpoint
-	mux.HandleFunc("/update-self", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/update-self", unlessDisabled("update-self", requireAPIToken(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			// Mark update as starting
-			updateStatus.Lock()
-			updateStatus.self = UpdateStatus{
-				IsRunning: true,
-				StartTime: time.Now(),
-				Message:   "Self update started",
+			if r.URL.Query().Get("dry_run") == "true" {
+				selfUpdatePreviewHandler(w, r)
+				return
+			}
+			if refuseDuringSelfUpdate(w) {
+				return
 			}
-			updateStatus.Unlock()
+			triggerSelfUpdate("Self update", "Self update started", "")
 
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
@@ -755,30 +992,13 @@ func setupRoutes() http.Handler {
 				"status":    "Self update started",
 				"timestamp": time.Now().Format(time.RFC3339),
 			})
-
-			// Run self update asynchronously
-			go func() {
-				if err := deploySelfUpdate(); err != nil {
-					slog.Error("Manual self update failed", "error", err)
-					updateStatus.Lock()
-					updateStatus.self.IsRunning = false
-					updateStatus.self.Error = err.Error()
-					updateStatus.self.Message = "Self update failed"
-					updateStatus.self.CompletedAt = time.Now()
-					updateStatus.Unlock()
-				} else {
-					slog.Info("Manual self update completed successfully")
-					updateStatus.Lock()
-					updateStatus.self.IsRunning = false
-					updateStatus.self.Message = "Self update completed successfully"
-					updateStatus.self.CompletedAt = time.Now()
-					updateStatus.Unlock()
-				}
-			}()
 		} else {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
-	})
+	})))
+
+	// Retained self-update builds, and rolling back to one of them
+	mux.HandleFunc("/update-self/rollback", unlessDisabled("update-self", requireAPIToken(readOnlyDuringSelfUpdate(selfUpdateRollbackHandler))))
 
 	// SSE endpoint for real-time log streaming
 	mux.HandleFunc("/logs", func(w http.ResponseWriter, r *http.Request) {
@@ -796,6 +1016,9 @@ func setupRoutes() http.Handler {
 			return
 		}
 
+		// Optionally follow a single webhook or deployment
+		correlationID := r.URL.Query().Get("correlation_id")
+
 		// Create client channel
 		clientChan := make(chan []byte, 100)
 		globalLogStreamer.AddClient(clientChan)
@@ -803,6 +1026,9 @@ func setupRoutes() http.Handler {
 
 		// Send buffered logs first
 		for _, logEntry := range globalLogStreamer.GetBufferedLogs() {
+			if !logEntryMatches(logEntry, correlationID) {
+				continue
+			}
 			fmt.Fprintf(w, "data: %s\n\n", logEntry)
 			flusher.Flush()
 		}
@@ -811,14 +1037,25 @@ func setupRoutes() http.Handler {
 		for {
 			select {
 			case logEntry := <-clientChan:
+				if !logEntryMatches(logEntry, correlationID) {
+					continue
+				}
 				fmt.Fprintf(w, "data: %s\n\n", logEntry)
 				flusher.Flush()
 			case <-r.Context().Done():
 				return
+			case <-draining:
+				return
 			}
 		}
 	})
 
+	// The application's own stdout/stderr, as server-sent events
+	mux.HandleFunc("/app-logs", requireAPIToken(appLogsStreamHandler))
+
+	// Log file history beyond the live stream's buffer, by entry ID
+	mux.HandleFunc("/logs/history", logHistoryHandler)
+
 	// Logs-only page endpoint
 	mux.HandleFunc("/logs-only", logsOnlyHandler)
 
@@ -834,10 +1071,12 @@ func statusHandler(w http.ResponseWriter, r *http.Request) {
 
 	status := map[string]interface{}{
 		"server": map[string]interface{}{
-			"port":             appConfig.Port,
-			"target_repo":      appConfig.TargetRepoURL,
-			"self_update_repo": appConfig.SelfUpdateRepoURL,
-			"allowed_branches": appConfig.AllowedBranches,
+			"port":               appConfig().Port,
+			"target_repo":        appConfig().TargetRepoURL,
+			"self_update_repo":   appConfig().SelfUpdateRepoURL,
+			"self_update_branch": appConfig().SelfUpdateBranch,
+			"allowed_branches":   appConfig().AllowedBranches,
+			"allowed_tags":       appConfig().AllowedTags,
 		},
 		"process":   processManager.GetWebStatus(),
 		"timestamp": time.Now().Format(time.RFC3339),
@@ -943,7 +1182,7 @@ func monitorHandler(w http.ResponseWriter, r *http.Request) {
 
     <script>
         function loadStatus() {
-            fetch('/status')
+            fetch('status')
                 .then(response => response.json())
                 .then(data => {
                     updateServerInfo(data.server);
@@ -965,7 +1204,11 @@ func monitorHandler(w http.ResponseWriter, r *http.Request) {
         function updateProcessInfo(process) {
             const statusElement = document.getElementById('process-status');
             if (process.running) {
-                statusElement.innerHTML = '<span class="status-indicator status-running"></span><span>Running</span>';
+                let runningLabel = 'Running';
+                if (process.instances_total > 1) {
+                    runningLabel += ' (' + process.instances_running + '/' + process.instances_total + ' instances)';
+                }
+                statusElement.innerHTML = '<span class="status-indicator status-running"></span><span>' + runningLabel + '</span>';
                 document.getElementById('process-pid').textContent = process.pid;
                 document.getElementById('process-uptime').textContent = process.uptime;
                 document.getElementById('restart-count').textContent = process.restart_count;
@@ -1005,40 +1248,44 @@ func monitorHandler(w http.ResponseWriter, r *http.Request) {
 
 func webhookHandler(w http.ResponseWriter, r *http.Request) {
 	// Log incoming request details
-	slog.Info("Incoming webhook request",
+	forge := detectWebhookForge(r)
+	slog.InfoContext(r.Context(), "Incoming webhook request",
 		"method", r.Method,
 		"path", r.URL.Path,
 		"remote_addr", r.RemoteAddr,
 		"user_agent", r.Header.Get("User-Agent"),
 		"content_type", r.Header.Get("Content-Type"),
-		"signature_present", r.Header.Get("X-Hub-Signature-256") != "")
+		"forge", forge.Name,
+		"signature_present", r.Header.Get(forge.SignatureHeader) != "")
 
 	if r.Method != http.MethodPost {
-		slog.Warn("Invalid HTTP method received", "method", r.Method)
+		slog.WarnContext(r.Context(), "Invalid HTTP method received", "method", r.Method)
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	signature := r.Header.Get("X-Hub-Signature-256")
+	signature := r.Header.Get(forge.SignatureHeader)
 	// Only require signature if secret is configured
-	if appConfig.Secret != "" && signature == "" {
+	if appConfig().Secret != "" && signature == "" {
+		recordAuthFailure(r, "missing webhook signature")
 		http.Error(w, "Missing signature", http.StatusUnauthorized)
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
+	// The signature is computed as the body streams in
+	mac := hmac.New(sha256.New, []byte(appConfig().Secret))
+	body, err := readWebhookBody(w, r, maxWebhookPayloadSize, mac)
 	if err != nil {
-		slog.Error("Failed to read request body", "error", err)
-		http.Error(w, "Failed to read body", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to read request body", "error", err)
+		writeWebhookBodyError(w, err)
 		return
 	}
-	defer r.Body.Close()
 
-	slog.Info("Request body read successfully", "body_size", len(body))
+	slog.InfoContext(r.Context(), "Request body read successfully", "body_size", len(body))
 
 	// Validate payload is not empty
 	if len(body) == 0 {
-		slog.Warn("Empty request body received")
+		slog.WarnContext(r.Context(), "Empty request body received")
 		http.Error(w, "Empty request body", http.StatusBadRequest)
 		return
 	}
@@ -1046,147 +1293,174 @@ func webhookHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate JSON structure - reject empty objects
 	trimmedBody := strings.TrimSpace(string(body))
 	if trimmedBody == "{}" {
-		slog.Warn("Empty JSON object received")
+		slog.WarnContext(r.Context(), "Empty JSON object received")
 		http.Error(w, "Invalid JSON payload - empty object", http.StatusBadRequest)
 		return
 	}
 
-	if !verifySignature(body, signature) {
-		slog.Warn("Invalid signature verification",
+	if !verifySignature(mac, forge.SignaturePrefix, signature) {
+		slog.WarnContext(r.Context(), "Invalid signature verification",
 			"received_signature", signature,
 			"body_size", len(body))
+		recordAuthFailure(r, "invalid webhook signature")
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
-	slog.Info("Signature verification successful")
+	slog.InfoContext(r.Context(), "Signature verification successful")
+
+	// Gitea and Gogs send every subscribed event to the same URL
+	event := r.Header.Get(forge.EventHeader)
+	if forge != forgeGitHub && event != "" && event != "push" && event != "release" {
+		slog.InfoContext(r.Context(), "Ignoring non-push event", "forge", forge.Name, "event", event)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Event %s ignored", event)
+		return
+	}
 
 	var payload GitHubPushPayload
 	if err := json.Unmarshal(body, &payload); err != nil {
-		slog.Error("Failed to unmarshal JSON payload", "error", err, "body_preview", string(body[:min(200, len(body))]))
+		slog.ErrorContext(r.Context(), "Failed to unmarshal JSON payload", "error", err, "body_preview", string(body[:min(200, len(body))]))
 		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
 		return
 	}
+	release := event == "release"
+	if release {
+		// A published release deploys its tag like a push of the tag would
+		if payload.Action != "published" {
+			slog.InfoContext(r.Context(), "Ignoring release event", "action", payload.Action)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "Release %s ignored", payload.Action)
+			return
+		}
+		if payload.Release.TagName == "" {
+			slog.WarnContext(r.Context(), "Missing tag name in release payload")
+			http.Error(w, "Invalid payload - missing release tag", http.StatusBadRequest)
+			return
+		}
+		payload.Ref = "refs/tags/" + payload.Release.TagName
+	} else {
+		normalizePushPayload(&payload)
+	}
 
 	// Validate required GitHub webhook fields
 	if payload.Repository.Name == "" {
-		slog.Warn("Missing repository name in payload")
+		slog.WarnContext(r.Context(), "Missing repository name in payload")
 		http.Error(w, "Invalid payload - missing repository name", http.StatusBadRequest)
 		return
 	}
 	if payload.Ref == "" {
-		slog.Warn("Missing ref in payload")
+		slog.WarnContext(r.Context(), "Missing ref in payload")
 		http.Error(w, "Invalid payload - missing ref", http.StatusBadRequest)
 		return
 	}
-	if payload.HeadCommit.ID == "" {
-		slog.Warn("Missing commit ID in payload")
+	if !release && payload.HeadCommit.ID == "" && isZeroCommit(payload.After) {
+		slog.InfoContext(r.Context(), "Ignoring push that deleted a ref", "ref", payload.Ref)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Ref %s was deleted, nothing to deploy", payload.Ref)
+		return
+	}
+	if !release && payload.HeadCommit.ID == "" {
+		slog.WarnContext(r.Context(), "Missing commit ID in payload")
 		http.Error(w, "Invalid payload - missing commit ID", http.StatusBadRequest)
 		return
 	}
 
-	slog.Info("Payload parsed successfully",
+	slog.InfoContext(r.Context(), "Payload parsed successfully",
 		"repository", payload.Repository.Name,
 		"ref", payload.Ref,
 		"branch", extractBranchFromRef(payload.Ref),
 		"commit_id", payload.HeadCommit.ID[:min(8, len(payload.HeadCommit.ID))])
 
+	// Self-updates follow self_update_branch and self_update_tags rather
+	// than the target's allowed_branches
+	if payload.Repository.URL == appConfig().SelfUpdateRepoURL {
+		ref, ok := selfUpdateRef(payload.Ref)
+		if !ok {
+			slog.InfoContext(r.Context(), "Ref does not trigger self-updates", "ref", payload.Ref, "self_update_branch", appConfig().SelfUpdateBranch)
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "Ref %s is not configured for self-updates", payload.Ref)
+			return
+		}
+		triggerSelfUpdate("Webhook self-update", fmt.Sprintf("Webhook self-update triggered for %s (%s)", payload.Repository.Name, ref), ref)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Self-update deployment triggered for %s", payload.Repository.Name)
+		return
+	}
+
+	if configRepoPushHandled(w, r, payload.Repository.URL, payload.Ref) {
+		return
+	}
+
 	branch := extractBranchFromRef(payload.Ref)
-	if !isAllowedBranch(branch) {
-		slog.Info("Branch not in allowed branches", "branch", branch)
+	tag, isTag := strings.CutPrefix(payload.Ref, "refs/tags/")
+	if isTag && !isAllowedTag(tag) {
+		slog.InfoContext(r.Context(), "Tag not in allowed tags", "tag", tag)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Tag %s is not configured for auto-deployment", tag)
+		return
+	}
+	if !isTag && !isAllowedBranch(branch) {
+		slog.InfoContext(r.Context(), "Branch not in allowed branches", "branch", branch)
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "Branch %s is not configured for auto-deployment", branch)
 		return
 	}
 
-	slog.Info("Received push event", "branch", branch, "repository", payload.Repository.Name)
+	slog.InfoContext(r.Context(), "Received push event", "ref", payload.Ref, "repository", payload.Repository.Name, "release", release)
 
-	// Check if this is a self-update deployment
-	if payload.Repository.URL == appConfig.SelfUpdateRepoURL {
-		// Mark self-update as starting
-		updateStatus.Lock()
-		updateStatus.self = UpdateStatus{
-			IsRunning: true,
-			StartTime: time.Now(),
-			Message:   fmt.Sprintf("Webhook self-update triggered for %s", payload.Repository.Name),
-		}
-		updateStatus.Unlock()
-
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Self-update deployment triggered for %s", payload.Repository.Name)
-		go func() {
-			if err := deploySelfUpdate(); err != nil {
-				slog.Error("Self-update deployment failed", "error", err)
-				updateStatus.Lock()
-				updateStatus.self.IsRunning = false
-				updateStatus.self.Error = err.Error()
-				updateStatus.self.Message = "Webhook self-update failed"
-				updateStatus.self.CompletedAt = time.Now()
-				updateStatus.Unlock()
-			} else {
-				slog.Info("Self-update deployment completed successfully")
-				updateStatus.Lock()
-				updateStatus.self.IsRunning = false
-				updateStatus.self.Message = "Webhook self-update completed successfully"
-				updateStatus.self.CompletedAt = time.Now()
-				updateStatus.Unlock()
-			}
-		}()
-	} else {
-		// Mark target update as starting
-		updateStatus.Lock()
-		updateStatus.target = UpdateStatus{
-			IsRunning: true,
-			StartTime: time.Now(),
-			Message:   fmt.Sprintf("Webhook deployment triggered for %s", payload.Repository.Name),
-		}
-		updateStatus.Unlock()
+	req := DeployRequest{RepoURL: payload.Repository.URL, Trigger: "Webhook deployment", TriggeredBy: payload.Pusher.Name, Tags: []string{history.TagWebhook}}
+	if isTag {
+		req.GitTag = tag
+	}
+	if req.TriggeredBy == "" {
+		req.TriggeredBy = payload.Sender.Login
+	}
+	req.OverrideDependencies = dependencyOverride(r)
+	req.CorrelationID = requestCorrelationID(r)
+	req.Issues = pushIssueKeys(payload)
+	if blocked, status, message := freezeGate(r, req, true); blocked {
+		w.WriteHeader(status)
+		fmt.Fprint(w, message)
+		return
+	}
 
-		// Deploy any repository (repo-agnostic approach)
+	if req.GitTag != "" && !claimTagDeploy(req.GitTag) {
+		slog.InfoContext(r.Context(), "Tag was just deployed", "tag", req.GitTag)
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Deployment triggered for %s", payload.Repository.Name)
-		go func() {
-			if err := deployTargetRepo(payload.Repository.URL); err != nil {
-				slog.Error("Target deployment failed", "error", err)
-				updateStatus.Lock()
-				updateStatus.target.IsRunning = false
-				updateStatus.target.Error = err.Error()
-				updateStatus.target.Message = "Webhook deployment failed"
-				updateStatus.target.CompletedAt = time.Now()
-				updateStatus.Unlock()
-			} else {
-				slog.Info("Target deployment completed successfully")
-				updateStatus.Lock()
-				updateStatus.target.IsRunning = false
-				updateStatus.target.Message = "Webhook deployment completed successfully"
-				updateStatus.target.CompletedAt = time.Now()
-				updateStatus.Unlock()
-			}
-		}()
+		fmt.Fprintf(w, "Tag %s was already deployed", req.GitTag)
+		return
 	}
+
+	// Deploy any repository (repo-agnostic approach)
+	triggerTargetDeployment(req, fmt.Sprintf("Webhook deployment triggered for %s", payload.Repository.Name))
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Deployment triggered for %s", payload.Repository.Name)
 }
 
-func verifySignature(body []byte, signature string) bool {
-	if appConfig.Secret == "" {
+// verifySignature checks signature against mac, the HMAC of the body keyed
+// with the webhook secret, formatted as prefix followed by the hex digest
+func verifySignature(mac hash.Hash, prefix, signature string) bool {
+	if appConfig().Secret == "" {
 		return true
 	}
 
-	expectedSig := "sha256=" + computeHMAC(body, appConfig.Secret)
+	expectedSig := prefix + hex.EncodeToString(mac.Sum(nil))
 	return hmac.Equal([]byte(signature), []byte(expectedSig))
 }
 
-func computeHMAC(data []byte, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(data)
-	return hex.EncodeToString(h.Sum(nil))
-}
-
 func extractBranchFromRef(ref string) string {
 	return strings.TrimPrefix(ref, "refs/heads/")
 }
 
 func isAllowedBranch(branch string) bool {
-	allowedBranches := strings.Split(appConfig.AllowedBranches, ",")
+	return branchMatches(appConfig().AllowedBranches, branch)
+}
+
+// branchMatches reports whether branch is in allowed, a comma-separated
+// allowed_branches list; an empty list allows every branch
+func branchMatches(allowed, branch string) bool {
+	allowedBranches := strings.Split(allowed, ",")
 	if len(allowedBranches) == 0 || (len(allowedBranches) == 1 && allowedBranches[0] == "") {
 		return true
 	}
@@ -1205,69 +1479,427 @@ func isAllowedBranch(branch string) bool {
 	return false
 }
 
-func deployTargetRepo(repoURL string) error {
-	slog.Info("Starting deployment process", "repo_url", repoURL)
+// DeployRequest describes a specific revision of the target app to deploy
+type DeployRequest struct {
+	RepoURL     string `json:"repo"`
+	Branch      string `json:"branch,omitempty"`
+	Commit      string `json:"commit,omitempty"`
+	Environment string `json:"environment,omitempty"`
+
+	// GitTag deploys the commit a tag points to, e.g. a release
+	GitTag string `json:"git_tag,omitempty"`
+
+	// Restart-only deployments reuse the current checkout and/or build
+	SkipFetch bool `json:"skip_fetch,omitempty"`
+	SkipBuild bool `json:"skip_build,omitempty"`
+
+	// PreviousRelease switches traffic back to the blue-green release that
+	// was live before the current one, without fetching or building
+	PreviousRelease bool `json:"previous_release,omitempty"`
+
+	// Tags label the deployment in history, e.g. hotfix or rollback
+	Tags []string `json:"tags,omitempty"`
+
+	// Issues are tracker keys from the pushed commits; when empty they are
+	// read from the commits deployed since the last successful deployment
+	Issues []string `json:"issues,omitempty"`
+
+	Trigger     string   `json:"-"` // What started the deployment, for history
+	TriggeredBy string   `json:"-"` // Who started it: pusher, client address or upstream app
+	Chain       []string `json:"-"` // Upstream apps that chained into this deployment
+
+	// Deploy even while a critical dependency is unhealthy
+	OverrideDependencies bool `json:"-"`
+
+	// CorrelationID ties the logs, history record and notifications of the
+	// deployment to the request that started it
+	CorrelationID string `json:"-"`
+
+	// Artifact is a build dropped into incoming_dir, deployed instead of
+	// fetching and building a commit
+	Artifact string `json:"-"`
+}
+
+// IsRestart reports whether req only restarts the current build
+func (req DeployRequest) IsRestart() bool {
+	return req.SkipFetch && req.SkipBuild
+}
+
+// deployTarget fetches, builds and starts the revision described by req
+func deployTarget(req DeployRequest) (err error) {
+	repoURL := req.RepoURL
+	slog.Info("Starting deployment process", "repo_url", repoURL, "branch", req.Branch, "commit", req.Commit)
+
+	// Run chained deployments and record the outcome once this one finishes
+	startedAt := time.Now()
+	profile := newDeployProfile()
+	buildOutput := newTailBuffer(maxBuildOutput)
+	var commit string
+	defer func() {
+		finishDeployment(req, commit, startedAt, profile, buildOutput.Bytes(), err)
+	}()
 
-	if err := os.MkdirAll(appConfig.DeployDir, 0755); err != nil {
+	if err := os.MkdirAll(appConfig().DeployDir, 0755); err != nil {
 		return fmt.Errorf("failed to create deploy directory: %w", err)
 	}
 
-	repoDir := filepath.Join(appConfig.DeployDir, "repo")
+	// Don't deploy, e.g. run migrations, while a critical dependency is down
+	if dependencyGateEnabled() {
+		if err := profile.step("dependencies", func() error { return dependencyGate(&req) }); err != nil {
+			return err
+		}
+	}
 
-	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
-		slog.Info("Cloning repository", "path", repoDir)
-		if err := runCommandInDir("", "git", "clone", repoURL, repoDir); err != nil {
-			return fmt.Errorf("failed to clone repository: %w", err)
+	// Only one deployment touching e.g. a shared database runs at a time
+	if len(appConfig().SharedResources) > 0 {
+		var release func()
+		if err := profile.step("resources", func() (err error) {
+			release, err = lockSharedResources(req)
+			return err
+		}); err != nil {
+			return err
 		}
-	} else {
-		slog.Info("Updating repository", "path", repoDir)
-		if err := runCommandInDir(repoDir, "git", "fetch", "origin"); err != nil {
-			return fmt.Errorf("failed to fetch updates: %w", err)
+		defer release()
+	}
+
+	if req.PreviousRelease {
+		req.Tags = append(req.Tags, "rollback")
+		setRunningBuild("")
+		commit, err = rollbackBlueGreen(profile)
+		return err
+	}
+
+	repoDir := targetRepoDir()
+
+	if req.Artifact != "" {
+		// The checkout, if any, stays as it is; the artifact is the build
+		if err := profile.step("unpack", func() error { return unpackDroppedArtifact(req.Artifact) }); err != nil {
+			return err
 		}
-		if err := runCommandInDir(repoDir, "git", "reset", "--hard", "origin/HEAD"); err != nil {
-			return fmt.Errorf("failed to reset repository: %w", err)
+	} else if req.SkipFetch {
+		if _, err := os.Stat(repoDir); err != nil {
+			return fmt.Errorf("cannot skip fetch, no existing checkout at %s", repoDir)
+		}
+		slog.Info("Skipping fetch, using existing checkout", "path", repoDir)
+	} else if err := profile.step("fetch", func() error { return checkoutTargetRevision(req, repoDir) }); err != nil {
+		return err
+	}
+
+	// Report the deployment outcome on the commit being deployed; restarts
+	// don't deploy anything new so they leave commit statuses alone, and an
+	// artifact's commit isn't known
+	if req.Artifact == "" {
+		commit = gitHeadCommit(repoDir)
+	}
+	if !req.IsRestart() {
+		notifyDeploymentStarted(req, commit)
+	}
+	if len(req.Issues) == 0 && !req.IsRestart() && commit != "" {
+		req.Issues = deployedIssueKeys(repoDir, commit)
+	}
+	if !req.IsRestart() && commit != "" {
+		// A check run also shows developers the build output
+		if check := startDeploymentCheck(repoURL, commit, buildOutput); check != nil {
+			defer func() { check.finish(err) }()
+		} else {
+			reportCommitStatus(repoURL, commit, github.StatePending, "Deployment in progress")
+			defer func() {
+				if err != nil {
+					reportCommitStatus(repoURL, commit, github.StateFailure, err.Error())
+				} else {
+					reportCommitStatus(repoURL, commit, github.StateSuccess, "Deployed successfully")
+				}
+			}()
 		}
 	}
 
 	// Use deploy config from main configuration (not from cloned repo)
-	deployConfig := appConfig
+	deployConfig := appConfig()
+
+	// Inject per-host configuration before the build so it can be embedded
+	if len(deployConfig.ConfigTemplates) > 0 {
+		if err := profile.step("render", func() error { return renderConfigTemplates(req, commit, targetWorkingDir()) }); err != nil {
+			return err
+		}
+	}
+
+	// Reuse the stored build of this commit if it was built before
+	restored := false
+	if !req.SkipBuild && req.Artifact == "" && artifactStore != nil {
+		profile.step("restore", func() error {
+			restored = restoreArtifacts(commit)
+			return nil
+		})
+	}
 
 	// Run build command
-	if deployConfig.BuildCommand != "" {
-		slog.Info("Running build command", "command", deployConfig.BuildCommand)
-		if err := runShellCommandInDir(repoDir, deployConfig.BuildCommand); err != nil {
+	if req.SkipBuild {
+		slog.Info("Skipping build, restarting the current build")
+	} else if req.Artifact != "" {
+		slog.Info("Skipping build, using dropped artifact", "artifact", filepath.Base(req.Artifact))
+	} else if restored {
+		slog.Info("Skipping build, using stored artifacts", "commit", commit)
+	} else if deployConfig.BuildCommand != "" {
+		command, err := buildCommand(templateData(req.Branch, commit, targetWorkingDir()))
+		if err != nil {
+			return err
+		}
+		slog.Info("Running build command", "command", command)
+		err = profile.step("build", func() error {
+			return runBuildCommand(repoDir, command, buildOutput)
+		})
+		if err != nil {
 			return fmt.Errorf("build failed: %w", err)
 		}
+		profile.step("store", func() error {
+			saveArtifacts(commit)
+			return nil
+		})
+	}
+
+	// Leave the application running when the build is byte-for-byte what it
+	// already runs, e.g. after a docs-only commit
+	if deployConfig.SkipUnchangedBuilds && !req.IsRestart() {
+		var digest string
+		profile.step("compare", func() error {
+			digest = buildDigest()
+			return nil
+		})
+		if buildUnchanged(digest) {
+			slog.Info("Build output unchanged, leaving the application running", "digest", digest[:12], "commit", commit)
+			req.Tags = append(req.Tags, history.TagUnchangedBuild)
+			return nil
+		}
+		defer func() {
+			if err != nil {
+				digest = ""
+			}
+			setRunningBuild(digest)
+		}()
 	}
 
 	// Start the process using the process manager
-	workingDir := repoDir
-	if deployConfig.WorkingDir != "" {
-		workingDir = filepath.Join(repoDir, deployConfig.WorkingDir)
+	workingDir := targetWorkingDir()
+
+	switch deployConfig.DeployDriver {
+	case "ssh":
+		return deployToRemoteHosts(profile, workingDir)
+	case "sftp":
+		return publishToSFTPHosts(profile, filepath.Join(workingDir, deployConfig.PublishDir))
+	}
+
+	if blueGreenEnabled() {
+		return deployBlueGreen(profile, req, commit)
+	}
+
+	// Run a versioned copy of the build that a failure can roll back from;
+	// a restart reruns the current copy
+	var build *deployedBuild
+	if deployConfig.KeepBuilds > 0 {
+		if current, ok := currentBuild(); ok && req.IsRestart() {
+			workingDir = buildWorkingDir(current.ID)
+		} else if !req.IsRestart() {
+			var id string
+			if err := profile.step("stage", func() (err error) {
+				id, err = stageBuild(commit)
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to stage build: %w", err)
+			}
+			build = &deployedBuild{ID: id, Commit: commit, Branch: req.Branch}
+			workingDir = buildWorkingDir(id)
+		}
+	}
+	rollback := func(cause error) error {
+		if build == nil {
+			return cause
+		}
+		err := rollbackBuild(profile, deployConfig, cause)
+		if errors.Is(err, errRolledBack) {
+			req.Tags = append(req.Tags, history.TagRolledBack)
+		}
+		return err
+	}
+
+	// Stop the old version up front when an external system needs a window
+	// between stop and start (e.g. draining a load balancer)
+	if pauseEnabled() {
+		err := profile.step("stop", processManager.StopCurrentProcess)
+		if err != nil {
+			return fmt.Errorf("failed to stop application process: %w", err)
+		}
+		// The old version stays stopped when the new one isn't confirmed
+		if err := profile.step("pause", func() error { return pauseBeforeStart(repoURL) }); err != nil {
+			return err
+		}
 	}
 
 	slog.Info("Starting application process", "command", deployConfig.RunCommand, "working_dir", workingDir)
-	if err := processManager.StartProcess(deployConfig, workingDir); err != nil {
-		return fmt.Errorf("failed to start application process: %w", err)
+	started := time.Now()
+	if err := startInstances(profile, deployConfig, workingDir, templateData(req.Branch, commit, workingDir)); err != nil {
+		return rollback(err)
+	}
+
+	profile.step("warmup", func() error {
+		warmUpApp()
+		return nil
+	})
+
+	if build != nil {
+		if err := profile.step("watch", func() error { return watchNewBuild(started) }); err != nil {
+			return rollback(err)
+		}
+		markBuildDeployed(*build)
 	}
 
 	return nil
 }
 
-func deploySelfUpdate() error {
-	slog.Info("Starting self-update process")
+// checkoutTargetRevision clones or fetches the target repository into repoDir
+// and checks out the revision requested by req
+func checkoutTargetRevision(req DeployRequest, repoDir string) error {
+	repoURL := req.RepoURL
+	repoMu.Lock()
+	defer repoMu.Unlock()
+
+	// With a shared mirror the checkout clones and fetches from it, borrowing
+	// its objects, instead of going to the remote
+	mirror := ""
+	if appConfig().GitMirrorDir != "" {
+		var err error
+		if mirror, err = updateMirror(repoURL); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
+		slog.Info("Cloning repository", "path", repoDir)
+		args := gitCommand(repoURL, "clone", repoURL, repoDir)
+		if mirror != "" {
+			args = []string{"clone", "--shared", mirror, repoDir}
+		}
+		if err := runCommandInDir("", "git", args...); err != nil {
+			return fmt.Errorf("failed to clone repository: %w", err)
+		}
+	} else {
+		if mirror != "" {
+			if err := useMirror(repoDir, mirror); err != nil {
+				return fmt.Errorf("failed to switch to shared mirror: %w", err)
+			}
+		} else if err := leaveMirror(repoDir, repoURL); err != nil {
+			return fmt.Errorf("failed to detach from shared mirror: %w", err)
+		}
+		slog.Info("Updating repository", "path", repoDir)
+		if err := runCommandInDir(repoDir, "git", gitCommand(repoURL, "fetch", "origin")...); err != nil {
+			return fmt.Errorf("failed to fetch updates: %w", err)
+		}
+	}
+
+	// Check out the requested revision, defaulting to the remote's default branch
+	target := "origin/HEAD"
+	if req.Commit != "" {
+		if err := checkCommitOnAllowedBranch(repoDir, req.Commit, req.Branch); err != nil {
+			return err
+		}
+		target = req.Commit
+	} else if req.GitTag != "" {
+		// --force picks up a tag that was moved since the last fetch
+		tagRef := "refs/tags/" + req.GitTag
+		if err := runCommandInDir(repoDir, "git", gitCommand(repoURL, "fetch", "--force", "origin", tagRef+":"+tagRef)...); err != nil {
+			return fmt.Errorf("failed to fetch tag %s: %w", req.GitTag, err)
+		}
+		target = tagRef
+	} else if req.Branch != "" {
+		target = "origin/" + req.Branch
+	}
+	if err := runCommandInDir(repoDir, "git", "reset", "--hard", target); err != nil {
+		return fmt.Errorf("failed to check out %s: %w", target, err)
+	}
+
+	return nil
+}
+
+// checkCommitOnAllowedBranch refuses a commit that isn't on branch, or on a
+// branch in allowed_branches when no branch was given, so a bare commit SHA
+// can't deploy what only a disallowed branch contains
+func checkCommitOnAllowedBranch(repoDir, commit, branch string) error {
+	out, err := exec.Command("git", "-C", repoDir, "branch", "-r", "--contains", commit, "--format=%(refname:short)").Output()
+	if err != nil {
+		return fmt.Errorf("commit %s not found in the fetched branches", commit)
+	}
+	for _, ref := range strings.Fields(string(out)) {
+		name, ok := strings.CutPrefix(ref, "origin/")
+		if !ok || name == "HEAD" {
+			continue
+		}
+		if name == branch || (branch == "" && isAllowedBranch(name)) {
+			return nil
+		}
+	}
+	if branch != "" {
+		return fmt.Errorf("commit %s is not on branch %s", commit, branch)
+	}
+	return fmt.Errorf("commit %s is not on any branch in allowed_branches", commit)
+}
+
+// targetRepoDir returns the directory the target repository is checked out in
+func targetRepoDir() string {
+	return filepath.Join(appConfig().DeployDir, "repo")
+}
+
+// targetWorkingDir returns the directory the target application runs in
+func targetWorkingDir() string {
+	repoDir := targetRepoDir()
+	if appConfig().WorkingDir != "" {
+		return filepath.Join(repoDir, appConfig().WorkingDir)
+	}
+	return repoDir
+}
+
+// deploySelfUpdate rebuilds binaryDeploy from ref of self_update_repo_url: a
+// branch, or refs/tags/<tag>. It returns the commit it fetched and its
+// subject line, empty when fetching failed.
+func deploySelfUpdate(ref string) (commit, message string, err error) {
+	slog.Info("Starting self-update process", "ref", ref)
 
 	// Get current binary path
 	currentBinary, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("getting current binary path: %w", err)
+		return "", "", fmt.Errorf("getting current binary path: %w", err)
 	}
 
 	// Create self-updater
-	updaterInstance := updater.NewSelfUpdater(currentBinary, appConfig.SelfUpdateDir)
+	updaterInstance := updater.NewSelfUpdater(currentBinary, appConfig().SelfUpdateDir)
+	updaterInstance.GitConfigArgs = gitConfigArgs(appConfig().SelfUpdateRepoURL)
+	updaterInstance.Keep = appConfig().SelfUpdateKeep
+	updaterInstance.CurrentCommit = buildRevision()
+	updaterInstance.Ref = ref
 
 	// Perform self-update
-	return updaterInstance.Update(appConfig.SelfUpdateRepoURL, "main")
+	err = updaterInstance.Update(appConfig().SelfUpdateRepoURL, ref)
+	return updaterInstance.Commit, updaterInstance.CommitMessage, err
+}
+
+// previewSelfUpdate builds ref of self_update_repo_url without installing
+// it, reporting its version and the commits since the running binary
+func previewSelfUpdate(ref string) (*updater.Preview, error) {
+	currentBinary, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("getting current binary path: %w", err)
+	}
+	updaterInstance := updater.NewSelfUpdater(currentBinary, appConfig().SelfUpdateDir)
+	updaterInstance.GitConfigArgs = gitConfigArgs(appConfig().SelfUpdateRepoURL)
+	return updaterInstance.Preview(appConfig().SelfUpdateRepoURL, ref, buildRevision())
+}
+
+// cleanSelfUpdateLeftovers removes what a self-update interrupted by a crash
+// left behind
+func cleanSelfUpdateLeftovers() {
+	currentBinary, err := os.Executable()
+	if err != nil {
+		return
+	}
+	updater.NewSelfUpdater(currentBinary, appConfig().SelfUpdateDir).RemoveLeftovers()
 }
 
 func runCommand(dir, command string, args ...string) error {
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"binaryDeploy/history"
+)
+
+var (
+	// branchNamePattern is a conservative subset of git's ref name rules
+	branchNamePattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+	commitPattern     = regexp.MustCompile(`^[0-9a-fA-F]{7,40}$`)
+	tagPattern        = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,31}$`)
+)
+
+// maxDeployTags bounds how many tags one deployment may carry
+const maxDeployTags = 10
+
+// deployHandler handles POST /deploy. Without a body it redeploys the
+// default branch of the target repo; with a JSON DeployRequest body it
+// deploys the requested repo, branch or commit. skip_fetch and skip_build
+// together only restart the current build.
+func deployHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	req := DeployRequest{RepoURL: appConfig().TargetRepoURL}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to read body"})
+		return
+	}
+	if len(strings.TrimSpace(string(body))) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid JSON payload"})
+			return
+		}
+	}
+
+	// Restart-only flags may also be given as query parameters
+	query := r.URL.Query()
+	if query.Get("skip_fetch") == "true" {
+		req.SkipFetch = true
+	}
+	if query.Get("skip_build") == "true" {
+		req.SkipBuild = true
+	}
+	req.Tags = append(req.Tags, query["tag"]...)
+	req.OverrideDependencies = dependencyOverride(r)
+	req.CorrelationID = requestCorrelationID(r)
+
+	if err := validateDeployRequest(&req); err != nil {
+		slog.WarnContext(r.Context(), "Rejected manual deploy request", "error", err, "remote_addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	req.Trigger = "Manual deployment"
+	if req.IsRestart() {
+		req.Trigger = "Manual restart"
+	} else if req.PreviousRelease {
+		req.Trigger = "Manual rollback"
+	}
+	req.Chain = parseDeployChain(r)
+	for _, app := range req.Chain {
+		if app == appConfig().AppName {
+			slog.WarnContext(r.Context(), "Rejected chained deployment cycle", "chain", strings.Join(req.Chain, ","))
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "deployment chain cycle: " + appConfig().AppName + " is already upstream"})
+			return
+		}
+	}
+	req.TriggeredBy = requestSource(r)
+	req.Tags = append(req.Tags, history.TagManual)
+	if len(req.Chain) > 0 {
+		req.Trigger = "Chained deployment"
+		req.TriggeredBy = req.Chain[len(req.Chain)-1]
+		req.Tags[len(req.Tags)-1] = history.TagChained
+	}
+
+	// A simulation builds and starts the app in a scratch directory; it
+	// doesn't deploy anything, so freezes and the queue don't apply
+	if query.Get("simulate") == "true" {
+		if req.SkipFetch || req.SkipBuild {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "simulate cannot be combined with skip_fetch or skip_build"})
+			return
+		}
+		slog.InfoContext(r.Context(), "Simulated deployment requested", "branch", req.Branch, "commit", req.Commit, "remote_addr", r.RemoteAddr)
+		result := simulateDeployment(req)
+		if !result.Passed {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
+	if blocked, status, message := freezeGate(r, req, false); blocked {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": message})
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Manual deployment requested",
+		"repo_url", req.RepoURL,
+		"branch", req.Branch,
+		"commit", req.Commit,
+		"git_tag", req.GitTag,
+		"skip_fetch", req.SkipFetch,
+		"skip_build", req.SkipBuild,
+		"environment", req.Environment,
+		"tags", strings.Join(req.Tags, ","),
+		"chain", strings.Join(req.Chain, ","),
+		"remote_addr", r.RemoteAddr)
+
+	if err := runDeployment(req); err != nil {
+		if errors.Is(err, errDependenciesUnhealthy) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else if errors.Is(err, errLimitExceeded) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		} else if errors.Is(err, errResourceBusy) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error(), "correlation_id": req.CorrelationID})
+		return
+	}
+
+	status, commit := "deployment started", gitHeadCommit(targetRepoDir())
+	if req.IsRestart() {
+		status = "restarted"
+	} else if req.PreviousRelease {
+		status, commit = "rolled back", liveReleaseCommit()
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":         status,
+		"repo":           req.RepoURL,
+		"branch":         req.Branch,
+		"commit":         commit,
+		"correlation_id": req.CorrelationID,
+	})
+}
+
+// validateDeployRequest fills in defaults and checks the request against the
+// configured application
+func validateDeployRequest(req *DeployRequest) error {
+	if req.RepoURL == "" {
+		req.RepoURL = appConfig().TargetRepoURL
+	}
+	if req.RepoURL != appConfig().TargetRepoURL {
+		return fmt.Errorf("repo %q is not a configured application", req.RepoURL)
+	}
+
+	if req.Branch != "" {
+		if !branchNamePattern.MatchString(req.Branch) || strings.HasPrefix(req.Branch, "-") ||
+			strings.Contains(req.Branch, "..") {
+			return fmt.Errorf("invalid branch name %q", req.Branch)
+		}
+		if !isAllowedBranch(req.Branch) {
+			return fmt.Errorf("branch %q is not in allowed_branches", req.Branch)
+		}
+	}
+
+	if req.Commit != "" && !commitPattern.MatchString(req.Commit) {
+		return fmt.Errorf("invalid commit %q: expected a 7-40 character hex SHA", req.Commit)
+	}
+
+	if req.GitTag != "" {
+		if !branchNamePattern.MatchString(req.GitTag) || strings.HasPrefix(req.GitTag, "-") ||
+			strings.Contains(req.GitTag, "..") {
+			return fmt.Errorf("invalid git_tag %q", req.GitTag)
+		}
+		if !isAllowedTag(req.GitTag) {
+			return fmt.Errorf("git_tag %q does not match allowed_tags", req.GitTag)
+		}
+		if req.Branch != "" || req.Commit != "" {
+			return fmt.Errorf("git_tag cannot be combined with branch or commit")
+		}
+	}
+
+	if req.SkipFetch && (req.Branch != "" || req.Commit != "" || req.GitTag != "") {
+		return fmt.Errorf("branch, commit and git_tag cannot be combined with skip_fetch")
+	}
+
+	if req.PreviousRelease {
+		if !blueGreenEnabled() {
+			return fmt.Errorf("previous_release requires deployment_strategy=blue-green")
+		}
+		if req.Branch != "" || req.Commit != "" || req.GitTag != "" || req.SkipFetch || req.SkipBuild {
+			return fmt.Errorf("previous_release cannot be combined with branch, commit, git_tag, skip_fetch or skip_build")
+		}
+	}
+
+	if len(req.Tags) > maxDeployTags {
+		return fmt.Errorf("too many tags: at most %d are allowed", maxDeployTags)
+	}
+	for i, tag := range req.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q: use up to 32 lowercase letters, digits, '.', '_' or '-'", req.Tags[i])
+		}
+		req.Tags[i] = tag
+	}
+
+	if req.Environment != "" && req.Environment != appConfig().Environment {
+		return fmt.Errorf("environment %q is not configured (this server deploys %q)", req.Environment, appConfig().Environment)
+	}
+
+	return nil
+}
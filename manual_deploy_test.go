@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/testutil"
+)
+
+func TestCheckoutTargetRevision_CommitMustBeOnAnAllowedBranch(t *testing.T) {
+	repo := testutil.NewRepo(t, map[string]string{"main.go": "package main\n"})
+	onMain := repo.Commit("Release", map[string]string{"VERSION": "1\n"})
+	repo.Branch("experiment")
+	onExperiment := repo.Commit("Unreviewed change", map[string]string{"VERSION": "2\n"})
+	repo.Checkout("main")
+
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL:   repo.URL,
+		AllowedBranches: "main",
+		DeployDir:       t.TempDir(),
+	})
+	repoDir := filepath.Join(appConfig().DeployDir, "repo")
+
+	tests := []struct {
+		name    string
+		req     DeployRequest
+		allowed bool
+	}{
+		{"commit on an allowed branch", DeployRequest{Commit: onMain}, true},
+		{"abbreviated commit on an allowed branch", DeployRequest{Commit: onMain[:8]}, true},
+		{"commit only on a disallowed branch", DeployRequest{Commit: onExperiment}, false},
+		{"commit on the given branch", DeployRequest{Branch: "main", Commit: onMain}, true},
+		{"commit not on the given branch", DeployRequest{Branch: "main", Commit: onExperiment}, false},
+		{"unknown commit", DeployRequest{Commit: "0123456789abcdef0123456789abcdef01234567"}, false},
+	}
+	for _, tt := range tests {
+		tt.req.RepoURL = repo.URL
+		err := checkoutTargetRevision(tt.req, repoDir)
+		if tt.allowed && err != nil {
+			t.Errorf("%s: expected the checkout to succeed, got %v", tt.name, err)
+		}
+		if !tt.allowed && err == nil {
+			t.Errorf("%s: expected the checkout to be refused", tt.name)
+		}
+		if !tt.allowed && gitHeadCommit(repoDir) == onExperiment {
+			t.Errorf("%s: the disallowed commit was checked out", tt.name)
+		}
+	}
+}
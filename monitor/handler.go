@@ -6,35 +6,95 @@ import (
 	"net/http"
 	"time"
 
-	"binaryDeploy/processmanager"
+	"binaryDeploy/health"
 )
 
 // ServerConfig represents the server configuration for the monitor
 type ServerConfig struct {
 	Port              string   `json:"port"`
-	Secret            string   `json:"secret"`
+	Secret            string   `json:"-"` // Never serialized
 	TargetRepoURL     string   `json:"target_repo_url"`
 	SelfUpdateRepoURL string   `json:"self_update_repo_url"`
 	DeployDir         string   `json:"deploy_dir"`
 	SelfUpdateDir     string   `json:"self_update_dir"`
 	AllowedBranches   []string `json:"allowed_branches"`
 	LogFile           string   `json:"log_file"`
+	DisabledEndpoints []string `json:"disabled_endpoints"`
+	Tenant            string   `json:"tenant,omitempty"` // Namespace served in multi-tenant mode
+	Login             bool     `json:"login"`            // Pages require logging in, so offer to log out
+
+	// Tells servers apart in a fleet
+	InstanceName string `json:"instance_name,omitempty"`
+}
+
+// StatusProvider reports the state of the managed application, e.g. a
+// processmanager.ProcessManager or processmanager.Group
+type StatusProvider interface {
+	GetWebStatus() map[string]interface{}
+}
+
+// DependencyProvider reports the health of the application's external
+// dependencies, e.g. a health.DependencyMonitor
+type DependencyProvider interface {
+	Statuses() []health.DependencyStatus
+}
+
+// LogFileProvider reports whether logs reach the log file, e.g. a
+// logrotate.File opened with a fallback
+type LogFileProvider interface {
+	Err() error
+}
+
+// LimitsProvider reports resource limits and their usage, e.g. deployments
+// in flight against max_concurrent_deploys
+type LimitsProvider interface {
+	LimitStatus() map[string]interface{}
+}
+
+// ReadOnlyProvider reports whether mutating endpoints are refused, e.g.
+// while binaryDeploy is updating itself
+type ReadOnlyProvider interface {
+	ReadOnlyStatus() map[string]interface{}
 }
 
 // Handler handles HTTP requests for the web monitoring interface
 type Handler struct {
-	processManager *processmanager.ProcessManager
+	processManager StatusProvider
 	serverConfig   *ServerConfig
+	dependencies   DependencyProvider
+	logFile        LogFileProvider
+	limits         LimitsProvider
+	readOnly       ReadOnlyProvider
 }
 
 // NewHandler creates a new monitor handler
-func NewHandler(pm *processmanager.ProcessManager, serverConfig *ServerConfig) *Handler {
+func NewHandler(pm StatusProvider, serverConfig *ServerConfig) *Handler {
 	return &Handler{
 		processManager: pm,
 		serverConfig:   serverConfig,
 	}
 }
 
+// SetDependencies includes the health of external dependencies in /status
+func (h *Handler) SetDependencies(dp DependencyProvider) {
+	h.dependencies = dp
+}
+
+// SetLogFile includes whether logging is degraded to stderr in /status
+func (h *Handler) SetLogFile(lf LogFileProvider) {
+	h.logFile = lf
+}
+
+// SetLimits includes resource limits and their usage in /status
+func (h *Handler) SetLimits(lp LimitsProvider) {
+	h.limits = lp
+}
+
+// SetReadOnly includes whether the API is read-only in /status
+func (h *Handler) SetReadOnly(rp ReadOnlyProvider) {
+	h.readOnly = rp
+}
+
 // RegisterRoutes registers monitoring routes with the given mux
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/status", h.statusHandler)
@@ -44,19 +104,53 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 // statusHandler returns JSON with current system status
 func (h *Handler) statusHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Status())
+}
 
+// Status is the current system status served at /status
+func (h *Handler) Status() map[string]interface{} {
+	server := map[string]interface{}{
+		"port":             h.serverConfig.Port,
+		"target_repo":      h.serverConfig.TargetRepoURL,
+		"self_update_repo": h.serverConfig.SelfUpdateRepoURL,
+		"allowed_branches": h.serverConfig.AllowedBranches,
+		"disabled":         h.serverConfig.DisabledEndpoints,
+	}
+	if h.serverConfig.Tenant != "" {
+		server["tenant"] = h.serverConfig.Tenant
+	}
+	if h.serverConfig.InstanceName != "" {
+		server["instance_name"] = h.serverConfig.InstanceName
+	}
+	if h.serverConfig.Login {
+		server["login"] = true
+	}
 	status := map[string]interface{}{
-		"server": map[string]interface{}{
-			"port":             h.serverConfig.Port,
-			"target_repo":      h.serverConfig.TargetRepoURL,
-			"self_update_repo": h.serverConfig.SelfUpdateRepoURL,
-			"allowed_branches": h.serverConfig.AllowedBranches,
-		},
+		"server":    server,
 		"process":   h.processManager.GetWebStatus(),
 		"timestamp": time.Now().Format(time.RFC3339),
 	}
-
-	json.NewEncoder(w).Encode(status)
+	if h.dependencies != nil {
+		status["dependencies"] = h.dependencies.Statuses()
+	}
+	if h.logFile != nil {
+		logging := map[string]interface{}{
+			"file":     h.serverConfig.LogFile,
+			"degraded": false,
+		}
+		if err := h.logFile.Err(); err != nil {
+			logging["degraded"] = true
+			logging["error"] = err.Error()
+		}
+		status["logging"] = logging
+	}
+	if h.limits != nil {
+		status["limits"] = h.limits.LimitStatus()
+	}
+	if h.readOnly != nil {
+		status["read_only"] = h.readOnly.ReadOnlyStatus()
+	}
+	return status
 }
 
 // monitorHandler serves the HTML monitoring dashboard
@@ -224,6 +318,11 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             cursor: not-allowed;
         }
 
+        .action-btn:disabled {
+            opacity: 0.6;
+            cursor: not-allowed;
+        }
+
         .update-target-btn:hover {
             border-color: var(--success-color);
             color: var(--success-color);
@@ -234,6 +333,16 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             color: var(--warning-color);
         }
 
+        .process-actions {
+            display: flex;
+            gap: 0.5rem;
+            padding-top: 1rem;
+        }
+
+        .process-actions .action-btn {
+            padding: 0.5rem 1rem;
+        }
+
         .btn-icon {
             font-size: 1rem;
         }
@@ -340,6 +449,17 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             background: white;
         }
 
+        .read-only-banner {
+            display: none;
+            background: rgba(245, 158, 11, 0.1);
+            border: 1px solid var(--warning-color);
+            color: #92400e;
+            padding: 1rem 1.5rem;
+            border-radius: var(--radius-lg);
+            margin-bottom: 2rem;
+            font-weight: 500;
+        }
+
         .status-badge.idle {
             background: var(--text-muted);
             color: white;
@@ -506,6 +626,35 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             word-break: break-all;
         }
 
+        .deployments-table {
+            min-width: 40rem;
+            border-collapse: collapse;
+            font-size: 0.875rem;
+        }
+
+        .deployments-table th {
+            text-align: left;
+            font-weight: 600;
+            color: var(--text-secondary);
+            padding: 0.5rem 0.75rem;
+            border-bottom: 1px solid var(--border-color);
+        }
+
+        .deployments-table td {
+            padding: 0.625rem 0.75rem;
+            border-bottom: 1px solid var(--border-color);
+            color: var(--text-primary);
+        }
+
+        .deployments-table tr:last-child td {
+            border-bottom: none;
+        }
+
+        .deployments-table code {
+            font-family: 'SF Mono', Monaco, monospace;
+            font-size: 0.8125rem;
+        }
+
         .empty-state {
             text-align: center;
             padding: 3rem 1rem;
@@ -899,7 +1048,7 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
                     <div class="logo">🚀</div>
                     <div>
                         <h1>Binary Deploy Monitor</h1>
-                        <div class="subtitle">Real-time deployment and process monitoring</div>
+                        <div class="subtitle" id="subtitle">Real-time deployment and process monitoring</div>
                     </div>
                 </div>
                 <div class="header-actions">
@@ -915,10 +1064,18 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
                         <span class="refresh-icon"></span>
                         <span>Refresh</span>
                     </button>
+                    <form method="post" action="logout" id="logoutForm" style="display: none">
+                        <button class="action-btn" type="submit">
+                            <span class="btn-icon">🔒</span>
+                            <span>Log Out</span>
+                        </button>
+                    </form>
                     <div class="last-update" id="last-update">Loading...</div>
                 </div>
             </div>
         </header>
+
+        <div class="read-only-banner" id="read-only-banner"></div>
         
         <!-- Update Status Displays -->
         <div class="update-status-container">
@@ -969,6 +1126,14 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
                         <span class="status-label">Allowed Branches</span>
                         <span class="status-value" id="allowed-branches">-</span>
                     </div>
+                    <div class="status-grid-item">
+                        <span class="status-label">Disabled Endpoints</span>
+                        <span class="status-value" id="disabled-endpoints">-</span>
+                    </div>
+                    <div class="status-grid-item">
+                        <span class="status-label">Log File</span>
+                        <span class="status-value" id="log-file">-</span>
+                    </div>
                 </div>
             </div>
             
@@ -1001,6 +1166,14 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
                         <span class="status-label">Restart Count</span>
                         <span class="status-value" id="restart-count">-</span>
                     </div>
+                    <div class="status-grid-item">
+                        <span class="status-label">Liveness</span>
+                        <span class="status-value" id="process-liveness">-</span>
+                    </div>
+                    <div class="status-grid-item">
+                        <span class="status-label">Last Exit</span>
+                        <span class="status-value" id="last-exit">-</span>
+                    </div>
                     <div class="status-grid-item">
                         <span class="status-label">Command</span>
                         <span class="status-value" id="process-command">-</span>
@@ -1009,10 +1182,46 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
                         <span class="status-label">Working Directory</span>
                         <span class="status-value" id="working-dir">-</span>
                     </div>
+                    <div class="process-actions" id="processActions">
+                        <button class="action-btn" onclick="controlProcess('restart')" id="processRestartBtn">
+                            <span class="btn-icon">🔁</span>
+                            <span>Restart</span>
+                        </button>
+                        <button class="action-btn" onclick="controlProcess('stop')" id="processStopBtn">
+                            <span class="btn-icon">⏹</span>
+                            <span>Stop</span>
+                        </button>
+                        <button class="action-btn" onclick="controlProcess('start')" id="processStartBtn">
+                            <span class="btn-icon">▶</span>
+                            <span>Start</span>
+                        </button>
+                    </div>
                 </div>
             </div>
         </div>
         
+        <!-- Dependencies Panel, shown when dependency checks are configured -->
+        <div class="card" id="dependencies-card" style="display: none;">
+            <div class="card-header">
+                <h2 class="card-title">
+                    <span class="card-icon">🔗</span>
+                    Dependencies
+                </h2>
+            </div>
+            <div class="card-body" id="dependencies"></div>
+        </div>
+        
+        <!-- Cluster Panel, shown when peer_urls are configured -->
+        <div class="card" id="cluster-card" style="display: none;">
+            <div class="card-header">
+                <h2 class="card-title">
+                    <span class="card-icon">🌐</span>
+                    Cluster
+                </h2>
+            </div>
+            <div class="card-body" id="cluster"></div>
+        </div>
+        
         <div class="card">
             <div class="card-header">
                 <h2 class="card-title">
@@ -1029,6 +1238,38 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             </div>
         </div>
         
+        <!-- Deployment History Panel -->
+        <div class="card">
+            <div class="card-header">
+                <div class="log-header-content">
+                    <h2 class="card-title">
+                        <span class="card-icon">🕘</span>
+                        Recent Deployments
+                    </h2>
+                    <div class="log-controls">
+                        <select class="action-btn" id="deploymentResult" onchange="filterDeployments()">
+                            <option value="">All results</option>
+                            <option value="success">Succeeded</option>
+                            <option value="failure">Failed</option>
+                        </select>
+                        <button class="action-btn" onclick="pageDeployments(-1)" id="deploymentsNewerBtn" disabled>
+                            <span>Newer</span>
+                        </button>
+                        <button class="action-btn" onclick="pageDeployments(1)" id="deploymentsOlderBtn" disabled>
+                            <span>Older</span>
+                        </button>
+                    </div>
+                </div>
+            </div>
+            <div class="card-body" id="deployment-history">
+                <div class="empty-state">
+                    <div class="empty-state-icon">📭</div>
+                    <div class="empty-state-text">No deployments yet</div>
+                    <div class="empty-state-subtext">Completed deployments will appear here</div>
+                </div>
+            </div>
+        </div>
+        
         <!-- Live Logs Panel -->
         <div class="card">
             <div class="card-header">
@@ -1047,7 +1288,7 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
                             <span class="btn-icon">🗑️</span>
                             <span>Clear</span>
                         </button>
-                        <a href="/logs-only" class="action-btn" target="_blank">
+                        <a href="logs-only" class="action-btn" target="_blank">
                             <span class="btn-icon">🔗</span>
                             <span>Full Screen</span>
                         </a>
@@ -1086,7 +1327,7 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             statusElement.textContent = '🟡 Connecting...';
             statusElement.className = 'log-status connecting';
 
-            eventSource = new EventSource('/logs');
+            eventSource = new EventSource('logs');
             
             eventSource.onopen = function() {
                 statusElement.textContent = '🟢 Connected';
@@ -1260,12 +1501,16 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             refreshBtn.classList.add('loading');
             
             Promise.all([
-                fetch('/status').then(response => response.json()),
-                fetch('/update-status').then(response => response.json())
+                fetch('status').then(response => response.json()),
+                fetch('update-status').then(response => response.json())
             ])
                 .then(([statusData, updateData]) => {
                     updateServerInfo(statusData.server);
+                    updateLogging(statusData.logging);
+                    updateReadOnly(statusData.read_only);
                     updateProcessInfo(statusData.process);
+                    updateDependencies(statusData.dependencies);
+                    loadCluster();
                     updateStatusInfo(updateData);
                     document.getElementById('last-update').textContent = 'Last updated: ' + new Date(statusData.timestamp).toLocaleTimeString();
                 })
@@ -1283,6 +1528,43 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             document.getElementById('target-repo').textContent = server.target_repo || 'Not configured';
             document.getElementById('self-update-repo').textContent = server.self_update_repo || 'Not configured';
             document.getElementById('allowed-branches').textContent = server.allowed_branches ? server.allowed_branches.join(', ') : 'All branches';
+            const disabled = server.disabled || [];
+            document.getElementById('disabled-endpoints').textContent = disabled.length ? disabled.join(', ') : 'None';
+            // Endpoints turned off in the config answer 404, so don't offer them
+            document.getElementById('updateTargetBtn').style.display = disabled.includes('update-target') ? 'none' : '';
+            document.getElementById('updateSelfBtn').style.display = disabled.includes('update-self') ? 'none' : '';
+            document.getElementById('processActions').style.display = disabled.includes('process') ? 'none' : '';
+            document.getElementById('logoutForm').style.display = server.login ? '' : 'none';
+            // Name the server so tabs of several instances can be told apart
+            if (server.instance_name) {
+                document.title = server.instance_name + ' - Binary Deploy Monitor';
+                document.getElementById('subtitle').textContent = server.instance_name + ' · Real-time deployment and process monitoring';
+            }
+        }
+        
+        function updateLogging(logging) {
+            const el = document.getElementById('log-file');
+            if (!logging) {
+                el.textContent = '-';
+                return;
+            }
+            el.innerHTML = logging.degraded ?
+                '<span class="status-badge error" title="' + escapeHtml(logging.error) + '">Not writable</span>' :
+                escapeHtml(logging.file);
+        }
+        
+        // Deployments and process control are refused while binaryDeploy
+        // updates itself
+        function updateReadOnly(readOnly) {
+            const banner = document.getElementById('read-only-banner');
+            const active = !!(readOnly && readOnly.active);
+            banner.style.display = active ? 'block' : 'none';
+            if (active) {
+                banner.textContent = '🔒 Read-only: ' + readOnly.message + ' since ' + new Date(readOnly.since).toLocaleTimeString() + '. Deployments and process control resume when it finishes.';
+            }
+            document.getElementById('updateTargetBtn').disabled = active;
+            document.getElementById('updateSelfBtn').disabled = active;
+            document.querySelectorAll('#processActions button').forEach(btn => btn.disabled = active);
         }
         
         function updateStatusInfo(updateData) {
@@ -1329,8 +1611,26 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             }
         }
         
+        function describeExit(exit) {
+            if (!exit) return '-';
+            let text = exit.type;
+            if (exit.oom_killed) text += ' (OOM killed)';
+            else if (exit.signal) text += ' (signal ' + exit.signal + ')';
+            else if (exit.exit_code !== undefined) text += ' (exit code ' + exit.exit_code + ')';
+            return text + ' at ' + new Date(exit.time).toLocaleString();
+        }
+
+        function describeLiveness(liveness) {
+            if (!liveness) return '-';
+            let text = liveness.status;
+            if (liveness.consecutive_failures) text += ' (' + liveness.consecutive_failures + ' failed)';
+            return text;
+        }
+
         function updateProcessInfo(process) {
             const statusElement = document.getElementById('process-status');
+            document.getElementById('last-exit').textContent = describeExit(process.last_exit);
+            document.getElementById('process-liveness').textContent = describeLiveness(process.liveness);
             
             if (process.running) {
                 statusElement.innerHTML = '<span class="status-badge running"><span class="status-indicator running"></span>Running</span>';
@@ -1384,6 +1684,74 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             }
         }
         
+        function updateDependencies(dependencies) {
+            const card = document.getElementById('dependencies-card');
+            if (!dependencies) {
+                card.style.display = 'none';
+                return;
+            }
+            card.style.display = '';
+
+            let rows = '';
+            dependencies.forEach(d => {
+                const badge = d.healthy ? 'success' : 'error';
+                rows += '<tr>' +
+                    '<td>' + escapeHtml(d.name) + '</td>' +
+                    '<td><code>' + escapeHtml(d.target) + '</code></td>' +
+                    '<td><span class="status-badge ' + badge + '" title="' + escapeHtml(d.error) + '">' + (d.healthy ? 'Up' : 'Down') + '</span></td>' +
+                    '<td>' + d.latency_ms + 'ms</td>' +
+                    '<td>' + new Date(d.since).toLocaleString() + '</td>' +
+                    '</tr>';
+            });
+
+            document.getElementById('dependencies').innerHTML = rows === '' ?
+                '<div class="empty-state"><div class="empty-state-text">Checking dependencies...</div></div>' :
+                '<table class="deployments-table">' +
+                '<thead><tr><th>Name</th><th>Target</th><th>Status</th><th>Latency</th><th>Since</th></tr></thead>' +
+                '<tbody>' + rows + '</tbody></table>';
+        }
+        
+        // The cluster endpoint answers 404 without peer_urls, hiding the card
+        function loadCluster() {
+            fetch('cluster/status')
+                .then(response => response.ok ? response.json() : null)
+                .then(updateCluster)
+                .catch(() => updateCluster(null));
+        }
+        
+        function updateCluster(cluster) {
+            const card = document.getElementById('cluster-card');
+            if (!cluster) {
+                card.style.display = 'none';
+                return;
+            }
+            card.style.display = '';
+
+            let rows = '';
+            cluster.members.forEach(m => {
+                const name = m.instance_name || m.url || 'this server';
+                let badge = '<span class="status-badge error" title="' + escapeHtml(m.error) + '">Unreachable</span>';
+                if (m.reachable) {
+                    const updating = m.status.read_only && m.status.read_only.active;
+                    const running = m.status.process && m.status.process.running;
+                    badge = updating ? '<span class="status-badge updating">Updating</span>' :
+                        running ? '<span class="status-badge success">Running</span>' :
+                        '<span class="status-badge stopped">Stopped</span>';
+                }
+                rows += '<tr>' +
+                    '<td>' + escapeHtml(name) + (m.self ? ' (this server)' : '') + '</td>' +
+                    '<td><code>' + escapeHtml(m.url || '-') + '</code></td>' +
+                    '<td>' + badge + '</td>' +
+                    '<td>' + (m.self ? '-' : m.latency_ms + 'ms') + '</td>' +
+                    '</tr>';
+            });
+
+            document.getElementById('cluster').innerHTML =
+                '<table class="deployments-table">' +
+                '<thead><tr><th>Server</th><th>URL</th><th>Status</th><th>Latency</th></tr></thead>' +
+                '<tbody>' + rows + '</tbody></table>';
+        }
+        
         function updateTargetApp() {
             const btn = document.getElementById('updateTargetBtn');
             const originalContent = btn.innerHTML;
@@ -1392,7 +1760,7 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             btn.disabled = true;
             btn.innerHTML = '<span class="btn-icon">⏳</span><span>Updating...</span>';
             
-            fetch('/update-target', { method: 'POST' })
+            fetch('update-target', { method: 'POST' })
                 .then(response => response.json())
                 .then(data => {
                     showNotification('Target app update triggered successfully!', 'success');
@@ -1421,7 +1789,7 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             btn.disabled = true;
             btn.innerHTML = '<span class="btn-icon">⏳</span><span>Updating...</span>';
             
-            fetch('/update-self', { method: 'POST' })
+            fetch('update-self', { method: 'POST' })
                 .then(response => response.json())
                 .then(data => {
                     showNotification('Self update triggered successfully!', 'warning');
@@ -1442,6 +1810,33 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
                 });
         }
 
+        // Stops, starts or restarts the target app without redeploying it
+        function controlProcess(action) {
+            if (action === 'stop' && !confirm('Stop the application? It stays down until started again or redeployed.')) {
+                return;
+            }
+            const buttons = document.querySelectorAll('#processActions button');
+            buttons.forEach(btn => btn.disabled = true);
+
+            fetch('api/process/' + action, { method: 'POST' })
+                .then(response => response.text().then(text => {
+                    if (!response.ok) {
+                        let message = text.trim() || response.statusText;
+                        try { message = JSON.parse(text).error || message; } catch (e) {}
+                        throw new Error(message);
+                    }
+                    showNotification('Application ' + (action === 'stop' ? 'stopped' : action + 'ed'), 'success');
+                }))
+                .catch(error => {
+                    console.error('Process ' + action + ' error:', error);
+                    showNotification('Failed to ' + action + ' the application: ' + error.message, 'error');
+                })
+                .finally(() => {
+                    buttons.forEach(btn => btn.disabled = false);
+                    loadStatus();
+                });
+        }
+
         function showNotification(message, type) {
             type = type || 'info';
             // Create notification element
@@ -1486,7 +1881,7 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             btn.disabled = true;
             btn.innerHTML = '<span class="btn-icon">⏳</span><span>Updating...</span>';
             
-            fetch('/update-self', { method: 'POST' })
+            fetch('update-self', { method: 'POST' })
                 .then(response => response.json())
                 .then(data => {
                     showNotification('Self update triggered successfully!', 'warning');
@@ -1543,14 +1938,110 @@ func (h *Handler) monitorHandler(w http.ResponseWriter, r *http.Request) {
             }
         }
 
+        const deploymentsPageSize = 10;
+        let deploymentsETag = '';
+        let deploymentsOffset = 0;
+
+        function filterDeployments() {
+            deploymentsOffset = 0;
+            deploymentsETag = '';
+            loadDeployments();
+        }
+
+        function pageDeployments(direction) {
+            deploymentsOffset = Math.max(0, deploymentsOffset + direction * deploymentsPageSize);
+            deploymentsETag = '';
+            loadDeployments();
+        }
+
+        function loadDeployments() {
+            const headers = deploymentsETag ? { 'If-None-Match': deploymentsETag } : {};
+            const result = document.getElementById('deploymentResult').value;
+            const url = 'deployments?limit=' + deploymentsPageSize + '&offset=' + deploymentsOffset + (result ? '&result=' + result : '');
+            fetch(url, { headers: headers })
+                .then(response => {
+                    if (response.status === 304) {
+                        return null;
+                    }
+                    deploymentsETag = response.headers.get('ETag') || '';
+                    return response.json();
+                })
+                .then(data => {
+                    if (data) {
+                        document.getElementById('deploymentsNewerBtn').disabled = data.offset === 0;
+                        document.getElementById('deploymentsOlderBtn').disabled = data.next_offset === undefined;
+                        renderDeployments(data.deployments, data.total);
+                    }
+                })
+                .catch(error => console.error('Error loading deployments:', error));
+        }
+
+        // Quotes are escaped too, as the result also goes into attributes
+        function escapeHtml(text) {
+            return String(text || '')
+                .replace(/&/g, '&amp;')
+                .replace(/</g, '&lt;')
+                .replace(/>/g, '&gt;')
+                .replace(/"/g, '&quot;')
+                .replace(/'/g, '&#39;');
+        }
+
+        function renderReleaseNotes(notes) {
+            if (!notes) return '';
+            const lines = [];
+            notes.sections.forEach(s => {
+                lines.push(s.title + ':');
+                s.changes.forEach(c => lines.push('  ' + (c.scope ? c.scope + ': ' : '') + c.summary));
+            });
+            return ' <span title="' + escapeHtml(lines.join('\n')) + '">(' + notes.commits + ' commit' + (notes.commits === 1 ? '' : 's') + ')</span>';
+        }
+
+        function renderIssues(issues) {
+            return (issues || []).map(issue => issue.url ?
+                '<a href="' + escapeHtml(issue.url) + '" target="_blank" rel="noopener">' + escapeHtml(issue.key) + '</a>' :
+                escapeHtml(issue.key)).join(', ');
+        }
+
+        function renderDeployments(deployments, total) {
+            const container = document.getElementById('deployment-history');
+            if (!deployments || deployments.length === 0) {
+                if (document.getElementById('deploymentResult').value || deploymentsOffset > 0) {
+                    container.innerHTML = '<div class="empty-state"><div class="empty-state-text">No matching deployments</div></div>';
+                }
+                return;
+            }
+
+            let rows = '';
+            deployments.forEach(d => {
+                const badge = d.result === 'success' ? 'success' : 'error';
+                rows += '<tr>' +
+                    '<td>' + new Date(d.started_at).toLocaleString() + '</td>' +
+                    '<td><code>' + escapeHtml(d.short_commit) + '</code>' + (d.kind === 'restart' ? ' (restart)' : '') + (d.version ? ' ' + escapeHtml(d.version) : '') + (d.artifact ? ' ' + escapeHtml(d.artifact) : '') + renderReleaseNotes(d.release_notes) + '</td>' +
+                    '<td>' + escapeHtml(d.branch || '-') + '</td>' +
+                    '<td><span class="status-badge ' + badge + '" title="' + escapeHtml(d.error) + '">' + escapeHtml(d.result) + '</span></td>' +
+                    '<td>' + escapeHtml(d.duration) + '</td>' +
+                    '<td>' + escapeHtml(d.trigger) + (d.triggered_by ? ' by ' + escapeHtml(d.triggered_by) : '') + '</td>' +
+                    '<td>' + escapeHtml((d.tags || []).join(', ')) + '</td>' +
+                    '<td>' + renderIssues(d.issues) + '</td>' +
+                    '</tr>';
+            });
+
+            container.innerHTML = '<table class="deployments-table">' +
+                '<thead><tr><th>Started</th><th>Commit</th><th>Branch</th><th>Result</th><th>Duration</th><th>Triggered</th><th>Tags</th><th>Issues</th></tr></thead>' +
+                '<tbody>' + rows + '</tbody></table>' +
+                '<div class="empty-state-subtext">' + (deploymentsOffset + 1) + '-' + (deploymentsOffset + deployments.length) + ' of ' + total + '</div>';
+        }
+
         // Auto-refresh every 5 seconds
         setInterval(loadStatus, 5000);
+        setInterval(loadDeployments, 5000);
         
         // Initialize log streaming
         initializeLogStreaming();
         
         // Initial load
         loadStatus();
+        loadDeployments();
     </script>
 </body>
 </html>`
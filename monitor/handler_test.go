@@ -1,9 +1,14 @@
 package monitor
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"binaryDeploy/health"
 	"binaryDeploy/processmanager"
 )
 
@@ -43,3 +48,111 @@ func TestRegisterRoutes(t *testing.T) {
 	// In a real test, you'd make actual HTTP requests to verify behavior
 	// For now, just ensure no panics occur during registration
 }
+
+type fakeDependencies []health.DependencyStatus
+
+func (f fakeDependencies) Statuses() []health.DependencyStatus { return f }
+
+func TestStatusHandler_Dependencies(t *testing.T) {
+	handler := NewHandler(processmanager.NewProcessManager(), &ServerConfig{Port: "8080"})
+
+	rec := httptest.NewRecorder()
+	handler.statusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if strings.Contains(rec.Body.String(), `"dependencies"`) {
+		t.Errorf("expected no dependencies without a provider, got %s", rec.Body.String())
+	}
+
+	handler.SetDependencies(fakeDependencies{{Name: "db", Target: "postgres://db:5432", Error: "connection refused"}})
+	rec = httptest.NewRecorder()
+	handler.statusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var status struct {
+		Dependencies []health.DependencyStatus `json:"dependencies"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Dependencies) != 1 || status.Dependencies[0].Name != "db" || status.Dependencies[0].Healthy {
+		t.Errorf("unexpected dependencies %+v", status.Dependencies)
+	}
+}
+
+type fakeLogFile struct{ err error }
+
+func (f fakeLogFile) Err() error { return f.err }
+
+func TestStatusHandler_Logging(t *testing.T) {
+	handler := NewHandler(processmanager.NewProcessManager(), &ServerConfig{Port: "8080", LogFile: "/var/log/binaryDeploy.log"})
+	handler.SetLogFile(fakeLogFile{err: errors.New("read-only file system")})
+
+	rec := httptest.NewRecorder()
+	handler.statusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var status struct {
+		Logging struct {
+			File     string `json:"file"`
+			Degraded bool   `json:"degraded"`
+			Error    string `json:"error"`
+		} `json:"logging"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Logging.Degraded || status.Logging.Error != "read-only file system" || status.Logging.File != "/var/log/binaryDeploy.log" {
+		t.Errorf("expected degraded logging, got %+v", status.Logging)
+	}
+}
+
+func TestStatusHandler_DisabledEndpoints(t *testing.T) {
+	handler := NewHandler(processmanager.NewProcessManager(), &ServerConfig{Port: "8080", DisabledEndpoints: []string{"update-self", "exec"}, InstanceName: "web-01"})
+
+	rec := httptest.NewRecorder()
+	handler.statusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var status struct {
+		Server struct {
+			Disabled     []string `json:"disabled"`
+			InstanceName string   `json:"instance_name"`
+		} `json:"server"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(status.Server.Disabled, ",") != "update-self,exec" {
+		t.Errorf("expected disabled endpoints in the server status, got %v", status.Server.Disabled)
+	}
+	if status.Server.InstanceName != "web-01" {
+		t.Errorf("expected the instance name in the server status, got %q", status.Server.InstanceName)
+	}
+}
+
+type fakeReadOnly map[string]interface{}
+
+func (f fakeReadOnly) ReadOnlyStatus() map[string]interface{} { return f }
+
+func TestStatusHandler_ReadOnly(t *testing.T) {
+	handler := NewHandler(processmanager.NewProcessManager(), &ServerConfig{Port: "8080"})
+
+	rec := httptest.NewRecorder()
+	handler.statusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if strings.Contains(rec.Body.String(), `"read_only"`) {
+		t.Errorf("expected no read-only state without a provider, got %s", rec.Body.String())
+	}
+
+	handler.SetReadOnly(fakeReadOnly{"active": true, "reason": "self-update"})
+	rec = httptest.NewRecorder()
+	handler.statusHandler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var status struct {
+		ReadOnly struct {
+			Active bool   `json:"active"`
+			Reason string `json:"reason"`
+		} `json:"read_only"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.ReadOnly.Active || status.ReadOnly.Reason != "self-update" {
+		t.Errorf("expected an active read-only state, got %+v", status.ReadOnly)
+	}
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/portalloc"
+)
+
+const (
+	// appEnv and appListenEnv tell an app instance started in multi-app
+	// mode which app it deploys and where to listen
+	appEnv       = "BINARYDEPLOY_APP"
+	appListenEnv = "BINARYDEPLOY_APP_LISTEN"
+
+	// appsRoot holds the working directory of each app instance
+	appsRoot = "apps"
+
+	// appWebhookTimeout bounds how long an app instance may take to answer
+	// a forwarded webhook
+	appWebhookTimeout = 30 * time.Second
+)
+
+// managedAppName is the app this instance deploys when it was started in
+// multi-app mode, empty otherwise
+var managedAppName = os.Getenv(appEnv)
+
+// applyAppOverrides confines an app instance the way applyTenantOverrides
+// confines a tenant: the front server is its only way in, under /a/<name>
+func applyAppOverrides(cfg *config.DeployConfig, name, listen string) error {
+	if cfg.ConfigRepoURL != "" {
+		return fmt.Errorf("config_repo_url is not supported in multi-app mode")
+	}
+	cfg.Listen = []string{listen}
+	cfg.AdminListen = nil
+	cfg.BasePath = "/a/" + name
+	cfg.TrustedProxies = append(cfg.TrustedProxies, "127.0.0.1/32", "::1/128")
+	if !slices.Contains(cfg.DisabledEndpoints, "update-self") {
+		cfg.DisabledEndpoints = append(cfg.DisabledEndpoints, "update-self")
+	}
+	return nil
+}
+
+// multiAppConfig returns the apps declared in deploy.config or deploy.d/,
+// nil when binaryDeploy deploys a single app
+func multiAppConfig() (*config.AppsConfig, error) {
+	if _, err := os.Stat(deployConfigFile); err != nil {
+		// loadConfig explains how to create one
+		return nil, nil
+	}
+	return config.LoadAppsConfig(deployConfigFile, config.AppsDir)
+}
+
+// appTarget is what the front server routes webhook pushes by
+type appTarget struct {
+	RepoURL   string
+	Branches  string   // allowed_branches
+	Tags      []string // allowed_tags
+	DeployDir string
+	Ports     int // Application ports the app needs from port_range
+}
+
+// appsFront is the front server of multi-app mode
+type appsFront struct {
+	cfg       *config.AppsConfig
+	processes map[string]*instanceProcess
+	names     []string
+
+	mu      sync.RWMutex
+	targets map[string]appTarget
+}
+
+// runMultiApp runs one binaryDeploy instance per app, each in its own
+// directory under apps/ with its own deploy.config, history and logs, and
+// routes webhook pushes to the apps whose repository and branches match
+func runMultiApp(cfg *config.AppsConfig) int {
+	if tenantName != "" {
+		fmt.Fprintf(os.Stderr, "Multi-app mode is not available to tenants; declare each app as its own tenant\n")
+		return 1
+	}
+	if err := config.ValidateAppsConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Apps configuration validation failed: %v\n", err)
+		return 1
+	}
+
+	// The front server applies the shared webhook and ban settings itself,
+	// ahead of the apps
+	currentConfig.Store(cfg.Front)
+	setupAuthGuard()
+	setupReverseProxy()
+
+	front := &appsFront{cfg: cfg, processes: make(map[string]*instanceProcess, len(cfg.Apps)), targets: map[string]appTarget{}}
+	deployDirs := map[string]string{}
+	for _, app := range cfg.Apps {
+		target, err := prepareApp(app, portalloc.Range{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "App %s: %v\n", app.Name, err)
+			return 1
+		}
+		if other, ok := deployDirs[target.DeployDir]; ok {
+			fmt.Fprintf(os.Stderr, "Apps %s and %s share the deploy directory %s\n", other, app.Name, target.DeployDir)
+			return 1
+		}
+		deployDirs[target.DeployDir] = app.Name
+		front.targets[app.Name] = target
+		front.names = append(front.names, app.Name)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating the binaryDeploy executable: %v\n", err)
+		return 1
+	}
+
+	var ports *portalloc.Allocator
+	if cfg.PortRange.Size() > 0 {
+		listen := append(append([]string{}, cfg.Listen...), cfg.AdminListen...)
+		if ports, err = openPortAllocator(cfg.PortRange, filepath.Join(appsRoot, "ports.json"), listen, front.names); err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening port allocations: %v\n", err)
+			return 1
+		}
+	}
+
+	output := &lockedWriter{w: os.Stdout}
+	for _, app := range cfg.Apps {
+		proc := &instanceProcess{
+			kind: "app",
+			name: app.Name,
+			dir:  filepath.Join(appsRoot, app.Name),
+			port: app.Port,
+			path: "/a/" + app.Name + "/",
+			env: []string{
+				appEnv + "=" + app.Name,
+				appListenEnv + "=" + appListenAddress(app),
+			},
+			output: output,
+			quit:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		if ports != nil {
+			if proc.appPorts, err = ports.Allocate(app.Name, front.targets[app.Name].Ports); err != nil {
+				fmt.Fprintf(os.Stderr, "App %s: %v\n", app.Name, err)
+				return 1
+			}
+			proc.env = append(proc.env, allocatedPortsEnv+"="+proc.appPorts.String())
+			slog.Info("Allocated application ports", "app", app.Name, "ports", proc.appPorts.String())
+		}
+		front.processes[app.Name] = proc
+		go proc.supervise(executable)
+	}
+
+	routes := front.routes()
+	public, surface := routes, "all"
+	if len(cfg.AdminListen) > 0 {
+		public, surface = publicRoutes(routes), "webhooks"
+	}
+	tlsConfig, certificates, err := newServerTLS(cfg.TLS)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up TLS: %v\n", err)
+		os.Exit(1)
+	}
+	var servers []*http.Server
+	for _, addr := range cfg.Listen {
+		servers = append(servers, startFrontServer(addr, surface, public, tlsConfig))
+	}
+	for _, addr := range cfg.AdminListen {
+		servers = append(servers, startFrontServer(addr, "admin", routes, tlsConfig))
+	}
+	if certificates != nil {
+		go certificates.Run(context.Background())
+	}
+	slog.Info("Serving apps", "apps", front.names)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		front.reload()
+	}
+
+	slog.Info("Shutting down apps...")
+	ctx, cancel := context.WithTimeout(context.Background(), instanceStopTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Server forced to shutdown", "addr", server.Addr, "error", err)
+		}
+	}
+	stopInstances(ctx, front.processes)
+	slog.Info("All apps stopped")
+	return 0
+}
+
+func appListenAddress(app config.App) string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(app.Port))
+}
+
+// prepareApp validates the app's settings as the deploy.config of its
+// instance, on the ports allocated to it if any, and writes them to
+// apps/<name>/deploy.config. A file that fails validation is not written,
+// so the instance keeps its current one.
+func prepareApp(app config.App, allocated portalloc.Range) (appTarget, error) {
+	dir := filepath.Join(appsRoot, app.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return appTarget{}, err
+	}
+	path := filepath.Join(dir, deployConfigFile)
+	incoming := path + ".incoming"
+	if err := app.WriteConfigFile(incoming); err != nil {
+		return appTarget{}, err
+	}
+
+	cfg, err := config.LoadDeployConfig(incoming)
+	if err == nil {
+		err = applyAppOverrides(cfg, app.Name, appListenAddress(app))
+	}
+	if err == nil && allocated.Size() > 0 {
+		if err = applyAllocatedPorts(cfg, allocated.String()); err != nil {
+			err = fmt.Errorf("%w; restart binaryDeploy to allocate more", err)
+		}
+	}
+	if err == nil {
+		err = config.ValidateConfig(cfg)
+	}
+	if err != nil {
+		os.Remove(incoming)
+		return appTarget{}, err
+	}
+	if err := os.Rename(incoming, path); err != nil {
+		os.Remove(incoming)
+		return appTarget{}, err
+	}
+
+	deployDir := cfg.DeployDir
+	if !filepath.IsAbs(deployDir) {
+		deployDir = filepath.Join(dir, deployDir)
+	}
+	deployDir, err = filepath.Abs(deployDir)
+	if err != nil {
+		return appTarget{}, err
+	}
+	return appTarget{RepoURL: cfg.TargetRepoURL, Branches: cfg.AllowedBranches, Tags: cfg.AllowedTags, DeployDir: deployDir, Ports: cfg.ApplicationPorts()}, nil
+}
+
+// reload re-reads deploy.config and deploy.d/ on SIGHUP and passes each
+// app's new settings on to its instance, which reloads them itself. Adding
+// or removing apps takes a restart.
+func (f *appsFront) reload() {
+	slog.Info("Received SIGHUP, reloading apps")
+	cfg, err := config.LoadAppsConfig(deployConfigFile, config.AppsDir)
+	if err == nil && cfg == nil {
+		err = errors.New("no apps declared")
+	}
+	if err == nil {
+		err = config.ValidateAppsConfig(cfg)
+	}
+	if err != nil {
+		slog.Error("Reloading apps failed, keeping the running configuration", "error", err)
+		return
+	}
+	currentConfig.Store(cfg.Front)
+	setupReverseProxy()
+
+	declared := map[string]bool{}
+	for _, app := range cfg.Apps {
+		declared[app.Name] = true
+		proc, ok := f.processes[app.Name]
+		if !ok {
+			slog.Warn("New app starts once binaryDeploy restarts", "app", app.Name)
+			continue
+		}
+		app.Port = proc.port
+		target, err := prepareApp(app, proc.appPorts)
+		if err != nil {
+			slog.Error("Invalid app configuration, keeping the running one", "app", app.Name, "error", err)
+			continue
+		}
+		f.mu.Lock()
+		f.targets[app.Name] = target
+		f.mu.Unlock()
+		proc.signal(syscall.SIGHUP)
+	}
+	for _, name := range f.names {
+		if !declared[name] {
+			slog.Warn("Removed app keeps running until binaryDeploy restarts", "app", name)
+		}
+	}
+}
+
+// matchingApps returns the apps deploying ref of repoURL, a branch or
+// refs/tags/<tag>
+func (f *appsFront) matchingApps(repoURL, ref string) []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	tag, isTag := strings.CutPrefix(ref, "refs/tags/")
+	var names []string
+	for _, name := range f.names {
+		target := f.targets[name]
+		if target.RepoURL != repoURL {
+			continue
+		}
+		if (isTag && tagMatches(target.Tags, tag)) || (!isTag && branchMatches(target.Branches, extractBranchFromRef(ref))) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// appWebhookResult is an app instance's answer to a forwarded webhook
+type appWebhookResult struct {
+	Status   int    `json:"status"`
+	Response string `json:"response"`
+}
+
+// webhookHandler routes a push or release to every app whose
+// target_repo_url and allowed_branches or allowed_tags match it. The apps verify the signature themselves, with
+// their own secret; the front server counts their rejections towards bans.
+func (f *appsFront) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := readWebhookBody(w, r, maxWebhookPayloadSize, nil)
+	if err != nil {
+		slog.Warn("Rejected webhook body", "client", requestSource(r), "error", err)
+		writeWebhookBodyError(w, err)
+		return
+	}
+
+	var payload GitHubPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if forge := detectWebhookForge(r); r.Header.Get(forge.EventHeader) == "release" {
+		payload.Ref = "refs/tags/" + payload.Release.TagName
+	}
+	names := f.matchingApps(payload.Repository.URL, payload.Ref)
+	if len(names) == 0 {
+		slog.Info("No app deploys the pushed branch", "repo_url", payload.Repository.URL, "ref", payload.Ref)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "No app deploys %s from %s", payload.Ref, payload.Repository.URL)
+		return
+	}
+
+	results := make(map[string]appWebhookResult, len(names))
+	status := http.StatusOK
+	for _, name := range names {
+		result := f.forwardWebhook(r, name, body)
+		slog.Info("Forwarded webhook to app", "app", name, "ref", payload.Ref, "status", result.Status)
+		results[name] = result
+		if result.Status == http.StatusUnauthorized {
+			recordAuthFailure(r, "webhook signature rejected by app "+name)
+		}
+		if result.Status >= 300 && status == http.StatusOK {
+			status = result.Status
+		}
+	}
+
+	if len(names) == 1 {
+		w.WriteHeader(results[names[0]].Status)
+		io.WriteString(w, results[names[0]].Response)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"apps": results})
+}
+
+// forwardWebhook passes the webhook on to the named app's instance with its
+// headers, so the signature still verifies
+func (f *appsFront) forwardWebhook(r *http.Request, name string, body []byte) appWebhookResult {
+	proc := f.processes[name]
+	target := proc.instanceURL()
+	target.Path = "/a/" + name + "/webhook"
+	target.RawQuery = r.URL.RawQuery
+
+	ctx, cancel := context.WithTimeout(r.Context(), appWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return appWebhookResult{Status: http.StatusInternalServerError, Response: err.Error()}
+	}
+	req.Header = r.Header.Clone()
+	req.Host = r.Host
+	clientIP := requestSource(r)
+	if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+		clientIP = prior + ", " + clientIP
+	}
+	req.Header.Set("X-Forwarded-For", clientIP)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.Warn("App unavailable", "app", name, "error", err)
+		return appWebhookResult{Status: http.StatusBadGateway, Response: fmt.Sprintf("App %s is unavailable", name)}
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return appWebhookResult{Status: resp.StatusCode, Response: strings.TrimSpace(string(response))}
+}
+
+// appStatus is the /apps view of an app instance
+type appStatus struct {
+	instanceStatus
+	RepoURL  string   `json:"repo_url"`
+	Branches string   `json:"allowed_branches,omitempty"`
+	Tags     []string `json:"allowed_tags,omitempty"`
+}
+
+// routes serves the webhook, each app under /a/<name>/ and its
+// /apps/<name>/ management API, plus an app index and /apps status
+func (f *appsFront) routes() http.Handler {
+	proxies := make(map[string]*httputil.ReverseProxy, len(f.processes))
+	apiProxies := make(map[string]*httputil.ReverseProxy, len(f.processes))
+	for name, proc := range f.processes {
+		proxies[name] = instanceProxy(proc, "")
+		apiProxies[name] = instanceProxy(proc, "/a/"+name)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", f.webhookHandler)
+	mux.HandleFunc("/a/", func(w http.ResponseWriter, r *http.Request) {
+		name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/a/"), "/")
+		proxy, ok := proxies[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/apps/", func(w http.ResponseWriter, r *http.Request) {
+		name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/apps/"), "/")
+		proxy, ok := apiProxies[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown application %q", name), http.StatusNotFound)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/apps", func(w http.ResponseWriter, r *http.Request) {
+		if !supervisorAuthorized(w, r, f.cfg.APIToken) {
+			return
+		}
+		statuses := make([]appStatus, 0, len(f.names))
+		f.mu.RLock()
+		for _, name := range f.names {
+			target := f.targets[name]
+			statuses = append(statuses, appStatus{f.processes[name].status(), target.RepoURL, target.Branches, target.Tags})
+		}
+		f.mu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"apps": statuses})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		appIndexTemplate.Execute(w, f.names)
+	})
+	return mux
+}
+
+// frontHandler refuses banned clients and applies trusted proxies'
+// X-Forwarded-* headers ahead of handler, as in single-app mode
+func frontHandler(handler http.Handler) http.Handler {
+	return withForwardedHeaders(withAuthBans(handler))
+}
+
+// startFrontServer serves handler on addr, over HTTPS when tlsConfig is
+// set, exiting if the address can't be listened on
+func startFrontServer(addr, surface string, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	server := &http.Server{Addr: addr, Handler: frontHandler(handler), ReadHeaderTimeout: readHeaderTimeout, TLSConfig: tlsConfig}
+	go func() {
+		slog.Info("Starting multi-app server", "addr", addr, "serves", surface, "tls", tlsConfig != nil)
+		if err := listenAndServe(server); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server failed", "addr", addr, "error", err)
+			fmt.Fprintf(os.Stderr, "Error listening on %s: %v\n", addr, err)
+			os.Exit(1)
+		}
+	}()
+	return server
+}
+
+var appIndexTemplate = template.Must(template.New("apps").Parse(`<!DOCTYPE html>
+<html><head><title>binaryDeploy apps</title></head>
+<body><h1>Apps</h1><ul>
+{{range .}}<li><a href="/a/{{.}}/">{{.}}</a></li>
+{{end}}</ul></body></html>
+`))
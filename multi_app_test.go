@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+// fakeApp stands in for an app instance, verifying webhook signatures with
+// its own secret
+type fakeApp struct {
+	secret string
+
+	mu        sync.Mutex
+	paths     []string
+	forwarded []string
+}
+
+func (a *fakeApp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	a.mu.Lock()
+	a.paths = append(a.paths, r.URL.Path)
+	a.forwarded = append(a.forwarded, r.Header.Get("X-Forwarded-For"))
+	a.mu.Unlock()
+
+	mac := hmac.New(sha256.New, []byte(a.secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(r.Header.Get("X-Hub-Signature-256")), []byte(expected)) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	fmt.Fprint(w, "Deployment queued")
+}
+
+func (a *fakeApp) received() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.paths)
+}
+
+// newTestFront returns a front server routing to a fakeApp per target
+func newTestFront(t *testing.T, front *config.DeployConfig, targets map[string]appTarget) (*appsFront, map[string]*fakeApp) {
+	t.Helper()
+	currentConfig.Store(front)
+	setupAuthGuard()
+	setupReverseProxy()
+
+	f := &appsFront{cfg: &config.AppsConfig{}, processes: map[string]*instanceProcess{}, targets: targets}
+	apps := map[string]*fakeApp{}
+	for name := range targets {
+		app := &fakeApp{secret: name + "-secret"}
+		server := httptest.NewServer(app)
+		t.Cleanup(server.Close)
+		u, _ := url.Parse(server.URL)
+		port, _ := strconv.Atoi(u.Port())
+		f.processes[name] = &instanceProcess{kind: "app", name: name, port: port}
+		f.names = append(f.names, name)
+		apps[name] = app
+	}
+	slices.Sort(f.names)
+	return f, apps
+}
+
+func pushPayload(repoURL, ref string) string {
+	return fmt.Sprintf(`{"ref": %q, "after": "3f9c2a7e1b", "repository": {"clone_url": %q}}`, ref, repoURL)
+}
+
+func signedWebhook(body, secret string) *http.Request {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.RemoteAddr = "203.0.113.7:40000"
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestAppsFront_RoutesPushesToMatchingApps(t *testing.T) {
+	const shop, blog = "https://git.example.com/team/shop.git", "https://git.example.com/team/blog.git"
+	front, apps := newTestFront(t, config.DefaultDeployConfig(), map[string]appTarget{
+		"shop":         {RepoURL: shop, Branches: "main"},
+		"shop-staging": {RepoURL: shop, Branches: "staging"},
+		"blog":         {RepoURL: blog, Branches: "main", Tags: []string{"v*"}},
+	})
+	handler := frontHandler(front.routes())
+
+	tests := []struct {
+		repoURL, ref string
+		apps         []string
+	}{
+		{shop, "refs/heads/main", []string{"shop"}},
+		{shop, "refs/heads/staging", []string{"shop-staging"}},
+		{blog, "refs/heads/main", []string{"blog"}},
+		{blog, "refs/tags/v1.2.0", []string{"blog"}},
+		{shop, "refs/heads/feature", nil},
+		{"https://git.example.com/team/other.git", "refs/heads/main", nil},
+	}
+	for _, tt := range tests {
+		if got := front.matchingApps(tt.repoURL, tt.ref); !slices.Equal(got, tt.apps) {
+			t.Errorf("%s %s: expected apps %v, got %v", tt.repoURL, tt.ref, tt.apps, got)
+		}
+	}
+
+	for _, tt := range tests[:3] {
+		name := tt.apps[0]
+		before := len(apps[name].received())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, signedWebhook(pushPayload(tt.repoURL, tt.ref), name+"-secret"))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s %s: expected 200, got %d %s", tt.repoURL, tt.ref, rec.Code, rec.Body)
+		}
+		if received := apps[name].received(); len(received) != before+1 || received[before] != "/a/"+name+"/webhook" {
+			t.Errorf("%s %s: expected the push to reach %s at /a/%s/webhook, got %v", tt.repoURL, tt.ref, name, name, received)
+		}
+	}
+	for name, app := range apps {
+		if n := len(app.received()); n != 1 {
+			t.Errorf("Expected %s to receive exactly its own push, got %d", name, n)
+		}
+	}
+	if forwarded := apps["shop"].forwarded[0]; forwarded != "203.0.113.7" {
+		t.Errorf("Expected the client address to be forwarded, got %q", forwarded)
+	}
+}
+
+func TestAppsFront_SignatureCheckedByAppAndBansRepeatedFailures(t *testing.T) {
+	const shop = "https://git.example.com/team/shop.git"
+	settings := config.DefaultDeployConfig()
+	settings.AuthFailureThreshold = 3
+	front, _ := newTestFront(t, settings, map[string]appTarget{"shop": {RepoURL: shop, Branches: "main"}})
+	handler := frontHandler(front.routes())
+	body := pushPayload(shop, "refs/heads/main")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedWebhook(body, "shop-secret"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected a correctly signed push to be accepted, got %d %s", rec.Code, rec.Body)
+	}
+
+	for i := 0; i < settings.AuthFailureThreshold; i++ {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, signedWebhook(body, "wrong-secret"))
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected a wrongly signed push to be refused with 401, got %d %s", rec.Code, rec.Body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedWebhook(body, "shop-secret"))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected the front server to ban the client after %d bad signatures, got %d", settings.AuthFailureThreshold, rec.Code)
+	}
+}
+
+func TestAppsFront_WebhookBodyLimits(t *testing.T) {
+	const shop = "https://git.example.com/team/shop.git"
+	settings := config.DefaultDeployConfig()
+	settings.WebhookTimeout = 1
+	front, apps := newTestFront(t, settings, map[string]appTarget{"shop": {RepoURL: shop, Branches: "main"}})
+	server := httptest.NewServer(frontHandler(front.routes()))
+	defer server.Close()
+
+	body, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte(`{"ref": "refs/heads/main",`))
+		time.Sleep(3 * time.Second)
+		writer.Close()
+	}()
+	resp, err := http.Post(server.URL+"/webhook", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("Expected a body slower than webhook_body_timeout to get 408, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/webhook", "application/json", strings.NewReader(strings.Repeat(" ", maxWebhookPayloadSize+1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected an oversized body to get 413, got %d", resp.StatusCode)
+	}
+
+	if received := apps["shop"].received(); len(received) != 0 {
+		t.Errorf("Expected rejected bodies not to be forwarded, got %v", received)
+	}
+}
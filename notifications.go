@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"binaryDeploy/history"
+	"binaryDeploy/notify"
+)
+
+// setupNotifications routes events to notify_urls and the configured
+// notifiers. ValidateConfig has already built every notifier once, so
+// failures here are unexpected and only disable the notifier concerned.
+func setupNotifications() {
+	var routes []notify.Route
+	for _, url := range appConfig().NotifyURLs {
+		routes = append(routes, notify.Route{Name: "notify_urls", Notifier: &notify.Webhook{URL: url, Secret: appConfig().NotifySecret}})
+	}
+
+	names := make([]string, 0, len(appConfig().Notifiers))
+	for name := range appConfig().Notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		route, err := notify.NewRoute(name, appConfig().Notifiers[name])
+		if err != nil {
+			slog.Error("Failed to set up notifier", "notifier", name, "error", err)
+			continue
+		}
+		routes = append(routes, route)
+		slog.Info("Notifier enabled", "notifier", name, "type", appConfig().Notifiers[name]["type"],
+			"events", route.Events, "min_severity", route.MinSeverity, "quiet_hours", appConfig().Notifiers[name]["quiet_hours"])
+	}
+
+	dispatcher := notify.NewDispatcher(routes...)
+	dispatcher.InstanceName = appConfig().InstanceName
+	currentNotifier.Store(dispatcher)
+}
+
+// notifyDeploymentStarted announces a deployment once the commit it deploys
+// is checked out; commit is empty for dropped artifacts
+func notifyDeploymentStarted(req DeployRequest, commit string) {
+	fields := map[string]interface{}{
+		"branch":       req.Branch,
+		"trigger":      req.Trigger,
+		"triggered_by": req.TriggeredBy,
+	}
+	message := "Deploying " + appConfig().AppName
+	if commit != "" {
+		fields["commit"] = commit
+		message += " at " + shortSHA(commit)
+		if subject := gitCommitSubject(targetRepoDir(), commit); subject != "" {
+			fields["commit_message"] = subject
+		}
+	}
+	if req.GitTag != "" {
+		fields["version"] = req.GitTag
+		message = fmt.Sprintf("Deploying %s %s (%s)", appConfig().AppName, req.GitTag, shortSHA(commit))
+	}
+	if req.Artifact != "" {
+		fields["artifact"] = filepath.Base(req.Artifact)
+		message += " from " + filepath.Base(req.Artifact)
+	}
+	notifier().Notify(notify.Event{
+		Type:          notify.EventDeploymentStarted,
+		App:           appConfig().AppName,
+		Message:       message,
+		Fields:        fields,
+		CorrelationID: req.CorrelationID,
+	})
+}
+
+// notifySelfUpdateStarted announces a self-update to ref, a branch or
+// refs/tags/<tag>
+func notifySelfUpdateStarted(label, ref string) {
+	notifier().Notify(notify.Event{
+		Type:    notify.EventSelfUpdateStarted,
+		App:     "binaryDeploy",
+		Message: "Updating binaryDeploy to " + strings.TrimPrefix(ref, "refs/tags/"),
+		Fields: map[string]interface{}{
+			"ref":     ref,
+			"trigger": label,
+		},
+	})
+}
+
+// notifySelfUpdateFinished announces the outcome of the self-update or
+// rollback recorded in rec; message is the subject line of its commit, if
+// known
+func notifySelfUpdateFinished(rec history.Record, message string) {
+	target := rec.Version
+	if target == "" {
+		target = rec.Branch
+	}
+	fields := map[string]interface{}{
+		"commit":       rec.Commit,
+		"trigger":      rec.Trigger,
+		"triggered_by": rec.TriggeredBy,
+		"duration_ms":  rec.FinishedAt.Sub(rec.StartedAt).Milliseconds(),
+	}
+	if message != "" {
+		fields["commit_message"] = message
+	}
+	if rec.Version != "" {
+		fields["version"] = rec.Version
+	}
+	if rec.Branch != "" {
+		fields["branch"] = rec.Branch
+	}
+
+	event := notify.Event{
+		Type:    notify.EventSelfUpdateSucceeded,
+		App:     rec.App,
+		Message: "Updated binaryDeploy to " + target,
+		Fields:  fields,
+	}
+	if rec.Commit != "" && !strings.HasPrefix(rec.Commit, target) {
+		event.Message += fmt.Sprintf(" (%s)", shortSHA(rec.Commit))
+	}
+	if rec.Result == history.ResultFailure {
+		fields["error"] = rec.Error
+		event.Type = notify.EventSelfUpdateFailed
+		event.Message = fmt.Sprintf("Self-update of binaryDeploy to %s failed: %s", target, rec.Error)
+	}
+	notifier().Notify(event)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/notify"
+	"binaryDeploy/testutil"
+	"binaryDeploy/updater"
+)
+
+// waitForEvents waits for dispatcher to deliver what was sent so far
+func waitForEvents(t *testing.T, recorder *recordingNotifier, dispatcher *notify.Dispatcher) []notify.Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dispatcher.Wait(ctx)
+	return recorder.Events()
+}
+
+func TestNotifyDeploymentStarted_IncludesTheCommit(t *testing.T) {
+	repo := testutil.NewRepo(t, map[string]string{"main.go": "package main\n"})
+	commit := repo.Commit("Add billing export", map[string]string{"billing.go": "package main\n"})
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp", DeployDir: t.TempDir()})
+	gitOutput(t, appConfig().DeployDir, "clone", "--quiet", repo.URL, filepath.Base(targetRepoDir()))
+	recorder, dispatcher := useRecordingNotifier(t)
+
+	notifyDeploymentStarted(DeployRequest{Branch: "main", Trigger: "Webhook deployment", TriggeredBy: "alice", CorrelationID: "req-1"}, commit)
+	notifyDeploymentStarted(DeployRequest{GitTag: "v1.2.0", Trigger: "Webhook deployment"}, commit)
+
+	events := waitForEvents(t, recorder, dispatcher)
+	if len(events) != 2 {
+		t.Fatalf("Expected two events, got %+v", events)
+	}
+	branch, tag := events[0], events[1]
+	if branch.Type != notify.EventDeploymentStarted || branch.App != "myapp" || branch.CorrelationID != "req-1" {
+		t.Errorf("Unexpected event %+v", branch)
+	}
+	if branch.Message != "Deploying myapp at "+shortSHA(commit) || branch.Fields["commit_message"] != "Add billing export" ||
+		branch.Fields["branch"] != "main" || branch.Fields["triggered_by"] != "alice" {
+		t.Errorf("Expected the commit and its subject, got %q %v", branch.Message, branch.Fields)
+	}
+	if tag.Message != "Deploying myapp v1.2.0 ("+shortSHA(commit)+")" || tag.Fields["version"] != "v1.2.0" {
+		t.Errorf("Expected the tag as version, got %q %v", tag.Message, tag.Fields)
+	}
+}
+
+func TestRecordSelfUpdate_NotifiesOutcome(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{SelfUpdateRepoURL: "https://git.example.com/team/binaryDeploy.git"})
+	recorder, dispatcher := useRecordingNotifier(t)
+
+	start := time.Now().Add(-time.Minute)
+	notifySelfUpdateStarted("Webhook self-update", "refs/tags/v2.0.0")
+	recordSelfUpdate("Webhook self-update", "refs/tags/v2.0.0", "a1b2c3d4e5f6a7b8", "Speed up builds", start, nil)
+	recordSelfUpdate("Self update", "main", "", "", start, errors.New("go build: exit status 1"))
+	recordSelfUpdateRollback(updater.RetainedBinary{Version: "v1.9.0", Commit: "9f8e7d6c5b4a"}, "", "alice", start, nil)
+
+	events := waitForEvents(t, recorder, dispatcher)
+	if len(events) != 4 {
+		t.Fatalf("Expected four events, got %+v", events)
+	}
+	tests := []struct {
+		event   notify.Event
+		typ     string
+		message string
+	}{
+		{events[0], notify.EventSelfUpdateStarted, "Updating binaryDeploy to v2.0.0"},
+		{events[1], notify.EventSelfUpdateSucceeded, "Updated binaryDeploy to v2.0.0 (a1b2c3d4)"},
+		{events[2], notify.EventSelfUpdateFailed, "Self-update of binaryDeploy to main failed: go build: exit status 1"},
+		{events[3], notify.EventSelfUpdateSucceeded, "Updated binaryDeploy to v1.9.0 (9f8e7d6c)"},
+	}
+	for i, tt := range tests {
+		if tt.event.Type != tt.typ || tt.event.Message != tt.message {
+			t.Errorf("Event %d: expected %s %q, got %s %q", i, tt.typ, tt.message, tt.event.Type, tt.event.Message)
+		}
+	}
+	if fields := events[1].Fields; fields["commit_message"] != "Speed up builds" || fields["version"] != "v2.0.0" || fields["duration_ms"].(int64) < 60000 {
+		t.Errorf("Expected the commit subject, version and duration, got %v", fields)
+	}
+	if events[2].Fields["error"] != "go build: exit status 1" || events[3].Fields["triggered_by"] != "alice" {
+		t.Errorf("Unexpected fields %v, %v", events[2].Fields, events[3].Fields)
+	}
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email sends events through an SMTP server
+type Email struct {
+	Addr     string // host:port
+	Username string // Authenticates with PLAIN when set
+	Password string
+	From     string
+	To       []string
+}
+
+// NewEmail creates an email provider; options: smtp_host, smtp_port (587
+// when empty), username, password, from and to (comma-separated)
+func NewEmail(options map[string]string) (Notifier, error) {
+	host, err := required(options, "smtp_host")
+	if err != nil {
+		return nil, err
+	}
+	port := options["smtp_port"]
+	if port == "" {
+		port = "587"
+	}
+	from, err := required(options, "from")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid from %q: %w", from, err)
+	}
+	e := &Email{Addr: net.JoinHostPort(host, port), Username: options["username"], Password: options["password"], From: from}
+	for _, to := range strings.Split(options["to"], ",") {
+		if to = strings.TrimSpace(to); to == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("invalid to %q: %w", to, err)
+		}
+		e.To = append(e.To, to)
+	}
+	if len(e.To) == 0 {
+		return nil, fmt.Errorf("missing to")
+	}
+	return e, nil
+}
+
+// Send mails event to every recipient. net/smtp takes no context, so a
+// stuck server holds the delivery goroutine until it gives up.
+func (e *Email) Send(_ context.Context, event Event) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, _ := net.SplitHostPort(e.Addr)
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	return smtp.SendMail(e.Addr, auth, addressOnly(e.From), addressesOnly(e.To), e.message(event))
+}
+
+// message formats event as a plain text email
+func (e *Email) message(event Event) []byte {
+	tag := "binaryDeploy"
+	if event.InstanceName != "" {
+		tag += " " + event.InstanceName
+	}
+	subject := fmt.Sprintf("[%s] %s: %s", tag, strings.ToUpper(event.Severity), event.Type)
+	if event.App != "" {
+		subject += " (" + event.App + ")"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", event.Timestamp.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(Text(event), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// addressOnly strips the display name from "Name <user@example.com>"
+func addressOnly(address string) string {
+	if a, err := mail.ParseAddress(address); err == nil {
+		return a.Address
+	}
+	return address
+}
+
+func addressesOnly(addresses []string) []string {
+	out := make([]string, len(addresses))
+	for i, address := range addresses {
+		out[i] = addressOnly(address)
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+// Package notify delivers events operators should hear about to chat
+// services, email and webhooks. Providers are looked up by type in a
+// registry, so new ones can be added without touching the dispatch code.
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"binaryDeploy/schedule"
+)
+
+// Event types sent to notification providers
+const (
+	EventScheduledRestart       = "scheduled_restart"
+	EventScheduledRestartFailed = "scheduled_restart_failed"
+	EventProcessCrashed         = "process_crashed"
+	EventDeploymentStarted      = "deployment_started"
+	EventDeploymentSlow         = "deployment_slow"
+	EventDeploymentFailed       = "deployment_failed"
+	EventDeploymentSucceeded    = "deployment_succeeded"
+	EventSelfUpdateStarted      = "self_update_started"
+	EventSelfUpdateSucceeded    = "self_update_succeeded"
+	EventSelfUpdateFailed       = "self_update_failed"
+	EventDependencyDown         = "dependency_down"
+	EventDependencyUp           = "dependency_up"
+	EventClientBanned           = "client_banned"
+	EventUptimeReport           = "uptime_report"
+	EventLimitExceeded          = "limit_exceeded"
+	EventConfigApplied          = "config_applied"
+	EventConfigRejected         = "config_rejected"
+)
+
+// Event severities, from least to most severe
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityError    = "error"
+	SeverityCritical = "critical"
+)
+
+// Severities lists the valid severities in increasing order
+var Severities = []string{SeverityInfo, SeverityWarning, SeverityError, SeverityCritical}
+
+// defaultSeverities is the severity of event types that don't set one
+var defaultSeverities = map[string]string{
+	EventScheduledRestartFailed: SeverityError,
+	EventProcessCrashed:         SeverityCritical,
+	EventDeploymentSlow:         SeverityWarning,
+	EventDeploymentFailed:       SeverityError,
+	EventSelfUpdateFailed:       SeverityError,
+	EventDependencyDown:         SeverityWarning,
+	EventClientBanned:           SeverityWarning,
+	EventLimitExceeded:          SeverityWarning,
+	EventConfigRejected:         SeverityError,
+}
+
+// Event describes something operators should hear about
+type Event struct {
+	Type          string                 `json:"type"`
+	Severity      string                 `json:"severity"`
+	App           string                 `json:"app,omitempty"`
+	InstanceName  string                 `json:"instance_name,omitempty"` // The binaryDeploy server the event comes from
+	Message       string                 `json:"message"`
+	Fields        map[string]interface{} `json:"fields,omitempty"`
+	CorrelationID string                 `json:"correlation_id,omitempty"` // Ties the event to a deployment's logs
+	Timestamp     time.Time              `json:"timestamp"`
+}
+
+// Notifier delivers events to one destination
+type Notifier interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// Route sends the events matching its filters to a Notifier
+type Route struct {
+	Name        string // Identifies the route in logs
+	Notifier    Notifier
+	Events      []string // Event types delivered; empty delivers all
+	MinSeverity string   // Least severe event delivered; empty delivers all
+
+	// During quiet hours only events at least as severe as
+	// QuietMinSeverity are delivered; the rest are dropped
+	QuietHours       []schedule.Window
+	QuietMinSeverity string
+}
+
+// Matches reports whether event should be delivered on the route
+func (r Route) Matches(event Event) bool {
+	if len(r.Events) > 0 && !slices.Contains(r.Events, event.Type) {
+		return false
+	}
+	if r.MinSeverity != "" && severityRank(event.Severity) < severityRank(r.MinSeverity) {
+		return false
+	}
+	return !r.Quiet(event.Timestamp) || severityRank(event.Severity) >= severityRank(r.QuietMinSeverity)
+}
+
+// Quiet reports whether t falls in the route's quiet hours
+func (r Route) Quiet(t time.Time) bool {
+	for _, w := range r.QuietHours {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendTimeout bounds each delivery
+const sendTimeout = 30 * time.Second
+
+// Dispatcher fans events out to the configured routes
+type Dispatcher struct {
+	routes []Route
+
+	// InstanceName is set on events that don't name their server, so alerts
+	// from several servers can be told apart in a shared channel
+	InstanceName string
+
+	sending sync.WaitGroup
+}
+
+// NewDispatcher creates a Dispatcher for routes. A Dispatcher with no routes
+// is valid and simply drops events.
+func NewDispatcher(routes ...Route) *Dispatcher {
+	return &Dispatcher{routes: routes}
+}
+
+// Notify sends the event to every matching route in the background
+func (d *Dispatcher) Notify(event Event) {
+	if d == nil || len(d.routes) == 0 {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Severity == "" {
+		event.Severity = DefaultSeverity(event.Type)
+	}
+	if event.InstanceName == "" {
+		event.InstanceName = d.InstanceName
+	}
+
+	for _, route := range d.routes {
+		if !route.Matches(event) {
+			if route.Quiet(event.Timestamp) {
+				slog.Debug("Notification held back by quiet hours", "type", event.Type, "notifier", route.Name)
+			}
+			continue
+		}
+		d.sending.Add(1)
+		go func(route Route) {
+			defer d.sending.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := route.Notifier.Send(ctx, event); err != nil {
+				slog.Warn("Failed to deliver notification", "type", event.Type, "notifier", route.Name, "error", err)
+			}
+		}(route)
+	}
+}
+
+// Wait blocks until the notifications being sent have been delivered or
+// ctx is done, so they aren't lost when binaryDeploy exits
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		d.sending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DefaultSeverity is the severity of eventType when the event doesn't set one
+func DefaultSeverity(eventType string) string {
+	if severity, ok := defaultSeverities[eventType]; ok {
+		return severity
+	}
+	return SeverityInfo
+}
+
+// severityRank orders severities; unknown ones rank as info
+func severityRank(severity string) int {
+	return max(slices.Index(Severities, severity), 0)
+}
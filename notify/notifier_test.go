@@ -0,0 +1,312 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type captured struct {
+	path string
+	body map[string]interface{}
+}
+
+func captureServer(t *testing.T, got *[]captured) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := captured{path: r.URL.Path}
+		json.NewDecoder(r.Body).Decode(&c.body)
+		*got = append(*got, c)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// recorder is a custom provider collecting the events it is sent
+type recorder struct {
+	events chan Event
+}
+
+func (r *recorder) Send(_ context.Context, event Event) error {
+	r.events <- event
+	return nil
+}
+
+func TestRegister_CustomProvider(t *testing.T) {
+	rec := &recorder{events: make(chan Event, 10)}
+	Register("test-recorder", func(options map[string]string) (Notifier, error) {
+		if options["channel"] != "ops" {
+			t.Errorf("expected provider options without routing keys, got %v", options)
+		}
+		if _, ok := options["min_severity"]; ok {
+			t.Errorf("expected min_severity to be consumed by the route, got %v", options)
+		}
+		return rec, nil
+	})
+
+	route, err := NewRoute("ops", map[string]string{"type": "test-recorder", "channel": "ops", "min_severity": "error"})
+	if err != nil {
+		t.Fatalf("NewRoute failed: %v", err)
+	}
+	d := NewDispatcher(route)
+	d.Notify(Event{Type: EventDeploymentSucceeded, Message: "deployed"})
+	d.Notify(Event{Type: EventDeploymentFailed, Message: "failed"})
+
+	select {
+	case event := <-rec.events:
+		if event.Type != EventDeploymentFailed || event.Severity != SeverityError || event.Timestamp.IsZero() {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the failure to be delivered")
+	}
+	select {
+	case event := <-rec.events:
+		t.Errorf("expected info events to be filtered out, got %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNewRoute_Errors(t *testing.T) {
+	for name, options := range map[string]map[string]string{
+		"unknown type":     {"type": "carrier-pigeon"},
+		"missing type":     {},
+		"missing url":      {"type": "slack"},
+		"bad severity":     {"type": "webhook", "url": "http://example.com", "min_severity": "loud"},
+		"missing chat_id":  {"type": "telegram", "bot_token": "123:abc"},
+		"bad recipient":    {"type": "email", "smtp_host": "mail", "from": "bd@example.com", "to": "not an address"},
+		"missing to":       {"type": "email", "smtp_host": "mail", "from": "bd@example.com"},
+		"missing smtphost": {"type": "email", "from": "bd@example.com", "to": "ops@example.com"},
+		"bad quiet hours":  {"type": "webhook", "url": "http://example.com", "quiet_hours": "late"},
+		"bad quiet level":  {"type": "webhook", "url": "http://example.com", "quiet_min_severity": "hush"},
+	} {
+		if _, err := NewRoute("ops", options); err == nil {
+			t.Errorf("%s: expected an error", name)
+		} else if !strings.HasPrefix(err.Error(), "notifiers.ops: ") {
+			t.Errorf("%s: expected the error to name the notifier, got %v", name, err)
+		}
+	}
+}
+
+func TestRoute_Matches(t *testing.T) {
+	route := Route{Events: []string{EventDeploymentFailed, EventProcessCrashed}, MinSeverity: SeverityCritical}
+	if route.Matches(Event{Type: EventDeploymentSlow, Severity: SeverityCritical}) {
+		t.Error("expected other event types to be filtered out")
+	}
+	if route.Matches(Event{Type: EventDeploymentFailed, Severity: SeverityError}) {
+		t.Error("expected less severe events to be filtered out")
+	}
+	if !route.Matches(Event{Type: EventProcessCrashed, Severity: SeverityCritical}) {
+		t.Error("expected a matching event to be delivered")
+	}
+	if !(Route{}).Matches(Event{Type: "anything"}) {
+		t.Error("expected a route without filters to deliver everything")
+	}
+}
+
+func TestRoute_QuietHours(t *testing.T) {
+	route, err := NewRoute("deploys", map[string]string{"type": "webhook", "url": "http://example.com",
+		"quiet_hours": "22:00-07:00; Sat 00:00-Mon 00:00"})
+	if err != nil {
+		t.Fatalf("NewRoute failed: %v", err)
+	}
+
+	night := time.Date(2024, 3, 6, 23, 30, 0, 0, time.Local) // Wednesday
+	weekend := time.Date(2024, 3, 9, 12, 0, 0, 0, time.Local)
+	day := time.Date(2024, 3, 6, 12, 0, 0, 0, time.Local)
+	for _, tc := range []struct {
+		at       time.Time
+		severity string
+		want     bool
+	}{
+		{day, SeverityInfo, true},
+		{night, SeverityInfo, false},
+		{night, SeverityError, false},
+		{night, SeverityCritical, true},
+		{weekend, SeverityWarning, false},
+	} {
+		event := Event{Type: EventDeploymentFailed, Severity: tc.severity, Timestamp: tc.at}
+		if got := route.Matches(event); got != tc.want {
+			t.Errorf("%s at %s: expected %v, got %v", tc.severity, tc.at.Format("Mon 15:04"), tc.want, got)
+		}
+	}
+
+	route, _ = NewRoute("deploys", map[string]string{"type": "webhook", "url": "http://example.com",
+		"quiet_hours": "22:00-07:00", "quiet_min_severity": "error"})
+	if !route.Matches(Event{Severity: SeverityError, Timestamp: night}) {
+		t.Error("expected quiet_min_severity to let errors through at night")
+	}
+}
+
+func TestProviders_Payloads(t *testing.T) {
+	var got []captured
+	srv := captureServer(t, &got)
+	event := Event{Type: EventDeploymentFailed, Severity: SeverityError, App: "myapp", Message: "Deployment failed",
+		Fields: map[string]interface{}{"commit": "1a2b3c4d"}, CorrelationID: "c0ffee"}
+
+	for _, options := range []map[string]string{
+		{"type": "webhook", "url": srv.URL + "/webhook"},
+		{"type": "slack", "url": srv.URL + "/slack", "channel": "#deploys"},
+		{"type": "discord", "url": srv.URL + "/discord"},
+		{"type": "telegram", "api_url": srv.URL, "bot_token": "123:abc", "chat_id": "-100"},
+	} {
+		route, err := NewRoute(options["type"], options)
+		if err != nil {
+			t.Fatalf("NewRoute failed: %v", err)
+		}
+		if err := route.Notifier.Send(context.Background(), event); err != nil {
+			t.Fatalf("%s: Send failed: %v", options["type"], err)
+		}
+	}
+
+	if len(got) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(got))
+	}
+	if got[0].body["type"] != EventDeploymentFailed || got[0].body["correlation_id"] != "c0ffee" {
+		t.Errorf("unexpected webhook payload %v", got[0].body)
+	}
+	text := "[ERROR] myapp: Deployment failed\ncommit: 1a2b3c4d\ncorrelation_id: c0ffee"
+	if got[1].body["text"] != text || got[1].body["channel"] != "#deploys" {
+		t.Errorf("unexpected slack payload %v", got[1].body)
+	}
+	if got[2].body["content"] != text {
+		t.Errorf("unexpected discord payload %v", got[2].body)
+	}
+	if got[3].path != "/bot123:abc/sendMessage" || got[3].body["chat_id"] != "-100" || got[3].body["text"] != text {
+		t.Errorf("unexpected telegram request %s %v", got[3].path, got[3].body)
+	}
+}
+
+func TestWebhook_Signature(t *testing.T) {
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	t.Cleanup(srv.Close)
+
+	w := &Webhook{URL: srv.URL}
+	if err := w.Send(context.Background(), Event{Type: EventDeploymentSucceeded}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if signature != "" {
+		t.Errorf("expected no signature without a secret, got %q", signature)
+	}
+
+	w.Secret = "s3cr3t"
+	if err := w.Send(context.Background(), Event{Type: EventDeploymentSucceeded}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("expected signature %q, got %q", want, signature)
+	}
+}
+
+func TestTelegram_RedactsTokenFromErrors(t *testing.T) {
+	tg := &Telegram{URL: "http://127.0.0.1:1", BotToken: "123:s3cr3t", ChatID: "1"}
+	err := tg.Send(context.Background(), Event{Type: EventDeploymentFailed})
+	if err == nil || strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("expected an error without the token, got %v", err)
+	}
+}
+
+func TestEmail_Message(t *testing.T) {
+	n, err := NewEmail(map[string]string{"smtp_host": "mail.example.com", "from": "binaryDeploy <bd@example.com>",
+		"to": "ops@example.com, oncall@example.com"})
+	if err != nil {
+		t.Fatalf("NewEmail failed: %v", err)
+	}
+	e := n.(*Email)
+	if e.Addr != "mail.example.com:587" {
+		t.Errorf("expected the submission port by default, got %s", e.Addr)
+	}
+	msg := string(e.message(Event{Type: EventProcessCrashed, Severity: SeverityCritical, App: "myapp",
+		Message: "myapp crashed", Timestamp: time.Now()}))
+	for _, want := range []string{
+		"To: ops@example.com, oncall@example.com\r\n",
+		"Subject: [binaryDeploy] CRITICAL: process_crashed (myapp)\r\n",
+		"\r\n\r\n[CRITICAL] myapp: myapp crashed\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected message to contain %q, got:\n%s", want, msg)
+		}
+	}
+}
+
+func TestInstanceName(t *testing.T) {
+	event := Event{Type: EventProcessCrashed, Severity: SeverityCritical, App: "myapp", InstanceName: "web-01",
+		Message: "myapp crashed", Timestamp: time.Now()}
+	if got := Text(event); got != "[CRITICAL] myapp@web-01: myapp crashed" {
+		t.Errorf("unexpected text %q", got)
+	}
+	event.App = ""
+	if got := Text(event); got != "[CRITICAL] web-01: myapp crashed" {
+		t.Errorf("unexpected text without an app %q", got)
+	}
+
+	e := &Email{From: "bd@example.com", To: []string{"ops@example.com"}}
+	if msg := string(e.message(event)); !strings.Contains(msg, "Subject: [binaryDeploy web-01] CRITICAL: process_crashed\r\n") {
+		t.Errorf("expected the instance in the subject, got:\n%s", msg)
+	}
+
+	rec := &recorder{events: make(chan Event, 1)}
+	d := NewDispatcher(Route{Name: "recorder", Notifier: rec})
+	d.InstanceName = "web-02"
+	d.Notify(Event{Type: EventDeploymentSucceeded})
+	select {
+	case sent := <-rec.events:
+		if sent.InstanceName != "web-02" {
+			t.Errorf("expected the dispatcher's instance on the event, got %q", sent.InstanceName)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+}
+
+// gate is a provider whose deliveries block until it is opened
+type gate struct {
+	open chan struct{}
+}
+
+func (g *gate) Send(ctx context.Context, _ Event) error {
+	select {
+	case <-g.open:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestDispatcher_Wait(t *testing.T) {
+	g := &gate{open: make(chan struct{})}
+	d := NewDispatcher(Route{Name: "gate", Notifier: g})
+	d.Notify(Event{Type: EventSelfUpdateSucceeded})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Wait(ctx); err == nil {
+		t.Fatal("expected Wait to time out while the notification is being sent")
+	}
+
+	close(g.open)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Wait(ctx); err != nil {
+		t.Fatalf("expected Wait to return once the notification was sent: %v", err)
+	}
+
+	var nilDispatcher *Dispatcher
+	if err := nilDispatcher.Wait(ctx); err != nil {
+		t.Errorf("expected a nil dispatcher to have nothing to wait for, got %v", err)
+	}
+}
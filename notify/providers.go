@@ -0,0 +1,218 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("webhook", NewWebhook)
+	Register("slack", NewSlack)
+	Register("discord", NewDiscord)
+	Register("telegram", NewTelegram)
+	Register("email", NewEmail)
+}
+
+// client is shared by the HTTP providers
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Webhook posts the event as JSON to a URL
+type Webhook struct {
+	URL    string
+	Secret string // Signs the body in SignatureHeader when set
+}
+
+// NewWebhook creates a webhook provider; options: url, secret
+func NewWebhook(options map[string]string) (Notifier, error) {
+	url, err := required(options, "url")
+	if err != nil {
+		return nil, err
+	}
+	return &Webhook{URL: url, Secret: options["secret"]}, nil
+}
+
+// Send posts event to the URL
+func (w *Webhook) Send(ctx context.Context, event Event) error {
+	return postSignedJSON(ctx, w.URL, w.Secret, event)
+}
+
+// Slack posts to a Slack (or Mattermost) incoming webhook
+type Slack struct {
+	URL      string
+	Channel  string // Overrides the webhook's default channel
+	Username string
+}
+
+// NewSlack creates a Slack provider; options: url, channel, username
+func NewSlack(options map[string]string) (Notifier, error) {
+	url, err := required(options, "url")
+	if err != nil {
+		return nil, err
+	}
+	return &Slack{URL: url, Channel: options["channel"], Username: options["username"]}, nil
+}
+
+type slackMessage struct {
+	Text     string `json:"text"`
+	Channel  string `json:"channel,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// Send posts event as a message
+func (s *Slack) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, s.URL, slackMessage{Text: Text(event), Channel: s.Channel, Username: s.Username})
+}
+
+// Discord posts to a Discord channel webhook
+type Discord struct {
+	URL      string
+	Username string
+}
+
+// NewDiscord creates a Discord provider; options: url, username
+func NewDiscord(options map[string]string) (Notifier, error) {
+	url, err := required(options, "url")
+	if err != nil {
+		return nil, err
+	}
+	return &Discord{URL: url, Username: options["username"]}, nil
+}
+
+type discordMessage struct {
+	Content  string `json:"content"`
+	Username string `json:"username,omitempty"`
+}
+
+// discordMaxContent is the longest message Discord accepts
+const discordMaxContent = 2000
+
+// Send posts event as a message
+func (d *Discord) Send(ctx context.Context, event Event) error {
+	return postJSON(ctx, d.URL, discordMessage{Content: truncate(Text(event), discordMaxContent), Username: d.Username})
+}
+
+// DefaultTelegramURL is the Telegram Bot API
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// Telegram sends messages to a chat through a bot
+type Telegram struct {
+	URL      string
+	BotToken string
+	ChatID   string
+}
+
+// NewTelegram creates a Telegram provider; options: bot_token, chat_id and
+// api_url (DefaultTelegramURL when empty)
+func NewTelegram(options map[string]string) (Notifier, error) {
+	token, err := required(options, "bot_token")
+	if err != nil {
+		return nil, err
+	}
+	chatID, err := required(options, "chat_id")
+	if err != nil {
+		return nil, err
+	}
+	url := options["api_url"]
+	if url == "" {
+		url = DefaultTelegramURL
+	}
+	return &Telegram{URL: strings.TrimRight(url, "/"), BotToken: token, ChatID: chatID}, nil
+}
+
+type telegramMessage struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// telegramMaxText is the longest message Telegram accepts
+const telegramMaxText = 4096
+
+// Send sends event as a message
+func (t *Telegram) Send(ctx context.Context, event Event) error {
+	url := t.URL + "/bot" + t.BotToken + "/sendMessage"
+	err := postJSON(ctx, url, telegramMessage{ChatID: t.ChatID, Text: truncate(Text(event), telegramMaxText)})
+	if err != nil {
+		// Errors from the HTTP client quote the URL, token included
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), t.BotToken, "[REDACTED]"))
+	}
+	return nil
+}
+
+// Text renders event for humans: a summary line, naming the app and the
+// server it runs on, followed by its fields
+func Text(event Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s]", strings.ToUpper(event.Severity))
+	switch {
+	case event.App != "" && event.InstanceName != "":
+		fmt.Fprintf(&b, " %s@%s:", event.App, event.InstanceName)
+	case event.App != "":
+		fmt.Fprintf(&b, " %s:", event.App)
+	case event.InstanceName != "":
+		fmt.Fprintf(&b, " %s:", event.InstanceName)
+	}
+	b.WriteString(" " + event.Message)
+
+	keys := make([]string, 0, len(event.Fields))
+	for key := range event.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\n%s: %v", key, event.Fields[key])
+	}
+	if event.CorrelationID != "" {
+		fmt.Fprintf(&b, "\ncorrelation_id: %s", event.CorrelationID)
+	}
+	return b.String()
+}
+
+// postJSON posts payload to url, failing on non-2xx responses
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	return postSignedJSON(ctx, url, "", payload)
+}
+
+// postSignedJSON is postJSON with the body signed with secret, unless it
+// is empty
+func postSignedJSON(ctx context.Context, url, secret string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = strings.ToValidUTF8(s[:n-3], "")
+	return s + "..."
+}
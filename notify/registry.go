@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"binaryDeploy/schedule"
+)
+
+// Factory builds a Notifier from its options, the notifiers.<name>.<option>
+// entries of deploy.config other than the routing options NewRoute handles
+type Factory func(options map[string]string) (Notifier, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a provider available as notifiers.<name>.type=typ. Call it
+// from an init function to embed a custom provider; registering a type twice
+// panics.
+func Register(typ string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if factory == nil {
+		panic("notify: Register factory is nil")
+	}
+	if _, dup := registry[typ]; dup {
+		panic("notify: Register called twice for provider " + typ)
+	}
+	registry[typ] = factory
+}
+
+// Types returns the registered provider types, sorted
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New builds a provider of type typ
+func New(typ string, options map[string]string) (Notifier, error) {
+	registryMu.RLock()
+	factory, ok := registry[typ]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q: expected one of %s", typ, strings.Join(Types(), ", "))
+	}
+	return factory(options)
+}
+
+// routeOptions are the notifiers.<name>.<option> entries NewRoute handles
+// itself rather than passing to the provider
+var routeOptions = map[string]bool{
+	"type": true, "events": true, "min_severity": true, "quiet_hours": true, "quiet_min_severity": true,
+}
+
+// NewRoute builds the route configured as notifiers.<name>.<option>:
+// type selects the provider; events, min_severity, quiet_hours and
+// quiet_min_severity filter what it receives; the remaining options go to
+// the provider
+func NewRoute(name string, options map[string]string) (Route, error) {
+	route := Route{Name: name, MinSeverity: options["min_severity"]}
+	if events := options["events"]; events != "" {
+		for _, event := range strings.Split(events, ",") {
+			if event = strings.TrimSpace(event); event != "" {
+				route.Events = append(route.Events, event)
+			}
+		}
+	}
+	for _, key := range []string{"min_severity", "quiet_min_severity"} {
+		if severity := options[key]; severity != "" && !slices.Contains(Severities, severity) {
+			return Route{}, fmt.Errorf("notifiers.%s: invalid %s %q: expected one of %s",
+				name, key, severity, strings.Join(Severities, ", "))
+		}
+	}
+	if spec := options["quiet_hours"]; spec != "" {
+		windows, err := schedule.ParseWindows(spec)
+		if err != nil {
+			return Route{}, fmt.Errorf("notifiers.%s: invalid quiet_hours: %w", name, err)
+		}
+		route.QuietHours = windows
+		route.QuietMinSeverity = options["quiet_min_severity"]
+		if route.QuietMinSeverity == "" {
+			route.QuietMinSeverity = SeverityCritical
+		}
+	}
+
+	providerOptions := make(map[string]string, len(options))
+	for key, value := range options {
+		if !routeOptions[key] {
+			providerOptions[key] = value
+		}
+	}
+	if options["type"] == "" {
+		return Route{}, fmt.Errorf("notifiers.%s: missing type", name)
+	}
+	n, err := New(options["type"], providerOptions)
+	if err != nil {
+		return Route{}, fmt.Errorf("notifiers.%s: %w", name, err)
+	}
+	route.Notifier = n
+	return route, nil
+}
+
+// required returns options[key], or an error naming it when it is empty
+func required(options map[string]string, key string) (string, error) {
+	if options[key] == "" {
+		return "", fmt.Errorf("missing %s", key)
+	}
+	return options[key], nil
+}
+
+// SecretOptions are provider options holding credentials, redacted from
+// logs and post-mortem bundles. Webhook URLs count: Slack and Discord
+// embed the token in them.
+var SecretOptions = []string{"url", "bot_token", "password", "secret"}
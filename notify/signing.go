@@ -0,0 +1,19 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignatureHeader carries the signature of a signed outbound webhook, in
+// the format GitHub uses for X-Hub-Signature-256
+const SignatureHeader = "X-BinaryDeploy-Signature-256"
+
+// Sign returns the SignatureHeader value for body: "sha256=" followed by
+// the hex HMAC-SHA256 of the exact bytes sent, keyed with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
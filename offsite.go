@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"binaryDeploy/artifacts"
+	"binaryDeploy/backup"
+	"binaryDeploy/logrotate"
+)
+
+// startOffsiteBackups periodically copies the deployment history, the
+// configured backup_paths and rotated logs to offsite_store, so losing the
+// host's disk doesn't lose the audit trail. It returns immediately when no
+// offsite_store is configured.
+func startOffsiteBackups() {
+	if appConfig().OffsiteStore == "" {
+		return
+	}
+
+	backend, err := newObjectBackend(appConfig().OffsiteStore)
+	if err != nil {
+		slog.Error("Invalid offsite_store, offsite backups disabled", "error", err)
+		return
+	}
+
+	interval := time.Duration(appConfig().OffsiteInterval) * time.Second
+	slog.Info("Offsite backups enabled", "store", backend, "interval", interval.String())
+
+	go func() {
+		for {
+			runOffsiteBackup(backend, time.Now().UTC())
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// runOffsiteBackup uploads everything once. Each part is attempted even if an
+// earlier one fails; failures are logged and retried on the next run.
+func runOffsiteBackup(backend artifacts.Backend, now time.Time) {
+	if err := exportHistory(backend, now); err != nil {
+		slog.Error("Failed to export deployment history", "error", err)
+	}
+	if err := backupData(backend, now); err != nil {
+		slog.Error("Failed to back up data", "paths", appConfig().BackupPaths, "error", err)
+	}
+	if err := shipRotatedLogs(backend); err != nil {
+		slog.Error("Failed to upload rotated logs", "error", err)
+	}
+}
+
+// exportHistory uploads the deployment history as history/<date>/deployments.jsonl.
+// Each day keeps its own copy so a damaged local file can't overwrite every
+// earlier export.
+func exportHistory(backend artifacts.Backend, now time.Time) error {
+	if deployHistory == nil {
+		return nil
+	}
+	data, err := deployHistory.Export()
+	if err != nil || len(data) == 0 {
+		return err
+	}
+
+	key := "history/" + now.Format("2006-01-02") + "/deployments.jsonl"
+	if err := backend.Put(key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+	slog.Debug("Exported deployment history", "key", key, "bytes", len(data))
+	return nil
+}
+
+// backupData archives backup_paths to backups/<timestamp>.tar.gz
+func backupData(backend artifacts.Backend, now time.Time) error {
+	if len(appConfig().BackupPaths) == 0 {
+		return nil
+	}
+
+	tmp, err := createTempFile("backup", ".tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := backup.Archive(tmp, appConfig().BackupPaths); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("backups/%s.tar.gz", now.Format("20060102T150405Z"))
+	if err := backend.Put(key, tmp, size); err != nil {
+		return err
+	}
+	slog.Info("Uploaded data backup", "key", key, "bytes", size)
+	return nil
+}
+
+// shipRotatedLogs uploads rotated log files, including app_log_file's, to
+// logs/ and removes each local copy once it is stored
+func shipRotatedLogs(backend artifacts.Backend) error {
+	rotated, err := logrotate.Rotated(appConfig().LogFile)
+	if err != nil {
+		return err
+	}
+	if appConfig().AppLogFile != "" {
+		appRotated, err := logrotate.Rotated(appConfig().AppLogFile)
+		if err != nil {
+			return err
+		}
+		rotated = append(rotated, appRotated...)
+	}
+
+	for _, path := range rotated {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err == nil {
+			err = backend.Put("logs/"+filepath.Base(path), f, info.Size())
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("uploading %s: %w", path, err)
+		}
+
+		if err := os.Remove(path); err != nil {
+			slog.Warn("Failed to remove uploaded log file", "path", path, "error", err)
+		}
+		slog.Info("Uploaded rotated log", "path", path)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"binaryDeploy/notify"
+)
+
+// pauseRetryInterval is how often the verification webhook is retried
+const pauseRetryInterval = 5 * time.Second
+
+// pauseEnabled reports whether the pipeline should stop the old version and
+// pause before starting the new one
+func pauseEnabled() bool {
+	return appConfig().PauseBeforeStart > 0 || appConfig().PauseWebhookURL != ""
+}
+
+// pauseBeforeStart waits for the configured delay and, if set, for the
+// verification webhook to confirm that the new version may be started. It is
+// called after the old process has been stopped. Without a confirmation the
+// deployment fails, unless pause_start_on_timeout is set.
+func pauseBeforeStart(repoURL string) error {
+	if appConfig().PauseBeforeStart > 0 {
+		delay := time.Duration(appConfig().PauseBeforeStart) * time.Second
+		slog.Info("Pausing before starting new version", "app", appConfig().AppName, "delay", delay.String())
+		time.Sleep(delay)
+	}
+
+	if appConfig().PauseWebhookURL == "" {
+		return nil
+	}
+
+	timeout := time.Duration(appConfig().PauseTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	slog.Info("Waiting for verification webhook before starting new version",
+		"app", appConfig().AppName,
+		"url", appConfig().PauseWebhookURL,
+		"timeout", timeout.String())
+
+	if err := waitForPauseConfirmation(ctx, repoURL); err != nil {
+		if !appConfig().PauseStartOnTimeout {
+			slog.Error("Verification webhook did not confirm, not starting the new version", "app", appConfig().AppName, "error", err)
+			return fmt.Errorf("verification webhook did not confirm: %w", err)
+		}
+		slog.Warn("Verification webhook did not confirm, starting new version anyway", "app", appConfig().AppName, "error", err)
+		return nil
+	}
+
+	slog.Info("Verification webhook confirmed, starting new version", "app", appConfig().AppName)
+	return nil
+}
+
+// waitForPauseConfirmation calls the verification webhook until it answers 2xx
+func waitForPauseConfirmation(ctx context.Context, repoURL string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event":     "pause_before_start",
+		"app":       appConfig().AppName,
+		"repo_url":  repoURL,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, appConfig().PauseWebhookURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if appConfig().NotifySecret != "" {
+			req.Header.Set(notify.SignatureHeader, notify.Sign(appConfig().NotifySecret, body))
+		}
+
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		slog.Info("Verification webhook not confirmed yet", "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("no confirmation after %d attempts: %w", attempt, err)
+		case <-time.After(pauseRetryInterval):
+		}
+	}
+}
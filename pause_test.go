@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func TestPauseBeforeStart_TimeoutAbortsByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp", PauseWebhookURL: server.URL, PauseTimeout: 1})
+	if err := pauseBeforeStart("https://git.example.com/team/app.git"); err == nil {
+		t.Error("expected the deployment to be aborted when the webhook doesn't confirm")
+	}
+
+	appConfig().PauseStartOnTimeout = true
+	if err := pauseBeforeStart("https://git.example.com/team/app.git"); err != nil {
+		t.Errorf("expected pause_start_on_timeout to start anyway, got %v", err)
+	}
+}
+
+func TestPauseBeforeStart_Confirmed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp", PauseWebhookURL: server.URL, PauseTimeout: 1})
+	if err := pauseBeforeStart("https://git.example.com/team/app.git"); err != nil {
+		t.Errorf("expected a confirmed pause to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+// Package portalloc hands out blocks of ports from a range so applications
+// sharing a host never collide. Leases are kept in a file, so an owner gets
+// the same ports back after a restart.
+package portalloc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Range is the ports First through Last. The zero Range is empty.
+type Range struct {
+	First int `json:"first"`
+	Last  int `json:"last"`
+}
+
+// ParseRange reads a range such as 20000-20999, or a single port
+func ParseRange(s string) (Range, error) {
+	first, last, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		last = first
+	}
+	a, errA := strconv.Atoi(strings.TrimSpace(first))
+	b, errB := strconv.Atoi(strings.TrimSpace(last))
+	if errA != nil || errB != nil || a <= 0 || b > 65535 || a > b {
+		return Range{}, fmt.Errorf("invalid port range %q: expected first-last, e.g. 20000-20999", s)
+	}
+	return Range{First: a, Last: b}, nil
+}
+
+func (r Range) String() string {
+	if r.First == r.Last {
+		return strconv.Itoa(r.First)
+	}
+	return fmt.Sprintf("%d-%d", r.First, r.Last)
+}
+
+// Size is the number of ports in r
+func (r Range) Size() int {
+	if r.First == 0 {
+		return 0
+	}
+	return r.Last - r.First + 1
+}
+
+// Contains reports whether port is in r
+func (r Range) Contains(port int) bool {
+	return r.Size() > 0 && port >= r.First && port <= r.Last
+}
+
+// Overlaps reports whether r and other share a port
+func (r Range) Overlaps(other Range) bool {
+	return r.Size() > 0 && other.Size() > 0 && r.First <= other.Last && other.First <= r.Last
+}
+
+// Allocator leases contiguous blocks of a range to named owners
+type Allocator struct {
+	pool Range
+	path string
+
+	// free reports whether nothing else on the host listens on port, so a
+	// new lease doesn't collide with a process binaryDeploy doesn't manage
+	free func(port int) bool
+
+	mu       sync.Mutex
+	leases   map[string]Range
+	excluded map[int]bool
+}
+
+// Open returns an allocator for pool that keeps its leases in the file at
+// path. Leases no longer inside pool are dropped.
+func Open(pool Range, path string) (*Allocator, error) {
+	if pool.Size() == 0 {
+		return nil, errors.New("empty port range")
+	}
+	a := &Allocator{pool: pool, path: path, free: portFree, leases: map[string]Range{}, excluded: map[int]bool{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		var leases map[string]Range
+		if err := json.Unmarshal(data, &leases); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for owner, r := range leases {
+			if pool.Contains(r.First) && pool.Contains(r.Last) && r.Size() > 0 {
+				a.leases[owner] = r
+			}
+		}
+	}
+	return a, nil
+}
+
+// Exclude keeps ports out of new leases, e.g. the ones binaryDeploy itself
+// listens on
+func (a *Allocator) Exclude(ports ...int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, port := range ports {
+		a.excluded[port] = true
+	}
+}
+
+// Allocate returns owner's lease of size ports, keeping its existing one
+// when it has the right size. Otherwise the first free block of the range
+// is leased, preferring to keep the lease's first port.
+func (a *Allocator) Allocate(owner string, size int) (Range, error) {
+	if size <= 0 {
+		return Range{}, fmt.Errorf("invalid number of ports %d", size)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	previous, ok := a.leases[owner]
+	if ok && previous.Size() == size {
+		return previous, nil
+	}
+	delete(a.leases, owner)
+
+	starts := []int{}
+	if ok {
+		starts = append(starts, previous.First)
+	}
+	for port := a.pool.First; port+size-1 <= a.pool.Last; port++ {
+		starts = append(starts, port)
+	}
+	for _, first := range starts {
+		block := Range{First: first, Last: first + size - 1}
+		if a.available(block) {
+			a.leases[owner] = block
+			if err := a.save(); err != nil {
+				delete(a.leases, owner)
+				return Range{}, err
+			}
+			return block, nil
+		}
+	}
+	if ok {
+		a.leases[owner] = previous
+	}
+	return Range{}, fmt.Errorf("no %d free consecutive ports left in %s for %s", size, a.pool, owner)
+}
+
+// available reports whether block lies in the range and no port of it is
+// leased, excluded or in use
+func (a *Allocator) available(block Range) bool {
+	if !a.pool.Contains(block.First) || !a.pool.Contains(block.Last) {
+		return false
+	}
+	for _, lease := range a.leases {
+		if lease.Overlaps(block) {
+			return false
+		}
+	}
+	for port := block.First; port <= block.Last; port++ {
+		if a.excluded[port] || !a.free(port) {
+			return false
+		}
+	}
+	return true
+}
+
+// Retain releases the leases of every owner not in owners
+func (a *Allocator) Retain(owners []string) error {
+	keep := map[string]bool{}
+	for _, owner := range owners {
+		keep[owner] = true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	changed := false
+	for owner := range a.leases {
+		if !keep[owner] {
+			delete(a.leases, owner)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return a.save()
+}
+
+func (a *Allocator) save() error {
+	data, err := json.MarshalIndent(a.leases, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := a.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.path)
+}
+
+// portFree reports whether port can be listened on, on any interface
+func portFree(port int) bool {
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
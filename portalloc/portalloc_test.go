@@ -0,0 +1,94 @@
+package portalloc
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	for input, want := range map[string]Range{
+		"20000-20999":  {20000, 20999},
+		" 8000 - 8001": {8000, 8001},
+		"9000":         {9000, 9000},
+	} {
+		got, err := ParseRange(input)
+		if err != nil || got != want {
+			t.Errorf("ParseRange(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "abc", "2000-1000", "0-10", "65000-70000"} {
+		if _, err := ParseRange(input); err == nil {
+			t.Errorf("ParseRange(%q): expected an error", input)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	a, err := Open(Range{20000, 20009}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inUse := map[int]bool{20003: true}
+	a.free = func(port int) bool { return !inUse[port] }
+	a.Exclude(20000)
+
+	api, err := a.Allocate("api", 2)
+	if err != nil || api != (Range{20001, 20002}) {
+		t.Fatalf("api got %v, %v", api, err)
+	}
+	// 20003 is taken by another process
+	web, err := a.Allocate("web", 3)
+	if err != nil || web != (Range{20004, 20006}) {
+		t.Fatalf("web got %v, %v", web, err)
+	}
+	if again, _ := a.Allocate("api", 2); again != api {
+		t.Errorf("api moved from %v to %v", api, again)
+	}
+	if _, err := a.Allocate("worker", 4); err == nil {
+		t.Error("expected the range to be exhausted")
+	}
+
+	// Leases survive a restart, even though their ports are in use by then
+	b, err := Open(Range{20000, 20009}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.free = func(int) bool { return false }
+	if got, err := b.Allocate("web", 3); err != nil || got != web {
+		t.Errorf("web after restart got %v, %v; want %v", got, err, web)
+	}
+
+	// Releasing api makes room for a bigger worker
+	b.free = func(int) bool { return true }
+	if err := b.Retain([]string{"web"}); err != nil {
+		t.Fatal(err)
+	}
+	if worker, err := b.Allocate("worker", 3); err != nil || worker != (Range{20000, 20002}) {
+		t.Errorf("worker got %v, %v", worker, err)
+	}
+
+	// A lease that has to grow keeps its first port when it can
+	if grown, err := b.Allocate("web", 4); err != nil || grown != (Range{20004, 20007}) {
+		t.Errorf("grown web got %v, %v", grown, err)
+	}
+}
+
+func TestOpenDropsLeasesOutsideRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	a, _ := Open(Range{20000, 20009}, path)
+	a.free = func(int) bool { return true }
+	a.Allocate("api", 5)
+	a.Allocate("web", 5)
+
+	b, err := Open(Range{20000, 20004}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := b.leases["web"]; ok {
+		t.Error("lease outside the new range was kept")
+	}
+	if _, ok := b.leases["api"]; !ok {
+		t.Error("lease inside the new range was dropped")
+	}
+}
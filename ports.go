@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+
+	"binaryDeploy/config"
+	"binaryDeploy/portalloc"
+)
+
+// allocatedPortsEnv tells an app or tenant instance which application ports
+// the front server or supervisor allocated to it from port_range
+const allocatedPortsEnv = "BINARYDEPLOY_APP_PORTS"
+
+// applyAllocatedPorts moves the application onto the allocated ports: the
+// first is its port, and the next two the blue and green release ports
+// under blue-green deployments. It fails if the application needs more.
+func applyAllocatedPorts(cfg *config.DeployConfig, ports string) error {
+	allocated, err := portalloc.ParseRange(ports)
+	if err != nil {
+		return err
+	}
+	if need := cfg.ApplicationPorts(); need > allocated.Size() {
+		return fmt.Errorf("the application needs %d ports but was allocated %s", need, allocated)
+	}
+	cfg.ApplicationPort = allocated.First
+	if cfg.DeploymentStrategy == config.StrategyBlueGreen {
+		cfg.BlueGreenPorts = []int{allocated.First + 1, allocated.First + 2}
+	}
+	cfg.AllocatedPorts = allocated
+	return nil
+}
+
+// openPortAllocator opens the allocator of a port_range, its leases kept at
+// path, keeping the ports the front server listens on out of it. Leases of
+// owners that are gone are released.
+func openPortAllocator(pool portalloc.Range, path string, listen, owners []string) (*portalloc.Allocator, error) {
+	allocator, err := portalloc.Open(pool, path)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range listen {
+		if _, port, err := net.SplitHostPort(addr); err == nil {
+			if p, err := strconv.Atoi(port); err == nil {
+				allocator.Exclude(p)
+			}
+		}
+	}
+	if err := allocator.Retain(owners); err != nil {
+		return nil, err
+	}
+	slog.Info("Allocating application ports", "range", pool.String(), "leases", path)
+	return allocator, nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"binaryDeploy/backup"
+	"binaryDeploy/history"
+	"binaryDeploy/notify"
+	"binaryDeploy/secrets"
+)
+
+const (
+	maxBuildOutput      = 1 << 20 // Bytes of build output kept for post-mortems
+	postmortemAppLines  = 1000
+	postmortemEvents    = 100
+	postmortemDirectory = "postmortems"
+)
+
+// secretConfigKeys are masked in the config snapshot of a post-mortem bundle
+var secretConfigKeys = map[string]bool{
+	"secret":                 true,
+	"api_token":              true,
+	"generic_webhook_token":  true,
+	"chatops_signing_secret": true,
+	"chatops_token":          true,
+	"pagerduty_routing_key":  true,
+	"opsgenie_api_key":       true,
+	"jira_token":             true,
+	"github_token":           true,
+	"git_token":              true,
+	"chain_token":            true,
+	"peer_token":             true,
+	"s3_access_key":          true,
+	"s3_secret_key":          true,
+	"notify_secret":          true,
+}
+
+// isSecretConfigKey reports whether key holds a credential, including the
+// credential options of notifiers.<name>.<option>
+func isSecretConfigKey(key string) bool {
+	if rest, ok := strings.CutPrefix(key, "notifiers."); ok {
+		_, option, _ := strings.Cut(rest, ".")
+		return slices.Contains(notify.SecretOptions, option)
+	}
+	return secretConfigKeys[key]
+}
+
+// urlCredentials matches user:password@ in URLs such as git remotes
+var urlCredentials = regexp.MustCompile(`://[^/@\s]+@`)
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.max; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns a copy of the kept output
+func (t *tailBuffer) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.buf...)
+}
+
+// runBuildCommand runs the build like runShellCommandInDir, also copying its
+// output to capture
+func runBuildCommand(dir, shellCommand string, capture io.Writer) error {
+	cmd := exec.Command("sh", "-c", shellCommand)
+	cmd.Dir = dir
+	cmd.Stdout = io.MultiWriter(os.Stdout, capture)
+	cmd.Stderr = io.MultiWriter(os.Stderr, capture)
+	return cmd.Run()
+}
+
+// writePostmortemBundle gathers what's needed to debug the failed deployment
+// rec into <deploy_dir>/postmortems/<id>.tar.gz and returns that path
+// relative to deploy_dir
+func writePostmortemBundle(rec history.Record, buildOutput []byte) (string, error) {
+	record, _ := json.MarshalIndent(rec, "", "  ")
+	process, _ := json.MarshalIndent(map[string]interface{}{
+		"status": processManager.GetWebStatus(),
+		"events": processManager.Events().Recent(postmortemEvents),
+	}, "", "  ")
+
+	files := []backup.File{
+		{Name: "deployment.json", Data: record},
+		{Name: "build.log", Data: buildOutput},
+		{Name: "git.txt", Data: gitState(targetRepoDir())},
+		{Name: "deploy.config", Data: maskedConfig("deploy.config")},
+		{Name: "binaryDeploy.log", Data: bytes.Join(globalLogStreamer.GetBufferedLogs(), []byte("\n"))},
+		{Name: "app-output.log", Data: bytes.Join(appOutput.Tail(postmortemAppLines), []byte("\n"))},
+		{Name: "process.json", Data: process},
+	}
+	for i := range files {
+		files[i].Data = []byte(secretRedactor.Redact(string(files[i].Data)))
+	}
+
+	dir := filepath.Join(appConfig().DeployDir, postmortemDirectory)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".bundle-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = backup.ArchiveFiles(tmp, files, time.Now())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	name := rec.ID + ".tar.gz"
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", err
+	}
+
+	prunePostmortems(dir, appConfig().PostmortemKeep)
+	return postmortemDirectory + "/" + name, nil
+}
+
+// prunePostmortems deletes all but the newest keep bundles in dir
+func prunePostmortems(dir string, keep int) {
+	bundles, err := filepath.Glob(filepath.Join(dir, "*.tar.gz"))
+	if err != nil || len(bundles) <= keep {
+		return
+	}
+
+	modTimes := make(map[string]time.Time, len(bundles))
+	for _, path := range bundles {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	sort.Slice(bundles, func(i, j int) bool { return modTimes[bundles[i]].After(modTimes[bundles[j]]) })
+
+	for _, path := range bundles[keep:] {
+		if err := os.Remove(path); err != nil {
+			slog.Warn("Failed to remove old post-mortem bundle", "path", path, "error", err)
+		}
+	}
+}
+
+// gitState describes the checkout in repoDir: HEAD, status, recent history
+// and remotes (with credentials stripped)
+func gitState(repoDir string) []byte {
+	var out bytes.Buffer
+	for _, args := range [][]string{
+		{"rev-parse", "HEAD"},
+		{"status", "--short", "--branch"},
+		{"log", "-10", "--format=%H %an %ad %s", "--date=iso"},
+		{"remote", "-v"},
+	} {
+		fmt.Fprintf(&out, "$ git %s\n", strings.Join(args, " "))
+		result, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).CombinedOutput()
+		out.Write(urlCredentials.ReplaceAll(result, []byte("://***@")))
+		if err != nil {
+			fmt.Fprintf(&out, "(%v)\n", err)
+		}
+		out.WriteString("\n")
+	}
+	return out.Bytes()
+}
+
+// maskedConfig returns the config file with credentials and encrypted values
+// replaced
+func maskedConfig(filename string) []byte {
+	f, err := os.Open(filename)
+	if err != nil {
+		return []byte(fmt.Sprintf("(%v)\n", err))
+	}
+	defer f.Close()
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if ok && !strings.HasPrefix(key, "#") && (isSecretConfigKey(key) || secrets.IsEncrypted(strings.TrimSpace(value))) {
+			line = key + "=[REDACTED]"
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.Bytes()
+}
+
+// notifyDeploymentFailed tells operators about a failed deployment and where
+// to download its post-mortem bundle
+func notifyDeploymentFailed(rec history.Record) {
+	fields := map[string]interface{}{
+		"id":      rec.ID,
+		"kind":    rec.Kind,
+		"commit":  rec.Commit,
+		"branch":  rec.Branch,
+		"trigger": rec.Trigger,
+		"error":   rec.Error,
+
+		"duration_ms": rec.FinishedAt.Sub(rec.StartedAt).Milliseconds(),
+	}
+	if subject := gitCommitSubject(targetRepoDir(), rec.Commit); subject != "" {
+		fields["commit_message"] = subject
+	}
+	if rec.Bundle != "" {
+		fields["bundle"] = rec.Bundle
+		fields["bundle_url"] = appConfig().BasePath + "/apps/" + appConfig().AppName + "/files/" + rec.Bundle
+	}
+	if len(rec.Issues) > 0 {
+		fields["issues"] = issueLinks(rec.Issues)
+	}
+	if rec.ReleaseNotes != nil {
+		fields["release_notes"] = rec.ReleaseNotes
+	}
+	notifier().Notify(notify.Event{
+		Type:          notify.EventDeploymentFailed,
+		App:           rec.App,
+		Message:       fmt.Sprintf("Deployment of %s failed: %s", rec.App, rec.Error),
+		Fields:        fields,
+		CorrelationID: rec.CorrelationID,
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// privateStateDir is state_dir, or a directory next to the deploy directory.
+// It holds credentials binaryDeploy writes itself, such as the git credential
+// store and the session key, and is kept out of deploy_dir because the file
+// browser serves everything in there.
+func privateStateDir() string {
+	if appConfig().StateDir != "" {
+		return appConfig().StateDir
+	}
+	return filepath.Clean(appConfig().DeployDir) + "-state"
+}
+
+// privateStatePath returns the path of name in the private state directory,
+// creating the directory readable by binaryDeploy's user only
+func privateStatePath(name string) (string, error) {
+	dir, err := filepath.Abs(privateStateDir())
+	if err != nil {
+		return "", err
+	}
+	deployDir, err := filepath.Abs(appConfig().DeployDir)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(deployDir, dir); err == nil && !strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("state directory %s is inside deploy_dir, set state_dir to a directory outside of it", dir)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("creating state directory: %w", err)
+	}
+	// MkdirAll keeps the mode of an existing directory
+	if err := os.Chmod(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
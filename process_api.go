@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"binaryDeploy/history"
+)
+
+// processActions are the operations of POST /api/process/{action}
+var processActions = []string{"stop", "start", "restart"}
+
+// processAPIHandler serves POST /api/process/{stop,start,restart}
+func processAPIHandler(w http.ResponseWriter, r *http.Request) {
+	processControlHandler(w, r, strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/process/"), "/"))
+}
+
+// processControlHandler stops, starts or restarts the target app without
+// redeploying it. Start reruns the current build the way a restart-only
+// deployment does, so it works for an app that was stopped or never started
+// since binaryDeploy came up.
+func processControlHandler(w http.ResponseWriter, r *http.Request, action string) {
+	if !slices.Contains(processActions, action) {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if targetDeploymentRunning() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "a deployment of " + appConfig().AppName + " is running"})
+		return
+	}
+
+	source := requestSource(r)
+	slog.InfoContext(r.Context(), "Process control requested", "action", action, "app", appConfig().AppName, "remote_addr", r.RemoteAddr)
+
+	var err error
+	status := http.StatusInternalServerError
+	switch action {
+	case "stop":
+		err = processManager.StopCurrentProcess()
+	case "start":
+		if processManager.IsRunning() {
+			status, err = http.StatusConflict, errors.New("the application is already running")
+			break
+		}
+		err = runDeployment(DeployRequest{
+			RepoURL:       appConfig().TargetRepoURL,
+			SkipFetch:     true,
+			SkipBuild:     true,
+			Trigger:       "Manual process start",
+			TriggeredBy:   source,
+			Tags:          []string{history.TagManual},
+			CorrelationID: requestCorrelationID(r),
+		})
+		if errors.Is(err, errResourceBusy) {
+			status = http.StatusConflict
+		}
+	case "restart":
+		if !processManager.IsRunning() {
+			status, err = http.StatusConflict, errors.New("the application is not running; start it instead")
+			break
+		}
+		err = restartProcess(source)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Process control failed", "action", action, "app", appConfig().AppName, "error", err)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Process control completed", "action", action, "app", appConfig().AppName, "pid", processManager.GetCurrentPID())
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"app":     appConfig().AppName,
+		"action":  action,
+		"process": processManager.GetWebStatus(),
+	})
+}
+
+// restartProcess gracefully restarts the running app with its current
+// configuration and records the restart in history
+func restartProcess(triggeredBy string) error {
+	start := time.Now()
+	err := processManager.RestartProcess()
+	rec := history.Record{
+		App:         appConfig().AppName,
+		Kind:        history.KindRestart,
+		Trigger:     "Manual process restart",
+		TriggeredBy: triggeredBy,
+		Result:      history.ResultSuccess,
+		StartedAt:   start,
+		FinishedAt:  time.Now(),
+		Tags:        []string{history.TagManual},
+	}
+	if err != nil {
+		rec.Result = history.ResultFailure
+		rec.Error = err.Error()
+	}
+	recordDeployment(rec)
+	return err
+}
+
+// targetDeploymentRunning reports whether an update of the target app
+// started by a webhook or the dashboard is still running
+func targetDeploymentRunning() bool {
+	updateStatus.RLock()
+	defer updateStatus.RUnlock()
+	return updateStatus.target.IsRunning
+}
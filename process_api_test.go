@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/history"
+	"binaryDeploy/kv"
+	"binaryDeploy/processmanager"
+)
+
+func setupProcessAPITest(t *testing.T) *config.DeployConfig {
+	t.Helper()
+	cfg := &config.DeployConfig{
+		AppName:       "myapp",
+		TargetRepoURL: "https://git.example.com/team/app.git",
+		RunCommand:    "exec sleep 30",
+		DeployDir:     t.TempDir(),
+	}
+	currentConfig.Store(cfg)
+	if err := os.MkdirAll(targetRepoDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	store, err := kv.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployHistory, err = history.NewStore(store); err != nil {
+		t.Fatal(err)
+	}
+	group := processmanager.NewGroup(1)
+	processManager = group
+	t.Cleanup(func() {
+		group.Shutdown()
+		deployHistory = nil
+	})
+	return cfg
+}
+
+func processRequest(method, action string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	processAPIHandler(rec, httptest.NewRequest(method, "/api/process/"+action, nil))
+	return rec
+}
+
+func TestProcessAPI_StopStartRestart(t *testing.T) {
+	cfg := setupProcessAPITest(t)
+	if err := processManager.StartProcess(cfg, targetWorkingDir()); err != nil {
+		t.Fatal(err)
+	}
+	pid := processManager.GetCurrentPID()
+
+	if rec := processRequest(http.MethodPost, "start"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected start of a running app to conflict, got %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := processRequest(http.MethodPost, "restart"); rec.Code != http.StatusOK {
+		t.Fatalf("restart: %d %s", rec.Code, rec.Body)
+	}
+	if !processManager.IsRunning() || processManager.GetCurrentPID() == pid {
+		t.Errorf("Expected a new process after the restart, PID was %d and is %d", pid, processManager.GetCurrentPID())
+	}
+
+	if rec := processRequest(http.MethodPost, "stop"); rec.Code != http.StatusOK {
+		t.Fatalf("stop: %d %s", rec.Code, rec.Body)
+	}
+	if processManager.IsRunning() {
+		t.Fatal("Expected the app to be stopped")
+	}
+	if rec := processRequest(http.MethodPost, "restart"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected restart of a stopped app to conflict, got %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := processRequest(http.MethodPost, "start"); rec.Code != http.StatusOK {
+		t.Fatalf("start: %d %s", rec.Code, rec.Body)
+	}
+	if !processManager.IsRunning() {
+		t.Error("Expected the app to run again after start")
+	}
+
+	records, err := deployHistory.Recent(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, trigger := range []string{"Manual process restart", "Manual process start"} {
+		found := false
+		for _, rec := range records {
+			if rec.Trigger == trigger && rec.HasTag(history.TagManual) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %q to be recorded in history, got %+v", trigger, records)
+		}
+	}
+}
+
+func TestProcessAPI_RefusesDuringDeployment(t *testing.T) {
+	setupProcessAPITest(t)
+
+	if rec := processRequest(http.MethodPost, "reboot"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown action to get 404, got %d", rec.Code)
+	}
+	if rec := processRequest(http.MethodGet, "stop"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET to get 405, got %d", rec.Code)
+	}
+
+	updateStatus.Lock()
+	updateStatus.target.IsRunning = true
+	updateStatus.Unlock()
+	t.Cleanup(func() {
+		updateStatus.Lock()
+		updateStatus.target.IsRunning = false
+		updateStatus.Unlock()
+	})
+	if rec := processRequest(http.MethodPost, "stop"); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a conflict while a deployment runs, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package processmanager
+
+import (
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// cpuMask is a Linux cpu_set_t for up to 1024 CPUs
+type cpuMask [1024 / 64]uint64
+
+// startWithAffinity starts cmd restricted to cpus, or anywhere if cpus is
+// empty. The child inherits the CPU affinity of the thread that forks it, so
+// the calling thread is pinned to cpus while it starts cmd and restored
+// afterwards; every process the child starts inherits the affinity in turn.
+func startWithAffinity(cmd *exec.Cmd, cpus []int) error {
+	if len(cpus) == 0 {
+		return cmd.Start()
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var previous, mask cpuMask
+	if err := schedAffinity(syscall.SYS_SCHED_GETAFFINITY, &previous); err != nil {
+		slog.Warn("Failed to read CPU affinity, starting without cpu_affinity", "error", err)
+		return cmd.Start()
+	}
+	for _, cpu := range cpus {
+		mask[cpu/64] |= 1 << (cpu % 64)
+	}
+	if err := schedAffinity(syscall.SYS_SCHED_SETAFFINITY, &mask); err != nil {
+		slog.Warn("Failed to set CPU affinity, starting without cpu_affinity", "cpus", cpus, "error", err)
+		return cmd.Start()
+	}
+	defer func() {
+		if err := schedAffinity(syscall.SYS_SCHED_SETAFFINITY, &previous); err != nil {
+			slog.Error("Failed to restore CPU affinity of binaryDeploy thread", "error", err)
+		}
+	}()
+
+	return cmd.Start()
+}
+
+// schedAffinity gets or sets the CPU affinity of the calling thread
+func schedAffinity(trap uintptr, mask *cpuMask) error {
+	_, _, errno := syscall.RawSyscall(trap, 0, unsafe.Sizeof(*mask), uintptr(unsafe.Pointer(mask)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package processmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestProcessManager_AppliesCPUAffinity(t *testing.T) {
+	dir := t.TempDir()
+	pm := NewProcessManager()
+
+	deployConfig := &config.DeployConfig{
+		RunCommand:  `grep Cpus_allowed_list /proc/self/status > cpus; sleep 5`,
+		CPUAffinity: []int{0},
+	}
+	if err := pm.StartProcess(deployConfig, dir); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer pm.Shutdown()
+
+	time.Sleep(300 * time.Millisecond)
+
+	data, err := os.ReadFile(filepath.Join(dir, "cpus"))
+	if err != nil {
+		t.Fatalf("process did not run: %v", err)
+	}
+	if got := strings.Fields(string(data)); len(got) != 2 || got[1] != "0" {
+		t.Errorf("expected the process to be pinned to CPU 0, got %q", data)
+	}
+
+}
+
+func TestProcessManager_StartsWithUnavailableCPUAffinity(t *testing.T) {
+	pm := NewProcessManager()
+
+	// No host has CPU 1023 online, so pinning fails and the process starts
+	// unpinned
+	deployConfig := &config.DeployConfig{
+		RunCommand:  "sleep 5",
+		CPUAffinity: []int{1023},
+	}
+	if err := pm.StartProcess(deployConfig, t.TempDir()); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer pm.Shutdown()
+
+	if !pm.IsRunning() {
+		t.Error("expected the process to be running")
+	}
+}
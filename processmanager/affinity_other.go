@@ -0,0 +1,16 @@
+//go:build !linux
+
+package processmanager
+
+import (
+	"log/slog"
+	"os/exec"
+)
+
+// startWithAffinity starts cmd; CPU affinity is only implemented on Linux
+func startWithAffinity(cmd *exec.Cmd, cpus []int) error {
+	if len(cpus) > 0 {
+		slog.Warn("CPU affinity is only supported on Linux, ignoring cpu_affinity")
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,89 @@
+package processmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+// CoreDumpDir returns the directory under deploy_dir where core dumps of
+// crashed processes are kept
+func CoreDumpDir(deployConfig *config.DeployConfig) string {
+	return filepath.Join(deployConfig.DeployDir, "cores")
+}
+
+// coreDumpCommand wraps command so the processes it starts may dump core
+func coreDumpCommand(command string) string {
+	return "ulimit -c unlimited 2>/dev/null\n" + command
+}
+
+// collectCoreDumps moves core files written in workingDir since the process
+// started into destDir, then deletes all but the newest keep dumps. It
+// returns the names the new dumps were stored under.
+func collectCoreDumps(workingDir string, since time.Time, destDir string, keep int) ([]string, error) {
+	candidates, err := filepath.Glob(filepath.Join(workingDir, "core*"))
+	if err != nil {
+		return nil, err
+	}
+
+	// File timestamps come from a coarser clock than time.Now
+	since = since.Add(-time.Second)
+
+	var collected []string
+	for _, path := range candidates {
+		base := filepath.Base(path)
+		if base != "core" && !strings.HasPrefix(base, "core.") {
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(since) {
+			continue
+		}
+
+		if err := os.MkdirAll(destDir, 0755); err != nil {
+			return collected, err
+		}
+		name := info.ModTime().UTC().Format("20060102T150405") + "-" + base
+		if err := os.Rename(path, filepath.Join(destDir, name)); err != nil {
+			return collected, fmt.Errorf("moving core dump %s: %w", base, err)
+		}
+		collected = append(collected, name)
+	}
+
+	if len(collected) > 0 {
+		if err := pruneCoreDumps(destDir, keep); err != nil {
+			return collected, err
+		}
+	}
+	return collected, nil
+}
+
+// pruneCoreDumps deletes all but the newest keep files in dir
+func pruneCoreDumps(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// Names start with a timestamp, so they sort oldest first
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package processmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestCollectCoreDumps_MovesNewDumpsAndPrunes(t *testing.T) {
+	workingDir := t.TempDir()
+	destDir := filepath.Join(t.TempDir(), "cores")
+	start := time.Now()
+
+	old := filepath.Join(workingDir, "core.1")
+	os.WriteFile(old, []byte("old"), 0600)
+	os.Chtimes(old, start.Add(-time.Hour), start.Add(-time.Hour))
+	os.WriteFile(filepath.Join(workingDir, "core.42"), []byte("dump"), 0600)
+	os.WriteFile(filepath.Join(workingDir, "corefile.go"), []byte("package x"), 0644)
+
+	os.MkdirAll(destDir, 0755)
+	for _, name := range []string{"20200101T000000-core.7", "20200102T000000-core.8"} {
+		os.WriteFile(filepath.Join(destDir, name), nil, 0600)
+	}
+
+	collected, err := collectCoreDumps(workingDir, start, destDir, 2)
+	if err != nil {
+		t.Fatalf("collectCoreDumps failed: %v", err)
+	}
+	if len(collected) != 1 || filepath.Ext(collected[0]) != ".42" {
+		t.Fatalf("expected core.42 to be collected, got %v", collected)
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Error("expected a core older than the process to be left alone")
+	}
+	if _, err := os.Stat(filepath.Join(workingDir, "corefile.go")); err != nil {
+		t.Error("expected unrelated files to be left alone")
+	}
+
+	entries, _ := os.ReadDir(destDir)
+	if len(entries) != 2 || entries[0].Name() != "20200102T000000-core.8" || entries[1].Name() != collected[0] {
+		t.Errorf("expected the two newest dumps to be kept, got %v", entries)
+	}
+}
+
+func TestProcessManager_CoreDumpOnCrash(t *testing.T) {
+	deployDir := t.TempDir()
+	workingDir := t.TempDir()
+	pm := NewProcessManager()
+	done := make(chan Event, 1)
+	pm.SetExitHandler(func(e Event) { done <- e })
+
+	// Stand in for the kernel writing a core dump
+	deployConfig := &config.DeployConfig{
+		RunCommand:    "echo dump > core.123; exit 139",
+		DeployDir:     deployDir,
+		CoreDumps:     true,
+		CoreDumpsKeep: 3,
+	}
+	if err := pm.StartProcess(deployConfig, workingDir); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+
+	select {
+	case event := <-done:
+		if event.CoreDump == "" {
+			t.Fatalf("expected a core dump in the crash event, got %+v", event)
+		}
+		if _, err := os.Stat(filepath.Join(deployDir, filepath.FromSlash(event.CoreDump))); err != nil {
+			t.Errorf("expected the dump under deploy_dir: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("exit handler was not called")
+	}
+}
@@ -0,0 +1,118 @@
+package processmanager
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Process event types
+const (
+	EventStarted     = "started"      // Started by a deployment or restart
+	EventStartFailed = "start_failed" // The run command could not be started
+	EventExited      = "exited"       // Exited on its own with status 0
+	EventCrashed     = "crashed"      // Exited with a non-zero status or a signal
+	EventRestarted   = "restarted"    // Restarted automatically after exiting
+	EventStopped     = "stopped"      // Stopped by binaryDeploy or an operator
+	EventUnhealthy   = "unhealthy"    // Failed health_check_failures liveness checks in a row
+)
+
+// defaultEventLogSize is how many events a log keeps
+const defaultEventLogSize = 500
+
+// Event is one state transition of a managed process
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Instance int       `json:"instance"`
+	PID      int       `json:"pid,omitempty"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Signal   string    `json:"signal,omitempty"`
+	Attempt  int       `json:"attempt,omitempty"` // Restart attempt, for restarted events
+	Uptime   string    `json:"uptime,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+
+	// Set on crashes
+	OOMKilled bool     `json:"oom_killed,omitempty"`
+	Output    []string `json:"output,omitempty"`    // Last crash_output_lines lines of stdout/stderr
+	CoreDump  string   `json:"core_dump,omitempty"` // Relative to deploy_dir
+}
+
+// EventLog keeps the most recent process events in memory
+type EventLog struct {
+	mu     sync.Mutex
+	events []Event
+	max    int
+}
+
+// NewEventLog returns a log holding at most max events
+func NewEventLog(max int) *EventLog {
+	if max <= 0 {
+		max = defaultEventLogSize
+	}
+	return &EventLog{max: max}
+}
+
+// Add records e, dropping the oldest event when the log is full
+func (l *EventLog) Add(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.events) >= l.max {
+		l.events = append(l.events[:0], l.events[len(l.events)-l.max+1:]...)
+	}
+	l.events = append(l.events, e)
+}
+
+// Recent returns up to n events, newest first. n <= 0 returns all of them.
+func (l *EventLog) Recent(n int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if n <= 0 || n > len(l.events) {
+		n = len(l.events)
+	}
+	result := make([]Event, 0, n)
+	for i := len(l.events) - 1; i >= 0 && len(result) < n; i-- {
+		result = append(result, l.events[i])
+	}
+	return result
+}
+
+// exitEvent describes how a process ended from the error returned by Wait
+func exitEvent(process *Process, err error) Event {
+	event := Event{
+		Type:   EventExited,
+		PID:    process.PID,
+		Uptime: time.Since(process.StartTime).Round(time.Second).String(),
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		event.Type = EventCrashed
+		event.Reason = err.Error()
+		return event
+	}
+
+	state := process.Cmd.ProcessState
+	if state == nil {
+		return event
+	}
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		event.Type = EventCrashed
+		event.Signal = status.Signal().String()
+		return event
+	}
+	code := state.ExitCode()
+	event.ExitCode = &code
+	if code != 0 {
+		event.Type = EventCrashed
+	}
+	return event
+}
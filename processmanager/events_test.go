@@ -0,0 +1,205 @@
+package processmanager
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestEventLog_KeepsMostRecent(t *testing.T) {
+	log := NewEventLog(3)
+	for i := 1; i <= 5; i++ {
+		log.Add(Event{Type: EventStarted, PID: i})
+	}
+
+	events := log.Recent(0)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if events[0].PID != 5 || events[2].PID != 3 {
+		t.Errorf("expected newest first from PID 5 to 3, got %+v", events)
+	}
+	if got := log.Recent(1); len(got) != 1 || got[0].PID != 5 {
+		t.Errorf("Recent(1) = %+v", got)
+	}
+}
+
+// waitForExits returns a channel receiving the exit events of pm
+func waitForExits(pm *ProcessManager) <-chan Event {
+	exits := make(chan Event, 10)
+	pm.SetExitHandler(func(e Event) { exits <- e })
+	return exits
+}
+
+// nextExit waits for the next exit event from exits
+func nextExit(t *testing.T, exits <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-exits:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("exit handler was not called")
+		return Event{}
+	}
+}
+
+func TestProcessManager_RecordsCrashAndRestart(t *testing.T) {
+	pm := NewProcessManager()
+	exits := waitForExits(pm)
+	deployConfig := &config.DeployConfig{
+		RunCommand:   "exit 3",
+		RestartDelay: 0,
+		MaxRestarts:  1,
+	}
+
+	if err := pm.StartProcess(deployConfig, "./"); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	nextExit(t, exits)
+	nextExit(t, exits)
+	pm.StopCurrentProcess()
+
+	// Oldest first: started, crashed, restarted, crashed
+	events := pm.Events().Recent(0)
+	var types []string
+	for i := len(events) - 1; i >= 0; i-- {
+		types = append(types, events[i].Type)
+	}
+	want := []string{EventStarted, EventCrashed, EventRestarted, EventCrashed}
+	if len(types) != len(want) {
+		t.Fatalf("expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected events %v, got %v", want, types)
+		}
+	}
+
+	crash := events[0]
+	if crash.ExitCode == nil || *crash.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %v", crash.ExitCode)
+	}
+	if crash.Reason != "max_restarts reached" {
+		t.Errorf("expected the final crash to note the restart limit, got %q", crash.Reason)
+	}
+	if events[1].Attempt != 1 {
+		t.Errorf("expected restart attempt 1, got %d", events[1].Attempt)
+	}
+}
+
+func TestProcessManager_RecordsSignalAndStop(t *testing.T) {
+	pm := NewProcessManager()
+	exits := waitForExits(pm)
+	deployConfig := &config.DeployConfig{RunCommand: "kill -KILL $$"}
+
+	if err := pm.StartProcess(deployConfig, "./"); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	nextExit(t, exits)
+
+	crash := pm.Events().Recent(1)[0]
+	if crash.Type != EventCrashed || crash.Signal != "killed" {
+		t.Errorf("expected a crash by SIGKILL, got %+v", crash)
+	}
+
+	deployConfig = &config.DeployConfig{RunCommand: "sleep 5"}
+	if err := pm.StartProcess(deployConfig, "./"); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	if err := pm.StopCurrentProcess(); err != nil {
+		t.Fatalf("Failed to stop process: %v", err)
+	}
+
+	stop := pm.Events().Recent(1)[0]
+	if stop.Type != EventStopped || stop.PID == 0 {
+		t.Errorf("expected a stopped event, got %+v", stop)
+	}
+}
+
+func TestGroup_SharesEventLog(t *testing.T) {
+	g := NewGroup(2)
+	deployConfig := &config.DeployConfig{RunCommand: "sleep 5", ApplicationPort: 19400}
+
+	if err := g.StartProcess(deployConfig, "./"); err != nil {
+		t.Fatalf("Failed to start group: %v", err)
+	}
+	g.StopCurrentProcess()
+
+	seen := map[int]bool{}
+	for _, e := range g.Events().Recent(0) {
+		if e.Type == EventStarted {
+			seen[e.Instance] = true
+		}
+	}
+	if !seen[0] || !seen[1] {
+		t.Errorf("expected started events for both instances, got %+v", g.Events().Recent(0))
+	}
+}
+
+func TestProcessManager_CrashIncludesOutput(t *testing.T) {
+	pm := NewProcessManager()
+	pm.SetOutputFilter(func(line string) string {
+		if line == "token=hunter2" {
+			return "token=[REDACTED]"
+		}
+		return line
+	})
+	exits := waitForExits(pm)
+
+	deployConfig := &config.DeployConfig{
+		RunCommand:       "echo one; echo token=hunter2 >&2; echo two; exit 2",
+		CrashOutputLines: 3,
+	}
+	if err := pm.StartProcess(deployConfig, "./"); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+
+	crash := nextExit(t, exits)
+	if crash.Type != EventCrashed || crash.ExitCode == nil || *crash.ExitCode != 2 {
+		t.Fatalf("expected a crash with exit code 2, got %+v", crash)
+	}
+	// stdout and stderr are read concurrently, so only the order within a
+	// stream is known
+	output := append([]string(nil), crash.Output...)
+	sort.Strings(output)
+	if len(output) != 3 || output[0] != "one" || output[1] != "token=[REDACTED]" || output[2] != "two" {
+		t.Errorf("expected the filtered output, got %q", crash.Output)
+	}
+	if i, j := slices.Index(crash.Output, "one"), slices.Index(crash.Output, "two"); i > j {
+		t.Errorf("expected stdout lines in order, got %q", crash.Output)
+	}
+	if last, _ := pm.GetWebStatus()["last_exit"].(Event); last.Type != EventCrashed {
+		t.Errorf("expected last_exit in status, got %+v", pm.GetWebStatus()["last_exit"])
+	}
+}
+
+func TestOutputTail_PartialAndLongLines(t *testing.T) {
+	tail := newOutputTail(3)
+	stdout := tail.stream()
+	stdout.Write([]byte("a\nb"))
+	stdout.Write([]byte("c\n" + strings.Repeat("x", 2*maxOutputLineLength) + "\nd"))
+
+	lines := tail.Lines()
+	if len(lines) != 3 || lines[0] != "bc" || len(lines[1]) != maxOutputLineLength || lines[2] != "d" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
+
+func TestOutputTail_StreamsDontSplice(t *testing.T) {
+	tail := newOutputTail(5)
+	stdout, stderr := tail.stream(), tail.stream()
+	stdout.Write([]byte("hel"))
+	stderr.Write([]byte("warn"))
+	stdout.Write([]byte("lo\nwor"))
+	stderr.Write([]byte("ing\n"))
+	stdout.Write([]byte("ld"))
+
+	lines := tail.Lines()
+	if len(lines) != 3 || lines[0] != "hello" || lines[1] != "warning" || lines[2] != "world" {
+		t.Errorf("unexpected lines %q", lines)
+	}
+}
@@ -0,0 +1,272 @@
+package processmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"binaryDeploy/config"
+)
+
+// Group supervises several instances of the same application. Each instance
+// has its own ProcessManager, so crashes and restarts are handled per
+// instance.
+type Group struct {
+	instances []*ProcessManager
+	ports     []int
+	mutex     sync.RWMutex
+	logger    *slog.Logger
+	events    *EventLog
+}
+
+// NewGroup creates a group of n instances (at least one)
+func NewGroup(n int) *Group {
+	if n < 1 {
+		n = 1
+	}
+
+	g := &Group{
+		instances: make([]*ProcessManager, n),
+		ports:     make([]int, n),
+		logger:    slog.Default(),
+		events:    NewEventLog(0),
+	}
+	for i := range g.instances {
+		g.instances[i] = NewProcessManager()
+		g.instances[i].SetEventLog(g.events, i)
+	}
+	return g
+}
+
+// Size returns the number of instances
+func (g *Group) Size() int {
+	return len(g.instances)
+}
+
+// InstancePort returns the port instance i is started with. Under the
+// blue-green strategy instances 0 and 1 are the blue and green releases.
+func InstancePort(deployConfig *config.DeployConfig, i int) int {
+	if deployConfig.DeploymentStrategy == config.StrategyBlueGreen && i < len(deployConfig.BlueGreenPorts) {
+		return deployConfig.BlueGreenPorts[i]
+	}
+	return deployConfig.ApplicationPort + i
+}
+
+// instanceEnv returns the PORT and INSTANCE_ID variables for instance i. A
+// single instance keeps binaryDeploy's environment untouched unless its
+// port was allocated, which it has no other way to learn.
+func (g *Group) instanceEnv(deployConfig *config.DeployConfig, i int) []string {
+	if len(g.instances) == 1 && deployConfig.AllocatedPorts.Size() == 0 {
+		return nil
+	}
+	return []string{
+		"PORT=" + strconv.Itoa(InstancePort(deployConfig, i)),
+		"INSTANCE_ID=" + strconv.Itoa(i),
+	}
+}
+
+// instanceHealthURL is health_check_url for instance i, on its port if the
+// URL is a path
+func instanceHealthURL(deployConfig *config.DeployConfig, i int) string {
+	if strings.HasPrefix(deployConfig.HealthCheckURL, "/") {
+		return fmt.Sprintf("http://127.0.0.1:%d%s", InstancePort(deployConfig, i), deployConfig.HealthCheckURL)
+	}
+	return deployConfig.HealthCheckURL
+}
+
+// StartInstance stops instance i if it is running and starts it again
+func (g *Group) StartInstance(i int, deployConfig *config.DeployConfig, workingDir string) error {
+	if i < 0 || i >= len(g.instances) {
+		return fmt.Errorf("instance %d out of range", i)
+	}
+
+	g.mutex.Lock()
+	g.ports[i] = InstancePort(deployConfig, i)
+	g.mutex.Unlock()
+
+	pm := g.instances[i]
+	pm.SetEnv(g.instanceEnv(deployConfig, i))
+	pm.SetHealthURL(instanceHealthURL(deployConfig, i))
+	if err := pm.StartProcess(deployConfig, workingDir); err != nil {
+		return fmt.Errorf("instance %d: %w", i, err)
+	}
+	return nil
+}
+
+// WaitInstanceReady blocks until instance i notifies readiness, if
+// readiness_notify is set
+func (g *Group) WaitInstanceReady(ctx context.Context, i int) error {
+	if i < 0 || i >= len(g.instances) {
+		return fmt.Errorf("instance %d out of range", i)
+	}
+	return g.instances[i].WaitReady(ctx)
+}
+
+// StopInstance stops instance i, leaving the others running
+func (g *Group) StopInstance(i int) error {
+	if i < 0 || i >= len(g.instances) {
+		return fmt.Errorf("instance %d out of range", i)
+	}
+	return g.instances[i].StopCurrentProcess()
+}
+
+// InstanceRunning reports whether instance i is running
+func (g *Group) InstanceRunning(i int) bool {
+	return i >= 0 && i < len(g.instances) && g.instances[i].IsRunning()
+}
+
+// StartProcess (re)starts every instance
+func (g *Group) StartProcess(deployConfig *config.DeployConfig, workingDir string) error {
+	for i := range g.instances {
+		if err := g.StartInstance(i, deployConfig, workingDir); err != nil {
+			return err
+		}
+	}
+	if len(g.instances) > 1 {
+		g.logger.Info("All instances started", "instances", len(g.instances))
+	}
+	return nil
+}
+
+// StopCurrentProcess stops every instance
+func (g *Group) StopCurrentProcess() error {
+	var errs []error
+	for i, pm := range g.instances {
+		if err := pm.StopCurrentProcess(); err != nil {
+			errs = append(errs, fmt.Errorf("instance %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RestartProcess restarts every running instance with its current
+// configuration
+func (g *Group) RestartProcess() error {
+	restarted := 0
+	for i, pm := range g.instances {
+		if !pm.IsRunning() {
+			continue
+		}
+		if err := pm.RestartProcess(); err != nil {
+			return fmt.Errorf("instance %d: %w", i, err)
+		}
+		restarted++
+	}
+	if restarted == 0 {
+		return fmt.Errorf("no process is running")
+	}
+	return nil
+}
+
+// IsRunning returns true if any instance is running
+func (g *Group) IsRunning() bool {
+	for _, pm := range g.instances {
+		if pm.IsRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetExitHandler registers fn for exits of every instance
+func (g *Group) SetExitHandler(fn func(Event)) {
+	for _, pm := range g.instances {
+		pm.SetExitHandler(fn)
+	}
+}
+
+// SetOutputSink registers fn to receive the output of every instance
+func (g *Group) SetOutputSink(fn func(instance int, stream string) io.Writer) {
+	for _, pm := range g.instances {
+		pm.SetOutputSink(fn)
+	}
+}
+
+// SetOutputFilter registers fn to rewrite captured output of every instance
+func (g *Group) SetOutputFilter(fn func(string) string) {
+	for _, pm := range g.instances {
+		pm.SetOutputFilter(fn)
+	}
+}
+
+// Events returns the state transitions of all instances
+func (g *Group) Events() *EventLog {
+	return g.events
+}
+
+// GetCurrentPID returns the PID of the first running instance, or 0
+func (g *Group) GetCurrentPID() int {
+	for _, pm := range g.instances {
+		if pid := pm.GetCurrentPID(); pid != 0 {
+			return pid
+		}
+	}
+	return 0
+}
+
+// ExecCommand builds a one-off command in the environment of instance i,
+// with its PORT and INSTANCE_ID
+func (g *Group) ExecCommand(ctx context.Context, i int, workingDir, command string) (*exec.Cmd, error) {
+	if i < 0 || i >= len(g.instances) {
+		return nil, fmt.Errorf("instance %d out of range", i)
+	}
+	return g.instances[i].ExecCommand(ctx, workingDir, command), nil
+}
+
+// GetWebStatus returns the first running instance's status, with aggregate
+// counts and a per-instance breakdown
+func (g *Group) GetWebStatus() map[string]interface{} {
+	g.mutex.RLock()
+	ports := append([]int(nil), g.ports...)
+	g.mutex.RUnlock()
+
+	var status map[string]interface{}
+	instances := make([]map[string]interface{}, len(g.instances))
+	running := 0
+
+	for i, pm := range g.instances {
+		s := pm.GetWebStatus()
+		instances[i] = map[string]interface{}{
+			"instance":      i,
+			"port":          ports[i],
+			"running":       s["running"],
+			"pid":           s["pid"],
+			"uptime":        s["uptime"],
+			"restart_count": s["restart_count"],
+			"last_exit":     s["last_exit"],
+			"liveness":      s["liveness"],
+			"ready":         s["ready"],
+		}
+		if s["running"] == true {
+			running++
+			if status == nil {
+				status = s
+			}
+		}
+	}
+	if status == nil {
+		status = g.instances[0].GetWebStatus()
+	}
+
+	status["instances"] = instances
+	status["instances_total"] = len(g.instances)
+	status["instances_running"] = running
+	return status
+}
+
+// Shutdown stops all instances gracefully
+func (g *Group) Shutdown() error {
+	var errs []error
+	for i, pm := range g.instances {
+		if err := pm.Shutdown(); err != nil {
+			errs = append(errs, fmt.Errorf("instance %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,153 @@
+package processmanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestGroup_StartsInstancesWithEnv(t *testing.T) {
+	dir := t.TempDir()
+	g := NewGroup(3)
+
+	deployConfig := &config.DeployConfig{
+		RunCommand:      `echo "$PORT $INSTANCE_ID" > "out-$INSTANCE_ID"; sleep 5`,
+		ApplicationPort: 9000,
+		MaxRestarts:     0,
+	}
+
+	if err := g.StartProcess(deployConfig, dir); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer g.Shutdown()
+
+	time.Sleep(300 * time.Millisecond)
+
+	for i, want := range []string{"9000 0", "9001 1", "9002 2"} {
+		data, err := os.ReadFile(filepath.Join(dir, "out-"+strconv.Itoa(i)))
+		if err != nil {
+			t.Fatalf("instance %d did not run: %v", i, err)
+		}
+		if got := strings.TrimSpace(string(data)); got != want {
+			t.Errorf("instance %d: expected %q, got %q", i, want, got)
+		}
+	}
+
+	status := g.GetWebStatus()
+	if status["instances_running"] != 3 || status["instances_total"] != 3 {
+		t.Errorf("unexpected aggregate status: running=%v total=%v", status["instances_running"], status["instances_total"])
+	}
+	if instances := status["instances"].([]map[string]interface{}); instances[2]["port"] != 9002 {
+		t.Errorf("expected instance 2 on port 9002, got %v", instances[2]["port"])
+	}
+}
+
+func TestGroup_ExecRunsInInstanceEnv(t *testing.T) {
+	g := NewGroup(2)
+	deployConfig := &config.DeployConfig{RunCommand: "sleep 5", ApplicationPort: 9100}
+	if err := g.StartProcess(deployConfig, t.TempDir()); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer g.Shutdown()
+
+	cmd, err := g.ExecCommand(context.Background(), 1, t.TempDir(), `echo "$PORT $INSTANCE_ID"`)
+	if err != nil {
+		t.Fatalf("ExecCommand failed: %v", err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("exec failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "9101 1" {
+		t.Errorf("expected instance 1's PORT and INSTANCE_ID, got %q", got)
+	}
+
+	if _, err := g.ExecCommand(context.Background(), 2, t.TempDir(), "true"); err == nil {
+		t.Error("expected an error for an instance that doesn't exist")
+	}
+}
+
+func TestGroup_InstancesSupervisedIndependently(t *testing.T) {
+	dir := t.TempDir()
+	g := NewGroup(2)
+
+	// Instance 1 exits immediately; instance 0 keeps running
+	deployConfig := &config.DeployConfig{
+		RunCommand:  `[ "$INSTANCE_ID" = 1 ] && exit 1; sleep 5`,
+		MaxRestarts: 0,
+	}
+
+	if err := g.StartProcess(deployConfig, dir); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer g.Shutdown()
+
+	time.Sleep(500 * time.Millisecond)
+
+	if !g.IsRunning() {
+		t.Fatal("expected the group to still be running")
+	}
+	if status := g.GetWebStatus(); status["instances_running"] != 1 {
+		t.Errorf("expected 1 running instance, got %v", status["instances_running"])
+	}
+
+	if err := g.StopCurrentProcess(); err != nil {
+		t.Fatalf("StopCurrentProcess failed: %v", err)
+	}
+	if g.IsRunning() {
+		t.Error("expected all instances to be stopped")
+	}
+}
+
+func TestGroup_SingleInstanceKeepsEnvironment(t *testing.T) {
+	g := NewGroup(0)
+	if g.Size() != 1 {
+		t.Fatalf("expected a single instance, got %d", g.Size())
+	}
+	if env := g.instanceEnv(&config.DeployConfig{ApplicationPort: 8080}, 0); env != nil {
+		t.Errorf("expected no extra environment, got %v", env)
+	}
+}
+
+func TestGroup_BlueGreenPorts(t *testing.T) {
+	dir := t.TempDir()
+	g := NewGroup(2)
+
+	deployConfig := &config.DeployConfig{
+		RunCommand:         `echo "$PORT" > "out-$INSTANCE_ID"; sleep 5`,
+		ApplicationPort:    8080,
+		DeploymentStrategy: config.StrategyBlueGreen,
+		BlueGreenPorts:     []int{8181, 8282},
+	}
+
+	if err := g.StartInstance(1, deployConfig, dir); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	defer g.Shutdown()
+
+	time.Sleep(300 * time.Millisecond)
+
+	data, err := os.ReadFile(filepath.Join(dir, "out-1"))
+	if err != nil {
+		t.Fatalf("green instance did not run: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "8282" {
+		t.Errorf("expected the green instance on port 8282, got %q", got)
+	}
+	if g.InstanceRunning(0) || !g.InstanceRunning(1) {
+		t.Errorf("expected only the green instance to run")
+	}
+
+	if err := g.StopInstance(1); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+	if g.IsRunning() {
+		t.Error("expected no instance to run")
+	}
+}
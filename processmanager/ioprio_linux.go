@@ -0,0 +1,31 @@
+//go:build linux
+
+package processmanager
+
+import "syscall"
+
+// ioprio_set(2) arguments
+const (
+	ioprioWhoProcessGroup = 2
+	ioprioClassShift      = 13
+)
+
+var ioprioClasses = map[string]int{
+	IOClassRealtime:   1,
+	IOClassBestEffort: 2,
+	IOClassIdle:       3,
+}
+
+// setIOPriority sets the IO scheduling class and level of the process group
+// pgid. The idle class has no levels.
+func setIOPriority(pgid int, class string, level int) error {
+	if class == IOClassIdle {
+		level = 0
+	}
+	prio := ioprioClasses[class]<<ioprioClassShift | level
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcessGroup, uintptr(pgid), uintptr(prio))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
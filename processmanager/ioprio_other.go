@@ -0,0 +1,10 @@
+//go:build !linux
+
+package processmanager
+
+import "errors"
+
+// setIOPriority is only implemented with Linux's ioprio_set
+func setIOPriority(pgid int, class string, level int) error {
+	return errors.New("IO priority is only supported on Linux")
+}
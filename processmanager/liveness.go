@@ -0,0 +1,101 @@
+package processmanager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"binaryDeploy/health"
+)
+
+// Liveness states
+const (
+	LivenessStarting  = "starting"  // Within health_check_timeout of starting, failures don't count yet
+	LivenessHealthy   = "healthy"   // The last check passed
+	LivenessUnhealthy = "unhealthy" // The last check failed
+)
+
+// Liveness is the result of probing the running process with
+// health_check_url
+type Liveness struct {
+	Status    string    `json:"status"`
+	Failures  int       `json:"consecutive_failures"`
+	CheckedAt time.Time `json:"checked_at,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// SetHealthURL sets the URL probed every health_check_interval while the
+// process runs. Empty disables probing for processes started afterwards.
+func (pm *ProcessManager) SetHealthURL(url string) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	pm.healthURL = url
+}
+
+// startProbing starts probing process if health_check_interval is set. The
+// caller holds pm.mutex.
+func (pm *ProcessManager) startProbing(process *Process) {
+	interval := time.Duration(process.Config.HealthCheckInterval) * time.Second
+	if pm.healthURL == "" || interval <= 0 {
+		return
+	}
+	pm.liveness = Liveness{Status: LivenessStarting}
+	go pm.probeLiveness(process, pm.healthURL, interval)
+}
+
+// probeLiveness checks url every interval until process exits, restarting it
+// after health_check_failures consecutive failures. Failures within
+// health_check_timeout of starting don't count, so a slow start isn't
+// mistaken for a hung process.
+func (pm *ProcessManager) probeLiveness(process *Process, url string, interval time.Duration) {
+	grace := process.StartTime.Add(time.Duration(process.Config.HealthCheckTimeout) * time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-process.exited:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := health.Check(ctx, url)
+		cancel()
+
+		pm.mutex.Lock()
+		if pm.currentProcess != process {
+			pm.mutex.Unlock()
+			return
+		}
+		now := time.Now()
+		pm.liveness.CheckedAt = now
+		if err == nil {
+			pm.liveness = Liveness{Status: LivenessHealthy, CheckedAt: now}
+			pm.mutex.Unlock()
+			continue
+		}
+		pm.liveness.Error = err.Error()
+		if pm.liveness.Status == LivenessStarting && now.Before(grace) {
+			pm.mutex.Unlock()
+			continue
+		}
+		pm.liveness.Status = LivenessUnhealthy
+		pm.liveness.Failures++
+		failures := pm.liveness.Failures
+		if failures < process.Config.HealthCheckFailures {
+			pm.mutex.Unlock()
+			pm.logger.Warn("Application failed a health check", "pid", process.PID, "failures", failures, "error", err)
+			continue
+		}
+
+		reason := fmt.Sprintf("failed %d health checks in a row: %v", failures, err)
+		pm.logger.Error("Application is unhealthy, restarting it", "pid", process.PID, "failures", failures, "error", err)
+		pm.events.Add(pm.instanceEvent(Event{Type: EventUnhealthy, PID: process.PID, Reason: reason}))
+		if err := pm.startProcessLocked(process.Config, process.WorkingDir, "unhealthy"); err != nil {
+			pm.logger.Error("Failed to restart unhealthy application", "error", err)
+		}
+		pm.mutex.Unlock()
+		return
+	}
+}
@@ -0,0 +1,55 @@
+package processmanager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestProcessManager_RestartsUnhealthyProcess(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	pm := NewProcessManager()
+	pm.SetHealthURL(srv.URL)
+	deployConfig := &config.DeployConfig{
+		RunCommand:          "sleep 30",
+		HealthCheckInterval: 1,
+		HealthCheckFailures: 2,
+	}
+	if err := pm.StartProcess(deployConfig, t.TempDir()); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer pm.Shutdown()
+	pid := pm.GetCurrentPID()
+
+	time.Sleep(1500 * time.Millisecond)
+	if got, _ := pm.GetWebStatus()["liveness"].(Liveness); got.Status != LivenessHealthy {
+		t.Fatalf("expected a healthy process, got %+v", got)
+	}
+
+	// Two failures in a row restart the process, which takes a few seconds
+	// to stop
+	healthy.Store(false)
+	deadline := time.Now().Add(10 * time.Second)
+	for pm.GetCurrentPID() == pid && time.Now().Before(deadline) {
+		time.Sleep(200 * time.Millisecond)
+	}
+	healthy.Store(true)
+	if pm.GetCurrentPID() == pid || pm.GetCurrentPID() == 0 {
+		t.Fatalf("expected the unhealthy process to be replaced, pid %d", pm.GetCurrentPID())
+	}
+	if events := pm.Events().Recent(0); len(events) < 3 || events[2].Type != EventUnhealthy || events[1].Reason != "unhealthy" {
+		t.Errorf("expected unhealthy, stopped and started events, got %+v", events)
+	}
+}
@@ -3,9 +3,11 @@ package processmanager
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
@@ -23,7 +25,11 @@ type Process struct {
 	RestartCount int
 	Config       *config.DeployConfig
 	WorkingDir   string
+	Env          []string
 	cancel       context.CancelFunc
+	output       *outputTail   // Last lines of stdout/stderr
+	oomKills     int           // cgroup oom_kill counter when the process started
+	exited       chan struct{} // Closed once the process has been waited for
 }
 
 // ProcessManager manages the lifecycle of a single application process
@@ -31,12 +37,93 @@ type ProcessManager struct {
 	currentProcess *Process
 	mutex          sync.RWMutex
 	logger         *slog.Logger
+	env            []string // Extra environment for started processes
+	events         *EventLog
+	instance       int // Instance number recorded in events
+	lastExit       *Event
+	onExit         func(Event)
+	outputFilter   func(string) string
+	outputSink     func(instance int, stream string) io.Writer
+	oom            *oomDetector
+	healthURL      string   // Probed every health_check_interval while running
+	liveness       Liveness // Result of probing the current process
+
+	// Readiness socket, opened for the first process with readiness_notify
+	notifyOnce sync.Once
+	notify     *notifySocket
+	notifyErr  error
 }
 
 // NewProcessManager creates a new ProcessManager instance
 func NewProcessManager() *ProcessManager {
 	return &ProcessManager{
 		logger: slog.Default(),
+		events: NewEventLog(0),
+		oom:    newOOMDetector(),
+	}
+}
+
+// SetExitHandler registers fn to be called whenever the process exits on its
+// own, with the exit details that were recorded in the event log
+func (pm *ProcessManager) SetExitHandler(fn func(Event)) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	pm.onExit = fn
+}
+
+// SetOutputSink registers fn to supply a writer that receives a copy of the
+// stdout or stderr of each process started from now on
+func (pm *ProcessManager) SetOutputSink(fn func(instance int, stream string) io.Writer) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	pm.outputSink = fn
+}
+
+// SetOutputFilter registers fn to rewrite captured output lines before they
+// are stored, e.g. to redact secrets
+func (pm *ProcessManager) SetOutputFilter(fn func(string) string) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	pm.outputFilter = fn
+}
+
+// SetEventLog makes the manager record its events in log as the given
+// instance, so several managers can share one log
+func (pm *ProcessManager) SetEventLog(log *EventLog, instance int) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	pm.events = log
+	pm.instance = instance
+}
+
+// Events returns the log of process state transitions
+func (pm *ProcessManager) Events() *EventLog {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+	return pm.events
+}
+
+// record adds e to the event log
+func (pm *ProcessManager) record(e Event) {
+	pm.mutex.RLock()
+	defer pm.mutex.RUnlock()
+	pm.events.Add(pm.instanceEvent(e))
+}
+
+// instanceEvent tags e with this manager's instance. The caller must hold
+// the mutex.
+func (pm *ProcessManager) instanceEvent(e Event) Event {
+	e.Instance = pm.instance
+	return e
+}
+
+// stoppedEvent describes a process stopped on purpose
+func stoppedEvent(process *Process, reason string) Event {
+	return Event{
+		Type:   EventStopped,
+		PID:    process.PID,
+		Uptime: time.Since(process.StartTime).Round(time.Second).String(),
+		Reason: reason,
 	}
 }
 
@@ -62,11 +149,29 @@ func (pm *ProcessManager) GetCurrentWorkingDir() string {
 	return ""
 }
 
+// SetEnv sets extra KEY=value environment variables for processes started
+// from now on, in addition to binaryDeploy's own environment
+func (pm *ProcessManager) SetEnv(env []string) {
+	pm.mutex.Lock()
+	defer pm.mutex.Unlock()
+	pm.env = env
+}
+
 // StartProcess stops any existing process and starts a new one
 func (pm *ProcessManager) StartProcess(deployConfig *config.DeployConfig, workingDir string) error {
+	return pm.startProcess(deployConfig, workingDir, "replaced")
+}
+
+// startProcess starts a new process, recording why any existing one was
+// stopped as stopReason
+func (pm *ProcessManager) startProcess(deployConfig *config.DeployConfig, workingDir, stopReason string) error {
 	pm.mutex.Lock()
 	defer pm.mutex.Unlock()
+	return pm.startProcessLocked(deployConfig, workingDir, stopReason)
+}
 
+// startProcessLocked is startProcess with pm.mutex held
+func (pm *ProcessManager) startProcessLocked(deployConfig *config.DeployConfig, workingDir, stopReason string) error {
 	// Stop any existing process first
 	if pm.currentProcess != nil {
 		if err := pm.stopProcessInternal(pm.currentProcess); err != nil {
@@ -74,19 +179,22 @@ func (pm *ProcessManager) StartProcess(deployConfig *config.DeployConfig, workin
 			return fmt.Errorf("failed to stop existing process before starting new one: %w", err)
 		}
 		pm.logger.Info("Existing process stopped successfully")
+		pm.events.Add(pm.instanceEvent(stoppedEvent(pm.currentProcess, stopReason)))
 	}
 
 	// Create and start new process
-	process, err := pm.createProcess(deployConfig, workingDir)
+	process, err := pm.createProcess(deployConfig, workingDir, pm.env)
 	if err != nil {
 		return fmt.Errorf("failed to create process: %w", err)
 	}
 
 	if err := pm.startProcessInternal(process); err != nil {
+		pm.events.Add(pm.instanceEvent(Event{Type: EventStartFailed, Reason: err.Error()}))
 		return fmt.Errorf("failed to start process: %w", err)
 	}
 
 	pm.currentProcess = process
+	pm.events.Add(pm.instanceEvent(Event{Type: EventStarted, PID: process.PID}))
 	pm.logger.Info("Process started successfully",
 		"pid", process.PID,
 		"command", deployConfig.RunCommand,
@@ -94,12 +202,19 @@ func (pm *ProcessManager) StartProcess(deployConfig *config.DeployConfig, workin
 
 	// Start monitoring the process in a goroutine
 	go pm.monitorProcess(process)
+	pm.startProbing(process)
 
 	return nil
 }
 
 // StopCurrentProcess stops the currently running process
 func (pm *ProcessManager) StopCurrentProcess() error {
+	return pm.stopCurrentProcess("")
+}
+
+// stopCurrentProcess stops the running process, recording reason in its
+// stopped event
+func (pm *ProcessManager) stopCurrentProcess(reason string) error {
 	pm.mutex.Lock()
 
 	if pm.currentProcess == nil {
@@ -114,9 +229,27 @@ func (pm *ProcessManager) StopCurrentProcess() error {
 
 	// Stop the process outside of lock
 	err := pm.stopProcessInternal(process)
+	if err == nil {
+		pm.record(stoppedEvent(process, reason))
+	}
 	return err
 }
 
+// RestartProcess gracefully stops the current process and starts it again with
+// the same configuration and working directory
+func (pm *ProcessManager) RestartProcess() error {
+	pm.mutex.RLock()
+	process := pm.currentProcess
+	pm.mutex.RUnlock()
+
+	if process == nil {
+		return fmt.Errorf("no process is running")
+	}
+
+	pm.logger.Info("Restarting process", "pid", process.PID)
+	return pm.startProcess(process.Config, process.WorkingDir, "restart")
+}
+
 // IsRunning returns true if a process is currently running
 func (pm *ProcessManager) IsRunning() bool {
 	pm.mutex.RLock()
@@ -126,13 +259,39 @@ func (pm *ProcessManager) IsRunning() bool {
 }
 
 // createProcess creates a new Process instance without starting it
-func (pm *ProcessManager) createProcess(deployConfig *config.DeployConfig, workingDir string) (*Process, error) {
+func (pm *ProcessManager) createProcess(deployConfig *config.DeployConfig, workingDir string, env []string) (*Process, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", deployConfig.RunCommand)
+	command := deployConfig.RunCommand
+	if deployConfig.CoreDumps && deployConfig.ULimitCore == "" {
+		command = coreDumpCommand(command)
+	}
+	command = ulimitCommand(deployConfig, command)
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	output := newOutputTail(deployConfig.CrashOutputLines)
 	cmd.Dir = workingDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = io.MultiWriter(os.Stdout, output.stream())
+	cmd.Stderr = io.MultiWriter(os.Stderr, output.stream())
+	if pm.outputSink != nil {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, pm.outputSink(pm.instance, "stdout"))
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, pm.outputSink(pm.instance, "stderr"))
+	}
+	// Don't let a background child holding the output pipe open delay
+	// noticing that the process itself exited
+	cmd.WaitDelay = 2 * time.Second
+	cmdEnv := env
+	if deployConfig.ReadinessNotify {
+		notifyEnv, err := pm.notifyEnv()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		cmdEnv = append(env[:len(env):len(env)], notifyEnv)
+	}
+	if len(cmdEnv) > 0 {
+		cmd.Env = append(os.Environ(), cmdEnv...)
+	}
 
 	// Set up process group for better signal handling
 	cmd.SysProcAttr = &syscall.SysProcAttr{
@@ -144,20 +303,52 @@ func (pm *ProcessManager) createProcess(deployConfig *config.DeployConfig, worki
 	return &Process{
 		Config:     deployConfig,
 		WorkingDir: workingDir,
+		Env:        env,
 		Cmd:        cmd,
 		cancel:     cancel,
+		output:     output,
+		exited:     make(chan struct{}),
 	}, nil
 }
 
+// ExecCommand builds a one-off command that runs in the application's working
+// directory with the same environment as the managed process. The command runs
+// in its own process group so the whole group is killed when ctx is cancelled.
+func (pm *ProcessManager) ExecCommand(ctx context.Context, workingDir, command string) *exec.Cmd {
+	pm.mutex.RLock()
+	env := pm.env
+	pm.mutex.RUnlock()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = workingDir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	pm.logger.Info("Creating exec command", "command", command, "working_dir", workingDir)
+
+	return cmd
+}
+
 // startProcessInternal starts a process and sets its PID
 func (pm *ProcessManager) startProcessInternal(process *Process) error {
-	if err := process.Cmd.Start(); err != nil {
+	process.oomKills = pm.oom.oomKills()
+	if err := startWithAffinity(process.Cmd, process.Config.CPUAffinity); err != nil {
 		return err
 	}
 
 	process.PID = process.Cmd.Process.Pid
 	process.StartTime = time.Now()
 
+	// Running at the wrong priority beats not running at all
+	if err := applyPriority(process.Config, process.PID); err != nil {
+		pm.logger.Warn("Failed to set process priority", "pid", process.PID, "error", err)
+	}
+
 	return nil
 }
 
@@ -312,6 +503,7 @@ func (pm *ProcessManager) stopProcessInternal(process *Process) error {
 // monitorProcess watches a process and handles restarts if it exits unexpectedly
 func (pm *ProcessManager) monitorProcess(process *Process) {
 	err := process.Cmd.Wait()
+	close(process.exited)
 
 	pm.mutex.Lock()
 
@@ -337,8 +529,24 @@ func (pm *ProcessManager) monitorProcess(process *Process) {
 			"uptime", time.Since(process.StartTime))
 	}
 
+	event := pm.describeExit(process, err)
+	restart := process.Config.MaxRestarts > 0 && process.RestartCount < process.Config.MaxRestarts
+	if !restart && process.Config.MaxRestarts > 0 {
+		event.Reason = joinReason(event.Reason, "max_restarts reached")
+	}
+	pm.record(event)
+
+	pm.mutex.Lock()
+	event = pm.instanceEvent(event)
+	pm.lastExit = &event
+	onExit := pm.onExit
+	pm.mutex.Unlock()
+	if onExit != nil {
+		onExit(event)
+	}
+
 	// Handle restart logic
-	if process.Config.MaxRestarts > 0 && process.RestartCount < process.Config.MaxRestarts {
+	if restart {
 		process.RestartCount++
 		pm.logger.Info("Restarting process",
 			"attempt", process.RestartCount,
@@ -349,7 +557,7 @@ func (pm *ProcessManager) monitorProcess(process *Process) {
 		time.Sleep(time.Duration(process.Config.RestartDelay) * time.Second)
 
 		// Try to restart - this will handle locking properly
-		newProcess, err := pm.createProcess(process.Config, process.WorkingDir)
+		newProcess, err := pm.createProcess(process.Config, process.WorkingDir, process.Env)
 		if err != nil {
 			pm.logger.Error("Failed to create restart process", "error", err)
 			return
@@ -357,13 +565,16 @@ func (pm *ProcessManager) monitorProcess(process *Process) {
 
 		if err := pm.startProcessInternal(newProcess); err != nil {
 			pm.logger.Error("Failed to start restart process", "error", err)
+			pm.record(Event{Type: EventStartFailed, Attempt: process.RestartCount, Reason: err.Error()})
 			return
 		}
 
 		newProcess.RestartCount = process.RestartCount
+		pm.record(Event{Type: EventRestarted, PID: newProcess.PID, Attempt: newProcess.RestartCount})
 
 		pm.mutex.Lock()
 		pm.currentProcess = newProcess
+		pm.startProbing(newProcess)
 		pm.mutex.Unlock()
 
 		pm.logger.Info("Process restarted successfully", "pid", newProcess.PID)
@@ -392,6 +603,10 @@ func (pm *ProcessManager) GetWebStatus() map[string]interface{} {
 		"config":        map[string]interface{}{},
 	}
 
+	if pm.lastExit != nil {
+		status["last_exit"] = *pm.lastExit
+	}
+
 	if pm.currentProcess != nil {
 		uptime := time.Since(pm.currentProcess.StartTime)
 
@@ -401,6 +616,16 @@ func (pm *ProcessManager) GetWebStatus() map[string]interface{} {
 		status["command"] = pm.currentProcess.Config.RunCommand
 		status["working_dir"] = pm.currentProcess.WorkingDir
 		status["restart_count"] = pm.currentProcess.RestartCount
+		if pm.healthURL != "" && pm.currentProcess.Config.HealthCheckInterval > 0 {
+			status["liveness"] = pm.liveness
+		}
+		if pm.currentProcess.Config.ReadinessNotify && pm.notify != nil {
+			ready, notifyStatus, _ := pm.notify.readySince(pm.currentProcess.StartTime)
+			status["ready"] = ready
+			if notifyStatus != "" {
+				status["notify_status"] = notifyStatus
+			}
+		}
 
 		if pm.currentProcess.Config != nil {
 			status["config"] = map[string]interface{}{
@@ -417,7 +642,53 @@ func (pm *ProcessManager) GetWebStatus() map[string]interface{} {
 	return status
 }
 
+// describeExit builds the exit event for a process that ended on its own,
+// adding its last output and whether the OOM killer ended it
+func (pm *ProcessManager) describeExit(process *Process, err error) Event {
+	event := exitEvent(process, err)
+	if event.Type != EventCrashed {
+		return event
+	}
+
+	pm.mutex.RLock()
+	filter := pm.outputFilter
+	pm.mutex.RUnlock()
+	if process.output != nil {
+		event.Output = process.output.Lines()
+		if filter != nil {
+			for i, line := range event.Output {
+				event.Output[i] = filter(line)
+			}
+		}
+	}
+
+	if event.Signal == syscall.SIGKILL.String() && pm.oom.killed(process.PID, process.oomKills) {
+		event.OOMKilled = true
+		event.Reason = joinReason(event.Reason, "killed by the kernel OOM killer")
+	}
+
+	if process.Config.CoreDumps {
+		destDir := CoreDumpDir(process.Config)
+		dumps, err := collectCoreDumps(process.WorkingDir, process.StartTime, destDir, process.Config.CoreDumpsKeep)
+		if err != nil {
+			pm.logger.Warn("Failed to collect core dump", "pid", process.PID, "error", err)
+		}
+		if len(dumps) > 0 {
+			event.CoreDump = filepath.ToSlash(filepath.Join(filepath.Base(destDir), dumps[len(dumps)-1]))
+			pm.logger.Info("Collected core dump", "pid", process.PID, "path", filepath.Join(destDir, dumps[len(dumps)-1]))
+		}
+	}
+	return event
+}
+
+func joinReason(reason, more string) string {
+	if reason == "" {
+		return more
+	}
+	return reason + "; " + more
+}
+
 // Shutdown stops all processes gracefully
 func (pm *ProcessManager) Shutdown() error {
-	return pm.StopCurrentProcess()
+	return pm.stopCurrentProcess("shutdown")
 }
@@ -0,0 +1,113 @@
+package processmanager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// notifySockets numbers the readiness sockets of this binaryDeploy process
+var notifySockets atomic.Int64
+
+// notifySocket receives sd_notify style datagrams ("READY=1", "STATUS=...")
+// from the application at the address passed to it as NOTIFY_SOCKET
+type notifySocket struct {
+	conn *net.UnixConn
+	addr string
+
+	mu      sync.Mutex
+	readyAt time.Time
+	status  string
+	changed chan struct{} // Closed and replaced on every notification
+}
+
+// listenNotify opens a new readiness socket
+func listenNotify() (*notifySocket, error) {
+	addr := notifySocketAddr(notifySockets.Add(1))
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("opening readiness notification socket: %w", err)
+	}
+	ns := &notifySocket{conn: conn, addr: addr, changed: make(chan struct{})}
+	go ns.receive()
+	return ns, nil
+}
+
+// receive records notifications until the socket is closed
+func (ns *notifySocket) receive() {
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := ns.conn.ReadFromUnix(buf)
+		if err != nil {
+			return
+		}
+
+		ns.mu.Lock()
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			switch {
+			case line == "READY=1":
+				ns.readyAt = time.Now()
+			case strings.HasPrefix(line, "STATUS="):
+				ns.status = strings.TrimPrefix(line, "STATUS=")
+			}
+		}
+		close(ns.changed)
+		ns.changed = make(chan struct{})
+		ns.mu.Unlock()
+	}
+}
+
+// readySince reports whether READY=1 arrived after since, the latest STATUS
+// and a channel closed on the next notification
+func (ns *notifySocket) readySince(since time.Time) (bool, string, <-chan struct{}) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return !ns.readyAt.Before(since), ns.status, ns.changed
+}
+
+// notifyEnv opens the manager's readiness socket on first use and returns
+// the NOTIFY_SOCKET variable pointing the application at it
+func (pm *ProcessManager) notifyEnv() (string, error) {
+	pm.notifyOnce.Do(func() {
+		pm.notify, pm.notifyErr = listenNotify()
+	})
+	if pm.notifyErr != nil {
+		return "", pm.notifyErr
+	}
+	return "NOTIFY_SOCKET=" + pm.notify.addr, nil
+}
+
+// WaitReady blocks until the running process notifies readiness with
+// READY=1 on NOTIFY_SOCKET, it exits or ctx is done
+func (pm *ProcessManager) WaitReady(ctx context.Context) error {
+	pm.mutex.RLock()
+	process := pm.currentProcess
+	pm.mutex.RUnlock()
+	if process == nil {
+		return fmt.Errorf("no process is running")
+	}
+	if !process.Config.ReadinessNotify || pm.notify == nil {
+		return nil
+	}
+
+	for {
+		ready, status, changed := pm.notify.readySince(process.StartTime)
+		if ready {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-process.exited:
+			return fmt.Errorf("application exited before notifying readiness")
+		case <-ctx.Done():
+			if status != "" {
+				return fmt.Errorf("application did not notify readiness (status %q): %w", status, ctx.Err())
+			}
+			return fmt.Errorf("application did not notify readiness: %w", ctx.Err())
+		}
+	}
+}
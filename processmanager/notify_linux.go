@@ -0,0 +1,14 @@
+//go:build linux
+
+package processmanager
+
+import (
+	"fmt"
+	"os"
+)
+
+// notifySocketAddr names readiness socket n in the abstract namespace, which
+// needs no file and disappears with binaryDeploy
+func notifySocketAddr(n int64) string {
+	return fmt.Sprintf("@binaryDeploy/%d/notify-%d", os.Getpid(), n)
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package processmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// notifySocketAddr places readiness socket n in the temporary directory, as
+// abstract sockets are Linux only
+func notifySocketAddr(n int64) string {
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("binaryDeploy-%d-notify-%d.sock", os.Getpid(), n))
+	os.Remove(path)
+	return path
+}
@@ -0,0 +1,76 @@
+package processmanager
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestProcessManager_WaitReady(t *testing.T) {
+	dir := t.TempDir()
+	pm := NewProcessManager()
+
+	// The test notifies on the process's behalf at the address it was given
+	deployConfig := &config.DeployConfig{
+		RunCommand:      `echo "$NOTIFY_SOCKET" > socket; sleep 5`,
+		ReadinessNotify: true,
+	}
+	if err := pm.StartProcess(deployConfig, dir); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer pm.Shutdown()
+	time.Sleep(200 * time.Millisecond)
+
+	if ready, _ := pm.GetWebStatus()["ready"].(bool); ready {
+		t.Error("expected the process not to be ready before it notifies")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "socket"))
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		t.Fatalf("NOTIFY_SOCKET was not set: %v", err)
+	}
+	addr := &net.UnixAddr{Name: strings.TrimSpace(string(data)), Net: "unixgram"}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		t.Fatalf("connecting to NOTIFY_SOCKET: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("STATUS=serving\nREADY=1")); err != nil {
+		t.Fatalf("notifying: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pm.WaitReady(ctx); err != nil {
+		t.Fatalf("WaitReady failed: %v", err)
+	}
+	status := pm.GetWebStatus()
+	if status["ready"] != true || status["notify_status"] != "serving" {
+		t.Errorf("expected a ready process with status serving, got ready=%v status=%v", status["ready"], status["notify_status"])
+	}
+}
+
+func TestProcessManager_WaitReadyExited(t *testing.T) {
+	pm := NewProcessManager()
+
+	deployConfig := &config.DeployConfig{
+		RunCommand:      "sleep 0.2; exit 1",
+		ReadinessNotify: true,
+	}
+	if err := pm.StartProcess(deployConfig, t.TempDir()); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer pm.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pm.WaitReady(ctx); err == nil || ctx.Err() != nil {
+		t.Errorf("expected WaitReady to fail when the process exits, got %v", err)
+	}
+}
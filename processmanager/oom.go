@@ -0,0 +1,91 @@
+package processmanager
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// oomDetector tells whether a process was killed by the kernel's OOM killer.
+// It compares the oom_kill counter of binaryDeploy's cgroup (cgroup v2), which
+// the managed process inherits, and falls back to scanning the kernel log.
+type oomDetector struct {
+	memoryEvents string // Path of the cgroup's memory.events file
+	kernelLog    func() ([]byte, error)
+}
+
+func newOOMDetector() *oomDetector {
+	return &oomDetector{
+		memoryEvents: cgroupMemoryEvents(),
+		kernelLog: func() ([]byte, error) {
+			return exec.Command("dmesg").Output()
+		},
+	}
+}
+
+// cgroupMemoryEvents locates memory.events for the current cgroup v2, or
+// returns "" when it isn't available
+func cgroupMemoryEvents() string {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join("/sys/fs/cgroup", path, "memory.events")
+		}
+	}
+	return ""
+}
+
+// oomKills returns the cgroup's oom_kill counter, or -1 when it can't be read
+func (d *oomDetector) oomKills() int {
+	if d.memoryEvents == "" {
+		return -1
+	}
+	data, err := os.ReadFile(d.memoryEvents)
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n
+			}
+		}
+	}
+	return -1
+}
+
+// killed reports whether pid was OOM-killed, given the oom_kill counter
+// sampled when it started
+func (d *oomDetector) killed(pid, killsAtStart int) bool {
+	if killsAtStart >= 0 {
+		if now := d.oomKills(); now >= 0 {
+			return now > killsAtStart
+		}
+	}
+
+	// The kernel log needs privileges many hosts don't grant; treat any
+	// failure as "not an OOM kill"
+	if d.kernelLog == nil {
+		return false
+	}
+	log, err := d.kernelLog()
+	if err != nil {
+		return false
+	}
+	needle := []byte(fmt.Sprintf("Killed process %d ", pid))
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	for scanner.Scan() {
+		if bytes.Contains(scanner.Bytes(), needle) {
+			return true
+		}
+	}
+	return false
+}
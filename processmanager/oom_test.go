@@ -0,0 +1,45 @@
+package processmanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOOMDetector_CgroupCounter(t *testing.T) {
+	events := filepath.Join(t.TempDir(), "memory.events")
+	os.WriteFile(events, []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644)
+	d := &oomDetector{memoryEvents: events}
+
+	start := d.oomKills()
+	if start != 1 {
+		t.Fatalf("expected oom_kill 1, got %d", start)
+	}
+	if d.killed(42, start) {
+		t.Error("expected no OOM kill while the counter is unchanged")
+	}
+
+	os.WriteFile(events, []byte("oom 2\noom_kill 2\n"), 0644)
+	if !d.killed(42, start) {
+		t.Error("expected an OOM kill after the counter increased")
+	}
+}
+
+func TestOOMDetector_KernelLogFallback(t *testing.T) {
+	d := &oomDetector{kernelLog: func() ([]byte, error) {
+		return []byte("[123.4] Out of memory: Killed process 4242 (myapp) total-vm:1024kB\n"), nil
+	}}
+
+	if !d.killed(4242, -1) {
+		t.Error("expected the kernel log entry to be found")
+	}
+	if d.killed(424, -1) {
+		t.Error("expected other PIDs not to match")
+	}
+
+	d.kernelLog = func() ([]byte, error) { return nil, errors.New("permission denied") }
+	if d.killed(4242, -1) {
+		t.Error("expected an unreadable kernel log to mean no OOM kill")
+	}
+}
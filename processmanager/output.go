@@ -0,0 +1,98 @@
+package processmanager
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxOutputLineLength bounds the memory a single runaway line can take
+const maxOutputLineLength = 1024
+
+// outputTail keeps the last lines a process wrote, so a crash can be
+// reported with the output that led up to it. Each output stream writes
+// through its own outputStream, so lines the process writes to stdout and
+// stderr at the same time aren't spliced together.
+type outputTail struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	streams []*outputStream
+}
+
+func newOutputTail(max int) *outputTail {
+	return &outputTail{max: max}
+}
+
+// outputStream assembles the lines of one output stream of a process
+type outputStream struct {
+	tail    *outputTail
+	partial []byte // Guarded by tail.mu
+}
+
+// stream returns a writer for one output stream, such as stdout
+func (t *outputTail) stream() *outputStream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &outputStream{tail: t}
+	t.streams = append(t.streams, s)
+	return s
+}
+
+// Write records complete lines from p and holds on to a trailing partial line
+func (s *outputStream) Write(p []byte) (int, error) {
+	t := s.tail
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			s.appendPartial(data)
+			break
+		}
+		s.appendPartial(data[:i])
+		t.push(string(bytes.TrimRight(s.partial, "\r")))
+		s.partial = s.partial[:0]
+		data = data[i+1:]
+	}
+	return len(p), nil
+}
+
+func (s *outputStream) appendPartial(b []byte) {
+	if room := maxOutputLineLength - len(s.partial); room > 0 {
+		s.partial = append(s.partial, b[:min(room, len(b))]...)
+	}
+}
+
+func (t *outputTail) push(line string) {
+	if t.max <= 0 {
+		return
+	}
+	if len(t.lines) >= t.max {
+		t.lines = append(t.lines[:0], t.lines[len(t.lines)-t.max+1:]...)
+	}
+	t.lines = append(t.lines, line)
+}
+
+// Lines returns the recorded lines, oldest first, including the unfinished
+// last line of each stream
+func (t *outputTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.max <= 0 {
+		return nil
+	}
+	lines := append([]string(nil), t.lines...)
+	for _, s := range t.streams {
+		if len(s.partial) > 0 {
+			lines = append(lines, string(s.partial))
+		}
+	}
+	if len(lines) > t.max {
+		lines = lines[len(lines)-t.max:]
+	}
+	return lines
+}
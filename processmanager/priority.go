@@ -0,0 +1,34 @@
+package processmanager
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"binaryDeploy/config"
+)
+
+// IO scheduling classes, as named in deploy.config
+const (
+	IOClassRealtime   = "realtime"
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+)
+
+// applyPriority sets the CPU and IO scheduling priority of the process group
+// led by pid. The process's children join its group, so they inherit both,
+// including any forked before the priority was set.
+func applyPriority(deployConfig *config.DeployConfig, pid int) error {
+	var errs []error
+	if deployConfig.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PGRP, pid, deployConfig.Nice); err != nil {
+			errs = append(errs, fmt.Errorf("setting nice %d: %w", deployConfig.Nice, err))
+		}
+	}
+	if deployConfig.IOClass != "" {
+		if err := setIOPriority(pid, deployConfig.IOClass, deployConfig.IOLevel); err != nil {
+			errs = append(errs, fmt.Errorf("setting IO priority %s: %w", deployConfig.IOClass, err))
+		}
+	}
+	return errors.Join(errs...)
+}
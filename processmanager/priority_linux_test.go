@@ -0,0 +1,47 @@
+package processmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestProcessManager_AppliesPriority(t *testing.T) {
+	dir := t.TempDir()
+	pm := NewProcessManager()
+
+	// The child forked by the shell reports its own niceness (field 19 of
+	// /proc/self/stat)
+	deployConfig := &config.DeployConfig{
+		RunCommand: `sleep 0.2; cut -d' ' -f19 /proc/self/stat > nice; sleep 5`,
+		Nice:       7,
+		IOClass:    IOClassIdle,
+	}
+	if err := pm.StartProcess(deployConfig, dir); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer pm.Shutdown()
+
+	time.Sleep(500 * time.Millisecond)
+
+	data, err := os.ReadFile(filepath.Join(dir, "nice"))
+	if err != nil {
+		t.Fatalf("process did not run: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "7" {
+		t.Errorf("expected nice 7 in a child process, got %q", got)
+	}
+
+	prio, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, 1, uintptr(pm.GetCurrentPID()), 0)
+	if errno != 0 {
+		t.Fatalf("ioprio_get failed: %v", errno)
+	}
+	if class := prio >> ioprioClassShift; class != 3 {
+		t.Errorf("expected the idle IO class (3), got %d", class)
+	}
+}
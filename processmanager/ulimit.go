@@ -0,0 +1,35 @@
+package processmanager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"binaryDeploy/config"
+)
+
+// ulimitCommand wraps command so the shell sets the configured resource
+// limits before it runs the application, which inherits them. A limit the
+// shell can't set, such as one above the hard limit without root, is
+// reported on the application's stderr and left as it was.
+func ulimitCommand(deployConfig *config.DeployConfig, command string) string {
+	var b strings.Builder
+	if limit := deployConfig.ULimitNofile; limit != "" {
+		fmt.Fprintf(&b, "ulimit -n %s\n", limit)
+	}
+	if limit := deployConfig.ULimitNproc; limit != "" {
+		// bash names the process limit -u, dash -p
+		fmt.Fprintf(&b, "if ulimit -u >/dev/null 2>&1; then ulimit -u %[1]s; else ulimit -p %[1]s; fi\n", limit)
+	}
+	if limit := deployConfig.ULimitCore; limit != "" {
+		// sh counts core sizes in 512-byte blocks
+		if mb, err := strconv.ParseUint(limit, 10, 64); err == nil {
+			limit = strconv.FormatUint(mb*2048, 10)
+		}
+		fmt.Fprintf(&b, "ulimit -c %s\n", limit)
+	}
+	if b.Len() == 0 {
+		return command
+	}
+	return b.String() + command
+}
@@ -0,0 +1,51 @@
+package processmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestProcessManager_AppliesULimits(t *testing.T) {
+	dir := t.TempDir()
+	pm := NewProcessManager()
+
+	// Lowering limits works without root
+	deployConfig := &config.DeployConfig{
+		RunCommand:   `echo $(ulimit -n) $(ulimit -c) > limits; sleep 5`,
+		ULimitNofile: "256",
+		ULimitCore:   "0",
+		CoreDumps:    true,
+	}
+	if err := pm.StartProcess(deployConfig, dir); err != nil {
+		t.Fatalf("StartProcess failed: %v", err)
+	}
+	defer pm.Shutdown()
+
+	time.Sleep(300 * time.Millisecond)
+
+	data, err := os.ReadFile(filepath.Join(dir, "limits"))
+	if err != nil {
+		t.Fatalf("process did not run: %v", err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "256 0" {
+		t.Errorf("expected nofile 256 and core 0 overriding core_dumps, got %q", got)
+	}
+}
+
+func TestULimitCommand(t *testing.T) {
+	if got := ulimitCommand(&config.DeployConfig{}, "./app"); got != "./app" {
+		t.Errorf("expected the command unchanged without limits, got %q", got)
+	}
+
+	got := ulimitCommand(&config.DeployConfig{ULimitNproc: "unlimited", ULimitCore: "2"}, "./app")
+	for _, want := range []string{"ulimit -u unlimited", "ulimit -p unlimited", "ulimit -c 4096\n", "\n./app"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"binaryDeploy/history"
+)
+
+// deployProfile times the pipeline steps of one deployment. A nil profile
+// (profile_deployments disabled) just runs the steps.
+type deployProfile struct {
+	steps []history.StepTiming
+}
+
+// newDeployProfile returns a profile when profile_deployments is enabled
+func newDeployProfile() *deployProfile {
+	if !appConfig().ProfileDeployments {
+		return nil
+	}
+	return &deployProfile{}
+}
+
+// step runs fn as the named pipeline step, recording how long it took and how
+// much the heap grew while it ran
+func (p *deployProfile) step(name string, fn func() error) error {
+	if p == nil {
+		return fn()
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	err := fn()
+
+	duration := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	timing := history.StepTiming{
+		Name:           name,
+		DurationMS:     duration.Milliseconds(),
+		HeapDeltaBytes: int64(after.HeapAlloc) - int64(before.HeapAlloc),
+		Failed:         err != nil,
+	}
+	p.steps = append(p.steps, timing)
+
+	slog.Info("Deployment step finished",
+		"step", name,
+		"duration", duration.String(),
+		"heap_delta_bytes", timing.HeapDeltaBytes,
+		"failed", timing.Failed)
+	return err
+}
+
+// Steps returns the recorded step timings
+func (p *deployProfile) Steps() []history.StepTiming {
+	if p == nil {
+		return nil
+	}
+	return p.steps
+}
+
+// logSummary logs one line breaking the deployment down by step
+func (p *deployProfile) logSummary(total time.Duration) {
+	if p == nil || len(p.steps) == 0 {
+		return
+	}
+
+	parts := make([]string, len(p.steps))
+	for i, s := range p.steps {
+		parts[i] = s.Name + "=" + (time.Duration(s.DurationMS) * time.Millisecond).String()
+	}
+	slog.Info("Deployment profile", "total", total.String(), "steps", strings.Join(parts, " "))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestDeployProfile_RecordsSteps(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{ProfileDeployments: true})
+	profile := newDeployProfile()
+
+	buildFailed := errors.New("build failed")
+	profile.step("fetch", func() error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	})
+	if err := profile.step("build", func() error { return buildFailed }); err != buildFailed {
+		t.Errorf("Expected the step's error to be returned, got %v", err)
+	}
+
+	steps := profile.Steps()
+	if len(steps) != 2 || steps[0].Name != "fetch" || steps[1].Name != "build" {
+		t.Fatalf("Expected the fetch and build steps in order, got %+v", steps)
+	}
+	if steps[0].DurationMS < 20 || steps[0].Failed {
+		t.Errorf("Expected fetch to take at least 20ms and succeed, got %+v", steps[0])
+	}
+	if !steps[1].Failed {
+		t.Errorf("Expected build to be marked failed, got %+v", steps[1])
+	}
+}
+
+func TestDeployProfile_DisabledStillRunsSteps(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{})
+	profile := newDeployProfile()
+
+	ran := false
+	if err := profile.step("build", func() error {
+		ran = true
+		return nil
+	}); err != nil || !ran {
+		t.Errorf("Expected the step to run without profiling, got ran=%v err=%v", ran, err)
+	}
+	if steps := profile.Steps(); steps != nil {
+		t.Errorf("Expected no timings without profile_deployments, got %+v", steps)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"binaryDeploy/authguard"
+)
+
+// trustedProxies are the peers whose X-Forwarded-* headers are believed
+var trustedProxies struct {
+	sync.RWMutex
+	networks []*net.IPNet
+}
+
+// setupReverseProxy prepares serving behind a reverse proxy
+func setupReverseProxy() {
+	networks, err := authguard.ParseNetworks(appConfig().TrustedProxies)
+	if err != nil {
+		slog.Error("Invalid trusted_proxies, X-Forwarded-* headers are ignored", "error", err)
+		return
+	}
+	trustedProxies.Lock()
+	trustedProxies.networks = networks
+	trustedProxies.Unlock()
+	if appConfig().BasePath != "" {
+		slog.Info("Serving under a path prefix", "base_path", appConfig().BasePath)
+	}
+}
+
+// withBasePath serves next under base_path, which it strips from the URL so
+// routes are registered without it. Requests outside the prefix get 404.
+func withBasePath(next http.Handler) http.Handler {
+	base := appConfig().BasePath
+	if base == "" {
+		return next
+	}
+	stripped := http.StripPrefix(base, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == base:
+			// Relative links in the dashboard need the trailing slash
+			http.Redirect(w, r, base+"/", http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, base+"/"):
+			stripped.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// withForwardedHeaders applies X-Forwarded-For, -Proto and -Host set by a
+// trusted proxy, so client addresses in logs, audit trails and bans are the
+// real ones. Headers from any other peer are ignored; they are trivially
+// forged.
+func withForwardedHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isTrustedProxy(requestSource(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = r.Clone(r.Context())
+		if client := forwardedClient(r.Header); client != "" {
+			r.RemoteAddr = client
+		}
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			r.URL.Scheme = proto
+		}
+		if host := r.Header.Get("X-Forwarded-Host"); host != "" {
+			r.Host = host
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClient returns the client address from X-Forwarded-For: the
+// right-most address not belonging to a trusted proxy, as anything left of
+// it was supplied by the client itself. X-Real-IP is the fallback.
+func forwardedClient(header http.Header) string {
+	var hops []string
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			return ""
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+	}
+	if ip := strings.TrimSpace(header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return ""
+}
+
+// isTrustedProxy reports whether addr belongs to trusted_proxies
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	trustedProxies.RLock()
+	defer trustedProxies.RUnlock()
+	for _, network := range trustedProxies.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
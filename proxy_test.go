@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func TestWithBasePath(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{BasePath: "/deploy-bot"})
+	handler := withBasePath(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+
+	tests := []struct {
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{"/deploy-bot/webhook", http.StatusOK, "/webhook", ""},
+		{"/deploy-bot/", http.StatusOK, "/", ""},
+		{"/deploy-bot", http.StatusMovedPermanently, "", "/deploy-bot/"},
+		{"/webhook", http.StatusNotFound, "", ""},
+		{"/deploy-botnet/webhook", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.status, rec.Code)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s: expected the handler to see %q, got %q", tt.path, tt.body, rec.Body)
+		}
+		if location := rec.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s: expected Location %q, got %q", tt.path, tt.location, location)
+		}
+	}
+}
+
+func TestWithForwardedHeaders(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{TrustedProxies: []string{"10.0.0.0/8"}})
+	setupReverseProxy()
+
+	type seen struct{ client, scheme, host string }
+	var got seen
+	handler := withForwardedHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = seen{requestSource(r), r.URL.Scheme, r.Host}
+	}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       seen
+	}{
+		{"trusted proxy", "10.0.0.2:4000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "deploy.example.com"},
+			seen{"203.0.113.7", "https", "deploy.example.com"}},
+		{"client-supplied hops left of the proxy", "10.0.0.2:4000",
+			map[string]string{"X-Forwarded-For": "1.2.3.4, 203.0.113.7, 10.0.0.3"},
+			seen{"203.0.113.7", "", "example.com"}},
+		{"X-Real-IP", "10.0.0.2:4000", map[string]string{"X-Real-IP": "203.0.113.8"}, seen{"203.0.113.8", "", "example.com"}},
+		{"untrusted peer", "198.51.100.1:4000",
+			map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.example"},
+			seen{"198.51.100.1", "", "example.com"}},
+		{"invalid scheme", "10.0.0.2:4000", map[string]string{"X-Forwarded-Proto": "javascript"}, seen{"10.0.0.2", "", "example.com"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/webhook", nil)
+		req.RemoteAddr = tt.remoteAddr
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package publish works out which files of a build output directory changed
+// since the last upload and scripts an SFTP session that stages them in a
+// temporary directory before renaming them into place
+package publish
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Manifest maps slash-separated file paths to their SHA-256
+type Manifest map[string]string
+
+// Scan hashes every regular file under dir
+func Scan(dir string) (Manifest, error) {
+	m := Manifest{}
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		sum, err := hashFile(file)
+		if err != nil {
+			return err
+		}
+		m[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	return m, err
+}
+
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Diff returns the files that are new or changed in next and the files of
+// prev that are gone, both sorted
+func Diff(prev, next Manifest) (changed, removed []string) {
+	for p, sum := range next {
+		if prev[p] != sum {
+			changed = append(changed, p)
+		}
+	}
+	for p := range prev {
+		if _, ok := next[p]; !ok {
+			removed = append(removed, p)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// LoadManifest reads a manifest saved by Save. A missing file is an empty
+// manifest, so the first publish uploads everything.
+func LoadManifest(file string) (Manifest, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return Manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := Manifest{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing publish manifest %s: %w", file, err)
+	}
+	return m, nil
+}
+
+// Save writes m to file
+func (m Manifest) Save(file string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// SFTPBatch returns an sftp batch script that uploads changed files from
+// localDir into staging (a directory inside remoteDir, so renames stay on one
+// filesystem), renames each into place, deletes removed files and finally
+// drops the staging directory.
+// Commands prefixed with "-" may fail without aborting the batch, e.g. mkdir
+// of a directory that already exists.
+func SFTPBatch(localDir, remoteDir, staging string, changed, removed []string) string {
+	var b strings.Builder
+	remoteDir = strings.TrimRight(remoteDir, "/")
+	stagingDir := remoteDir + "/" + staging
+
+	// Every file is uploaded before any is renamed, so a dropped connection
+	// leaves the live directory untouched
+	fmt.Fprintf(&b, "-mkdir %s\n", quote(remoteDir))
+	fmt.Fprintf(&b, "mkdir %s\n", quote(stagingDir))
+	for i, p := range changed {
+		fmt.Fprintf(&b, "put -p %s %s\n", quote(filepath.Join(localDir, filepath.FromSlash(p))), quote(fmt.Sprintf("%s/%d", stagingDir, i)))
+	}
+
+	for _, dir := range parentDirs(changed) {
+		fmt.Fprintf(&b, "-mkdir %s\n", quote(remoteDir+"/"+dir))
+	}
+	for i, p := range changed {
+		fmt.Fprintf(&b, "rename %s %s\n", quote(fmt.Sprintf("%s/%d", stagingDir, i)), quote(remoteDir+"/"+p))
+	}
+	for _, p := range removed {
+		fmt.Fprintf(&b, "-rm %s\n", quote(remoteDir+"/"+p))
+	}
+	fmt.Fprintf(&b, "-rmdir %s\n", quote(stagingDir))
+	return b.String()
+}
+
+// parentDirs returns every directory containing one of files, parents first
+func parentDirs(files []string) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, f := range files {
+		for dir := path.Dir(f); dir != "."; dir = path.Dir(dir) {
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") < strings.Count(dirs[j], "/") ||
+			(strings.Count(dirs[i], "/") == strings.Count(dirs[j], "/") && dirs[i] < dirs[j])
+	})
+	return dirs
+}
+
+// quote wraps s in double quotes for sftp's batch parser
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
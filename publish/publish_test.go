@@ -0,0 +1,75 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanAndDiff(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "css"), 0755)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("v1"), 0644)
+	os.WriteFile(filepath.Join(dir, "css", "site.css"), []byte("body{}"), 0644)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref"), 0644)
+
+	first, err := Scan(dir)
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("expected 2 files without .git, got %v", first)
+	}
+
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("v2"), 0644)
+	os.Remove(filepath.Join(dir, "css", "site.css"))
+	os.WriteFile(filepath.Join(dir, "about.html"), []byte("new"), 0644)
+
+	second, _ := Scan(dir)
+	changed, removed := Diff(first, second)
+	if !reflect.DeepEqual(changed, []string{"about.html", "index.html"}) || !reflect.DeepEqual(removed, []string{"css/site.css"}) {
+		t.Errorf("Diff = %v, %v", changed, removed)
+	}
+
+	if changed, removed := Diff(second, second); len(changed)+len(removed) != 0 {
+		t.Errorf("expected no changes, got %v, %v", changed, removed)
+	}
+}
+
+func TestManifest_SaveAndLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state", "host.json")
+
+	m, err := LoadManifest(file)
+	if err != nil || len(m) != 0 {
+		t.Fatalf("expected an empty manifest before the first publish, got %v, %v", m, err)
+	}
+
+	Manifest{"a.html": "abc"}.Save(file)
+	m, err = LoadManifest(file)
+	if err != nil || m["a.html"] != "abc" {
+		t.Errorf("LoadManifest = %v, %v", m, err)
+	}
+}
+
+func TestSFTPBatch(t *testing.T) {
+	batch := SFTPBatch("/build", "/www/", ".staging-1", []string{"a b.html", "css/x/site.css"}, []string{"old.html"})
+
+	want := []string{
+		`-mkdir "/www"`,
+		`mkdir "/www/.staging-1"`,
+		`put -p "/build/a b.html" "/www/.staging-1/0"`,
+		`put -p "/build/css/x/site.css" "/www/.staging-1/1"`,
+		`-mkdir "/www/css"`,
+		`-mkdir "/www/css/x"`,
+		`rename "/www/.staging-1/0" "/www/a b.html"`,
+		`rename "/www/.staging-1/1" "/www/css/x/site.css"`,
+		`-rm "/www/old.html"`,
+		`-rmdir "/www/.staging-1"`,
+	}
+	if got := strings.Split(strings.TrimSpace(batch), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected batch:\n%s", batch)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// selfUpdateRetryAfter is the Retry-After of requests refused while
+// binaryDeploy updates itself
+const selfUpdateRetryAfter = 30 * time.Second
+
+// runningSelfUpdate returns the self-update or rollback replacing the
+// binary, if any
+func runningSelfUpdate() (UpdateStatus, bool) {
+	updateStatus.RLock()
+	defer updateStatus.RUnlock()
+	return updateStatus.self, updateStatus.self.IsRunning
+}
+
+// readOnlyDuringSelfUpdate serves GET and HEAD requests as usual but refuses
+// anything else with 503 while a self-update is running, instead of starting
+// deployments or touching the process while the binary is being replaced
+func readOnlyDuringSelfUpdate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && refuseDuringSelfUpdate(w) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// refuseDuringSelfUpdate answers 503 "updating" with a Retry-After and
+// returns true when a self-update is running
+func refuseDuringSelfUpdate(w http.ResponseWriter) bool {
+	self, running := runningSelfUpdate()
+	if !running {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(int(selfUpdateRetryAfter.Seconds())))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":  "binaryDeploy is updating itself, try again later",
+		"status": "updating",
+		"since":  self.StartTime.Format(time.RFC3339),
+	})
+	return true
+}
+
+// readOnlyStatus reports the read-only state in /status
+type readOnlyStatus struct{}
+
+func (readOnlyStatus) ReadOnlyStatus() map[string]interface{} {
+	self, running := runningSelfUpdate()
+	status := map[string]interface{}{"active": running}
+	if running {
+		status["reason"] = "self-update"
+		status["message"] = self.Message
+		status["since"] = self.StartTime.Format(time.RFC3339)
+		status["retry_after_seconds"] = int(selfUpdateRetryAfter.Seconds())
+	}
+	return status
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+
+	"binaryDeploy/history"
+	"binaryDeploy/notify"
+	"binaryDeploy/releasenotes"
+)
+
+// maxReleaseNoteCommits bounds the changelog of a single deployment
+const maxReleaseNoteCommits = 200
+
+// buildReleaseNotes lists the commits between the last successfully deployed
+// commit and commit. The first deployment only lists commit itself.
+func buildReleaseNotes(repoDir, commit string) *releasenotes.Notes {
+	if commit == "" {
+		return nil
+	}
+
+	previous := lastDeployedCommit()
+	revs := []string{"-n", "1", commit}
+	if previous != "" {
+		if previous == commit {
+			return nil
+		}
+		revs = []string{"-n", fmt.Sprint(maxReleaseNoteCommits), previous + ".." + commit}
+	}
+	commits, err := gitCommits(repoDir, revs...)
+	if err != nil {
+		// A rollback or force push leaves previous outside the new history
+		slog.Warn("Failed to read commits for release notes", "from", previous, "to", commit, "error", err)
+		return nil
+	}
+	notes := releasenotes.Build(previous, commit, commits)
+	if notes.Empty() {
+		return nil
+	}
+	return &notes
+}
+
+// gitCommits lists the commits selected by git log arguments, newest first
+func gitCommits(repoDir string, revs ...string) ([]releasenotes.Commit, error) {
+	out, err := exec.Command("git", append([]string{"-C", repoDir, "log", "--format=%H%x00%s"}, revs...)...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []releasenotes.Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if sha, subject, ok := strings.Cut(line, "\x00"); ok {
+			commits = append(commits, releasenotes.Commit{SHA: sha, Subject: subject})
+		}
+	}
+	return commits, nil
+}
+
+// notifyDeploymentSucceeded announces a successful deployment with what it
+// contained
+func notifyDeploymentSucceeded(rec history.Record) {
+	fields := map[string]interface{}{
+		"commit":       rec.Commit,
+		"branch":       rec.Branch,
+		"trigger":      rec.Trigger,
+		"triggered_by": rec.TriggeredBy,
+		"duration_ms":  rec.FinishedAt.Sub(rec.StartedAt).Milliseconds(),
+	}
+	if subject := gitCommitSubject(targetRepoDir(), rec.Commit); subject != "" {
+		fields["commit_message"] = subject
+	}
+	message := fmt.Sprintf("Deployed %s at %s", rec.App, shortSHA(rec.Commit))
+	if rec.Version != "" {
+		fields["version"] = rec.Version
+		message = fmt.Sprintf("Deployed %s %s (%s)", rec.App, rec.Version, shortSHA(rec.Commit))
+	}
+	if rec.ReleaseNotes != nil {
+		fields["release_notes"] = rec.ReleaseNotes
+		message += "\n\n" + rec.ReleaseNotes.Markdown()
+	}
+	if len(rec.Issues) > 0 {
+		fields["issues"] = issueLinks(rec.Issues)
+	}
+	notifier().Notify(notify.Event{
+		Type:          notify.EventDeploymentSucceeded,
+		App:           rec.App,
+		Message:       message,
+		Fields:        fields,
+		CorrelationID: rec.CorrelationID,
+	})
+}
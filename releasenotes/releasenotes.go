@@ -0,0 +1,176 @@
+// Package releasenotes builds a changelog from commit subjects, grouped by
+// conventional-commit type (feat, fix, ...)
+package releasenotes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"binaryDeploy/semver"
+)
+
+// Commit is one deployed commit
+type Commit struct {
+	SHA     string
+	Subject string
+}
+
+// Change is a commit as it appears in the notes
+type Change struct {
+	SHA      string `json:"sha"`
+	Type     string `json:"type"`
+	Scope    string `json:"scope,omitempty"`
+	Summary  string `json:"summary"`
+	Breaking bool   `json:"breaking,omitempty"`
+}
+
+// Section groups the changes of one type
+type Section struct {
+	Type    string   `json:"type"`
+	Title   string   `json:"title"`
+	Changes []Change `json:"changes"`
+}
+
+// Notes is the changelog of one deployment
+type Notes struct {
+	From     string    `json:"from,omitempty"` // Previously deployed commit
+	To       string    `json:"to"`
+	Sections []Section `json:"sections"`
+	Commits  int       `json:"commits"`
+}
+
+// sectionOrder lists the conventional-commit types in the order they appear,
+// with their headings. Anything else is grouped under "other".
+var sectionOrder = []struct{ Type, Title string }{
+	{"breaking", "Breaking Changes"},
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"revert", "Reverts"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build"},
+	{"ci", "CI"},
+	{"chore", "Chores"},
+	{"other", "Other Changes"},
+}
+
+// conventional matches "type(scope)!: summary"
+var conventional = regexp.MustCompile(`^([a-zA-Z]+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+// aliases maps common spellings to their conventional type
+var aliases = map[string]string{
+	"feature": "feat",
+	"bugfix":  "fix",
+	"hotfix":  "fix",
+	"doc":     "docs",
+	"tests":   "test",
+}
+
+// ParseSubject classifies a commit subject
+func ParseSubject(sha, subject string) Change {
+	subject = strings.TrimSpace(subject)
+	change := Change{SHA: sha, Type: "other", Summary: subject}
+
+	m := conventional.FindStringSubmatch(subject)
+	if m == nil {
+		return change
+	}
+	kind := strings.ToLower(m[1])
+	if alias, ok := aliases[kind]; ok {
+		kind = alias
+	}
+	if !knownType(kind) {
+		return change
+	}
+	change.Type = kind
+	change.Scope = m[2]
+	change.Breaking = m[3] == "!"
+	change.Summary = m[4]
+	return change
+}
+
+func knownType(kind string) bool {
+	for _, s := range sectionOrder {
+		if s.Type == kind && kind != "breaking" && kind != "other" {
+			return true
+		}
+	}
+	return false
+}
+
+// Build groups commits, newest first, into notes for the range from..to.
+// Merge commits are left out. Breaking changes are listed both under
+// "Breaking Changes" and their own type.
+func Build(from, to string, commits []Commit) Notes {
+	grouped := make(map[string][]Change)
+	count := 0
+	for _, c := range commits {
+		if strings.HasPrefix(c.Subject, "Merge ") {
+			continue
+		}
+		count++
+		change := ParseSubject(c.SHA, c.Subject)
+		if change.Breaking {
+			grouped["breaking"] = append(grouped["breaking"], change)
+		}
+		grouped[change.Type] = append(grouped[change.Type], change)
+	}
+
+	notes := Notes{From: from, To: to, Commits: count}
+	for _, s := range sectionOrder {
+		if changes := grouped[s.Type]; len(changes) > 0 {
+			notes.Sections = append(notes.Sections, Section{Type: s.Type, Title: s.Title, Changes: changes})
+		}
+	}
+	return notes
+}
+
+// Empty reports whether the notes contain no changes
+func (n Notes) Empty() bool {
+	return n.Commits == 0
+}
+
+// Markdown renders the notes as a Markdown changelog
+func (n Notes) Markdown() string {
+	var b strings.Builder
+	if n.From != "" {
+		fmt.Fprintf(&b, "Changes from %s to %s (%d commits)\n", short(n.From), short(n.To), n.Commits)
+	} else {
+		fmt.Fprintf(&b, "Changes in %s\n", short(n.To))
+	}
+	for _, s := range n.Sections {
+		fmt.Fprintf(&b, "\n### %s\n\n", s.Title)
+		for _, c := range s.Changes {
+			b.WriteString("- ")
+			if c.Scope != "" {
+				fmt.Fprintf(&b, "**%s:** ", c.Scope)
+			}
+			fmt.Fprintf(&b, "%s (%s)\n", c.Summary, short(c.SHA))
+		}
+	}
+	return b.String()
+}
+
+func short(sha string) string {
+	return sha[:min(8, len(sha))]
+}
+
+// BumpLevel returns the semantic version bump the notes call for: major for
+// breaking changes, minor for features and patch for anything else
+func (n Notes) BumpLevel() int {
+	level := semver.None
+	for _, s := range n.Sections {
+		switch s.Type {
+		case "breaking":
+			return semver.Major
+		case "feat":
+			level = max(level, semver.Minor)
+		default:
+			level = max(level, semver.Patch)
+		}
+	}
+	return level
+}
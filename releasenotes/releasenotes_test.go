@@ -0,0 +1,79 @@
+package releasenotes
+
+import (
+	"strings"
+	"testing"
+
+	"binaryDeploy/semver"
+)
+
+func TestParseSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    Change
+	}{
+		{"feat(api): add /timeline", Change{Type: "feat", Scope: "api", Summary: "add /timeline"}},
+		{"fix!: drop legacy config keys", Change{Type: "fix", Summary: "drop legacy config keys", Breaking: true}},
+		{"Bugfix: handle empty payloads", Change{Type: "fix", Summary: "handle empty payloads"}},
+		{"Update README", Change{Type: "other", Summary: "Update README"}},
+		{"WIP: something", Change{Type: "other", Summary: "WIP: something"}},
+	}
+	for _, tt := range tests {
+		got := ParseSubject("", tt.subject)
+		if got != tt.want {
+			t.Errorf("ParseSubject(%q) = %+v, want %+v", tt.subject, got, tt.want)
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	notes := Build("aaaaaaaaaaaa", "bbbbbbbbbbbb", []Commit{
+		{SHA: "1111111111", Subject: "feat(ui)!: new dashboard layout"},
+		{SHA: "2222222222", Subject: "Merge pull request #7 from team/branch"},
+		{SHA: "3333333333", Subject: "fix: crash on empty branch"},
+		{SHA: "4444444444", Subject: "bump deps"},
+		{SHA: "5555555555", Subject: "feat: chat-ops"},
+	})
+
+	if notes.Commits != 4 {
+		t.Errorf("expected merge commits to be skipped, got %d commits", notes.Commits)
+	}
+	var order []string
+	for _, s := range notes.Sections {
+		order = append(order, s.Type)
+	}
+	if strings.Join(order, ",") != "breaking,feat,fix,other" {
+		t.Errorf("unexpected section order %v", order)
+	}
+	if len(notes.Sections[1].Changes) != 2 {
+		t.Errorf("expected two features, got %+v", notes.Sections[1].Changes)
+	}
+
+	md := notes.Markdown()
+	for _, want := range []string{"Changes from aaaaaaaa to bbbbbbbb (4 commits)", "### Breaking Changes", "- **ui:** new dashboard layout (11111111)", "### Other Changes\n\n- bump deps (44444444)"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in:\n%s", want, md)
+		}
+	}
+}
+
+func TestBumpLevel(t *testing.T) {
+	tests := []struct {
+		subjects []string
+		want     int
+	}{
+		{nil, semver.None},
+		{[]string{"docs: typo", "tidy up"}, semver.Patch},
+		{[]string{"fix: crash", "feat: export"}, semver.Minor},
+		{[]string{"feat: export", "refactor!: rename config keys"}, semver.Major},
+	}
+	for _, tt := range tests {
+		var commits []Commit
+		for _, s := range tt.subjects {
+			commits = append(commits, Commit{Subject: s})
+		}
+		if got := Build("", "", commits).BumpLevel(); got != tt.want {
+			t.Errorf("BumpLevel(%v) = %s, want %s", tt.subjects, semver.LevelName(got), semver.LevelName(tt.want))
+		}
+	}
+}
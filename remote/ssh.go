@@ -0,0 +1,132 @@
+// Package remote deploys built releases to hosts that don't run binaryDeploy
+// themselves, using the system ssh and rsync binaries
+package remote
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Host is an SSH destination, parsed from "[user@]host[:port]"
+type Host struct {
+	User string
+	Name string
+	Port int
+}
+
+// ParseHost parses "[user@]host[:port]"
+func ParseHost(spec string) (Host, error) {
+	var h Host
+	rest := strings.TrimSpace(spec)
+	if user, host, ok := strings.Cut(rest, "@"); ok {
+		h.User, rest = user, host
+	}
+	if name, port, err := net.SplitHostPort(rest); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 1 || p > 65535 {
+			return Host{}, fmt.Errorf("ssh host %q: invalid port %q", spec, port)
+		}
+		h.Name, h.Port = name, p
+	} else {
+		h.Name = strings.Trim(rest, "[]")
+	}
+	if h.Name == "" || strings.HasPrefix(h.Name, "-") || strings.HasPrefix(h.User, "-") {
+		return Host{}, fmt.Errorf("invalid ssh host %q", spec)
+	}
+	return h, nil
+}
+
+// destination is the user@host form ssh and rsync expect
+func (h Host) destination() string {
+	if h.User == "" {
+		return h.Name
+	}
+	return h.User + "@" + h.Name
+}
+
+// String returns the host in the form it was configured
+func (h Host) String() string {
+	s := h.destination()
+	if h.Port != 0 {
+		s += ":" + strconv.Itoa(h.Port)
+	}
+	return s
+}
+
+// Options control how ssh connects. Host keys are always verified.
+type Options struct {
+	KeyFile        string // Private key; ssh's defaults when empty
+	KnownHostsFile string // Known hosts; ~/.ssh/known_hosts when empty
+}
+
+// sshArgs returns the ssh options shared by ssh and rsync's remote shell
+func (o Options) sshArgs(h Host) []string {
+	args := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=yes"}
+	if o.KeyFile != "" {
+		args = append(args, "-i", o.KeyFile, "-o", "IdentitiesOnly=yes")
+	}
+	if o.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+o.KnownHostsFile)
+	}
+	if h.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.Port))
+	}
+	return args
+}
+
+// SyncCommand returns the rsync invocation that mirrors paths (relative to
+// localDir) into remoteDir on h. .git is never copied.
+func SyncCommand(h Host, opts Options, localDir string, paths []string, remoteDir string) *exec.Cmd {
+	rsh := append([]string{"ssh"}, opts.sshArgs(h)...)
+	args := []string{"-az", "--delete-after", "--relative", "--exclude", ".git", "-e", shellJoin(rsh), "--"}
+	for _, p := range paths {
+		args = append(args, "./"+strings.TrimPrefix(p, "./"))
+	}
+	args = append(args, h.destination()+":"+strings.TrimRight(remoteDir, "/")+"/")
+
+	cmd := exec.Command("rsync", args...)
+	cmd.Dir = localDir
+	return cmd
+}
+
+// RunCommand returns the ssh invocation that runs command in remoteDir on h
+func RunCommand(h Host, opts Options, remoteDir, command string) *exec.Cmd {
+	args := append(opts.sshArgs(h), "--", h.destination(), "cd "+shellQuote(remoteDir)+" && "+command)
+	return exec.Command("ssh", args...)
+}
+
+// SFTPCommand returns the sftp invocation that runs the batch script in
+// batchFile against h, aborting on the first failing command
+func SFTPCommand(h Host, opts Options, batchFile string) *exec.Cmd {
+	// sftp takes the port as -P, so leave it out of the shared ssh options
+	args := append([]string{"-b", batchFile}, opts.sshArgs(Host{Name: h.Name})...)
+	if h.Port != 0 {
+		args = append(args, "-P", strconv.Itoa(h.Port))
+	}
+	args = append(args, "--", h.destination())
+	return exec.Command("sftp", args...)
+}
+
+// Run runs cmd with its output going to ours, like the local build steps
+func Run(cmd *exec.Cmd) error {
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// shellQuote quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
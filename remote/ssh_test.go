@@ -0,0 +1,66 @@
+package remote
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHost(t *testing.T) {
+	tests := map[string]Host{
+		"web1":                 {Name: "web1"},
+		"deploy@web1":          {User: "deploy", Name: "web1"},
+		"deploy@web1:2222":     {User: "deploy", Name: "web1", Port: 2222},
+		"deploy@[2001:db8::1]": {User: "deploy", Name: "2001:db8::1"},
+	}
+	for spec, want := range tests {
+		got, err := ParseHost(spec)
+		if err != nil || got != want {
+			t.Errorf("ParseHost(%q) = %+v, %v; want %+v", spec, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"", "web1:0", "web1:ssh", "-oProxyCommand=x", "-x@web1"} {
+		if _, err := ParseHost(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestSyncCommand(t *testing.T) {
+	h := Host{User: "deploy", Name: "web1", Port: 2222}
+	cmd := SyncCommand(h, Options{KeyFile: "/keys/id_ed25519"}, "/srv/repo", []string{"bin/app", "./static"}, "/opt/app/")
+
+	args := strings.Join(cmd.Args, " ")
+	for _, want := range []string{"--exclude .git", "./bin/app ./static deploy@web1:/opt/app/", "'-p' '2222'", "'-i' '/keys/id_ed25519'", "StrictHostKeyChecking=yes"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected %q in %q", want, args)
+		}
+	}
+	if cmd.Dir != "/srv/repo" {
+		t.Errorf("expected rsync to run in the local release dir, got %q", cmd.Dir)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	cmd := RunCommand(Host{Name: "web1"}, Options{}, "/opt/my app", "sudo systemctl restart app")
+
+	last := cmd.Args[len(cmd.Args)-1]
+	if last != "cd '/opt/my app' && sudo systemctl restart app" {
+		t.Errorf("unexpected remote command %q", last)
+	}
+	if cmd.Args[len(cmd.Args)-2] != "web1" || cmd.Args[len(cmd.Args)-3] != "--" {
+		t.Errorf("expected the destination after --, got %v", cmd.Args)
+	}
+}
+
+func TestSFTPCommand(t *testing.T) {
+	cmd := SFTPCommand(Host{User: "site", Name: "shared.example.com", Port: 2222}, Options{}, "/tmp/batch")
+
+	args := strings.Join(cmd.Args, " ")
+	if !strings.HasPrefix(args, "sftp -b /tmp/batch ") || !strings.HasSuffix(args, "-P 2222 -- site@shared.example.com") {
+		t.Errorf("unexpected sftp command %q", args)
+	}
+	if strings.Contains(args, "-p 2222") {
+		t.Errorf("sftp takes the port as -P, got %q", args)
+	}
+}
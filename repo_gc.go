@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"binaryDeploy/schedule"
+)
+
+// repoMu serializes git operations on the target repository checkout, so
+// maintenance never runs in the middle of a fetch
+var repoMu sync.Mutex
+
+// gitGCResult describes one run of repository maintenance
+type gitGCResult struct {
+	Path       string `json:"path"`
+	SizeBefore int64  `json:"size_before"` // Bytes in .git
+	SizeAfter  int64  `json:"size_after"`
+	Duration   string `json:"duration"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// startGitGCScheduler runs git gc on the target repository checkout on the
+// configured git_gc_schedule. It returns immediately when no schedule is
+// configured.
+func startGitGCScheduler() {
+	if appConfig().GitGCSchedule == "" {
+		return
+	}
+
+	sched, err := schedule.Parse(appConfig().GitGCSchedule)
+	if err != nil {
+		slog.Error("Invalid git gc schedule, repository maintenance disabled", "schedule", appConfig().GitGCSchedule, "error", err)
+		return
+	}
+
+	go func() {
+		for {
+			next := sched.Next(time.Now())
+			if next.IsZero() {
+				slog.Warn("Git gc schedule never fires again, stopping scheduler", "schedule", sched.String())
+				return
+			}
+			time.Sleep(time.Until(next))
+			runGitGC()
+		}
+	}()
+}
+
+// errNoCheckout is returned by runGitGC before the first deployment
+var errNoCheckout = errors.New("repository has not been cloned yet")
+
+// runGitGC packs the target repository checkout and prunes unreachable
+// objects older than git_gc_prune
+func runGitGC() (gitGCResult, error) {
+	repoMu.Lock()
+	defer repoMu.Unlock()
+
+	repoDir := targetRepoDir()
+	result := gitGCResult{Path: repoDir}
+	gitDir := filepath.Join(repoDir, ".git")
+	if _, err := os.Stat(gitDir); err != nil {
+		slog.Info("Skipping git gc, repository has not been cloned", "path", repoDir)
+		return result, errNoCheckout
+	}
+
+	slog.Info("Running git gc", "path", repoDir, "prune", appConfig().GitGCPrune, "aggressive", appConfig().GitGCAggressive)
+	start := time.Now()
+	result.SizeBefore = dirSize(gitDir)
+
+	args := []string{"gc", "--quiet", "--prune=" + appConfig().GitGCPrune}
+	if appConfig().GitGCAggressive {
+		args = append(args, "--aggressive")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoDir
+	output, err := cmd.CombinedOutput()
+
+	elapsed := time.Since(start)
+	result.SizeAfter = dirSize(gitDir)
+	result.Duration = elapsed.Round(time.Millisecond).String()
+	result.DurationMS = elapsed.Milliseconds()
+	if err != nil {
+		err = fmt.Errorf("git gc: %w: %s", err, strings.TrimSpace(string(output)))
+		slog.Error("Git gc failed", "path", repoDir, "error", err)
+		return result, err
+	}
+
+	slog.Info("Git gc finished", "path", repoDir, "size_before", result.SizeBefore,
+		"size_after", result.SizeAfter, "duration", result.Duration)
+	return result, nil
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// appGCHandler serves POST /apps/{name}/gc, running repository maintenance
+// now and returning the result
+func appGCHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	result, err := runGitGC()
+	if errors.Is(err, errNoCheckout) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/testutil"
+)
+
+func postAppGC() *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	appGCHandler(rec, httptest.NewRequest(http.MethodPost, "/apps/myapp/gc", nil))
+	return rec
+}
+
+func TestAppGCHandler_PacksTheCheckout(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp", DeployDir: t.TempDir(), GitGCPrune: "now"})
+
+	if rec := postAppGC(); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 before the first clone, got %d %s", rec.Code, rec.Body)
+	}
+
+	repo := testutil.NewRepo(t, map[string]string{"main.go": "package main\n"})
+	for i := 0; i < 5; i++ {
+		repo.Commit("Change", map[string]string{"VERSION": strconv.Itoa(i) + "\n"})
+	}
+	if out, err := exec.Command("git", "clone", "--quiet", "--no-local", repo.URL, targetRepoDir()).CombinedOutput(); err != nil {
+		t.Fatalf("git clone: %v: %s", err, out)
+	}
+	// Unpack into loose objects, which git gc packs again
+	if out, err := exec.Command("sh", "-c", "cd "+targetRepoDir()+" && mv .git/objects/pack/*.pack pack && git unpack-objects -q < pack && rm pack .git/objects/pack/*").CombinedOutput(); err != nil {
+		t.Fatalf("unpacking: %v: %s", err, out)
+	}
+	loose, _ := filepath.Glob(filepath.Join(targetRepoDir(), ".git", "objects", "??"))
+	if len(loose) == 0 {
+		t.Fatal("Expected loose objects before git gc")
+	}
+
+	rec := postAppGC()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	var result gitGCResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Path != targetRepoDir() || result.SizeBefore == 0 || result.SizeAfter == 0 || result.Duration == "" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if loose, _ := filepath.Glob(filepath.Join(targetRepoDir(), ".git", "objects", "??")); len(loose) != 0 {
+		t.Errorf("Expected git gc to pack the loose objects, %d directories left", len(loose))
+	}
+	if packs, _ := filepath.Glob(filepath.Join(targetRepoDir(), ".git", "objects", "pack", "*.pack")); len(packs) != 1 {
+		t.Errorf("Expected one pack after git gc, got %v", packs)
+	}
+}
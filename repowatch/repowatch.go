@@ -0,0 +1,60 @@
+// Package repowatch reports commits to a local git repository, for
+// deploying from file:// repositories in setups where nothing can send a
+// webhook
+package repowatch
+
+import (
+	"context"
+	"time"
+)
+
+// debounce coalesces the burst of changes one commit or push makes
+const debounce = 500 * time.Millisecond
+
+// pollInterval is how often refs are checked where inotify isn't available
+const pollInterval = 2 * time.Second
+
+// Watch calls onChange whenever HEAD, packed-refs or a branch of the
+// repository whose git directory is gitDir changes, until ctx is done.
+// Changes arriving within debounce of each other result in one call, and
+// calls never overlap. onChange may be called for changes that don't move
+// any ref, so callers compare the commit they care about.
+func Watch(ctx context.Context, gitDir string, onChange func()) error {
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+	if err := watch(ctx, gitDir, notify); err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changes:
+			}
+
+			// Wait for the changes to settle
+			timer := time.NewTimer(debounce)
+		settle:
+			for {
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-changes:
+					timer.Reset(debounce)
+				case <-timer.C:
+					break settle
+				}
+			}
+			onChange()
+		}
+	}()
+	return nil
+}
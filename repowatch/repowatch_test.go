@@ -0,0 +1,83 @@
+package repowatch
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"binaryDeploy/testutil"
+)
+
+func watchRepo(t *testing.T, repo *testutil.Repo) <-chan struct{} {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	changes := make(chan struct{}, 10)
+	err := Watch(ctx, filepath.Join(repo.Dir, ".git"), func() { changes <- struct{}{} })
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	return changes
+}
+
+func expectChange(t *testing.T, changes <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-changes:
+	case <-time.After(3*pollInterval + debounce):
+		t.Fatalf("expected a change after %s", what)
+	}
+}
+
+func TestWatch_Commit(t *testing.T) {
+	repo := testutil.NewRepo(t, map[string]string{"a.txt": "1"})
+	changes := watchRepo(t, repo)
+
+	repo.Commit("Second", map[string]string{"a.txt": "2"})
+	expectChange(t, changes, "a commit")
+
+	// The commit's burst of writes is reported once
+	select {
+	case <-changes:
+		t.Error("expected one change per commit")
+	case <-time.After(2 * debounce):
+	}
+}
+
+func TestWatch_NestedBranch(t *testing.T) {
+	repo := testutil.NewRepo(t, map[string]string{"a.txt": "1"})
+	changes := watchRepo(t, repo)
+
+	repo.Branch("feature/login")
+	expectChange(t, changes, "creating a branch")
+
+	repo.Commit("On the branch", map[string]string{"b.txt": "1"})
+	expectChange(t, changes, "a commit to a nested branch")
+}
+
+func TestWatch_StopsWithContext(t *testing.T) {
+	repo := testutil.NewRepo(t, map[string]string{"a.txt": "1"})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	changes := make(chan struct{}, 10)
+	if err := Watch(ctx, filepath.Join(repo.Dir, ".git"), func() { changes <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	repo.Commit("Unwatched", nil)
+	select {
+	case <-changes:
+		t.Error("expected no changes after the context is done")
+	case <-time.After(2 * debounce):
+	}
+}
+
+func TestWatch_MissingRepository(t *testing.T) {
+	if err := Watch(context.Background(), filepath.Join(t.TempDir(), "missing"), func() {}); err == nil {
+		t.Error("expected an error for a missing git directory")
+	}
+}
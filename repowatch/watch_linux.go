@@ -0,0 +1,125 @@
+//go:build linux
+
+package repowatch
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// watchMask selects the events that can mean a ref was written. Git writes
+// refs to a .lock file and renames it into place.
+const watchMask = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM
+
+// watch notifies on changes in gitDir using inotify
+func watch(ctx context.Context, gitDir string, notify func()) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+	// A non-blocking descriptor is read through the runtime poller, so
+	// closing the file ends the read loop
+	f := os.NewFile(uintptr(fd), "inotify")
+	w := &inotifyWatcher{fd: fd, gitDir: gitDir, dirs: map[int32]string{}}
+
+	if err := w.add(gitDir); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.addTree(filepath.Join(gitDir, "refs", "heads")); err != nil {
+		f.Close()
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+	go w.read(f, notify)
+	return nil
+}
+
+type inotifyWatcher struct {
+	fd     int
+	gitDir string
+	dirs   map[int32]string // Watch descriptor to directory; only used by read after setup
+}
+
+// add watches dir
+func (w *inotifyWatcher) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", dir, err)
+	}
+	w.dirs[int32(wd)] = dir
+	return nil
+}
+
+// addTree watches dir and its subdirectories, which hold branches with
+// slashes in their names
+func (w *inotifyWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return w.add(path)
+	})
+}
+
+// read turns inotify events into notifications until f is closed
+func (w *inotifyWatcher) read(f *os.File, notify func()) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			start := off + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[start:start+nameLen]), "\x00")
+			off = start + nameLen
+
+			if mask&syscall.IN_Q_OVERFLOW != 0 {
+				notify()
+				continue
+			}
+			dir, ok := w.dirs[wd]
+			if !ok || name == "" {
+				continue
+			}
+			if dir == w.gitDir {
+				// The git directory itself is busy (index, logs, ...); only
+				// HEAD and packed-refs matter there
+				if name == "HEAD" || name == "packed-refs" {
+					notify()
+				}
+				continue
+			}
+			if mask&syscall.IN_ISDIR != 0 {
+				if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					// A new branch namespace, e.g. refs/heads/feature/
+					w.addTree(filepath.Join(dir, name))
+					notify()
+				}
+				continue
+			}
+			if !strings.HasSuffix(name, ".lock") {
+				notify()
+			}
+		}
+	}
+}
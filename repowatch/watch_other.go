@@ -0,0 +1,63 @@
+//go:build !linux
+
+package repowatch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watch notifies on changes in gitDir by polling, where inotify isn't
+// available
+func watch(ctx context.Context, gitDir string, notify func()) error {
+	last, err := refsFingerprint(gitDir)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current, err := refsFingerprint(gitDir)
+			if err == nil && current != last {
+				last = current
+				notify()
+			}
+		}
+	}()
+	return nil
+}
+
+// refsFingerprint summarizes the size and modification time of HEAD,
+// packed-refs and every branch
+func refsFingerprint(gitDir string) (string, error) {
+	var b strings.Builder
+	for _, name := range []string{"HEAD", "packed-refs"} {
+		if info, err := os.Stat(filepath.Join(gitDir, name)); err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	err := filepath.WalkDir(filepath.Join(gitDir, "refs", "heads"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while walking
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return b.String(), err
+}
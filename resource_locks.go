@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// resourceLockPoll is how often a deployment waiting for a shared resource
+// tries the lock again
+const resourceLockPoll = time.Second
+
+// errResourceBusy is returned when a shared resource stays locked by another
+// deployment for resource_lock_timeout
+var errResourceBusy = errors.New("shared resource busy")
+
+// lockSharedResources takes the lock of every resource in
+// shared_resources, waiting while other deployments, of this or any other
+// app, hold them. Locks are taken in name order so two apps sharing several
+// resources can't deadlock. The returned function releases them all.
+func lockSharedResources(req DeployRequest) (release func(), err error) {
+	resources := slices.Clone(appConfig().SharedResources)
+	slices.Sort(resources)
+	resources = slices.Compact(resources)
+
+	if err := os.MkdirAll(appConfig().ResourceLockDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating resource lock directory: %w", err)
+	}
+
+	var releases []func()
+	release = func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, resource := range resources {
+		unlock, err := lockSharedResource(resource, req)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, unlock)
+	}
+	return release, nil
+}
+
+// lockSharedResource waits up to resource_lock_timeout for resource and
+// records req as its holder, so deployments waiting for it can say which
+// deployment they are waiting on
+func lockSharedResource(resource string, req DeployRequest) (func(), error) {
+	path := filepath.Join(appConfig().ResourceLockDir, resource+".lock")
+	holderPath := filepath.Join(appConfig().ResourceLockDir, resource+".holder")
+
+	var deadline time.Time
+	if appConfig().ResourceLockTimeout > 0 {
+		deadline = time.Now().Add(time.Duration(appConfig().ResourceLockTimeout) * time.Second)
+	}
+	waitStart := time.Now()
+	for {
+		unlock, err := tryLockFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if unlock != nil {
+			holder := fmt.Sprintf("%s pid %d correlation_id %s since %s", appConfig().AppName, os.Getpid(),
+				req.CorrelationID, time.Now().Format(time.RFC3339))
+			if err := os.WriteFile(holderPath, []byte(holder+"\n"), 0644); err != nil {
+				slog.Warn("Failed to record shared resource holder", "resource", resource, "error", err)
+			}
+			if waited := time.Since(waitStart); waited >= resourceLockPoll {
+				slog.Info("Acquired shared resource", "resource", resource, "waited", waited.Round(time.Second).String())
+			}
+			return func() {
+				os.Remove(holderPath)
+				unlock()
+			}, nil
+		}
+
+		holder := resourceHolder(holderPath)
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s is still held by %s after %ds (resource_lock_timeout)",
+				errResourceBusy, resource, holder, appConfig().ResourceLockTimeout)
+		}
+		if time.Since(waitStart) < resourceLockPoll {
+			slog.Info("Waiting for shared resource held by another deployment", "resource", resource, "holder", holder)
+		}
+		time.Sleep(resourceLockPoll)
+	}
+}
+
+// resourceHolder describes the deployment holding a shared resource
+func resourceHolder(holderPath string) string {
+	holder, err := os.ReadFile(holderPath)
+	if err != nil || len(holder) == 0 {
+		return "another deployment"
+	}
+	return strings.TrimSpace(string(holder))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestLockSharedResources_SerializesDeployments(t *testing.T) {
+	lockDir := t.TempDir()
+	currentConfig.Store(&config.DeployConfig{
+		AppName:             "billing",
+		SharedResources:     []string{"orders-db", "cache", "orders-db"},
+		ResourceLockDir:     lockDir,
+		ResourceLockTimeout: 1,
+	})
+
+	release, err := lockSharedResources(DeployRequest{CorrelationID: "deploy-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder, err := os.ReadFile(filepath.Join(lockDir, "orders-db.holder"))
+	if err != nil || !strings.Contains(string(holder), "billing") || !strings.Contains(string(holder), "deploy-1") {
+		t.Errorf("Expected the holder to be recorded, got %q, %v", holder, err)
+	}
+
+	// Another app sharing only the database waits, then gives up
+	currentConfig.Store(&config.DeployConfig{
+		AppName:             "shop",
+		SharedResources:     []string{"orders-db"},
+		ResourceLockDir:     lockDir,
+		ResourceLockTimeout: 1,
+	})
+	_, err = lockSharedResources(DeployRequest{CorrelationID: "deploy-2"})
+	if !errors.Is(err, errResourceBusy) || !strings.Contains(err.Error(), "deploy-1") {
+		t.Fatalf("Expected the resource to be busy with deploy-1, got %v", err)
+	}
+
+	// Once released while the other deployment waits, it gets the resource
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := lockSharedResources(DeployRequest{CorrelationID: "deploy-3"})
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+	release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("Expected the waiting deployment to get the resource, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The waiting deployment never got the resource")
+	}
+
+	if _, err := os.Stat(filepath.Join(lockDir, "orders-db.holder")); !os.IsNotExist(err) {
+		t.Error("Expected the holder record to be removed on release")
+	}
+}
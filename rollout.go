@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"binaryDeploy/config"
+	"binaryDeploy/templating"
+)
+
+// startInstances replaces the running instances one at a time, waiting for
+// each to pass its health check before moving on, so the instances not yet
+// replaced keep serving throughout the deployment. A failing instance stops
+// the rollout with the remaining instances still on the old version. data
+// resolves the placeholders of run_command.
+func startInstances(profile *deployProfile, deployConfig *config.DeployConfig, workingDir string, data templating.Data) error {
+	n := processManager.Size()
+	stepName := func(step string, i int) string {
+		if n == 1 {
+			return step
+		}
+		return fmt.Sprintf("%s[%d]", step, i)
+	}
+
+	for i := 0; i < n; i++ {
+		if n > 1 {
+			slog.Info("Replacing instance", "instance", i, "instances", n)
+		}
+
+		err := profile.step(stepName("start", i), func() error {
+			cfg, err := instanceConfig(deployConfig, i, data)
+			if err != nil {
+				return err
+			}
+			return processManager.StartInstance(i, cfg, workingDir)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start application process: %w", err)
+		}
+
+		if err := profile.step(stepName("health", i), func() error { return waitForInstanceReady(i) }); err != nil {
+			if n == 1 {
+				return fmt.Errorf("application did not become healthy: %w", err)
+			}
+			return fmt.Errorf("instance %d did not become healthy, %d instance(s) left on the previous version: %w", i, n-i-1, err)
+		}
+	}
+
+	if n > 1 {
+		slog.Info("All instances replaced", "instances", n)
+	}
+	return nil
+}
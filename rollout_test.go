@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/processmanager"
+	"binaryDeploy/templating"
+)
+
+// instanceHealthServers stands in for n instances listening on consecutive
+// loopback ports, answering 503 for the instances in unhealthy. It returns
+// the first port and the number of health checks each instance got.
+func instanceHealthServers(t *testing.T, n int, unhealthy ...int) (int, []*atomic.Int32) {
+	t.Helper()
+	for base := 29300; base < 29900; base += n {
+		var listeners []net.Listener
+		for i := 0; i < n; i++ {
+			l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(base+i)))
+			if err != nil {
+				break
+			}
+			listeners = append(listeners, l)
+		}
+		if len(listeners) < n {
+			for _, l := range listeners {
+				l.Close()
+			}
+			continue
+		}
+
+		checks := make([]*atomic.Int32, n)
+		for i, l := range listeners {
+			count, healthy := &atomic.Int32{}, !slices.Contains(unhealthy, i)
+			checks[i] = count
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				count.Add(1)
+				if !healthy {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			server.Listener.Close()
+			server.Listener = l
+			server.Start()
+			t.Cleanup(server.Close)
+		}
+		return base, checks
+	}
+	t.Fatal("No free consecutive loopback ports")
+	return 0, nil
+}
+
+// setupRolloutTest returns the deploy.config of n instances whose health
+// checks go to instanceHealthServers
+func setupRolloutTest(t *testing.T, n int, unhealthy ...int) (*config.DeployConfig, []*atomic.Int32) {
+	t.Helper()
+	port, checks := instanceHealthServers(t, n, unhealthy...)
+	cfg := &config.DeployConfig{
+		RunCommand:         "exec sleep 30",
+		ApplicationPort:    port,
+		HealthCheckURL:     "/healthz",
+		HealthCheckTimeout: 1,
+	}
+	currentConfig.Store(cfg)
+	group := processmanager.NewGroup(n)
+	t.Cleanup(func() { group.Shutdown() })
+	processManager = group
+	return cfg, checks
+}
+
+func TestStartInstances_ReplacesEachInstanceOnceHealthy(t *testing.T) {
+	cfg, checks := setupRolloutTest(t, 3)
+	profile := &deployProfile{}
+
+	if err := startInstances(profile, cfg, t.TempDir(), templating.Data{}); err != nil {
+		t.Fatalf("startInstances: %v", err)
+	}
+	for i := range checks {
+		if !processManager.InstanceRunning(i) || checks[i].Load() == 0 {
+			t.Errorf("Expected instance %d started and health checked", i)
+		}
+	}
+
+	var steps []string
+	for _, step := range profile.Steps() {
+		steps = append(steps, step.Name)
+	}
+	want := []string{"start[0]", "health[0]", "start[1]", "health[1]", "start[2]", "health[2]"}
+	if !slices.Equal(steps, want) {
+		t.Errorf("Expected each instance to pass its health check before the next starts, got %v", steps)
+	}
+}
+
+func TestStartInstances_StopsAtUnhealthyInstance(t *testing.T) {
+	cfg, checks := setupRolloutTest(t, 3, 1)
+
+	err := startInstances(nil, cfg, t.TempDir(), templating.Data{})
+	if err == nil || !strings.Contains(err.Error(), "instance 1 did not become healthy, 1 instance(s) left on the previous version") {
+		t.Fatalf("Expected the rollout to stop at instance 1, got %v", err)
+	}
+	if processManager.InstanceRunning(2) || checks[2].Load() != 0 {
+		t.Error("Expected instance 2 to be left alone")
+	}
+	if !processManager.InstanceRunning(0) {
+		t.Error("Expected the healthy instance 0 to keep running")
+	}
+}
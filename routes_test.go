@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/processmanager"
+)
+
+func TestSetupRoutes_DeploymentEndpointsRequireAPIToken(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL: "https://git.example.com/team/app.git",
+		APIToken:      "routes-test-token",
+	})
+	processManager = processmanager.NewGroup(1)
+	routes := setupRoutes()
+
+	for _, path := range []string{"/deploy", "/update-target", "/update-self", "/update-self?dry_run=true", "/update-self/rollback"} {
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("POST %s without a token: expected 401, got %d", path, rec.Code)
+		}
+
+		// A wrong method gets past authentication without starting anything
+		req := httptest.NewRequest(http.MethodPut, path, nil)
+		req.Header.Set("Authorization", "Bearer routes-test-token")
+		rec = httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		if rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden {
+			t.Errorf("PUT %s with the token: expected authentication to pass, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestSetupRoutes_ProfilingRequiresAPIToken(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL: "https://git.example.com/team/app.git",
+		APIToken:      "pprof-test-token",
+	})
+	processManager = processmanager.NewGroup(1)
+	routes := setupRoutes()
+
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected pprof to require the api_token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil)
+	req.Header.Set("Authorization", "Bearer pprof-test-token")
+	rec = httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected a goroutine profile with the api_token, got %d", rec.Code)
+	}
+}
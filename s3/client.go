@@ -0,0 +1,249 @@
+// Package s3 is a minimal client for S3-compatible object storage (AWS S3,
+// MinIO, Ceph, R2, ...) using path-style requests and Signature Version 4
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// unsignedPayload lets bodies stream without hashing them up front
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Config locates a bucket and the credentials used to access it
+type Config struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Region    string // Defaults to us-east-1
+	Bucket    string
+	Prefix    string // Prepended to every key, e.g. "binaryDeploy/myapp"
+	AccessKey string
+	SecretKey string
+}
+
+// Client reads and writes objects in one bucket
+type Client struct {
+	cfg      Config
+	endpoint *url.URL
+	http     *http.Client
+	now      func() time.Time
+}
+
+// New validates cfg and returns a client for its bucket
+func New(cfg Config) (*Client, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("S3 access key and secret key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	return &Client{cfg: cfg, endpoint: endpoint, http: &http.Client{}, now: time.Now}, nil
+}
+
+// String describes the bucket location for logs, without credentials
+func (c *Client) String() string {
+	return fmt.Sprintf("s3://%s/%s", c.cfg.Bucket, c.cfg.Prefix)
+}
+
+// Put uploads size bytes from body to key
+func (c *Client) Put(key string, body io.Reader, size int64) error {
+	req, err := c.newRequest(http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get opens key for reading. A missing key returns an error wrapping
+// fs.ErrNotExist.
+func (c *Client) Get(key string) (io.ReadCloser, error) {
+	req, err := c.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Exists reports whether key is present
+func (c *Client) Exists(key string) (bool, error) {
+	req, err := c.newRequest(http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
+}
+
+// newRequest builds a signed request for key
+func (c *Client) newRequest(method, key string, body io.Reader) (*http.Request, error) {
+	path := "/" + c.cfg.Bucket + "/" + strings.TrimLeft(c.objectKey(key), "/")
+	u := *c.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	u.RawPath = strings.TrimRight(c.endpoint.EscapedPath(), "/") + encodePath(path)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, c.now().UTC())
+	return req, nil
+}
+
+// objectKey prepends the configured prefix
+func (c *Client) objectKey(key string) string {
+	if c.cfg.Prefix == "" {
+		return key
+	}
+	return c.cfg.Prefix + "/" + key
+}
+
+// do sends req and turns error statuses into errors
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s %s: %w", req.Method, req.URL.Path, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("S3 %s %s: %w", req.Method, req.URL.Path, fs.ErrNotExist)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("S3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (c *Client) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	// Go sends Host from req.Host rather than the header map
+	names := []string{"host"}
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + c.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretKey), date)
+	key = hmacSHA256(key, c.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by key as SigV4 requires
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, encode(k, true)+"="+encode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// encodePath URI-encodes every segment of an object path
+func encodePath(path string) string {
+	return encode(path, false)
+}
+
+// encode percent-encodes everything but unreserved characters (and '/' in
+// paths), as SigV4 requires
+func encode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || (ch == '/' && !encodeSlash) {
+			b.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package s3
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBucket is an in-memory S3 endpoint that checks requests are signed
+func fakeBucket(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") {
+			t.Errorf("unexpected Authorization header %q", auth)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet, http.MethodHead:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+}
+
+func TestClient_RoundTrip(t *testing.T) {
+	server := fakeBucket(t)
+	defer server.Close()
+
+	client, err := New(Config{Endpoint: server.URL, Bucket: "deploys", Prefix: "/myapp/", AccessKey: "AKID", SecretKey: "secret"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if ok, err := client.Exists("objects/a b"); err != nil || ok {
+		t.Fatalf("expected missing object, got %v, %v", ok, err)
+	}
+
+	if err := client.Put("objects/a b", strings.NewReader("hello"), 5); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if ok, err := client.Exists("objects/a b"); err != nil || !ok {
+		t.Fatalf("expected object to exist, got %v, %v", ok, err)
+	}
+
+	body, err := client.Get("objects/a b")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer body.Close()
+	if data, _ := io.ReadAll(body); string(data) != "hello" {
+		t.Errorf("got %q, want hello", data)
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	for _, cfg := range []Config{
+		{Endpoint: "not a url", Bucket: "b", AccessKey: "a", SecretKey: "s"},
+		{Endpoint: "http://minio:9000", AccessKey: "a", SecretKey: "s"},
+		{Endpoint: "http://minio:9000", Bucket: "b"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	if got := encodePath("/bucket/a b+c/d~e"); got != "/bucket/a%20b%2Bc/d~e" {
+		t.Errorf("encodePath = %q", got)
+	}
+}
@@ -0,0 +1,177 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five-field cron expression (minute hour dom month dow)
+type Schedule struct {
+	Expr   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// Cron semantics: when both day-of-month and day-of-week are restricted,
+	// a time matches if either one does
+	domRestricted bool
+	dowRestricted bool
+}
+
+// shortcuts maps the common @-descriptors to their cron equivalents
+var shortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // 0 and 7 are both Sunday
+}
+
+// Parse parses a standard five-field cron expression or an @-shortcut
+func Parse(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	spec := expr
+	if s, ok := shortcuts[strings.ToLower(spec)]; ok {
+		spec = s
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %q: expected 5 fields, got %d", expr, len(parts))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, part := range parts {
+		b, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+
+	// Fold Sunday=7 onto Sunday=0
+	if bits[4]&(1<<7) != 0 {
+		bits[4] = bits[4]&^(1<<7) | 1
+	}
+
+	return &Schedule{
+		Expr:          expr,
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: parts[2] != "*",
+		dowRestricted: parts[4] != "*",
+	}, nil
+}
+
+// parseField parses one comma-separated cron field into a bit set
+func parseField(spec string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(spec, ",") {
+		rangePart, step := item, 1
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			rangePart = item[:idx]
+			s, err := strconv.Atoi(item[idx+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field: %q", f.name, item)
+			}
+			step = s
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range in %s field: %q", f.name, item)
+			}
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s field: %q", f.name, item)
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		if lo < f.min || hi > f.max || lo > hi {
+			return 0, fmt.Errorf("%s field out of range (%d-%d): %q", f.name, f.min, f.max, item)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time strictly after t that matches the schedule,
+// or the zero time if nothing matches within the next five years
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			// Truncate would round in UTC, off the hour in zones such as
+			// +05:30
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the day-of-month/day-of-week matching rules
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// String returns the original expression
+func (s *Schedule) String() string {
+	return s.Expr
+}
@@ -0,0 +1,83 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	}
+
+	for _, expr := range invalid {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Expected error parsing %q", expr)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	base := time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC) // Friday
+
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"@daily", time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"30 3 * * *", time.Date(2025, time.March, 15, 3, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, time.March, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 4 * * 1-5", time.Date(2025, time.March, 17, 4, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * *", time.Date(2025, time.April, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day-of-month or day-of-week may match when both are set
+		{"0 0 20 * 6", time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+		}
+		if next := s.Next(base); !next.Equal(tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.expr, tt.expected, next)
+		}
+	}
+}
+
+func TestSchedule_NextInHalfHourZone(t *testing.T) {
+	s, err := Parse("0 3 * * *")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	kolkata := time.FixedZone("IST", 5*60*60+30*60)
+	at := time.Date(2025, time.March, 14, 10, 30, 0, 0, kolkata)
+	expected := time.Date(2025, time.March, 15, 3, 0, 0, 0, kolkata)
+	if next := s.Next(at); !next.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, next)
+	}
+}
+
+func TestSchedule_NextIsStrictlyAfter(t *testing.T) {
+	s, err := Parse("30 10 * * *")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+
+	at := time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC)
+	if next := s.Next(at); !next.Equal(at.AddDate(0, 0, 1)) {
+		t.Errorf("Expected next run a day later, got %v", next)
+	}
+}
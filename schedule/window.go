@@ -0,0 +1,137 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const minutesPerWeek = 7 * 24 * 60
+
+// Window is a recurring weekly time range, e.g. "Fri 16:00-Mon 08:00", such
+// as a deployment freeze or notification quiet hours. Start and end are
+// minutes since Sunday 00:00; a window whose end is before its start wraps
+// around the week boundary.
+type Window struct {
+	Spec  string
+	start int
+	end   int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWindows parses a semicolon-separated list of windows. Each window is
+// either "Day HH:MM-Day HH:MM" (weekly) or "HH:MM-HH:MM" (every day).
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(part, "-", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("window %q: expected START-END", part)
+		}
+		startDay, startMin, err := parseWindowBound(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", part, err)
+		}
+		endDay, endMin, err := parseWindowBound(bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", part, err)
+		}
+		if (startDay < 0) != (endDay < 0) {
+			return nil, fmt.Errorf("window %q: either both or neither bound needs a weekday", part)
+		}
+
+		if startDay >= 0 {
+			windows = append(windows, Window{Spec: part, start: startDay*1440 + startMin, end: endDay*1440 + endMin})
+			continue
+		}
+
+		// Daily window: one weekly window per day
+		for day := 0; day < 7; day++ {
+			end := day*1440 + endMin
+			if endMin <= startMin {
+				end += 1440
+			}
+			windows = append(windows, Window{Spec: part, start: day*1440 + startMin, end: end % minutesPerWeek})
+		}
+	}
+	return windows, nil
+}
+
+// parseWindowBound parses "Fri 16:00" or "16:00"; day is -1 when omitted
+func parseWindowBound(bound string) (day, minute int, err error) {
+	fields := strings.Fields(bound)
+	day = -1
+	switch len(fields) {
+	case 1:
+	case 2:
+		wd, ok := weekdays[strings.ToLower(fields[0])[:min(3, len(fields[0]))]]
+		if !ok {
+			return 0, 0, fmt.Errorf("unknown weekday %q", fields[0])
+		}
+		day = int(wd)
+	default:
+		return 0, 0, fmt.Errorf("invalid bound %q", bound)
+	}
+
+	t, err := time.Parse("15:04", fields[len(fields)-1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q", fields[len(fields)-1])
+	}
+	return day, t.Hour()*60 + t.Minute(), nil
+}
+
+// minuteOfWeek returns the minutes since Sunday 00:00 for t
+func minuteOfWeek(t time.Time) int {
+	return int(t.Weekday())*1440 + t.Hour()*60 + t.Minute()
+}
+
+// Contains reports whether t falls inside the window
+func (w Window) Contains(t time.Time) bool {
+	m := minuteOfWeek(t)
+	if w.start <= w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// EndAfter returns the first time the window ends after t
+func (w Window) EndAfter(t time.Time) time.Time {
+	delta := (w.end - minuteOfWeek(t) + minutesPerWeek) % minutesPerWeek
+	if delta == 0 {
+		delta = minutesPerWeek
+	}
+	return t.Truncate(time.Minute).Add(time.Duration(delta) * time.Minute)
+}
+
+// ActiveWindow returns the window containing t and when the freeze lifts.
+// Overlapping or back-to-back windows are followed until none applies.
+func ActiveWindow(windows []Window, t time.Time) (*Window, time.Time, bool) {
+	var active *Window
+	until := t
+	for i := 0; i <= len(windows); i++ {
+		found := false
+		for j := range windows {
+			if windows[j].Contains(until) {
+				if active == nil {
+					active = &windows[j]
+				}
+				until = windows[j].EndAfter(until)
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return active, until, active != nil
+}
@@ -0,0 +1,75 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// 2025-03-14 is a Friday
+func at(day, hour, minute int) time.Time {
+	return time.Date(2025, time.March, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestWindow_WeekendFreeze(t *testing.T) {
+	windows, err := ParseWindows("Fri 16:00-Mon 08:00")
+	if err != nil {
+		t.Fatalf("ParseWindows failed: %v", err)
+	}
+
+	tests := []struct {
+		t      time.Time
+		frozen bool
+	}{
+		{at(14, 15, 59), false},
+		{at(14, 16, 0), true},
+		{at(15, 12, 0), true},
+		{at(16, 23, 0), true},
+		{at(17, 7, 59), true},
+		{at(17, 8, 0), false},
+		{at(19, 12, 0), false},
+	}
+
+	for _, tt := range tests {
+		_, until, frozen := ActiveWindow(windows, tt.t)
+		if frozen != tt.frozen {
+			t.Errorf("%v: expected frozen=%v", tt.t, tt.frozen)
+		}
+		if frozen && !until.Equal(at(17, 8, 0)) {
+			t.Errorf("%v: expected freeze to lift Monday 08:00, got %v", tt.t, until)
+		}
+	}
+}
+
+func TestWindow_DailyWrapping(t *testing.T) {
+	windows, err := ParseWindows("22:00-06:00")
+	if err != nil {
+		t.Fatalf("ParseWindows failed: %v", err)
+	}
+
+	if _, until, frozen := ActiveWindow(windows, at(18, 23, 30)); !frozen || !until.Equal(at(19, 6, 0)) {
+		t.Errorf("Expected Tuesday 23:30 to be frozen until 06:00, got frozen=%v until=%v", frozen, until)
+	}
+	if _, _, frozen := ActiveWindow(windows, at(18, 12, 0)); frozen {
+		t.Error("Expected midday to be outside the window")
+	}
+}
+
+func TestWindow_BackToBack(t *testing.T) {
+	windows, err := ParseWindows("Sat 00:00-Sun 00:00; Sun 00:00-Sun 12:00")
+	if err != nil {
+		t.Fatalf("ParseWindows failed: %v", err)
+	}
+
+	_, until, frozen := ActiveWindow(windows, at(15, 10, 0))
+	if !frozen || !until.Equal(at(16, 12, 0)) {
+		t.Errorf("Expected chained windows to lift Sunday 12:00, got frozen=%v until=%v", frozen, until)
+	}
+}
+
+func TestParseWindows_Invalid(t *testing.T) {
+	for _, spec := range []string{"Fri 16:00", "Fri 16:00-08:00", "Funday 10:00-Mon 10:00", "25:00-26:00"} {
+		if _, err := ParseWindows(spec); err == nil {
+			t.Errorf("Expected error parsing %q", spec)
+		}
+	}
+}
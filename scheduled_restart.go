@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/notify"
+	"binaryDeploy/schedule"
+)
+
+// startRestartScheduler gracefully restarts the target app on the configured
+// restart_schedule. It returns immediately when no schedule is configured.
+func startRestartScheduler() {
+	if appConfig().RestartSchedule == "" {
+		return
+	}
+
+	sched, err := schedule.Parse(appConfig().RestartSchedule)
+	if err != nil {
+		slog.Error("Invalid restart schedule, scheduled restarts disabled", "schedule", appConfig().RestartSchedule, "error", err)
+		return
+	}
+
+	go func() {
+		for {
+			next := sched.Next(time.Now())
+			if next.IsZero() {
+				slog.Warn("Restart schedule never fires again, stopping scheduler", "schedule", sched.String())
+				return
+			}
+
+			slog.Info("Next scheduled restart", "app", appConfig().AppName, "at", next.Format(time.RFC3339))
+			time.Sleep(time.Until(next))
+
+			runScheduledRestart(sched)
+		}
+	}()
+}
+
+// runScheduledRestart performs one scheduled restart and reports the outcome
+func runScheduledRestart(sched *schedule.Schedule) {
+	if !processManager.IsRunning() {
+		slog.Info("Skipping scheduled restart, application is not running", "app", appConfig().AppName)
+		return
+	}
+
+	slog.Info("Performing scheduled restart", "app", appConfig().AppName, "schedule", sched.String())
+	start := time.Now()
+
+	err := processManager.RestartProcess()
+	rec := history.Record{
+		App:         appConfig().AppName,
+		Kind:        history.KindRestart,
+		Trigger:     "Scheduled restart",
+		TriggeredBy: "binaryDeploy",
+		Result:      history.ResultSuccess,
+		StartedAt:   start,
+		FinishedAt:  time.Now(),
+		Tags:        []string{history.TagScheduled},
+	}
+	if err != nil {
+		rec.Result = history.ResultFailure
+		rec.Error = err.Error()
+	}
+	recordDeployment(rec)
+
+	if err != nil {
+		slog.Error("Scheduled restart failed", "app", appConfig().AppName, "error", err)
+		notifier().Notify(notify.Event{
+			Type:    notify.EventScheduledRestartFailed,
+			App:     appConfig().AppName,
+			Message: fmt.Sprintf("Scheduled restart of %s failed: %v", appConfig().AppName, err),
+			Fields:  map[string]interface{}{"schedule": sched.String()},
+		})
+		return
+	}
+
+	slog.Info("Scheduled restart completed", "app", appConfig().AppName, "pid", processManager.GetCurrentPID(), "duration", time.Since(start).String())
+	notifier().Notify(notify.Event{
+		Type:    notify.EventScheduledRestart,
+		App:     appConfig().AppName,
+		Message: fmt.Sprintf("Scheduled restart of %s completed", appConfig().AppName),
+		Fields: map[string]interface{}{
+			"schedule": sched.String(),
+			"pid":      processManager.GetCurrentPID(),
+			"duration": time.Since(start).String(),
+		},
+	})
+}
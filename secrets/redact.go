@@ -0,0 +1,149 @@
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted replaces secret values in output
+const Redacted = "[REDACTED]"
+
+// minRedactLength avoids mangling output with very short values that are
+// unlikely to be real secrets
+const minRedactLength = 4
+
+// Rule redacts text matching Pattern. When the pattern has a capture group
+// only the first group is replaced, so "password=(\S+)" keeps "password=".
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	keyword string // Set for keyword rules, to also match field names
+}
+
+// NewRule compiles pattern into a rule
+func NewRule(name, pattern string) (Rule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid redaction rule %s: %w", name, err)
+	}
+	return Rule{Name: name, Pattern: re}, nil
+}
+
+// KeywordRule redacts the value assigned to keyword, as in "password=x",
+// "DB_PASSWORD: x" or "password = \"x y\"", ignoring case
+func KeywordRule(keyword string) Rule {
+	pattern := `(?i)` + regexp.QuoteMeta(keyword) + `["']?\s*[=:]\s*("[^"]*"|'[^']*'|[^\s,;&"']+)`
+	return Rule{Name: "keyword:" + keyword, Pattern: regexp.MustCompile(pattern), keyword: strings.ToLower(keyword)}
+}
+
+// BuiltinRules match well-known credential formats
+func BuiltinRules() []Rule {
+	return []Rule{
+		{Name: "aws-access-key-id", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{Name: "aws-secret-access-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`)},
+		{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b|\bgithub_pat_[A-Za-z0-9_]{22,}\b`)},
+		{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`)},
+		{Name: "bearer-token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{16,}=*)`)},
+	}
+}
+
+// Redactor replaces known secret values and rule matches in strings. Its
+// secrets can be replaced with Update while it is in use.
+type Redactor struct {
+	state atomic.Pointer[redactState]
+}
+
+// redactState is what a Redactor currently redacts
+type redactState struct {
+	replacer *strings.Replacer
+	rules    []Rule
+}
+
+// NewRedactor returns a redactor for values and rules. Longer values are
+// replaced first so a secret containing another is fully hidden.
+func NewRedactor(values []string, rules ...Rule) *Redactor {
+	r := &Redactor{}
+	r.Update(values, rules...)
+	return r
+}
+
+// Update replaces the values and rules r redacts, e.g. after the
+// configuration was reloaded
+func (r *Redactor) Update(values []string, rules ...Rule) {
+	state := &redactState{rules: rules}
+	defer r.state.Store(state)
+
+	sorted := make([]string, 0, len(values))
+	for _, v := range values {
+		if len(v) >= minRedactLength {
+			sorted = append(sorted, v)
+		}
+	}
+	if len(sorted) == 0 {
+		return
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	pairs := make([]string, 0, 2*len(sorted))
+	for _, v := range sorted {
+		pairs = append(pairs, v, Redacted)
+	}
+	state.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with every secret value and rule match replaced. A nil or
+// empty redactor returns s unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	state := r.state.Load()
+	if state.replacer != nil {
+		s = state.replacer.Replace(s)
+	}
+	for _, rule := range state.rules {
+		s = applyRule(rule.Pattern, s)
+	}
+	return s
+}
+
+// RedactField redacts value, or all of it when the field name key contains
+// a keyword rule's keyword (e.g. a log attribute named "db_password")
+func (r *Redactor) RedactField(key, value string) string {
+	if r == nil {
+		return value
+	}
+	lower := strings.ToLower(key)
+	for _, rule := range r.state.Load().rules {
+		if rule.keyword != "" && strings.Contains(lower, rule.keyword) && value != "" {
+			return Redacted
+		}
+	}
+	return r.Redact(value)
+}
+
+// applyRule replaces matches of re in s, or only their first group when re
+// has one
+func applyRule(re *regexp.Regexp, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Redacted)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
@@ -0,0 +1,117 @@
+// Package secrets encrypts configuration values at rest with AES-256-GCM and
+// redacts decrypted values from output
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Prefix marks an encrypted value, e.g. secret=enc:v1:3q2+7w...
+const Prefix = "enc:v1:"
+
+// Environment variables holding the key, base64-encoded, or a file containing it
+const (
+	KeyEnv     = "BINARYDEPLOY_SECRET_KEY"
+	KeyFileEnv = "BINARYDEPLOY_SECRET_KEY_FILE"
+)
+
+// ErrNoKey is returned when an encrypted value is found but no key is set
+var ErrNoKey = errors.New("encrypted value found but " + KeyEnv + " (or " + KeyFileEnv + ") is not set")
+
+// IsEncrypted reports whether value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// GenerateKey returns a new random key, base64-encoded
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes a base64-encoded 256-bit key
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("secret key is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secret key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// LoadKey reads the key from KeyEnv or the file named by KeyFileEnv. It
+// returns nil without error when neither is set.
+func LoadKey() ([]byte, error) {
+	if encoded := os.Getenv(KeyEnv); encoded != "" {
+		return ParseKey(encoded)
+	}
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading secret key file: %w", err)
+		}
+		return ParseKey(string(data))
+	}
+	return nil, nil
+}
+
+// Encrypt seals plaintext with key and returns it as a Prefix-ed string
+func Encrypt(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt
+func Decrypt(key []byte, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", fmt.Errorf("value is not encrypted")
+	}
+	if key == nil {
+		return "", ErrNoKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return "", fmt.Errorf("encrypted value is not valid base64: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted value is truncated")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting value failed (wrong key?)")
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
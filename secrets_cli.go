@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"binaryDeploy/secrets"
+)
+
+// runSecretsCommand implements the generate-secret-key and encrypt-secret
+// subcommands used to prepare encrypted deploy.config values
+func runSecretsCommand(command string, in io.Reader, out io.Writer) error {
+	switch command {
+	case "generate-secret-key":
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, key)
+		return nil
+
+	case "encrypt-secret":
+		key, err := secrets.LoadKey()
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return fmt.Errorf("set %s (or %s) to the key the server will use", secrets.KeyEnv, secrets.KeyFileEnv)
+		}
+		data, err := io.ReadAll(io.LimitReader(in, 64*1024))
+		if err != nil {
+			return err
+		}
+		value, err := secrets.Encrypt(key, strings.TrimRight(string(data), "\r\n"))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, value)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", command)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"binaryDeploy/jobs"
+)
+
+// inheritedListenersEnv hands the listening sockets to the binary
+// re-executed after a self-update, as comma-separated address=fd pairs
+const inheritedListenersEnv = "BINARYDEPLOY_LISTEN_FDS"
+
+var (
+	// inheritedListeners are the sockets handed over by the previous binary,
+	// by listen address, until a server takes them
+	inheritedListeners = map[string]net.Listener{}
+
+	// serverListeners are the sockets binaryDeploy serves on, by listen
+	// address
+	serverListeners = struct {
+		sync.Mutex
+		m map[string]*handoverListener
+	}{m: make(map[string]*handoverListener)}
+
+	// openConnections counts the connections of the servers, so a restart
+	// can wait for them to finish
+	openConnections atomic.Int64
+
+	// selfRestart asks main to re-execute binaryDeploy once a self-update
+	// has installed a new binary
+	selfRestart = make(chan string, 1)
+
+	// draining is closed when the servers stop accepting for a restart,
+	// ending log streams that would otherwise hold it up
+	draining = make(chan struct{})
+)
+
+// adoptInheritedListeners picks up the sockets a previous binary handed over
+// when it re-executed binaryDeploy. The variable is removed so the managed
+// app doesn't see it.
+func adoptInheritedListeners() {
+	value := os.Getenv(inheritedListenersEnv)
+	if value == "" {
+		return
+	}
+	os.Unsetenv(inheritedListenersEnv)
+
+	for _, pair := range strings.Split(value, ",") {
+		addr, fdText, _ := strings.Cut(pair, "=")
+		fd, err := strconv.Atoi(fdText)
+		if err != nil || addr == "" {
+			slog.Warn("Ignoring malformed inherited listener", "value", pair)
+			continue
+		}
+		f := os.NewFile(uintptr(fd), addr)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			slog.Warn("Failed to adopt inherited listener", "addr", addr, "fd", fd, "error", err)
+			continue
+		}
+		inheritedListeners[addr] = ln
+	}
+	slog.Info("Serving on sockets handed over by the previous binary", "listeners", len(inheritedListeners))
+}
+
+// handoverListener is a server socket that can stop accepting without the
+// server shutting down. http.Server.Shutdown drops connections whose request
+// hadn't been read yet; stopping the socket instead lets the server answer
+// everything it accepted while the backlog waits for the new binary.
+type handoverListener struct {
+	net.Listener
+	stopped chan struct{}
+}
+
+func (l *handoverListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		select {
+		case <-l.stopped:
+			return nil, http.ErrServerClosed
+		default:
+		}
+	}
+	return conn, err
+}
+
+// stop stops accepting connections; Serve returns http.ErrServerClosed
+func (l *handoverListener) stop() {
+	close(l.stopped)
+	l.Listener.Close()
+}
+
+// listen returns the socket handed over for addr, or listens on it, and
+// keeps the socket to hand over on a self-update restart
+func listen(addr string) (net.Listener, error) {
+	ln, ok := inheritedListeners[addr]
+	if ok {
+		delete(inheritedListeners, addr)
+	} else {
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return nil, err
+		}
+	}
+
+	hl := &handoverListener{Listener: ln, stopped: make(chan struct{})}
+	serverListeners.Lock()
+	serverListeners.m[addr] = hl
+	serverListeners.Unlock()
+	return hl, nil
+}
+
+// trackConnection is the ConnState hook of the servers
+func trackConnection(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		openConnections.Add(1)
+	case http.StateClosed, http.StateHijacked:
+		openConnections.Add(-1)
+	}
+}
+
+// closeUnusedListeners closes handed-over sockets no server took, e.g.
+// because listen changed with the update
+func closeUnusedListeners() {
+	for addr, ln := range inheritedListeners {
+		slog.Info("Closing inherited listener that is no longer configured", "addr", addr)
+		ln.Close()
+		delete(inheritedListeners, addr)
+	}
+}
+
+// requestSelfRestart asks main to restart into the binary reason installed,
+// unless self_update_restart is off
+func requestSelfRestart(reason string) bool {
+	if !appConfig().SelfUpdateRestart {
+		return false
+	}
+	select {
+	case selfRestart <- reason:
+	default: // A restart is already pending
+	}
+	return true
+}
+
+// restartSelf re-executes binaryDeploy in place: the new binary keeps the
+// process ID and takes over the listening sockets, so connections arriving
+// meanwhile wait in the socket backlog instead of being refused. A running
+// deployment, then open connections and outgoing notifications, are given
+// self_update_drain_timeout to finish first. It returns if the sockets can't
+// be handed over, leaving binaryDeploy running; if the exec itself fails the
+// servers are gone and binaryDeploy exits for its supervisor to restart it.
+func restartSelf(servers []*http.Server, reason string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating the new binary: %w", err)
+	}
+
+	// Duplicates of the sockets stay open while the servers shut down
+	serverListeners.Lock()
+	files := make([]*os.File, 0, len(serverListeners.m))
+	pairs := make([]string, 0, len(serverListeners.m))
+	for addr, ln := range serverListeners.m {
+		filer, ok := ln.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+		f, err := filer.File()
+		if err == nil {
+			err = clearCloseOnExec(f)
+		}
+		if err != nil {
+			serverListeners.Unlock()
+			closeFiles(files)
+			return fmt.Errorf("handing over %s: %w", addr, err)
+		}
+		files = append(files, f)
+		pairs = append(pairs, fmt.Sprintf("%s=%d", addr, f.Fd()))
+	}
+	serverListeners.Unlock()
+
+	drain := time.Duration(appConfig().SelfUpdateDrainTimeout) * time.Second
+	slog.Info("Restarting into the new binary", "reason", reason, "binary", executable, "listeners", len(pairs), "drain_timeout", drain.String())
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	waitForDeployments(ctx)
+	cancel()
+
+	// The new binary starts the app again
+	if err := processManager.Shutdown(); err != nil {
+		slog.Error("Failed to shutdown process manager", "error", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	drainServers(ctx, servers)
+	if err := notifier().Wait(ctx); err != nil {
+		slog.Warn("Restarting before every notification was delivered", "error", err)
+	}
+	removeTempDirs()
+
+	env := append(os.Environ(), inheritedListenersEnv+"="+strings.Join(pairs, ","))
+	err = syscall.Exec(executable, os.Args, env)
+	runtime.KeepAlive(files)
+
+	slog.Error("Failed to re-execute binaryDeploy, exiting", "binary", executable, "error", err)
+	os.Exit(1)
+	return err
+}
+
+// drainServers stops accepting connections and waits for the open ones to
+// finish their request
+func drainServers(ctx context.Context, servers []*http.Server) {
+	serverListeners.Lock()
+	for _, ln := range serverListeners.m {
+		ln.stop()
+	}
+	serverListeners.Unlock()
+	for _, server := range servers {
+		server.SetKeepAlivesEnabled(false)
+	}
+	close(draining)
+
+	for openConnections.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			slog.Warn("Restarting with connections still open", "connections", openConnections.Load())
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// selfRestartFailed leaves read-only mode when binaryDeploy couldn't restart
+// into the installed binary, which then runs from the next restart
+func selfRestartFailed(err error) {
+	slog.Error("Failed to restart into the new binary, it runs from the next restart", "error", err)
+	updateStatus.Lock()
+	updateStatus.self.IsRunning = false
+	updateStatus.self.Message = "Installed the new binary, but failed to restart into it"
+	updateStatus.self.Error = err.Error()
+	updateStatus.Unlock()
+}
+
+// waitForDeployments waits for a running deployment of the target app to
+// finish, so the restart doesn't interrupt it
+func waitForDeployments(ctx context.Context) {
+	for deploymentInFlight() {
+		select {
+		case <-ctx.Done():
+			slog.Warn("Restarting while a deployment is running; it will be reported as interrupted")
+			return
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func deploymentInFlight() bool {
+	if targetDeploymentRunning() {
+		return true
+	}
+	if deployQueue == nil {
+		return false
+	}
+	for _, job := range deployQueue.List() {
+		if job.State == jobs.StateRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// clearCloseOnExec lets f survive syscall.Exec
+func clearCloseOnExec(f *os.File) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_SETFD, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// selfUpdatePreviewMu lets one preview build run at a time, as they share a
+// work directory
+var selfUpdatePreviewMu sync.Mutex
+
+// selfUpdatePreviewHandler serves POST /update-self?dry_run=true: it builds
+// self_update_branch and reports what a self-update would install without
+// swapping the binary
+func selfUpdatePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !selfUpdatePreviewMu.TryLock() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "a self-update preview is already running"})
+		return
+	}
+	defer selfUpdatePreviewMu.Unlock()
+
+	preview, err := previewSelfUpdate(selfUpdateBranch())
+	if err != nil {
+		slog.Error("Self-update preview failed", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(preview)
+}
+
+// buildRevision is the commit the running binary was built from, or "" when
+// it was built without version control information
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/updater"
+)
+
+// selfUpdateRollbackHandler lists the retained self-update builds on GET
+// and installs one of them on POST: ?version=<version>, or the build before
+// the current one without it
+func selfUpdateRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	currentBinary, err := os.Executable()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	su := updater.NewSelfUpdater(currentBinary, appConfig().SelfUpdateDir)
+
+	switch r.Method {
+	case http.MethodGet:
+		binaries, err := su.RetainedBinaries()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"binaries": binaries})
+	case http.MethodPost:
+		// The rollback replaces the binary like a self-update, so the API is
+		// read-only while it runs
+		updateStatus.Lock()
+		if updateStatus.self.IsRunning {
+			updateStatus.Unlock()
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "a self-update is running"})
+			return
+		}
+		start := time.Now()
+		updateStatus.self = UpdateStatus{IsRunning: true, StartTime: start, Message: "Self-update rollback started"}
+		updateStatus.Unlock()
+
+		version := r.URL.Query().Get("version")
+		binary, err := su.RollbackTo(version)
+		recordSelfUpdateRollback(binary, version, requestSource(r), start, err)
+		restarting := false
+		updateStatus.Lock()
+		updateStatus.self.Message = "Self-update rollback completed successfully"
+		if err != nil {
+			updateStatus.self.Message = "Self-update rollback failed"
+			updateStatus.self.Error = err.Error()
+		} else if restarting = requestSelfRestart("Self-update rollback"); restarting {
+			updateStatus.self.Message = "Self-update rollback completed, restarting into the rolled back binary"
+		}
+		updateStatus.self.IsRunning = restarting
+		updateStatus.self.CompletedAt = time.Now()
+		updateStatus.Unlock()
+		if errors.Is(err, updater.ErrUnknownVersion) {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			slog.Error("Self-update rollback failed", "version", version, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		slog.Info("Rolled back self-update", "version", binary.Version, "commit", binary.Commit)
+		json.NewEncoder(w).Encode(map[string]any{"status": "rolled back", "binary": binary, "restarting": restarting})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// recordSelfUpdateRollback adds a rollback to a retained build to the
+// deployment history and announces it
+func recordSelfUpdateRollback(binary updater.RetainedBinary, version, triggeredBy string, start time.Time, err error) {
+	rec := history.Record{
+		App:         "binaryDeploy",
+		Kind:        history.KindSelfUpdate,
+		Trigger:     "Self-update rollback",
+		TriggeredBy: triggeredBy,
+		Repo:        appConfig().SelfUpdateRepoURL,
+		Commit:      binary.Commit,
+		Version:     binary.Version,
+		Result:      history.ResultSuccess,
+		StartedAt:   start,
+		FinishedAt:  time.Now(),
+		Tags:        []string{"rollback"},
+	}
+	if err != nil {
+		rec.Version = version
+		rec.Result = history.ResultFailure
+		rec.Error = err.Error()
+	}
+	recordDeployment(rec)
+	notifySelfUpdateFinished(rec, "")
+}
@@ -0,0 +1,109 @@
+// Package semver parses semantic versions and works out the next one from
+// the kind of changes being released
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a MAJOR.MINOR.PATCH version. Pre-release and build metadata are
+// not supported.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// versionPattern matches 1.2.3 with an optional leading v
+var versionPattern = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
+
+// Parse reads a version such as 1.2.3 or v1.2.3
+func Parse(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid semantic version %q", s)
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v precedes other
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// Bump levels, from least to most significant
+const (
+	None = iota
+	Patch
+	Minor
+	Major
+)
+
+// LevelName names a bump level
+func LevelName(level int) string {
+	switch level {
+	case Patch:
+		return "patch"
+	case Minor:
+		return "minor"
+	case Major:
+		return "major"
+	default:
+		return "none"
+	}
+}
+
+// Bump returns the version after a change of the given level. Before 1.0.0
+// breaking changes only bump the minor version, as the spec allows.
+func (v Version) Bump(level int) Version {
+	if level == Major && v.Major == 0 {
+		level = Minor
+	}
+	switch level {
+	case Major:
+		return Version{Major: v.Major + 1}
+	case Minor:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	case Patch:
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	default:
+		return v
+	}
+}
+
+// Latest returns the highest version among tags that consist of prefix and a
+// version, e.g. v1.4.0 for prefix "v"
+func Latest(prefix string, tags []string) (Version, string, bool) {
+	var latest Version
+	var latestTag string
+	found := false
+	for _, tag := range tags {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(tag), prefix)
+		if !ok {
+			continue
+		}
+		v, err := Parse(rest)
+		if err != nil || strings.HasPrefix(rest, "v") {
+			continue
+		}
+		if !found || latest.Less(v) {
+			latest, latestTag, found = v, tag, true
+		}
+	}
+	return latest, latestTag, found
+}
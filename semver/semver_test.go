@@ -0,0 +1,45 @@
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.12.0")
+	if err != nil || v != (Version{1, 12, 0}) {
+		t.Errorf("Parse(v1.12.0) = %v, %v", v, err)
+	}
+	for _, bad := range []string{"1.2", "01.2.3", "1.2.3-rc.1", "release-1.2.3"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestBump(t *testing.T) {
+	tests := []struct {
+		from  Version
+		level int
+		want  string
+	}{
+		{Version{1, 4, 2}, Patch, "1.4.3"},
+		{Version{1, 4, 2}, Minor, "1.5.0"},
+		{Version{1, 4, 2}, Major, "2.0.0"},
+		{Version{0, 3, 1}, Major, "0.4.0"},
+		{Version{1, 4, 2}, None, "1.4.2"},
+	}
+	for _, tt := range tests {
+		if got := tt.from.Bump(tt.level).String(); got != tt.want {
+			t.Errorf("%v.Bump(%s) = %s, want %s", tt.from, LevelName(tt.level), got, tt.want)
+		}
+	}
+}
+
+func TestLatest(t *testing.T) {
+	tags := []string{"v1.9.0", "v1.10.0", "nightly", "v2.0.0-rc.1", "app-v3.0.0", "vv9.0.0"}
+	v, tag, ok := Latest("v", tags)
+	if !ok || tag != "v1.10.0" || v != (Version{1, 10, 0}) {
+		t.Errorf("Latest = %v, %q, %v", v, tag, ok)
+	}
+	if _, _, ok := Latest("release-", tags); ok {
+		t.Error("expected no release- tags")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"binaryDeploy/publish"
+	"binaryDeploy/remote"
+)
+
+// publishToSFTPHosts uploads the files of outputDir that changed since the
+// last publish to each of ssh_hosts over SFTP, for shared hosting targets
+// that offer nothing but file access
+func publishToSFTPHosts(profile *deployProfile, outputDir string) error {
+	opts := remote.Options{KeyFile: appConfig().SSHKeyFile, KnownHostsFile: appConfig().SSHKnownHostsFile}
+
+	current, err := publish.Scan(outputDir)
+	if err != nil {
+		return fmt.Errorf("scanning publish_dir: %w", err)
+	}
+
+	for i, spec := range appConfig().SSHHosts {
+		host, err := remote.ParseHost(spec)
+		if err != nil {
+			return err
+		}
+
+		err = profile.step("publish["+host.Name+"]", func() error {
+			return publishToHost(host, opts, outputDir, current)
+		})
+		if err != nil {
+			return fmt.Errorf("publishing to %s failed, %d host(s) left on the previous version: %w", host, len(appConfig().SSHHosts)-i-1, err)
+		}
+	}
+	return nil
+}
+
+// publishToHost uploads what changed on one host and remembers what it holds
+func publishToHost(host remote.Host, opts remote.Options, outputDir string, current publish.Manifest) error {
+	stateFile := filepath.Join(appConfig().DeployDir, "publish", strings.ReplaceAll(host.String(), "/", "_")+".json")
+	previous, err := publish.LoadManifest(stateFile)
+	if err != nil {
+		return err
+	}
+
+	changed, removed := publish.Diff(previous, current)
+	if len(changed) == 0 && len(removed) == 0 {
+		slog.Info("Published files are up to date", "host", host.String())
+		return nil
+	}
+
+	staging := fmt.Sprintf(".publish-%d", time.Now().UnixNano())
+	batch, err := createTempFile("sftp", "")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(batch.Name())
+	_, err = batch.WriteString(publish.SFTPBatch(outputDir, appConfig().SSHRemoteDir, staging, changed, removed))
+	if closeErr := batch.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Publishing over SFTP", "host", host.String(), "remote_dir", appConfig().SSHRemoteDir, "changed", len(changed), "removed", len(removed))
+	if err := remote.Run(remote.SFTPCommand(host, opts, batch.Name())); err != nil {
+		return err
+	}
+	return current.Save(stateFile)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/processmanager"
+	"binaryDeploy/templating"
+)
+
+// simulateSettleTime is how long a simulated app without a health check must
+// stay up to pass
+const simulateSettleTime = 3 * time.Second
+
+// SimulationResult is the outcome of a simulated deployment
+type SimulationResult struct {
+	Passed   bool                 `json:"passed"`
+	Commit   string               `json:"commit,omitempty"`
+	Error    string               `json:"error,omitempty"`
+	Duration string               `json:"duration"`
+	Steps    []history.StepTiming `json:"steps"`
+}
+
+// simulateDeployment runs the fetch, render, build, start and health steps for
+// req in a disposable directory. The live app, its directories, the queue and
+// the history are never touched.
+func simulateDeployment(req DeployRequest) SimulationResult {
+	start := time.Now()
+	profile := &deployProfile{}
+
+	commit, err := runSimulation(req, profile)
+
+	result := SimulationResult{
+		Passed:   err == nil,
+		Commit:   commit,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+		Steps:    profile.Steps(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	slog.Info("Simulated deployment finished", "passed", result.Passed, "commit", commit, "duration", result.Duration, "error", result.Error)
+	return result
+}
+
+func runSimulation(req DeployRequest, profile *deployProfile) (commit string, err error) {
+	dir, err := makeTempDir("simulate")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	repoDir := filepath.Join(dir, "repo")
+	if err := profile.step("fetch", func() error { return checkoutTargetRevision(req, repoDir) }); err != nil {
+		return "", err
+	}
+	commit = gitHeadCommit(repoDir)
+
+	workingDir := repoDir
+	if appConfig().WorkingDir != "" {
+		workingDir = filepath.Join(repoDir, appConfig().WorkingDir)
+	}
+
+	if len(appConfig().ConfigTemplates) > 0 {
+		if err := profile.step("render", func() error { return renderConfigTemplates(req, commit, workingDir) }); err != nil {
+			return commit, err
+		}
+	}
+
+	data := templateData(req.Branch, commit, workingDir)
+	if appConfig().BuildCommand != "" {
+		command, err := buildCommand(data)
+		if err != nil {
+			return commit, err
+		}
+		err = profile.step("build", func() error { return runShellCommandInDir(repoDir, command) })
+		if err != nil {
+			return commit, fmt.Errorf("build failed: %w", err)
+		}
+	}
+
+	// Remote drivers don't run the app here, so there is nothing to start
+	if appConfig().RunCommand == "" {
+		return commit, nil
+	}
+	return commit, simulateStart(profile, workingDir, data)
+}
+
+// simulateStart starts the built app on a free port and waits for it to pass
+// its health check, or to stay up for simulateSettleTime without one
+func simulateStart(profile *deployProfile, workingDir string, data templating.Data) error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+
+	// A private manager with its own config copy, so the live processes and
+	// their restart policy are left alone
+	cfg := *appConfig()
+	cfg.ApplicationPort = port
+	cfg.MaxRestarts = 0
+	data.Port = port
+	if cfg.RunCommand, err = templating.Command(cfg.RunCommand, data); err != nil {
+		return fmt.Errorf("invalid run_command: %w", err)
+	}
+	pm := processmanager.NewProcessManager()
+	pm.SetEnv([]string{"PORT=" + strconv.Itoa(port), "INSTANCE_ID=0"})
+	defer pm.StopCurrentProcess()
+
+	if err := profile.step("start", func() error { return pm.StartProcess(&cfg, workingDir) }); err != nil {
+		return fmt.Errorf("failed to start application process: %w", err)
+	}
+
+	return profile.step("health", func() error {
+		if cfg.ReadinessNotify {
+			if err := waitForNotifyReady(pm.WaitReady); err != nil {
+				return err
+			}
+		}
+		if strings.HasPrefix(appConfig().HealthCheckURL, "/") {
+			url := fmt.Sprintf("http://127.0.0.1:%d%s", port, appConfig().HealthCheckURL)
+			if err := waitForURLReady(url); err != nil {
+				return fmt.Errorf("application did not become healthy: %w", err)
+			}
+		} else {
+			time.Sleep(simulateSettleTime)
+		}
+		if !pm.IsRunning() {
+			return fmt.Errorf("application exited during start-up")
+		}
+		return nil
+	})
+}
+
+// freePort returns a TCP port that is currently unused
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("finding a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// runSimulateCommand implements --simulate for CI: it loads deploy.config,
+// simulates a deployment of the requested revision and returns the exit code
+func runSimulateCommand(args []string) int {
+	flags := flag.NewFlagSet("--simulate", flag.ContinueOnError)
+	branch := flags.String("branch", "", "branch to deploy (default: the remote's default branch)")
+	commit := flags.String("commit", "", "commit SHA to deploy")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	loadConfig()
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	setupTempDirs()
+	defer removeTempDirs()
+	if err := setupGitCredentials(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	req := DeployRequest{RepoURL: appConfig().TargetRepoURL, Branch: *branch, Commit: *commit}
+	if err := validateDeployRequest(&req); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	result := simulateDeployment(req)
+	for _, step := range result.Steps {
+		status := "ok"
+		if step.Failed {
+			status = "FAILED"
+		}
+		fmt.Printf("  %-8s %-6s %s\n", step.Name, status, time.Duration(step.DurationMS)*time.Millisecond)
+	}
+	if !result.Passed {
+		fmt.Printf("FAIL %s: %s\n", shortSHA(result.Commit), result.Error)
+		return 1
+	}
+	fmt.Printf("PASS %s would deploy (%s)\n", shortSHA(result.Commit), result.Duration)
+	return 0
+}
+
+func shortSHA(commit string) string {
+	return commit[:min(8, len(commit))]
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+
+	"binaryDeploy/remote"
+)
+
+// deployToRemoteHosts pushes the built release in workingDir to each of
+// ssh_hosts in turn and restarts it there. A failing host stops the rollout
+// with the remaining hosts left on the previous version.
+func deployToRemoteHosts(profile *deployProfile, workingDir string) error {
+	opts := remote.Options{KeyFile: appConfig().SSHKeyFile, KnownHostsFile: appConfig().SSHKnownHostsFile}
+
+	paths := appConfig().SSHSyncPaths
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	for i, spec := range appConfig().SSHHosts {
+		host, err := remote.ParseHost(spec)
+		if err != nil {
+			return err
+		}
+		left := len(appConfig().SSHHosts) - i - 1
+
+		slog.Info("Syncing release to remote host", "host", host.String(), "remote_dir", appConfig().SSHRemoteDir, "paths", paths)
+		err = profile.step("sync["+host.Name+"]", func() error {
+			return remote.Run(remote.SyncCommand(host, opts, workingDir, paths, appConfig().SSHRemoteDir))
+		})
+		if err != nil {
+			return fmt.Errorf("syncing to %s failed, %d host(s) left on the previous version: %w", host, left, err)
+		}
+
+		if appConfig().SSHRestartCommand != "" {
+			slog.Info("Restarting application on remote host", "host", host.String(), "command", appConfig().SSHRestartCommand)
+			err := profile.step("restart["+host.Name+"]", func() error {
+				return remote.Run(remote.RunCommand(host, opts, appConfig().SSHRemoteDir, appConfig().SSHRestartCommand))
+			})
+			if err != nil {
+				return fmt.Errorf("restart on %s failed, %d host(s) left on the previous version: %w", host, left, err)
+			}
+		}
+
+		resolve := func(pathOrURL string) string { return remoteURL(host, pathOrURL) }
+		if appConfig().HealthCheckURL != "" {
+			err := profile.step("health["+host.Name+"]", func() error {
+				return waitForURLReady(resolve(appConfig().HealthCheckURL))
+			})
+			if err != nil {
+				return fmt.Errorf("%s did not become healthy, %d host(s) left on the previous version: %w", host, left, err)
+			}
+		}
+		profile.step("warmup["+host.Name+"]", func() error {
+			warmUpURLs(resolve)
+			return nil
+		})
+	}
+
+	slog.Info("Deployed to all remote hosts", "hosts", len(appConfig().SSHHosts))
+	return nil
+}
+
+// remoteURL turns a configured path like "/healthz" into a URL on the
+// application port of a remote host; absolute URLs are returned unchanged
+func remoteURL(host remote.Host, pathOrURL string) string {
+	if !strings.HasPrefix(pathOrURL, "/") {
+		return pathOrURL
+	}
+	return "http://" + net.JoinHostPort(host.Name, strconv.Itoa(appConfig().ApplicationPort)) + pathOrURL
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"binaryDeploy/kv"
+)
+
+// Outcomes of a diagnostic check
+const (
+	checkOK      = "ok"
+	checkWarning = "warning"
+	checkError   = "error"
+)
+
+// repoReachTimeout bounds the git ls-remote probing the target repository
+const repoReachTimeout = 20 * time.Second
+
+// clockFloor is the earliest plausible time when the binary carries no
+// commit time to compare against
+var clockFloor = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// diagnosticCheck is the outcome of one startup check
+type diagnosticCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// diagnosticsReport is the result of the startup diagnostics pass
+type diagnosticsReport struct {
+	Time     time.Time         `json:"time"`
+	Duration string            `json:"duration"`
+	Passed   bool              `json:"passed"` // No check failed; warnings allowed
+	Checks   []diagnosticCheck `json:"checks"`
+}
+
+var (
+	diagnosticsMu sync.RWMutex
+	diagnostics   *diagnosticsReport
+)
+
+// runStartupDiagnostics checks the host for what deployments will need and
+// logs the report, so a misconfigured host shows up at startup rather than
+// at the first webhook. With diagnostics_strict a failed check stops
+// binaryDeploy. It must run before the listeners start, as it checks their
+// addresses are free.
+func runStartupDiagnostics() {
+	started := time.Now()
+	report := &diagnosticsReport{Time: started, Passed: true}
+	add := func(name, status, detail string) {
+		report.Checks = append(report.Checks, diagnosticCheck{Name: name, Status: status, Detail: detail})
+		if status == checkError {
+			report.Passed = false
+		}
+	}
+
+	checkTools(add)
+	checkRepoReachable(add)
+	checkDeployDir(add)
+	checkListenAddresses(add)
+	checkClock(add)
+	report.Duration = time.Since(started).Round(time.Millisecond).String()
+
+	diagnosticsMu.Lock()
+	diagnostics = report
+	diagnosticsMu.Unlock()
+
+	failed := 0
+	for _, check := range report.Checks {
+		switch check.Status {
+		case checkError:
+			failed++
+			slog.Error("Startup check failed", "check", check.Name, "detail", check.Detail)
+			fmt.Fprintf(os.Stderr, "Startup check %s failed: %s\n", check.Name, check.Detail)
+		case checkWarning:
+			slog.Warn("Startup check warning", "check", check.Name, "detail", check.Detail)
+		default:
+			slog.Debug("Startup check passed", "check", check.Name, "detail", check.Detail)
+		}
+	}
+	slog.Info("Startup diagnostics finished", "passed", report.Passed, "checks", len(report.Checks), "failed", failed, "duration", report.Duration)
+
+	if !report.Passed && appConfig().DiagnosticsStrict {
+		fmt.Fprintf(os.Stderr, "Startup diagnostics failed and diagnostics_strict is set, exiting\n")
+		os.Exit(1)
+	}
+}
+
+// checkTools looks for the executables deployments run
+func checkTools(add func(name, status, detail string)) {
+	tools := []string{"git", "sh"}
+	if appConfig().StorageDriver == kv.DriverSQLite {
+		tools = append(tools, "sqlite3")
+	}
+	switch appConfig().DeployDriver {
+	case "ssh":
+		tools = append(tools, "ssh", "rsync")
+	case "sftp":
+		tools = append(tools, "sftp")
+	}
+	seen := map[string]bool{}
+	for _, tool := range tools {
+		seen[tool] = true
+		if path, err := exec.LookPath(tool); err != nil {
+			add("tool:"+tool, checkError, fmt.Sprintf("%s not found in PATH", tool))
+		} else {
+			add("tool:"+tool, checkOK, path)
+		}
+	}
+
+	// The first word of the build and run commands is usually the toolchain,
+	// e.g. go, npm or cargo. It's only a guess, so a miss is a warning;
+	// shell builtins count, and paths like ./app are built by the deployment
+	// and placeholders resolved by it.
+	for _, command := range []string{appConfig().BuildCommand, appConfig().RunCommand} {
+		fields := strings.Fields(command)
+		if len(fields) == 0 || strings.ContainsAny(fields[0], "=/") || strings.Contains(fields[0], "{{") || seen[fields[0]] {
+			continue
+		}
+		tool := fields[0]
+		seen[tool] = true
+		out, err := exec.Command("sh", "-c", `command -v "$1"`, "sh", tool).Output()
+		if err != nil {
+			add("tool:"+tool, checkWarning, fmt.Sprintf("%s, used by the build or run command, not found in PATH", tool))
+		} else {
+			add("tool:"+tool, checkOK, strings.TrimSpace(string(out)))
+		}
+	}
+}
+
+// checkRepoReachable lists the target repository's HEAD
+func checkRepoReachable(add func(name, status, detail string)) {
+	if appConfig().TargetRepoURL == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), repoReachTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", gitCommand(appConfig().TargetRepoURL, "ls-remote", appConfig().TargetRepoURL, "HEAD")...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.CombinedOutput()
+	switch {
+	case ctx.Err() != nil:
+		add("target_repo", checkError, fmt.Sprintf("%s did not answer within %s", appConfig().TargetRepoURL, repoReachTimeout))
+	case err != nil:
+		add("target_repo", checkError, fmt.Sprintf("%s: %v: %s", appConfig().TargetRepoURL, err, strings.TrimSpace(string(out))))
+	default:
+		add("target_repo", checkOK, appConfig().TargetRepoURL)
+	}
+}
+
+// checkDeployDir creates and removes a file in deploy_dir
+func checkDeployDir(add func(name, status, detail string)) {
+	if err := os.MkdirAll(appConfig().DeployDir, 0755); err != nil {
+		add("deploy_dir", checkError, err.Error())
+		return
+	}
+	f, err := os.CreateTemp(appConfig().DeployDir, ".diagnostics-*")
+	if err != nil {
+		add("deploy_dir", checkError, fmt.Sprintf("%s is not writable: %v", appConfig().DeployDir, err))
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	add("deploy_dir", checkOK, appConfig().DeployDir)
+}
+
+// checkListenAddresses binds each listen address briefly, unless the
+// previous binary handed its socket over
+func checkListenAddresses(add func(name, status, detail string)) {
+	for _, addr := range append(listenAddresses(), appConfig().AdminListen...) {
+		if _, ok := inheritedListeners[addr]; ok {
+			add("listen:"+addr, checkOK, "handed over by the previous binary")
+			continue
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			add("listen:"+addr, checkError, err.Error())
+			continue
+		}
+		ln.Close()
+		add("listen:"+addr, checkOK, "free")
+	}
+}
+
+// checkClock catches clocks far in the past, which break TLS verification,
+// schedules and history ordering
+func checkClock(add func(name, status, detail string)) {
+	floor, source := clockFloor, "2024-01-01"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key != "vcs.time" {
+				continue
+			}
+			if t, err := time.Parse(time.RFC3339, setting.Value); err == nil {
+				floor, source = t, "the commit binaryDeploy was built from"
+			}
+		}
+	}
+
+	now := time.Now()
+	if now.Before(floor) {
+		add("clock", checkError, fmt.Sprintf("system time %s is before %s", now.Format(time.RFC3339), source))
+		return
+	}
+	add("clock", checkOK, now.UTC().Format(time.RFC3339))
+}
+
+// diagnosticsHandler serves the startup diagnostics report
+func diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	diagnosticsMu.RLock()
+	report := diagnostics
+	diagnosticsMu.RUnlock()
+	if report == nil {
+		http.Error(w, "Diagnostics have not run", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/testutil"
+)
+
+func diagnosticStatuses(report *diagnosticsReport) map[string]string {
+	statuses := map[string]string{}
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestRunStartupDiagnostics(t *testing.T) {
+	repo := testutil.NewRepo(t, map[string]string{"main.go": "package main\n"})
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	free := fmt.Sprintf("127.0.0.1:%d", testutil.FreePort(t))
+
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL: repo.URL,
+		DeployDir:     filepath.Join(t.TempDir(), "deployments"),
+		BuildCommand:  "no-such-toolchain-xyz build -o app .",
+		RunCommand:    "./app",
+		Listen:        []string{free},
+		AdminListen:   []string{busy.Addr().String()},
+	})
+	runStartupDiagnostics()
+
+	diagnosticsMu.RLock()
+	report := diagnostics
+	diagnosticsMu.RUnlock()
+	if report == nil {
+		t.Fatal("Expected the report to be kept for /diagnostics")
+	}
+	statuses := diagnosticStatuses(report)
+	want := map[string]string{
+		"tool:git":                       checkOK,
+		"tool:sh":                        checkOK,
+		"tool:no-such-toolchain-xyz":     checkWarning,
+		"target_repo":                    checkOK,
+		"deploy_dir":                     checkOK,
+		"listen:" + free:                 checkOK,
+		"listen:" + busy.Addr().String(): checkError,
+		"clock":                          checkOK,
+	}
+	for name, status := range want {
+		if statuses[name] != status {
+			t.Errorf("%s: expected %q, got %q", name, status, statuses[name])
+		}
+	}
+	if _, ok := statuses["tool:./app"]; ok {
+		t.Error("Expected a run command built by the deployment not to be looked up")
+	}
+	if report.Passed {
+		t.Error("Expected the busy admin address to fail the diagnostics")
+	}
+}
+
+func TestRunStartupDiagnostics_UnreachableRepository(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL: filepath.Join(t.TempDir(), "missing.git"),
+		DeployDir:     t.TempDir(),
+		Listen:        []string{fmt.Sprintf("127.0.0.1:%d", testutil.FreePort(t))},
+	})
+	runStartupDiagnostics()
+
+	diagnosticsMu.RLock()
+	report := diagnostics
+	diagnosticsMu.RUnlock()
+	if status := diagnosticStatuses(report)["target_repo"]; status != checkError {
+		t.Errorf("Expected an unreachable repository to fail, got %q", status)
+	}
+	if report.Passed {
+		t.Error("Expected the report not to pass")
+	}
+}
+
+func TestDiagnosticsHandler(t *testing.T) {
+	diagnosticsMu.Lock()
+	diagnostics = nil
+	diagnosticsMu.Unlock()
+
+	rec := httptest.NewRecorder()
+	diagnosticsHandler(rec, httptest.NewRequest(http.MethodGet, "/diagnostics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before the diagnostics ran, got %d", rec.Code)
+	}
+
+	diagnosticsMu.Lock()
+	diagnostics = &diagnosticsReport{Passed: true, Checks: []diagnosticCheck{{Name: "clock", Status: checkOK}}}
+	diagnosticsMu.Unlock()
+
+	rec = httptest.NewRecorder()
+	diagnosticsHandler(rec, httptest.NewRequest(http.MethodPost, "/diagnostics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	diagnosticsHandler(rec, httptest.NewRequest(http.MethodGet, "/diagnostics", nil))
+	var report diagnosticsReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Decoding the report: %v", err)
+	}
+	if !report.Passed || len(report.Checks) != 1 || report.Checks[0].Name != "clock" {
+		t.Errorf("Expected the stored report, got %+v", report)
+	}
+}
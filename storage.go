@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log/slog"
+	"path/filepath"
+
+	"binaryDeploy/kv"
+)
+
+// dataStore holds binaryDeploy's persistent state; nil if it couldn't be
+// opened, in which case the features built on it are disabled
+var dataStore kv.Store
+
+// setupStorage opens the configured key/value store
+func setupStorage() {
+	path := storagePath()
+	db, err := kv.Open(appConfig().StorageDriver, path)
+	if err != nil {
+		slog.Error("Failed to open storage, history and the deployment queue are disabled",
+			"driver", appConfig().StorageDriver, "path", path, "error", err)
+		return
+	}
+	dataStore = db
+	slog.Info("Opened storage", "driver", appConfig().StorageDriver, "path", path)
+}
+
+// storagePath is storage_path, or a location inside the deploy directory
+// suited to the driver
+func storagePath() string {
+	if appConfig().StoragePath != "" {
+		return appConfig().StoragePath
+	}
+	if appConfig().StorageDriver == kv.DriverSQLite {
+		return filepath.Join(appConfig().DeployDir, "data.db")
+	}
+	return filepath.Join(appConfig().DeployDir, "data")
+}
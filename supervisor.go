@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/portalloc"
+)
+
+const (
+	instanceRestartMinBackoff = time.Second
+	instanceRestartMaxBackoff = time.Minute
+	instanceStopTimeout       = 30 * time.Second
+)
+
+// instanceProcess supervises a child binaryDeploy instance started by the
+// multi-tenant or multi-app supervisor
+type instanceProcess struct {
+	kind   string // "tenant" or "app", for logs
+	name   string
+	dir    string
+	user   string // Optional system user the instance runs as
+	port   int    // Loopback port the instance listens on
+	path   string // Where the front server serves the instance
+	limits config.Limits
+	env    []string // Added to the supervisor's environment
+	output io.Writer
+
+	// Application ports allocated from port_range, passed in env
+	appPorts portalloc.Range
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	startedAt time.Time
+	restarts  int
+	lastExit  string
+	stopping  bool
+	quit      chan struct{} // Closed by stop to cut a restart backoff short
+	done      chan struct{} // Closed when supervise returns
+}
+
+// instanceStatus is the status endpoint view of an instanceProcess
+type instanceStatus struct {
+	Name      string     `json:"name"`
+	Dir       string     `json:"dir"`
+	User      string     `json:"user,omitempty"`
+	Port      int        `json:"port"`
+	Path      string     `json:"path"`
+	Running   bool       `json:"running"`
+	PID       int        `json:"pid,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	Restarts  int        `json:"restarts"`
+	LastExit  string     `json:"last_exit,omitempty"`
+	Limits    string     `json:"limits,omitempty"`
+	AppPorts  string     `json:"app_ports,omitempty"`
+}
+
+// supervise runs the instance until stop is called, restarting it with
+// exponential backoff whenever it exits
+func (p *instanceProcess) supervise(executable string) {
+	defer close(p.done)
+
+	backoff := instanceRestartMinBackoff
+	for {
+		cmd, err := p.command(executable)
+		if err == nil {
+			err = cmd.Start()
+		}
+
+		p.mu.Lock()
+		if p.stopping {
+			p.mu.Unlock()
+			if err == nil {
+				cmd.Process.Signal(syscall.SIGTERM)
+				cmd.Wait()
+			}
+			return
+		}
+		if err != nil {
+			p.lastExit = err.Error()
+			p.mu.Unlock()
+			slog.Error("Failed to start instance", p.kind, p.name, "error", err)
+		} else {
+			p.cmd, p.startedAt = cmd, time.Now()
+			p.mu.Unlock()
+			slog.Info("Instance started", p.kind, p.name, "pid", cmd.Process.Pid, "port", p.port)
+
+			err = cmd.Wait()
+			p.mu.Lock()
+			ran := time.Since(p.startedAt)
+			p.cmd = nil
+			if err != nil {
+				p.lastExit = err.Error()
+			} else {
+				p.lastExit = "exited"
+			}
+			stopping := p.stopping
+			p.mu.Unlock()
+			if stopping {
+				return
+			}
+			slog.Warn("Instance exited", p.kind, p.name, "error", err, "ran", ran.Round(time.Second))
+			if ran > instanceRestartMaxBackoff {
+				backoff = instanceRestartMinBackoff
+			}
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-p.quit:
+			return
+		}
+		if backoff *= 2; backoff > instanceRestartMaxBackoff {
+			backoff = instanceRestartMaxBackoff
+		}
+
+		p.mu.Lock()
+		if p.stopping {
+			p.mu.Unlock()
+			return
+		}
+		p.restarts++
+		p.mu.Unlock()
+	}
+}
+
+// command builds the command that runs the instance from its directory, as
+// its user when one is configured
+func (p *instanceProcess) command(executable string) (*exec.Cmd, error) {
+	cmd := exec.Command(executable)
+	cmd.Dir = p.dir
+	cmd.Env = append(os.Environ(), p.env...)
+	prefix := "[" + p.name + "] "
+	cmd.Stdout = &prefixWriter{prefix: prefix, w: p.output}
+	cmd.Stderr = &prefixWriter{prefix: prefix, w: p.output}
+	cmd.WaitDelay = 2 * time.Second
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if p.user != "" {
+		u, err := user.Lookup(p.user)
+		if err != nil {
+			return nil, err
+		}
+		uid, err := strconv.ParseUint(u.Uid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: invalid uid %q", u.Username, u.Uid)
+		}
+		gid, err := strconv.ParseUint(u.Gid, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: invalid gid %q", u.Username, u.Gid)
+		}
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+		cmd.Env = append(cmd.Env, "HOME="+u.HomeDir, "USER="+u.Username)
+	}
+	return cmd, nil
+}
+
+// signal sends sig to the instance if it is running
+func (p *instanceProcess) signal(sig os.Signal) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != nil {
+		p.cmd.Process.Signal(sig)
+	}
+}
+
+// stop asks the instance to shut down gracefully, killing its process group
+// if it hasn't exited when ctx is done
+func (p *instanceProcess) stop(ctx context.Context) {
+	p.mu.Lock()
+	p.stopping = true
+	cmd := p.cmd
+	p.mu.Unlock()
+	close(p.quit)
+
+	if cmd != nil {
+		cmd.Process.Signal(syscall.SIGTERM)
+	}
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		if cmd != nil {
+			slog.Warn("Instance did not stop in time, killing it", p.kind, p.name)
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+		<-p.done
+	}
+}
+
+func (p *instanceProcess) status() instanceStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := instanceStatus{
+		Name:     p.name,
+		Dir:      p.dir,
+		User:     p.user,
+		Port:     p.port,
+		Path:     p.path,
+		Running:  p.cmd != nil,
+		Restarts: p.restarts,
+		LastExit: p.lastExit,
+		Limits:   p.limits.String(),
+	}
+	if p.appPorts.Size() > 0 {
+		status.AppPorts = p.appPorts.String()
+	}
+	if p.cmd != nil {
+		status.PID = p.cmd.Process.Pid
+		startedAt := p.startedAt
+		status.StartedAt = &startedAt
+	}
+	return status
+}
+
+// stopInstances stops every instance in parallel
+func stopInstances(ctx context.Context, processes map[string]*instanceProcess) {
+	var wg sync.WaitGroup
+	for _, proc := range processes {
+		wg.Add(1)
+		go func(proc *instanceProcess) {
+			defer wg.Done()
+			proc.stop(ctx)
+		}(proc)
+	}
+	wg.Wait()
+}
+
+// instanceURL is the loopback address an instance listens on
+func (p *instanceProcess) instanceURL() *url.URL {
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort("127.0.0.1", strconv.Itoa(p.port))}
+}
+
+// instanceProxy forwards requests to the instance with basePath prepended
+// to their path, keeping the client's Host and adding X-Forwarded headers
+// the instance trusts
+func instanceProxy(p *instanceProcess, basePath string) *httputil.ReverseProxy {
+	target := p.instanceURL()
+	target.Path = basePath
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.Out.Host = r.In.Host
+			r.SetXForwarded()
+		},
+		// Flush immediately so /logs/stream keeps streaming
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Warn("Instance unavailable", p.kind, p.name, "path", r.URL.Path, "error", err)
+			http.Error(w, fmt.Sprintf("%s %s is unavailable", p.kind, p.name), http.StatusBadGateway)
+		},
+	}
+}
+
+// supervisorAuthorized checks the bearer token of a request to a
+// supervisor's status endpoint, answering it if the check fails
+func supervisorAuthorized(w http.ResponseWriter, r *http.Request, apiToken string) bool {
+	if apiToken == "" {
+		http.Error(w, "Endpoint disabled: api_token is not configured", http.StatusForbidden)
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) != 1 {
+		slog.Warn("Rejected unauthenticated status request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// lockedWriter serialises writes from several instances' output
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(b)
+}
+
+// prefixWriter writes each complete line with prefix, holding back a
+// trailing partial line until it is completed
+type prefixWriter struct {
+	prefix string
+	w      io.Writer
+	buf    []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := append([]byte(p.prefix), p.buf[:i+1]...)
+		p.buf = p.buf[i+1:]
+		if _, err := p.w.Write(line); err != nil {
+			return len(b), err
+		}
+	}
+	return len(b), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// tagDeployWindow is how long a tag deployed by a webhook isn't deployed
+// again by another one. Publishing a release on GitHub sends both a push of
+// its new tag and a release event.
+const tagDeployWindow = 10 * time.Minute
+
+var recentTagDeploys = struct {
+	sync.Mutex
+	at map[string]time.Time
+}{at: map[string]time.Time{}}
+
+// isAllowedTag reports whether pushing or releasing tag deploys it
+func isAllowedTag(tag string) bool {
+	return tagMatches(appConfig().AllowedTags, tag)
+}
+
+// tagMatches reports whether tag matches one of patterns, where a trailing
+// * matches any suffix; no patterns match no tag
+func tagMatches(patterns []string, tag string) bool {
+	for _, pattern := range patterns {
+		if prefix, wildcard := strings.CutSuffix(pattern, "*"); (wildcard && strings.HasPrefix(tag, prefix)) || tag == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// claimTagDeploy reports whether a webhook may deploy tag, false when
+// another webhook deployed it within tagDeployWindow
+func claimTagDeploy(tag string) bool {
+	recentTagDeploys.Lock()
+	defer recentTagDeploys.Unlock()
+	now := time.Now()
+	for t, at := range recentTagDeploys.at {
+		if now.Sub(at) > tagDeployWindow {
+			delete(recentTagDeploys.at, t)
+		}
+	}
+	if _, ok := recentTagDeploys.at[tag]; ok {
+		return false
+	}
+	recentTagDeploys.at[tag] = now
+	return true
+}
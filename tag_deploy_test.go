@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+	"binaryDeploy/testutil"
+)
+
+func TestIsAllowedTag(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{AllowedTags: []string{"v*", "stable"}})
+	for tag, want := range map[string]bool{"v1.2.0": true, "stable": true, "stable-2": false, "release-1": false} {
+		if got := isAllowedTag(tag); got != want {
+			t.Errorf("isAllowedTag(%q) = %v, want %v", tag, got, want)
+		}
+	}
+	currentConfig.Store(&config.DeployConfig{})
+	if isAllowedTag("v1.2.0") {
+		t.Error("Expected no tag to deploy without allowed_tags")
+	}
+}
+
+func TestClaimTagDeploy_OncePerRelease(t *testing.T) {
+	if !claimTagDeploy("v9.0.0-claim") {
+		t.Fatal("Expected the first webhook of a tag to deploy it")
+	}
+	if claimTagDeploy("v9.0.0-claim") {
+		t.Error("Expected the release event following the tag push to be skipped")
+	}
+	if !claimTagDeploy("v9.0.1-claim") {
+		t.Error("Expected another tag to deploy")
+	}
+}
+
+func TestWebhookHandler_TagsAndReleases(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL:   "https://git.example.com/team/app.git",
+		AllowedBranches: "main",
+		AllowedTags:     []string{"v*"},
+		WebhookTimeout:  10,
+	})
+	claimTagDeploy("v2.0.0")
+
+	tests := []struct {
+		name    string
+		event   string
+		payload string
+		status  int
+		body    string
+	}{
+		{"tag not allowed", "push", `{"ref": "refs/tags/nightly", "after": "3f9c2a7e1b", "repository": {"name": "app", "clone_url": "https://git.example.com/team/app.git"}}`,
+			http.StatusOK, "Tag nightly is not configured"},
+		{"release not published", "release", `{"action": "created", "release": {"tag_name": "v2.0.0"}, "repository": {"name": "app", "clone_url": "https://git.example.com/team/app.git"}}`,
+			http.StatusOK, "Release created ignored"},
+		{"release without tag", "release", `{"action": "published", "release": {}, "repository": {"name": "app", "clone_url": "https://git.example.com/team/app.git"}}`,
+			http.StatusBadRequest, "missing release tag"},
+		{"release of a tag just pushed", "release", `{"action": "published", "release": {"tag_name": "v2.0.0"}, "repository": {"name": "app", "clone_url": "https://git.example.com/team/app.git"}}`,
+			http.StatusOK, "Tag v2.0.0 was already deployed"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.payload))
+		req.Header.Set("X-Gitea-Event", tt.event)
+		rec := httptest.NewRecorder()
+		webhookHandler(rec, req)
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.name, tt.status, tt.body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestCheckoutTargetRevision_Tag(t *testing.T) {
+	repo := testutil.NewRepo(t, map[string]string{"main.go": "package main\n"})
+	release := repo.Commit("Release 1.0", map[string]string{"VERSION": "1.0\n"})
+	gitOutput(t, repo.Dir, "tag", "v1.0.0")
+	repo.Commit("Work in progress", map[string]string{"VERSION": "1.1-dev\n"})
+
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL:   repo.URL,
+		AllowedBranches: "main",
+		AllowedTags:     []string{"v*"},
+		DeployDir:       t.TempDir(),
+	})
+	repoDir := filepath.Join(appConfig().DeployDir, "repo")
+	if err := checkoutTargetRevision(DeployRequest{RepoURL: repo.URL, GitTag: "v1.0.0"}, repoDir); err != nil {
+		t.Fatalf("Checking out v1.0.0: %v", err)
+	}
+	if head := gitHeadCommit(repoDir); head != release {
+		t.Errorf("Expected the tagged commit %s, got %s", release, head)
+	}
+}
+
+func TestValidateDeployRequest_GitTag(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{AllowedBranches: "main", AllowedTags: []string{"v*"}})
+	tests := []struct {
+		req DeployRequest
+		ok  bool
+	}{
+		{DeployRequest{GitTag: "v1.0.0"}, true},
+		{DeployRequest{GitTag: "nightly"}, false},
+		{DeployRequest{GitTag: "-v1"}, false},
+		{DeployRequest{GitTag: "v1..2"}, false},
+		{DeployRequest{GitTag: "v1.0.0", Branch: "main"}, false},
+		{DeployRequest{GitTag: "v1.0.0", SkipFetch: true}, false},
+	}
+	for _, tt := range tests {
+		err := validateDeployRequest(&tt.req)
+		if (err == nil) != tt.ok {
+			t.Errorf("%+v: expected ok=%v, got %v", tt.req, tt.ok, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// legacyTempMaxAge is how old scratch files earlier versions left in the
+// system temp directory must be before they are removed, so ones still in
+// use by a running --simulate aren't
+const legacyTempMaxAge = 24 * time.Hour
+
+// legacyTempPatterns match what earlier versions put in the system temp
+// directory
+var legacyTempPatterns = []string{"binaryDeploy-simulate-*", "binaryDeploy-backup-*", "binaryDeploy-sftp-*"}
+
+// processTempDir holds this process's scratch files; "" if it couldn't be
+// created, in which case the system temp directory is used
+var processTempDir string
+
+// releaseTempDir releases the lock on processTempDir. Holding on to it also
+// keeps the lock file from being closed by the garbage collector.
+var releaseTempDir func()
+
+// setupTempDirs removes scratch directories left behind by crashed
+// processes and creates this process's own. Each process's directory is
+// guarded by a lock file it holds until it exits, so directories of
+// processes still running, e.g. a --simulate next to the server, are kept.
+func setupTempDirs() {
+	root := tempRoot()
+	if err := os.MkdirAll(root, 0700); err != nil {
+		slog.Error("Failed to create temp directory, using the system one", "path", root, "error", err)
+		return
+	}
+	cleanTempRoot(root)
+	cleanLegacyTemp()
+
+	name := fmt.Sprintf("run-%d-%d", os.Getpid(), time.Now().UnixNano())
+	unlock, err := lockFile(filepath.Join(root, name+".lock"))
+	if err != nil {
+		slog.Error("Failed to lock temp directory, using the system one", "path", root, "error", err)
+		return
+	}
+	dir := filepath.Join(root, name)
+	if err := os.Mkdir(dir, 0700); err != nil {
+		unlock()
+		slog.Error("Failed to create temp directory, using the system one", "path", dir, "error", err)
+		return
+	}
+	processTempDir, releaseTempDir = dir, unlock
+}
+
+// removeTempDirs removes this process's scratch directory on a clean exit
+func removeTempDirs() {
+	if processTempDir == "" {
+		return
+	}
+	if err := os.RemoveAll(processTempDir); err != nil {
+		slog.Warn("Failed to remove temp directory", "path", processTempDir, "error", err)
+	}
+	os.Remove(processTempDir + ".lock")
+	releaseTempDir()
+	processTempDir = ""
+}
+
+// tempRoot is temp_dir, or a directory inside the deploy directory
+func tempRoot() string {
+	if appConfig().TempDir != "" {
+		return appConfig().TempDir
+	}
+	return filepath.Join(appConfig().DeployDir, ".tmp")
+}
+
+// makeTempDir creates a scratch directory named after purpose
+func makeTempDir(purpose string) (string, error) {
+	if processTempDir == "" {
+		return os.MkdirTemp("", "binaryDeploy-"+purpose+"-*")
+	}
+	return os.MkdirTemp(processTempDir, purpose+"-*")
+}
+
+// createTempFile creates a scratch file named after purpose, ending in
+// suffix
+func createTempFile(purpose, suffix string) (*os.File, error) {
+	if processTempDir == "" {
+		return os.CreateTemp("", "binaryDeploy-"+purpose+"-*"+suffix)
+	}
+	return os.CreateTemp(processTempDir, purpose+"-*"+suffix)
+}
+
+// cleanTempRoot removes the directories of processes that no longer hold
+// their lock
+func cleanTempRoot(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		slog.Error("Failed to list temp directory", "path", root, "error", err)
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, "run-") {
+			continue
+		}
+		lock := filepath.Join(root, name+".lock")
+		unlock, err := tryLockFile(lock)
+		if err != nil {
+			slog.Warn("Failed to check temp directory lock", "path", lock, "error", err)
+			continue
+		}
+		if unlock == nil {
+			continue // Its process is still running
+		}
+		removeLeftover(filepath.Join(root, name))
+		os.Remove(lock)
+		unlock()
+	}
+
+	// Lock files whose directory was never created or already removed
+	locks, _ := filepath.Glob(filepath.Join(root, "run-*.lock"))
+	for _, lock := range locks {
+		if _, err := os.Stat(strings.TrimSuffix(lock, ".lock")); !os.IsNotExist(err) {
+			continue
+		}
+		if unlock, err := tryLockFile(lock); err == nil && unlock != nil {
+			os.Remove(lock)
+			unlock()
+		}
+	}
+}
+
+// cleanLegacyTemp removes old scratch files earlier versions left in the
+// system temp directory and partial post-mortem bundles
+func cleanLegacyTemp() {
+	for _, pattern := range legacyTempPatterns {
+		matches, _ := filepath.Glob(filepath.Join(os.TempDir(), pattern))
+		for _, path := range matches {
+			if info, err := os.Lstat(path); err == nil && time.Since(info.ModTime()) > legacyTempMaxAge {
+				removeLeftover(path)
+			}
+		}
+	}
+
+	// Bundles are written next to the finished ones and renamed into place
+	partial, _ := filepath.Glob(filepath.Join(appConfig().DeployDir, postmortemDirectory, ".bundle-*"))
+	for _, path := range partial {
+		removeLeftover(path)
+	}
+}
+
+// removeLeftover deletes a scratch file or directory left behind by a crash
+func removeLeftover(path string) {
+	size := dirSize(path)
+	if err := os.RemoveAll(path); err != nil {
+		slog.Warn("Failed to remove leftover temp files", "path", path, "error", err)
+		return
+	}
+	slog.Info("Removed leftover temp files", "path", path, "bytes", size)
+}
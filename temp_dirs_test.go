@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func TestSetupTempDirs_RemovesOnlyDirectoriesOfExitedProcesses(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{DeployDir: t.TempDir()})
+	root := tempRoot()
+
+	crashed := filepath.Join(root, "run-1-1")
+	running := filepath.Join(root, "run-2-2")
+	for _, dir := range []string{crashed, running} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "scratch"), []byte("partial build"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(crashed+".lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(running + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	orphanLock := filepath.Join(root, "run-3-3.lock")
+	if err := os.WriteFile(orphanLock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	setupTempDirs()
+	defer removeTempDirs()
+
+	for _, path := range []string{crashed, crashed + ".lock", orphanLock} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s of an exited process to be removed", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(running, "scratch")); err != nil {
+		t.Errorf("Expected the directory of a running process to be kept: %v", err)
+	}
+	if filepath.Dir(processTempDir) != root || !strings.HasPrefix(filepath.Base(processTempDir), "run-") {
+		t.Errorf("Expected this process's directory under %s, got %q", root, processTempDir)
+	}
+}
+
+func TestTempDirs_ScratchFilesLiveInTheProcessDirectory(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{DeployDir: t.TempDir(), TempDir: filepath.Join(t.TempDir(), "scratch")})
+	setupTempDirs()
+	dir := processTempDir
+	if filepath.Dir(dir) != appConfig().TempDir {
+		t.Fatalf("Expected temp_dir to be used, got %q", dir)
+	}
+
+	scratch, err := makeTempDir("simulate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := createTempFile("backup", ".tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	for _, path := range []string{scratch, f.Name()} {
+		if filepath.Dir(path) != dir {
+			t.Errorf("Expected %s inside %s", path, dir)
+		}
+	}
+	if !strings.HasSuffix(f.Name(), ".tar.gz") {
+		t.Errorf("Expected the suffix to be kept, got %s", f.Name())
+	}
+
+	removeTempDirs()
+	for _, path := range []string{dir, dir + ".lock"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed on a clean exit", path)
+		}
+	}
+	if processTempDir != "" {
+		t.Errorf("Expected processTempDir to be reset, got %q", processTempDir)
+	}
+}
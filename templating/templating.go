@@ -0,0 +1,167 @@
+// Package templating renders per-host configuration files for the target app
+// from templates kept in its repository, e.g. config.tmpl -> config.yaml
+package templating
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// File maps a template to the file rendered from it. Both paths are relative
+// to the directory passed to Render.
+type File struct {
+	Source string
+	Dest   string
+}
+
+// Data is what templates can refer to, e.g. {{ .App }} or {{ .Vars.db_password }}
+type Data struct {
+	App         string
+	Environment string
+	Branch      string
+	Commit      string
+	Port        int
+	ReleaseDir  string // Working directory of the release being deployed
+	Vars        map[string]string
+}
+
+// Env is the deployment environment, a shorthand for {{ .Environment }}
+func (d Data) Env() string {
+	return d.Environment
+}
+
+// ParseCommand checks the placeholders of a build_command or run_command
+func ParseCommand(command string) error {
+	_, err := template.New("command").Option("missingkey=error").Parse(command)
+	return err
+}
+
+// Command resolves the placeholders of a build_command or run_command, such
+// as {{ .Port }} or {{ .Commit }}. Like Render, referring to a variable that
+// isn't set is an error.
+func Command(command string, data Data) (string, error) {
+	if !strings.Contains(command, "{{") {
+		return command, nil
+	}
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(command)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// ParseFiles parses entries of the form "SOURCE:DEST". A bare "SOURCE" ending
+// in .tmpl renders to the same path without the extension.
+func ParseFiles(entries []string) ([]File, error) {
+	var files []File
+	for _, entry := range entries {
+		source, dest, ok := strings.Cut(entry, ":")
+		source, dest = strings.TrimSpace(source), strings.TrimSpace(dest)
+		if !ok {
+			if !strings.HasSuffix(source, ".tmpl") {
+				return nil, fmt.Errorf("template %q: expected SOURCE:DEST or a .tmpl file", entry)
+			}
+			dest = strings.TrimSuffix(source, ".tmpl")
+		}
+		if source == "" || dest == "" {
+			return nil, fmt.Errorf("template %q: source and destination are required", entry)
+		}
+		for _, path := range []string{source, dest} {
+			if filepath.IsAbs(path) || !filepath.IsLocal(path) {
+				return nil, fmt.Errorf("template %q: %s must be a relative path inside the release", entry, path)
+			}
+		}
+		if filepath.Clean(source) == filepath.Clean(dest) {
+			return nil, fmt.Errorf("template %q: destination would overwrite the template", entry)
+		}
+		files = append(files, File{Source: source, Dest: dest})
+	}
+	return files, nil
+}
+
+// Render renders every file inside dir. Referring to a variable that isn't
+// set is an error rather than an empty string, so a missing secret fails the
+// deployment instead of producing a broken config. Rendered files are written
+// atomically and readable only by their owner since they may hold secrets.
+func Render(dir string, files []File, data Data) error {
+	for _, file := range files {
+		if err := renderFile(dir, file, data); err != nil {
+			return fmt.Errorf("rendering %s: %w", file.Source, err)
+		}
+	}
+	return nil
+}
+
+func renderFile(dir string, file File, data Data) error {
+	// A symlink committed to the repository could otherwise read a file of
+	// the host into the release, or write the rendered file outside of it
+	for _, path := range []string{file.Source, file.Dest} {
+		if err := checkNoSymlinks(dir, path); err != nil {
+			return err
+		}
+	}
+
+	text, err := os.ReadFile(filepath.Join(dir, file.Source))
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New(file.Source).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return err
+	}
+
+	dest := filepath.Join(dir, file.Dest)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".render-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(out.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// checkNoSymlinks fails if any existing element of path, relative to dir, is
+// a symlink
+func checkNoSymlinks(dir, path string) error {
+	current := dir
+	for _, name := range strings.Split(filepath.Clean(path), string(filepath.Separator)) {
+		current = filepath.Join(current, name)
+		info, err := os.Lstat(current)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", current)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package templating
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFiles(t *testing.T) {
+	files, err := ParseFiles([]string{"config.tmpl:config.yaml", "conf/app.env.tmpl"})
+	if err != nil {
+		t.Fatalf("ParseFiles failed: %v", err)
+	}
+	if len(files) != 2 || files[0].Dest != "config.yaml" || files[1].Dest != "conf/app.env" {
+		t.Errorf("unexpected files %+v", files)
+	}
+
+	for _, bad := range []string{"config.yaml", "a.tmpl:../escape.yaml", "/etc/a.tmpl:a", "a.tmpl:a.tmpl", ":a"} {
+		if _, err := ParseFiles([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.tmpl"), []byte("app: {{ .App }}\nport: {{ .Port }}\ndb: {{ .Vars.db_password }}\n"), 0644)
+
+	files := []File{{Source: "config.tmpl", Dest: "conf/config.yaml"}}
+	data := Data{App: "myapp", Port: 3000, Vars: map[string]string{"db_password": "hunter2"}}
+	if err := Render(dir, files, data); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	dest := filepath.Join(dir, "conf", "config.yaml")
+	got, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("reading rendered file: %v", err)
+	}
+	if want := "app: myapp\nport: 3000\ndb: hunter2\n"; string(got) != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+	if info, _ := os.Stat(dest); info.Mode().Perm() != 0600 {
+		t.Errorf("expected rendered file to be private, got %v", info.Mode().Perm())
+	}
+}
+
+func TestRender_RejectsSymlinks(t *testing.T) {
+	host := t.TempDir()
+	os.WriteFile(filepath.Join(host, "shadow"), []byte("root:x\n"), 0644)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.tmpl"), []byte("app: {{ .App }}\n"), 0644)
+	os.Symlink(filepath.Join(host, "shadow"), filepath.Join(dir, "secrets.tmpl"))
+	os.Symlink(host, filepath.Join(dir, "conf"))
+
+	for _, file := range []File{
+		{Source: "secrets.tmpl", Dest: "secrets.txt"},
+		{Source: "conf/shadow", Dest: "shadow.txt"},
+		{Source: "config.tmpl", Dest: "conf/config.yaml"},
+	} {
+		if err := Render(dir, []File{file}, Data{App: "myapp"}); err == nil {
+			t.Errorf("expected %s -> %s to be rejected", file.Source, file.Dest)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(host, "config.yaml")); !os.IsNotExist(err) {
+		t.Error("expected nothing to be written through the symlink")
+	}
+}
+
+func TestRender_MissingVariable(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.tmpl"), []byte("db: {{ .Vars.db_password }}\n"), 0644)
+
+	err := Render(dir, []File{{Source: "config.tmpl", Dest: "config.yaml"}}, Data{Vars: map[string]string{}})
+	if err == nil {
+		t.Fatal("expected an error for an unset variable")
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "config.yaml")); !os.IsNotExist(statErr) {
+		t.Error("expected no output file after a failed render")
+	}
+}
+
+func TestCommand(t *testing.T) {
+	data := Data{Environment: "production", Commit: "c7478f14", Port: 3001, ReleaseDir: "/srv/app/releases/blue"}
+	got, err := Command("{{ .ReleaseDir }}/app --port={{ .Port }} --env={{ .Env }} --version={{ .Commit }}", data)
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	if want := "/srv/app/releases/blue/app --port=3001 --env=production --version=c7478f14"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got, err := Command("./app --port=$PORT", data); err != nil || got != "./app --port=$PORT" {
+		t.Errorf("expected a command without placeholders unchanged, got %q, %v", got, err)
+	}
+	if _, err := Command("./app {{ .Vars.missing }}", Data{Vars: map[string]string{}}); err == nil {
+		t.Error("expected an error for an unset variable")
+	}
+	if err := ParseCommand("./app {{ .Port"); err == nil {
+		t.Error("expected an error for an unterminated placeholder")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"binaryDeploy/config"
+	"binaryDeploy/portalloc"
+)
+
+const (
+	// tenantEnv, tenantListenEnv and tenantLimitsEnv tell a tenant instance
+	// started by the supervisor which namespace it serves, where to listen
+	// and which limits apply
+	tenantEnv       = "BINARYDEPLOY_TENANT"
+	tenantListenEnv = "BINARYDEPLOY_TENANT_LISTEN"
+	tenantLimitsEnv = "BINARYDEPLOY_TENANT_LIMITS"
+)
+
+// tenantName is the namespace this instance serves when it was started by
+// a multi-tenant supervisor, empty otherwise
+var tenantName = os.Getenv(tenantEnv)
+
+// applyTenantOverrides confines a tenant instance: it only listens on the
+// loopback port the supervisor assigned over plain HTTP, serves everything
+// under /t/<name>, trusts the supervisor's forwarded headers, can't replace the
+// binary every tenant shares and is held to the supervisor's limits
+func applyTenantOverrides(cfg *config.DeployConfig) error {
+	if tenantName == "" {
+		return nil
+	}
+	limits, err := config.ParseLimits(os.Getenv(tenantLimitsEnv))
+	if err != nil {
+		return fmt.Errorf("%s: %w", tenantLimitsEnv, err)
+	}
+	cfg.Limits = cfg.Limits.Override(limits)
+	cfg.Listen = []string{os.Getenv(tenantListenEnv)}
+	cfg.AdminListen = nil
+	cfg.TLS = config.TLS{}
+	cfg.BasePath = "/t/" + tenantName
+	cfg.TrustedProxies = append(cfg.TrustedProxies, "127.0.0.1/32", "::1/128")
+	if !slices.Contains(cfg.DisabledEndpoints, "update-self") {
+		cfg.DisabledEndpoints = append(cfg.DisabledEndpoints, "update-self")
+	}
+	return nil
+}
+
+// runTenantsCommand implements "binaryDeploy tenants [file]": it starts one
+// isolated binaryDeploy instance per tenant, each in its own directory with
+// its own deploy.config, and serves them all from one front server under
+// /t/<name>/
+func runTenantsCommand(args []string) int {
+	path := "tenants.config"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	cfg, err := config.LoadTenantsConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading %s: %v\n", path, err)
+		return 1
+	}
+	if err := config.ValidateTenantsConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Tenants configuration validation failed: %v\n", err)
+		return 1
+	}
+	for _, tenant := range cfg.Tenants {
+		if _, err := os.Stat(filepath.Join(tenant.Dir, "deploy.config")); err != nil {
+			fmt.Fprintf(os.Stderr, "Tenant %s: %v\n", tenant.Name, err)
+			return 1
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating the binaryDeploy executable: %v\n", err)
+		return 1
+	}
+
+	var ports *portalloc.Allocator
+	if cfg.PortRange.Size() > 0 {
+		names := make([]string, 0, len(cfg.Tenants))
+		for _, tenant := range cfg.Tenants {
+			names = append(names, tenant.Name)
+		}
+		leases := filepath.Join(filepath.Dir(path), "tenant-ports.json")
+		if ports, err = openPortAllocator(cfg.PortRange, leases, []string{cfg.Listen}, names); err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening port allocations: %v\n", err)
+			return 1
+		}
+	}
+
+	output := &lockedWriter{w: os.Stdout}
+	processes := make(map[string]*instanceProcess, len(cfg.Tenants))
+	for _, tenant := range cfg.Tenants {
+		proc := &instanceProcess{
+			kind:   "tenant",
+			name:   tenant.Name,
+			dir:    tenant.Dir,
+			user:   tenant.User,
+			port:   tenant.Port,
+			path:   "/t/" + tenant.Name + "/",
+			limits: tenant.Limits,
+			env: []string{
+				tenantEnv + "=" + tenant.Name,
+				tenantListenEnv + "=" + net.JoinHostPort("127.0.0.1", strconv.Itoa(tenant.Port)),
+				tenantLimitsEnv + "=" + tenant.Limits.String(),
+			},
+			output: output,
+			quit:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		if ports != nil {
+			if proc.appPorts, err = ports.Allocate(tenant.Name, cfg.TenantPorts); err != nil {
+				fmt.Fprintf(os.Stderr, "Tenant %s: %v\n", tenant.Name, err)
+				return 1
+			}
+			proc.env = append(proc.env, allocatedPortsEnv+"="+proc.appPorts.String())
+			slog.Info("Allocated application ports", "tenant", tenant.Name, "ports", proc.appPorts.String())
+		}
+		processes[tenant.Name] = proc
+		go proc.supervise(executable)
+	}
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           tenantRoutes(cfg, processes),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	go func() {
+		slog.Info("Starting multi-tenant server", "addr", cfg.Listen, "tenants", len(cfg.Tenants))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server failed", "addr", cfg.Listen, "error", err)
+			fmt.Fprintf(os.Stderr, "Error listening on %s: %v\n", cfg.Listen, err)
+			os.Exit(1)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("Shutting down tenants...")
+	ctx, cancel := context.WithTimeout(context.Background(), instanceStopTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Server forced to shutdown", "addr", cfg.Listen, "error", err)
+	}
+	stopInstances(ctx, processes)
+	slog.Info("All tenants stopped")
+	return 0
+}
+
+// tenantRoutes serves each tenant under /t/<name>/ by proxying to its
+// instance, plus a tenant index and the /tenants status endpoint
+func tenantRoutes(cfg *config.TenantsConfig, processes map[string]*instanceProcess) http.Handler {
+	proxies := make(map[string]*httputil.ReverseProxy, len(processes))
+	for name, proc := range processes {
+		proxies[name] = instanceProxy(proc, "")
+	}
+
+	names := make([]string, 0, len(processes))
+	for name := range processes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/t/", func(w http.ResponseWriter, r *http.Request) {
+		name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/t/"), "/")
+		proxy, ok := proxies[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/tenants", func(w http.ResponseWriter, r *http.Request) {
+		if !supervisorAuthorized(w, r, cfg.APIToken) {
+			return
+		}
+		statuses := make([]instanceStatus, 0, len(names))
+		for _, name := range names {
+			statuses = append(statuses, processes[name].status())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"tenants": statuses})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		tenantIndexTemplate.Execute(w, names)
+	})
+	return mux
+}
+
+var tenantIndexTemplate = template.Must(template.New("tenants").Parse(`<!DOCTYPE html>
+<html><head><title>binaryDeploy tenants</title></head>
+<body><h1>Tenants</h1><ul>
+{{range .}}<li><a href="/t/{{.}}/">{{.}}</a></li>
+{{end}}</ul></body></html>
+`))
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func writeTenantsConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tenants.config")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTenantsConfig_AssignsPortsInNameOrder(t *testing.T) {
+	cfg, err := config.LoadTenantsConfig(writeTenantsConfig(t, `listen=127.0.0.1:9000
+tenant_port_base=20000
+tenants.globex.dir=/srv/globex
+tenants.acme.dir=/srv/acme
+tenants.acme.user=acme
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.ValidateTenantsConfig(cfg); err != nil {
+		t.Fatalf("Expected the configuration to be valid: %v", err)
+	}
+	if cfg.Listen != "127.0.0.1:9000" || len(cfg.Tenants) != 2 {
+		t.Fatalf("Unexpected configuration %+v", cfg)
+	}
+	acme, globex := cfg.Tenants[0], cfg.Tenants[1]
+	if acme.Name != "acme" || acme.Port != 20000 || acme.User != "acme" || acme.Dir != "/srv/acme" {
+		t.Errorf("Unexpected tenant %+v", acme)
+	}
+	if globex.Name != "globex" || globex.Port != 20001 {
+		t.Errorf("Unexpected tenant %+v", globex)
+	}
+}
+
+func TestValidateTenantsConfig_RejectsSharedState(t *testing.T) {
+	tests := map[string]string{
+		"shared directory": "tenants.acme.dir=/srv/shared\ntenants.globex.dir=/srv/shared/../shared\n",
+		"bad name":         "tenants.Acme_Corp.dir=/srv/acme\n",
+		"missing dir":      "tenants.acme.user=acme\n",
+		"no tenants":       "listen=:8080\n",
+	}
+	for name, content := range tests {
+		cfg, err := config.LoadTenantsConfig(writeTenantsConfig(t, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := config.ValidateTenantsConfig(cfg); err == nil {
+			t.Errorf("%s: expected the configuration to be refused", name)
+		}
+	}
+	if _, err := config.LoadTenantsConfig(writeTenantsConfig(t, "tenants.acme.color=blue\n")); err == nil {
+		t.Error("Expected an unknown tenant option to be refused")
+	}
+}
+
+func TestTenantRoutes_ServesEachTenantUnderItsPath(t *testing.T) {
+	processes := map[string]*instanceProcess{}
+	for _, name := range []string{"acme", "globex"} {
+		name := name
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "%s %s %s", name, r.URL.Path, r.Header.Get("X-Forwarded-For"))
+		}))
+		t.Cleanup(server.Close)
+		u, _ := url.Parse(server.URL)
+		port, _ := strconv.Atoi(u.Port())
+		processes[name] = &instanceProcess{kind: "tenant", name: name, port: port, path: "/t/" + name + "/"}
+	}
+	routes := tenantRoutes(&config.TenantsConfig{APIToken: "tenants-test-token"}, processes)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.7:40000"
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		routes.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/t/globex/status", "")
+	if rec.Code != http.StatusOK || rec.Body.String() != "globex /t/globex/status 203.0.113.7" {
+		t.Errorf("Expected globex's instance to serve its path with the client address, got %d %q", rec.Code, rec.Body)
+	}
+	if rec := get("/t/initech/status", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown tenant to get 404, got %d", rec.Code)
+	}
+
+	if rec := get("/tenants", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected /tenants to require the api_token, got %d", rec.Code)
+	}
+	rec = get("/tenants", "tenants-test-token")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"acme"`) || !strings.Contains(rec.Body.String(), `"name":"globex"`) {
+		t.Errorf("Expected the status of both tenants, got %d %s", rec.Code, rec.Body)
+	}
+
+	rec = get("/", "")
+	if !strings.Contains(rec.Body.String(), `href="/t/acme/"`) || !strings.Contains(rec.Body.String(), `href="/t/globex/"`) {
+		t.Errorf("Expected the index to link every tenant, got %s", rec.Body)
+	}
+}
+
+func TestApplyTenantOverrides_ConfinesTheInstance(t *testing.T) {
+	previous := tenantName
+	tenantName = "acme"
+	t.Cleanup(func() { tenantName = previous })
+	t.Setenv(tenantListenEnv, "127.0.0.1:20000")
+	t.Setenv(tenantLimitsEnv, "")
+
+	cfg := &config.DeployConfig{
+		Listen:      []string{":80"},
+		AdminListen: []string{":9090"},
+		TLS:         config.TLS{CertFile: "cert.pem", KeyFile: "key.pem"},
+	}
+	if err := applyTenantOverrides(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Listen) != 1 || cfg.Listen[0] != "127.0.0.1:20000" || cfg.AdminListen != nil || cfg.TLS.CertFile != "" {
+		t.Errorf("Expected only the assigned loopback address over plain HTTP, got %v %v %+v", cfg.Listen, cfg.AdminListen, cfg.TLS)
+	}
+	if cfg.BasePath != "/t/acme" {
+		t.Errorf("Expected base path /t/acme, got %q", cfg.BasePath)
+	}
+	if !slices.Contains(cfg.DisabledEndpoints, "update-self") {
+		t.Errorf("Expected self-updates to be disabled, got %v", cfg.DisabledEndpoints)
+	}
+	if _, ok := selfUpdateRef("refs/heads/main"); ok {
+		t.Error("Expected pushes not to self-update a tenant instance")
+	}
+}
@@ -2,10 +2,7 @@ package test
 
 import (
 	"bytes"
-	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -21,6 +18,7 @@ import (
 	"time"
 
 	"binaryDeploy/processmanager"
+	"binaryDeploy/testutil"
 )
 
 // TestConfig holds configuration for integration tests
@@ -396,9 +394,7 @@ func (env *TestEnvironment) GenerateWebhookPayload(repoURL, repoName, branch, co
 
 // GenerateHMACSignature generates HMAC-SHA256 signature
 func (env *TestEnvironment) GenerateHMACSignature(payload string) string {
-	h := hmac.New(sha256.New, []byte(env.Config.Secret))
-	h.Write([]byte(payload))
-	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+	return testutil.Sign(env.Config.Secret, []byte(payload))
 }
 
 // SendWebhookRequest sends a webhook request to the server
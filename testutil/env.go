@@ -0,0 +1,118 @@
+package testutil
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Env is a scratch environment for running binaryDeploy against a Repo: a
+// working directory holding deploy.config, a deploy directory and free ports
+// for the server and the application
+type Env struct {
+	Dir        string
+	DeployDir  string
+	ConfigPath string
+	Secret     string
+	Port       int // binaryDeploy's port
+	AppPort    int // The deployed application's port
+
+	// Config holds the deploy.config values written by WriteConfig. Adjust it
+	// before writing.
+	Config map[string]string
+}
+
+// NewEnv returns an environment configured to deploy repo. Build and run
+// commands default to building a Go app into ./app.
+func NewEnv(t testing.TB, repo *Repo) *Env {
+	t.Helper()
+
+	dir := t.TempDir()
+	e := &Env{
+		Dir:        dir,
+		DeployDir:  filepath.Join(dir, "deployments"),
+		ConfigPath: filepath.Join(dir, "deploy.config"),
+		Secret:     "test-webhook-secret",
+		Port:       FreePort(t),
+		AppPort:    FreePort(t),
+	}
+	e.Config = map[string]string{
+		"target_repo_url":  repo.URL,
+		"allowed_branches": "main",
+		"secret":           e.Secret,
+		"build_command":    "go build -o app .",
+		"run_command":      "./app",
+		"binary_port":      fmt.Sprint(e.Port),
+		"port":             fmt.Sprint(e.AppPort),
+		"deploy_dir":       e.DeployDir,
+		"self_update_dir":  filepath.Join(dir, "self-update"),
+		"log_file":         filepath.Join(dir, "binaryDeploy.log"),
+	}
+	return e
+}
+
+// WriteConfig writes Config to ConfigPath in deploy.config format
+func (e *Env) WriteConfig(t testing.TB) {
+	t.Helper()
+
+	keys := make([]string, 0, len(e.Config))
+	for k := range e.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s=%s\n", k, e.Config[k])
+	}
+	if err := os.WriteFile(e.ConfigPath, []byte(b.String()), 0600); err != nil {
+		t.Fatalf("writing deploy.config: %v", err)
+	}
+}
+
+// URL returns the address of binaryDeploy's endpoint at path
+func (e *Env) URL(path string) string {
+	return fmt.Sprintf("http://127.0.0.1:%d%s", e.Port, path)
+}
+
+// FreePort returns a TCP port that was free when it was checked
+func FreePort(t testing.TB) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("finding a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// HTTPApp returns the files of a minimal Go web app for a Repo. It listens on
+// $PORT, answers /health with 200 and / with version.
+func HTTPApp(version string) map[string]string {
+	return map[string]string{
+		"go.mod": "module testapp\n\ngo 1.21\n",
+		"main.go": `package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+)
+
+func main() {
+	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, ` + fmt.Sprintf("%q", version) + `)
+	})
+	http.ListenAndServe(":"+os.Getenv("PORT"), nil)
+}
+`,
+	}
+}
@@ -0,0 +1,86 @@
+package testutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Repo is a throwaway git repository that binaryDeploy can clone via its
+// file:// URL
+type Repo struct {
+	Dir string
+	URL string
+	t   testing.TB
+}
+
+// NewRepo creates a repository on branch main with files committed. It is
+// removed when the test ends.
+func NewRepo(t testing.TB, files map[string]string) *Repo {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "repo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("creating repo dir: %v", err)
+	}
+	r := &Repo{Dir: dir, URL: "file://" + dir, t: t}
+
+	r.git("init", "-q")
+	r.git("symbolic-ref", "HEAD", "refs/heads/main")
+	r.git("config", "user.email", "test@example.com")
+	r.git("config", "user.name", "Test User")
+	r.Commit("Initial commit", files)
+	return r
+}
+
+// Commit writes files (paths relative to the repo) and commits them on the
+// current branch, returning the new commit SHA
+func (r *Repo) Commit(message string, files map[string]string) string {
+	r.t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(r.Dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			r.t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	r.git("add", "-A")
+	r.git("commit", "-q", "--allow-empty", "-m", message)
+	return r.Head()
+}
+
+// Branch creates branch name from the current commit and checks it out
+func (r *Repo) Branch(name string) {
+	r.t.Helper()
+	r.git("checkout", "-q", "-b", name)
+}
+
+// Checkout switches to an existing branch
+func (r *Repo) Checkout(name string) {
+	r.t.Helper()
+	r.git("checkout", "-q", name)
+}
+
+// Head returns the SHA of the checked out commit
+func (r *Repo) Head() string {
+	r.t.Helper()
+	return r.git("rev-parse", "HEAD")
+}
+
+// git runs a git command in the repo, failing the test on error
+func (r *Repo) git(args ...string) string {
+	r.t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
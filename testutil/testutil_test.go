@@ -0,0 +1,84 @@
+package testutil
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func TestSignedWebhookRequest(t *testing.T) {
+	body := PushEvent{RepoName: "app", CloneURL: "file:///tmp/app", Branch: "main", Commit: "abc123", Pusher: "octocat"}.Payload()
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if decoded["ref"] != "refs/heads/main" {
+		t.Errorf("unexpected ref %v", decoded["ref"])
+	}
+
+	req, err := NewWebhookRequest("http://localhost/webhook", "s3cret", body)
+	if err != nil {
+		t.Fatalf("NewWebhookRequest failed: %v", err)
+	}
+	// Known HMAC-SHA256 of "" with key "key"
+	if got := Sign("key", nil); got != "sha256=5d5d139563c95b5967b9bd9a8c9b233a9dedb45072794cd232dc1b74832607d0" {
+		t.Errorf("Sign = %s", got)
+	}
+	if req.Header.Get(SignatureHeader) != Sign("s3cret", body) {
+		t.Error("request is not signed with the given secret")
+	}
+	sent, _ := io.ReadAll(req.Body)
+	if string(sent) != string(body) {
+		t.Error("request body differs from the payload")
+	}
+}
+
+func TestRepo(t *testing.T) {
+	repo := NewRepo(t, HTTPApp("v1"))
+	first := repo.Head()
+
+	repo.Branch("feature")
+	second := repo.Commit("Bump version", HTTPApp("v2"))
+	if second == first || len(second) != 40 {
+		t.Fatalf("expected a new commit, got %s after %s", second, first)
+	}
+
+	repo.Checkout("main")
+	if repo.Head() != first {
+		t.Error("expected main to still point at the first commit")
+	}
+	if !strings.HasPrefix(repo.URL, "file://") {
+		t.Errorf("unexpected repo URL %s", repo.URL)
+	}
+}
+
+func TestEnv_WriteConfig(t *testing.T) {
+	env := NewEnv(t, NewRepo(t, HTTPApp("v1")))
+	env.Config["health_check_url"] = "/health"
+	env.WriteConfig(t)
+
+	data, err := os.ReadFile(env.ConfigPath)
+	if err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+	for _, want := range []string{"secret=test-webhook-secret\n", "health_check_url=/health\n", "target_repo_url=file://"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %q in deploy.config:\n%s", want, data)
+		}
+	}
+	cfg, err := config.LoadDeployConfig(env.ConfigPath)
+	if err != nil {
+		t.Fatalf("binaryDeploy rejected the generated config: %v", err)
+	}
+	if cfg.DeployDir != env.DeployDir || cfg.ApplicationPort != env.AppPort {
+		t.Errorf("config not loaded as written: %+v", cfg)
+	}
+	if env.Port == env.AppPort {
+		t.Error("expected distinct ports for the server and the app")
+	}
+}
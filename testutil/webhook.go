@@ -0,0 +1,73 @@
+// Package testutil helps teams that embed binaryDeploy write integration
+// tests: signed GitHub webhook payloads, throwaway git repositories and a
+// scratch deploy environment. Its exported API is kept stable.
+package testutil
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// SignatureHeader is the header GitHub sends the payload signature in
+const SignatureHeader = "X-Hub-Signature-256"
+
+// PushEvent describes a GitHub push webhook
+type PushEvent struct {
+	RepoName string
+	CloneURL string
+	Branch   string
+	Commit   string
+	Message  string
+	Pusher   string
+}
+
+// Payload returns the JSON body GitHub would send for e
+func (e PushEvent) Payload() []byte {
+	var p struct {
+		Ref        string `json:"ref"`
+		Repository struct {
+			Name     string `json:"name"`
+			CloneURL string `json:"clone_url"`
+		} `json:"repository"`
+		HeadCommit struct {
+			ID      string `json:"id"`
+			Message string `json:"message"`
+		} `json:"head_commit"`
+		Pusher struct {
+			Name string `json:"name"`
+		} `json:"pusher"`
+	}
+	p.Ref = "refs/heads/" + e.Branch
+	p.Repository.Name = e.RepoName
+	p.Repository.CloneURL = e.CloneURL
+	p.HeadCommit.ID = e.Commit
+	p.HeadCommit.Message = e.Message
+	p.Pusher.Name = e.Pusher
+
+	data, _ := json.Marshal(p) // Only strings, can't fail
+	return data
+}
+
+// Sign returns the X-Hub-Signature-256 value for body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewWebhookRequest returns a signed push webhook request for url. Pass it to
+// a handler through httptest.NewRecorder or send it with an http.Client.
+func NewWebhookRequest(url, secret string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+	return req, nil
+}
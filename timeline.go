@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"binaryDeploy/history"
+	"binaryDeploy/processmanager"
+)
+
+const (
+	defaultTimelineEntries = 200
+	maxTimelineEntries     = 1000
+)
+
+// Timeline entry sources
+const (
+	TimelineDeployment = "deployment"
+	TimelineProcess    = "process"
+	TimelineSelfUpdate = "self-update"
+	TimelineLog        = "log"
+)
+
+// TimelineEntry is one thing that happened, from any source
+type TimelineEntry struct {
+	Time    time.Time   `json:"time"`
+	Source  string      `json:"source"`
+	Type    string      `json:"type"`
+	Summary string      `json:"summary"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// timelineHandler handles GET /timeline?since=T&until=T&limit=N&source=S. It
+// merges deployments, self-updates, process events and error logs into one
+// feed ordered oldest first, keeping the latest limit entries in the window.
+func timelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var since, until time.Time
+	for name, dest := range map[string]*time.Time{"since": &since, "until": &until} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, name+" must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			*dest = t
+		}
+	}
+
+	limit := defaultTimelineEntries
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxTimelineEntries)
+	}
+
+	sources := map[string]bool{}
+	for _, s := range query["source"] {
+		sources[s] = true
+	}
+	include := func(source string) bool { return len(sources) == 0 || sources[source] }
+
+	var entries []TimelineEntry
+	if include(TimelineDeployment) || include(TimelineSelfUpdate) {
+		entries = append(entries, historyTimeline(include)...)
+	}
+	if include(TimelineProcess) {
+		entries = append(entries, processTimeline(processManager.Events().Recent(0))...)
+	}
+	if include(TimelineLog) {
+		entries = append(entries, logTimeline(globalLogStreamer.GetBufferedLogs())...)
+	}
+
+	window := entries[:0]
+	for _, e := range entries {
+		if (since.IsZero() || !e.Time.Before(since)) && (until.IsZero() || !e.Time.After(until)) {
+			window = append(window, e)
+		}
+	}
+	sort.SliceStable(window, func(i, j int) bool { return window[i].Time.Before(window[j].Time) })
+	if len(window) > limit {
+		window = window[len(window)-limit:]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": window,
+	})
+}
+
+// historyTimeline turns each history record into start and finish entries
+func historyTimeline(include func(string) bool) []TimelineEntry {
+	if deployHistory == nil {
+		return nil
+	}
+	records, err := deployHistory.Recent(0)
+	if err != nil {
+		slog.Error("Failed to read deployment history for timeline", "error", err)
+		return nil
+	}
+
+	var entries []TimelineEntry
+	for _, rec := range records {
+		source := TimelineDeployment
+		if rec.Kind == history.KindSelfUpdate {
+			source = TimelineSelfUpdate
+		}
+		if !include(source) {
+			continue
+		}
+
+		what := rec.Kind
+		if rec.Commit != "" {
+			what += " of " + rec.Commit[:min(8, len(rec.Commit))]
+		}
+		if rec.Branch != "" {
+			what += " (" + rec.Branch + ")"
+		}
+		summary := summarizeDeployment(rec)
+
+		entries = append(entries, TimelineEntry{
+			Time:    rec.StartedAt,
+			Source:  source,
+			Type:    rec.Kind + "_started",
+			Summary: fmt.Sprintf("%s started: %s", what, rec.Trigger),
+			Details: summary,
+		})
+		finished := TimelineEntry{
+			Time:    rec.FinishedAt,
+			Source:  source,
+			Type:    rec.Kind + "_" + rec.Result,
+			Summary: fmt.Sprintf("%s finished: %s in %s", what, rec.Result, summary.Duration),
+			Details: summary,
+		}
+		if rec.Error != "" {
+			finished.Summary += ": " + rec.Error
+		}
+		entries = append(entries, finished)
+	}
+	return entries
+}
+
+// processTimeline turns process events into entries
+func processTimeline(events []processmanager.Event) []TimelineEntry {
+	entries := make([]TimelineEntry, 0, len(events))
+	for _, e := range events {
+		summary := fmt.Sprintf("instance %d %s", e.Instance, e.Type)
+		switch {
+		case e.Type == processmanager.EventCrashed:
+			summary += ": " + crashSummary(e)
+		case e.Reason != "":
+			summary += ": " + e.Reason
+		}
+		if e.PID != 0 {
+			summary += fmt.Sprintf(" (pid %d)", e.PID)
+		}
+		entries = append(entries, TimelineEntry{
+			Time:    e.Time,
+			Source:  TimelineProcess,
+			Type:    e.Type,
+			Summary: summary,
+			Details: e,
+		})
+	}
+	return entries
+}
+
+// logTimeline picks the error-level entries out of the buffered logs
+func logTimeline(logs [][]byte) []TimelineEntry {
+	var entries []TimelineEntry
+	for _, data := range logs {
+		var entry StreamingLogEntry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.Level != slog.LevelError.String() {
+			continue
+		}
+		entries = append(entries, TimelineEntry{
+			Time:    entry.Timestamp,
+			Source:  TimelineLog,
+			Type:    "error",
+			Summary: entry.Message,
+			Details: entry.Fields,
+		})
+	}
+	return entries
+}
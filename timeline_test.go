@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+	"binaryDeploy/history"
+	"binaryDeploy/kv"
+	"binaryDeploy/processmanager"
+)
+
+func getTimeline(t *testing.T, query string) []TimelineEntry {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	timelineHandler(rec, httptest.NewRequest(http.MethodGet, "/timeline"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /timeline%s: %d %s", query, rec.Code, rec.Body)
+	}
+	var body struct {
+		Entries []TimelineEntry `json:"entries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Entries
+}
+
+func timelineTypes(entries []TimelineEntry) []string {
+	types := make([]string, len(entries))
+	for i, e := range entries {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestTimelineHandler_MergesSourcesInOrder(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{AppName: "myapp"})
+	store, err := kv.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployHistory, err = history.NewStore(store); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { deployHistory = nil })
+	processManager = processmanager.NewGroup(1)
+	globalLogStreamer = NewLogStreamer(slog.NewTextHandler(io.Discard, nil), 10, 0, nil)
+
+	base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	deployHistory.Append(history.Record{ID: history.NewID(), Kind: history.KindDeploy, Commit: "3f9c2a7e1b", Branch: "main",
+		Trigger: "webhook", Result: history.ResultFailure, Error: "build failed", StartedAt: base, FinishedAt: base.Add(2 * time.Minute)})
+	deployHistory.Append(history.Record{ID: history.NewID(), Kind: history.KindSelfUpdate, Commit: "a1b2c3d4e5",
+		Trigger: "manual", Result: history.ResultSuccess, StartedAt: base.Add(5 * time.Minute), FinishedAt: base.Add(6 * time.Minute)})
+	processManager.Events().Add(processmanager.Event{Time: base.Add(time.Minute), Type: processmanager.EventStarted, PID: 4242})
+
+	want := []string{"deploy_started", processmanager.EventStarted, "deploy_failure", "self-update_started", "self-update_success"}
+	entries := getTimeline(t, "")
+	if types := timelineTypes(entries); !slices.Equal(types, want) {
+		t.Fatalf("Expected %v, got %v", want, types)
+	}
+	if summary := entries[2].Summary; summary != "deploy of 3f9c2a7e (main) finished: failure in 2m0s: build failed" {
+		t.Errorf("Unexpected deployment summary %q", summary)
+	}
+
+	if types := timelineTypes(getTimeline(t, "?source=process&source=self-update")); !slices.Equal(types, []string{processmanager.EventStarted, "self-update_started", "self-update_success"}) {
+		t.Errorf("Expected only process and self-update entries, got %v", types)
+	}
+	window := "?since=" + base.Add(time.Minute).Format(time.RFC3339) + "&until=" + base.Add(5*time.Minute).Format(time.RFC3339)
+	if types := timelineTypes(getTimeline(t, window)); !slices.Equal(types, want[1:4]) {
+		t.Errorf("Expected the entries within the window, got %v", types)
+	}
+	if types := timelineTypes(getTimeline(t, "?limit=2")); !slices.Equal(types, want[3:]) {
+		t.Errorf("Expected the latest 2 entries, got %v", types)
+	}
+
+	for _, query := range []string{"?since=yesterday", "?limit=0"} {
+		rec := httptest.NewRecorder()
+		timelineHandler(rec, httptest.NewRequest(http.MethodGet, "/timeline"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestLogTimeline_KeepsErrors(t *testing.T) {
+	var logs [][]byte
+	for _, entry := range []StreamingLogEntry{
+		{Timestamp: time.Now(), Level: slog.LevelInfo.String(), Message: "Deployment started"},
+		{Timestamp: time.Now(), Level: slog.LevelError.String(), Message: "Health check failed"},
+	} {
+		data, _ := json.Marshal(entry)
+		logs = append(logs, data)
+	}
+	logs = append(logs, []byte("not json"))
+
+	entries := logTimeline(logs)
+	if len(entries) != 1 || entries[0].Summary != "Health check failed" || entries[0].Source != TimelineLog {
+		t.Errorf("Expected only the error entry, got %+v", entries)
+	}
+}
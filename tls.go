@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"binaryDeploy/config"
+	"binaryDeploy/tlscert"
+)
+
+// serverTLS is the configuration the listeners serve HTTPS with, nil when
+// they serve plain HTTP
+var serverTLS *tls.Config
+
+// certificateManager obtains and renews the certificate when acme_hostnames
+// is set
+var certificateManager *tlscert.Manager
+
+// setupTLS loads tls_cert_file or prepares the ACME certificate manager,
+// exiting when the certificate can't be loaded
+func setupTLS() {
+	var err error
+	serverTLS, certificateManager, err = newServerTLS(appConfig().TLS)
+	if err != nil {
+		slog.Error("Failed to set up TLS", "error", err)
+		fmt.Fprintf(os.Stderr, "Error setting up TLS: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// startCertificateRenewal obtains the ACME certificate in the background
+// and keeps it renewed. It runs once the listeners are up, since the CA
+// validates the hostnames by connecting to them.
+func startCertificateRenewal() {
+	if certificateManager != nil {
+		go certificateManager.Run(context.Background())
+	}
+}
+
+// newServerTLS builds the TLS configuration of settings, returning the ACME
+// manager to run when the certificate is obtained automatically
+func newServerTLS(settings config.TLS) (*tls.Config, *tlscert.Manager, error) {
+	switch {
+	case settings.ACME():
+		manager, err := tlscert.NewManager(tlscert.Config{
+			Hostnames:    settings.ACMEHostnames,
+			Email:        settings.ACMEEmail,
+			DirectoryURL: settings.ACMEDirectoryURL,
+			CacheDir:     settings.ACMECacheDir,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		slog.Info("Serving HTTPS with an ACME certificate", "hostnames", settings.ACMEHostnames, "ca", settings.ACMEDirectoryURL)
+		return manager.TLSConfig(), manager, nil
+	case settings.CertFile != "":
+		cert, err := tlscert.LoadFile(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		slog.Info("Serving HTTPS", "cert", settings.CertFile)
+		return &tls.Config{GetCertificate: cert.GetCertificate, MinVersion: tls.VersionTLS12}, nil, nil
+	}
+	return nil, nil, nil
+}
@@ -0,0 +1,283 @@
+package tlscert
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// acmeClient speaks the subset of ACME (RFC 8555) needed to obtain a
+// certificate: an account, an order, its authorizations and finalization.
+// It is not safe for concurrent use.
+type acmeClient struct {
+	directoryURL string
+	http         *http.Client
+	key          *ecdsa.PrivateKey // Account key, ES256
+	kid          string            // Account URL once registered
+
+	directory struct {
+		NewNonce   string `json:"newNonce"`
+		NewAccount string `json:"newAccount"`
+		NewOrder   string `json:"newOrder"`
+	}
+	nonce string
+}
+
+// acmeOrder is an order or, from newOrder, the order being created
+type acmeOrder struct {
+	Status         string       `json:"status"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate"`
+	Error          *acmeProblem `json:"error"`
+}
+
+type acmeAuthorization struct {
+	Status     string `json:"status"`
+	Identifier struct {
+		Value string `json:"value"`
+	} `json:"identifier"`
+	Challenges []acmeChallenge `json:"challenges"`
+}
+
+type acmeChallenge struct {
+	Type   string       `json:"type"`
+	URL    string       `json:"url"`
+	Token  string       `json:"token"`
+	Status string       `json:"status"`
+	Error  *acmeProblem `json:"error"`
+}
+
+// acmeProblem is an RFC 7807 problem document returned by the CA
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+func (p *acmeProblem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+// errBadNonce is the problem type of a request whose nonce was rejected;
+// the request is retried with the fresh nonce of the rejection
+const errBadNonce = "urn:ietf:params:acme:error:badNonce"
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// jwk is the account's public key as a JSON Web Key, with its members in
+// the lexicographic order its thumbprint requires
+func (c *acmeClient) jwk() string {
+	pub, _ := c.key.PublicKey.ECDH()
+	point := pub.Bytes() // 0x04 || X || Y
+	return fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`, b64(point[1:33]), b64(point[33:]))
+}
+
+// keyAuthorization proves to the CA that the account holder answers token
+func (c *acmeClient) keyAuthorization(token string) string {
+	thumbprint := sha256.Sum256([]byte(c.jwk()))
+	return token + "." + b64(thumbprint[:])
+}
+
+// discover fetches the CA's directory
+func (c *acmeClient) discover(ctx context.Context) error {
+	if c.directory.NewOrder != "" {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.directoryURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ACME directory %s: %s", c.directoryURL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(&c.directory)
+}
+
+// register creates the account, or looks up the existing one for the key
+func (c *acmeClient) register(ctx context.Context, email string) error {
+	if c.kid != "" {
+		return nil
+	}
+	account := map[string]any{"termsOfServiceAgreed": true}
+	if email != "" {
+		account["contact"] = []string{"mailto:" + email}
+	}
+	resp, err := c.post(ctx, c.directory.NewAccount, account, nil)
+	if err != nil {
+		return fmt.Errorf("registering ACME account: %w", err)
+	}
+	c.kid = resp.Header.Get("Location")
+	if c.kid == "" {
+		return fmt.Errorf("registering ACME account: no account URL in the response")
+	}
+	return nil
+}
+
+// post sends a JWS signed request with payload, or a POST-as-GET when
+// payload is nil, decoding the JSON response into out when it is set
+func (c *acmeClient) post(ctx context.Context, url string, payload, out any) (*http.Response, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, url, body)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 400 {
+			problem := &acmeProblem{}
+			if json.Unmarshal(data, problem) != nil || problem.Type == "" {
+				return nil, fmt.Errorf("%s: %s", url, resp.Status)
+			}
+			if problem.Type == errBadNonce && attempt < 2 {
+				continue
+			}
+			return nil, problem
+		}
+		if out != nil {
+			if raw, ok := out.(*[]byte); ok {
+				*raw = data
+			} else if err := json.Unmarshal(data, out); err != nil {
+				return nil, fmt.Errorf("%s: %w", url, err)
+			}
+		}
+		return resp, nil
+	}
+}
+
+// send signs body for url and posts it, keeping the nonce of the response
+// for the next request
+func (c *acmeClient) send(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	if c.nonce == "" {
+		if err := c.fetchNonce(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	protected := map[string]any{"alg": "ES256", "nonce": c.nonce, "url": url}
+	if c.kid != "" {
+		protected["kid"] = c.kid
+	} else {
+		protected["jwk"] = json.RawMessage(c.jwk())
+	}
+	header, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	signingInput := b64(header) + "." + b64(body)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	jws, err := json.Marshal(map[string]string{
+		"protected": b64(header),
+		"payload":   b64(body),
+		"signature": b64(signature),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jws))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/jose+json")
+	req.Header.Set("Accept", "application/pem-certificate-chain, application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		c.nonce = ""
+		return nil, err
+	}
+	c.nonce = resp.Header.Get("Replay-Nonce")
+	return resp, nil
+}
+
+func (c *acmeClient) fetchNonce(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.directory.NewNonce, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if c.nonce = resp.Header.Get("Replay-Nonce"); c.nonce == "" {
+		return fmt.Errorf("ACME newNonce %s: no nonce in the response", c.directory.NewNonce)
+	}
+	return nil
+}
+
+// newOrder asks for a certificate covering hostnames, returning the order's
+// URL
+func (c *acmeClient) newOrder(ctx context.Context, hostnames []string) (string, *acmeOrder, error) {
+	var identifiers []map[string]string
+	for _, host := range hostnames {
+		identifiers = append(identifiers, map[string]string{"type": "dns", "value": host})
+	}
+	order := &acmeOrder{}
+	resp, err := c.post(ctx, c.directory.NewOrder, map[string]any{"identifiers": identifiers}, order)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating ACME order: %w", err)
+	}
+	return resp.Header.Get("Location"), order, nil
+}
+
+// poll fetches url into out until done reports true, waiting as long as
+// the CA's Retry-After asks between attempts
+func (c *acmeClient) poll(ctx context.Context, url string, out any, done func() bool) error {
+	for {
+		resp, err := c.post(ctx, url, nil, out)
+		if err != nil {
+			return err
+		}
+		if done() {
+			return nil
+		}
+		wait := time.Second
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// errNoTLSALPN is returned for authorizations the CA can't validate with
+// tls-alpn-01, the only challenge binaryDeploy answers
+var errNoTLSALPN = errors.New("the CA offers no tls-alpn-01 challenge")
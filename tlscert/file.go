@@ -0,0 +1,92 @@
+// Package tlscert provides the certificates binaryDeploy serves HTTPS with:
+// a certificate and key read from files and reloaded when they change, or
+// one obtained and renewed automatically from an ACME CA such as Let's
+// Encrypt
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileCheckInterval is how often handshakes look for a replaced certificate
+const fileCheckInterval = 30 * time.Second
+
+// File serves the certificate in a pair of PEM files, picking up renewals
+// written by certbot or another tool without a restart
+type File struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	mtime     time.Time // Newest modification time of the two files when cert was loaded
+	checkedAt time.Time
+}
+
+// LoadFile reads the certificate chain in certFile and its key in keyFile
+func LoadFile(certFile, keyFile string) (*File, error) {
+	f := &File{certFile: certFile, keyFile: keyFile}
+	modTime, err := f.modTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.load(modTime); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetCertificate is a tls.Config.GetCertificate that reloads the files when
+// they were modified since they were last read. A pair that fails to load,
+// e.g. because only one of the files was replaced yet, keeps the previous
+// certificate in use.
+func (f *File) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.checkedAt) >= fileCheckInterval {
+		f.checkedAt = time.Now()
+		if modTime, err := f.modTime(); err != nil {
+			slog.Warn("Failed to check TLS certificate files", "error", err)
+		} else if modTime.After(f.mtime) {
+			if err := f.load(modTime); err != nil {
+				slog.Warn("Failed to reload TLS certificate, keeping the previous one", "cert", f.certFile, "error", err)
+			} else {
+				slog.Info("Reloaded TLS certificate", "cert", f.certFile, "expires", f.cert.Leaf.NotAfter)
+			}
+		}
+	}
+	return f.cert, nil
+}
+
+// modTime is the newest modification time of the certificate and key files
+func (f *File) modTime() (time.Time, error) {
+	var newest time.Time
+	for _, path := range []string{f.certFile, f.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+func (f *File) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", f.certFile, err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("loading %s: %w", f.certFile, err)
+	}
+	f.cert, f.mtime, f.checkedAt = &cert, modTime, time.Now()
+	return nil
+}
@@ -0,0 +1,81 @@
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a certificate for name and its key to dir
+func writeSelfSigned(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+func TestFileReloadsReplacedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, "old.example.com")
+
+	f, err := LoadFile(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := f.GetCertificate(&tls.ClientHelloInfo{})
+	if cert.Leaf.Subject.CommonName != "old.example.com" {
+		t.Fatalf("serving %s", cert.Leaf.Subject.CommonName)
+	}
+
+	writeSelfSigned(t, dir, "new.example.com")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	f.checkedAt = time.Time{}
+	cert, _ = f.GetCertificate(&tls.ClientHelloInfo{})
+	if cert.Leaf.Subject.CommonName != "new.example.com" {
+		t.Errorf("serving %s after the files were replaced", cert.Leaf.Subject.CommonName)
+	}
+
+	// A key that doesn't match keeps the previous certificate
+	os.WriteFile(keyFile, []byte("garbage"), 0600)
+	later = later.Add(time.Minute)
+	os.Chtimes(keyFile, later, later)
+	f.checkedAt = time.Time{}
+	cert, _ = f.GetCertificate(&tls.ClientHelloInfo{})
+	if cert == nil || cert.Leaf.Subject.CommonName != "new.example.com" {
+		t.Error("a broken key replaced the certificate")
+	}
+}
+
+func TestLoadFileMissing(t *testing.T) {
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "cert.pem"), "key.pem"); err == nil {
+		t.Error("expected an error for missing files")
+	}
+}
@@ -0,0 +1,397 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// acmeALPNProto is the protocol ACME CAs negotiate to validate
+	// tls-alpn-01 challenges (RFC 8737)
+	acmeALPNProto = "acme-tls/1"
+
+	// renewCheckInterval is how often the certificate's expiry is checked
+	renewCheckInterval = 12 * time.Hour
+	retryMinBackoff    = time.Minute
+	retryMaxBackoff    = 6 * time.Hour
+
+	// issueTimeout bounds one attempt at obtaining a certificate
+	issueTimeout = 5 * time.Minute
+
+	accountKeyFile  = "account.key"
+	certificateFile = "certificate.pem" // Private key followed by the chain
+)
+
+// idPeACMEIdentifier is the extension that carries the key authorization
+// digest in a tls-alpn-01 challenge certificate
+var idPeACMEIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// Config configures automatic certificates
+type Config struct {
+	Hostnames    []string // Names the certificate covers
+	Email        string   // Optional contact for expiry notices
+	DirectoryURL string   // ACME directory, e.g. Let's Encrypt's
+	CacheDir     string   // Holds the account key and the certificate across restarts
+}
+
+// Manager obtains one certificate covering every hostname from an ACME CA,
+// proving control of them with tls-alpn-01 challenges answered on the
+// listeners it serves, and renews it before it expires
+type Manager struct {
+	cfg    Config
+	client *acmeClient
+	now    func() time.Time
+
+	mu         sync.Mutex
+	cert       *tls.Certificate
+	challenges map[string]*tls.Certificate // tls-alpn-01 certificates by hostname
+}
+
+// NewManager loads or creates the ACME account key and loads the cached
+// certificate, if one was obtained before for the same hostnames
+func NewManager(cfg Config) (*Manager, error) {
+	if len(cfg.Hostnames) == 0 {
+		return nil, fmt.Errorf("no hostnames to obtain a certificate for")
+	}
+	cfg.Hostnames = slices.Clone(cfg.Hostnames)
+	sort.Strings(cfg.Hostnames)
+	if err := os.MkdirAll(cfg.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+
+	key, err := loadAccountKey(filepath.Join(cfg.CacheDir, accountKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		cfg:        cfg,
+		client:     &acmeClient{directoryURL: cfg.DirectoryURL, http: &http.Client{Timeout: time.Minute}, key: key},
+		now:        time.Now,
+		challenges: map[string]*tls.Certificate{},
+	}
+
+	if cert, err := loadCachedCertificate(filepath.Join(cfg.CacheDir, certificateFile)); err == nil {
+		if coversHostnames(cert.Leaf, cfg.Hostnames) {
+			m.cert = cert
+		} else {
+			slog.Info("Cached certificate doesn't cover acme_hostnames, a new one will be obtained", "names", cert.Leaf.DNSNames)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Ignoring unreadable cached certificate", "error", err)
+	}
+	return m, nil
+}
+
+// TLSConfig returns the server configuration that serves the certificate
+// and answers tls-alpn-01 challenges
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: m.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1", acmeALPNProto},
+		MinVersion:     tls.VersionTLS12,
+	}
+}
+
+// GetCertificate is a tls.Config.GetCertificate serving the challenge
+// certificate to a CA validating a hostname and the obtained certificate to
+// everyone else
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.Contains(hello.SupportedProtos, acmeALPNProto) {
+		cert, ok := m.challenges[hello.ServerName]
+		if !ok {
+			return nil, fmt.Errorf("no ACME challenge pending for %q", hello.ServerName)
+		}
+		return cert, nil
+	}
+	if m.cert == nil {
+		return nil, fmt.Errorf("no certificate for %v obtained yet", m.cfg.Hostnames)
+	}
+	return m.cert, nil
+}
+
+// Run obtains the certificate if there is none and renews it once two
+// thirds of its lifetime have passed, until ctx is done. Failed attempts
+// are retried with exponential backoff.
+func (m *Manager) Run(ctx context.Context) {
+	backoff := retryMinBackoff
+	for {
+		wait := renewCheckInterval
+		if m.needsRenewal() {
+			if err := m.obtain(ctx); err != nil {
+				slog.Error("Failed to obtain TLS certificate", "hostnames", m.cfg.Hostnames, "retry_in", backoff, "error", err)
+				wait = backoff
+				backoff = min(backoff*2, retryMaxBackoff)
+			} else {
+				backoff = retryMinBackoff
+			}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// needsRenewal reports whether there is no certificate or it is in the last
+// third of its lifetime
+func (m *Manager) needsRenewal() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cert == nil {
+		return true
+	}
+	leaf := m.cert.Leaf
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	return m.now().After(leaf.NotAfter.Add(-lifetime / 3))
+}
+
+// obtain orders a certificate for the hostnames, answers its challenges and
+// caches the result
+func (m *Manager) obtain(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, issueTimeout)
+	defer cancel()
+
+	c := m.client
+	if err := c.discover(ctx); err != nil {
+		return err
+	}
+	if err := c.register(ctx, m.cfg.Email); err != nil {
+		return err
+	}
+	orderURL, order, err := c.newOrder(ctx, m.cfg.Hostnames)
+	if err != nil {
+		return err
+	}
+	slog.Info("Requesting TLS certificate", "hostnames", m.cfg.Hostnames, "ca", m.cfg.DirectoryURL)
+
+	for _, authzURL := range order.Authorizations {
+		if err := m.authorize(ctx, authzURL); err != nil {
+			return err
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: m.cfg.Hostnames[0]},
+		DNSNames: m.cfg.Hostnames,
+	}, key)
+	if err != nil {
+		return err
+	}
+	if _, err := c.post(ctx, order.Finalize, map[string]string{"csr": b64(csr)}, order); err != nil {
+		return fmt.Errorf("finalizing ACME order: %w", err)
+	}
+	err = c.poll(ctx, orderURL, order, func() bool {
+		return order.Status != "pending" && order.Status != "ready" && order.Status != "processing"
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for the certificate: %w", err)
+	}
+	if order.Status != "valid" {
+		if order.Error != nil {
+			return fmt.Errorf("ACME order is %s: %w", order.Status, order.Error)
+		}
+		return fmt.Errorf("ACME order is %s", order.Status)
+	}
+
+	var chain []byte
+	if _, err := c.post(ctx, order.Certificate, nil, &chain); err != nil {
+		return fmt.Errorf("downloading the certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	bundle := append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), chain...)
+	cert, err := parseBundle(bundle)
+	if err != nil {
+		return fmt.Errorf("the CA returned an unusable certificate: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(m.cfg.CacheDir, certificateFile), bundle); err != nil {
+		slog.Warn("Failed to cache TLS certificate, it will be requested again after a restart", "error", err)
+	}
+
+	m.mu.Lock()
+	m.cert = cert
+	m.mu.Unlock()
+	slog.Info("Obtained TLS certificate", "hostnames", m.cfg.Hostnames, "expires", cert.Leaf.NotAfter)
+	return nil
+}
+
+// authorize answers the tls-alpn-01 challenge of an authorization and waits
+// for the CA to validate it
+func (m *Manager) authorize(ctx context.Context, authzURL string) error {
+	c := m.client
+	authz := &acmeAuthorization{}
+	if _, err := c.post(ctx, authzURL, nil, authz); err != nil {
+		return fmt.Errorf("fetching ACME authorization: %w", err)
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+	host := authz.Identifier.Value
+
+	var challenge *acmeChallenge
+	for i := range authz.Challenges {
+		if authz.Challenges[i].Type == "tls-alpn-01" {
+			challenge = &authz.Challenges[i]
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("%s: %w", host, errNoTLSALPN)
+	}
+
+	cert, err := challengeCertificate(host, c.keyAuthorization(challenge.Token))
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.challenges[host] = cert
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.challenges, host)
+		m.mu.Unlock()
+	}()
+
+	if _, err := c.post(ctx, challenge.URL, struct{}{}, nil); err != nil {
+		return fmt.Errorf("answering ACME challenge for %s: %w", host, err)
+	}
+	err = c.poll(ctx, authzURL, authz, func() bool { return authz.Status != "pending" })
+	if err != nil {
+		return fmt.Errorf("waiting for ACME validation of %s: %w", host, err)
+	}
+	if authz.Status != "valid" {
+		for _, ch := range authz.Challenges {
+			if ch.Error != nil {
+				return fmt.Errorf("%s failed validation: %w", host, ch.Error)
+			}
+		}
+		return fmt.Errorf("%s failed validation: authorization is %s", host, authz.Status)
+	}
+	slog.Info("Validated hostname", "host", host)
+	return nil
+}
+
+// challengeCertificate is the self-signed certificate that proves control
+// of host to a CA validating keyAuth with tls-alpn-01
+func challengeCertificate(host, keyAuth string) (*tls.Certificate, error) {
+	digest := sha256.Sum256([]byte(keyAuth))
+	value, err := asn1.Marshal(digest[:])
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "ACME challenge"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(24 * time.Hour),
+		DNSNames:        []string{host},
+		ExtraExtensions: []pkix.Extension{{Id: idPeACMEIdentifier, Critical: true, Value: value}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// coversHostnames reports whether leaf is valid for exactly hostnames
+func coversHostnames(leaf *x509.Certificate, hostnames []string) bool {
+	names := slices.Clone(leaf.DNSNames)
+	sort.Strings(names)
+	return slices.Equal(names, hostnames)
+}
+
+// loadAccountKey reads the ACME account key at path, generating and saving
+// one if there is none
+func loadAccountKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM key", path)
+		}
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func loadCachedCertificate(path string) (*tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseBundle(data)
+}
+
+// parseBundle reads a PEM private key followed by its certificate chain
+func parseBundle(data []byte) (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(data, data)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// writeFileAtomic replaces path with data, readable only by its owner
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,301 @@
+package tlscert
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCA is an ACME server that validates tls-alpn-01 challenges by
+// connecting to validateAddr, the listener the manager serves
+type fakeCA struct {
+	t            *testing.T
+	server       *httptest.Server
+	validateAddr string
+
+	mu       sync.Mutex
+	jwk      string
+	token    string
+	statuses map[string]string // Authorization status by hostname
+	order    acmeOrder
+	chain    []byte
+	caKey    *ecdsa.PrivateKey
+	caCert   *x509.Certificate
+}
+
+func newFakeCA(t *testing.T, hostnames []string) *fakeCA {
+	ca := &fakeCA{t: t, token: "tok3n", statuses: map[string]string{}}
+	ca.caKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Fake CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &ca.caKey.PublicKey, ca.caKey)
+	ca.caCert, _ = x509.ParseCertificate(der)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   ca.server.URL + "/nonce",
+			"newAccount": ca.server.URL + "/account",
+			"newOrder":   ca.server.URL + "/order",
+		})
+	})
+	mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "n0nce")
+	})
+	mux.HandleFunc("/account", func(w http.ResponseWriter, r *http.Request) {
+		header, _ := ca.decode(w, r)
+		var protected struct {
+			JWK json.RawMessage `json:"jwk"`
+		}
+		json.Unmarshal(header, &protected)
+		ca.mu.Lock()
+		ca.jwk = string(protected.JWK)
+		ca.mu.Unlock()
+		w.Header().Set("Location", ca.server.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"status":"valid"}`))
+	})
+	mux.HandleFunc("/order", func(w http.ResponseWriter, r *http.Request) {
+		ca.decode(w, r)
+		ca.mu.Lock()
+		defer ca.mu.Unlock()
+		ca.order = acmeOrder{Status: "pending", Finalize: ca.server.URL + "/finalize"}
+		for _, host := range hostnames {
+			ca.statuses[host] = "pending"
+			ca.order.Authorizations = append(ca.order.Authorizations, ca.server.URL+"/authz/"+host)
+		}
+		w.Header().Set("Location", ca.server.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ca.order)
+	})
+	mux.HandleFunc("/order/1", func(w http.ResponseWriter, r *http.Request) {
+		ca.decode(w, r)
+		ca.mu.Lock()
+		defer ca.mu.Unlock()
+		json.NewEncoder(w).Encode(ca.order)
+	})
+	mux.HandleFunc("/authz/", func(w http.ResponseWriter, r *http.Request) {
+		ca.decode(w, r)
+		host := strings.TrimPrefix(r.URL.Path, "/authz/")
+		ca.mu.Lock()
+		defer ca.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":     ca.statuses[host],
+			"identifier": map[string]string{"type": "dns", "value": host},
+			"challenges": []map[string]string{
+				{"type": "http-01", "url": ca.server.URL + "/unused", "token": ca.token},
+				{"type": "tls-alpn-01", "url": ca.server.URL + "/challenge/" + host, "token": ca.token},
+			},
+		})
+	})
+	mux.HandleFunc("/challenge/", func(w http.ResponseWriter, r *http.Request) {
+		ca.decode(w, r)
+		host := strings.TrimPrefix(r.URL.Path, "/challenge/")
+		status := "valid"
+		if err := ca.validate(host); err != nil {
+			t.Errorf("validating %s: %v", host, err)
+			status = "invalid"
+		}
+		ca.mu.Lock()
+		ca.statuses[host] = status
+		ca.mu.Unlock()
+		w.Write([]byte(`{"status":"processing"}`))
+	})
+	mux.HandleFunc("/finalize", func(w http.ResponseWriter, r *http.Request) {
+		_, payload := ca.decode(w, r)
+		var finalize struct {
+			CSR string `json:"csr"`
+		}
+		json.Unmarshal(payload, &finalize)
+		der, _ := base64.RawURLEncoding.DecodeString(finalize.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			t.Errorf("parsing CSR: %v", err)
+			return
+		}
+		ca.mu.Lock()
+		defer ca.mu.Unlock()
+		ca.issue(csr)
+		ca.order.Status = "processing"
+		json.NewEncoder(w).Encode(ca.order)
+		ca.order.Status, ca.order.Certificate = "valid", ca.server.URL+"/certificate"
+	})
+	mux.HandleFunc("/certificate", func(w http.ResponseWriter, r *http.Request) {
+		ca.decode(w, r)
+		ca.mu.Lock()
+		defer ca.mu.Unlock()
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(ca.chain)
+	})
+	ca.server = httptest.NewServer(mux)
+	t.Cleanup(ca.server.Close)
+	return ca
+}
+
+// decode checks a JWS request and returns its protected header and payload
+func (ca *fakeCA) decode(w http.ResponseWriter, r *http.Request) (header, payload []byte) {
+	w.Header().Set("Replay-Nonce", "n0nce")
+	var jws struct{ Protected, Payload, Signature string }
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &jws); err != nil || jws.Signature == "" {
+		ca.t.Errorf("%s: invalid JWS %q", r.URL.Path, body)
+	}
+	if r.Header.Get("Content-Type") != "application/jose+json" {
+		ca.t.Errorf("%s: Content-Type %q", r.URL.Path, r.Header.Get("Content-Type"))
+	}
+	header, _ = base64.RawURLEncoding.DecodeString(jws.Protected)
+	payload, _ = base64.RawURLEncoding.DecodeString(jws.Payload)
+	return header, payload
+}
+
+// validate performs the tls-alpn-01 check of RFC 8737
+func (ca *fakeCA) validate(host string) error {
+	conn, err := tls.Dial("tcp", ca.validateAddr, &tls.Config{
+		ServerName:         host,
+		NextProtos:         []string{acmeALPNProto},
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+	if state.NegotiatedProtocol != acmeALPNProto {
+		return fmt.Errorf("negotiated %q", state.NegotiatedProtocol)
+	}
+
+	ca.mu.Lock()
+	thumbprint := sha256.Sum256([]byte(ca.jwk))
+	keyAuth := ca.token + "." + base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	ca.mu.Unlock()
+	digest := sha256.Sum256([]byte(keyAuth))
+
+	leaf := state.PeerCertificates[0]
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(idPeACMEIdentifier) {
+			if !ext.Critical {
+				return fmt.Errorf("acmeIdentifier extension is not critical")
+			}
+			var value []byte
+			if _, err := asn1.Unmarshal(ext.Value, &value); err != nil || !bytes.Equal(value, digest[:]) {
+				return fmt.Errorf("acmeIdentifier doesn't match the key authorization")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("no acmeIdentifier extension")
+}
+
+func (ca *fakeCA) issue(csr *x509.CertificateRequest) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, ca.caCert, csr.PublicKey, ca.caKey)
+	ca.chain = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.caCert.Raw})...)
+}
+
+func TestManagerObtainsCertificate(t *testing.T) {
+	hostnames := []string{"www.example.com", "example.com"}
+	ca := newFakeCA(t, hostnames)
+	cacheDir := t.TempDir()
+
+	m, err := NewManager(Config{Hostnames: hostnames, DirectoryURL: ca.server.URL + "/directory", CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"}); err == nil {
+		t.Fatal("expected an error before a certificate is obtained")
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", m.TLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	ca.validateAddr = listener.Addr().String()
+
+	if err := m.obtain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coversHostnames(cert.Leaf, []string{"example.com", "www.example.com"}) {
+		t.Errorf("certificate covers %v", cert.Leaf.DNSNames)
+	}
+	if m.needsRenewal() {
+		t.Error("fresh certificate needs renewal")
+	}
+	if len(m.challenges) != 0 {
+		t.Errorf("challenges left behind: %v", m.challenges)
+	}
+
+	// A restart reuses the account key and the cached certificate
+	again, err := NewManager(Config{Hostnames: hostnames, DirectoryURL: ca.server.URL + "/directory", CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.needsRenewal() {
+		t.Error("cached certificate was not loaded")
+	}
+	if !again.client.key.Equal(m.client.key) {
+		t.Error("account key was not reused")
+	}
+
+	// The certificate is renewed in the last third of its lifetime
+	again.now = func() time.Time { return time.Now().Add(70 * 24 * time.Hour) }
+	if !again.needsRenewal() {
+		t.Error("expected a certificate with 20 days left to need renewal")
+	}
+
+	// Different hostnames need a new certificate
+	other, err := NewManager(Config{Hostnames: []string{"example.org"}, DirectoryURL: ca.server.URL + "/directory", CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !other.needsRenewal() {
+		t.Error("cached certificate for other hostnames was used")
+	}
+}
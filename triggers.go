@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"binaryDeploy/history"
+)
+
+// triggerTargetDeployment marks the target update as running and deploys req
+// in the background. req.Trigger names the trigger in status messages and
+// logs, e.g. "Webhook deployment".
+func triggerTargetDeployment(req DeployRequest, startMessage string) {
+	label := req.Trigger
+
+	updateStatus.Lock()
+	updateStatus.target = UpdateStatus{
+		IsRunning: true,
+		StartTime: time.Now(),
+		Message:   startMessage,
+	}
+	updateStatus.Unlock()
+
+	go func() {
+		if err := runDeployment(req); err != nil {
+			slog.Error(label+" failed", "error", err)
+			updateStatus.Lock()
+			updateStatus.target.IsRunning = false
+			updateStatus.target.Error = err.Error()
+			updateStatus.target.Message = label + " failed"
+			if errors.Is(err, errRolledBack) {
+				updateStatus.target.RolledBack = true
+				updateStatus.target.Message = label + " failed, rolled back to the previous build"
+			}
+			updateStatus.target.CompletedAt = time.Now()
+			updateStatus.Unlock()
+		} else {
+			slog.Info(label + " completed successfully")
+			updateStatus.Lock()
+			updateStatus.target.IsRunning = false
+			updateStatus.target.Message = label + " completed successfully"
+			updateStatus.target.CompletedAt = time.Now()
+			updateStatus.Unlock()
+		}
+	}()
+}
+
+// selfUpdateRef decides whether a push to self_update_repo_url of ref
+// (refs/heads/<branch>, refs/tags/<tag> or a bare branch name) triggers a
+// self-update, returning the ref to update to. Tenant instances never
+// self-update as they share one binary.
+func selfUpdateRef(ref string) (string, bool) {
+	if tenantName != "" {
+		return "", false
+	}
+	if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+		if tagMatches(appConfig().SelfUpdateTags, tag) {
+			return ref, true
+		}
+		return "", false
+	}
+	branch := extractBranchFromRef(ref)
+	if appConfig().SelfUpdateBranch == "" {
+		// Pushes to an allowed branch update to the default branch
+		return "", isAllowedBranch(branch)
+	}
+	return branch, branch == appConfig().SelfUpdateBranch
+}
+
+// selfUpdateBranch is self_update_branch, or HEAD for the default branch of
+// self_update_repo_url when none is configured
+func selfUpdateBranch() string {
+	if appConfig().SelfUpdateBranch == "" {
+		return "HEAD"
+	}
+	return appConfig().SelfUpdateBranch
+}
+
+// triggerSelfUpdate marks the self-update as running and performs it in the
+// background. ref is the branch or refs/tags/<tag> to update to; empty
+// means self_update_branch.
+func triggerSelfUpdate(label, startMessage, ref string) {
+	if ref == "" {
+		ref = selfUpdateBranch()
+	}
+
+	updateStatus.Lock()
+	updateStatus.self = UpdateStatus{
+		IsRunning: true,
+		StartTime: time.Now(),
+		Message:   startMessage,
+	}
+	updateStatus.Unlock()
+	notifySelfUpdateStarted(label, ref)
+
+	go func() {
+		start := time.Now()
+		commit, message, err := deploySelfUpdate(ref)
+		recordSelfUpdate(label, ref, commit, message, start, err)
+		if err != nil {
+			slog.Error(label+" failed", "error", err)
+			updateStatus.Lock()
+			updateStatus.self.IsRunning = false
+			updateStatus.self.Error = err.Error()
+			updateStatus.self.Message = label + " failed"
+			updateStatus.self.CompletedAt = time.Now()
+			updateStatus.Unlock()
+		} else {
+			slog.Info(label + " completed successfully")
+			updateStatus.Lock()
+			updateStatus.self.Message = label + " completed successfully"
+			updateStatus.self.CompletedAt = time.Now()
+			// Stay read-only until the new binary takes over
+			if requestSelfRestart(label) {
+				updateStatus.self.Message = label + " completed, restarting into the new binary"
+			} else {
+				updateStatus.self.IsRunning = false
+			}
+			updateStatus.Unlock()
+		}
+	}()
+}
+
+// recordSelfUpdate adds a self-update of commit, with subject line message,
+// to the deployment history so it shows up next to the deployments it may
+// have affected, and announces its outcome
+func recordSelfUpdate(label, ref, commit, message string, start time.Time, err error) {
+	rec := history.Record{
+		App:         "binaryDeploy",
+		Kind:        history.KindSelfUpdate,
+		Trigger:     label,
+		TriggeredBy: "binaryDeploy",
+		Repo:        appConfig().SelfUpdateRepoURL,
+		Commit:      commit,
+		Result:      history.ResultSuccess,
+		StartedAt:   start,
+		FinishedAt:  time.Now(),
+	}
+	if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+		rec.Version = tag
+	} else {
+		rec.Branch = ref
+	}
+	if err != nil {
+		rec.Result = history.ResultFailure
+		rec.Error = err.Error()
+	}
+	recordDeployment(rec)
+	notifySelfUpdateFinished(rec, message)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func TestSelfUpdateRef(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		AllowedBranches:  "main,develop",
+		SelfUpdateBranch: "stable",
+		SelfUpdateTags:   []string{"v*", "nightly"},
+	})
+
+	tests := []struct {
+		ref  string
+		want string
+		ok   bool
+	}{
+		{"refs/heads/stable", "stable", true},
+		{"stable", "stable", true},
+		{"refs/heads/main", "", false}, // allowed_branches only applies to the target
+		{"refs/tags/v1.4.0", "refs/tags/v1.4.0", true},
+		{"refs/tags/nightly", "refs/tags/nightly", true},
+		{"refs/tags/nightly-2", "", false},
+		{"refs/tags/release-1", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := selfUpdateRef(tt.ref)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("selfUpdateRef(%q) = %q, %v; want %q, %v", tt.ref, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	currentConfig.Store(&config.DeployConfig{AllowedBranches: "main"})
+	if _, ok := selfUpdateRef("refs/tags/v1.4.0"); ok {
+		t.Error("Expected tags not to trigger self-updates without self_update_tags")
+	}
+}
+
+func TestWebhookHandler_IgnoresOtherSelfUpdateRefs(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{
+		TargetRepoURL:     "https://git.example.com/team/app.git",
+		AllowedBranches:   "main",
+		SelfUpdateRepoURL: "https://git.example.com/team/binaryDeploy.git",
+		SelfUpdateBranch:  "stable",
+		SelfUpdateTags:    []string{"v*"},
+		WebhookTimeout:    10,
+	})
+
+	for _, ref := range []string{"refs/heads/main", "refs/tags/release-1"} {
+		payload := `{"ref": "` + ref + `", "repository": {"name": "binaryDeploy", "clone_url": "https://git.example.com/team/binaryDeploy.git"}, "head_commit": {"id": "0123456789abcdef"}}`
+		rec := httptest.NewRecorder()
+		webhookHandler(rec, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload)))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "not configured for self-updates") {
+			t.Errorf("%s: expected the push to be acknowledged and ignored, got %d %s", ref, rec.Code, rec.Body)
+		}
+	}
+
+	updateStatus.Lock()
+	running := updateStatus.self.IsRunning
+	updateStatus.Unlock()
+	if running {
+		t.Error("Expected no self-update to start")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// systemCABundles are the usual locations of the system CA bundle, in the
+// same order crypto/x509 checks them on Linux
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// gitCABundle is the combined system + custom CA bundle handed to git, or
+// empty when no custom CAs are configured
+var gitCABundle string
+
+// setupTrustedCAs adds the configured ca_certs to the roots trusted by
+// outbound HTTPS calls and prepares a combined bundle for git
+func setupTrustedCAs() error {
+	if len(appConfig().CACerts) == 0 {
+		return nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	var bundle []byte
+	for _, path := range systemCABundles {
+		if data, err := os.ReadFile(path); err == nil {
+			bundle = append(bundle, data...)
+			bundle = append(bundle, '\n')
+			break
+		}
+	}
+
+	for _, caFile := range appConfig().CACerts {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("reading CA certificate %s: %w", caFile, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", caFile)
+		}
+		bundle = append(bundle, pem...)
+		bundle = append(bundle, '\n')
+	}
+
+	// Every client built on the default transport now trusts the custom CAs
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	if err := os.MkdirAll(appConfig().DeployDir, 0755); err != nil {
+		return fmt.Errorf("creating deploy directory: %w", err)
+	}
+	bundlePath, err := filepath.Abs(filepath.Join(appConfig().DeployDir, ".ca-bundle.pem"))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(bundlePath, bundle, 0644); err != nil {
+		return fmt.Errorf("writing git CA bundle: %w", err)
+	}
+	gitCABundle = bundlePath
+
+	slog.Info("Custom CA certificates loaded", "files", appConfig().CACerts, "git_bundle", bundlePath)
+	return nil
+}
+
+// gitConfigArgs returns the "-c key=value" options git needs to talk to
+// repoURL with the configured TLS settings and credentials
+func gitConfigArgs(repoURL string) []string {
+	var args []string
+	if gitCABundle != "" {
+		args = append(args, "-c", "http.sslCAInfo="+gitCABundle)
+	}
+	for _, insecure := range appConfig().InsecureSkipVerifyRepos {
+		if insecure == repoURL {
+			slog.Warn("TLS verification disabled for repository", "repo_url", repoURL)
+			args = append(args, "-c", "http.sslVerify=false")
+			break
+		}
+	}
+	return append(args, gitCredentialArgs()...)
+}
+
+// gitCommand prefixes a git subcommand with the TLS and credential options
+// for repoURL
+func gitCommand(repoURL string, args ...string) []string {
+	return append(gitConfigArgs(repoURL), args...)
+}
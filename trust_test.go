@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+func TestSetupTrustedCAs_TrustsCustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	transport := http.DefaultTransport.(*http.Transport)
+	previous := transport.TLSClientConfig
+	t.Cleanup(func() {
+		transport.TLSClientConfig = previous
+		gitCABundle = ""
+	})
+
+	if _, err := http.Get(server.URL); err == nil {
+		t.Fatal("Expected the test server's certificate to be untrusted before ca_certs is loaded")
+	}
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "internal-ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	currentConfig.Store(&config.DeployConfig{DeployDir: filepath.Join(dir, "deployments"), CACerts: []string{caFile}})
+	if err := setupTrustedCAs(); err != nil {
+		t.Fatalf("setupTrustedCAs: %v", err)
+	}
+	transport.CloseIdleConnections()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the custom CA to be trusted, got %v", err)
+	}
+	resp.Body.Close()
+
+	bundle, err := os.ReadFile(gitCABundle)
+	if err != nil {
+		t.Fatalf("Expected a CA bundle for git: %v", err)
+	}
+	if !strings.Contains(string(bundle), string(caPEM)) {
+		t.Error("Expected the git CA bundle to contain the custom CA")
+	}
+	if args := gitConfigArgs("https://git.internal/team/app.git"); !slices.Contains(args, "http.sslCAInfo="+gitCABundle) {
+		t.Errorf("Expected git to be pointed at the CA bundle, got %v", args)
+	}
+}
+
+func TestSetupTrustedCAs_RejectsFilesWithoutCertificates(t *testing.T) {
+	transport := http.DefaultTransport.(*http.Transport)
+	previous := transport.TLSClientConfig
+	t.Cleanup(func() { transport.TLSClientConfig = previous })
+
+	dir := t.TempDir()
+	notACert := filepath.Join(dir, "empty.pem")
+	os.WriteFile(notACert, []byte("not a certificate\n"), 0644)
+
+	for _, caFile := range []string{notACert, filepath.Join(dir, "missing.pem")} {
+		currentConfig.Store(&config.DeployConfig{DeployDir: dir, CACerts: []string{caFile}})
+		if err := setupTrustedCAs(); err == nil {
+			t.Errorf("%s: expected an error", filepath.Base(caFile))
+		}
+	}
+	if transport.TLSClientConfig != previous {
+		t.Error("Expected a failed setup to leave outbound TLS untouched")
+	}
+}
+
+func TestGitConfigArgs_SkipsVerificationOnlyForListedRepos(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{InsecureSkipVerifyRepos: []string{"https://git.internal/team/legacy.git"}})
+
+	if args := gitConfigArgs("https://git.internal/team/legacy.git"); !slices.Contains(args, "http.sslVerify=false") {
+		t.Errorf("Expected TLS verification disabled for the listed repository, got %v", args)
+	}
+	for _, repoURL := range []string{"https://git.internal/team/app.git", "https://git.internal/team/legacy.git.evil"} {
+		if args := gitConfigArgs(repoURL); slices.Contains(args, "http.sslVerify=false") {
+			t.Errorf("%s: expected TLS verification, got %v", repoURL, args)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+
+	"binaryDeploy/artifacts"
+	"binaryDeploy/templating"
+)
+
+// runningBuild is the digest of the build the application was last
+// started from, empty when unknown (e.g. after binaryDeploy restarted or a
+// deployment failed)
+var runningBuild struct {
+	sync.Mutex
+	digest string
+}
+
+// buildDigest hashes the build in the working directory: artifact_paths
+// plus the rendered config_templates, whose changes also need a restart.
+// It returns "" when the build can't be hashed.
+func buildDigest() string {
+	paths := append([]string{}, appConfig().ArtifactPaths...)
+	files, _ := templating.ParseFiles(appConfig().ConfigTemplates)
+	for _, f := range files {
+		paths = append(paths, f.Dest)
+	}
+
+	digest, err := artifacts.Digest(targetWorkingDir(), paths)
+	if err != nil {
+		slog.Warn("Failed to hash build output, deploying as usual", "paths", paths, "error", err)
+		return ""
+	}
+	return digest
+}
+
+// buildUnchanged reports whether digest is the build the application is
+// running. A local application that isn't running always needs a start.
+func buildUnchanged(digest string) bool {
+	runningBuild.Lock()
+	same := digest != "" && digest == runningBuild.digest
+	runningBuild.Unlock()
+
+	return same && (appConfig().DeployDriver != "local" || processManager.IsRunning())
+}
+
+// setRunningBuild remembers the digest of the build just started; "" when
+// it's no longer known what runs
+func setRunningBuild(digest string) {
+	runningBuild.Lock()
+	runningBuild.digest = digest
+	runningBuild.Unlock()
+}
@@ -0,0 +1,125 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Preview is what a self-update to a ref would install
+type Preview struct {
+	Ref            string   `json:"ref"`
+	Commit         string   `json:"commit"`
+	Version        string   `json:"version"`
+	CurrentCommit  string   `json:"current_commit,omitempty"`
+	CurrentVersion string   `json:"current_version"`
+	Commits        []Commit `json:"commits"`
+
+	// CommitsComplete is false when the current commit isn't an ancestor
+	// of Commit (or unknown), in which case Commits holds the latest
+	// commits of ref rather than those since the current version
+	CommitsComplete bool `json:"commits_complete"`
+}
+
+// Commit is one entry of a preview's commit log
+type Commit struct {
+	SHA     string `json:"sha"`
+	Subject string `json:"subject"`
+}
+
+// previewCommitLimit caps the commit log of a preview
+const previewCommitLimit = 100
+
+// Preview fetches and builds ref of repoURL like Update does, but only
+// reports the candidate's version and the commits since currentCommit, the
+// revision the running binary was built from ("" if unknown). The running
+// binary and its backup are left alone; the work directory is separate
+// from Update's so a preview can run alongside an update.
+func (su *SelfUpdater) Preview(repoURL, ref, currentCommit string) (*Preview, error) {
+	slog.Info("Starting self-update preview", "repo_url", repoURL, "ref", ref)
+
+	workDir := filepath.Join(su.SelfUpdateDir, "preview")
+	defer func() {
+		if err := os.RemoveAll(workDir); err != nil {
+			slog.Warn("Failed to clean up preview directory", "error", err)
+		}
+	}()
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, fmt.Errorf("creating preview directory: %w", err)
+	}
+
+	repoDir := filepath.Join(workDir, "repo")
+	if err := su.cloneOrUpdateRepo(repoURL, repoDir, ref); err != nil {
+		return nil, fmt.Errorf("cloning/updating repo: %w", err)
+	}
+	deployConfig, err := su.readDeployConfig(filepath.Join(repoDir, "deploy.config"))
+	if err != nil {
+		return nil, fmt.Errorf("reading deploy config: %w", err)
+	}
+	newBinaryPath, err := su.buildNewBinary(repoDir, deployConfig)
+	if err != nil {
+		return nil, fmt.Errorf("building new binary: %w", err)
+	}
+	if err := su.verifyNewBinary(newBinaryPath); err != nil {
+		return nil, fmt.Errorf("verifying new binary: %w", err)
+	}
+
+	preview := &Preview{
+		Ref:            ref,
+		CurrentCommit:  currentCommit,
+		Version:        binaryVersion(newBinaryPath),
+		CurrentVersion: binaryVersion(su.CurrentBinaryPath),
+	}
+	if preview.Commit, err = gitOutput(repoDir, "rev-parse", "HEAD"); err != nil {
+		return nil, fmt.Errorf("reading the built commit: %w", err)
+	}
+
+	limit := fmt.Sprintf("--max-count=%d", previewCommitLimit)
+	revs := []string{limit, "HEAD"}
+	if currentCommit != "" {
+		if _, err := gitOutput(repoDir, "merge-base", "--is-ancestor", currentCommit, "HEAD"); err == nil {
+			revs = []string{limit, currentCommit + "..HEAD"}
+			preview.CommitsComplete = true
+		}
+	}
+	log, err := gitOutput(repoDir, append([]string{"log", "--format=%H%x00%s"}, revs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("listing commits: %w", err)
+	}
+	preview.Commits = []Commit{}
+	for _, line := range strings.Split(log, "\n") {
+		if sha, subject, ok := strings.Cut(line, "\x00"); ok {
+			preview.Commits = append(preview.Commits, Commit{SHA: sha, Subject: subject})
+		}
+	}
+	if len(preview.Commits) == previewCommitLimit {
+		preview.CommitsComplete = false
+	}
+
+	slog.Info("Self-update preview completed", "commit", preview.Commit, "version", preview.Version, "commits", len(preview.Commits))
+	return preview, nil
+}
+
+// binaryVersion is the first line a binary prints for --version, or "" when
+// it doesn't run
+func binaryVersion(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line
+}
+
+// gitOutput runs git in dir and returns its trimmed output
+func gitOutput(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,234 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RetainedBinary is a binaryDeploy build kept for pinned rollbacks
+type RetainedBinary struct {
+	Version     string    `json:"version"` // Tag, or the short commit of a branch build
+	Commit      string    `json:"commit,omitempty"`
+	Ref         string    `json:"ref,omitempty"`
+	SHA256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installed_at"`
+	Current     bool      `json:"current"`
+}
+
+// retainedIndex is the index of the binaries directory, oldest first
+type retainedIndex struct {
+	Binaries []RetainedBinary `json:"binaries"`
+	Current  string           `json:"current"` // SHA256 of the installed binary
+}
+
+// ErrUnknownVersion is returned for a rollback to a version that isn't
+// retained
+var ErrUnknownVersion = errors.New("version is not retained")
+
+func (su *SelfUpdater) binariesDir() string {
+	return filepath.Join(su.SelfUpdateDir, "binaries")
+}
+
+func (su *SelfUpdater) loadIndex() (*retainedIndex, error) {
+	index := &retainedIndex{}
+	data, err := os.ReadFile(filepath.Join(su.binariesDir(), "index.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("reading retained binaries: %w", err)
+	}
+	return index, nil
+}
+
+func (su *SelfUpdater) saveIndex(index *retainedIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(su.binariesDir(), "index.json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// RetainedBinaries lists the retained builds, newest first
+func (su *SelfUpdater) RetainedBinaries() ([]RetainedBinary, error) {
+	index, err := su.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+	binaries := make([]RetainedBinary, 0, len(index.Binaries))
+	for i := len(index.Binaries) - 1; i >= 0; i-- {
+		b := index.Binaries[i]
+		b.Current = b.SHA256 == index.Current
+		binaries = append(binaries, b)
+	}
+	return binaries, nil
+}
+
+// retainCurrent keeps the running binary as the newest retained build
+// before an update replaces it. It is copied in when no earlier update
+// retained it, so the first update can be rolled back too.
+func (su *SelfUpdater) retainCurrent() error {
+	if err := os.MkdirAll(su.binariesDir(), 0755); err != nil {
+		return err
+	}
+	index, err := su.loadIndex()
+	if err != nil {
+		return err
+	}
+	sum, err := fileSHA256(su.CurrentBinaryPath)
+	if err != nil {
+		return err
+	}
+	if i := index.find(sum); i >= 0 {
+		// After a rollback the build rolled back to is the one the new
+		// build replaces, so it moves up to be pruned last
+		binary := index.Binaries[i]
+		index.Binaries = append(append(index.Binaries[:i], index.Binaries[i+1:]...), binary)
+		index.Current = sum
+		return su.saveIndex(index)
+	}
+	info, err := os.Stat(su.CurrentBinaryPath)
+	if err != nil {
+		return err
+	}
+	version := shortCommit(su.CurrentCommit)
+	if version == "" {
+		version = "build-" + sum[:12]
+	}
+	if err := index.add(su, su.CurrentBinaryPath, RetainedBinary{Version: version, Commit: su.CurrentCommit, SHA256: sum, InstalledAt: info.ModTime()}); err != nil {
+		return err
+	}
+	index.Current = sum
+	return su.saveIndex(index)
+}
+
+// retainInstall records that the build at newBinaryPath, built from commit
+// of ref, was installed. Builds beyond Keep are removed, oldest first.
+func (su *SelfUpdater) retainInstall(newBinaryPath, ref, commit string) error {
+	index, err := su.loadIndex()
+	if err != nil {
+		return err
+	}
+	sum, err := fileSHA256(newBinaryPath)
+	if err != nil {
+		return err
+	}
+	version := shortCommit(commit)
+	if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+		version = tag
+	}
+	if err := index.add(su, newBinaryPath, RetainedBinary{Version: version, Commit: commit, Ref: ref, SHA256: sum, InstalledAt: time.Now()}); err != nil {
+		return err
+	}
+	index.Current = sum
+
+	keep := max(su.Keep, 1)
+	for len(index.Binaries) > keep {
+		old := index.Binaries[0]
+		index.Binaries = index.Binaries[1:]
+		if err := os.Remove(su.retainedPath(old.SHA256)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed to remove retained binary", "version", old.Version, "error", err)
+		}
+	}
+	return su.saveIndex(index)
+}
+
+// RollbackTo installs the retained build of version, or the one installed
+// before the current one when version is empty
+func (su *SelfUpdater) RollbackTo(version string) (RetainedBinary, error) {
+	index, err := su.loadIndex()
+	if err != nil {
+		return RetainedBinary{}, err
+	}
+
+	target := -1
+	if version == "" {
+		if current := index.find(index.Current); current > 0 {
+			target = current - 1
+		}
+	} else {
+		for i := len(index.Binaries) - 1; i >= 0; i-- {
+			if index.Binaries[i].Version == version {
+				target = i
+				break
+			}
+		}
+	}
+	if target < 0 {
+		if version == "" {
+			return RetainedBinary{}, fmt.Errorf("no build before the current one: %w", ErrUnknownVersion)
+		}
+		return RetainedBinary{}, fmt.Errorf("%s: %w", version, ErrUnknownVersion)
+	}
+
+	binary := index.Binaries[target]
+	slog.Info("Rolling back to retained binary", "version", binary.Version, "commit", binary.Commit)
+	if err := su.replaceBinaryAtomically(su.retainedPath(binary.SHA256)); err != nil {
+		return RetainedBinary{}, err
+	}
+	index.Current = binary.SHA256
+	if err := su.saveIndex(index); err != nil {
+		return RetainedBinary{}, err
+	}
+	binary.Current = true
+	return binary, nil
+}
+
+func (su *SelfUpdater) retainedPath(sum string) string {
+	return filepath.Join(su.binariesDir(), "binaryDeploy-"+sum[:16])
+}
+
+// find returns the position of the build with sum, or -1
+func (index *retainedIndex) find(sum string) int {
+	for i, b := range index.Binaries {
+		if b.SHA256 == sum {
+			return i
+		}
+	}
+	return -1
+}
+
+// add copies the binary at path into the store as the newest build,
+// replacing an earlier copy of the same build
+func (index *retainedIndex) add(su *SelfUpdater, path string, binary RetainedBinary) error {
+	if i := index.find(binary.SHA256); i >= 0 {
+		index.Binaries = append(index.Binaries[:i], index.Binaries[i+1:]...)
+	} else if err := su.copyFile(path, su.retainedPath(binary.SHA256)); err != nil {
+		return fmt.Errorf("retaining binary: %w", err)
+	}
+	index.Binaries = append(index.Binaries, binary)
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func shortCommit(commit string) string {
+	return commit[:min(12, len(commit))]
+}
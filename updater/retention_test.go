@@ -0,0 +1,87 @@
+package updater
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// install simulates an update to a build with content, as Update retains
+// and installs it
+func install(t *testing.T, su *SelfUpdater, content, ref, commit string) {
+	t.Helper()
+	if err := su.retainCurrent(); err != nil {
+		t.Fatalf("retainCurrent: %v", err)
+	}
+	built := filepath.Join(t.TempDir(), "binaryDeploy")
+	if err := os.WriteFile(built, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := su.replaceBinaryAtomically(built); err != nil {
+		t.Fatal(err)
+	}
+	if err := su.retainInstall(built, ref, commit); err != nil {
+		t.Fatalf("retainInstall: %v", err)
+	}
+	su.CurrentCommit = commit
+}
+
+func installed(t *testing.T, su *SelfUpdater) string {
+	t.Helper()
+	data, err := os.ReadFile(su.CurrentBinaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRetainedBinaries_RollBackToPinnedVersion(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "binaryDeploy")
+	if err := os.WriteFile(binary, []byte("original build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	su := NewSelfUpdater(binary, filepath.Join(dir, "self-update"))
+	su.Keep = 3
+	su.CurrentCommit = "0000000000000000"
+
+	install(t, su, "release 1", "refs/tags/v1.0.0", "1111111111111111")
+	install(t, su, "release 2", "refs/tags/v2.0.0", "2222222222222222")
+	install(t, su, "main build", "main", "3333333333333333")
+
+	binaries, err := su.RetainedBinaries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []string
+	for _, b := range binaries {
+		versions = append(versions, b.Version)
+	}
+	if len(binaries) != 3 || versions[0] != "333333333333" || versions[1] != "v2.0.0" || versions[2] != "v1.0.0" || !binaries[0].Current {
+		t.Fatalf("Expected the three newest builds newest first with the installed one current, got %+v", binaries)
+	}
+
+	// Without a version the build before the current one is installed
+	if b, err := su.RollbackTo(""); err != nil || b.Version != "v2.0.0" || installed(t, su) != "release 2" {
+		t.Fatalf("Expected a rollback to v2.0.0, got %+v, %v, %q", b, err, installed(t, su))
+	}
+	if b, err := su.RollbackTo("v1.0.0"); err != nil || b.Commit != "1111111111111111" || installed(t, su) != "release 1" {
+		t.Fatalf("Expected a rollback to the pinned v1.0.0, got %+v, %v, %q", b, err, installed(t, su))
+	}
+	if _, err := su.RollbackTo("v0.9.0"); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected ErrUnknownVersion for a build that isn't retained, got %v", err)
+	}
+	if _, err := su.RollbackTo(""); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected no build before the oldest one, got %v", err)
+	}
+
+	// The original build was pruned when the third update was retained
+	if _, err := su.RollbackTo("000000000000"); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("Expected the oldest build beyond keep to be pruned, got %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(su.binariesDir(), "binaryDeploy-*"))
+	if len(files) != 3 {
+		t.Errorf("Expected three retained binaries on disk, got %v", files)
+	}
+}
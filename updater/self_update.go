@@ -18,6 +18,22 @@ type SelfUpdater struct {
 	SelfUpdateDir     string
 	TempDir           string
 	BackupPath        string
+	GitConfigArgs     []string // "-c key=value" options passed to git (e.g. TLS settings)
+
+	// Ref is the branch or refs/tags/<name> Update builds; empty (or HEAD)
+	// builds the default branch of the repository
+	Ref string
+
+	// Keep is how many builds are retained for RollbackTo, including the
+	// installed one; CurrentCommit is the revision the running binary was
+	// built from, "" if unknown
+	Keep          int
+	CurrentCommit string
+
+	// Commit and CommitMessage (its subject line) describe the revision
+	// the last Update fetched, set once it has been checked out
+	Commit        string
+	CommitMessage string
 }
 
 // NewSelfUpdater creates a new SelfUpdater instance
@@ -32,7 +48,7 @@ func NewSelfUpdater(currentBinaryPath, selfUpdateDir string) *SelfUpdater {
 
 // Update performs the self-update process with automatic rollback on failure
 func (su *SelfUpdater) Update(repoURL, branch string) error {
-	slog.Info("Starting self-update", "repo_url", repoURL, "branch", branch)
+	slog.Info("Starting self-update", "repo_url", repoURL, "branch", branch, "ref", su.Ref)
 
 	// Create temporary directory for update
 	if err := os.MkdirAll(su.TempDir, 0755); err != nil {
@@ -42,10 +58,12 @@ func (su *SelfUpdater) Update(repoURL, branch string) error {
 	repoDir := filepath.Join(su.TempDir, "repo")
 
 	// Clone or update the repository
-	if err := su.cloneOrUpdateRepo(repoURL, repoDir); err != nil {
+	if err := su.cloneOrUpdateRepo(repoURL, repoDir, su.Ref); err != nil {
 		su.cleanup()
 		return fmt.Errorf("cloning/updating repo: %w", err)
 	}
+	su.Commit, _ = gitOutput(repoDir, "rev-parse", "HEAD")
+	su.CommitMessage, _ = gitOutput(repoDir, "log", "-1", "--format=%s")
 
 	// Read deploy config from the cloned repository
 	configPath := filepath.Join(repoDir, "deploy.config")
@@ -74,6 +92,11 @@ func (su *SelfUpdater) Update(repoURL, branch string) error {
 		return fmt.Errorf("verifying new binary: %w", err)
 	}
 
+	// Keep the running build for pinned rollbacks before it is replaced
+	if err := su.retainCurrent(); err != nil {
+		slog.Warn("Failed to retain the current binary", "error", err)
+	}
+
 	// Replace current binary atomically
 	if err := su.replaceBinaryAtomically(newBinaryPath); err != nil {
 		// Try to rollback on failure
@@ -98,6 +121,10 @@ func (su *SelfUpdater) Update(repoURL, branch string) error {
 		return fmt.Errorf("new binary test failed (rollback attempted): %w", err)
 	}
 
+	if err := su.retainInstall(newBinaryPath, su.Ref, su.Commit); err != nil {
+		slog.Warn("Failed to retain the new binary", "error", err)
+	}
+
 	// Clean up temporary files on success
 	su.cleanup()
 
@@ -123,25 +150,40 @@ func (su *SelfUpdater) testNewBinary() error {
 	return nil
 }
 
-// cloneOrUpdateRepo clones the repository or updates an existing one
-func (su *SelfUpdater) cloneOrUpdateRepo(repoURL, repoDir string) error {
+// cloneOrUpdateRepo clones the repository or updates an existing one, then
+// checks out ref, or the default branch when ref is empty or HEAD
+func (su *SelfUpdater) cloneOrUpdateRepo(repoURL, repoDir, ref string) error {
 	if _, err := os.Stat(repoDir); os.IsNotExist(err) {
 		slog.Info("Cloning repository", "path", repoDir)
-		if err := su.runCommand("git", "clone", repoURL, repoDir); err != nil {
+		if err := su.runCommand("git", su.gitArgs("clone", repoURL, repoDir)...); err != nil {
 			return err
 		}
 	} else {
 		slog.Info("Updating repository", "path", repoDir)
-		if err := su.runCommandInDir(repoDir, "git", "fetch", "origin"); err != nil {
-			return err
-		}
-		if err := su.runCommandInDir(repoDir, "git", "reset", "--hard", "origin/HEAD"); err != nil {
+		// --force picks up tags that were moved since the last update
+		if err := su.runCommandInDir(repoDir, "git", su.gitArgs("fetch", "--force", "--tags", "origin")...); err != nil {
 			return err
 		}
 	}
+
+	// Without a branch, follow the default branch of the repository
+	target := "origin/HEAD"
+	if strings.HasPrefix(ref, "refs/tags/") {
+		target = ref
+	} else if ref != "" && ref != "HEAD" {
+		target = "origin/" + ref
+	}
+	if err := su.runCommandInDir(repoDir, "git", "reset", "--hard", target); err != nil {
+		return fmt.Errorf("checking out %s: %w", ref, err)
+	}
 	return nil
 }
 
+// gitArgs prefixes a git subcommand with the configured git options
+func (su *SelfUpdater) gitArgs(args ...string) []string {
+	return append(append([]string{}, su.GitConfigArgs...), args...)
+}
+
 // readDeployConfig reads the deploy.config file
 func (su *SelfUpdater) readDeployConfig(configPath string) (interface{}, error) {
 	// For now, we'll read it as a simple map until we integrate the config package
@@ -336,6 +378,22 @@ func (su *SelfUpdater) HasBackup() bool {
 	return err == nil
 }
 
+// RemoveLeftovers removes the work directory and partially written
+// binaries an update or preview interrupted by a crash leaves behind. The
+// backup binary is kept for Rollback.
+func (su *SelfUpdater) RemoveLeftovers() {
+	for _, path := range []string{su.TempDir, filepath.Join(su.SelfUpdateDir, "preview"), su.CurrentBinaryPath + ".new", su.CurrentBinaryPath + ".rollback"} {
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			slog.Warn("Failed to remove self-update leftovers", "path", path, "error", err)
+			continue
+		}
+		slog.Info("Removed self-update leftovers", "path", path)
+	}
+}
+
 // cleanup removes temporary files
 func (su *SelfUpdater) cleanup() {
 	if err := os.RemoveAll(su.TempDir); err != nil {
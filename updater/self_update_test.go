@@ -0,0 +1,35 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveLeftovers_KeepsBackup(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "binaryDeploy")
+	su := NewSelfUpdater(binary, filepath.Join(dir, "self-update"))
+
+	if err := os.MkdirAll(filepath.Join(su.TempDir, "repo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{binary, binary + ".new", binary + ".rollback", su.BackupPath} {
+		if err := os.WriteFile(path, []byte("binary"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	su.RemoveLeftovers()
+
+	for _, path := range []string{su.TempDir, binary + ".new", binary + ".rollback"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	for _, path := range []string{binary, su.BackupPath} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", path, err)
+		}
+	}
+}
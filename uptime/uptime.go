@@ -0,0 +1,138 @@
+// Package uptime records whether the application was up at regular samples
+// and sums them into daily totals, so availability can be reported per month
+package uptime
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"binaryDeploy/kv"
+)
+
+// bucket holds one Day per calendar day (UTC), keyed by its date
+const bucket = "uptime"
+
+// Day sums the samples taken on one calendar day (UTC)
+type Day struct {
+	Date             string `json:"date"` // YYYY-MM-DD
+	MonitoredSeconds int64  `json:"monitored_seconds"`
+	UpSeconds        int64  `json:"up_seconds"`
+	Outages          int    `json:"outages"` // Times the application went down
+}
+
+// Store keeps daily totals in a key/value store
+type Store struct {
+	mu     sync.Mutex
+	db     kv.Store
+	wasUp  bool
+	primed bool
+}
+
+// NewStore returns a store backed by db
+func NewStore(db kv.Store) *Store {
+	return &Store{db: db}
+}
+
+// Record adds a sample covering interval that ended at at. A down sample
+// following an up one, or the first sample after startup, starts an
+// outage.
+func (s *Store) Record(at time.Time, interval time.Duration, up bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	date := at.UTC().Format(time.DateOnly)
+	day, err := s.day(date)
+	if err != nil {
+		return err
+	}
+	seconds := int64(interval.Round(time.Second) / time.Second)
+	day.MonitoredSeconds += seconds
+	if up {
+		day.UpSeconds += seconds
+	} else if s.wasUp || !s.primed {
+		day.Outages++
+	}
+	s.wasUp, s.primed = up, true
+
+	data, err := json.Marshal(day)
+	if err != nil {
+		return fmt.Errorf("encoding uptime: %w", err)
+	}
+	if err := s.db.Put(bucket, date, data); err != nil {
+		return fmt.Errorf("writing uptime: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) day(date string) (Day, error) {
+	data, err := s.db.Get(bucket, date)
+	if err == kv.ErrNotFound {
+		return Day{Date: date}, nil
+	}
+	if err != nil {
+		return Day{}, fmt.Errorf("reading uptime: %w", err)
+	}
+	var day Day
+	if err := json.Unmarshal(data, &day); err != nil {
+		return Day{Date: date}, nil
+	}
+	return day, nil
+}
+
+// Month returns the recorded days of the month containing month, in date
+// order. Days without samples are omitted.
+func (s *Store) Month(month time.Time) ([]Day, error) {
+	entries, err := s.db.List(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("reading uptime: %w", err)
+	}
+	prefix := month.UTC().Format("2006-01-")
+	days := []Day{}
+	for _, e := range entries {
+		if len(e.Key) < len(prefix) || e.Key[:len(prefix)] != prefix {
+			continue
+		}
+		var day Day
+		if err := json.Unmarshal(e.Value, &day); err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// Summary totals a month of days
+type Summary struct {
+	MonitoredSeconds int64 `json:"monitored_seconds"`
+	UpSeconds        int64 `json:"up_seconds"`
+	DownSeconds      int64 `json:"down_seconds"`
+	Outages          int   `json:"outages"`
+
+	// Availability is the percentage of monitored time the application was
+	// up; nil when nothing was monitored
+	Availability *float64 `json:"availability_percent"`
+	// Coverage is the percentage of the period that was monitored, lower
+	// while binaryDeploy itself was down
+	Coverage float64 `json:"coverage_percent"`
+}
+
+// Summarize totals days over a period lasting period
+func Summarize(days []Day, period time.Duration) Summary {
+	var sum Summary
+	for _, d := range days {
+		sum.MonitoredSeconds += d.MonitoredSeconds
+		sum.UpSeconds += d.UpSeconds
+		sum.Outages += d.Outages
+	}
+	sum.DownSeconds = sum.MonitoredSeconds - sum.UpSeconds
+	if sum.MonitoredSeconds > 0 {
+		availability := 100 * float64(sum.UpSeconds) / float64(sum.MonitoredSeconds)
+		sum.Availability = &availability
+	}
+	if period > 0 {
+		sum.Coverage = min(100, 100*float64(sum.MonitoredSeconds)/period.Seconds())
+	}
+	return sum
+}
@@ -0,0 +1,86 @@
+package uptime
+
+import (
+	"testing"
+	"time"
+
+	"binaryDeploy/kv"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := kv.OpenFile(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	return NewStore(db)
+}
+
+func TestStore_RecordAndMonth(t *testing.T) {
+	store := newTestStore(t)
+
+	start := time.Date(2026, 9, 30, 23, 59, 0, 0, time.UTC)
+	samples := []bool{true, true, false, false, true, false}
+	for i, up := range samples {
+		if err := store.Record(start.Add(time.Duration(i)*30*time.Second), 30*time.Second, up); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	september, err := store.Month(time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Month failed: %v", err)
+	}
+	if len(september) != 1 || september[0].MonitoredSeconds != 60 || september[0].UpSeconds != 60 {
+		t.Errorf("unexpected September days: %+v", september)
+	}
+
+	october, err := store.Month(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Month failed: %v", err)
+	}
+	if len(october) != 1 {
+		t.Fatalf("expected 1 October day, got %+v", october)
+	}
+	day := october[0]
+	if day.Date != "2026-10-01" || day.MonitoredSeconds != 120 || day.UpSeconds != 30 {
+		t.Errorf("unexpected October day: %+v", day)
+	}
+	if day.Outages != 2 {
+		t.Errorf("expected 2 outages, got %d", day.Outages)
+	}
+}
+
+func TestStore_FirstSampleDown(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Date(2026, 10, 5, 12, 0, 0, 0, time.UTC)
+	store.Record(now, time.Minute, false)
+	store.Record(now.Add(time.Minute), time.Minute, false)
+
+	days, _ := store.Month(now)
+	if len(days) != 1 || days[0].Outages != 1 {
+		t.Errorf("expected one outage starting at the first sample, got %+v", days)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	days := []Day{
+		{Date: "2026-10-01", MonitoredSeconds: 86400, UpSeconds: 86400},
+		{Date: "2026-10-02", MonitoredSeconds: 43200, UpSeconds: 42336, Outages: 2},
+	}
+	sum := Summarize(days, 4*24*time.Hour)
+	if sum.DownSeconds != 864 || sum.Outages != 2 {
+		t.Errorf("unexpected totals: %+v", sum)
+	}
+	if sum.Availability == nil || *sum.Availability != 99.33333333333333 {
+		t.Errorf("unexpected availability: %v", sum.Availability)
+	}
+	if sum.Coverage != 37.5 {
+		t.Errorf("expected coverage 37.5, got %v", sum.Coverage)
+	}
+
+	empty := Summarize(nil, time.Hour)
+	if empty.Availability != nil || empty.Coverage != 0 {
+		t.Errorf("expected no availability without samples, got %+v", empty)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"binaryDeploy/health"
+	"binaryDeploy/history"
+	"binaryDeploy/kv"
+	"binaryDeploy/notify"
+	"binaryDeploy/remote"
+	"binaryDeploy/uptime"
+)
+
+// uptimeSampleInterval is how often the application is checked for the
+// uptime report
+const uptimeSampleInterval = 30 * time.Second
+
+// uptimeReportsBucket remembers the last month whose report was sent
+const uptimeReportsBucket = "uptime-reports"
+
+// uptimeStore holds the daily uptime totals; nil without storage
+var uptimeStore *uptime.Store
+
+// setupUptimeTracking opens the uptime totals kept in the data store
+func setupUptimeTracking() {
+	if dataStore == nil {
+		return
+	}
+	uptimeStore = uptime.NewStore(dataStore)
+}
+
+// startUptimeTracker samples whether the application is up every
+// uptimeSampleInterval and, with uptime_report_notify, sends each month's
+// report when the month is over
+func startUptimeTracker() {
+	if uptimeStore == nil {
+		return
+	}
+
+	go func() {
+		last := time.Now()
+		ticker := time.NewTicker(uptimeSampleInterval)
+		for now := range ticker.C {
+			// A longer gap means the host was suspended; nobody watched the
+			// application meanwhile
+			interval := now.Sub(last)
+			if interval > 2*uptimeSampleInterval {
+				interval = uptimeSampleInterval
+			}
+			last = now
+
+			up, ok := appIsUp()
+			if !ok {
+				continue
+			}
+			if err := uptimeStore.Record(now, interval, up); err != nil {
+				slog.Warn("Failed to record uptime sample", "error", err)
+			}
+		}
+	}()
+
+	if appConfig().UptimeReportNotify {
+		go sendMonthlyUptimeReports()
+	}
+}
+
+// appIsUp reports whether the application is running and passes the
+// health check. Remote deployments are only checked through
+// health_check_url on every host; ok is false when there is nothing to
+// check.
+func appIsUp() (up, ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if appConfig().DeployDriver != "local" {
+		if appConfig().HealthCheckURL == "" {
+			return false, false
+		}
+		for _, spec := range appConfig().SSHHosts {
+			host, err := remote.ParseHost(spec)
+			if err != nil {
+				return false, false
+			}
+			if health.Check(ctx, remoteURL(host, appConfig().HealthCheckURL)) != nil {
+				return false, true
+			}
+		}
+		return true, true
+	}
+
+	if !processManager.IsRunning() {
+		return false, true
+	}
+	if appConfig().HealthCheckURL == "" {
+		return true, true
+	}
+	return health.Check(ctx, appURL(appConfig().HealthCheckURL)) == nil, true
+}
+
+// deploymentStats sums the deployments of a report's period
+type deploymentStats struct {
+	Total          int   `json:"total"`
+	Succeeded      int   `json:"succeeded"`
+	Failed         int   `json:"failed"`
+	Skipped        int   `json:"skipped"`
+	Restarts       int   `json:"restarts"`
+	MeanDurationMS int64 `json:"mean_duration_ms"` // Over succeeded and failed deployments
+}
+
+// uptimeReport is the availability and deployment activity of one month
+type uptimeReport struct {
+	App         string          `json:"app"`
+	Month       string          `json:"month"` // YYYY-MM
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"` // The end of the month, or now for the current month
+	Generated   time.Time       `json:"generated"`
+	Uptime      uptime.Summary  `json:"uptime"`
+	Deployments deploymentStats `json:"deployments"`
+	Days        []uptime.Day    `json:"days"`
+}
+
+// buildUptimeReport reports on the month starting at from (UTC)
+func buildUptimeReport(from time.Time) (uptimeReport, error) {
+	now := time.Now().UTC()
+	to := from.AddDate(0, 1, 0)
+	report := uptimeReport{
+		App:       appConfig().AppName,
+		Month:     from.Format("2006-01"),
+		From:      from,
+		To:        to,
+		Generated: now,
+		Days:      []uptime.Day{},
+	}
+
+	if now.Before(to) {
+		report.To = now
+	}
+
+	if uptimeStore != nil {
+		days, err := uptimeStore.Month(from)
+		if err != nil {
+			return report, err
+		}
+		report.Days = days
+	}
+	report.Uptime = uptime.Summarize(report.Days, max(report.To.Sub(from), 0))
+
+	if deployHistory != nil {
+		records, err := deployHistory.Query(0, func(rec history.Record) bool {
+			return !rec.StartedAt.Before(from) && rec.StartedAt.Before(to)
+		})
+		if err != nil {
+			return report, err
+		}
+		stats := &report.Deployments
+		var totalMS int64
+		for _, rec := range records {
+			switch {
+			case rec.Kind == history.KindRestart:
+				stats.Restarts++
+				continue
+			case rec.Kind != history.KindDeploy:
+				continue
+			}
+			stats.Total++
+			switch rec.Result {
+			case history.ResultSuccess:
+				stats.Succeeded++
+			case history.ResultFailure:
+				stats.Failed++
+			default:
+				stats.Skipped++
+				continue
+			}
+			totalMS += rec.DurationMS
+		}
+		if n := stats.Succeeded + stats.Failed; n > 0 {
+			stats.MeanDurationMS = totalMS / int64(n)
+		}
+	}
+	return report, nil
+}
+
+// availabilityText formats an availability percentage, or "n/a"
+func availabilityText(availability *float64) string {
+	if availability == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.3f%%", *availability)
+}
+
+// Summary describes the report in a few lines of plain text
+func (r uptimeReport) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Uptime report for %s, %s\n\n", r.App, r.From.Format("January 2006"))
+	fmt.Fprintf(&b, "Availability: %s (%d outages, %s down, %.1f%% of the month monitored)\n",
+		availabilityText(r.Uptime.Availability), r.Uptime.Outages,
+		(time.Duration(r.Uptime.DownSeconds) * time.Second).String(), r.Uptime.Coverage)
+	fmt.Fprintf(&b, "Deployments: %d (%d succeeded, %d failed, %d skipped), %d restarts\n",
+		r.Deployments.Total, r.Deployments.Succeeded, r.Deployments.Failed, r.Deployments.Skipped, r.Deployments.Restarts)
+	fmt.Fprintf(&b, "Mean deployment duration: %s\n", (time.Duration(r.Deployments.MeanDurationMS) * time.Millisecond).String())
+	return b.String()
+}
+
+var uptimeReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"availability": availabilityText,
+	"seconds":      func(s int64) string { return (time.Duration(s) * time.Second).String() },
+	"millis":       func(ms int64) string { return (time.Duration(ms) * time.Millisecond).String() },
+	"dayDowntime":  func(d uptime.Day) int64 { return d.MonitoredSeconds - d.UpSeconds },
+	"dayAvailability": func(d uptime.Day) string {
+		if d.MonitoredSeconds == 0 {
+			return "n/a"
+		}
+		return fmt.Sprintf("%.3f%%", 100*float64(d.UpSeconds)/float64(d.MonitoredSeconds))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.App}} uptime report {{.Month}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #333; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ddd; padding: 6px 12px; text-align: left; }
+th { background: #f5f5f5; }
+</style>
+</head>
+<body>
+<h1>{{.App}} &mdash; {{.From.Format "January 2006"}}</h1>
+<p>{{.From.Format "2006-01-02 15:04 MST"}} to {{.To.Format "2006-01-02 15:04 MST"}}, generated {{.Generated.Format "2006-01-02 15:04 MST"}}</p>
+<h2>Availability</h2>
+<table>
+<tr><th>Availability</th><td>{{availability .Uptime.Availability}}</td></tr>
+<tr><th>Outages</th><td>{{.Uptime.Outages}}</td></tr>
+<tr><th>Downtime</th><td>{{seconds .Uptime.DownSeconds}}</td></tr>
+<tr><th>Monitored</th><td>{{printf "%.1f" .Uptime.Coverage}}% of the period</td></tr>
+</table>
+<h2>Deployments</h2>
+<table>
+<tr><th>Deployments</th><td>{{.Deployments.Total}}</td></tr>
+<tr><th>Succeeded</th><td>{{.Deployments.Succeeded}}</td></tr>
+<tr><th>Failed</th><td>{{.Deployments.Failed}}</td></tr>
+<tr><th>Skipped</th><td>{{.Deployments.Skipped}}</td></tr>
+<tr><th>Restarts</th><td>{{.Deployments.Restarts}}</td></tr>
+<tr><th>Mean duration</th><td>{{millis .Deployments.MeanDurationMS}}</td></tr>
+</table>
+<h2>Daily</h2>
+<table>
+<tr><th>Date</th><th>Availability</th><th>Outages</th><th>Downtime</th></tr>
+{{range .Days}}<tr><td>{{.Date}}</td><td>{{dayAvailability .}}</td><td>{{.Outages}}</td><td>{{seconds (dayDowntime .)}}</td></tr>
+{{else}}<tr><td colspan="4">No samples recorded</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// uptimeReportHandler handles GET /reports/uptime?month=YYYY-MM&format=json|html,
+// reporting availability and deployments for a month (default the current
+// one)
+func uptimeReportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	from := monthStart(time.Now())
+	if month := r.URL.Query().Get("month"); month != "" {
+		t, err := time.Parse("2006-01", month)
+		if err != nil {
+			http.Error(w, "month must be YYYY-MM", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "html" {
+		http.Error(w, "format must be json or html", http.StatusBadRequest)
+		return
+	}
+
+	report, err := buildUptimeReport(from)
+	if err != nil {
+		slog.Error("Failed to build uptime report", "month", report.Month, "error", err)
+		http.Error(w, "Failed to build uptime report", http.StatusInternalServerError)
+		return
+	}
+
+	if format == "html" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := uptimeReportTemplate.Execute(w, report); err != nil {
+			slog.Error("Failed to render uptime report", "month", report.Month, "error", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// monthStart returns midnight UTC on the first of t's month
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// sendMonthlyUptimeReports sends the previous month's report to the
+// notifiers as an uptime_report event, once per month, catching up after
+// binaryDeploy was down at the turn of the month
+func sendMonthlyUptimeReports() {
+	for {
+		previous := monthStart(time.Now()).AddDate(0, -1, 0)
+		month := previous.Format("2006-01")
+
+		sent, err := dataStore.Get(uptimeReportsBucket, "last_sent")
+		if err != nil && err != kv.ErrNotFound {
+			slog.Warn("Failed to read the last uptime report sent", "error", err)
+		}
+		if err == kv.ErrNotFound || (err == nil && string(sent) < month) {
+			sendUptimeReport(previous)
+			if err := dataStore.Put(uptimeReportsBucket, "last_sent", []byte(month)); err != nil {
+				slog.Warn("Failed to remember the last uptime report sent", "month", month, "error", err)
+			}
+		}
+
+		next := monthStart(time.Now()).AddDate(0, 1, 0)
+		time.Sleep(time.Until(next) + time.Minute)
+	}
+}
+
+// sendUptimeReport notifies the report of the month starting at from,
+// unless nothing was recorded during it
+func sendUptimeReport(from time.Time) {
+	report, err := buildUptimeReport(from)
+	if err != nil {
+		slog.Error("Failed to build uptime report", "month", report.Month, "error", err)
+		return
+	}
+	if report.Uptime.MonitoredSeconds == 0 && report.Deployments.Total == 0 && report.Deployments.Restarts == 0 {
+		return
+	}
+
+	slog.Info("Sending uptime report", "app", appConfig().AppName, "month", report.Month,
+		"availability", availabilityText(report.Uptime.Availability), "deployments", report.Deployments.Total)
+	notifier().Notify(notify.Event{
+		Type:    notify.EventUptimeReport,
+		App:     appConfig().AppName,
+		Message: report.Summary(),
+		Fields: map[string]interface{}{
+			"month":       report.Month,
+			"uptime":      report.Uptime,
+			"deployments": report.Deployments,
+			"report_url":  appConfig().BasePath + "/reports/uptime?month=" + report.Month + "&format=html",
+		},
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"binaryDeploy/health"
+	"binaryDeploy/processmanager"
+)
+
+// appURL turns a configured path like "/healthz" into a URL on the
+// application's port, that of the live release under blue-green
+// deployments; absolute URLs are returned unchanged
+func appURL(pathOrURL string) string {
+	return instanceURL(pathOrURL, liveInstance())
+}
+
+// instanceURL is appURL for a specific instance's port
+func instanceURL(pathOrURL string, instance int) string {
+	if strings.HasPrefix(pathOrURL, "/") {
+		return fmt.Sprintf("http://127.0.0.1:%d%s", processmanager.InstancePort(appConfig(), instance), pathOrURL)
+	}
+	return pathOrURL
+}
+
+// waitForInstanceReady blocks until an instance notifies readiness, with
+// readiness_notify, and passes the configured health check. Without either
+// the instance is considered ready as soon as it starts.
+func waitForInstanceReady(instance int) error {
+	if appConfig().ReadinessNotify {
+		if err := waitForNotifyReady(func(ctx context.Context) error {
+			return processManager.WaitInstanceReady(ctx, instance)
+		}); err != nil {
+			return err
+		}
+	}
+	if appConfig().HealthCheckURL == "" {
+		return nil
+	}
+
+	return waitForURLReady(instanceURL(appConfig().HealthCheckURL, instance))
+}
+
+// waitForURLReady blocks until url passes the health check or
+// health_check_timeout runs out
+func waitForURLReady(url string) error {
+	timeout := time.Duration(appConfig().HealthCheckTimeout) * time.Second
+	slog.Info("Waiting for application health check", "url", url, "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := health.WaitForHealthy(ctx, url, time.Second); err != nil {
+		return err
+	}
+
+	slog.Info("Application health check passed", "url", url)
+	return nil
+}
+
+// waitForNotifyReady waits up to health_check_timeout for wait, which
+// returns once the application sent READY=1 to its NOTIFY_SOCKET
+func waitForNotifyReady(wait func(ctx context.Context) error) error {
+	timeout := time.Duration(appConfig().HealthCheckTimeout) * time.Second
+	slog.Info("Waiting for application readiness notification", "timeout", timeout.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := wait(ctx); err != nil {
+		return err
+	}
+
+	slog.Info("Application notified readiness")
+	return nil
+}
+
+// warmUpApp hits the configured warmup_urls so caches and JIT are primed
+// before the deployment is reported as complete
+func warmUpApp() {
+	warmUpURLs(appURL)
+}
+
+// warmUpURLs requests the warmup_urls, resolving each with resolve
+func warmUpURLs(resolve func(string) string) {
+	if len(appConfig().WarmupURLs) == 0 {
+		return
+	}
+
+	urls := make([]string, len(appConfig().WarmupURLs))
+	for i, u := range appConfig().WarmupURLs {
+		urls[i] = resolve(u)
+	}
+
+	slog.Info("Warming up application", "urls", len(urls))
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	failed := 0
+	for _, result := range health.Warmup(ctx, urls) {
+		if result.Error != "" || result.StatusCode >= 400 {
+			failed++
+		}
+	}
+
+	slog.Info("Application warm-up finished", "requests", len(urls), "failed", failed, "duration", time.Since(start).String())
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"binaryDeploy/config"
+)
+
+// testAppPort returns the port of server, standing in for the application
+func testAppPort(t *testing.T, server *httptest.Server) int {
+	t.Helper()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+func TestWaitForInstanceReady_WaitsForHealthCheck(t *testing.T) {
+	var checks atomic.Int32
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || checks.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer app.Close()
+
+	currentConfig.Store(&config.DeployConfig{
+		ApplicationPort:    testAppPort(t, app),
+		HealthCheckURL:     "/healthz",
+		HealthCheckTimeout: 10,
+	})
+	if err := waitForInstanceReady(0); err != nil {
+		t.Fatalf("Expected the application to become ready, got %v", err)
+	}
+	if n := checks.Load(); n != 3 {
+		t.Errorf("Expected readiness after the first passing check, got %d checks", n)
+	}
+
+	currentConfig.Store(&config.DeployConfig{
+		ApplicationPort:    testAppPort(t, app),
+		HealthCheckURL:     "/missing",
+		HealthCheckTimeout: 1,
+	})
+	if err := waitForInstanceReady(0); err == nil {
+		t.Error("Expected a health check that never passes to fail once health_check_timeout runs out")
+	}
+}
+
+func TestWarmUpApp_RequestsEachURLInOrder(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.RequestURI())
+		mu.Unlock()
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer app.Close()
+
+	currentConfig.Store(&config.DeployConfig{
+		ApplicationPort: testAppPort(t, app),
+		WarmupURLs:      []string{"/", "/broken", "/api/products?limit=100", app.URL + "/absolute"},
+	})
+	warmUpApp()
+
+	want := []string{"/", "/broken", "/api/products?limit=100", "/absolute"}
+	if !slices.Equal(requested, want) {
+		t.Errorf("Expected warm-up requests %v, got %v", want, requested)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// maxWebhookPayloadSize bounds the body accepted by /webhook; GitHub caps
+// push payloads at 25 MB
+const maxWebhookPayloadSize = 25 << 20
+
+// readHeaderTimeout bounds how long a client may take to send request headers
+const readHeaderTimeout = 10 * time.Second
+
+// webhookBodyError is a body that couldn't be read, with the status to
+// answer it with
+type webhookBodyError struct {
+	status int
+	err    error
+}
+
+func (e *webhookBodyError) Error() string { return e.err.Error() }
+
+func (e *webhookBodyError) Unwrap() error { return e.err }
+
+// readWebhookBody reads at most limit bytes of r's body, which must arrive
+// within webhook_body_timeout, so slow or endlessly chunked bodies can't tie
+// up handler goroutines. Each chunk is also written to mac, if not nil, as it
+// arrives. Errors are *webhookBodyError.
+func readWebhookBody(w http.ResponseWriter, r *http.Request, limit int64, mac hash.Hash) ([]byte, error) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(time.Duration(appConfig().WebhookTimeout) * time.Second)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		slog.DebugContext(r.Context(), "Cannot set a read deadline for the webhook body", "error", err)
+	} else {
+		defer rc.SetReadDeadline(time.Time{})
+	}
+	defer r.Body.Close()
+
+	var body io.Reader = io.LimitReader(r.Body, limit+1)
+	if mac != nil {
+		body = io.TeeReader(body, mac)
+	}
+	data, err := io.ReadAll(body)
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return nil, &webhookBodyError{http.StatusRequestTimeout,
+			fmt.Errorf("body not received within %ds (%d bytes read)", appConfig().WebhookTimeout, len(data))}
+	case err != nil:
+		return nil, &webhookBodyError{http.StatusBadRequest, err}
+	case int64(len(data)) > limit:
+		return nil, &webhookBodyError{http.StatusRequestEntityTooLarge, fmt.Errorf("body exceeds %d bytes", limit)}
+	}
+	return data, nil
+}
+
+// writeWebhookBodyError answers a request whose body readWebhookBody
+// rejected
+func writeWebhookBodyError(w http.ResponseWriter, err error) {
+	var bodyErr *webhookBodyError
+	if !errors.As(err, &bodyErr) {
+		bodyErr = &webhookBodyError{http.StatusBadRequest, err}
+	}
+	switch bodyErr.status {
+	case http.StatusRequestTimeout:
+		// The connection can't be reused with part of the body unread
+		w.Header().Set("Connection", "close")
+		http.Error(w, "Request body timeout", bodyErr.status)
+	case http.StatusRequestEntityTooLarge:
+		http.Error(w, "Payload too large", bodyErr.status)
+	default:
+		http.Error(w, "Failed to read body", bodyErr.status)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"binaryDeploy/config"
+)
+
+func TestReadWebhookBody(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{WebhookTimeout: 1})
+
+	type result struct {
+		body   string
+		digest string
+		status int
+	}
+	results := make(chan result, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		body, err := readWebhookBody(w, r, 64, mac)
+		var bodyErr *webhookBodyError
+		if errors.As(err, &bodyErr) {
+			results <- result{status: bodyErr.status}
+			writeWebhookBodyError(w, err)
+			return
+		}
+		results <- result{body: string(body), digest: hex.EncodeToString(mac.Sum(nil)), status: http.StatusOK}
+	}))
+	defer server.Close()
+
+	post := func(body io.Reader) result {
+		resp, err := http.Post(server.URL, "application/json", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		res := <-results
+		if resp.StatusCode != res.status {
+			t.Errorf("Expected the response status %d, got %d", res.status, resp.StatusCode)
+		}
+		return res
+	}
+
+	payload := `{"ref": "refs/heads/main"}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(payload))
+	if res := post(strings.NewReader(payload)); res.status != http.StatusOK || res.body != payload || res.digest != hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("Expected the body and its HMAC, got %+v", res)
+	}
+
+	if res := post(strings.NewReader(strings.Repeat("x", 65))); res.status != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected an oversized body to be refused with 413, got %d", res.status)
+	}
+
+	slow, writer := io.Pipe()
+	go func() {
+		fmt.Fprint(writer, `{"ref":`)
+		time.Sleep(2 * time.Second)
+		writer.Close()
+	}()
+	start := time.Now()
+	if res := post(slow); res.status != http.StatusRequestTimeout {
+		t.Errorf("Expected a body slower than webhook_body_timeout to be refused with 408, got %d", res.status)
+	}
+	if elapsed := time.Since(start); elapsed > 1900*time.Millisecond {
+		t.Errorf("Expected the read to give up after webhook_body_timeout, took %s", elapsed)
+	}
+}
+
+func TestWebhookHandler_VerifiesStreamedSignature(t *testing.T) {
+	currentConfig.Store(&config.DeployConfig{Secret: "webhook-secret", WebhookTimeout: 10, AuthFailureThreshold: 10})
+	setupAuthGuard()
+
+	payload := `{"ref": "refs/heads/main", "repository": {"clone_url": "https://git.example.com/team/app.git"}}`
+	for _, tt := range []struct {
+		name, signature string
+	}{
+		{"missing signature", ""},
+		{"wrong secret", "sha256=" + hex.EncodeToString(hmac.New(sha256.New, []byte("wrong")).Sum(nil))},
+		{"malformed signature", "sha1=abc"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "push")
+		if tt.signature != "" {
+			req.Header.Set("X-Hub-Signature-256", tt.signature)
+		}
+		rec := httptest.NewRecorder()
+		webhookHandler(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d %s", tt.name, rec.Code, rec.Body)
+		}
+	}
+}